@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/apicheck"
+)
+
+func main() {
+	var addr, auth string
+	var write bool
+	flag.StringVar(&addr, "addr", "http://127.0.0.1:7070", "address of the instance, including the base path")
+	flag.StringVar(&auth, "auth", "", "credentials in the form of `username:password`")
+	flag.BoolVar(&write, "write", false, "also run the checks which change (and restore) item statuses")
+	flag.Parse()
+
+	cfg := apicheck.Config{URL: addr, Write: write}
+	if auth != "" {
+		parts := strings.SplitN(auth, ":", 2)
+		if len(parts) != 2 {
+			log.Fatal("invalid auth format, expected username:password")
+		}
+		cfg.Username, cfg.Password = parts[0], parts[1]
+	}
+
+	results, err := apicheck.Run(cfg)
+	if err != nil {
+		log.Fatalf("failed to connect to %s: %s", addr, err)
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %s\n", result.Name, result.Err)
+		} else {
+			fmt.Printf("ok   %s\n", result.Name)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d checks failed\n", failed, len(results))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/server"
+	"github.com/nkanaev/yarr/src/server/acl"
+)
+
+// configFile holds the values read from the file passed with -config.
+// Its entries use the same names as the environment variables
+// (`YARR_AUTH=username:password`) and have lower precedence than them.
+var configFile = make(map[string]string)
+
+func parseConfig(r io.Reader) (map[string]string, error) {
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: wrong syntax (expected `KEY=value`)", n)
+		}
+		config[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return config, scanner.Err()
+}
+
+func readConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseConfig(f)
+}
+
+// configPathFromArgs finds the -config flag before the flags are defined,
+// since the file provides their default values.
+func configPathFromArgs(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if arg == name {
+			continue
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config=")
+		}
+	}
+	return ""
+}
+
+// liveOptions are the options that can be changed without a restart.
+type liveOptions struct {
+	auth, authfile, allow, authbypass, trustedproxies string
+}
+
+func (o liveOptions) config() (server.LiveConfig, error) {
+	var cfg server.LiveConfig
+	var err error
+	if o.authfile != "" {
+		f, err := os.Open(o.authfile)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to open auth file: %w", err)
+		}
+		defer f.Close()
+		cfg.Username, cfg.Password, err = parseAuthfile(f)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse auth file: %w", err)
+		}
+	} else if o.auth != "" {
+		cfg.Username, cfg.Password, err = parseAuthfile(strings.NewReader(o.auth))
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse auth literal: %w", err)
+		}
+	}
+	if cfg.AllowedNetworks, err = acl.ParseNetworks(o.allow); err != nil {
+		return cfg, fmt.Errorf("failed to parse allowed networks: %w", err)
+	}
+	if cfg.AuthBypassNetworks, err = acl.ParseNetworks(o.authbypass); err != nil {
+		return cfg, fmt.Errorf("failed to parse auth bypass networks: %w", err)
+	}
+	if cfg.TrustedProxies, err = acl.ParseNetworks(o.trustedproxies); err != nil {
+		return cfg, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logTimeFormat = "2006-01-02T15-04-05.000"
+
+// logFile is an io.Writer appending to the file at path. Once the file
+// grows past maxSize bytes it's renamed to `name-<timestamp>.ext` and
+// a new one is started. Rotated files older than maxAge, or beyond the
+// newest maxBackups, are removed. Zero values disable the limits.
+type logFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openLogFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*logFile, error) {
+	l := &logFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	l.cleanup()
+	return l, nil
+}
+
+func (l *logFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to rotate log file:", err)
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func (l *logFile) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.backupName(time.Now())); err != nil {
+		// keep logging into the old file rather than losing the output
+		if openErr := l.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	l.cleanup()
+	return nil
+}
+
+func (l *logFile) backupName(t time.Time) string {
+	ext := filepath.Ext(l.path)
+	return strings.TrimSuffix(l.path, ext) + "-" + t.Format(logTimeFormat) + ext
+}
+
+type logBackup struct {
+	path    string
+	created time.Time
+}
+
+// backups returns the rotated files, newest first.
+func (l *logFile) backups() []logBackup {
+	dir := filepath.Dir(l.path)
+	ext := filepath.Ext(l.path)
+	prefix := filepath.Base(strings.TrimSuffix(l.path, ext)) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	backups := make([]logBackup, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		created, err := time.ParseInLocation(logTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, logBackup{path: filepath.Join(dir, name), created: created})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].created.After(backups[j].created)
+	})
+	return backups
+}
+
+func (l *logFile) cleanup() {
+	for i, backup := range l.backups() {
+		if (l.maxBackups > 0 && i >= l.maxBackups) || (l.maxAge > 0 && time.Since(backup.created) > l.maxAge) {
+			os.Remove(backup.path)
+		}
+	}
+}
@@ -7,12 +7,21 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/nkanaev/yarr/src/assets"
+	"github.com/nkanaev/yarr/src/client"
+	"github.com/nkanaev/yarr/src/notify"
 	"github.com/nkanaev/yarr/src/platform"
 	"github.com/nkanaev/yarr/src/server"
 	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/tracing"
+	"github.com/nkanaev/yarr/src/tui"
 )
 
 var Version string = "0.0"
@@ -26,9 +35,20 @@ func opt(envVar, defaultValue string) string {
 	if value != "" {
 		return value
 	}
+	if value, ok := configFile[envVar]; ok {
+		return value
+	}
 	return defaultValue
 }
 
+func optInt(envVar string, defaultValue int) int {
+	value, err := strconv.Atoi(opt(envVar, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func parseAuthfile(authfile io.Reader) (username, password string, err error) {
 	scanner := bufio.NewScanner(authfile)
 	for scanner.Scan() {
@@ -47,29 +67,67 @@ func parseAuthfile(authfile io.Reader) (username, password string, err error) {
 func main() {
 	platform.FixConsoleIfNeeded()
 
-	var addr, db, authfile, auth, certfile, keyfile, basepath, logfile string
-	var ver, open bool
+	var addr, db, certfile, keyfile, basepath, logfile, downloaddir, otlpendpoint, config, mailtoken, themesdir, publicurl, secretkeyfile string
+	var live liveOptions
+	var ver, open, checkdb, notifyExec bool
+	var maxContentSize, downloadQuota, rateLimit int
+	var logMaxSize, logMaxAge, logMaxBackups int
+	var dbTuning storage.Tuning
+	var dbMmapSize int
 
 	flag.CommandLine.SetOutput(os.Stdout)
 
+	if path := configPathFromArgs(os.Args[1:]); path != "" {
+		var err error
+		if configFile, err = readConfig(path); err != nil {
+			log.Fatal("Failed to read config file: ", err)
+		}
+	}
+
 	flag.Usage = func() {
 		out := flag.CommandLine.Output()
-		fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(out, "Usage: %s [flags] [command]\n", os.Args[0])
 		flag.PrintDefaults()
-		fmt.Fprintln(out, "\nThe environmental variables, if present, will be used to provide\nthe default values for the params above:")
+		fmt.Fprintln(out, "\nCommands:")
+		fmt.Fprintln(out, "  reindex\n    \trebuild the search index and the unread counts, then exit")
+		fmt.Fprintln(out, "  tui [-server url] [-auth username:password]\n    \tbrowse the items in the terminal, from a running server or the storage file")
+		fmt.Fprintln(out, "\nThe environmental variables, if present, will be used to provide\nthe default values for the params above (the config file may set them too):")
 		fmt.Fprintln(out, " ", strings.Join(OptList, ", "))
 	}
 
 	flag.StringVar(&addr, "addr", opt("YARR_ADDR", "127.0.0.1:7070"), "address to run server on")
 	flag.StringVar(&basepath, "base", opt("YARR_BASE", ""), "base path of the service url")
-	flag.StringVar(&authfile, "auth-file", opt("YARR_AUTHFILE", ""), "`path` to a file containing username:password. Takes precedence over --auth (or YARR_AUTH)")
-	flag.StringVar(&auth, "auth", opt("YARR_AUTH", ""), "string with username and password in the format `username:password`")
+	flag.StringVar(&config, "config", "", "`path` to a file with YARR_*=value lines. Auth and network options are re-read on SIGHUP")
+	flag.StringVar(&live.authfile, "auth-file", opt("YARR_AUTHFILE", ""), "`path` to a file containing username:password. Takes precedence over --auth (or YARR_AUTH)")
+	flag.StringVar(&live.auth, "auth", opt("YARR_AUTH", ""), "string with username and password in the format `username:password`")
+	flag.StringVar(&live.allow, "allow", opt("YARR_ALLOW", ""), "comma-separated `networks` (CIDRs or IPs) allowed to access the server, all if empty")
+	flag.StringVar(&live.authbypass, "auth-bypass", opt("YARR_AUTH_BYPASS", ""), "comma-separated `networks` (CIDRs or IPs) that can access the server without logging in")
+	flag.StringVar(&live.trustedproxies, "trusted-proxies", opt("YARR_TRUSTED_PROXIES", ""), "comma-separated `networks` (CIDRs or IPs) of reverse proxies whose X-Forwarded-* headers are honored")
 	flag.StringVar(&certfile, "cert-file", opt("YARR_CERTFILE", ""), "`path` to cert file for https")
 	flag.StringVar(&keyfile, "key-file", opt("YARR_KEYFILE", ""), "`path` to key file for https")
 	flag.StringVar(&db, "db", opt("YARR_DB", ""), "storage file `path`")
+	flag.StringVar(&dbTuning.JournalMode, "db-journal-mode", opt("YARR_DB_JOURNAL_MODE", ""), "SQLite journal `mode` of the storage file (e.g. wal), SQLite's default if empty")
+	flag.StringVar(&dbTuning.Synchronous, "db-synchronous", opt("YARR_DB_SYNCHRONOUS", ""), "SQLite synchronous `level` (off, normal, full or extra), SQLite's default if empty")
+	flag.IntVar(&dbTuning.BusyTimeout, "db-busy-timeout", optInt("YARR_DB_BUSY_TIMEOUT", storage.SQLiteTuning.BusyTimeout), "`milliseconds` a query waits for the storage file to be unlocked before failing with \"database is locked\"")
+	flag.IntVar(&dbTuning.CacheSize, "db-cache-size", optInt("YARR_DB_CACHE_SIZE", 0), "SQLite page cache size in `kibibytes` (0 for SQLite's default)")
+	flag.IntVar(&dbMmapSize, "db-mmap-size", optInt("YARR_DB_MMAP_SIZE", 0), "`megabytes` of the storage file to memory-map (0 to disable)")
+	flag.StringVar(&secretkeyfile, "secret-key-file", opt("YARR_SECRET_KEY_FILE", ""), "`path` to the key encrypting the credentials of feeds, created if missing (default: the storage file path + .key)")
 	flag.StringVar(&logfile, "log-file", opt("YARR_LOGFILE", ""), "`path` to log file to use instead of stdout")
+	flag.IntVar(&logMaxSize, "log-max-size", optInt("YARR_LOG_MAX_SIZE", 10), "rotate the log file once it exceeds `megabytes` (0 to disable)")
+	flag.IntVar(&logMaxAge, "log-max-age", optInt("YARR_LOG_MAX_AGE", 30), "remove rotated log files older than `days` (0 to keep)")
+	flag.IntVar(&logMaxBackups, "log-max-backups", optInt("YARR_LOG_MAX_BACKUPS", 5), "maximum `number` of rotated log files to keep (0 to keep all)")
+	flag.IntVar(&maxContentSize, "max-content-size", optInt("YARR_MAX_CONTENT_SIZE", storage.MaxItemContentSize), "maximum item content size in `bytes` (larger contents get truncated, 0 to disable)")
+	flag.StringVar(&downloaddir, "download-dir", opt("YARR_DOWNLOAD_DIR", ""), "`path` to a directory to pre-download enclosures of selected feeds to")
+	flag.IntVar(&downloadQuota, "download-quota", optInt("YARR_DOWNLOAD_QUOTA", 1024), "maximum total size of downloaded enclosures in `megabytes` (0 for unlimited)")
+	flag.IntVar(&rateLimit, "rate-limit", optInt("YARR_RATE_LIMIT", 0), "maximum API `requests` per minute from a single client, answered with 429 beyond that (0 for unlimited)")
+	flag.StringVar(&publicurl, "public-url", opt("YARR_PUBLIC_URL", ""), "`url` the server is reachable at from the internet (e.g. https://yarr.example.com), enables following Fediverse accounts (@user@example.com) via ActivityPub")
+	flag.StringVar(&mailtoken, "mail-token", opt("YARR_MAIL_TOKEN", ""), "`token` enabling OPML import from emails posted to /opml/mail?token=... by an email gateway")
+	flag.StringVar(&themesdir, "themes-dir", opt("YARR_THEMES_DIR", ""), "`path` to a directory with templates, stylesheets and scripts used instead of the built-in ones (see doc/themes.md)")
+	flag.StringVar(&otlpendpoint, "otlp-endpoint", opt("YARR_OTLP_ENDPOINT", ""), "OpenTelemetry collector `url` to export traces to via OTLP/HTTP (e.g. http://localhost:4318)")
+	flag.BoolVar(&notifyExec, "notify-exec", opt("YARR_NOTIFY_EXEC", "false") == "true", "allow notifiers running commands on the server")
 	flag.BoolVar(&ver, "version", false, "print application version")
 	flag.BoolVar(&open, "open", false, "open the server in browser")
+	flag.BoolVar(&checkdb, "check-db", false, "check the storage file for corruption and orphaned rows, then exit")
 	flag.Parse()
 
 	if ver {
@@ -77,15 +135,59 @@ func main() {
 		return
 	}
 
+	command := flag.Arg(0)
+	if command != "" && command != "reindex" && command != "tui" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var tuiServer, tuiAuth string
+	if command == "tui" {
+		tuiFlags := flag.NewFlagSet("tui", flag.ExitOnError)
+		tuiFlags.StringVar(&tuiServer, "server", "", "`url` of a running server (including the base path) to connect to instead of opening the storage file")
+		tuiFlags.StringVar(&tuiAuth, "auth", "", "credentials for the server in the format `username:password`")
+		tuiFlags.Parse(flag.Args()[1:])
+		// the log would garble the screen
+		if logfile == "" {
+			log.SetOutput(io.Discard)
+		}
+	}
+	if tuiServer != "" {
+		username, password := "", ""
+		if tuiAuth != "" {
+			parts := strings.SplitN(tuiAuth, ":", 2)
+			if len(parts) != 2 {
+				fmt.Fprintln(os.Stderr, "invalid auth format, expected username:password")
+				os.Exit(2)
+			}
+			username, password = parts[0], parts[1]
+		}
+		c, err := client.New(tuiServer, username, password)
+		if err == nil {
+			err = tui.Run(c, os.Stdin, os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	if logfile != "" {
-		file, err := os.OpenFile(logfile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		file, err := openLogFile(
+			logfile,
+			int64(logMaxSize)<<20,
+			time.Duration(logMaxAge)*24*time.Hour,
+			logMaxBackups,
+		)
 		if err != nil {
 			log.Fatal("Failed to setup log file: ", err)
 		}
 		defer file.Close()
 		log.SetOutput(file)
-	} else {
+	} else if command != "tui" {
 		log.SetOutput(os.Stdout)
 	}
 
@@ -104,47 +206,121 @@ func main() {
 
 	log.Printf("using db file %s", db)
 
-	var username, password string
-	if authfile != "" {
-		f, err := os.Open(authfile)
-		if err != nil {
-			log.Fatal("Failed to open auth file: ", err)
-		}
-		defer f.Close()
-		username, password, err = parseAuthfile(f)
-		if err != nil {
-			log.Fatal("Failed to parse auth file: ", err)
-		}
-	} else if auth != "" {
-		username, password, err = parseAuthfile(strings.NewReader(auth))
-		if err != nil {
-			log.Fatal("Failed to parse auth literal: ", err)
-		}
+	liveConfig, err := live.config()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	if (certfile != "" || keyfile != "") && (certfile == "" || keyfile == "") {
 		log.Fatalf("Both cert & key files are required")
 	}
 
+	storage.MaxItemContentSize = maxContentSize
+
+	dbTuning.MmapSize = int64(dbMmapSize) << 20
+	if err := dbTuning.Validate(); err != nil {
+		log.Fatal("Invalid storage tuning: ", err)
+	}
+	storage.SQLiteTuning = dbTuning
+
+	if secretkeyfile == "" && db != ":memory:" {
+		secretkeyfile = db + ".key"
+	}
+	if secretkeyfile != "" {
+		key, err := loadSecretKey(secretkeyfile)
+		if err != nil {
+			log.Fatal("Failed to load secret key: ", err)
+		}
+		storage.SecretKey = key
+	}
+	notify.AllowExec = notifyExec
+
+	if otlpendpoint != "" {
+		tracing.Setup(otlpendpoint, "yarr")
+	}
+
 	store, err := storage.New(db)
 	if err != nil {
 		log.Fatal("Failed to initialise database: ", err)
 	}
 
+	if checkdb {
+		report, err := store.CheckIntegrity()
+		if err != nil {
+			log.Fatal("Failed to check database: ", err)
+		}
+		fmt.Println(report)
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "tui" {
+		if err := tui.Run(tui.NewStorageBackend(store), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "reindex" {
+		count, err := store.RebuildSearch()
+		if err != nil {
+			log.Fatal("Failed to rebuild search index: ", err)
+		}
+		fmt.Printf("indexed %d items\n", count)
+		if err := store.RebuildFeedCounts(); err != nil {
+			log.Fatal("Failed to rebuild unread counts: ", err)
+		}
+		return
+	}
+
+	if themesdir != "" {
+		if err := assets.SetOverlay(themesdir); err != nil {
+			log.Fatal("Failed to use themes directory: ", err)
+		}
+	}
+
 	srv := server.NewServer(store, addr)
 
 	if basepath != "" {
 		srv.BasePath = "/" + strings.Trim(basepath, "/")
 	}
 
+	srv.AllowedNetworks = liveConfig.AllowedNetworks
+	srv.AuthBypassNetworks = liveConfig.AuthBypassNetworks
+	srv.TrustedProxies = liveConfig.TrustedProxies
+	srv.ReloadConfig = func() error {
+		return reload(srv, config, live)
+	}
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			if err := srv.ReloadConfig(); err != nil {
+				log.Print("Failed to reload config: ", err)
+			}
+		}
+	}()
+
+	srv.MailToken = mailtoken
+	srv.RateLimit = int64(rateLimit)
+	srv.PublicURL = publicurl
+
 	if certfile != "" && keyfile != "" {
 		srv.CertFile = certfile
 		srv.KeyFile = keyfile
 	}
 
-	if username != "" && password != "" {
-		srv.Username = username
-		srv.Password = password
+	if downloaddir != "" {
+		srv.DownloadDir = downloaddir
+		srv.DownloadQuota = int64(downloadQuota) << 20
+	}
+
+	if liveConfig.Username != "" && liveConfig.Password != "" {
+		srv.Username = liveConfig.Username
+		srv.Password = liveConfig.Password
 	}
 
 	log.Printf("starting server at %s", srv.GetAddr())
@@ -153,3 +329,41 @@ func main() {
 	}
 	platform.Start(srv)
 }
+
+// reload re-reads the config file and applies the live options to the
+// running server. Options given on the command line or through the
+// environment keep precedence over the file, as on startup.
+func reload(srv *server.Server, path string, startup liveOptions) error {
+	if path != "" {
+		config, err := readConfig(path)
+		if err != nil {
+			return err
+		}
+		configFile = config
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	value := func(name, envVar, current string) string {
+		if explicit[name] {
+			return current
+		}
+		if value := os.Getenv(envVar); value != "" {
+			return value
+		}
+		return configFile[envVar]
+	}
+	live := liveOptions{
+		auth:           value("auth", "YARR_AUTH", startup.auth),
+		authfile:       value("auth-file", "YARR_AUTHFILE", startup.authfile),
+		allow:          value("allow", "YARR_ALLOW", startup.allow),
+		authbypass:     value("auth-bypass", "YARR_AUTH_BYPASS", startup.authbypass),
+		trustedproxies: value("trusted-proxies", "YARR_TRUSTED_PROXIES", startup.trustedproxies),
+	}
+	cfg, err := live.config()
+	if err != nil {
+		return err
+	}
+	srv.Reload(cfg)
+	log.Print("Reloaded config")
+	return nil
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPasswordFromAuthfile(t *testing.T) {
@@ -45,3 +48,58 @@ func TestPasswordFromAuthfile(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig(t *testing.T) {
+	config, err := parseConfig(strings.NewReader("# comment\n\nYARR_ADDR = 0.0.0.0:7070\nYARR_AUTH=user:pass=word\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["YARR_ADDR"] != "0.0.0.0:7070" || config["YARR_AUTH"] != "user:pass=word" || len(config) != 2 {
+		t.Fatalf("unexpected config: %#v", config)
+	}
+	if _, err := parseConfig(strings.NewReader("YARR_ADDR\n")); err == nil {
+		t.Fatal("expected error for a line without value")
+	}
+}
+
+func TestConfigPathFromArgs(t *testing.T) {
+	cases := map[string][]string{
+		"a.conf": {"-addr", ":7070", "-config", "a.conf"},
+		"b.conf": {"--config=b.conf"},
+		"":       {"-open"},
+	}
+	for want, args := range cases {
+		if have := configPathFromArgs(args); have != want {
+			t.Errorf("%v: expected %q, got %q", args, want, have)
+		}
+	}
+}
+
+func TestLogFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "yarr.log")
+	old := filepath.Join(dir, "yarr-"+time.Now().Add(-48*time.Hour).Format(logTimeFormat)+".log")
+	if err := os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := openLogFile(path, 10, 24*time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("expected backups older than max age to be removed")
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Write([]byte("line 123\n"))
+		time.Sleep(2 * time.Millisecond)
+	}
+	if backups := l.backups(); len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+	if data, _ := os.ReadFile(path); string(data) != "line 123\n" {
+		t.Fatalf("unexpected log file content: %q", data)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// loadSecretKey reads the hex encoded key the credentials of the feeds
+// are encrypted with, generating one on the first run. Losing it means
+// entering the credentials again.
+func loadSecretKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, storage.SecretKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != storage.SecretKeySize {
+		return nil, fmt.Errorf("%s: expected %d hex encoded bytes", path, storage.SecretKeySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,143 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nkanaev/yarr/src/parser"
+)
+
+func TestSignature(t *testing.T) {
+	pem, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecodePrivateKey(pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPem, _ := EncodePublicKey(&key.PublicKey)
+	public, err := DecodePublicKey(publicPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(keyID string) (*rsa.PublicKey, error) {
+		if keyID != "https://example.com/actor#main-key" {
+			return nil, errors.New("unknown key")
+		}
+		return public, nil
+	}
+
+	body := []byte(`{"type": "Create"}`)
+	req, _ := http.NewRequest("POST", "https://example.org/inbox", bytes.NewReader(body))
+	if err := Sign(req, body, "https://example.com/actor#main-key", key); err != nil {
+		t.Fatal(err)
+	}
+	if keyID, err := Verify(req, body, lookup); err != nil || keyID != "https://example.com/actor#main-key" {
+		t.Fatalf("unexpected result: %q, %v", keyID, err)
+	}
+	if _, err := Verify(req, []byte(`{"type": "Delete"}`), lookup); err == nil {
+		t.Error("the tampered body must be refused")
+	}
+
+	// signed for any host it's sent to
+	headers := []string{"(request-target)", "date", "digest"}
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	unbound := req.Clone(req.Context())
+	unbound.Header.Set("Signature", `keyId="https://example.com/actor#main-key",headers="(request-target) date digest",signature="`+
+		base64.StdEncoding.EncodeToString(signature)+`"`)
+	if _, err := Verify(unbound, body, lookup); err == nil {
+		t.Error("the request without a signed host must be refused")
+	}
+
+	req.Header.Set("Date", time.Now().Add(-2*time.Hour).UTC().Format(http.TimeFormat))
+	if _, err := Verify(req, body, lookup); err == nil {
+		t.Error("the changed date must be refused")
+	}
+	req.Header.Del("Signature")
+	if _, err := Verify(req, body, lookup); err == nil {
+		t.Error("the unsigned request must be refused")
+	}
+}
+
+func TestIsHandle(t *testing.T) {
+	for handle, want := range map[string]bool{
+		"@alice@example.com":           true,
+		"alice@example.com":            true,
+		"https://example.com/@alice":   false,
+		"@alice":                       false,
+		"@alice@example.com/feed.xml":  false,
+		"@alice@example.com@elsewhere": false,
+	} {
+		if have := IsHandle(handle); have != want {
+			t.Errorf("%s: want %v, have %v", handle, want, have)
+		}
+	}
+}
+
+func TestPostItem(t *testing.T) {
+	var activity Activity
+	err := json.Unmarshal([]byte(`{
+		"type": "Create",
+		"actor": {"id": "https://example.com/users/alice", "type": "Person"},
+		"object": {
+			"id": "https://example.com/users/alice/statuses/1",
+			"type": "Note",
+			"attributedTo": "https://example.com/users/alice",
+			"url": [{"type": "Link", "href": "https://example.com/@alice/1"}],
+			"published": "2024-05-01T10:00:00Z",
+			"sensitive": true,
+			"summary": "spoilers",
+			"content": "<p>The butler did it, obviously. Who else could have done it, in the library, with the candlestick?</p>",
+			"inReplyTo": null,
+			"attachment": [{"type": "Document", "mediaType": "image/png", "url": "https://example.com/a.png", "name": "a <b>"}]
+		}
+	}`), &activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activity.Actor != "https://example.com/users/alice" {
+		t.Errorf("unexpected actor: %q", activity.Actor)
+	}
+	post, id := activity.ObjectRef()
+	if post == nil || id != "https://example.com/users/alice/statuses/1" || !post.IsPost() {
+		t.Fatalf("unexpected object: %#v", post)
+	}
+	want := parser.Item{
+		GUID:     "https://example.com/users/alice/statuses/1",
+		Date:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		URL:      "https://example.com/@alice/1",
+		Title:    "spoilers",
+		Author:   "Alice",
+		Content:  `<p><strong>spoilers</strong></p><p>The butler did it, obviously. Who else could have done it, in the library, with the candlestick?</p><p><img src="https://example.com/a.png" alt="a &lt;b&gt;"></p>`,
+		ImageURL: "https://example.com/a.png",
+
+		Enclosures: []parser.Enclosure{{URL: "https://example.com/a.png", Type: "image/png"}},
+	}
+	if have := post.Item("Alice"); !reflect.DeepEqual(have, want) {
+		t.Logf("want: %#v", want)
+		t.Logf("have: %#v", have)
+		t.FailNow()
+	}
+
+	post.Sensitive = false
+	if have := post.Item("Alice").Title; have != "The butler did it, obviously. Who else could have done it, in the library, with…" {
+		t.Errorf("unexpected title: %q", have)
+	}
+
+	json.Unmarshal([]byte(`{"type": "Delete", "object": "https://example.com/users/alice/statuses/1"}`), &activity)
+	if post, id := activity.ObjectRef(); post != nil || id != "https://example.com/users/alice/statuses/1" {
+		t.Errorf("unexpected object: %#v, %q", post, id)
+	}
+}
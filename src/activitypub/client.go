@@ -0,0 +1,188 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// the documents larger than that aren't read
+const maxDocumentSize = 1 << 20
+
+// Client talks to the Fediverse servers on behalf of an actor.
+// The requests are signed with the actor's key if set,
+// as some servers insist on it ("authorized fetch").
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+	KeyID     string
+	Key       *rsa.PrivateKey
+}
+
+// IsHandle reports whether the string is an account handle: @user@example.com
+// (the leading @ is optional).
+func IsHandle(s string) bool {
+	user, host := splitHandle(s)
+	return user != "" && host != "" && !strings.ContainsAny(user+host, "/:@ ")
+}
+
+func splitHandle(s string) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "@"), "@")
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// Resolve looks up the actor id of the handle via WebFinger (RFC 7033).
+func (c *Client) Resolve(ctx context.Context, handle string) (string, error) {
+	user, host := splitHandle(handle)
+	if !IsHandle(handle) {
+		return "", fmt.Errorf("invalid handle %q", handle)
+	}
+	query := url.Values{"resource": {"acct:" + user + "@" + host}}
+	var doc struct {
+		Links []struct {
+			Rel  string `json:"rel"`
+			Type string `json:"type"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	if err := c.get(ctx, "https://"+host+"/.well-known/webfinger?"+query.Encode(), "application/jrd+json", &doc); err != nil {
+		return "", err
+	}
+	for _, link := range doc.Links {
+		if link.Rel == "self" && isActivityType(link.Type) && link.Href != "" {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no ActivityPub actor", handle)
+}
+
+// FetchActor fetches the actor document.
+func (c *Client) FetchActor(ctx context.Context, id string) (*Actor, error) {
+	var actor Actor
+	if err := c.Fetch(ctx, id, &actor); err != nil {
+		return nil, err
+	}
+	if actor.ID == "" || actor.Inbox == "" {
+		return nil, fmt.Errorf("%s isn't an actor", id)
+	}
+	return &actor, nil
+}
+
+// Fetch fetches the ActivityStreams document.
+func (c *Client) Fetch(ctx context.Context, id string, out interface{}) error {
+	return c.get(ctx, id, ContentType+`, application/ld+json; profile="`+activityStreams+`"`, out)
+}
+
+// Outbox returns the latest posts of the actor (the first page of its outbox).
+func (c *Client) Outbox(ctx context.Context, actor *Actor) ([]Object, error) {
+	if actor.Outbox == "" {
+		return nil, nil
+	}
+	var collection struct {
+		First        json.RawMessage `json:"first"`
+		OrderedItems []Activity      `json:"orderedItems"`
+	}
+	if err := c.Fetch(ctx, actor.Outbox, &collection); err != nil {
+		return nil, err
+	}
+	activities := collection.OrderedItems
+	if len(activities) == 0 && len(collection.First) > 0 {
+		var page struct {
+			OrderedItems []Activity `json:"orderedItems"`
+		}
+		// the first page is either embedded or linked to
+		if err := json.Unmarshal(collection.First, &page); err != nil || len(page.OrderedItems) == 0 {
+			var first Ref
+			if err := json.Unmarshal(collection.First, &first); err != nil || first == "" {
+				return nil, nil
+			}
+			if err := c.Fetch(ctx, string(first), &page); err != nil {
+				return nil, err
+			}
+		}
+		activities = page.OrderedItems
+	}
+	posts := make([]Object, 0, len(activities))
+	for _, activity := range activities {
+		if activity.Type != "Create" {
+			continue
+		}
+		if obj, _ := activity.ObjectRef(); obj != nil {
+			posts = append(posts, *obj)
+		}
+	}
+	return posts, nil
+}
+
+// Deliver posts the activity to the inbox.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity interface{}) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	res, err := c.do(req, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxDocumentSize))
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded with %s", inbox, res.Status)
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, url, accept string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	res, err := c.do(req, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", url, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxDocumentSize)).Decode(out)
+}
+
+func (c *Client) do(req *http.Request, body []byte) (*http.Response, error) {
+	if req.URL.Scheme != "https" && req.URL.Scheme != "http" {
+		return nil, errors.New("unsupported url scheme")
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.Key != nil {
+		if err := Sign(req, body, c.KeyID, c.Key); err != nil {
+			return nil, err
+		}
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func isActivityType(mediaType string) bool {
+	return mediaType == ContentType || strings.HasPrefix(mediaType, "application/ld+json")
+}
@@ -0,0 +1,83 @@
+package activitypub
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"github.com/nkanaev/yarr/src/parser"
+)
+
+// the length of the titles made up from the content of the posts without one
+const titleLength = 80
+
+// IsPost reports whether the object is worth an item: the replies
+// belong to the conversations, not to the author's feed.
+func (o *Object) IsPost() bool {
+	switch o.Type {
+	case "Note", "Article", "Page", "Question", "Video", "Audio", "Image", "Event":
+		return o.InReplyTo == ""
+	}
+	return false
+}
+
+// Item converts the post to a feed item. The content warning of a sensitive
+// post is kept above its content, the attached images below it.
+func (o *Object) Item(author string) parser.Item {
+	var content strings.Builder
+	if o.Sensitive && o.Summary != "" {
+		content.WriteString("<p><strong>" + html.EscapeString(o.Summary) + "</strong></p>")
+	} else if o.Type == "Article" && o.Summary != "" && o.Content == "" {
+		content.WriteString(o.Summary)
+	}
+	content.WriteString(o.Content)
+
+	item := parser.Item{
+		GUID:    o.ID,
+		Date:    o.Published,
+		Updated: o.Updated,
+		URL:     firstNonEmpty(string(o.URL), o.ID),
+		Title:   o.Name,
+		Author:  author,
+	}
+	for _, a := range o.Attachment {
+		if a.URL == "" {
+			continue
+		}
+		item.Enclosures = append(item.Enclosures, parser.Enclosure{URL: string(a.URL), Type: a.MediaType})
+		switch {
+		case strings.HasPrefix(a.MediaType, "image/") || a.Type == "Image":
+			content.WriteString(`<p><img src="` + html.EscapeString(string(a.URL)) + `" alt="` + html.EscapeString(a.Name) + `"></p>`)
+			if item.ImageURL == "" {
+				item.ImageURL = string(a.URL)
+			}
+		case strings.HasPrefix(a.MediaType, "audio/"):
+			if item.AudioURL == "" {
+				item.AudioURL = string(a.URL)
+			}
+		}
+	}
+	item.Content = content.String()
+	if item.Title == "" {
+		if o.Sensitive && o.Summary != "" {
+			// rather than giving the content away
+			item.Title = o.Summary
+		} else {
+			item.Title = excerpt(htmlutil.ExtractText(o.Content), titleLength)
+		}
+	}
+	return item
+}
+
+func excerpt(text string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)[:length]
+	if cut := strings.LastIndex(string(runes), " "); cut > length/2 {
+		return string(runes)[:cut] + "…"
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,135 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the requests older (or newer) than that are refused, so that
+// the captured ones can't be replayed later on
+const maxClockSkew = time.Hour
+
+// Sign adds the HTTP signature (https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures)
+// the Fediverse servers expect: rsa-sha256 over the request target, the host,
+// the date and, for requests with a body, its digest.
+func Sign(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature),
+	))
+	return nil
+}
+
+// Verify checks the signature of the request (see Sign) with the key
+// returned by publicKey for the signature's keyId, which is returned.
+// The request target, the host and the date have to be signed.
+func Verify(req *http.Request, body []byte, publicKey func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	params := parseSignature(req.Header.Get("Signature"))
+	keyID, signature := params["keyId"], params["signature"]
+	if keyID == "" || signature == "" {
+		return "", errors.New("the request isn't signed")
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return "", fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	signed := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		signed[h] = true
+	}
+	if !signed["(request-target)"] || !signed["host"] || !signed["date"] {
+		return "", errors.New("the request target, host and date must be signed")
+	}
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", errors.New("invalid date")
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", errors.New("the date is too far off")
+	}
+	if len(body) > 0 {
+		if !signed["digest"] {
+			return "", errors.New("the digest must be signed")
+		}
+		if req.Header.Get("Digest") != digest(body) {
+			return "", errors.New("the digest doesn't match the body")
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", errors.New("invalid signature encoding")
+	}
+	key, err := publicKey(keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the key %s: %w", keyID, err)
+	}
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], raw); err != nil {
+		return "", errors.New("invalid signature")
+	}
+	return keyID, nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		var val string
+		switch h {
+		case "(request-target)":
+			val = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			val = req.Host
+			if val == "" {
+				val = req.URL.Host
+			}
+		default:
+			val = strings.Join(req.Header.Values(h), ", ")
+		}
+		lines[i] = h + ": " + val
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature reads the key="value" pairs of the Signature header.
+func parseSignature(header string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			continue
+		}
+		key := strings.TrimSpace(part[:eq])
+		params[key] = strings.Trim(strings.TrimSpace(part[eq+1:]), `"`)
+	}
+	return params
+}
@@ -0,0 +1,130 @@
+// Package activitypub implements the part of ActivityPub
+// (https://www.w3.org/TR/activitypub/) needed to follow Fediverse accounts:
+// looking them up, signing and verifying the requests exchanged with
+// their servers and turning their posts into feed items.
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ContentType is the media type of the ActivityStreams documents.
+const ContentType = "application/activity+json"
+
+const activityStreams = "https://www.w3.org/ns/activitystreams"
+
+// Context is the JSON-LD context of the documents sent.
+var Context = []string{activityStreams, "https://w3id.org/security/v1"}
+
+type Actor struct {
+	Context           interface{} `json:"@context,omitempty"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername,omitempty"`
+	Name              string      `json:"name,omitempty"`
+	Summary           string      `json:"summary,omitempty"`
+	URL               Ref         `json:"url,omitempty"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox,omitempty"`
+	PublicKey         PublicKey   `json:"publicKey"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Title is the name the actor goes by.
+func (a *Actor) Title() string {
+	return firstNonEmpty(a.Name, a.PreferredUsername, a.ID)
+}
+
+type Activity struct {
+	Context interface{}     `json:"@context,omitempty"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Actor   Ref             `json:"actor"`
+	Object  json.RawMessage `json:"object,omitempty"`
+}
+
+// Object is a post: a Note (toot), an Article (blog post) and the like.
+type Object struct {
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	AttributedTo Ref          `json:"attributedTo"`
+	Name         string       `json:"name"`
+	Summary      string       `json:"summary"`
+	Content      string       `json:"content"`
+	URL          Ref          `json:"url"`
+	Published    time.Time    `json:"published"`
+	Updated      time.Time    `json:"updated"`
+	InReplyTo    Ref          `json:"inReplyTo"`
+	Sensitive    bool         `json:"sensitive"`
+	Attachment   []Attachment `json:"attachment"`
+}
+
+type Attachment struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType"`
+	URL       Ref    `json:"url"`
+	Name      string `json:"name"`
+}
+
+// Ref is a reference to another object. It's given either as the id
+// (or url) of the object, the object itself, or a list of those,
+// of which the first one is taken.
+type Ref string
+
+func (r *Ref) UnmarshalJSON(data []byte) error {
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return err
+	}
+	*r = Ref(refString(val))
+	return nil
+}
+
+func refString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for _, key := range []string{"id", "href"} {
+			if s, ok := v[key].(string); ok && s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s := refString(item); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// ObjectRef reads the object of the activity,
+// which is either embedded or referenced by its id.
+func (a *Activity) ObjectRef() (embedded *Object, id string) {
+	var ref Ref
+	if err := json.Unmarshal(a.Object, &ref); err == nil && ref != "" {
+		id = string(ref)
+	}
+	var obj Object
+	if err := json.Unmarshal(a.Object, &obj); err == nil && obj.ID != "" {
+		return &obj, obj.ID
+	}
+	return nil, id
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, val := range vals {
+		if val != "" {
+			return val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,358 @@
+// Package apicheck verifies the API semantics external clients rely on
+// (ordering, unread counts, pagination, the Fever API agreeing with
+// the native one) against a running instance.
+//
+// The checks assume the data doesn't change while they run, so the
+// instance shouldn't be refreshing feeds meanwhile. The Google Reader
+// API isn't implemented by yarr, hence not checked.
+package apicheck
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+type Config struct {
+	// address of the instance, including the base path
+	URL string
+	// credentials, empty if authentication is disabled
+	Username string
+	Password string
+	// Write enables the checks changing item statuses,
+	// which restore them afterwards
+	Write bool
+}
+
+// Result is the outcome of a single check, Err is nil if it passed.
+type Result struct {
+	Name string
+	Err  error
+}
+
+type check struct {
+	name  string
+	write bool
+	run   func(c *client) error
+}
+
+var checks = []check{
+	{"native/order-newest-first", false, checkNativeOrder(true)},
+	{"native/order-oldest-first", false, checkNativeOrder(false)},
+	{"native/unread-count", false, checkNativeCount("unread")},
+	{"native/starred-count", false, checkNativeCount("starred")},
+	{"fever/auth", false, checkFeverAuth},
+	{"fever/unread-item-ids", false, checkFeverIds("unread_item_ids", "unread")},
+	{"fever/saved-item-ids", false, checkFeverIds("saved_item_ids", "starred")},
+	{"fever/items-since-id", false, checkFeverItemsSinceId},
+	{"fever/items-max-id", false, checkFeverItemsMaxId},
+	{"fever/items-with-ids", false, checkFeverItemsWithIds},
+	{"native/mark-read", true, checkMarkRead(false)},
+	{"fever/mark-read", true, checkMarkRead(true)},
+}
+
+// Run logs in and runs the checks, an error is returned if the
+// instance can't be reached.
+func Run(cfg Config) ([]Result, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		if check.write && !cfg.Write {
+			continue
+		}
+		results = append(results, Result{Name: check.name, Err: check.run(c)})
+	}
+	return results, nil
+}
+
+func checkNativeOrder(newestFirst bool) func(c *client) error {
+	return func(c *client) error {
+		query := url.Values{}
+		if !newestFirst {
+			query.Set("oldest_first", "true")
+		}
+		items, err := c.items(query)
+		if err != nil {
+			return err
+		}
+		seen := make(map[int64]bool)
+		for i, item := range items {
+			if seen[item.Id] {
+				return fmt.Errorf("item %d listed twice", item.Id)
+			}
+			seen[item.Id] = true
+			if i == 0 {
+				continue
+			}
+			prev := items[i-1]
+			// items are ordered by date, and by id within the same date
+			ordered := prev.Date.Before(item.Date) || (prev.Date.Equal(item.Date) && prev.Id < item.Id)
+			if newestFirst {
+				ordered = prev.Date.After(item.Date) || (prev.Date.Equal(item.Date) && prev.Id > item.Id)
+			}
+			if !ordered {
+				return fmt.Errorf("item %d listed after item %d", item.Id, prev.Id)
+			}
+		}
+		return nil
+	}
+}
+
+func checkNativeCount(status string) func(c *client) error {
+	return func(c *client) error {
+		stats, err := c.stats()
+		if err != nil {
+			return err
+		}
+		items, err := c.items(url.Values{"status": {status}})
+		if err != nil {
+			return err
+		}
+		listed := make(map[int64]int64)
+		for _, item := range items {
+			if item.Status != status {
+				return fmt.Errorf("item %d listed as %s has status %s", item.Id, status, item.Status)
+			}
+			listed[item.FeedId]++
+		}
+		for _, stat := range stats {
+			count := stat.Unread
+			if status == "starred" {
+				count = stat.Starred
+			}
+			if count != listed[stat.FeedId] {
+				return fmt.Errorf("feed %d: %d %s counted, %d listed", stat.FeedId, count, status, listed[stat.FeedId])
+			}
+			delete(listed, stat.FeedId)
+		}
+		for feedId, n := range listed {
+			return fmt.Errorf("feed %d: %d %s listed, none counted", feedId, n, status)
+		}
+		return nil
+	}
+}
+
+func checkFeverAuth(c *client) error {
+	res, err := c.fever("")
+	if err != nil {
+		return err
+	}
+	if res.APIVersion != 3 || res.Auth != 1 {
+		return fmt.Errorf("want api_version 3 and auth 1, have %d and %d", res.APIVersion, res.Auth)
+	}
+	if c.authenticated {
+		res, err := c.feverWithKey("invalid", "")
+		if err != nil {
+			return err
+		}
+		if res.Auth != 0 {
+			return fmt.Errorf("invalid api key accepted")
+		}
+	}
+	return nil
+}
+
+func checkFeverIds(key, status string) func(c *client) error {
+	return func(c *client) error {
+		res, err := c.fever(key)
+		if err != nil {
+			return err
+		}
+		list := res.UnreadIds
+		if key == "saved_item_ids" {
+			list = res.SavedIds
+		}
+		ids, err := parseIds(list)
+		if err != nil {
+			return err
+		}
+		items, err := c.items(url.Values{"status": {status}})
+		if err != nil {
+			return err
+		}
+		return sameIds(ids, items)
+	}
+}
+
+func sameIds(ids []int64, items []nativeItem) error {
+	want := make(map[int64]bool)
+	for _, item := range items {
+		want[item.Id] = true
+	}
+	have := make(map[int64]bool)
+	for _, id := range ids {
+		if have[id] {
+			return fmt.Errorf("id %d listed twice", id)
+		}
+		have[id] = true
+		if !want[id] {
+			return fmt.Errorf("unexpected id %d", id)
+		}
+	}
+	if len(have) != len(want) {
+		return fmt.Errorf("%d ids listed, want %d", len(have), len(want))
+	}
+	return nil
+}
+
+// feverPages lists all items by following since_id or max_id.
+func feverPages(c *client, param string, start int64, ascending bool) ([]feverItem, int64, error) {
+	result := make([]feverItem, 0)
+	cursor := start
+	var total int64
+	for {
+		res, err := c.fever("items", param+"="+strconv.FormatInt(cursor, 10))
+		if err != nil {
+			return nil, 0, err
+		}
+		total = res.TotalItems
+		if len(res.Items) == 0 {
+			return result, total, nil
+		}
+		for _, item := range res.Items {
+			if ascending && item.Id <= cursor || !ascending && item.Id >= cursor {
+				return nil, 0, fmt.Errorf("%s=%d returned item %d out of order", param, cursor, item.Id)
+			}
+			cursor = item.Id
+		}
+		result = append(result, res.Items...)
+		if int64(len(result)) > total {
+			return nil, 0, fmt.Errorf("more items listed than the total of %d", total)
+		}
+	}
+}
+
+func checkFeverItemsSinceId(c *client) error {
+	items, total, err := feverPages(c, "since_id", 0, true)
+	if err != nil {
+		return err
+	}
+	if int64(len(items)) != total {
+		return fmt.Errorf("%d items listed, total_items is %d", len(items), total)
+	}
+	return nil
+}
+
+func checkFeverItemsMaxId(c *client) error {
+	ascending, _, err := feverPages(c, "since_id", 0, true)
+	if err != nil {
+		return err
+	}
+	if len(ascending) == 0 {
+		return nil
+	}
+	descending, _, err := feverPages(c, "max_id", ascending[len(ascending)-1].Id+1, false)
+	if err != nil {
+		return err
+	}
+	if len(descending) != len(ascending) {
+		return fmt.Errorf("%d items listed by max_id, %d by since_id", len(descending), len(ascending))
+	}
+	return nil
+}
+
+func checkFeverItemsWithIds(c *client) error {
+	res, err := c.fever("items", "since_id=0")
+	if err != nil {
+		return err
+	}
+	if len(res.Items) == 0 {
+		return nil
+	}
+	want := make([]int64, 0)
+	for i := len(res.Items) - 1; i >= 0; i -= 2 {
+		want = append(want, res.Items[i].Id)
+	}
+	list := ""
+	for i, id := range want {
+		if i > 0 {
+			list += ","
+		}
+		list += strconv.FormatInt(id, 10)
+	}
+	res, err = c.fever("items", "with_ids="+list)
+	if err != nil {
+		return err
+	}
+	have := make([]int64, len(res.Items))
+	for i, item := range res.Items {
+		have[i] = item.Id
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	if fmt.Sprint(have) != fmt.Sprint(want) {
+		return fmt.Errorf("with_ids=%s returned %v", list, have)
+	}
+	return nil
+}
+
+// checkMarkRead marks an unread item as read (via either API) and checks
+// that both APIs agree on the new unread counts, then restores the item.
+func checkMarkRead(fever bool) func(c *client) error {
+	return func(c *client) error {
+		unread, err := c.items(url.Values{"status": {"unread"}})
+		if err != nil {
+			return err
+		}
+		if len(unread) == 0 {
+			return nil
+		}
+		item := unread[0]
+
+		mark := func(status string) error {
+			if !fever {
+				return c.setStatus(item.Id, status)
+			}
+			return c.feverMark(item.Id, status)
+		}
+		if err := mark("read"); err != nil {
+			return err
+		}
+		restore := func() error { return mark("unread") }
+
+		if err := verifyUnread(c, len(unread)-1, item.Id, false); err != nil {
+			restore()
+			return err
+		}
+		if err := restore(); err != nil {
+			return err
+		}
+		return verifyUnread(c, len(unread), item.Id, true)
+	}
+}
+
+func verifyUnread(c *client, count int, id int64, isUnread bool) error {
+	stats, err := c.stats()
+	if err != nil {
+		return err
+	}
+	var counted int64
+	for _, stat := range stats {
+		counted += stat.Unread
+	}
+	if counted != int64(count) {
+		return fmt.Errorf("%d unread counted, want %d", counted, count)
+	}
+	res, err := c.fever("unread_item_ids")
+	if err != nil {
+		return err
+	}
+	ids, err := parseIds(res.UnreadIds)
+	if err != nil {
+		return err
+	}
+	if len(ids) != count {
+		return fmt.Errorf("fever lists %d unread ids, want %d", len(ids), count)
+	}
+	listed := false
+	for _, i := range ids {
+		listed = listed || i == id
+	}
+	if listed != isUnread {
+		return fmt.Errorf("item %d: fever unread %v, want %v", id, listed, isUnread)
+	}
+	return nil
+}
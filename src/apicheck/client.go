@@ -0,0 +1,196 @@
+package apicheck
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client talks to the instance the way external clients do:
+// the native API with the session cookie, Fever with the api key.
+type client struct {
+	base   string
+	apiKey string
+	http   *http.Client
+
+	authenticated bool
+}
+
+func newClient(cfg Config) (*client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{
+		base:   strings.TrimSuffix(cfg.URL, "/"),
+		apiKey: fmt.Sprintf("%x", md5.Sum([]byte(cfg.Username+":"+cfg.Password))),
+		http:   &http.Client{Jar: jar, Timeout: 30 * time.Second},
+
+		authenticated: cfg.Username != "",
+	}
+	if c.authenticated {
+		form := url.Values{"username": {cfg.Username}, "password": {cfg.Password}}
+		res, err := c.http.PostForm(c.base+"/", form)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		if u, _ := url.Parse(c.base + "/"); len(jar.Cookies(u)) == 0 {
+			return nil, fmt.Errorf("login failed")
+		}
+	}
+	return c, nil
+}
+
+func (c *client) do(method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = strings.NewReader(string(data))
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s %s: status code %d", method, path, res.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: %s", method, path, err)
+	}
+	return nil
+}
+
+type nativeItem struct {
+	Id     int64     `json:"id"`
+	FeedId int64     `json:"feed_id"`
+	Date   time.Time `json:"date"`
+	Status string    `json:"status"`
+}
+
+type nativeStat struct {
+	FeedId  int64 `json:"feed_id"`
+	Unread  int64 `json:"unread"`
+	Starred int64 `json:"starred"`
+}
+
+func (c *client) stats() ([]nativeStat, error) {
+	var status struct {
+		Stats []nativeStat `json:"stats"`
+	}
+	err := c.do("GET", "/api/status", nil, &status)
+	return status.Stats, err
+}
+
+// items lists all of the matching items, following the continuation
+// (the id of the last item on a page) until has_more is false.
+func (c *client) items(query url.Values) ([]nativeItem, error) {
+	result := make([]nativeItem, 0)
+	for page := 0; ; page++ {
+		if page > 100000 {
+			return nil, fmt.Errorf("pagination doesn't end")
+		}
+		var list struct {
+			List    []nativeItem `json:"list"`
+			HasMore bool         `json:"has_more"`
+		}
+		if err := c.do("GET", "/api/items?"+query.Encode(), nil, &list); err != nil {
+			return nil, err
+		}
+		result = append(result, list.List...)
+		if !list.HasMore {
+			return result, nil
+		}
+		if len(list.List) == 0 {
+			return nil, fmt.Errorf("empty page with has_more set")
+		}
+		query.Set("after", strconv.FormatInt(list.List[len(list.List)-1].Id, 10))
+	}
+}
+
+func (c *client) setStatus(id int64, status string) error {
+	return c.do("PUT", fmt.Sprintf("/api/items/%d", id), map[string]string{"status": status}, nil)
+}
+
+type feverItem struct {
+	Id      int64 `json:"id"`
+	FeedId  int64 `json:"feed_id"`
+	IsRead  int   `json:"is_read"`
+	IsSaved int   `json:"is_saved"`
+}
+
+type feverResponse struct {
+	APIVersion int         `json:"api_version"`
+	Auth       int         `json:"auth"`
+	Items      []feverItem `json:"items"`
+	TotalItems int64       `json:"total_items"`
+	UnreadIds  string      `json:"unread_item_ids"`
+	SavedIds   string      `json:"saved_item_ids"`
+}
+
+// fever calls the Fever API, e.g. fever("items", "since_id=0").
+func (c *client) fever(key string, params ...string) (*feverResponse, error) {
+	return c.feverWithKey(c.apiKey, key, params...)
+}
+
+func (c *client) feverWithKey(apiKey, key string, params ...string) (*feverResponse, error) {
+	query := "api&" + key
+	for _, param := range params {
+		query += "&" + param
+	}
+	form := url.Values{"api_key": {apiKey}}
+	res, err := c.http.PostForm(c.base+"/fever/?"+query, form)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fever %s: status code %d", key, res.StatusCode)
+	}
+	var out feverResponse
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("fever %s: %s", key, err)
+	}
+	return &out, nil
+}
+
+func (c *client) feverMark(id int64, as string) error {
+	_, err := c.fever("mark=item", "as="+as, "id="+strconv.FormatInt(id, 10))
+	return err
+}
+
+func parseIds(list string) ([]int64, error) {
+	result := make([]int64, 0)
+	if list == "" {
+		return result, nil
+	}
+	for _, s := range strings.Split(list, ",") {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id list %q", list)
+		}
+		result = append(result, id)
+	}
+	return result, nil
+}
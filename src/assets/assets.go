@@ -1,29 +1,154 @@
 package assets
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
 type assetsfs struct {
 	embedded  *embed.FS
 	templates map[string]*template.Template
+	// the directory with the files served instead of the built-in ones
+	overlay string
 }
 
 var FS assetsfs
 
 func (afs assetsfs) Open(name string) (fs.File, error) {
+	if afs.overlay != "" {
+		file, err := openOverlay(afs.overlay, name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return file, err
+		}
+	}
 	if afs.embedded != nil {
 		return afs.embedded.Open(name)
 	}
 	return os.DirFS("src/assets").Open(name)
 }
 
+// cached reports whether the assets never change while running.
+func (afs assetsfs) cached() bool {
+	return afs.embedded != nil && afs.overlay == ""
+}
+
+// SetOverlay makes the files of the directory (templates, stylesheets,
+// javascripts, graphicarts) take precedence over the built-in ones,
+// they're read on every use so that they may be edited while running.
+// An empty dir brings back the built-in files.
+func SetOverlay(dir string) error {
+	if dir == "" {
+		FS.overlay = ""
+		return nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	FS.overlay = dir
+	return nil
+}
+
+func openOverlay(dir, name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	realpath, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	// the symlinks mustn't lead out of the directory
+	rel, err := filepath.Rel(dir, realpath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return os.Open(realpath)
+}
+
+const hashLength = 10
+
+var (
+	hashesMu sync.Mutex
+	hashes   = make(map[string]string)
+)
+
+// Hash returns the hash of the asset's content.
+func Hash(name string) (string, error) {
+	if FS.cached() {
+		hashesMu.Lock()
+		defer hashesMu.Unlock()
+		if hash, found := hashes[name]; found {
+			return hash, nil
+		}
+	}
+	content, err := fs.ReadFile(FS, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	if FS.cached() {
+		hashes[name] = hash
+	}
+	return hash, nil
+}
+
+// Hashed returns the path of the asset with the hash of its content
+// in the name, for the urls which can be cached for good:
+// "javascripts/app.js" becomes "javascripts/app.0123456789.js".
+func Hashed(name string) string {
+	hash, err := Hash(name)
+	if err != nil {
+		log.Print(err)
+		return name
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Unhashed reverses Hashed, returning the path of the asset
+// and the hash in the name (if any).
+func Unhashed(name string) (string, string) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	i := strings.LastIndex(base, ".")
+	if i == -1 || !isHash(base[i+1:]) {
+		return name, ""
+	}
+	return base[:i] + ext, base[i+1:]
+}
+
+func isHash(s string) bool {
+	if len(s) != hashLength {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 func Template(path string) *template.Template {
 	var tmpl *template.Template
 	tmpl, found := FS.templates[path]
@@ -44,8 +169,15 @@ func Template(path string) *template.Template {
 				}
 				return template.HTML(content)
 			},
+			"static": func(name string) string {
+				return "./static/" + Hashed(name)
+			},
+			"exists": func(name string) bool {
+				_, err := fs.Stat(FS, name)
+				return err == nil
+			},
 		}).ParseFS(FS, path))
-		if FS.embedded != nil {
+		if FS.cached() {
 			FS.templates[path] = tmpl
 		}
 	}
@@ -52,6 +52,31 @@
       list_errors: function() {
         return api('get', './api/feeds/errors').then(json)
       },
+      stats: function(id) {
+        return api('get', './api/feeds/' + id + '/stats').then(json)
+      },
+      error_history: function(id) {
+        return api('get', './api/feeds/' + id + '/errors').then(json)
+      },
+      credentials: function(id, data) {
+        if (data) return api('put', './api/feeds/' + id + '/credentials', data)
+        return api('delete', './api/feeds/' + id + '/credentials')
+      },
+      bulk: function(data) {
+        return api('post', './api/feeds/bulk', data)
+      },
+      reorder: function(feeds) {
+        return api('put', './api/feeds/order', {feeds: feeds})
+      },
+      end_trial: function(id, keep) {
+        return api('post', './api/feeds/trials/' + id, {keep: keep})
+      },
+      restore: function(id) {
+        return api('post', './api/feeds/deleted/' + id)
+      },
+      purge: function(id) {
+        return api('delete', './api/feeds/deleted/' + id)
+      },
     },
     folders: {
       list: function() {
@@ -66,13 +91,19 @@
       delete: function(id) {
         return api('delete', './api/folders/' + id)
       },
+      reorder: function(ids) {
+        return api('put', './api/folders/order', {folder_ids: ids})
+      },
       list_items: function(id) {
         return api('get', './api/folders/' + id + '/items').then(json)
       }
     },
     items: {
-      get: function(id) {
-        return api('get', './api/items/' + id).then(json)
+      get: function(id, query) {
+        return api('get', './api/items/' + id + param(query)).then(json)
+      },
+      content: function(id) {
+        return api('get', './api/items/' + id + '/content').then(json)
       },
       list: function(query) {
         return api('get', './api/items' + param(query)).then(json)
@@ -83,6 +114,49 @@
       mark_read: function(query) {
         return api('put', './api/items' + param(query))
       },
+      snooze: function(id, until) {
+        return api('post', './api/items/' + id + '/snooze', {until: until}).then(json)
+      },
+      unsnooze: function(id) {
+        return api('delete', './api/items/' + id + '/snooze')
+      },
+      add_tag: function(id, tag) {
+        return api('post', './api/items/' + id + '/tags', {tag: tag}).then(json)
+      },
+      remove_tag: function(id, tag) {
+        return api('delete', './api/items/' + id + '/tags' + param({tag: tag}))
+      },
+      set_note: function(id, note) {
+        return api('put', './api/items/' + id + '/note', {note: note}).then(json)
+      },
+      import_states: function(states) {
+        return api('post', './api/items/states', states).then(json)
+      },
+    },
+    archive: {
+      list: function(query) {
+        return api('get', './api/archive' + param(query)).then(json)
+      },
+      get: function(id) {
+        return api('get', './api/archive/' + id).then(json)
+      },
+      delete: function(id) {
+        return api('delete', './api/archive/' + id)
+      },
+    },
+    searches: {
+      list: function() {
+        return api('get', './api/searches').then(json)
+      },
+      create: function(data) {
+        return api('post', './api/searches', data).then(json)
+      },
+      update: function(id, data) {
+        return api('put', './api/searches/' + id, data)
+      },
+      delete: function(id) {
+        return api('delete', './api/searches/' + id)
+      },
     },
     settings: {
       get: function() {
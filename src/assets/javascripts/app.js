@@ -142,6 +142,12 @@ Vue.component('modal', {
   },
 })
 
+// dateOptions shows the dates in the time zone of the settings, if any
+function dateOptions(options) {
+  if (app.settings.timezone) options.timeZone = app.settings.timezone
+  return options
+}
+
 function dateRepr(d) {
   var sec = (new Date().getTime() - d.getTime()) / 1000
   var neg = sec < 0
@@ -155,7 +161,7 @@ function dateRepr(d) {
   else if (sec < 604800)  // less than a week
     out = Math.round(sec / 86400) + 'd'
   else
-    out = d.toLocaleDateString(undefined, {year: "numeric", month: "long", day: "numeric"})
+    out = d.toLocaleDateString(undefined, dateOptions({year: "numeric", month: "long", day: "numeric"}))
 
   if (neg) return '-' + out
   return out
@@ -193,7 +199,8 @@ var vm = new Vue({
     })
   },
   data: function() {
-    var s = app.settings
+    // the default view (if any) takes precedence over the last used one
+    var s = Object.assign({}, app.settings, app.settings.default_view)
     return {
       'filterSelected': s.filter,
       'folders': [],
@@ -202,13 +209,17 @@ var vm = new Vue({
       'feedListWidth': s.feed_list_width || 300,
       'feedNewChoice': [],
       'feedNewChoiceSelected': '',
+      'feedDragged': null,
+      'folderDragged': null,
       'items': [],
       'itemsHasMore': true,
       'itemSelected': null,
       'itemSelectedDetails': null,
+      'itemNoteEditing': false,
       'itemSelectedReadability': '',
       'itemSearch': '',
       'itemSortNewestFirst': s.sort_newest_first,
+      'itemSortBy': s.sort_by || '',
       'itemListWidth': s.item_list_width || 300,
 
       'filteredFeedStats': {},
@@ -224,14 +235,31 @@ var vm = new Vue({
       },
       'fonts': ['', 'serif', 'monospace'],
       'feedStats': {},
+      'tagStats': [],
+      'searches': [],
+      'searchStats': {},
       'theme': {
         'name': s.theme_name,
         'font': s.theme_font,
         'size': s.theme_size,
       },
       'refreshRate': s.refresh_rate,
+      'digest': s.digest,
+      'archiveStarred': s.archive_starred,
+      'collapseDuplicates': s.collapse_duplicates,
+      'retentionDays': (s.retention || {}).days || 0,
+      'trialDays': s.trial_days,
+      'defaultFolder': s.default_folder,
+      'timezone': s.timezone,
+      'browserTimezone': Intl.DateTimeFormat().resolvedOptions().timeZone,
+      'trials': [],
+      'deletedFeeds': [],
+      'defaultView': app.settings.default_view || {},
       'authenticated': app.authenticated,
       'feed_errors': {},
+      'feedErrorHistory': null,
+      'feedStatsPanel': null,
+      'archive': {list: [], hasMore: false, opened: null},
     }
   },
   computed: {
@@ -243,10 +271,27 @@ var vm = new Vue({
           folders[feed.folder_id].push(feed)
         return folders
       }, {})
-      var folders = this.folders.slice().map(function(folder) {
-        folder.feeds = feedsByFolders[folder.id]
-        return folder
-      })
+      // subfolders follow their parent, hidden if any parent is collapsed
+      var foldersById = this.foldersById
+      var subfolders = this.folders.reduce(function(acc, folder) {
+        var parent_id = foldersById[folder.parent_id] ? folder.parent_id : null
+        if (!acc[parent_id])
+          acc[parent_id] = [folder]
+        else
+          acc[parent_id].push(folder)
+        return acc
+      }, {})
+      var folders = []
+      var walk = function(parent_id, depth, hidden) {
+        (subfolders[parent_id] || []).forEach(function(folder) {
+          folder.feeds = feedsByFolders[folder.id]
+          folder.depth = depth
+          folder.hidden = hidden
+          folders.push(folder)
+          walk(folder.id, depth + 1, hidden || !folder.is_expanded)
+        })
+      }
+      walk(null, 0, false)
       folders.push({id: null, feeds: feedsByFolders[null]})
       return folders
     },
@@ -256,19 +301,27 @@ var vm = new Vue({
     foldersById: function() {
       return this.folders.reduce(function(acc, f) { acc[f.id] = f; return acc }, {})
     },
+    searchesById: function() {
+      return this.searches.reduce(function(acc, s) { acc[s.id] = s; return acc }, {})
+    },
+    currentFeedError: function() {
+      return this.current.feed.id ? this.feed_errors[this.current.feed.id] || '' : ''
+    },
     current: function() {
       var parts = (this.feedSelected || '').split(':', 2)
       var type = parts[0]
       var guid = parts[1]
 
-      var folder = {}, feed = {}
+      var folder = {}, feed = {}, search = {}
 
       if (type == 'feed')
         feed = this.feedsById[guid] || {}
       if (type == 'folder')
         folder = this.foldersById[guid] || {}
+      if (type == 'search')
+        search = this.searchesById[guid] || {}
 
-      return {type: type, feed: feed, folder: folder}
+      return {type: type, feed: feed, folder: folder, search: search}
     },
     itemSelectedContent: function() {
       if (!this.itemSelected) return ''
@@ -278,6 +331,14 @@ var vm = new Vue({
 
       return this.itemSelectedDetails.content || ''
     },
+    itemSelectedAudio: function() {
+      // the first audio enclosure, unless the content plays it already
+      var item = this.itemSelectedDetails
+      if (!item) return ''
+      var audio = (item.enclosures || []).find(function(e) { return (e.type || '').indexOf('audio/') == 0 })
+      if (!audio || (item.content || '').indexOf(audio.url) != -1) return ''
+      return audio.url
+    },
   },
   watch: {
     'theme': {
@@ -296,7 +357,7 @@ var vm = new Vue({
       handler: debounce(function() {
         var title = TITLE
         var unreadCount = Object.values(this.feedStats).reduce(function(acc, stat) {
-          return acc + stat.unread
+          return stat.muted ? acc : acc + stat.unread
         }, 0)
         if (unreadCount) {
           title += ' ('+unreadCount+')'
@@ -317,24 +378,40 @@ var vm = new Vue({
       this.itemSelected = null
       if (this.$refs.itemlist) this.$refs.itemlist.scrollTop = 0
     },
+    'currentFeedError': function(newVal) {
+      this.feedErrorHistory = null
+      if (!newVal) return
+      var feedId = this.current.feed.id
+      api.feeds.error_history(feedId).then(function(history) {
+        if (vm.current.feed.id == feedId) vm.feedErrorHistory = history
+      })
+    },
     'itemSelected': function(newVal, oldVal) {
       this.itemSelectedReadability = ''
+      this.itemNoteEditing = false
       if (newVal === null) {
         this.itemSelectedDetails = null
         return
       }
       if (this.$refs.content) this.$refs.content.scrollTop = 0
 
-      api.items.get(newVal).then(function(item) {
-        this.itemSelectedDetails = item
-        if (this.itemSelectedDetails.status == 'unread') {
-          api.items.update(this.itemSelectedDetails.id, {status: 'read'}).then(function() {
-            this.feedStats[this.itemSelectedDetails.feed_id].unread -= 1
-            var itemInList = this.items.find(function(i) { return i.id == item.id })
-            if (itemInList) itemInList.status = 'read'
-            this.itemSelectedDetails.status = 'read'
-          }.bind(this))
+      // the item is marked read without waiting for its content to load
+      var itemInList = this.items.find(function(i) { return i.id == newVal })
+      if (itemInList && itemInList.status == 'unread') this.markItemRead(itemInList)
+
+      Promise.all([
+        api.items.get(newVal, {content: false}),
+        api.items.content(newVal),
+      ]).then(function(results) {
+        if (this.itemSelected != newVal) return
+        var item = results[0]
+        item.content = results[1].content
+        if (itemInList) {
+          item.status = itemInList.status
+        } else if (item.status == 'unread') {
+          this.markItemRead(item)
         }
+        this.itemSelectedDetails = item
       }.bind(this))
     },
     'itemSearch': debounce(function(newVal) {
@@ -344,6 +421,10 @@ var vm = new Vue({
       if (oldVal === undefined) return  // do nothing, initial setup
       api.settings.update({sort_newest_first: newVal}).then(vm.refreshItems.bind(this, false))
     },
+    'itemSortBy': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({sort_by: newVal}).then(vm.refreshItems.bind(this, false))
+    },
     'feedListWidth': debounce(function(newVal, oldVal) {
       if (oldVal === undefined) return  // do nothing, initial setup
       api.settings.update({feed_list_width: newVal})
@@ -356,6 +437,35 @@ var vm = new Vue({
       if (oldVal === undefined) return  // do nothing, initial setup
       api.settings.update({refresh_rate: newVal})
     },
+    'digest': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({digest: newVal})
+    },
+    'archiveStarred': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({archive_starred: newVal})
+    },
+    'collapseDuplicates': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({collapse_duplicates: newVal}).then(vm.refreshItems.bind(this, false))
+    },
+    'retentionDays': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({retention: newVal ? {days: newVal} : {}})
+    },
+    'trialDays': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({trial_days: newVal})
+    },
+    'defaultFolder': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      api.settings.update({default_folder: newVal})
+    },
+    'timezone': function(newVal, oldVal) {
+      if (oldVal === undefined) return  // do nothing, initial setup
+      app.settings.timezone = newVal
+      api.settings.update({timezone: newVal})
+    },
   },
   methods: {
     refreshStats: function(loopMode) {
@@ -366,6 +476,13 @@ var vm = new Vue({
         if (data.running) {
           setTimeout(vm.refreshStats.bind(vm, true), 500)
         }
+        vm.tagStats = data.tag_stats
+        vm.trials = data.trials
+        vm.deletedFeeds = data.deleted
+        vm.searchStats = data.search_stats.reduce(function(acc, stat) {
+          acc[stat.search_id] = stat
+          return acc
+        }, {})
         vm.feedStats = data.stats.reduce(function(acc, stat) {
           acc[stat.feed_id] = stat
           return acc
@@ -386,6 +503,11 @@ var vm = new Vue({
           query.feed_id = guid
         } else if (type == 'folder') {
           query.folder_id = guid
+        } else if (type == 'search') {
+          query.search_id = guid
+        } else if (type == 'tag') {
+          // the tag may contain colons
+          query.tag = this.feedSelected.slice(type.length + 1)
         }
       }
       if (this.filterSelected) {
@@ -397,14 +519,22 @@ var vm = new Vue({
       if (!this.itemSortNewestFirst) {
         query.oldest_first = true
       }
+      if (this.itemSortBy) {
+        query.sort = this.itemSortBy
+      }
+      if (this.itemSearch) {
+        // search results are ranked, see refreshItems for paging
+        query.sort = 'relevance'
+      }
       return query
     },
     refreshFeeds: function() {
       return Promise
-        .all([api.folders.list(), api.feeds.list()])
+        .all([api.folders.list(), api.feeds.list(), api.searches.list()])
         .then(function(values) {
           vm.folders = values[0]
           vm.feeds = values[1]
+          vm.searches = values[2]
         })
     },
     refreshItems: function(loadMore) {
@@ -415,7 +545,9 @@ var vm = new Vue({
       }
 
       var query = this.getItemsQuery()
-      if (loadMore) {
+      if (loadMore && query.sort == 'relevance') {
+        query.offset = vm.items.length
+      } else if (loadMore) {
         query.after = vm.items[vm.items.length-1].id
       }
 
@@ -461,16 +593,32 @@ var vm = new Vue({
         vm.refreshStats()
       })
     },
+    inFolder: function(folder_id, ancestor_id) {
+      // the parents are followed a bounded number of times in case of a loop
+      for (var i = 0; folder_id && i < this.folders.length; i++) {
+        if (folder_id == ancestor_id) return true
+        folder_id = (this.foldersById[folder_id] || {}).parent_id
+      }
+      return false
+    },
     toggleFolderExpanded: function(folder) {
       folder.is_expanded = !folder.is_expanded
       api.folders.update(folder.id, {is_expanded: folder.is_expanded})
     },
+    languageDir: function(language) {
+      if (!language) return undefined
+      var rtl = ['ar', 'arc', 'ckb', 'dv', 'fa', 'he', 'ku-Arab', 'ps', 'sd', 'ug', 'ur', 'yi']
+      for (var i = 0; i < rtl.length; i++) {
+        if (language == rtl[i] || language.indexOf(rtl[i] + '-') == 0) return 'rtl'
+      }
+      return 'ltr'
+    },
     formatDate: function(datestr) {
       var options = {
         year: "numeric", month: "long", day: "numeric",
         hour: '2-digit', minute: '2-digit',
       }
-      return new Date(datestr).toLocaleDateString(undefined, options)
+      return new Date(datestr).toLocaleDateString(undefined, dateOptions(options))
     },
     moveFeed: function(feed, folder) {
       var folder_id = folder ? folder.id : null
@@ -509,11 +657,70 @@ var vm = new Vue({
         api.folders.update(folder.id, {title: newTitle}).then(function() {
           folder.title = newTitle
           this.folders.sort(function(a, b) {
+            if (a.custom_order != b.custom_order) return a.custom_order < b.custom_order ? -1 : 1
             return a.title.localeCompare(b.title)
           })
         }.bind(this))
       }
     },
+    dragFeed: function(event, feed) {
+      this.feedDragged = feed
+      event.dataTransfer.effectAllowed = 'move'
+      event.dataTransfer.setData('text/plain', feed.feed_link)
+    },
+    // dropFeed puts the dragged feed before the target one,
+    // or at the end of the folder if there's no target.
+    dropFeed: function(folder, target) {
+      var dragged = this.feedDragged
+      this.feedDragged = null
+      if (!dragged || dragged === target) return
+      var feeds = (folder.feeds || []).filter(function(f) { return f.id != dragged.id })
+      var index = target ? feeds.indexOf(target) : feeds.length
+      feeds.splice(index, 0, dragged)
+      var folderId = folder.id || null
+      api.feeds.reorder(feeds.map(function(f) {
+        return {feed_id: f.id, folder_id: folderId}
+      })).then(function() {
+        vm.refreshFeeds()
+      })
+    },
+    dragFolder: function(event, folder) {
+      this.folderDragged = folder
+      event.dataTransfer.effectAllowed = 'move'
+      event.dataTransfer.setData('text/plain', folder.title)
+    },
+    // dropOnFolder puts the dragged feed at the end of the folder,
+    // or the dragged folder before it (next to it if nested elsewhere).
+    dropOnFolder: function(target) {
+      if (this.feedDragged) return this.dropFeed(target, null)
+
+      var dragged = this.folderDragged
+      this.folderDragged = null
+      if (!dragged || dragged === target) return
+      var siblings = this.foldersWithFeeds.filter(function(f) {
+        return f.id && f.id != dragged.id && f.parent_id == target.parent_id
+      })
+      siblings.splice(siblings.indexOf(target), 0, dragged)
+      var ids = siblings.map(function(f) { return f.id })
+
+      var moved = Promise.resolve()
+      if (dragged.parent_id != target.parent_id)
+        moved = api.folders.update(dragged.id, {parent_id: target.parent_id})
+      moved.then(function(res) {
+        // e.g. a folder dropped into its own subfolder
+        if (res && !res.ok) return
+        return api.folders.reorder(ids)
+      }).then(function() {
+        vm.refreshFeeds()
+      })
+    },
+    moveFolder: function(folder, parent) {
+      var parent_id = parent ? parent.id : null
+      api.folders.update(folder.id, {parent_id: parent_id}).then(function() {
+        folder.parent_id = parent_id
+        if (parent && !parent.is_expanded) vm.toggleFolderExpanded(parent)
+      })
+    },
     deleteFolder: function(folder) {
       if (confirm('Are you sure you want to delete ' + folder.title + '?')) {
         api.folders.delete(folder.id).then(function() {
@@ -531,6 +738,89 @@ var vm = new Vue({
         })
       }
     },
+    setFeedCredentials: function(feed) {
+      var value = prompt('Enter the credentials of the feed as username:password (empty to remove them)', '')
+      if (value === null) return
+      var sep = value.indexOf(':')
+      var data = value ? {username: value.slice(0, sep < 0 ? value.length : sep), password: sep < 0 ? '' : value.slice(sep+1)} : null
+      api.feeds.credentials(feed.id, data).then(function(res) {
+        if (res.ok) {
+          feed.has_credentials = !!data
+        } else {
+          res.json().then(function(err) { alert(err.error) })
+        }
+      })
+    },
+    showFeedStats: function(feed) {
+      this.feedStatsPanel = null
+      this.showSettings('stats')
+      api.feeds.stats(feed.id).then(function(stats) {
+        vm.feedStatsPanel = Object.assign({title: feed.title}, stats)
+      })
+    },
+    showArchive: function() {
+      this.archive = {list: [], hasMore: false, opened: null}
+      this.showSettings('archive')
+      this.loadArchive()
+    },
+    loadArchive: function() {
+      var list = this.archive.list
+      var query = list.length ? {after: list[list.length-1].id} : {}
+      api.archive.list(query).then(function(data) {
+        vm.archive.list = list.concat(data.list)
+        vm.archive.hasMore = data.has_more
+      })
+    },
+    openArchivedItem: function(archived) {
+      if (this.archive.opened && this.archive.opened.id == archived.id) {
+        this.archive.opened = null
+        return
+      }
+      api.archive.get(archived.id).then(function(details) {
+        vm.archive.opened = details
+      })
+    },
+    deleteArchivedItem: function(archived) {
+      if (!confirm('Remove "' + archived.title + '" from the archive?')) return
+      api.archive.delete(archived.id).then(function() {
+        vm.archive.list = vm.archive.list.filter(function(x) { return x.id != archived.id })
+        if (vm.archive.opened && vm.archive.opened.id == archived.id) vm.archive.opened = null
+      })
+    },
+    formatBytes: function(bytes) {
+      if (bytes < 1024) return bytes + ' B'
+      if (bytes < 1024 * 1024) return (bytes / 1024).toFixed(1) + ' KB'
+      return (bytes / 1024 / 1024).toFixed(1) + ' MB'
+    },
+    endTrial: function(trial, keep) {
+      if (!keep && !confirm('Are you sure you want to unsubscribe from ' + trial.title + '?')) return
+      api.feeds.end_trial(trial.feed_id, keep).then(function() {
+        vm.refreshStats()
+        vm.refreshFeeds()
+      })
+    },
+    restoreFeed: function(deleted) {
+      api.feeds.restore(deleted.feed_id).then(function() {
+        vm.refreshStats()
+        vm.refreshFeeds()
+      })
+    },
+    purgeFeed: function(deleted) {
+      if (!confirm('Are you sure you want to delete ' + deleted.title + ' for good?')) return
+      api.feeds.purge(deleted.feed_id).then(function() {
+        vm.refreshStats()
+      })
+    },
+    setFeedDailyLimit: function(feed, limit) {
+      api.feeds.update(feed.id, {daily_limit: limit}).then(function() {
+        feed.daily_limit = limit
+      })
+    },
+    setFeedRefreshInterval: function(feed, minutes) {
+      api.feeds.update(feed.id, {refresh_interval: minutes}).then(function() {
+        feed.refresh_interval = minutes
+      })
+    },
     renameFeed: function(feed) {
       var newTitle = prompt('Enter new title', feed.title)
       if (newTitle) {
@@ -540,7 +830,7 @@ var vm = new Vue({
       }
     },
     deleteFeed: function(feed) {
-      if (confirm('Are you sure you want to delete ' + feed.title + '?')) {
+      if (confirm('Are you sure you want to delete ' + feed.title + '? It can be restored for a week.')) {
         api.feeds.delete(feed.id).then(function() {
           vm.feedSelected = null
           vm.refreshStats()
@@ -548,6 +838,37 @@ var vm = new Vue({
         })
       }
     },
+    saveSearch: function() {
+      var title = prompt('Enter search title', this.itemSearch)
+      if (!title) return
+      var data = {title: title, query: this.itemSearch}
+      var parts = (this.feedSelected || '').split(':', 2)
+      if (parts[0] == 'feed') data.feed_id = +parts[1]
+      if (parts[0] == 'folder') data.folder_id = +parts[1]
+      if (parts[0] == 'tag') data.tag = this.feedSelected.slice(4)
+      api.searches.create(data).then(function(search) {
+        vm.itemSearch = ''
+        vm.refreshFeeds()
+        vm.refreshStats()
+        vm.feedSelected = 'search:' + search.id
+      })
+    },
+    renameSearch: function(search) {
+      var newTitle = prompt('Enter new title', search.title)
+      if (newTitle) {
+        api.searches.update(search.id, Object.assign({}, search, {title: newTitle})).then(function() {
+          search.title = newTitle
+        })
+      }
+    },
+    deleteSearch: function(search) {
+      if (confirm('Are you sure you want to delete ' + search.title + '?')) {
+        api.searches.delete(search.id).then(function() {
+          vm.feedSelected = null
+          vm.refreshFeeds()
+        })
+      }
+    },
     createFeed: function(event) {
       var form = event.target
       var data = {
@@ -567,6 +888,8 @@ var vm = new Vue({
         } else if (result.status === 'multiple') {
           vm.feedNewChoice = result.choice
           vm.feedNewChoiceSelected = result.choice[0].url
+        } else if (result.error) {
+          alert(result.error)
         } else {
           alert('No feeds found at the given url.')
         }
@@ -598,6 +921,61 @@ var vm = new Vue({
     toggleItemRead: function(item) {
       this.toggleItemStatus(item, 'unread', 'read')
     },
+    markItemRead: function(item) {
+      api.items.update(item.id, {status: 'read'}).then(function() {
+        this.feedStats[item.feed_id].unread -= 1
+        item.status = 'read'
+        if (this.itemSelectedDetails && this.itemSelectedDetails.id == item.id) {
+          this.itemSelectedDetails.status = 'read'
+        }
+      }.bind(this))
+    },
+    snoozeItem: function(item, hours) {
+      var oldstatus = item.status
+      var until = new Date(Date.now() + hours * 3600 * 1000)
+      api.items.snooze(item.id, until.toISOString()).then(function(snoozed) {
+        if (oldstatus == 'unread') this.feedStats[item.feed_id].unread -= 1
+        var itemInList = this.items.find(function(i) { return i.id == item.id })
+        if (itemInList) itemInList.status = snoozed.status
+        item.status = snoozed.status
+        item.snoozed_until = snoozed.snoozed_until
+      }.bind(this))
+    },
+    unsnoozeItem: function(item) {
+      api.items.unsnooze(item.id).then(function() {
+        this.feedStats[item.feed_id].unread += 1
+        var itemInList = this.items.find(function(i) { return i.id == item.id })
+        if (itemInList) itemInList.status = 'unread'
+        item.status = 'unread'
+        item.snoozed_until = null
+      }.bind(this))
+    },
+    addItemTag: function(item, tag) {
+      if (!tag.trim()) return
+      api.items.add_tag(item.id, tag).then(function(tagged) {
+        var itemInList = this.items.find(function(i) { return i.id == item.id })
+        if (itemInList) itemInList.tags = tagged.tags
+        item.tags = tagged.tags
+        this.refreshStats()
+      }.bind(this))
+    },
+    removeItemTag: function(item, tag) {
+      api.items.remove_tag(item.id, tag).then(function() {
+        var tags = (item.tags || []).filter(function(t) { return t != tag })
+        var itemInList = this.items.find(function(i) { return i.id == item.id })
+        if (itemInList) itemInList.tags = tags
+        item.tags = tags
+        this.refreshStats()
+      }.bind(this))
+    },
+    saveItemNote: function(item, note) {
+      api.items.set_note(item.id, note).then(function(updated) {
+        var itemInList = this.items.find(function(i) { return i.id == item.id })
+        if (itemInList) itemInList.note = updated.note || ''
+        item.note = updated.note || ''
+        this.itemNoteEditing = false
+      }.bind(this))
+    },
     importOPML: function(event) {
       var input = event.target
       var form = document.querySelector('#opml-import-form')
@@ -608,6 +986,15 @@ var vm = new Vue({
         vm.refreshStats()
       })
     },
+    importStates: function(event) {
+      var input = event.target
+      this.$refs.menuDropdown.hide()
+      input.files[0].text().then(JSON.parse).then(api.items.import_states).then(function() {
+        input.value = ''
+        vm.refreshItems(false)
+        vm.refreshStats()
+      })
+    },
     logout: function() {
       api.logout().then(function() {
         document.location.reload()
@@ -628,6 +1015,20 @@ var vm = new Vue({
         })
       }
     },
+    saveDefaultView: function(current) {
+      var view = {}
+      if (current) {
+        view = {
+          feed: this.feedSelected,
+          filter: this.filterSelected,
+          sort_by: this.itemSortBy,
+          sort_newest_first: this.itemSortNewestFirst,
+        }
+      }
+      api.settings.update({default_view: view}).then(function() {
+        this.defaultView = view
+      }.bind(this))
+    },
     showSettings: function(settings) {
       this.settings = settings
 
@@ -671,12 +1072,18 @@ var vm = new Vue({
         if (!this.feedStats[feed.id]) continue
 
         var n = vm.feedStats[feed.id][filter] || 0
-
-        if (!statsFolders[feed.folder_id]) statsFolders[feed.folder_id] = 0
+        // muted folders aren't counted until their quiet period is over
+        if (filter == 'unread' && vm.feedStats[feed.id].muted) n = 0
 
         statsFeeds[feed.id] = n
-        statsFolders[feed.folder_id] += n
         statsTotal += n
+
+        // the folders count the feeds of their subfolders too
+        var folder_id = feed.folder_id
+        for (var j = 0; folder_id && j < this.folders.length; j++) {
+          statsFolders[folder_id] = (statsFolders[folder_id] || 0) + n
+          folder_id = (this.foldersById[folder_id] || {}).parent_id
+        }
       }
 
       this.filteredFeedStats = statsFeeds
@@ -687,3 +1094,18 @@ var vm = new Vue({
 })
 
 vm.$mount('#app')
+
+// caching the app and the latest unread items for offline use, see sw.js
+if ('serviceWorker' in navigator) {
+  navigator.serviceWorker.register('./sw.js')
+    .then(function() { return navigator.serviceWorker.ready })
+    .then(function(registration) {
+      registration.active.postMessage('sync')
+      if (registration.periodicSync) {
+        registration.periodicSync.register('bundle', {minInterval: 6 * 3600 * 1000}).catch(function() {})
+      }
+    })
+    .catch(function(error) {
+      console.log('failed to register the service worker', error)
+    })
+}
@@ -0,0 +1,107 @@
+'use strict';
+
+// The service worker keeps the app usable offline: the static files
+// (whose names change along with their content) are served from the cache,
+// the rest from the network, falling back to the last response cached.
+// The latest unread items are precached from ./api/items/bundle.
+
+var CACHE = 'yarr-v1'
+var BUNDLE_SIZE = 100
+
+// the app's base url, ending with a slash
+var base = self.registration.scope
+
+var jsonResponse = function(data) {
+  return new Response(JSON.stringify(data), {headers: {'Content-Type': 'application/json'}})
+}
+
+var store = function(request, response) {
+  if (!response.ok || response.type != 'basic') return response
+  var copy = response.clone()
+  caches.open(CACHE).then(function(cache) { cache.put(request, copy) })
+  return response
+}
+
+var cacheFirst = function(request) {
+  return caches.match(request).then(function(cached) {
+    return cached || fetch(request).then(store.bind(null, request))
+  })
+}
+
+var networkFirst = function(request) {
+  return fetch(request).then(store.bind(null, request)).catch(function(error) {
+    return caches.match(request).then(function(cached) {
+      if (cached) return cached
+      throw error
+    })
+  })
+}
+
+// syncBundle caches the latest unread items the way the app requests them,
+// and drops the cached items which aren't among them anymore.
+var syncBundle = function() {
+  return fetch(base + 'api/items/bundle?limit=' + BUNDLE_SIZE, {credentials: 'same-origin'})
+    .then(function(response) {
+      if (!response.ok) return
+      return Promise.all([response.json(), caches.open(CACHE)]).then(function(results) {
+        var bundle = results[0], cache = results[1]
+        var unread = bundle.unread_ids.reduce(function(acc, id) { acc[id] = true; return acc }, {})
+        var pending = bundle.items.map(function(item) {
+          var details = Object.assign({}, item, {content: ''})
+          return Promise.all([
+            cache.put(base + 'api/items/' + item.id + '?content=false', jsonResponse(details)),
+            cache.put(base + 'api/items/' + item.id + '/content', jsonResponse({content: item.content})),
+          ])
+        })
+        pending.push(cache.put(base + 'api/feeds', jsonResponse(bundle.feeds)))
+        pending.push(cache.keys().then(function(requests) {
+          return Promise.all(requests.map(function(request) {
+            var match = request.url.slice(base.length).match(/^api\/items\/(\d+)(\?content=false|\/content)$/)
+            if (match && !unread[match[1]]) return cache.delete(request)
+          }))
+        }))
+        return Promise.all(pending)
+      })
+    })
+    .catch(function(error) {
+      console.log('failed to sync the items', error)
+    })
+}
+
+self.addEventListener('install', function(event) {
+  event.waitUntil(self.skipWaiting())
+})
+
+self.addEventListener('activate', function(event) {
+  event.waitUntil(caches.keys()
+    .then(function(keys) {
+      return Promise.all(keys.filter(function(key) { return key != CACHE }).map(function(key) {
+        return caches.delete(key)
+      }))
+    })
+    .then(function() { return self.clients.claim() }))
+})
+
+self.addEventListener('fetch', function(event) {
+  var request = event.request
+  if (request.method != 'GET' || !request.url.startsWith(base)) return
+
+  var path = request.url.slice(base.length)
+  // the live state isn't worth caching
+  if (path.startsWith('api/ws') || path.startsWith('api/status') || path.startsWith('api/items/bundle')) return
+
+  // the hashed names of the static files, see assets.Hashed
+  if (/^static\/.*\.[0-9a-f]{10}\.\w+$/.test(path)) {
+    event.respondWith(cacheFirst(request))
+  } else {
+    event.respondWith(networkFirst(request))
+  }
+})
+
+self.addEventListener('message', function(event) {
+  if (event.data == 'sync') event.waitUntil(syncBundle())
+})
+
+self.addEventListener('periodicsync', function(event) {
+  if (event.tag == 'bundle') event.waitUntil(syncBundle())
+})
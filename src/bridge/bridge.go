@@ -0,0 +1,214 @@
+// Package bridge talks to an RSS-Bridge instance (https://github.com/RSS-Bridge/rss-bridge),
+// which makes feeds out of the sites without any (Instagram, Telegram and the like).
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// the list of bridges is large (a few hundred of them)
+const maxListSize = 8 << 20
+
+// globalContext holds the parameters shared by the other contexts.
+const globalContext = "global"
+
+// Bridge is a source RSS-Bridge makes feeds of. Its parameters are
+// grouped in contexts, the ways to use it (e.g. by user or by hashtag),
+// one of which is chosen.
+type Bridge struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URI         string `json:"uri"`
+	// context name -> parameter name -> parameter
+	Contexts map[string]map[string]Parameter `json:"contexts"`
+	// the parameters of every context
+	Global map[string]Parameter `json:"global,omitempty"`
+}
+
+type Parameter struct {
+	Title        string      `json:"name"`
+	Type         string      `json:"type,omitempty"`
+	Required     bool        `json:"required,omitempty"`
+	ExampleValue interface{} `json:"exampleValue,omitempty"`
+	DefaultValue interface{} `json:"defaultValue,omitempty"`
+	// the choices of a list: label -> value (or a group of them)
+	Values interface{} `json:"values,omitempty"`
+}
+
+type bridgeInfo struct {
+	Status      string          `json:"status"`
+	Name        string          `json:"name"`
+	URI         string          `json:"uri"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// contexts reads the parameters of the bridge. They're an object of the
+// contexts, unless there are no named ones (a list of PHP arrays then):
+// these parameters go to the global context.
+func (info bridgeInfo) contexts() (map[string]map[string]Parameter, error) {
+	contexts := make(map[string]map[string]Parameter)
+	if len(info.Parameters) == 0 || info.Parameters[0] != '[' {
+		if len(info.Parameters) > 0 {
+			if err := json.Unmarshal(info.Parameters, &contexts); err != nil {
+				return nil, err
+			}
+		}
+		return contexts, nil
+	}
+	var unnamed []map[string]Parameter
+	if err := json.Unmarshal(info.Parameters, &unnamed); err != nil {
+		return nil, err
+	}
+	global := make(map[string]Parameter)
+	for _, params := range unnamed {
+		for name, p := range params {
+			global[name] = p
+		}
+	}
+	if len(global) > 0 {
+		contexts[globalContext] = global
+	}
+	return contexts, nil
+}
+
+// Client talks to the instance at URL.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+	URL       string
+}
+
+// List returns the bridges enabled on the instance, by name.
+func (c *Client) List(ctx context.Context) ([]Bridge, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint(url.Values{"action": {"list"}}), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %s", c.URL, res.Status)
+	}
+	var list struct {
+		Bridges map[string]bridgeInfo `json:"bridges"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxListSize)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("%s isn't an RSS-Bridge instance: %s", c.URL, err)
+	}
+	result := make([]Bridge, 0, len(list.Bridges))
+	for name, info := range list.Bridges {
+		if info.Status != "active" {
+			continue
+		}
+		contexts, err := info.contexts()
+		if err != nil {
+			// a bridge with unusual parameters shouldn't hide the rest
+			continue
+		}
+		b := Bridge{
+			Name:        name,
+			Title:       info.Name,
+			Description: info.Description,
+			URI:         info.URI,
+			Contexts:    make(map[string]map[string]Parameter),
+			Global:      contexts[globalContext],
+		}
+		for context, params := range contexts {
+			if context != globalContext {
+				b.Contexts[context] = params
+			}
+		}
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Find returns the bridge of the name, nil if there's none.
+func Find(bridges []Bridge, name string) *Bridge {
+	for i := range bridges {
+		if bridges[i].Name == name {
+			return &bridges[i]
+		}
+	}
+	return nil
+}
+
+// Check validates the parameters for the context (empty if the bridge
+// has none), returning a description of the first problem.
+func (b *Bridge) Check(context string, params map[string]string) error {
+	known := make(map[string]Parameter)
+	for name, p := range b.Global {
+		known[name] = p
+	}
+	if len(b.Contexts) > 0 {
+		contextParams, ok := b.Contexts[context]
+		if !ok {
+			return fmt.Errorf("unknown context %q of %s", context, b.Name)
+		}
+		for name, p := range contextParams {
+			known[name] = p
+		}
+	} else if context != "" {
+		return fmt.Errorf("%s has no contexts", b.Name)
+	}
+	for name := range params {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown parameter %q of %s", name, b.Name)
+		}
+	}
+	for name, p := range known {
+		if p.Required && strings.TrimSpace(params[name]) == "" {
+			return fmt.Errorf("%s (%s) is required", p.Title, name)
+		}
+	}
+	return nil
+}
+
+// FeedURL returns the url of the bridge's Atom feed on the instance.
+func (c *Client) FeedURL(bridge, context string, params map[string]string) string {
+	query := url.Values{}
+	for name, val := range params {
+		query.Set(name, val)
+	}
+	// set last, not to be overridden by the parameters
+	query.Set("action", "display")
+	query.Set("bridge", bridge)
+	query.Set("format", "Atom")
+	if context != "" {
+		query.Set("context", context)
+	} else {
+		query.Del("context")
+	}
+	return c.endpoint(query)
+}
+
+func (c *Client) endpoint(query url.Values) string {
+	base := c.URL
+	if i := strings.IndexAny(base, "?#"); i >= 0 {
+		base = base[:i]
+	}
+	if !strings.HasSuffix(base, "/") && !strings.HasSuffix(base, ".php") {
+		base += "/"
+	}
+	return base + "?" + query.Encode()
+}
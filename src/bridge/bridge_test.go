@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const list = `{"bridges": {
+	"InstagramBridge": {
+		"status": "active", "name": "Instagram Bridge", "uri": "https://www.instagram.com",
+		"parameters": {
+			"Username": {"u": {"name": "username", "required": true}},
+			"Hashtag": {"h": {"name": "hashtag", "required": true}},
+			"global": {"media_type": {"name": "Media type", "type": "list", "values": {"All": "all", "Video": "video"}}}
+		}
+	},
+	"CssSelectorBridge": {"status": "active", "name": "CSS Selector", "parameters": [
+		{"home_page": {"name": "Site", "required": true}}
+	]},
+	"NoParamsBridge": {"status": "active", "name": "No parameters", "parameters": []},
+	"DisabledBridge": {"status": "inactive"}
+}, "total": 3}`
+
+func TestList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("action") != "list" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(list))
+	}))
+	defer srv.Close()
+
+	client := &Client{URL: srv.URL}
+	bridges, err := client.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bridges) != 3 || bridges[0].Name != "CssSelectorBridge" || bridges[1].Name != "InstagramBridge" {
+		t.Fatalf("unexpected bridges: %#v", bridges)
+	}
+	instagram := Find(bridges, "InstagramBridge")
+	if len(instagram.Contexts) != 2 || instagram.Global["media_type"].Type != "list" {
+		t.Fatalf("unexpected parameters: %#v", instagram)
+	}
+
+	if err := instagram.Check("Username", map[string]string{"u": "someone", "media_type": "video"}); err != nil {
+		t.Error(err)
+	}
+	if err := instagram.Check("Username", map[string]string{"h": "tag"}); err == nil {
+		t.Error("expected an error for a parameter of another context")
+	}
+	if err := instagram.Check("Username", map[string]string{}); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+	if err := instagram.Check("Location", map[string]string{"u": "someone"}); err == nil {
+		t.Error("expected an error for an unknown context")
+	}
+	// the bridges with a single set of parameters have no named context
+	css := Find(bridges, "CssSelectorBridge")
+	if err := css.Check("", map[string]string{"home_page": "https://example.com"}); err != nil {
+		t.Error(err)
+	}
+	if err := css.Check("", nil); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+	if err := Find(bridges, "NoParamsBridge").Check("", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFeedURL(t *testing.T) {
+	for _, base := range []string{"https://bridge.example.com", "https://bridge.example.com/", "https://bridge.example.com/?action=list"} {
+		client := &Client{URL: base}
+		link := client.FeedURL("InstagramBridge", "Username", map[string]string{"u": "someone", "action": "list"})
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		if u.Path != "/" || query.Get("action") != "display" || query.Get("bridge") != "InstagramBridge" ||
+			query.Get("context") != "Username" || query.Get("u") != "someone" || query.Get("format") != "Atom" {
+			t.Errorf("unexpected url of %s: %s", base, link)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package client talks to a running yarr instance
+// through the same API the web interface uses.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Folder struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	ParentId *int64 `json:"parent_id"`
+}
+
+type Feed struct {
+	Id       int64  `json:"id"`
+	FolderId *int64 `json:"folder_id"`
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+	FeedLink string `json:"feed_link"`
+}
+
+// Stat holds the counters of a feed.
+type Stat struct {
+	FeedId  int64 `json:"feed_id"`
+	Unread  int64 `json:"unread"`
+	Starred int64 `json:"starred"`
+}
+
+// Item statuses.
+const (
+	Unread  = "unread"
+	Read    = "read"
+	Starred = "starred"
+)
+
+type Item struct {
+	Id     int64     `json:"id"`
+	FeedId int64     `json:"feed_id"`
+	Title  string    `json:"title"`
+	Link   string    `json:"link"`
+	Author string    `json:"author,omitempty"`
+	Date   time.Time `json:"date"`
+	Status string    `json:"status"`
+}
+
+// ItemQuery selects the items to list, any of the fields may be empty.
+type ItemQuery struct {
+	FolderId *int64
+	FeedId   *int64
+	Status   string
+	Search   string
+	// the id of the last item of the previous page
+	After *int64
+}
+
+func (q ItemQuery) values() url.Values {
+	values := url.Values{}
+	if q.FolderId != nil {
+		values.Set("folder_id", strconv.FormatInt(*q.FolderId, 10))
+	}
+	if q.FeedId != nil {
+		values.Set("feed_id", strconv.FormatInt(*q.FeedId, 10))
+	}
+	if q.Status != "" {
+		values.Set("status", q.Status)
+	}
+	if q.Search != "" {
+		values.Set("search", q.Search)
+	}
+	if q.After != nil {
+		values.Set("after", strconv.FormatInt(*q.After, 10))
+	}
+	return values
+}
+
+type Client struct {
+	base string
+	http *http.Client
+}
+
+// New connects to the instance at the given address (including the
+// base path), logging in if the username isn't empty.
+func New(addr, username, password string) (*Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		base: strings.TrimSuffix(addr, "/"),
+		http: &http.Client{Jar: jar, Timeout: 30 * time.Second},
+	}
+	if username != "" {
+		form := url.Values{"username": {username}, "password": {password}}
+		res, err := c.http.PostForm(c.base+"/", form)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		if u, _ := url.Parse(c.base + "/"); len(jar.Cookies(u)) == 0 {
+			return nil, fmt.Errorf("login failed")
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiErr)
+		if apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: status code %d", method, path, res.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: %s", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) Folders() ([]Folder, error) {
+	var folders []Folder
+	err := c.do("GET", "/api/folders", nil, &folders)
+	return folders, err
+}
+
+func (c *Client) Feeds() ([]Feed, error) {
+	var feeds []Feed
+	err := c.do("GET", "/api/feeds", nil, &feeds)
+	return feeds, err
+}
+
+func (c *Client) Stats() ([]Stat, error) {
+	var status struct {
+		Stats []Stat `json:"stats"`
+	}
+	err := c.do("GET", "/api/status", nil, &status)
+	return status.Stats, err
+}
+
+// Items returns a page of the matching items, newest first,
+// and whether there are more.
+func (c *Client) Items(q ItemQuery) ([]Item, bool, error) {
+	var list struct {
+		List    []Item `json:"list"`
+		HasMore bool   `json:"has_more"`
+	}
+	err := c.do("GET", "/api/items?"+q.values().Encode(), nil, &list)
+	return list.List, list.HasMore, err
+}
+
+// ItemContent returns the sanitized html content of the item.
+func (c *Client) ItemContent(id int64) (string, error) {
+	var content struct {
+		Content string `json:"content"`
+	}
+	err := c.do("GET", fmt.Sprintf("/api/items/%d/content", id), nil, &content)
+	return content.Content, err
+}
+
+// ItemText returns the content of the item converted to plain text,
+// the links followed by their urls.
+func (c *Client) ItemText(id int64) (string, error) {
+	var content struct {
+		Content string `json:"content"`
+	}
+	err := c.do("GET", fmt.Sprintf("/api/items/%d/content?format=text", id), nil, &content)
+	return content.Content, err
+}
+
+func (c *Client) SetStatus(id int64, status string) error {
+	return c.do("PUT", fmt.Sprintf("/api/items/%d", id), map[string]string{"status": status}, nil)
+}
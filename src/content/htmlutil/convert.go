@@ -0,0 +1,389 @@
+package htmlutil
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// PlainText converts the (sanitized) html into paragraphs of text,
+// keeping the urls of the links after their text.
+func PlainText(content string) string {
+	return convert(content, false)
+}
+
+// Markdown converts the (sanitized) html into Markdown,
+// keeping the links, images, emphasis, lists, quotes and code.
+func Markdown(content string) string {
+	return convert(content, true)
+}
+
+func convert(content string, markdown bool) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ExtractText(content)
+	}
+	c := &converter{markdown: markdown}
+	c.walk(doc)
+	return strings.TrimRight(c.b.String(), " \n")
+}
+
+var convertBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "aside": true, "nav": true, "address": true,
+	"figure": true, "figcaption": true, "details": true, "summary": true,
+	"table": true, "dl": true, "dt": true, "dd": true, "form": true, "fieldset": true,
+}
+
+var convertSkipTags = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true, "noscript": true,
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+type converter struct {
+	markdown bool
+	b        strings.Builder
+
+	// the line breaks to write before the next text, they're held back
+	// so that the empty lines get the prefix shared by the lines around
+	newlines int
+	// the prefix of the last line written
+	lastPrefix string
+	// whether whitespace was seen since the last write
+	space bool
+	// written at the start of every line, for quotes and list items
+	prefix string
+	// the list item marker, written instead of the end of the prefix
+	// on the next line
+	marker string
+	// the opening markup (emphasis, links), written along with
+	// the next text so that it's dropped if there's none
+	pending string
+	// the depth of <pre> and of the lists
+	pre   int
+	lists int
+}
+
+func (c *converter) lineStart() bool {
+	return c.b.Len() == 0 || c.newlines > 0
+}
+
+func (c *converter) write(s string) {
+	if s == "" {
+		return
+	}
+	if c.lineStart() {
+		if c.b.Len() > 0 {
+			c.b.WriteString("\n")
+			shared := commonPrefix(c.lastPrefix, c.prefix)
+			for i := 1; i < c.newlines; i++ {
+				c.b.WriteString(strings.TrimRight(shared, " ") + "\n")
+			}
+		}
+		c.lastPrefix = c.prefix
+		if c.marker != "" {
+			indent := utf8.RuneCountInString(c.marker)
+			c.b.WriteString(c.prefix[:len(c.prefix)-indent] + c.marker)
+			c.marker = ""
+		} else {
+			c.b.WriteString(c.prefix)
+		}
+	} else if c.space {
+		c.b.WriteString(" ")
+	}
+	c.b.WriteString(c.pending + s)
+	c.pending = ""
+	c.newlines = 0
+	c.space = false
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// open starts the markup which is closed by close.
+func (c *converter) open(markup string) {
+	c.pending += markup
+}
+
+// close ends the markup, dropping it if nothing was written in between.
+func (c *converter) close(markup, end string) {
+	if strings.HasSuffix(c.pending, markup) {
+		c.pending = strings.TrimSuffix(c.pending, markup)
+		return
+	}
+	c.b.WriteString(end)
+}
+
+func (c *converter) newline() {
+	c.newlines++
+	c.space = false
+}
+
+// line makes sure that the next text starts on a new line.
+func (c *converter) line() {
+	if c.b.Len() == 0 || c.marker != "" {
+		return
+	}
+	if c.newlines == 0 {
+		c.newline()
+	}
+}
+
+// block makes sure that the next text starts a new paragraph.
+func (c *converter) block() {
+	if c.b.Len() == 0 || c.marker != "" {
+		return
+	}
+	for c.newlines < 2 {
+		c.newline()
+	}
+}
+
+func (c *converter) text(s string) {
+	if c.pre > 0 {
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				c.newline()
+			}
+			c.write(line)
+		}
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(s); unicode.IsSpace(r) {
+		c.space = true
+	}
+	for i, word := range strings.Fields(s) {
+		if i > 0 {
+			c.space = true
+		}
+		if c.markdown {
+			word = markdownEscaper.Replace(word)
+			// not to be taken for a heading, a list item or a quote
+			if c.lineStart() && c.pending == "" && strings.ContainsAny(word[:1], "#-+>") {
+				word = `\` + word
+			}
+		}
+		c.write(word)
+	}
+	if r, _ := utf8.DecodeLastRuneInString(s); unicode.IsSpace(r) {
+		c.space = true
+	}
+}
+
+func rawText(node *html.Node) string {
+	var b strings.Builder
+	for _, n := range FindNodes(node, func(n *html.Node) bool { return n.Type == html.TextNode }) {
+		b.WriteString(n.Data)
+	}
+	return b.String()
+}
+
+func (c *converter) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+}
+
+func (c *converter) walk(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		c.text(node.Data)
+		return
+	case html.ElementNode:
+	default:
+		c.children(node)
+		return
+	}
+
+	tag := node.Data
+	switch {
+	case convertSkipTags[tag]:
+	case convertBlockTags[tag]:
+		c.block()
+		c.children(node)
+		c.block()
+	case tag == "br":
+		if c.markdown && c.pre == 0 && !c.lineStart() {
+			c.b.WriteString("  ")
+		}
+		c.newline()
+	case tag == "hr":
+		c.block()
+		if c.markdown {
+			c.write("---")
+		}
+		c.block()
+	case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+		c.block()
+		markup := ""
+		if c.markdown {
+			markup = strings.Repeat("#", int(tag[1]-'0')) + " "
+		}
+		c.open(markup)
+		c.children(node)
+		c.close(markup, "")
+		c.block()
+	case tag == "blockquote":
+		c.block()
+		prefix := c.prefix
+		c.prefix += "> "
+		c.children(node)
+		c.block()
+		c.prefix = prefix
+		c.block()
+	case tag == "pre":
+		c.block()
+		if c.markdown {
+			c.write("```")
+			c.newline()
+		}
+		c.pre++
+		c.children(node)
+		c.pre--
+		if c.markdown {
+			c.line()
+			c.write("```")
+		}
+		c.block()
+	case tag == "code" && c.markdown && c.pre == 0:
+		if code := strings.Join(strings.Fields(rawText(node)), " "); code != "" {
+			if strings.Contains(code, "`") {
+				code = "`` " + code + " ``"
+			} else {
+				code = "`" + code + "`"
+			}
+			c.write(code)
+		}
+	case (tag == "strong" || tag == "b") && c.markdown:
+		c.open("**")
+		c.children(node)
+		c.close("**", "**")
+	case (tag == "em" || tag == "i") && c.markdown:
+		c.open("_")
+		c.children(node)
+		c.close("_", "_")
+	case tag == "ul" || tag == "ol":
+		if c.lists > 0 {
+			c.line()
+		} else {
+			c.block()
+		}
+		c.lists++
+		c.list(node)
+		c.lists--
+		if c.lists > 0 {
+			c.line()
+		} else {
+			c.block()
+		}
+	case tag == "li":
+		// outside of a list
+		c.item(node, "-")
+	case tag == "tr":
+		c.line()
+		c.children(node)
+		c.line()
+	case tag == "td" || tag == "th":
+		c.children(node)
+		c.space = true
+	case tag == "a":
+		c.link(node)
+	case tag == "img":
+		c.image(Attr(node, "src"), Attr(node, "alt"))
+	case tag == "iframe" || tag == "video" || tag == "audio":
+		src := Attr(node, "src")
+		if src == "" {
+			if source := Query(node, "source"); len(source) > 0 {
+				src = Attr(source[0], "src")
+			}
+		}
+		if src == "" {
+			return
+		}
+		if c.markdown {
+			c.write("[" + tag + "](" + src + ")")
+		} else {
+			c.write(src)
+		}
+	default:
+		c.children(node)
+	}
+}
+
+func (c *converter) list(node *html.Node) {
+	ordered := node.Data == "ol"
+	number := 1
+	if start, err := strconv.Atoi(Attr(node, "start")); err == nil && ordered {
+		number = start
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			c.walk(child)
+			continue
+		}
+		marker := "-"
+		if ordered {
+			marker = strconv.Itoa(number) + "."
+			number++
+		} else if !c.markdown {
+			marker = "•"
+		}
+		c.item(child, marker)
+	}
+}
+
+func (c *converter) item(node *html.Node, marker string) {
+	c.line()
+	prefix := c.prefix
+	c.marker = marker + " "
+	// the continuation lines are aligned with the text of the item
+	c.prefix += strings.Repeat(" ", utf8.RuneCountInString(c.marker))
+	c.children(node)
+	c.marker = ""
+	c.prefix = prefix
+	c.line()
+}
+
+func (c *converter) link(node *html.Node) {
+	href := Attr(node, "href")
+	if c.markdown {
+		if href == "" || strings.HasPrefix(href, "#") {
+			c.children(node)
+			return
+		}
+		c.open("[")
+		c.children(node)
+		c.close("[", "]("+href+")")
+		return
+	}
+	c.children(node)
+	text := strings.Join(strings.Fields(rawText(node)), " ")
+	if IsAPossibleLink(href) && text != href {
+		c.space = true
+		c.write("(" + href + ")")
+	}
+}
+
+func (c *converter) image(src, alt string) {
+	alt = strings.Join(strings.Fields(alt), " ")
+	if c.markdown {
+		if src != "" {
+			c.write("![" + markdownEscaper.Replace(alt) + "](" + src + ")")
+		}
+		return
+	}
+	if alt != "" {
+		c.write("[" + alt + "]")
+	}
+}
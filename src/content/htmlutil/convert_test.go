@@ -0,0 +1,51 @@
+package htmlutil
+
+import "testing"
+
+const convertContent = `<h2>Title</h2>
+<p>Some <b>bold</b> and <em>emphasized</em>
+	text with <a href="http://example.com/a_b">a link</a>.<br>Next line, <code>x = y*2</code>.</p>
+<script>alert(1)</script>
+<ul><li>one</li><li>two<ol start="3"><li>three</li></ol></li></ul>
+<blockquote><p>quoted</p><p>twice</p></blockquote>
+<pre>func main() {
+	println()
+}</pre>
+<p><img src="http://example.com/x.png" alt="image"> <a href="http://example.com">http://example.com</a></p>`
+
+func TestPlainText(t *testing.T) {
+	want := "Title\n\n" +
+		"Some bold and emphasized text with a link (http://example.com/a_b).\nNext line, x = y*2.\n\n" +
+		"• one\n• two\n  3. three\n\n" +
+		"> quoted\n>\n> twice\n\n" +
+		"func main() {\n\tprintln()\n}\n\n" +
+		"[image] http://example.com"
+	if have := PlainText(convertContent); have != want {
+		t.Fatalf("want:\n%s\n\nhave:\n%s", want, have)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	want := "## Title\n\n" +
+		"Some **bold** and _emphasized_ text with [a link](http://example.com/a_b).  \nNext line, `x = y*2`.\n\n" +
+		"- one\n- two\n  3. three\n\n" +
+		"> quoted\n>\n> twice\n\n" +
+		"```\nfunc main() {\n\tprintln()\n}\n```\n\n" +
+		"![image](http://example.com/x.png) [http://example.com](http://example.com)"
+	if have := Markdown(convertContent); have != want {
+		t.Fatalf("want:\n%s\n\nhave:\n%s", want, have)
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	testcases := [][2]string{
+		{`<p>snake_case *not bold* [x]</p>`, `snake\_case \*not bold\* \[x\]`},
+		{`<p># not a heading</p><p>- not a list</p>`, "\\# not a heading\n\n\\- not a list"},
+		{`<p><b> </b>empty <a href="http://example.com"></a>markup</p>`, "empty markup"},
+	}
+	for _, testcase := range testcases {
+		if have := Markdown(testcase[0]); have != testcase[1] {
+			t.Errorf("%s: want %q, have %q", testcase[0], testcase[1], have)
+		}
+	}
+}
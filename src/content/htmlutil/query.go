@@ -27,6 +27,19 @@ func FindNodes(node *html.Node, match func(*html.Node) bool) []*html.Node {
 	return nodes
 }
 
+// First returns the first node matched in document order, nil if there's none.
+func First(node *html.Node, match func(*html.Node) bool) *html.Node {
+	if match(node) {
+		return node
+	}
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if found := First(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
 func Query(node *html.Node, sel string) []*html.Node {
 	matcher := NewMatcher(sel)
 	return FindNodes(node, matcher.Match)
@@ -0,0 +1,250 @@
+package htmlutil
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a list of CSS selectors, matching the elements any of them
+// matches. The subset supported is what's needed to point at a value on
+// a page: type (or *), #id, .class, [attr] and [attr=value] selectors,
+// combined with descendant and child (>) combinators.
+type Selector []complexSelector
+
+type complexSelector struct {
+	compounds []compound
+	// whether compounds[i] has to be a child of compounds[i-1],
+	// not just a descendant
+	child []bool
+}
+
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatch
+}
+
+type attrMatch struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// ParseSelector parses the CSS selector, see Selector.
+func ParseSelector(sel string) (Selector, error) {
+	p := selectorParser{s: sel}
+	var result Selector
+	for {
+		c, err := p.complex()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+		if p.pos == len(p.s) {
+			return result, nil
+		}
+		// complex() stops at the end or at a comma
+		p.pos++
+	}
+}
+
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("selector %q, position %d: %s", p.s, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) complex() (complexSelector, error) {
+	var c complexSelector
+	child := false
+	p.skipSpace()
+	for {
+		comp, err := p.compound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, comp)
+		c.child = append(c.child, child)
+		spaced := p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] == ',' {
+			return c, nil
+		}
+		child = p.s[p.pos] == '>'
+		if child {
+			p.pos++
+			p.skipSpace()
+		} else if !spaced {
+			return c, p.errorf("unexpected %q", p.s[p.pos])
+		}
+	}
+}
+
+func (p *selectorParser) compound() (compound, error) {
+	var c compound
+	start := p.pos
+	if p.pos < len(p.s) && p.s[p.pos] == '*' {
+		p.pos++
+	} else {
+		c.tag = strings.ToLower(p.ident())
+	}
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '#':
+			p.pos++
+			if c.id = p.ident(); c.id == "" {
+				return c, p.errorf("expected an id")
+			}
+		case '.':
+			p.pos++
+			class := p.ident()
+			if class == "" {
+				return c, p.errorf("expected a class")
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			p.pos++
+			attr, err := p.attr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, attr)
+		default:
+			if p.pos == start {
+				return c, p.errorf("unexpected %q", p.s[p.pos])
+			}
+			return c, nil
+		}
+	}
+	if p.pos == start {
+		return c, p.errorf("expected a selector")
+	}
+	return c, nil
+}
+
+func (p *selectorParser) attr() (attrMatch, error) {
+	var a attrMatch
+	p.skipSpace()
+	if a.name = strings.ToLower(p.ident()); a.name == "" {
+		return a, p.errorf("expected an attribute name")
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '=' {
+		p.pos++
+		p.skipSpace()
+		a.hasValue = true
+		if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+			quote := p.s[p.pos]
+			end := strings.IndexByte(p.s[p.pos+1:], quote)
+			if end < 0 {
+				return a, p.errorf("unterminated string")
+			}
+			a.value = p.s[p.pos+1 : p.pos+1+end]
+			p.pos += end + 2
+		} else {
+			a.value = p.ident()
+		}
+		p.skipSpace()
+	}
+	if p.pos == len(p.s) || p.s[p.pos] != ']' {
+		return a, p.errorf("expected ]")
+	}
+	p.pos++
+	return a, nil
+}
+
+func (p *selectorParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c >= 0x80 {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	return p.s[start:p.pos]
+}
+
+// Match tells whether the node is an element matched by the selector.
+func (s Selector) Match(n *html.Node) bool {
+	for _, c := range s {
+		if c.match(n, len(c.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c complexSelector) match(n *html.Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if c.match(parent, i-1) {
+			return true
+		}
+		if c.child[i] {
+			return false
+		}
+	}
+	return false
+}
+
+func (c compound) match(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && n.Data != c.tag) {
+		return false
+	}
+	if c.id != "" && Attr(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) > 0 {
+		classes := strings.Fields(Attr(n, "class"))
+		for _, class := range c.classes {
+			if !contains(classes, class) {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		value, ok := lookupAttr(n, a.name)
+		if !ok || (a.hasValue && value != a.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupAttr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package htmlutil
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSelector(t *testing.T) {
+	node, _ := html.Parse(strings.NewReader(`
+		<div id="main" class="post card">
+			<p class="lead">lead</p>
+			<section><p data-x="1">nested</p></section>
+		</div>
+		<p class="lead">outside</p>
+	`))
+	cases := map[string]string{
+		"p":                    "lead",
+		"#main > p":            "lead",
+		"body > .lead":         "outside",
+		".card.post section p": "nested",
+		"div p[data-x]":        "nested",
+		`p[data-x="1"]`:        "nested",
+		"h1, section > *":      "nested",
+	}
+	for sel, want := range cases {
+		s, err := ParseSelector(sel)
+		if err != nil {
+			t.Errorf("%q: %s", sel, err)
+			continue
+		}
+		if found := First(node, s.Match); found == nil || Text(found) != want {
+			t.Errorf("%q: want %q, have %v", sel, want, found)
+		}
+	}
+	s, _ := ParseSelector("#main > section > span, div.lead")
+	if found := First(node, s.Match); found != nil {
+		t.Errorf("unexpected match: %v", found)
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, sel := range []string{"", "p.", "#", "p[", "p[a=b", `p[a="b]`, "p >", "p:first-child", "a,"} {
+		if _, err := ParseSelector(sel); err == nil {
+			t.Errorf("%q: want an error", sel)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package rewrite applies the rules of a feed to the contents of its
+// items: extracting the article from the linked page (to make a full
+// content feed of a truncated one), stripping elements and rewriting
+// the content with regular expressions.
+package rewrite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+type Kind string
+
+const (
+	// the content is replaced with the elements of the linked page
+	// matching the selector
+	Extract Kind = "extract"
+	// the elements matching the selector are removed from the content
+	Strip Kind = "strip"
+	// the matches of the pattern are replaced, $1 standing for the
+	// first group (see regexp.Regexp.Expand)
+	Replace Kind = "replace"
+)
+
+type Rule struct {
+	Kind        Kind
+	Selector    string
+	Pattern     string
+	Replacement string
+}
+
+// ErrNoMatch is returned when an extract rule doesn't match the page.
+var ErrNoMatch = errors.New("the selector matches nothing on the page")
+
+// Validate checks the rule, returning a description of the first problem.
+func (r Rule) Validate() error {
+	switch r.Kind {
+	case Extract, Strip:
+		if strings.TrimSpace(r.Selector) == "" {
+			return errors.New("the selector is required")
+		}
+		_, err := htmlutil.ParseSelector(r.Selector)
+		return err
+	case Replace:
+		if r.Pattern == "" {
+			return errors.New("the pattern is required")
+		}
+		_, err := regexp.Compile(r.Pattern)
+		return err
+	}
+	return fmt.Errorf("unknown kind %q", r.Kind)
+}
+
+// Apply rewrites the content with the strip and replace rules, in order.
+// The invalid rules, and the extract ones, are skipped.
+func Apply(content string, rules []Rule) string {
+	for _, r := range rules {
+		switch r.Kind {
+		case Strip:
+			sel, err := htmlutil.ParseSelector(r.Selector)
+			if err != nil {
+				continue
+			}
+			content = strip(content, sel)
+		case Replace:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				continue
+			}
+			content = re.ReplaceAllString(content, r.Replacement)
+		}
+	}
+	return content
+}
+
+func strip(content string, sel htmlutil.Selector) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return content
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	removed := false
+	for _, n := range matches(body, sel) {
+		n.Parent.RemoveChild(n)
+		removed = true
+	}
+	if !removed {
+		return content
+	}
+	return htmlutil.InnerHTML(body)
+}
+
+// matches returns the outermost nodes matched, in document order.
+func matches(n *html.Node, sel htmlutil.Selector) []*html.Node {
+	result := make([]*html.Node, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if sel.Match(c) {
+			result = append(result, c)
+		} else {
+			result = append(result, matches(c, sel)...)
+		}
+	}
+	return result
+}
+
+// Extract returns the elements of the page, in the charset (utf-8 if
+// empty), matching the selector of the extract rule.
+func (r Rule) Extract(page io.Reader, cs string) (string, error) {
+	sel, err := htmlutil.ParseSelector(r.Selector)
+	if err != nil {
+		return "", err
+	}
+	if cs != "" {
+		if decoded, err := charset.NewReaderLabel(cs, page); err == nil {
+			page = decoded
+		}
+	}
+	doc, err := html.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	nodes := matches(doc, sel)
+	if len(nodes) == 0 {
+		return "", ErrNoMatch
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(htmlutil.HTML(n))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,60 @@
+package rewrite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	content := `<p>intro</p><div class="ads"><p>buy</p></div><p>Read more at <a href="/x">Example</a></p>`
+	have := Apply(content, []Rule{
+		{Kind: Strip, Selector: "div.ads"},
+		{Kind: Replace, Pattern: `<p>Read more at (<a [^>]*>).*?</a></p>`, Replacement: `<p>${1}source</a></p>`},
+		// skipped
+		{Kind: Extract, Selector: "p"},
+		{Kind: Strip, Selector: "p["},
+	})
+	want := `<p>intro</p><p><a href="/x">source</a></p>`
+	if have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+
+	// untouched if there's nothing to strip
+	content = `<p>unclosed <b>tag`
+	if have := Apply(content, []Rule{{Kind: Strip, Selector: "div"}}); have != content {
+		t.Errorf("want %q, have %q", content, have)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	page := `<html><body>
+		<header>menu</header>
+		<div class="article-body"><p>first</p><div class="article-body">nested</div></div>
+		<div class="article-body"><p>second</p></div>
+	</body></html>`
+	have, err := Rule{Kind: Extract, Selector: ".article-body"}.Extract(strings.NewReader(page), "")
+	want := `<div class="article-body"><p>first</p><div class="article-body">nested</div></div><div class="article-body"><p>second</p></div>`
+	if err != nil || have != want {
+		t.Errorf("want %q, have %q (%v)", want, have, err)
+	}
+	if _, err := (Rule{Kind: Extract, Selector: "article"}).Extract(strings.NewReader(page), ""); err != ErrNoMatch {
+		t.Errorf("want ErrNoMatch, have %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{Kind: "unknown"},
+		{Kind: Extract},
+		{Kind: Strip, Selector: "div["},
+		{Kind: Replace},
+		{Kind: Replace, Pattern: "("},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%#v: want an error", r)
+		}
+	}
+	if err := (Rule{Kind: Replace, Pattern: "a", Replacement: ""}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,8 +19,48 @@ import (
 
 var splitSrcsetRegex = regexp.MustCompile(`,\s+`)
 
+// Options tweak the sanitization for a particular source.
+type Options struct {
+	// IframeHosts are the hosts trusted to be embedded via iframes,
+	// in addition to the built-in allow-list.
+	IframeHosts []string
+
+	// BlockedHosts are the hosts (along with their subdomains) whose
+	// images, embeds and links are stripped, e.g. trackers or paywall scripts.
+	BlockedHosts []string
+	// AllowedHosts are exempt from BlockedHosts.
+	AllowedHosts []string
+}
+
+// blocks reports whether the url points at one of the blocked hosts.
+func (opts Options) blocks(src string) bool {
+	if len(opts.BlockedHosts) == 0 {
+		return false
+	}
+	domain := urlHostname(src)
+	if domain == "" {
+		return false
+	}
+	for _, host := range opts.AllowedHosts {
+		if hostMatches(domain, host) {
+			return false
+		}
+	}
+	for _, host := range opts.BlockedHosts {
+		if hostMatches(domain, host) {
+			return true
+		}
+	}
+	return false
+}
+
 // Sanitize returns safe HTML.
 func Sanitize(baseURL, input string) string {
+	return SanitizeWithOptions(baseURL, input, Options{})
+}
+
+// SanitizeWithOptions returns safe HTML, see Options.
+func SanitizeWithOptions(baseURL, input string, opts Options) string {
 	var buffer bytes.Buffer
 	var tagStack []string
 	var parentTag string
@@ -55,7 +96,7 @@ func Sanitize(baseURL, input string) string {
 			parentTag = tagName
 
 			if isValidTag(tagName) {
-				attrNames, htmlAttributes := sanitizeAttributes(baseURL, tagName, token.Attr)
+				attrNames, htmlAttributes := sanitizeAttributes(baseURL, tagName, token.Attr, opts)
 
 				if hasRequiredAttributes(tagName, attrNames) {
 					wrap := isVideoIframe(token)
@@ -96,7 +137,7 @@ func Sanitize(baseURL, input string) string {
 		case html.SelfClosingTagToken:
 			tagName := token.Data
 			if isValidTag(tagName) {
-				attrNames, htmlAttributes := sanitizeAttributes(baseURL, tagName, token.Attr)
+				attrNames, htmlAttributes := sanitizeAttributes(baseURL, tagName, token.Attr, opts)
 
 				if hasRequiredAttributes(tagName, attrNames) {
 					if len(attrNames) > 0 {
@@ -110,7 +151,7 @@ func Sanitize(baseURL, input string) string {
 	}
 }
 
-func sanitizeAttributes(baseURL, tagName string, attributes []html.Attribute) ([]string, string) {
+func sanitizeAttributes(baseURL, tagName string, attributes []html.Attribute, opts Options) ([]string, string) {
 	var htmlAttrs, attrNames []string
 
 	for _, attribute := range attributes {
@@ -120,18 +161,26 @@ func sanitizeAttributes(baseURL, tagName string, attributes []html.Attribute) ([
 			continue
 		}
 
+		// svg animations may rewrite links (<animate attributeName="href" ...>)
+		if attribute.Key == "attributename" && strings.Contains(strings.ToLower(value), "href") {
+			continue
+		}
+
 		if (tagName == "img" || tagName == "source") && attribute.Key == "srcset" {
-			value = sanitizeSrcsetAttr(baseURL, value)
+			value = sanitizeSrcsetAttr(baseURL, value, opts)
+			if value == "" {
+				continue
+			}
 		}
 
 		if isExternalResourceAttribute(attribute.Key) {
 			if tagName == "iframe" {
-				if isValidIframeSource(baseURL, attribute.Val) {
+				if isValidIframeSource(baseURL, attribute.Val, opts.IframeHosts) && !opts.blocks(attribute.Val) {
 					value = attribute.Val
 				} else {
 					continue
 				}
-			} else if tagName == "img" && attribute.Key == "src" && isValidDataAttribute(attribute.Val) {
+			} else if isDataAttributeAllowed(tagName, attribute.Key) && isValidDataAttribute(attribute.Val) {
 				value = attribute.Val
 			} else {
 				value = htmlutil.AbsoluteUrl(value, baseURL)
@@ -139,7 +188,7 @@ func sanitizeAttributes(baseURL, tagName string, attributes []html.Attribute) ([
 					continue
 				}
 
-				if !hasValidURIScheme(value) || isBlockedResource(value) {
+				if !hasValidURIScheme(value) || isBlockedResource(value) || opts.blocks(value) {
 					continue
 				}
 			}
@@ -189,6 +238,20 @@ func isValidAttribute(tagName, attributeName string) bool {
 	return false
 }
 
+// isDataAttributeAllowed reports whether the attribute may hold an (image) data uri.
+func isDataAttributeAllowed(tagName, attribute string) bool {
+	switch {
+	case tagName == "img" && attribute == "src":
+		return true
+	case tagName == "video" && attribute == "poster":
+		return true
+	case tagName == "image" && attribute == "href":
+		// svg <image>
+		return true
+	}
+	return false
+}
+
 func isExternalResourceAttribute(attribute string) bool {
 	switch attribute {
 	case "src", "href", "poster", "cite":
@@ -247,7 +310,7 @@ func isBlockedResource(src string) bool {
 	return false
 }
 
-func isValidIframeSource(baseURL, src string) bool {
+func isValidIframeSource(baseURL, src string, extraHosts []string) bool {
 	whitelist := []string{
 		"bandcamp.com",
 		"cdn.embedly.com",
@@ -274,9 +337,33 @@ func isValidIframeSource(baseURL, src string) bool {
 		}
 	}
 
+	// only https embeds from the user-provided hosts
+	if !strings.HasPrefix(src, "https://") {
+		return false
+	}
+	for _, host := range extraHosts {
+		if hostMatches(domain, host) {
+			return true
+		}
+	}
+
 	return false
 }
 
+// hostMatches reports whether the domain is the host or its subdomain.
+func hostMatches(domain, host string) bool {
+	host = strings.ToLower(strings.TrimSpace(host))
+	return host != "" && (domain == host || strings.HasSuffix(domain, "."+host))
+}
+
+func urlHostname(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
 func getTagAllowList() map[string][]string {
 	whitelist := make(map[string][]string)
 	whitelist["img"] = []string{"alt", "title", "src", "srcset", "sizes"}
@@ -372,7 +459,7 @@ Each string is composed of:
 - A width descriptor (a positive integer directly followed by w). The width descriptor is divided by the source size given in the sizes attribute to calculate the effective pixel density.
 - A pixel density descriptor (a positive floating point number directly followed by x).
 */
-func sanitizeSrcsetAttr(baseURL, value string) string {
+func sanitizeSrcsetAttr(baseURL, value string, opts Options) string {
 	var sanitizedSources []string
 	rawSources := splitSrcsetRegex.Split(value, -1)
 	for _, rawSource := range rawSources {
@@ -381,9 +468,13 @@ func sanitizeSrcsetAttr(baseURL, value string) string {
 
 		if nbParts > 0 {
 			sanitizedSource := parts[0]
-			if !strings.HasPrefix(parts[0], "data:") {
+			if strings.HasPrefix(parts[0], "data:") {
+				if !isValidDataAttribute(parts[0]) {
+					continue
+				}
+			} else {
 				sanitizedSource = htmlutil.AbsoluteUrl(parts[0], baseURL)
-				if sanitizedSource == "" {
+				if sanitizedSource == "" || opts.blocks(sanitizedSource) {
 					continue
 				}
 			}
@@ -414,19 +505,26 @@ func isValidWidthOrDensityDescriptor(value string) bool {
 
 func isValidDataAttribute(value string) bool {
 	var dataAttributeAllowList = []string{
-		"data:image/avif",
-		"data:image/apng",
-		"data:image/png",
-		"data:image/svg",
-		"data:image/svg+xml",
-		"data:image/jpg",
-		"data:image/jpeg",
-		"data:image/gif",
-		"data:image/webp",
-	}
-
-	for _, prefix := range dataAttributeAllowList {
-		if strings.HasPrefix(value, prefix) {
+		"image/avif",
+		"image/apng",
+		"image/png",
+		"image/svg",
+		"image/svg+xml",
+		"image/jpg",
+		"image/jpeg",
+		"image/gif",
+		"image/webp",
+	}
+
+	// data:[<mediatype>][;base64],<data>
+	if !strings.HasPrefix(value, "data:") {
+		return false
+	}
+	header := strings.SplitN(value[len("data:"):], ",", 2)[0]
+	mediatype := strings.ToLower(strings.TrimSpace(strings.SplitN(header, ";", 2)[0]))
+
+	for _, allowed := range dataAttributeAllowList {
+		if mediatype == allowed {
 			return true
 		}
 	}
@@ -312,3 +312,70 @@ func TestWrapYoutubeIFrames(t *testing.T) {
 		t.Errorf("Wrong output:\nwant: %v\nhave: %v", expected, output)
 	}
 }
+
+func TestImgWithSrcsetAndInvalidDataURL(t *testing.T) {
+	input := `<img srcset="data:text/html;base64,test 1x, example-640w.jpg 2x" src="http://example.org/example-320w.jpg">`
+	expected := `<img srcset="http://example.org/example-640w.jpg 2x" src="http://example.org/example-320w.jpg" loading="lazy">`
+	output := Sanitize("http://example.org/", input)
+
+	if output != expected {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+}
+
+func TestImgWithMalformedDataURL(t *testing.T) {
+	input := `<img src="data:image/svg+xml-foo,test" alt="Example">`
+	expected := ``
+	output := Sanitize("http://example.org/", input)
+
+	if output != expected {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+}
+
+func TestInlineSVG(t *testing.T) {
+	input := `<svg viewBox="0 0 10 10" onload="alert(1)"><a href="javascript:alert(1)"><circle r="5"/></a><image href="data:image/png;base64,test"/><animate attributeName="href" values="javascript:alert(1)"/></svg>`
+	expected := `<svg viewbox="0 0 10 10"><circle r="5"/><image href="data:image/png;base64,test"/></svg>`
+	output := Sanitize("http://example.org/", input)
+
+	if output != expected {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+}
+
+func TestIframeWithCustomHost(t *testing.T) {
+	input := `<iframe src="https://embed.example.com/player/1"></iframe>`
+	expected := `<iframe src="https://embed.example.com/player/1" sandbox="allow-scripts allow-same-origin allow-popups" loading="lazy"></iframe>`
+	output := SanitizeWithOptions("http://example.org/", input, Options{IframeHosts: []string{"example.com"}})
+	if output != expected {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+
+	output = Sanitize("http://example.org/", input)
+	if output != "" {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+
+	input = `<iframe src="http://embed.example.com/player/1"></iframe>`
+	output = SanitizeWithOptions("http://example.org/", input, Options{IframeHosts: []string{"example.com"}})
+	if output != "" {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+}
+
+func TestBlockedHosts(t *testing.T) {
+	opts := Options{
+		BlockedHosts: []string{"tracker.com", "paywall.net"},
+		AllowedHosts: []string{"img.paywall.net"},
+		IframeHosts:  []string{"paywall.net"},
+	}
+	input := `<p><img src="https://pixel.tracker.com/1.gif"><img src="https://img.paywall.net/a.png"> ` +
+		`<a href="https://tracker.com/click">link</a> <iframe src="https://embed.paywall.net/x"></iframe>` +
+		`<img src="https://example.org/b.png" srcset="https://tracker.com/c.png 2x, https://example.org/c.png 3x"></p>`
+	expected := `<p><img src="https://img.paywall.net/a.png" loading="lazy"> link ` +
+		`<img src="https://example.org/b.png" srcset="https://example.org/c.png 3x" loading="lazy"></p>`
+	output := SanitizeWithOptions("http://example.org/", input, opts)
+	if output != expected {
+		t.Errorf(`Wrong output: %s`, output)
+	}
+}
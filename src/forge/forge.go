@@ -0,0 +1,282 @@
+// Package forge makes feeds of the releases and tags of the repositories
+// hosted on GitHub, GitLab and Gitea (Forgejo, Codeberg) out of their APIs,
+// since the feeds these sites publish often leave out the release notes.
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	GitHub Kind = "github"
+	GitLab Kind = "gitlab"
+	Gitea  Kind = "gitea"
+)
+
+// the hosts recognized by the links to their repositories; the
+// self-hosted instances are subscribed to by the links to their APIs
+var knownHosts = map[string]Kind{
+	"github.com":   GitHub,
+	"gitlab.com":   GitLab,
+	"codeberg.org": Gitea,
+	"gitea.com":    Gitea,
+}
+
+// Source is the list of releases (or tags) of a repository.
+type Source struct {
+	Kind Kind
+	// scheme and host of the web site, e.g. "https://github.com"
+	Site string
+	// e.g. "owner/repo", or "group/subgroup/repo" on GitLab
+	Repo string
+	Tags bool
+}
+
+// Host is the host of the web site, which the tokens are set up for.
+func (s Source) Host() string {
+	return strings.TrimPrefix(strings.TrimPrefix(s.Site, "https://"), "http://")
+}
+
+// APIURL is the link of the source's API, which is stored as the feed link.
+func (s Source) APIURL() string {
+	switch s.Kind {
+	case GitHub:
+		api := "https://api.github.com"
+		if s.Host() != "github.com" {
+			// GitHub Enterprise
+			api = s.Site + "/api/v3"
+		}
+		if s.Tags {
+			return api + "/repos/" + s.Repo + "/tags"
+		}
+		return api + "/repos/" + s.Repo + "/releases"
+	case GitLab:
+		project := s.Site + "/api/v4/projects/" + url.PathEscape(s.Repo)
+		if s.Tags {
+			return project + "/repository/tags"
+		}
+		return project + "/releases?include_html_description=true"
+	default:
+		if s.Tags {
+			return s.Site + "/api/v1/repos/" + s.Repo + "/tags"
+		}
+		return s.Site + "/api/v1/repos/" + s.Repo + "/releases"
+	}
+}
+
+// WebURL is the page listing the releases (or tags).
+func (s Source) WebURL() string {
+	repo := s.Site + "/" + s.Repo
+	switch {
+	case s.Kind == GitLab && s.Tags:
+		return repo + "/-/tags"
+	case s.Kind == GitLab:
+		return repo + "/-/releases"
+	case s.Tags:
+		return repo + "/tags"
+	}
+	return repo + "/releases"
+}
+
+// tagURL is the page of a tag (or of its release).
+func (s Source) tagURL(tag string) string {
+	repo := s.Site + "/" + s.Repo
+	if s.Kind == GitLab {
+		return repo + "/-/tags/" + url.PathEscape(tag)
+	}
+	return repo + "/releases/tag/" + url.PathEscape(tag)
+}
+
+func (s Source) commitURL(sha string) string {
+	if s.Kind == GitLab {
+		return s.Site + "/" + s.Repo + "/-/commit/" + sha
+	}
+	return s.Site + "/" + s.Repo + "/commit/" + sha
+}
+
+func (s Source) Title() string {
+	if s.Tags {
+		return s.Repo + " tags"
+	}
+	return s.Repo + " releases"
+}
+
+// FromURL recognizes the link to a repository (or its releases or tags)
+// on one of the well-known hosts, or the link to a source's API.
+// Returns nil if it's neither.
+func FromURL(link string) *Source {
+	if source := FromAPIURL(link); source != nil {
+		return source
+	}
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	kind, ok := knownHosts[host]
+	if !ok {
+		return nil
+	}
+	source := &Source{Kind: kind, Site: "https://" + host}
+	path := strings.Trim(u.Path, "/")
+	switch {
+	case kind == GitLab && strings.HasSuffix(path, "/-/releases"):
+		path = strings.TrimSuffix(path, "/-/releases")
+	case kind == GitLab && strings.HasSuffix(path, "/-/tags"):
+		path, source.Tags = strings.TrimSuffix(path, "/-/tags"), true
+	case kind != GitLab && strings.HasSuffix(path, "/releases"):
+		path = strings.TrimSuffix(path, "/releases")
+	case kind != GitLab && strings.HasSuffix(path, "/tags"):
+		path, source.Tags = strings.TrimSuffix(path, "/tags"), true
+	}
+	parts := strings.Split(path, "/")
+	// the other pages of the repository (e.g. the commits feed)
+	// aren't handled, nor are the users' pages
+	if len(parts) < 2 || (kind != GitLab && len(parts) != 2) || strings.Contains(path, "/-/") {
+		return nil
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil
+		}
+	}
+	source.Repo = path
+	return source
+}
+
+// FromAPIURL recognizes the link made by Source.APIURL.
+func FromAPIURL(link string) *Source {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil
+	}
+	site := u.Scheme + "://" + u.Host
+	path := u.EscapedPath()
+
+	var source Source
+	switch {
+	case u.Host == "api.github.com" && strings.HasPrefix(path, "/repos/"):
+		source = Source{Kind: GitHub, Site: "https://github.com"}
+		path = strings.TrimPrefix(path, "/repos/")
+	case strings.HasPrefix(path, "/api/v3/repos/"):
+		source = Source{Kind: GitHub, Site: site}
+		path = strings.TrimPrefix(path, "/api/v3/repos/")
+	case strings.HasPrefix(path, "/api/v1/repos/"):
+		source = Source{Kind: Gitea, Site: site}
+		path = strings.TrimPrefix(path, "/api/v1/repos/")
+	case strings.HasPrefix(path, "/api/v4/projects/"):
+		source = Source{Kind: GitLab, Site: site}
+		path = strings.TrimPrefix(path, "/api/v4/projects/")
+		switch {
+		case strings.HasSuffix(path, "/releases"):
+			path = strings.TrimSuffix(path, "/releases")
+		case strings.HasSuffix(path, "/repository/tags"):
+			path, source.Tags = strings.TrimSuffix(path, "/repository/tags"), true
+		default:
+			return nil
+		}
+		repo, err := url.PathUnescape(path)
+		// the projects are told by their path rather than their id,
+		// which the links to the web site are made of
+		if err != nil || !strings.Contains(repo, "/") || strings.Contains(path, "/") {
+			return nil
+		}
+		source.Repo = repo
+		return &source
+	default:
+		return nil
+	}
+	switch {
+	case strings.HasSuffix(path, "/releases"):
+		path = strings.TrimSuffix(path, "/releases")
+	case strings.HasSuffix(path, "/tags"):
+		path, source.Tags = strings.TrimSuffix(path, "/tags"), true
+	default:
+		return nil
+	}
+	if parts := strings.Split(path, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil
+	}
+	source.Repo = path
+	return &source
+}
+
+// Header holds the headers of the requests to the source's API:
+// the token, if any, and the format of the release notes.
+func (s Source) Header(token string) http.Header {
+	header := make(http.Header)
+	switch s.Kind {
+	case GitHub:
+		// the release notes rendered as html along with the markdown
+		header.Set("Accept", "application/vnd.github.full+json")
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	case GitLab:
+		if token != "" {
+			header.Set("PRIVATE-TOKEN", token)
+		}
+	default:
+		header.Set("Accept", "application/json")
+		if token != "" {
+			header.Set("Authorization", "token "+token)
+		}
+	}
+	return header
+}
+
+// RateLimit tells whether the API is out of requests (whether or not
+// it has just refused one) and until when. The APIs report the number
+// of requests left and when it's reset, or how long to wait with
+// Retry-After; a refusal without either of these is waited out for
+// DefaultRetryAfter.
+func RateLimit(res *http.Response, now time.Time) (time.Time, bool) {
+	if after := res.Header.Get("Retry-After"); after != "" {
+		if seconds, err := strconv.Atoi(after); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(after); err == nil {
+			return date, true
+		}
+	}
+	remaining := header(res, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if remaining == "0" {
+		if reset, err := strconv.ParseInt(header(res, "X-RateLimit-Reset", "RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0), true
+		}
+		return now.Add(DefaultRetryAfter), true
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return now.Add(DefaultRetryAfter), true
+	}
+	return time.Time{}, false
+}
+
+var DefaultRetryAfter = 5 * time.Minute
+
+func header(res *http.Response, names ...string) string {
+	for _, name := range names {
+		if value := res.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// RateLimitError is returned for the requests which weren't made
+// (or were refused) because the API is out of requests.
+type RateLimitError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s until %s", e.Host, e.Until.Local().Format("15:04"))
+}
@@ -0,0 +1,139 @@
+package forge
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromURL(t *testing.T) {
+	cases := []struct {
+		link string
+		want *Source
+		api  string
+	}{
+		{
+			"https://github.com/nkanaev/yarr",
+			&Source{Kind: GitHub, Site: "https://github.com", Repo: "nkanaev/yarr"},
+			"https://api.github.com/repos/nkanaev/yarr/releases",
+		},
+		{
+			"https://www.github.com/nkanaev/yarr/tags/",
+			&Source{Kind: GitHub, Site: "https://github.com", Repo: "nkanaev/yarr", Tags: true},
+			"https://api.github.com/repos/nkanaev/yarr/tags",
+		},
+		{
+			"https://gitlab.com/group/sub/project/-/releases",
+			&Source{Kind: GitLab, Site: "https://gitlab.com", Repo: "group/sub/project"},
+			"https://gitlab.com/api/v4/projects/group%2Fsub%2Fproject/releases?include_html_description=true",
+		},
+		{
+			"https://codeberg.org/forgejo/forgejo/releases",
+			&Source{Kind: Gitea, Site: "https://codeberg.org", Repo: "forgejo/forgejo"},
+			"https://codeberg.org/api/v1/repos/forgejo/forgejo/releases",
+		},
+		{
+			"https://git.example.com/api/v1/repos/owner/repo/tags",
+			&Source{Kind: Gitea, Site: "https://git.example.com", Repo: "owner/repo", Tags: true},
+			"https://git.example.com/api/v1/repos/owner/repo/tags",
+		},
+		{
+			"https://gitlab.example.com/api/v4/projects/group%2Fproject/repository/tags",
+			&Source{Kind: GitLab, Site: "https://gitlab.example.com", Repo: "group/project", Tags: true},
+			"https://gitlab.example.com/api/v4/projects/group%2Fproject/repository/tags",
+		},
+		{"https://github.com/nkanaev/yarr/releases.atom", nil, ""},
+		{"https://github.com/nkanaev/yarr/commits/master", nil, ""},
+		{"https://github.com/nkanaev", nil, ""},
+		{"https://gitlab.example.com/api/v4/projects/42/releases", nil, ""},
+		{"https://example.com/owner/repo", nil, ""},
+	}
+	for _, tc := range cases {
+		have := FromURL(tc.link)
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s: want %#v, have %#v", tc.link, tc.want, have)
+			continue
+		}
+		if have != nil {
+			if api := have.APIURL(); api != tc.api {
+				t.Errorf("%s: want %s, have %s", tc.link, tc.api, api)
+			}
+			if again := FromAPIURL(have.APIURL()); !reflect.DeepEqual(again, have) {
+				t.Errorf("%s: the api link isn't recognized: %#v", tc.link, again)
+			}
+		}
+	}
+}
+
+func TestParseReleases(t *testing.T) {
+	source := Source{Kind: GitHub, Site: "https://github.com", Repo: "owner/repo"}
+	body := `[
+		{"tag_name": "v2.0", "name": "", "draft": true, "body": "soon"},
+		{"tag_name": "v1.1", "name": "Second", "prerelease": true, "html_url": "https://github.com/owner/repo/releases/tag/v1.1",
+		 "published_at": "2024-02-01T10:00:00Z", "author": {"login": "octocat"},
+		 "body": "* fix", "body_html": "<ul><li>fix</li></ul>"},
+		{"tag_name": "v1.0", "published_at": "2024-01-01T10:00:00Z", "body": "first <release>\n\nthanks"}
+	]`
+	feed, err := source.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "owner/repo releases" || feed.SiteURL != "https://github.com/owner/repo/releases" {
+		t.Errorf("unexpected feed: %#v", feed)
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("want 2 items, have %#v", feed.Items)
+	}
+	item := feed.Items[0]
+	if item.Title != "Second (pre-release)" || item.Author != "octocat" || item.Content != "<ul><li>fix</li></ul>" {
+		t.Errorf("unexpected item: %#v", item)
+	}
+	if item.GUID != "https://github.com/owner/repo/releases/tag/v1.1" || !item.Date.Equal(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected item: %#v", item)
+	}
+	item = feed.Items[1]
+	if want := "<p>first &lt;release&gt;</p><p>thanks</p>"; item.Title != "v1.0" || item.Content != want {
+		t.Errorf("unexpected item: %#v", item)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	source := Source{Kind: GitLab, Site: "https://gitlab.com", Repo: "group/project", Tags: true}
+	body := `[{"name": "v1.0", "message": "", "commit": {"id": "0123456789abcdef", "created_at": "2024-01-01T10:00:00Z"}}]`
+	feed, err := source.Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p>Commit <a href="https://gitlab.com/group/project/-/commit/0123456789abcdef"><code>01234567</code></a></p>`
+	if len(feed.Items) != 1 || feed.Items[0].Content != want || feed.Items[0].URL != "https://gitlab.com/group/project/-/tags/v1.0" {
+		t.Errorf("unexpected items: %#v", feed.Items)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		status  int
+		header  map[string]string
+		limited bool
+		until   time.Time
+	}{
+		{200, map[string]string{"X-RateLimit-Remaining": "10"}, false, time.Time{}},
+		{200, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1704070800"}, true, time.Unix(1704070800, 0)},
+		{403, map[string]string{"Retry-After": "60"}, true, now.Add(time.Minute)},
+		{429, map[string]string{"RateLimit-Remaining": "0"}, true, now.Add(DefaultRetryAfter)},
+		{403, map[string]string{}, false, time.Time{}},
+	}
+	for i, tc := range cases {
+		res := &http.Response{StatusCode: tc.status, Header: make(http.Header)}
+		for key, value := range tc.header {
+			res.Header.Set(key, value)
+		}
+		until, limited := RateLimit(res, now)
+		if limited != tc.limited || !until.Equal(tc.until) {
+			t.Errorf("%d: want %v %v, have %v %v", i, tc.limited, tc.until, limited, until)
+		}
+	}
+}
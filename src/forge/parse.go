@@ -0,0 +1,172 @@
+package forge
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nkanaev/yarr/src/parser"
+)
+
+type user struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+func (u *user) String() string {
+	switch {
+	case u == nil:
+		return ""
+	case u.Login != "":
+		return u.Login
+	case u.Name != "":
+		return u.Name
+	}
+	return u.Username
+}
+
+type release struct {
+	TagName    string     `json:"tag_name"`
+	Name       string     `json:"name"`
+	HTMLURL    string     `json:"html_url"`
+	Draft      bool       `json:"draft"`
+	Prerelease bool       `json:"prerelease"`
+	Upcoming   bool       `json:"upcoming_release"`
+	CreatedAt  *time.Time `json:"created_at"`
+	Published  *time.Time `json:"published_at"`
+	ReleasedAt *time.Time `json:"released_at"`
+	Author     *user      `json:"author"`
+
+	// GitHub and Gitea
+	Body     string `json:"body"`
+	BodyHTML string `json:"body_html"`
+	// GitLab
+	Description     string `json:"description"`
+	DescriptionHTML string `json:"description_html"`
+	Links           struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+type tag struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Commit  struct {
+		// GitHub and Gitea
+		SHA     string     `json:"sha"`
+		Created *time.Time `json:"created"`
+		// GitLab
+		ID        string     `json:"id"`
+		CreatedAt *time.Time `json:"created_at"`
+	} `json:"commit"`
+}
+
+// Parse reads the releases (or tags) returned by the source's API.
+// The drafts and the releases to come are left out.
+func (s Source) Parse(r io.Reader) (*parser.Feed, error) {
+	feed := &parser.Feed{Title: s.Title(), SiteURL: s.WebURL()}
+	if s.Tags {
+		var tags []tag
+		if err := json.NewDecoder(r).Decode(&tags); err != nil {
+			return nil, fmt.Errorf("failed to parse the tags: %w", err)
+		}
+		for _, t := range tags {
+			feed.Items = append(feed.Items, s.tagItem(t))
+		}
+		return feed, nil
+	}
+	var releases []release
+	if err := json.NewDecoder(r).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse the releases: %w", err)
+	}
+	for _, rel := range releases {
+		if rel.Draft || rel.Upcoming {
+			continue
+		}
+		feed.Items = append(feed.Items, s.releaseItem(rel))
+	}
+	return feed, nil
+}
+
+func (s Source) releaseItem(rel release) parser.Item {
+	link := firstOf(rel.HTMLURL, rel.Links.Self)
+	if link == "" {
+		link = s.tagURL(rel.TagName)
+	}
+	title := firstOf(rel.Name, rel.TagName)
+	if rel.Prerelease {
+		title += " (pre-release)"
+	}
+	content := firstOf(rel.BodyHTML, rel.DescriptionHTML)
+	if content == "" {
+		content = textHTML(firstOf(rel.Body, rel.Description))
+	}
+	return parser.Item{
+		// the link changes along with the tag, the tag is what it's about
+		GUID:    s.tagURL(rel.TagName),
+		URL:     link,
+		Title:   title,
+		Author:  rel.Author.String(),
+		Content: content,
+		Date:    firstDate(rel.Published, rel.ReleasedAt, rel.CreatedAt),
+	}
+}
+
+func (s Source) tagItem(t tag) parser.Item {
+	content := textHTML(t.Message)
+	if sha := firstOf(t.Commit.SHA, t.Commit.ID); sha != "" {
+		short := sha
+		if len(short) > 8 {
+			short = short[:8]
+		}
+		content += fmt.Sprintf(`<p>Commit <a href="%s"><code>%s</code></a></p>`, html.EscapeString(s.commitURL(sha)), html.EscapeString(short))
+	}
+	// GitHub doesn't say when the tags were made,
+	// these get the time they're first seen
+	return parser.Item{
+		GUID:    s.tagURL(t.Name),
+		URL:     s.tagURL(t.Name),
+		Title:   t.Name,
+		Content: content,
+		Date:    firstDate(t.Commit.Created, t.Commit.CreatedAt),
+	}
+}
+
+// textHTML marks up the release notes available as text (or Markdown)
+// only: the paragraphs and the line breaks are kept.
+func textHTML(text string) string {
+	var sb strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		sb.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return sb.String()
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func firstDate(dates ...*time.Time) time.Time {
+	for _, date := range dates {
+		if date != nil && !date.IsZero() {
+			return *date
+		}
+	}
+	return time.Time{}
+}
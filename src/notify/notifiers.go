@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func checkURL(link string) error {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", link)
+	}
+	return nil
+}
+
+// webhook posts the notification as JSON.
+type webhook struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func newWebhook(config json.RawMessage) (Notifier, error) {
+	n := &webhook{}
+	if err := decode(config, n); err != nil {
+		return nil, err
+	}
+	if err := checkURL(n.URL); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *webhook) Send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.Headers {
+		req.Header.Set(key, value)
+	}
+	return post(req)
+}
+
+// ntfy publishes to a topic of an ntfy server (https://ntfy.sh).
+type ntfy struct {
+	Server string `json:"server,omitempty"`
+	Topic  string `json:"topic"`
+	Token  string `json:"token,omitempty"`
+}
+
+func newNtfy(config json.RawMessage) (Notifier, error) {
+	n := &ntfy{}
+	if err := decode(config, n); err != nil {
+		return nil, err
+	}
+	if n.Server == "" {
+		n.Server = "https://ntfy.sh"
+	}
+	if err := checkURL(n.Server); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"topic": n.Topic}); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *ntfy) Send(notification Notification) error {
+	link := strings.TrimSuffix(n.Server, "/") + "/" + url.PathEscape(n.Topic)
+	req, err := http.NewRequest("POST", link, strings.NewReader(notification.Text()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", notification.Title)
+	if len(notification.Items) == 1 {
+		req.Header.Set("Click", notification.Items[0].Link)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(req)
+}
+
+// telegram sends a message via a bot to a chat.
+type telegram struct {
+	Token  string `json:"token"`
+	ChatId string `json:"chat_id"`
+}
+
+var telegramAPI = "https://api.telegram.org"
+
+func newTelegram(config json.RawMessage) (Notifier, error) {
+	n := &telegram{}
+	if err := decode(config, n); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"token": n.Token, "chat_id": n.ChatId}); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *telegram) Send(notification Notification) error {
+	form := url.Values{
+		"chat_id":                  {n.ChatId},
+		"text":                     {notification.Title + "\n\n" + notification.Text()},
+		"disable_web_page_preview": {strconv.FormatBool(len(notification.Items) > 1)},
+	}
+	req, err := http.NewRequest("POST", telegramAPI+"/bot"+n.Token+"/sendMessage", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return post(req)
+}
+
+// email sends a plain text message via SMTP.
+type email struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+var sendMail = smtp.SendMail
+
+func newEmail(config json.RawMessage) (Notifier, error) {
+	n := &email{}
+	if err := decode(config, n); err != nil {
+		return nil, err
+	}
+	if n.Port == 0 {
+		n.Port = 587
+	}
+	if err := required(map[string]string{"host": n.Host, "from": n.From, "to": strings.Join(n.To, "")}); err != nil {
+		return nil, err
+	}
+	for _, addr := range append([]string{n.From}, n.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid address %q", addr)
+		}
+	}
+	return n, nil
+}
+
+func (n *email) Send(notification Notification) error {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(notification.Title)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.Text(), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+	return sendMail(fmt.Sprintf("%s:%d", n.Host, n.Port), auth, n.From, n.To, msg.Bytes())
+}
+
+// command runs a program with the notification as JSON on its
+// standard input, only if allowed by AllowExec.
+type command struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+const commandTimeout = 30 * time.Second
+
+func newExec(config json.RawMessage) (Notifier, error) {
+	if !AllowExec {
+		return nil, fmt.Errorf("exec notifiers are disabled")
+	}
+	n := &command{}
+	if err := decode(config, n); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"command": n.Command}); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *command) Send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, n.Command, n.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
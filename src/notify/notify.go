@@ -0,0 +1,125 @@
+// Package notify delivers notifications about new items through
+// pluggable notifiers (webhook, ntfy, Telegram, email, exec), each
+// kind registered with a constructor parsing its JSON configuration.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Item struct {
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	FeedTitle string `json:"feed_title"`
+}
+
+type Notification struct {
+	Title string `json:"title"`
+	Items []Item `json:"items"`
+}
+
+// New returns the notification about the items, batched into a
+// single message if there are several of them.
+func New(items []Item) Notification {
+	if len(items) == 1 {
+		title := items[0].Title
+		if title == "" {
+			title = items[0].Link
+		}
+		return Notification{Title: items[0].FeedTitle + ": " + title, Items: items}
+	}
+	return Notification{Title: fmt.Sprintf("%d new items", len(items)), Items: items}
+}
+
+// Text is the plain text body of the notification.
+func (n Notification) Text() string {
+	var b strings.Builder
+	for _, item := range n.Items {
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		fmt.Fprintf(&b, "%s: %s\n%s\n", item.FeedTitle, title, item.Link)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type Notifier interface {
+	Send(n Notification) error
+}
+
+// AllowExec enables the exec notifiers, which run commands on the server.
+var AllowExec = false
+
+var kinds = map[string]func(config json.RawMessage) (Notifier, error){
+	"webhook":  newWebhook,
+	"ntfy":     newNtfy,
+	"telegram": newTelegram,
+	"email":    newEmail,
+	"exec":     newExec,
+}
+
+// Kinds lists the supported kinds of notifiers.
+func Kinds() []string {
+	result := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		result = append(result, kind)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// NewNotifier returns the notifier of the given kind, or an error
+// if the kind is unknown or its configuration is invalid.
+func NewNotifier(kind string, config json.RawMessage) (Notifier, error) {
+	constructor, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier kind %q", kind)
+	}
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	return constructor(config)
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func post(req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+func decode(config json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(string(config)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %s", err)
+	}
+	return nil
+}
+
+func required(fields map[string]string) error {
+	names := make([]string, 0)
+	for name, value := range fields {
+		if strings.TrimSpace(value) == "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return fmt.Errorf("missing %s", strings.Join(names, ", "))
+	}
+	return nil
+}
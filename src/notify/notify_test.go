@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewNotifierConfig(t *testing.T) {
+	invalid := map[string]string{
+		"unknown":  `{}`,
+		"webhook":  `{"url": "ftp://example.com"}`,
+		"ntfy":     `{"server": "https://ntfy.sh"}`,
+		"telegram": `{"token": "x"}`,
+		"email":    `{"host": "smtp.example.com", "from": "a@example.com", "to": ["b@example.com\r\nBcc: c@example.com"]}`,
+		"exec":     `{"command": "true"}`,
+	}
+	for kind, config := range invalid {
+		if _, err := NewNotifier(kind, json.RawMessage(config)); err == nil {
+			t.Errorf("%s: expected an error for %s", kind, config)
+		}
+	}
+	if _, err := NewNotifier("webhook", json.RawMessage(`{"url": "http://example.com", "extra": 1}`)); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, err := NewNotifier("ntfy", json.RawMessage(`{"topic": "yarr"}`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var req *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req, body = r, string(data)
+	}))
+	defer server.Close()
+
+	items := []Item{
+		{Title: "title1", Link: "http://example.com/1", FeedTitle: "feed"},
+		{Title: "", Link: "http://example.com/2", FeedTitle: "feed"},
+	}
+
+	webhook, _ := NewNotifier("webhook", json.RawMessage(`{"url": "`+server.URL+`/hook"}`))
+	if err := webhook.Send(New(items)); err != nil {
+		t.Fatal(err)
+	}
+	var n Notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/hook" || n.Title != "2 new items" || len(n.Items) != 2 {
+		t.Fatalf("invalid webhook request: %s %s", req.URL, body)
+	}
+
+	ntfy, _ := NewNotifier("ntfy", json.RawMessage(`{"server": "`+server.URL+`", "topic": "news"}`))
+	if err := ntfy.Send(New(items[:1])); err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/news" || req.Header.Get("Title") != "feed: title1" || req.Header.Get("Click") != items[0].Link {
+		t.Fatalf("invalid ntfy request: %s %v", req.URL, req.Header)
+	}
+	if want := "feed: title1\nhttp://example.com/1"; body != want {
+		t.Fatalf("want %q, have %q", want, body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	webhook, _ = NewNotifier("webhook", json.RawMessage(`{"url": "`+failing.URL+`"}`))
+	if err := webhook.Send(New(items)); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected an error with the status code, have %v", err)
+	}
+}
@@ -10,25 +10,22 @@ import (
 	"github.com/nkanaev/yarr/src/content/htmlutil"
 )
 
-type atomFeed struct {
-	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
-	ID      string      `xml:"id"`
-	Title   atomText    `xml:"title"`
-	Links   atomLinks   `xml:"link"`
-	Entries []atomEntry `xml:"entry"`
-}
+const atomNS = "http://www.w3.org/2005/Atom"
 
 type atomEntry struct {
-	ID        string    `xml:"id"`
-	Title     atomText  `xml:"title"`
-	Summary   atomText  `xml:"summary"`
-	Published string    `xml:"published"`
-	Updated   string    `xml:"updated"`
-	Links     atomLinks `xml:"link"`
-	Content   atomText  `xml:"http://www.w3.org/2005/Atom content"`
-	OrigLink  string    `xml:"http://rssnamespace.org/feedburner/ext/1.0 origLink"`
+	ID        string       `xml:"id"`
+	Title     atomText     `xml:"title"`
+	Summary   atomText     `xml:"summary"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Links     atomLinks    `xml:"link"`
+	Authors   []atomPerson `xml:"author"`
+	Content   atomText     `xml:"http://www.w3.org/2005/Atom content"`
+	OrigLink  string       `xml:"http://rssnamespace.org/feedburner/ext/1.0 origLink"`
+	Lang      string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
 
 	media
+	geo
 }
 
 type atomText struct {
@@ -37,9 +34,15 @@ type atomText struct {
 	XML  string `xml:",innerxml"`
 }
 
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
 type atomLink struct {
-	Href string `xml:"href,attr"`
-	Rel  string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
 }
 
 type atomLinks []atomLink
@@ -71,35 +74,91 @@ func (links atomLinks) First(rel string) string {
 }
 
 func ParseAtom(r io.Reader) (*Feed, error) {
-	srcfeed := atomFeed{}
+	dstfeed := &Feed{}
+	err := streamAtom(r, dstfeed, func(item Item) error {
+		dstfeed.Items = append(dstfeed.Items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dstfeed, nil
+}
 
+// streamAtom decodes the feed one entry at a time. Feed fields
+// are stored in dstfeed as they're encountered.
+func streamAtom(r io.Reader, dstfeed *Feed, emit func(Item) error) error {
+	var title atomText
+	var links atomLinks
 	decoder := xmlDecoder(r)
-	if err := decoder.Decode(&srcfeed); err != nil {
-		return nil, err
+	return streamXML(decoder, xml.Name{Space: atomNS, Local: "feed"}, func(path []string, el *xml.StartElement) (bool, error) {
+		if len(path) == 0 {
+			dstfeed.Language = xmlLang(el)
+			return false, nil
+		}
+		if len(path) != 1 {
+			return false, nil
+		}
+		switch el.Name.Local {
+		case "title":
+			title = atomText{}
+			if err := decoder.DecodeElement(&title, el); err != nil {
+				return true, err
+			}
+			dstfeed.Title = title.String()
+			return true, nil
+		case "link":
+			var link atomLink
+			if err := decoder.DecodeElement(&link, el); err != nil {
+				return true, err
+			}
+			links = append(links, link)
+			dstfeed.SiteURL = firstNonEmpty(links.First("alternate"), links.First(""))
+			dstfeed.FeedLink = links.First("self")
+			return true, nil
+		case "entry":
+			var srcitem atomEntry
+			if err := decoder.DecodeElement(&srcitem, el); err != nil {
+				return true, err
+			}
+			return true, emit(srcitem.item())
+		}
+		return false, nil
+	})
+}
+
+func (srcitem *atomEntry) item() Item {
+	linkFromID := ""
+	if htmlutil.IsAPossibleLink(srcitem.ID) {
+		linkFromID = srcitem.ID
 	}
 
-	dstfeed := &Feed{
-		Title:   srcfeed.Title.String(),
-		SiteURL: firstNonEmpty(srcfeed.Links.First("alternate"), srcfeed.Links.First("")),
+	link := firstNonEmpty(srcitem.OrigLink, srcitem.Links.First("alternate"), srcitem.Links.First(""), linkFromID)
+	enclosures := make([]Enclosure, 0)
+	for _, l := range srcitem.Links {
+		if l.Rel == "enclosure" {
+			enclosures = append(enclosures, Enclosure{URL: l.Href, Type: l.Type, Length: parseLength(l.Length)})
+		}
 	}
-	for _, srcitem := range srcfeed.Entries {
-		linkFromID := ""
-		guidFromID := ""
-		if htmlutil.IsAPossibleLink(srcitem.ID) {
-			linkFromID = srcitem.ID
-			guidFromID = srcitem.ID + "::" + srcitem.Updated
+	authors := make([]string, 0, len(srcitem.Authors))
+	for _, author := range srcitem.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			authors = append(authors, name)
 		}
+	}
+	return Item{
+		GUID:     firstNonEmpty(srcitem.ID, link),
+		Date:     dateParse(firstNonEmpty(srcitem.Published, srcitem.Updated)),
+		Updated:  dateParse(srcitem.Updated),
+		URL:      link,
+		Title:    srcitem.Title.Text(),
+		Author:   strings.Join(authors, ", "),
+		Language: srcitem.Lang,
+		Content:  firstNonEmpty(srcitem.Content.String(), srcitem.Summary.String(), srcitem.firstMediaDescription()),
+		ImageURL: srcitem.firstMediaThumbnail(),
+		AudioURL: "",
+		Location: srcitem.location(),
 
-		link := firstNonEmpty(srcitem.OrigLink, srcitem.Links.First("alternate"), srcitem.Links.First(""), linkFromID)
-		dstfeed.Items = append(dstfeed.Items, Item{
-			GUID:     firstNonEmpty(guidFromID, srcitem.ID, link),
-			Date:     dateParse(firstNonEmpty(srcitem.Published, srcitem.Updated)),
-			URL:      link,
-			Title:    srcitem.Title.Text(),
-			Content:  firstNonEmpty(srcitem.Content.String(), srcitem.Summary.String(), srcitem.firstMediaDescription()),
-			ImageURL: srcitem.firstMediaThumbnail(),
-			AudioURL: "",
-		})
+		Enclosures: append(enclosures, srcitem.mediaEnclosures()...),
 	}
-	return dstfeed, nil
 }
@@ -36,14 +36,17 @@ func TestAtom(t *testing.T) {
 		</feed>
 	`))
 	want := &Feed{
-		Title:   "Example Feed",
-		SiteURL: "http://example.org/",
+		Title:    "Example Feed",
+		SiteURL:  "http://example.org/",
+		FeedLink: "http://example.org/feed/",
 		Items: []Item{
 			{
 				GUID:     "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a",
 				Date:     time.Unix(1071340202, 0).UTC(),
+				Updated:  time.Unix(1071340202, 0).UTC(),
 				URL:      "http://example.org/2003/12/13/atom03.html",
 				Title:    "Atom-Powered Robots Run Amok",
+				Author:   "John Doe",
 				Content:  `<div xmlns="http://www.w3.org/1999/xhtml"><p>This is the entry content.</p></div>`,
 				ImageURL: "",
 				AudioURL: "",
@@ -192,8 +195,9 @@ func TestAtomLinkInID(t *testing.T) {
 	have := feed.Items
 	want := []Item{
 		Item{
-			GUID:     "https://example.com/posts/1::2003-12-13T09:17:51",
+			GUID:     "https://example.com/posts/1",
 			Date:     time.Date(2003, time.December, 13, 9, 17, 51, 0, time.UTC),
+			Updated:  time.Date(2003, time.December, 13, 9, 17, 51, 0, time.UTC),
 			URL:      "https://example.com/posts/1",
 			Title:    "one updated",
         },
@@ -203,7 +207,7 @@ func TestAtomLinkInID(t *testing.T) {
             Title: "two",
         },
         Item{
-            GUID: "https://example.com/posts/1::",
+            GUID: "https://example.com/posts/1",
             Date: time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
             URL: "https://example.com/posts/1",
             Title: "one",
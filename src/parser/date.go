@@ -1,6 +1,10 @@
 package parser
 
-import "time"
+import (
+	"strings"
+	"time"
+	"unicode"
+)
 
 // taken from github.com/mjibson/goread
 var dateFormats = []string{
@@ -203,16 +207,99 @@ var dateFormats = []string{
 	"2 January, 2006",
 }
 
+// layouts tried once a (missing, misspelled or localized) weekday
+// has been stripped from the date
+var dateFormatsNoWeekday = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04:05 MST",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04:05 MST",
+	"2 January 2006",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05 MST",
+	"Jan 2, 2006 15:04:05 MST",
+	"January 2, 2006 15:04:05 MST",
+}
+
+// offsets of the zone abbreviations commonly found in feeds. time.Parse
+// only knows the ones of the local zone and assumes UTC for the rest.
+var zoneOffsets = map[string]int{
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+	"CET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"BST":  1 * 3600,
+	"JST":  9 * 3600,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+}
+
 var defaultTime = time.Time{}
 
 func dateParse(line string) time.Time {
+	line = strings.Join(strings.Fields(line), " ")
 	if line == "" {
 		return defaultTime
 	}
 	for _, layout := range dateFormats {
 		if t, err := time.Parse(layout, line); err == nil {
-			return t
+			return fixZone(t)
+		}
+	}
+	if stripped := stripWeekday(line); stripped != line {
+		for _, layout := range dateFormatsNoWeekday {
+			if t, err := time.Parse(layout, stripped); err == nil {
+				return fixZone(t)
+			}
 		}
 	}
 	return defaultTime
 }
+
+func fixZone(t time.Time) time.Time {
+	name, offset := t.Zone()
+	if offset != 0 {
+		return t
+	}
+	if offset, ok := zoneOffsets[name]; ok {
+		return time.Date(
+			t.Year(), t.Month(), t.Day(),
+			t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+			time.FixedZone(name, offset),
+		)
+	}
+	return t
+}
+
+// stripWeekday removes the leading word of the date, unless it's a month.
+func stripWeekday(line string) string {
+	end := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end <= 0 {
+		return line
+	}
+	word := strings.ToLower(line[:end])
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(strings.ToLower(m.String()), word) {
+			return line
+		}
+	}
+	return strings.TrimLeft(line[end:], ",. ")
+}
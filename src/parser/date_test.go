@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateParse(t *testing.T) {
+	want := time.Date(2023, time.March, 7, 15, 4, 5, 0, time.UTC)
+	cases := []string{
+		"Tue, 07 Mar 2023 15:04:05 GMT",
+		"  Tue,  07 Mar 2023\n15:04:05 +0000 ",
+		"2023-03-07T15:04:05Z",
+		"Tues, 07 Mar 2023 15:04:05 +0000",
+		"Di, 07 Mar 2023 15:04:05 +0000",
+		"07 Mar 2023 15:04:05 +0000",
+		"Tue, 07 Mar 2023 10:04:05 EST",
+		"Tue, 07 Mar 2023 07:04:05 PST",
+	}
+	for _, line := range cases {
+		if have := dateParse(line); !have.Equal(want) {
+			t.Errorf("%q: expected %s, got %s", line, want, have)
+		}
+	}
+	for _, line := range []string{"", "yesterday", "Mon, 32 Foo 2023"} {
+		if have := dateParse(line); !have.IsZero() {
+			t.Errorf("%q: expected zero time, got %s", line, have)
+		}
+	}
+}
+
+func TestNormalizeDates(t *testing.T) {
+	now := time.Date(2023, time.March, 7, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	feed := &Feed{Items: []Item{
+		{Date: past},
+		{Date: time.Time{}},
+		{Date: time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{Date: time.Date(1901, time.December, 13, 0, 0, 0, 0, time.UTC)},
+		{Date: time.Date(1985, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{Date: now.Add(365 * 24 * time.Hour)},
+	}}
+	feed.NormalizeDates(now)
+	want := []time.Time{past, now, now, now, time.Date(1985, time.June, 1, 0, 0, 0, 0, time.UTC), now}
+	for i, item := range feed.Items {
+		if !item.Date.Equal(want[i]) {
+			t.Errorf("item %d: expected %s, got %s", i, want[i], item.Date)
+		}
+	}
+}
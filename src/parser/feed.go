@@ -20,6 +20,9 @@ type feedProbe struct {
 	feedType string
 	callback func(r io.Reader) (*Feed, error)
 	encoding string
+
+	// set for the formats which can be decoded item by item
+	stream func(r io.Reader, feed *Feed, emit func(Item) error) error
 }
 
 func sniff(lookup string) (out feedProbe) {
@@ -49,6 +52,7 @@ func sniff(lookup string) (out feedProbe) {
 				case "rss":
 					out.feedType = "rss"
 					out.callback = ParseRSS
+					out.stream = streamRSS
 					return
 				case "RDF":
 					out.feedType = "rdf"
@@ -57,6 +61,7 @@ func sniff(lookup string) (out feedProbe) {
 				case "feed":
 					out.feedType = "atom"
 					out.callback = ParseAtom
+					out.stream = streamAtom
 					return
 				}
 			}
@@ -65,15 +70,45 @@ func sniff(lookup string) (out feedProbe) {
 		out.feedType = "json"
 		out.callback = ParseJSON
 		return
+	case 'B', 'b':
+		if strings.HasPrefix(strings.ToUpper(lookup), "BEGIN:VCALENDAR") {
+			out.feedType = "ics"
+			out.callback = ParseICS
+		}
+		return
 	}
 	return
 }
 
+func (p feedProbe) isXML() bool {
+	return p.feedType != "json" && p.feedType != "ics"
+}
+
 func Parse(r io.Reader) (*Feed, error) {
 	return ParseWithEncoding(r, "")
 }
 
 func ParseWithEncoding(r io.Reader, fallbackEncoding string) (*Feed, error) {
+	r, out, err := prepare(r, fallbackEncoding, MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	// out.callback() will return the parsed feed. there's no custom order here,
+	// that's only applicable in the storage part, and in the opml-import part.
+	// this is a third "feed" representation.
+	feed, err := out.callback(r)
+	if feed != nil {
+		feed.cleanup()
+	}
+	return feed, err
+}
+
+// prepare detects the feed format and sets up the decoding of the
+// input, reading no more than limit bytes.
+func prepare(r io.Reader, fallbackEncoding string, limit int64) (io.Reader, feedProbe, error) {
+	r = &limitedReader{r: r, n: limit}
+
 	lookup := make([]byte, 2048)
 	n, err := io.ReadFull(r, lookup)
 	switch {
@@ -81,38 +116,36 @@ func ParseWithEncoding(r io.Reader, fallbackEncoding string) (*Feed, error) {
 		lookup = lookup[:n]
 		r = bytes.NewReader(lookup)
 	case err != nil:
-		return nil, err
+		return nil, feedProbe{}, err
 	default:
 		r = io.MultiReader(bytes.NewReader(lookup), r)
 	}
 
-	// out.callback() will return the parsed feed. there's no custom order here,
-	// that's only applicable in the storage part, and in the opml-import part.
-	// this is a third "feed" representation.
 	out := sniff(string(lookup))
 	if out.feedType == "" {
-		return nil, UnknownFormat
+		return nil, out, UnknownFormat
 	}
 
 	if out.encoding == "" && fallbackEncoding != "" {
 		r, err = charset.NewReaderLabel(fallbackEncoding, r)
 		if err != nil {
-			return nil, err
+			return nil, out, err
 		}
+		// transcoding may expand the input, limit the decoded size too
+		r = &limitedReader{r: r, n: limit}
 	}
 
-	if (out.feedType != "json") && (out.encoding == "" || out.encoding == "utf-8") {
+	if !out.isXML() {
+		return r, out, nil
+	}
+	if out.encoding == "" || out.encoding == "utf-8" {
 		// XML decoder will not rely on custom CharsetReader (see `xmlDecoder`)
 		// to handle invalid xml characters.
 		// Assume input is already UTF-8 and do the cleanup here.
 		r = NewSafeXMLReader(r)
 	}
-
-	feed, err := out.callback(r)
-	if feed != nil {
-		feed.cleanup()
-	}
-	return feed, err
+	r = newXMLLimitReader(r)
+	return r, out, nil
 }
 
 func ParseAndFix(r io.Reader, baseURL, fallbackEncoding string) (*Feed, error) {
@@ -121,20 +154,30 @@ func ParseAndFix(r io.Reader, baseURL, fallbackEncoding string) (*Feed, error) {
 		return nil, err
 	}
 	feed.TranslateURLs(baseURL)
-	feed.SetMissingDatesTo(time.Now())
+	feed.NormalizeDates(time.Now())
+	feed.DetectIncidents(baseURL)
 	return feed, nil
 }
 
 func (feed *Feed) cleanup() {
 	feed.Title = strings.TrimSpace(feed.Title)
 	feed.SiteURL = strings.TrimSpace(feed.SiteURL)
+	feed.FeedLink = strings.TrimSpace(feed.FeedLink)
+	feed.Language = cleanLanguage(feed.Language)
 
 	for i, item := range feed.Items {
 		feed.Items[i].GUID = strings.TrimSpace(item.GUID)
+		if lang := cleanLanguage(item.Language); lang != feed.Language {
+			feed.Items[i].Language = lang
+		} else {
+			feed.Items[i].Language = ""
+		}
 		feed.Items[i].URL = strings.TrimSpace(item.URL)
 		feed.Items[i].Title = strings.TrimSpace(htmlutil.ExtractText(item.Title))
 		feed.Items[i].Content = strings.TrimSpace(item.Content)
 
+		feed.Items[i].Enclosures = cleanEnclosures(item.Enclosures)
+
 		if item.ImageURL != "" && strings.Contains(item.Content, item.ImageURL) {
 			feed.Items[i].ImageURL = ""
 		}
@@ -144,6 +187,34 @@ func (feed *Feed) cleanup() {
 	}
 }
 
+// MaxEnclosures is the number of enclosures kept per item.
+var MaxEnclosures = 32
+
+// cleanEnclosures drops the enclosures without a url and the repeated
+// ones, nil if none is left.
+func cleanEnclosures(enclosures []Enclosure) []Enclosure {
+	var result []Enclosure
+	seen := make(map[string]bool)
+	for _, e := range enclosures {
+		e.URL = strings.TrimSpace(e.URL)
+		e.Type = strings.ToLower(strings.TrimSpace(e.Type))
+		if e.URL == "" || seen[e.URL] {
+			continue
+		}
+		seen[e.URL] = true
+		result = append(result, e)
+		if len(result) == MaxEnclosures {
+			break
+		}
+	}
+	return result
+}
+
+// MinDate is the Unix epoch, the date of the zero timestamps: items
+// dated to it, or earlier, get the fetch time instead. The old dates
+// past it are legitimate, e.g. in the feeds of archives.
+var MinDate = time.Unix(0, 0).UTC()
+
 func (feed *Feed) SetMissingDatesTo(newdate time.Time) {
 	for i, item := range feed.Items {
 		if item.Date.IsZero() {
@@ -152,6 +223,22 @@ func (feed *Feed) SetMissingDatesTo(newdate time.Time) {
 	}
 }
 
+// NormalizeDates replaces missing and bogus dates with the fetch time,
+// and clamps dates in the future to it, so that such items don't
+// stick to the top of the list. The upcoming events keep their date.
+func (feed *Feed) NormalizeDates(now time.Time) {
+	for i, item := range feed.Items {
+		if !item.Date.After(MinDate) || (item.Date.After(now) && item.Event == nil) {
+			feed.Items[i].Date = now
+		}
+		if !item.Updated.After(MinDate) {
+			feed.Items[i].Updated = time.Time{}
+		} else if item.Updated.After(now) {
+			feed.Items[i].Updated = now
+		}
+	}
+}
+
 func (feed *Feed) TranslateURLs(base string) error {
 	baseUrl, err := url.Parse(base)
 	if err != nil {
@@ -162,6 +249,18 @@ func (feed *Feed) TranslateURLs(base string) error {
 		return fmt.Errorf("failed to parse feed url: %#v", feed.SiteURL)
 	}
 	feed.SiteURL = baseUrl.ResolveReference(siteUrl).String()
+	if feed.FeedLink != "" {
+		if feedUrl, err := url.Parse(feed.FeedLink); err == nil {
+			feed.FeedLink = baseUrl.ResolveReference(feedUrl).String()
+		}
+	}
+	for i := range feed.Items {
+		for j, e := range feed.Items[i].Enclosures {
+			if link, err := url.Parse(e.URL); err == nil {
+				feed.Items[i].Enclosures[j].URL = siteUrl.ResolveReference(link).String()
+			}
+		}
+	}
 	for _, item := range feed.Items {
 		itemUrl, err := url.Parse(item.URL)
 		if err != nil {
@@ -150,3 +150,81 @@ func TestParseCleanIllegalCharsInNonUTF8(t *testing.T) {
 		t.Fatalf("invalid feed, got: %v", feed)
 	}
 }
+
+func TestParseLanguage(t *testing.T) {
+	cases := []struct {
+		feed  string
+		want  string
+		items []string
+	}{
+		{`<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="ar">
+			<entry><id>1</id></entry>
+			<entry xml:lang="en"><id>2</id></entry>
+		</feed>`, "ar", []string{"", "en"}},
+		{`<rss xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+			<language>he_il</language>
+			<item><guid>1</guid><dc:language>he-IL</dc:language></item>
+			<item><guid>2</guid><dc:language>en</dc:language></item>
+		</channel></rss>`, "he-IL", []string{"", "en"}},
+		{`<rss><channel><language>not a language!</language><item><guid>1</guid></item></channel></rss>`, "", []string{""}},
+		{`{"version": "https://jsonfeed.org/version/1.1", "language": "fa", "items": [{"id": "1", "language": "zh-hant-tw"}]}`, "fa", []string{"zh-Hant-TW"}},
+	}
+	for _, c := range cases {
+		feed, err := Parse(strings.NewReader(c.feed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		items := make([]string, len(feed.Items))
+		for i, item := range feed.Items {
+			items[i] = item.Language
+		}
+		if feed.Language != c.want || !reflect.DeepEqual(items, c.items) {
+			t.Errorf("want %q %q, have %q %q", c.want, c.items, feed.Language, items)
+		}
+	}
+}
+
+func TestParseEnclosures(t *testing.T) {
+	rss, _ := Parse(strings.NewReader(`
+		<rss xmlns:media="http://search.yahoo.com/mrss/"><channel><item>
+			<guid>1</guid>
+			<enclosure url="http://example.com/hq.mp3" type="audio/mpeg" length="2048"/>
+			<enclosure url="http://example.com/lq.ogg" type="Audio/Ogg" length="n/a"/>
+			<media:group>
+				<media:content url="http://example.com/hq.mp3" type="audio/mpeg" fileSize="2048"/>
+				<media:content url="http://example.com/clip.mp4" type="video/mp4" fileSize="4096"/>
+			</media:group>
+			<media:content url=""/>
+		</item></channel></rss>
+	`))
+	want := []Enclosure{
+		{URL: "http://example.com/hq.mp3", Type: "audio/mpeg", Length: 2048},
+		{URL: "http://example.com/lq.ogg", Type: "audio/ogg"},
+		{URL: "http://example.com/clip.mp4", Type: "video/mp4", Length: 4096},
+	}
+	if have := rss.Items[0].Enclosures; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %#v, have %#v", want, have)
+	}
+
+	atom, _ := ParseAndFix(strings.NewReader(`
+		<feed xmlns="http://www.w3.org/2005/Atom">
+			<link href="http://example.com/"/>
+			<entry><id>1</id><link rel="enclosure" href="/episode.m4a" type="audio/mp4" length="100"/></entry>
+		</feed>
+	`), "http://example.com/feed.xml", "")
+	want = []Enclosure{{URL: "http://example.com/episode.m4a", Type: "audio/mp4", Length: 100}}
+	if have := atom.Items[0].Enclosures; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %#v, have %#v", want, have)
+	}
+
+	json, _ := Parse(strings.NewReader(`{
+		"version": "https://jsonfeed.org/version/1.1",
+		"items": [{"id": "1", "attachments": [
+			{"url": "http://example.com/cover.jpg", "mime_type": "image/jpeg"},
+			{"url": "http://example.com/episode.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 300}
+		]}]
+	}`))
+	if have := json.Items[0].Enclosures; len(have) != 2 || have[1].Length != 300 {
+		t.Errorf("unexpected attachments: %#v", have)
+	}
+}
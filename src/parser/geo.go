@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// GeoRSS (https://www.georss.org) and W3C Basic Geo
+// (https://www.w3.org/2003/01/geo/) location of an item
+type geo struct {
+	GeoRSSPoint string    `xml:"http://www.georss.org/georss point"`
+	GeoRSSWhere *geoWhere `xml:"http://www.georss.org/georss where"`
+	GeoLat      string    `xml:"http://www.w3.org/2003/01/geo/wgs84_pos# lat"`
+	GeoLong     string    `xml:"http://www.w3.org/2003/01/geo/wgs84_pos# long"`
+	GeoPoint    *geoPoint `xml:"http://www.w3.org/2003/01/geo/wgs84_pos# Point"`
+}
+
+// <georss:where><gml:Point><gml:pos>45.256 -71.92</gml:pos></gml:Point></georss:where>
+type geoWhere struct {
+	Pos string `xml:"http://www.opengis.net/gml Point>pos"`
+}
+
+// <geo:Point><geo:lat>55.701</geo:lat><geo:long>12.552</geo:long></geo:Point>
+type geoPoint struct {
+	Lat  string `xml:"http://www.w3.org/2003/01/geo/wgs84_pos# lat"`
+	Long string `xml:"http://www.w3.org/2003/01/geo/wgs84_pos# long"`
+}
+
+func (g *geo) location() *Location {
+	if loc := parseGeoPair(g.GeoRSSPoint); loc != nil {
+		return loc
+	}
+	if g.GeoRSSWhere != nil {
+		if loc := parseGeoPair(g.GeoRSSWhere.Pos); loc != nil {
+			return loc
+		}
+	}
+	if loc := parseLocation(g.GeoLat, g.GeoLong); loc != nil {
+		return loc
+	}
+	if g.GeoPoint != nil {
+		return parseLocation(g.GeoPoint.Lat, g.GeoPoint.Long)
+	}
+	return nil
+}
+
+// parseGeoPair parses the "latitude longitude" pair of GeoRSS.
+func parseGeoPair(pair string) *Location {
+	fields := strings.Fields(strings.ReplaceAll(pair, ",", " "))
+	if len(fields) != 2 {
+		return nil
+	}
+	return parseLocation(fields[0], fields[1])
+}
+
+func parseLocation(lat, long string) *Location {
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(long), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil
+	}
+	return &Location{Latitude: latitude, Longitude: longitude}
+}
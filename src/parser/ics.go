@@ -0,0 +1,456 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iCalendar (RFC 5545) calendars are read as feeds of their upcoming
+// events: an event becomes an item once its reminder (the earliest VALARM,
+// or a day ahead if it has none) goes off, dated with the start of the event.
+// Recurring events are expanded by FREQ, INTERVAL, COUNT and UNTIL,
+// the rules narrowed down further (BYDAY and the like) yield the first
+// occurrence only.
+
+// defaultReminder is how early the events without any alarm show up.
+const defaultReminder = 24 * time.Hour
+
+// the occurrences of a recurring event looked at, whatever the rule says
+// (a daily event for a few centuries)
+const maxOccurrences = 100000
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsComponent is a BEGIN:...END block.
+type icsComponent struct {
+	name       string
+	props      []icsProperty
+	components []*icsComponent
+}
+
+func (c *icsComponent) prop(name string) *icsProperty {
+	for i := range c.props {
+		if c.props[i].name == name {
+			return &c.props[i]
+		}
+	}
+	return nil
+}
+
+func (c *icsComponent) text(name string) string {
+	if p := c.prop(name); p != nil {
+		return unescapeICSText(p.value)
+	}
+	return ""
+}
+
+func ParseICS(r io.Reader) (*Feed, error) {
+	return parseICS(r, time.Now())
+}
+
+func parseICS(r io.Reader, now time.Time) (*Feed, error) {
+	calendar, err := readICS(r)
+	if err != nil {
+		return nil, err
+	}
+	feed := &Feed{
+		Title:   calendar.text("X-WR-CALNAME"),
+		SiteURL: calendar.text("URL"),
+	}
+	for _, c := range calendar.components {
+		if c.name != "VEVENT" || strings.EqualFold(c.text("STATUS"), "CANCELLED") {
+			continue
+		}
+		feed.Items = append(feed.Items, eventItems(c, now)...)
+	}
+	sort.SliceStable(feed.Items, func(i, j int) bool {
+		return feed.Items[i].Date.Before(feed.Items[j].Date)
+	})
+	return feed, nil
+}
+
+// eventItems returns the occurrences of the event whose reminder
+// has gone off and which haven't ended yet.
+func eventItems(c *icsComponent, now time.Time) []Item {
+	startProp := c.prop("DTSTART")
+	if startProp == nil {
+		return nil
+	}
+	start, allDay, err := parseICSTime(startProp)
+	if err != nil {
+		return nil
+	}
+	length := time.Duration(0)
+	if endProp := c.prop("DTEND"); endProp != nil {
+		if end, _, err := parseICSTime(endProp); err == nil && end.After(start) {
+			length = end.Sub(start)
+		}
+	} else if d, err := parseICSDuration(c.text("DURATION")); err == nil && d > 0 {
+		length = d
+	} else if allDay {
+		length = 24 * time.Hour
+	}
+	reminder := eventReminder(c, length)
+
+	uid := c.text("UID")
+	var items []Item
+	for _, occurrence := range occurrences(c, start, now.Add(-length), now.Add(reminder)) {
+		end := occurrence.Add(length)
+		if now.Before(occurrence.Add(-reminder)) || now.After(end) {
+			continue
+		}
+		guid := uid
+		if guid == "" || c.prop("RRULE") != nil {
+			guid = fmt.Sprintf("%s@%s", uid, occurrence.UTC().Format("20060102T150405Z"))
+		}
+		items = append(items, Item{
+			GUID:     guid,
+			Date:     occurrence,
+			URL:      c.text("URL"),
+			Title:    c.text("SUMMARY"),
+			Author:   organizer(c),
+			Content:  eventContent(c, occurrence, end, allDay),
+			Location: parseGeoPair(strings.ReplaceAll(c.text("GEO"), ";", " ")),
+			Event:    &Event{Start: occurrence, End: end, AllDay: allDay, Place: c.text("LOCATION")},
+		})
+	}
+	return items
+}
+
+// eventReminder returns how long before the start the earliest alarm goes off.
+func eventReminder(c *icsComponent, length time.Duration) time.Duration {
+	reminder, found := time.Duration(0), false
+	for _, alarm := range c.components {
+		if alarm.name != "VALARM" {
+			continue
+		}
+		trigger := alarm.prop("TRIGGER")
+		if trigger == nil {
+			continue
+		}
+		var before time.Duration
+		if trigger.params["VALUE"] == "DATE-TIME" {
+			at, _, err := parseICSTime(trigger)
+			start, _, err2 := parseICSTime(c.prop("DTSTART"))
+			if err != nil || err2 != nil {
+				continue
+			}
+			before = start.Sub(at)
+		} else {
+			d, err := parseICSDuration(trigger.value)
+			if err != nil {
+				continue
+			}
+			before = -d
+			if trigger.params["RELATED"] == "END" {
+				before -= length
+			}
+		}
+		if !found || before > reminder {
+			reminder, found = before, true
+		}
+	}
+	if !found {
+		return defaultReminder
+	}
+	return reminder
+}
+
+func organizer(c *icsComponent) string {
+	p := c.prop("ORGANIZER")
+	if p == nil {
+		return ""
+	}
+	if name := strings.Trim(p.params["CN"], `"`); name != "" {
+		return name
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p.value, "mailto:"), "MAILTO:")
+}
+
+func eventContent(c *icsComponent, start, end time.Time, allDay bool) string {
+	var b strings.Builder
+	layout := "Mon, 2 Jan 2006 15:04 MST"
+	if allDay {
+		layout = "Mon, 2 Jan 2006"
+		// the end date is exclusive
+		end = end.AddDate(0, 0, -1)
+	}
+	when := start.Format(layout)
+	if !end.IsZero() && end.Format(layout) != when {
+		when += " – " + end.Format(layout)
+	}
+	fmt.Fprintf(&b, "<p><b>When:</b> %s</p>", html.EscapeString(when))
+	if place := c.text("LOCATION"); place != "" {
+		fmt.Fprintf(&b, "<p><b>Where:</b> %s</p>", html.EscapeString(place))
+	}
+	if description := c.text("DESCRIPTION"); description != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", plain2html(html.EscapeString(description)))
+	}
+	return b.String()
+}
+
+// occurrences returns the start times of the event between from and to
+// (and the first one regardless if it's not recurring).
+func occurrences(c *icsComponent, start, from, to time.Time) []time.Time {
+	excluded := make(map[time.Time]bool)
+	for _, p := range c.props {
+		if p.name != "EXDATE" {
+			continue
+		}
+		for _, value := range strings.Split(p.value, ",") {
+			if t, _, err := parseICSTime(&icsProperty{params: p.params, value: value}); err == nil {
+				excluded[t.UTC()] = true
+			}
+		}
+	}
+
+	rule := c.prop("RRULE")
+	if rule == nil {
+		return []time.Time{start}
+	}
+	parts := make(map[string]string)
+	for _, part := range strings.Split(rule.value, ";") {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+			parts[strings.ToUpper(kv[0])] = kv[1]
+		}
+	}
+	interval, _ := strconv.Atoi(parts["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(parts["COUNT"])
+	if count < 1 || count > maxOccurrences {
+		count = maxOccurrences
+	}
+	var until time.Time
+	if parts["UNTIL"] != "" {
+		until, _, _ = parseICSTime(&icsProperty{params: rule.params, value: parts["UNTIL"]})
+	}
+	for part := range parts {
+		if strings.HasPrefix(part, "BY") {
+			count = 1
+		}
+	}
+
+	result := make([]time.Time, 0)
+	for i := 0; i < count; i++ {
+		var t time.Time
+		n := i * interval
+		switch parts["FREQ"] {
+		case "DAILY":
+			t = start.AddDate(0, 0, n)
+		case "WEEKLY":
+			t = start.AddDate(0, 0, 7*n)
+		case "MONTHLY":
+			t = start.AddDate(0, n, 0)
+		case "YEARLY":
+			t = start.AddDate(n, 0, 0)
+		default:
+			// hourly and the like aren't calendar events
+			return []time.Time{start}
+		}
+		if t.After(to) || (!until.IsZero() && t.After(until)) {
+			break
+		}
+		if !t.Before(from) && !excluded[t.UTC()] {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// readICS reads the calendar, the VCALENDAR component.
+func readICS(r io.Reader) (*icsComponent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var stack []*icsComponent
+	var calendar *icsComponent
+	var line string
+	handle := func(line string) error {
+		p, ok := parseICSLine(line)
+		if !ok {
+			return nil
+		}
+		switch p.name {
+		case "BEGIN":
+			if len(stack) >= MaxNestingDepth {
+				return ErrNestingTooDeep
+			}
+			c := &icsComponent{name: strings.ToUpper(p.value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			} else if calendar == nil {
+				calendar = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.props = append(top.props, p)
+			}
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		next := strings.TrimRight(scanner.Text(), "\r")
+		// the long lines are folded, continued after a space or a tab
+		if strings.HasPrefix(next, " ") || strings.HasPrefix(next, "\t") {
+			line += next[1:]
+			continue
+		}
+		if err := handle(line); err != nil {
+			return nil, err
+		}
+		line = next
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := handle(line); err != nil {
+		return nil, err
+	}
+	if calendar == nil || calendar.name != "VCALENDAR" {
+		return nil, UnknownFormat
+	}
+	return calendar, nil
+}
+
+// parseICSLine parses the content line `NAME;PARAM=VALUE;PARAM="VALUE":value`.
+func parseICSLine(line string) (icsProperty, bool) {
+	p := icsProperty{params: make(map[string]string)}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return p, false
+	}
+	head := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(strings.TrimSpace(head[0]))
+	for _, param := range head[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	p.value = line[colon+1:]
+	return p, p.name != ""
+}
+
+func unescapeICSText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// parseICSTime parses a DATE or DATE-TIME value. The times in the named
+// time zone (TZID) are converted if the zone is known, the floating times
+// and the dates are taken as UTC.
+func parseICSTime(p *icsProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+	if len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := time.UTC
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseICSDuration parses a duration such as -PT15M, P1DT2H or P1W.
+func parseICSDuration(s string) (time.Duration, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	var total time.Duration
+	inTime := false
+	num := 0
+	digits := false
+	for _, r := range s[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			num = num*10 + int(r-'0')
+			digits = true
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+		unit := time.Duration(0)
+		switch {
+		case r == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case r == 'H' && inTime:
+			unit = time.Hour
+		case r == 'M' && inTime:
+			unit = time.Minute
+		case r == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration: %q", s)
+		}
+		total += time.Duration(num) * unit
+		num, digits = 0, false
+	}
+	if digits {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	return sign * total, nil
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"X-WR-CALNAME:Meetups\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:tomorrow@example.com\r\n" +
+	"DTSTART:20240102T100000Z\r\n" +
+	"DTEND:20240102T120000Z\r\n" +
+	"SUMMARY:Go meetup\r\n" +
+	"LOCATION:Main st. 1\\, Springfield\r\n" +
+	"DESCRIPTION:Talks and <pizza>.\\nSee https://example.com/meetup\r\n" +
+	" /details\r\n" +
+	"GEO:37.386013;-122.082932\r\n" +
+	"ORGANIZER;CN=\"Jane Doe\":mailto:jane@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:reminded@example.com\r\n" +
+	"DTSTART;TZID=UTC:20240101T130000\r\n" +
+	"SUMMARY:Lunch\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:weekly@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20231204\r\n" +
+	"RRULE:FREQ=WEEKLY;COUNT=10\r\n" +
+	"EXDATE;VALUE=DATE:20231225\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:past@example.com\r\n" +
+	"DTSTART:20231201T100000Z\r\n" +
+	"SUMMARY:Over\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@example.com\r\n" +
+	"DTSTART:20240101T150000Z\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"SUMMARY:Cancelled\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICS(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	feed, err := parseICS(strings.NewReader(calendar), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "Meetups" {
+		t.Errorf("unexpected title: %q", feed.Title)
+	}
+	guids := make([]string, 0)
+	for _, item := range feed.Items {
+		guids = append(guids, item.GUID)
+	}
+	// the lunch is 15 minutes ahead of its reminder
+	want := []string{"weekly@example.com@20240101T000000Z", "tomorrow@example.com"}
+	if !reflect.DeepEqual(guids, want) {
+		t.Fatalf("want %v, have %v", want, guids)
+	}
+
+	meetup := feed.Items[1]
+	if !meetup.Date.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) || meetup.Title != "Go meetup" || meetup.Author != "Jane Doe" {
+		t.Errorf("unexpected item: %#v", meetup)
+	}
+	wantContent := `<p><b>When:</b> Tue, 2 Jan 2024 10:00 UTC – Tue, 2 Jan 2024 12:00 UTC</p>` +
+		`<p><b>Where:</b> Main st. 1, Springfield</p>` +
+		`<p>Talks and &lt;pizza&gt;.<br>See <a href="https://example.com/meetup/details">https://example.com/meetup/details</a></p>`
+	if meetup.Content != wantContent {
+		t.Errorf("unexpected content:\n%s", meetup.Content)
+	}
+	if meetup.Location == nil || meetup.Location.Latitude != 37.386013 {
+		t.Errorf("unexpected location: %#v", meetup.Location)
+	}
+
+	feed, _ = parseICS(strings.NewReader(calendar), now.Add(50*time.Minute))
+	if len(feed.Items) != 3 || feed.Items[1].GUID != "reminded@example.com" {
+		t.Fatalf("expected the reminded event, got %#v", feed.Items)
+	}
+}
+
+func TestICSDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"PT15M":    15 * time.Minute,
+		"-PT15M":   -15 * time.Minute,
+		"P1DT2H":   26 * time.Hour,
+		"P1W":      7 * 24 * time.Hour,
+		"+PT1H30M": 90 * time.Minute,
+	}
+	for input, want := range cases {
+		if have, err := parseICSDuration(input); err != nil || have != want {
+			t.Errorf("%s: want %s, have %s (%v)", input, want, have, err)
+		}
+	}
+	for _, input := range []string{"", "P", "PT", "15M", "P1H", "PT1D"} {
+		if _, err := parseICSDuration(input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestICSSniff(t *testing.T) {
+	feed, err := Parse(strings.NewReader(calendar))
+	if err != nil || feed.Title != "Meetups" {
+		t.Fatalf("calendar not parsed: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+)
+
+// Incident is the state of an incident (or a maintenance)
+// announced by a status page, see DetectIncidents.
+type Incident struct {
+	// the latest update, e.g. "investigating" or "resolved"
+	Status string
+	// one of "maintenance", "minor", "major" and "critical"
+	Severity string
+}
+
+// the statuses of the updates of incidents and maintenances
+// of Statuspage (statuspage.io) and Cachet (cachethq.io)
+var incidentStatuses = map[string]bool{
+	"investigating": true,
+	"identified":    true,
+	"monitoring":    true,
+	"watching":      true,
+	"update":        true,
+	"resolved":      true,
+	"fixed":         true,
+	"postmortem":    true,
+	// maintenances
+	"scheduled":   true,
+	"in_progress": true,
+	"verifying":   true,
+	"completed":   true,
+}
+
+var maintenanceStatuses = map[string]bool{
+	"scheduled":   true,
+	"in_progress": true,
+	"verifying":   true,
+	"completed":   true,
+}
+
+// Statuspage lists the updates of an incident newest first:
+// <strong>Resolved</strong> - This incident has been resolved.
+var statusUpdateRe = regexp.MustCompile(`(?i)<strong>\s*([a-z][a-z ]*?)\s*</strong>\s*(?:-|&#8211;|–)`)
+
+// the words telling how bad an incident is, the worst first
+var severityWords = []struct {
+	severity string
+	words    []string
+}{
+	{"critical", []string{"major outage", "full outage", "complete outage", "is down", "are down", "unavailable"}},
+	{"major", []string{"outage", "disruption", "failing", "not working"}},
+}
+
+var incidentLinkRe = regexp.MustCompile(`/incidents/[0-9a-z]+/?$`)
+
+// IsStatusPage tells whether the feed is the history of a status page:
+// Statuspage serves it at /history.rss (or .atom), Cachet links its
+// items to /incidents/<id>.
+func IsStatusPage(feedLink string, feed *Feed) bool {
+	if u, err := url.Parse(feedLink); err == nil {
+		if path := strings.ToLower(u.Path); path == "/history.rss" || path == "/history.atom" {
+			return true
+		}
+	}
+	if len(feed.Items) == 0 {
+		return false
+	}
+	incidents := 0
+	for _, item := range feed.Items {
+		if incidentLinkRe.MatchString(item.URL) {
+			incidents++
+		}
+	}
+	return incidents*2 > len(feed.Items)
+}
+
+// DetectIncidents fills in the incidents of the items
+// if the feed is the history of a status page.
+func (feed *Feed) DetectIncidents(feedLink string) {
+	if !IsStatusPage(feedLink, feed) {
+		return
+	}
+	for i, item := range feed.Items {
+		feed.Items[i].Incident = parseIncident(item)
+	}
+}
+
+func parseIncident(item Item) *Incident {
+	incident := &Incident{}
+	if m := statusUpdateRe.FindStringSubmatch(item.Content); m != nil {
+		status := strings.ReplaceAll(strings.ToLower(m[1]), " ", "_")
+		if incidentStatuses[status] {
+			incident.Status = status
+		}
+	}
+	text := strings.ToLower(item.Title + " " + htmlutil.ExtractText(item.Content))
+	switch {
+	case maintenanceStatuses[incident.Status] || strings.Contains(strings.ToLower(item.Title), "maintenance"):
+		incident.Severity = "maintenance"
+	default:
+		incident.Severity = "minor"
+	severity:
+		for _, level := range severityWords {
+			for _, word := range level.words {
+				if strings.Contains(text, word) {
+					incident.Severity = level.severity
+					break severity
+				}
+			}
+		}
+	}
+	return incident
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectIncidents(t *testing.T) {
+	feed := &Feed{Items: []Item{
+		{
+			Title:   "Incident with Actions",
+			Content: `<p><small>Jan <var data-var='date'>15</var></small><br><strong>Monitoring</strong> - A fix has been deployed.</p><p><strong>Investigating</strong> - Some runs are failing.</p>`,
+		},
+		{
+			Title:   "Major outage of the API",
+			Content: `<p><strong>Resolved</strong> - This incident has been resolved.</p>`,
+		},
+		{
+			Title:   "Scheduled database maintenance",
+			Content: `<p><strong>In progress</strong> - Scheduled maintenance is currently in progress.</p>`,
+		},
+		{
+			Title:   "Slow page loads",
+			Content: `<p>We're looking into it.</p>`,
+		},
+	}}
+	feed.DetectIncidents("https://www.githubstatus.com/history.rss")
+
+	want := []*Incident{
+		{Status: "monitoring", Severity: "major"},
+		{Status: "resolved", Severity: "critical"},
+		{Status: "in_progress", Severity: "maintenance"},
+		{Status: "", Severity: "minor"},
+	}
+	for i, item := range feed.Items {
+		if !reflect.DeepEqual(item.Incident, want[i]) {
+			t.Errorf("%s: want %#v, have %#v", item.Title, want[i], item.Incident)
+		}
+	}
+}
+
+func TestIsStatusPage(t *testing.T) {
+	cachet := &Feed{Items: []Item{
+		{URL: "https://status.example.com/incidents/1"},
+		{URL: "https://status.example.com/incidents/2"},
+	}}
+	if !IsStatusPage("https://status.example.com/rss", cachet) {
+		t.Error("expected a status page")
+	}
+	blog := &Feed{Items: []Item{{URL: "https://example.com/posts/1"}}}
+	if IsStatusPage("https://example.com/feed.xml", blog) {
+		t.Error("unexpected status page")
+	}
+	blog.DetectIncidents("https://example.com/feed.xml")
+	if blog.Items[0].Incident != nil {
+		t.Errorf("unexpected incident: %#v", blog.Items[0].Incident)
+	}
+}
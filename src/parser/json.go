@@ -4,13 +4,16 @@ package parser
 import (
 	"encoding/json"
 	"io"
+	"strings"
 )
 
 type jsonFeed struct {
-	Version string     `json:"version"`
-	Title   string     `json:"title"`
-	SiteURL string     `json:"home_page_url"`
-	Items   []jsonItem `json:"items"`
+	Version  string     `json:"version"`
+	Title    string     `json:"title"`
+	SiteURL  string     `json:"home_page_url"`
+	FeedLink string     `json:"feed_url"`
+	Language string     `json:"language"`
+	Items    []jsonItem `json:"items"`
 }
 
 type jsonItem struct {
@@ -22,7 +25,30 @@ type jsonItem struct {
 	HTML          string           `json:"content_html"`
 	DatePublished string           `json:"date_published"`
 	DateModified  string           `json:"date_modified"`
+	Language      string           `json:"language"`
 	Attachments   []jsonAttachment `json:"attachments"`
+
+	// version 1.0 has a single author, 1.1 a list
+	Author  *jsonAuthor  `json:"author"`
+	Authors []jsonAuthor `json:"authors"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func (item *jsonItem) author() string {
+	authors := item.Authors
+	if len(authors) == 0 && item.Author != nil {
+		authors = []jsonAuthor{*item.Author}
+	}
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
 }
 
 type jsonAttachment struct {
@@ -41,16 +67,27 @@ func ParseJSON(data io.Reader) (*Feed, error) {
 	}
 
 	dstfeed := &Feed{
-		Title:   srcfeed.Title,
-		SiteURL: srcfeed.SiteURL,
+		Title:    srcfeed.Title,
+		SiteURL:  srcfeed.SiteURL,
+		FeedLink: srcfeed.FeedLink,
+		Language: srcfeed.Language,
 	}
 	for _, srcitem := range srcfeed.Items {
+		enclosures := make([]Enclosure, 0, len(srcitem.Attachments))
+		for _, a := range srcitem.Attachments {
+			enclosures = append(enclosures, Enclosure{URL: a.URL, Type: a.MimeType, Length: a.Size})
+		}
 		dstfeed.Items = append(dstfeed.Items, Item{
-			GUID:    firstNonEmpty(srcitem.ID, srcitem.URL),
-			Date:    dateParse(firstNonEmpty(srcitem.DatePublished, srcitem.DateModified)),
-			URL:     srcitem.URL,
-			Title:   srcitem.Title,
-			Content: firstNonEmpty(srcitem.HTML, srcitem.Text, srcitem.Summary),
+			GUID:     firstNonEmpty(srcitem.ID, srcitem.URL),
+			Date:     dateParse(firstNonEmpty(srcitem.DatePublished, srcitem.DateModified)),
+			Updated:  dateParse(srcitem.DateModified),
+			URL:      srcitem.URL,
+			Title:    srcitem.Title,
+			Author:   srcitem.author(),
+			Language: srcitem.Language,
+			Content:  firstNonEmpty(srcitem.HTML, srcitem.Text, srcitem.Summary),
+
+			Enclosures: enclosures,
 		})
 	}
 	return dstfeed, nil
@@ -26,8 +26,9 @@ func TestJSONFeed(t *testing.T) {
 		]
 	}`))
 	want := &Feed{
-		Title:   "My Example Feed",
-		SiteURL: "https://example.org/",
+		Title:    "My Example Feed",
+		SiteURL:  "https://example.org/",
+		FeedLink: "https://example.org/feed.json",
 		Items: []Item{
 			{GUID: "2", Content: "This is a second item.", URL: "https://example.org/second-item"},
 			{GUID: "1", Content: "<p>Hello, world!</p>", URL: "https://example.org/initial-post"},
@@ -40,3 +41,16 @@ func TestJSONFeed(t *testing.T) {
 		t.Fatal("invalid json")
 	}
 }
+
+func TestJSONFeedAuthors(t *testing.T) {
+	feed, _ := Parse(strings.NewReader(`{
+		"version": "https://jsonfeed.org/version/1.1",
+		"items": [
+			{"id": "1", "author": {"name": "John"}},
+			{"id": "2", "author": {"name": "John"}, "authors": [{"name": "Jane"}, {"name": " "}, {"name": "Jim"}]}
+		]
+	}`))
+	if len(feed.Items) != 2 || feed.Items[0].Author != "John" || feed.Items[1].Author != "Jane, Jim" {
+		t.Fatalf("invalid authors: %#v", feed.Items)
+	}
+}
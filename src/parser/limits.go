@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"errors"
+	"io"
+)
+
+// Limits applied to every parsed document. Feeds exceeding them are
+// rejected with an error instead of exhausting memory or CPU.
+var (
+	// MaxBodySize is the maximum number of (decoded) bytes read from a feed.
+	MaxBodySize int64 = 32 << 20
+
+	// MaxNestingDepth is the maximum depth of nested XML elements.
+	MaxNestingDepth = 512
+
+	// MaxAttributes is the maximum number of attributes a single XML element may have.
+	MaxAttributes = 128
+
+	// MaxEntityDeclarations is the maximum number of entities a DTD may declare.
+	MaxEntityDeclarations = 32
+)
+
+var (
+	ErrBodyTooLarge      = errors.New("feed body is too large")
+	ErrNestingTooDeep    = errors.New("feed elements are nested too deeply")
+	ErrTooManyAttributes = errors.New("feed element has too many attributes")
+	ErrTooManyEntities   = errors.New("feed declares too many entities")
+)
+
+// limitedReader behaves like io.LimitedReader, except that it fails
+// with ErrBodyTooLarge once the limit is exceeded instead of silently
+// truncating the input.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > lr.n+1 {
+		p = p[:lr.n+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.n -= int64(n)
+	if lr.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+type xmlScanState int
+
+const (
+	scanText xmlScanState = iota
+	scanMarkup
+	scanStartTag
+	scanEndTag
+	scanBang
+	scanComment
+	scanCDATA
+	scanProcInst
+	scanDirective
+)
+
+var (
+	commentStart = "--"
+	cdataStart   = "[CDATA["
+	entityDecl   = "<!ENTITY"
+)
+
+// xmlLimitReader passes the input through unchanged while tracking
+// just enough of the XML structure to enforce MaxNestingDepth,
+// MaxAttributes and MaxEntityDeclarations. Everything else is
+// left to the decoder.
+//
+// The check has to happen on the byte stream: wrapping the decoder
+// with xml.NewTokenDecoder would break `innerxml` fields.
+type xmlLimitReader struct {
+	r io.Reader
+
+	state    xmlScanState
+	depth    int
+	attrs    int
+	entities int
+	nesting  int
+	quote    byte
+	prev     byte
+	bang     []byte
+	tail     []byte
+}
+
+func newXMLLimitReader(r io.Reader) io.Reader {
+	return &xmlLimitReader{r: r}
+}
+
+func (xr *xmlLimitReader) Read(p []byte) (int, error) {
+	n, err := xr.r.Read(p)
+	for _, b := range p[:n] {
+		if scanErr := xr.scan(b); scanErr != nil {
+			return 0, scanErr
+		}
+	}
+	return n, err
+}
+
+func (xr *xmlLimitReader) scan(b byte) error {
+	switch xr.state {
+	case scanText:
+		if b == '<' {
+			xr.state = scanMarkup
+		}
+	case scanMarkup:
+		switch b {
+		case '/':
+			xr.state = scanEndTag
+			xr.depth--
+		case '?':
+			xr.state = scanProcInst
+		case '!':
+			xr.state = scanBang
+			xr.bang = xr.bang[:0]
+		default:
+			xr.state = scanStartTag
+			xr.attrs = 0
+			xr.depth++
+			if xr.depth > MaxNestingDepth {
+				return ErrNestingTooDeep
+			}
+		}
+	case scanStartTag:
+		switch {
+		case xr.quote != 0:
+			if b == xr.quote {
+				xr.quote = 0
+			}
+		case b == '"' || b == '\'':
+			xr.quote = b
+		case b == '=':
+			xr.attrs++
+			if xr.attrs > MaxAttributes {
+				return ErrTooManyAttributes
+			}
+		case b == '>':
+			if xr.prev == '/' {
+				xr.depth--
+			}
+			xr.state = scanText
+		}
+	case scanEndTag:
+		if b == '>' {
+			xr.state = scanText
+		}
+	case scanBang:
+		xr.bang = append(xr.bang, b)
+		bang := string(xr.bang)
+		switch {
+		case bang == commentStart:
+			xr.state = scanComment
+			xr.tail = xr.tail[:0]
+		case bang == cdataStart:
+			xr.state = scanCDATA
+			xr.tail = xr.tail[:0]
+		case !isPrefix(bang, commentStart) && !isPrefix(bang, cdataStart):
+			// a directive (<!DOCTYPE ...>), replay what's been consumed so far
+			xr.state = scanDirective
+			xr.nesting = 0
+			xr.tail = append(xr.tail[:0], "<!"...)
+			for _, c := range []byte(bang) {
+				if err := xr.scan(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case scanComment:
+		if xr.matchTail(b, "-->") {
+			xr.state = scanText
+		}
+	case scanCDATA:
+		if xr.matchTail(b, "]]>") {
+			xr.state = scanText
+		}
+	case scanProcInst:
+		if b == '>' && xr.prev == '?' {
+			xr.state = scanText
+		}
+	case scanDirective:
+		if xr.quote != 0 {
+			if b == xr.quote {
+				xr.quote = 0
+			}
+			break
+		}
+		if xr.matchTail(b, entityDecl) {
+			xr.entities++
+			if xr.entities > MaxEntityDeclarations {
+				return ErrTooManyEntities
+			}
+		}
+		switch b {
+		case '"', '\'':
+			xr.quote = b
+		case '<':
+			xr.nesting++
+		case '>':
+			if xr.nesting == 0 {
+				xr.state = scanText
+			} else {
+				xr.nesting--
+			}
+		}
+	}
+	xr.prev = b
+	return nil
+}
+
+// matchTail reports whether the most recent bytes (ending with b) are equal to suffix.
+func (xr *xmlLimitReader) matchTail(b byte, suffix string) bool {
+	xr.tail = append(xr.tail, b)
+	if len(xr.tail) > len(suffix) {
+		xr.tail = xr.tail[len(xr.tail)-len(suffix):]
+	}
+	return string(xr.tail) == suffix
+}
+
+func isPrefix(prefix, s string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBodyTooLarge(t *testing.T) {
+	defer func(size int64) { MaxBodySize = size }(MaxBodySize)
+	MaxBodySize = 4096
+
+	body := `<?xml version="1.0"?><rss version="2.0"><channel>` +
+		strings.Repeat(`<item><title>title</title></item>`, 200) +
+		`</channel></rss>`
+	_, err := Parse(strings.NewReader(body))
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected ErrBodyTooLarge, got: %v", err)
+	}
+
+	MaxBodySize = int64(len(body))
+	feed, err := Parse(strings.NewReader(body))
+	if err != nil || len(feed.Items) != 200 {
+		t.Fatalf("expected feed to be parsed, got: %v", err)
+	}
+}
+
+func TestParseNestingTooDeep(t *testing.T) {
+	body := `<?xml version="1.0"?><rss version="2.0"><channel><item><title>` +
+		strings.Repeat(`<x>`, MaxNestingDepth) +
+		`</title></item></channel></rss>`
+	_, err := Parse(strings.NewReader(body))
+	if !errors.Is(err, ErrNestingTooDeep) {
+		t.Fatalf("expected ErrNestingTooDeep, got: %v", err)
+	}
+}
+
+func TestParseTooManyAttributes(t *testing.T) {
+	body := `<?xml version="1.0"?><rss version="2.0"><channel><item ` +
+		strings.Repeat(`a="1" `, MaxAttributes+1) +
+		`><title>title</title></item></channel></rss>`
+	_, err := Parse(strings.NewReader(body))
+	if !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("expected ErrTooManyAttributes, got: %v", err)
+	}
+}
+
+func TestParseTooManyEntities(t *testing.T) {
+	decl := ""
+	for i := 0; i <= MaxEntityDeclarations; i++ {
+		decl += `<!ENTITY lol "lol&lol;">`
+	}
+	body := `<?xml version="1.0"?><!DOCTYPE rss [` + decl + `]><rss version="2.0"><channel></channel></rss>`
+	_, err := Parse(strings.NewReader(body))
+	if !errors.Is(err, ErrTooManyEntities) {
+		t.Fatalf("expected ErrTooManyEntities, got: %v", err)
+	}
+}
+
+func TestParseWithinLimits(t *testing.T) {
+	body := `<?xml version="1.0"?>
+		<!DOCTYPE rss [<!ENTITY copy "&#169;">]>
+		<!-- <a><b><c> -->
+		<rss version="2.0"><channel>
+			<item>
+				<title><![CDATA[<b>bold</b> & <i>]]></title>
+				<link href="http://example.com/" rel="alternate" />
+			</item>
+		</channel></rss>`
+	feed, err := Parse(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "bold &" {
+		t.Fatalf("unexpected feed: %#v", feed)
+	}
+}
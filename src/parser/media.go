@@ -8,11 +8,15 @@ type media struct {
 }
 
 type mediaGroup struct {
+	MediaContents     []mediaContent     `xml:"http://search.yahoo.com/mrss/ content"`
 	MediaThumbnails   []mediaThumbnail   `xml:"http://search.yahoo.com/mrss/ thumbnail"`
 	MediaDescriptions []mediaDescription `xml:"http://search.yahoo.com/mrss/ description"`
 }
 
 type mediaContent struct {
+	URL             string           `xml:"url,attr"`
+	Type            string           `xml:"type,attr"`
+	FileSize        string           `xml:"fileSize,attr"`
 	MediaThumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
 }
 
@@ -53,3 +57,17 @@ func (m *media) firstMediaDescription() string {
 	}
 	return ""
 }
+
+// mediaEnclosures returns the media:content elements, the ones of the
+// groups (the qualities or formats of the same media) included.
+func (m *media) mediaEnclosures() []Enclosure {
+	contents := m.MediaContents
+	for _, g := range m.MediaGroups {
+		contents = append(contents, g.MediaContents...)
+	}
+	result := make([]Enclosure, 0, len(contents))
+	for _, c := range contents {
+		result = append(result, Enclosure{URL: c.URL, Type: c.Type, Length: parseLength(c.FileSize)})
+	}
+	return result
+}
@@ -5,16 +5,90 @@ import "time"
 type Feed struct {
 	Title   string
 	SiteURL string
-	Items   []Item
+	// the url the feed says it's at (e.g. atom:link rel="self"),
+	// empty if it doesn't say
+	FeedLink string
+	// the language tag of the content (e.g. "en-US"), if the feed says so
+	Language string
+	Items    []Item
 }
 
 type Item struct {
-	GUID  string
-	Date  time.Time
-	URL   string
-	Title string
+	GUID    string
+	Date    time.Time
+	Updated time.Time
+	URL     string
+	Title   string
+	// names of the authors, comma-separated
+	Author string
+	// the language tag of the item, when it differs from the feed's
+	Language string
 
 	Content  string
 	ImageURL string
+	// the first audio enclosure, played by the UI
 	AudioURL string
+	// the media files of the item, whatever element they come from
+	Enclosures []Enclosure
+
+	Podcast *Podcast
+	// where the item is about, nil if the feed doesn't say
+	Location *Location
+	// set for the events of calendars, see ParseICS
+	Event *Event
+	// set for the items of status pages, see DetectIncidents
+	Incident *Incident
+}
+
+// Enclosure is a media file attached to an item: an RSS enclosure,
+// a media:content, an Atom enclosure link or a JSON Feed attachment.
+type Enclosure struct {
+	URL string
+	// the MIME type, empty if the feed doesn't say
+	Type string
+	// the size in bytes, 0 if the feed doesn't say
+	Length int64
+}
+
+// Event is an occurrence of a calendar event, starting at the item's Date.
+type Event struct {
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+	Place  string
+}
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Podcast struct {
+	ChaptersURL  string
+	ChaptersType string
+	Transcripts  []Transcript
+	Persons      []Person
+}
+
+type Chapter struct {
+	Start float64
+	End   float64
+	Title string
+	Image string
+	URL   string
+}
+
+type Transcript struct {
+	URL      string
+	Type     string
+	Language string
+	Rel      string
+}
+
+type Person struct {
+	Name  string
+	Role  string
+	Group string
+	Image string
+	URL   string
 }
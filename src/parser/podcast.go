@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// podcastindex.org namespace elements
+// https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md
+type podcast struct {
+	PodcastChapters    *podcastChapters    `xml:"https://podcastindex.org/namespace/1.0 chapters"`
+	PodcastTranscripts []podcastTranscript `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+	PodcastPersons     []podcastPerson     `xml:"https://podcastindex.org/namespace/1.0 person"`
+}
+
+type podcastChapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type podcastTranscript struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Language string `xml:"language,attr"`
+	Rel      string `xml:"rel,attr"`
+}
+
+type podcastPerson struct {
+	Name  string `xml:",chardata"`
+	Role  string `xml:"role,attr"`
+	Group string `xml:"group,attr"`
+	Image string `xml:"img,attr"`
+	Href  string `xml:"href,attr"`
+}
+
+func (p *podcast) podcastInfo() *Podcast {
+	if p.PodcastChapters == nil && len(p.PodcastTranscripts) == 0 && len(p.PodcastPersons) == 0 {
+		return nil
+	}
+	info := &Podcast{}
+	if p.PodcastChapters != nil {
+		info.ChaptersURL = strings.TrimSpace(p.PodcastChapters.URL)
+		info.ChaptersType = p.PodcastChapters.Type
+	}
+	for _, t := range p.PodcastTranscripts {
+		if strings.TrimSpace(t.URL) == "" {
+			continue
+		}
+		info.Transcripts = append(info.Transcripts, Transcript{
+			URL:      strings.TrimSpace(t.URL),
+			Type:     t.Type,
+			Language: t.Language,
+			Rel:      t.Rel,
+		})
+	}
+	for _, person := range p.PodcastPersons {
+		name := strings.TrimSpace(person.Name)
+		if name == "" {
+			continue
+		}
+		role := strings.ToLower(firstNonEmpty(person.Role, "host"))
+		info.Persons = append(info.Persons, Person{
+			Name:  name,
+			Role:  role,
+			Group: strings.ToLower(firstNonEmpty(person.Group, "cast")),
+			Image: strings.TrimSpace(person.Image),
+			URL:   strings.TrimSpace(person.Href),
+		})
+	}
+	return info
+}
+
+type jsonChapters struct {
+	Chapters []struct {
+		StartTime float64 `json:"startTime"`
+		EndTime   float64 `json:"endTime"`
+		Title     string  `json:"title"`
+		Image     string  `json:"img"`
+		URL       string  `json:"url"`
+		TOC       *bool   `json:"toc"`
+	} `json:"chapters"`
+}
+
+// ParseChapters parses the podcastindex json chapters format.
+// https://github.com/Podcastindex-org/podcast-namespace/blob/main/chapters/jsonChapters.md
+func ParseChapters(r io.Reader) ([]Chapter, error) {
+	var src jsonChapters
+	if err := json.NewDecoder(&limitedReader{r: r, n: MaxBodySize}).Decode(&src); err != nil {
+		return nil, err
+	}
+	chapters := make([]Chapter, 0, len(src.Chapters))
+	for _, c := range src.Chapters {
+		// chapters with `toc: false` are meant for the player only
+		if c.TOC != nil && !*c.TOC {
+			continue
+		}
+		chapters = append(chapters, Chapter{
+			Start: c.StartTime,
+			End:   c.EndTime,
+			Title: strings.TrimSpace(c.Title),
+			Image: c.Image,
+			URL:   c.URL,
+		})
+	}
+	return chapters, nil
+}
@@ -12,6 +12,7 @@ type rdfFeed struct {
 	XMLName xml.Name  `xml:"RDF"`
 	Title   string    `xml:"channel>title"`
 	Link    string    `xml:"channel>link"`
+	Lang    string    `xml:"channel>http://purl.org/dc/elements/1.1/ language"`
 	Items   []rdfItem `xml:"item"`
 }
 
@@ -20,8 +21,12 @@ type rdfItem struct {
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 
-	DublinCoreDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
-	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	DublinCoreDate     string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	DublinCoreCreator  string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	DublinCoreLanguage string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	ContentEncoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+
+	geo
 }
 
 func ParseRDF(r io.Reader) (*Feed, error) {
@@ -33,16 +38,21 @@ func ParseRDF(r io.Reader) (*Feed, error) {
 	}
 
 	dstfeed := &Feed{
-		Title:   srcfeed.Title,
-		SiteURL: srcfeed.Link,
+		Title:    srcfeed.Title,
+		SiteURL:  srcfeed.Link,
+		Language: srcfeed.Lang,
 	}
 	for _, srcitem := range srcfeed.Items {
 		dstfeed.Items = append(dstfeed.Items, Item{
-			GUID:    srcitem.Link,
-			URL:     srcitem.Link,
-			Date:    dateParse(srcitem.DublinCoreDate),
-			Title:   srcitem.Title,
-			Content: firstNonEmpty(srcitem.ContentEncoded, srcitem.Description),
+			GUID:     srcitem.Link,
+			URL:      srcitem.Link,
+			Date:     dateParse(srcitem.DublinCoreDate),
+			Title:    srcitem.Title,
+			Author:   srcitem.DublinCoreCreator,
+			Language: srcitem.DublinCoreLanguage,
+			Content:  firstNonEmpty(srcitem.ContentEncoded, srcitem.Description),
+
+			Location: srcitem.location(),
 		})
 	}
 	return dstfeed, nil
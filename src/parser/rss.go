@@ -11,29 +11,26 @@ import (
 	"strings"
 )
 
-type rssFeed struct {
-	XMLName xml.Name  `xml:"rss"`
-	Version string    `xml:"version,attr"`
-	Title   string    `xml:"channel>title"`
-	Link    string    `xml:"channel>link"`
-	Items   []rssItem `xml:"channel>item"`
-}
-
 type rssItem struct {
 	GUID        rssGuid        `xml:"guid"`
 	Title       string         `xml:"title"`
 	Link        string         `xml:"rss link"`
 	Description string         `xml:"rss description"`
 	PubDate     string         `xml:"pubDate"`
+	Author      string         `xml:"rss author"`
 	Enclosures  []rssEnclosure `xml:"enclosure"`
 
-	DublinCoreDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
-	ContentEncoded string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	DublinCoreDate     string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	DublinCoreCreator  string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	DublinCoreLanguage string `xml:"http://purl.org/dc/elements/1.1/ language"`
+	ContentEncoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
 
 	OrigLink          string `xml:"http://rssnamespace.org/feedburner/ext/1.0 origLink"`
 	OrigEnclosureLink string `xml:"http://rssnamespace.org/feedburner/ext/1.0 origEnclosureLink"`
 
 	media
+	podcast
+	geo
 }
 
 type rssGuid struct {
@@ -61,45 +58,91 @@ type rssEnclosure struct {
 }
 
 func ParseRSS(r io.Reader) (*Feed, error) {
-	srcfeed := rssFeed{}
-
-	decoder := xmlDecoder(r)
-	decoder.DefaultSpace = "rss"
-	if err := decoder.Decode(&srcfeed); err != nil {
+	dstfeed := &Feed{}
+	err := streamRSS(r, dstfeed, func(item Item) error {
+		dstfeed.Items = append(dstfeed.Items, item)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	return dstfeed, nil
+}
 
-	dstfeed := &Feed{
-		Title:   srcfeed.Title,
-		SiteURL: srcfeed.Link,
-	}
-	for _, srcitem := range srcfeed.Items {
-		podcastURL := ""
-		for _, e := range srcitem.Enclosures {
-			if strings.HasPrefix(e.Type, "audio/") {
-				podcastURL = e.URL
-
-				if srcitem.OrigEnclosureLink != "" && strings.Contains(podcastURL, path.Base(srcitem.OrigEnclosureLink)) {
-					podcastURL = srcitem.OrigEnclosureLink
+// streamRSS decodes the channel one item at a time. Channel fields
+// are stored in dstfeed as they're encountered.
+func streamRSS(r io.Reader, dstfeed *Feed, emit func(Item) error) error {
+	decoder := xmlDecoder(r)
+	decoder.DefaultSpace = "rss"
+	return streamXML(decoder, xml.Name{Local: "rss"}, func(path []string, el *xml.StartElement) (bool, error) {
+		if len(path) == 0 {
+			dstfeed.Language = xmlLang(el)
+			return false, nil
+		}
+		if len(path) != 2 || path[1] != "channel" {
+			return false, nil
+		}
+		switch el.Name.Local {
+		case "title":
+			return true, decoder.DecodeElement(&dstfeed.Title, el)
+		case "language":
+			// <language> or <dc:language>
+			return true, decoder.DecodeElement(&dstfeed.Language, el)
+		case "link":
+			if el.Name.Space == atomNS {
+				var link atomLink
+				if err := decoder.DecodeElement(&link, el); err != nil {
+					return true, err
+				}
+				if link.Rel == "self" {
+					dstfeed.FeedLink = link.Href
 				}
-				break
+				return true, nil
 			}
+			return true, decoder.DecodeElement(&dstfeed.SiteURL, el)
+		case "item":
+			var srcitem rssItem
+			if err := decoder.DecodeElement(&srcitem, el); err != nil {
+				return true, err
+			}
+			return true, emit(srcitem.item())
 		}
+		return false, nil
+	})
+}
 
-		permalink := ""
-		if srcitem.GUID.IsPermaLink == "true" {
-			permalink = srcitem.GUID.GUID
+func (srcitem *rssItem) item() Item {
+	podcastURL := ""
+	enclosures := make([]Enclosure, 0, len(srcitem.Enclosures))
+	for _, e := range srcitem.Enclosures {
+		link := e.URL
+		if srcitem.OrigEnclosureLink != "" && strings.Contains(link, path.Base(srcitem.OrigEnclosureLink)) {
+			link = srcitem.OrigEnclosureLink
 		}
+		if podcastURL == "" && strings.HasPrefix(e.Type, "audio/") {
+			podcastURL = link
+		}
+		enclosures = append(enclosures, Enclosure{URL: link, Type: e.Type, Length: parseLength(e.Length)})
+	}
 
-		dstfeed.Items = append(dstfeed.Items, Item{
-			GUID:     firstNonEmpty(srcitem.GUID.GUID, srcitem.Link),
-			Date:     dateParse(firstNonEmpty(srcitem.DublinCoreDate, srcitem.PubDate)),
-			URL:      firstNonEmpty(srcitem.OrigLink, srcitem.Link, permalink),
-			Title:    srcitem.Title,
-			Content:  firstNonEmpty(srcitem.ContentEncoded, srcitem.Description),
-			AudioURL: podcastURL,
-			ImageURL: srcitem.firstMediaThumbnail(),
-		})
+	permalink := ""
+	if srcitem.GUID.IsPermaLink == "true" {
+		permalink = srcitem.GUID.GUID
+	}
+
+	return Item{
+		GUID:     firstNonEmpty(srcitem.GUID.GUID, srcitem.Link),
+		Date:     dateParse(firstNonEmpty(srcitem.DublinCoreDate, srcitem.PubDate)),
+		URL:      firstNonEmpty(srcitem.OrigLink, srcitem.Link, permalink),
+		Title:    srcitem.Title,
+		Author:   strings.TrimSpace(firstNonEmpty(srcitem.DublinCoreCreator, srcitem.Author)),
+		Language: srcitem.DublinCoreLanguage,
+		Content:  firstNonEmpty(srcitem.ContentEncoded, srcitem.Description),
+		AudioURL: podcastURL,
+		ImageURL: srcitem.firstMediaThumbnail(),
+		Podcast:  srcitem.podcastInfo(),
+		Location: srcitem.location(),
+
+		Enclosures: append(enclosures, srcitem.mediaEnclosures()...),
 	}
-	return dstfeed, nil
 }
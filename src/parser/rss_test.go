@@ -30,8 +30,9 @@ func TestRSSFeed(t *testing.T) {
 		</rss>
 	`))
 	want := &Feed{
-		Title:   "Scripting News",
-		SiteURL: "http://www.scripting.com/",
+		Title:    "Scripting News",
+		SiteURL:  "http://www.scripting.com/",
+		Language: "en",
 		Items: []Item{
 			{
 				GUID:    "http://www.scripting.com/one/",
@@ -223,8 +224,139 @@ func TestRSSIsPermalink(t *testing.T) {
 		},
 	}
 	for i := 0; i < len(want); i++ {
-		if want[i] != have[i] {
+		if !reflect.DeepEqual(want[i], have[i]) {
 			t.Errorf("Failed to handle isPermalink\nwant: %#v\nhave: %#v\n", want[i], have[i])
 		}
 	}
 }
+
+func TestRSSAuthor(t *testing.T) {
+	feed, _ := Parse(strings.NewReader(`
+		<?xml version="1.0" encoding="UTF-8"?>
+		<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<channel>
+				<item><guid>1</guid><author>john@example.com (John)</author></item>
+				<item><guid>2</guid><author>john@example.com</author><dc:creator>Jane</dc:creator></item>
+			</channel>
+		</rss>
+	`))
+	if feed.Items[0].Author != "john@example.com (John)" || feed.Items[1].Author != "Jane" {
+		t.Fatalf("invalid authors: %#v", feed.Items)
+	}
+}
+
+func TestRSSPodcastNamespace(t *testing.T) {
+	feed, _ := Parse(strings.NewReader(`
+		<?xml version="1.0" encoding="UTF-8"?>
+		<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+			<channel>
+				<item>
+					<enclosure length="100500" type="audio/mpeg" url="http://example.com/audio.mp3"/>
+					<podcast:chapters url="http://example.com/chapters.json" type="application/json+chapters"/>
+					<podcast:transcript url="http://example.com/transcript.vtt" type="text/vtt" language="en"/>
+					<podcast:person href="http://example.com/jane" img="http://example.com/jane.jpg">Jane Doe</podcast:person>
+					<podcast:person role="Guest"> John Doe </podcast:person>
+				</item>
+				<item>
+					<enclosure length="100500" type="audio/mpeg" url="http://example.com/audio2.mp3"/>
+				</item>
+			</channel>
+		</rss>
+	`))
+	have := feed.Items[0].Podcast
+	want := &Podcast{
+		ChaptersURL:  "http://example.com/chapters.json",
+		ChaptersType: "application/json+chapters",
+		Transcripts: []Transcript{
+			{URL: "http://example.com/transcript.vtt", Type: "text/vtt", Language: "en"},
+		},
+		Persons: []Person{
+			{Name: "Jane Doe", Role: "host", Group: "cast", Image: "http://example.com/jane.jpg", URL: "http://example.com/jane"},
+			{Name: "John Doe", Role: "guest", Group: "cast"},
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Logf("want: %#v", want)
+		t.Logf("have: %#v", have)
+		t.FailNow()
+	}
+	if feed.Items[1].Podcast != nil {
+		t.Fatalf("unexpected podcast info: %#v", feed.Items[1].Podcast)
+	}
+}
+
+func TestParseChapters(t *testing.T) {
+	have, err := ParseChapters(strings.NewReader(`{
+		"version": "1.2.0",
+		"chapters": [
+			{"startTime": 0, "title": "Intro"},
+			{"startTime": 61.5, "title": "Ad", "toc": false},
+			{"startTime": 120, "endTime": 300, "title": "Topic", "url": "http://example.com/"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Start: 0, Title: "Intro"},
+		{Start: 120, End: 300, Title: "Topic", URL: "http://example.com/"},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Logf("want: %#v", want)
+		t.Logf("have: %#v", have)
+		t.FailNow()
+	}
+}
+
+func TestRSSGeo(t *testing.T) {
+	feed, _ := Parse(strings.NewReader(`
+		<?xml version="1.0" encoding="UTF-8"?>
+		<rss version="2.0"
+			xmlns:georss="http://www.georss.org/georss"
+			xmlns:gml="http://www.opengis.net/gml"
+			xmlns:geo="http://www.w3.org/2003/01/geo/wgs84_pos#">
+		<channel>
+			<item><guid>1</guid><georss:point>45.256 -71.92</georss:point></item>
+			<item><guid>2</guid><georss:where><gml:Point><gml:pos>-33.87, 151.21</gml:pos></gml:Point></georss:where></item>
+			<item><guid>3</guid><geo:lat>55.701</geo:lat><geo:long>12.552</geo:long></item>
+			<item><guid>4</guid><geo:Point><geo:lat>1.5</geo:lat><geo:long>-2.5</geo:long></geo:Point></item>
+			<item><guid>5</guid><georss:point>95 10</georss:point></item>
+			<item><guid>6</guid></item>
+		</channel>
+		</rss>
+	`))
+	want := []*Location{
+		{Latitude: 45.256, Longitude: -71.92},
+		{Latitude: -33.87, Longitude: 151.21},
+		{Latitude: 55.701, Longitude: 12.552},
+		{Latitude: 1.5, Longitude: -2.5},
+		nil,
+		nil,
+	}
+	have := make([]*Location, 0)
+	for _, item := range feed.Items {
+		have = append(have, item.Location)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Logf("want: %#v", want)
+		t.Logf("have: %#v", have)
+		t.FailNow()
+	}
+}
+
+func TestRSSSelfLink(t *testing.T) {
+	feed, _ := Parse(strings.NewReader(`
+		<?xml version="1.0"?>
+		<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+		<channel>
+			<link>http://example.com/</link>
+			<atom:link href="http://example.com/hub" rel="hub"/>
+			<atom:link href="https://example.com/feed.xml" rel="self"/>
+			<title>Title</title>
+		</channel>
+		</rss>
+	`))
+	if feed.SiteURL != "http://example.com/" || feed.FeedLink != "https://example.com/feed.xml" {
+		t.Errorf("unexpected links: %q, %q", feed.SiteURL, feed.FeedLink)
+	}
+}
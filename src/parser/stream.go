@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MaxStreamBodySize is the maximum number of (decoded) bytes read by
+// ParseStream. It's larger than MaxBodySize since streamed documents
+// are never held in memory as a whole.
+var MaxStreamBodySize int64 = 256 << 20
+
+// ParseStream parses the feed incrementally and hands the items over
+// to fn in batches of up to batchSize, each wrapped in a Feed with the
+// channel fields seen so far. The batches are fixed up the same way
+// as by ParseAndFix.
+//
+// RSS and Atom are decoded item by item. The other formats are parsed
+// as a whole (within MaxBodySize) and then split into batches.
+func ParseStream(r io.Reader, baseURL, fallbackEncoding string, batchSize int, fn func(*Feed) error) error {
+	r, probe, err := prepare(r, fallbackEncoding, MaxStreamBodySize)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	feed := &Feed{}
+	flush := func() error {
+		if len(feed.Items) == 0 {
+			return nil
+		}
+		batch := &Feed{Title: feed.Title, SiteURL: feed.SiteURL, FeedLink: feed.FeedLink, Language: feed.Language, Items: feed.Items}
+		feed.Items = nil
+		batch.cleanup()
+		batch.TranslateURLs(baseURL)
+		batch.NormalizeDates(now)
+		batch.DetectIncidents(baseURL)
+		return fn(batch)
+	}
+
+	if probe.stream == nil {
+		whole, err := probe.callback(&limitedReader{r: r, n: MaxBodySize})
+		if err != nil {
+			return err
+		}
+		feed.Title, feed.SiteURL, feed.FeedLink, feed.Language = whole.Title, whole.SiteURL, whole.FeedLink, whole.Language
+		for len(whole.Items) > 0 {
+			n := batchSize
+			if n > len(whole.Items) {
+				n = len(whole.Items)
+			}
+			feed.Items, whole.Items = whole.Items[:n], whole.Items[n:]
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = probe.stream(r, feed, func(item Item) error {
+		feed.Items = append(feed.Items, item)
+		if len(feed.Items) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// streamXML walks the document checking that the root element is the
+// expected one. Every start element below it is offered to visit along
+// with the names of its ancestors. visit reports whether it consumed
+// the element (with DecodeElement), otherwise the walk descends into it.
+// The root element is offered too (for its attributes), with no
+// ancestors, and can't be consumed.
+func streamXML(decoder *xml.Decoder, root xml.Name, visit func(path []string, el *xml.StartElement) (bool, error)) error {
+	var path []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF && path != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if path == nil {
+				if t.Name.Local != root.Local || (root.Space != "" && t.Name.Space != root.Space) {
+					return fmt.Errorf("expected element type <%s> but have <%s>", root.Local, t.Name.Local)
+				}
+				if _, err := visit(nil, &t); err != nil {
+					return err
+				}
+			} else {
+				consumed, err := visit(path, &t)
+				if err != nil {
+					return err
+				}
+				if consumed {
+					continue
+				}
+			}
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+			if len(path) == 0 {
+				// ignore whatever follows the root element, like Decode does
+				return nil
+			}
+		}
+	}
+}
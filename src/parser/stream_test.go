@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func streamBatches(t *testing.T, input string, batchSize int) [][]string {
+	var batches [][]string
+	err := ParseStream(strings.NewReader(input), "http://example.com/", "", batchSize, func(feed *Feed) error {
+		if feed.Title != "Title" {
+			t.Errorf("invalid feed title: %q", feed.Title)
+		}
+		var guids []string
+		for _, item := range feed.Items {
+			guids = append(guids, item.GUID)
+		}
+		batches = append(batches, guids)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batches
+}
+
+func TestParseStreamRSS(t *testing.T) {
+	var items strings.Builder
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&items, "<item><guid> %d </guid><title>Item %d</title></item>", i, i)
+	}
+	input := `<?xml version="1.0"?>
+		<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+		  <channel>
+		    <title>Title</title>
+		    <atom:link href="http://example.com/feed.xml" rel="self"/>
+		    ` + items.String() + `
+		  </channel>
+		</rss>`
+
+	have := fmt.Sprint(streamBatches(t, input, 2))
+	if want := "[[1 2] [3 4] [5]]"; have != want {
+		t.Fatalf("invalid batches\nwant: %s\nhave: %s", want, have)
+	}
+}
+
+func TestParseStreamAtom(t *testing.T) {
+	input := `<?xml version="1.0" encoding="utf-8"?>
+		<feed xmlns="http://www.w3.org/2005/Atom">
+		  <title>Title</title>
+		  <entry><id>1</id></entry>
+		  <entry><id>2</id></entry>
+		  <entry><id>3</id></entry>
+		</feed>`
+
+	have := fmt.Sprint(streamBatches(t, input, 3))
+	if want := "[[1 2 3]]"; have != want {
+		t.Fatalf("invalid batches\nwant: %s\nhave: %s", want, have)
+	}
+}
+
+func TestParseStreamJSON(t *testing.T) {
+	input := `{
+		"version": "https://jsonfeed.org/version/1",
+		"title": "Title",
+		"items": [{"id": "1"}, {"id": "2"}, {"id": "3"}]
+	}`
+
+	have := fmt.Sprint(streamBatches(t, input, 2))
+	if want := "[[1 2] [3]]"; have != want {
+		t.Fatalf("invalid batches\nwant: %s\nhave: %s", want, have)
+	}
+}
+
+func TestParseStreamWrongRoot(t *testing.T) {
+	input := `<feed><title>Title</title><entry><id>1</id></entry></feed>`
+	err := ParseStream(strings.NewReader(input), "", "", 10, func(*Feed) error { return nil })
+	if err == nil {
+		t.Fatal("expected an error for atom feed without namespace")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html/charset"
@@ -21,6 +22,54 @@ func firstNonEmpty(vals ...string) string {
 	return ""
 }
 
+const xmlNS = "http://www.w3.org/XML/1998/namespace"
+
+// xmlLang returns the xml:lang attribute of the element.
+func xmlLang(el *xml.StartElement) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Space == xmlNS && attr.Name.Local == "lang" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+var languageRe = regexp.MustCompile(`^[a-zA-Z]{2,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+// cleanLanguage normalizes the language tag ("en_us" becomes "en-US"),
+// returning an empty string for anything that doesn't look like one.
+func cleanLanguage(lang string) string {
+	lang = strings.ReplaceAll(strings.TrimSpace(lang), "_", "-")
+	if len(lang) > 35 || !languageRe.MatchString(lang) {
+		return ""
+	}
+	parts := strings.Split(lang, "-")
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			// a region
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			// a script
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// parseLength reads a size in bytes, 0 if it's missing or invalid.
+func parseLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
 var linkRe = regexp.MustCompile(`(https?:\/\/\S+)`)
 
 func plain2html(text string) string {
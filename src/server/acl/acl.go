@@ -0,0 +1,74 @@
+package acl
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+// ParseNetworks parses a comma-separated list of CIDRs.
+// Plain IP addresses are treated as single-host networks.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", item)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP returns the address of the client making the request.
+func ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Contains reports whether the ip belongs to any of the networks.
+func Contains(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests from clients outside of the allowed networks.
+type Middleware struct {
+	Allowed []*net.IPNet
+}
+
+func (m *Middleware) Handler(c *router.Context) {
+	if !Contains(m.Allowed, ClientIP(c.Req)) {
+		c.Out.WriteHeader(http.StatusForbidden)
+		return
+	}
+	c.Next()
+}
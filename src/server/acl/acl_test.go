@@ -0,0 +1,38 @@
+package acl
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks("192.168.0.0/16, 10.0.0.1,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(networks))
+	}
+	cases := map[string]bool{
+		"192.168.1.20": true,
+		"10.0.0.1":     true,
+		"10.0.0.2":     false,
+		"::1":          true,
+		"8.8.8.8":      false,
+	}
+	for ip, want := range cases {
+		if have := Contains(networks, net.ParseIP(ip)); have != want {
+			t.Errorf("%s: expected %v, got %v", ip, want, have)
+		}
+	}
+
+	if _, err := ParseNetworks("192.168.0.0/33"); err == nil {
+		t.Error("expected invalid cidr to fail")
+	}
+	if _, err := ParseNetworks("localhost"); err == nil {
+		t.Error("expected hostname to fail")
+	}
+	if networks, _ := ParseNetworks(""); len(networks) != 0 {
+		t.Error("expected no networks")
+	}
+}
@@ -0,0 +1,76 @@
+package acl
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+// Proxy rewrites requests coming through trusted reverse proxies, so that
+// the client address, scheme and host reflect the original request as
+// reported by the X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host
+// headers. The headers are ignored for requests from other addresses.
+type Proxy struct {
+	Trusted []*net.IPNet
+}
+
+func (p *Proxy) Handler(c *router.Context) {
+	if !Contains(p.Trusted, ClientIP(c.Req)) {
+		c.Next()
+		return
+	}
+	req := c.Req
+	if ip := p.forwardedFor(req); ip != nil {
+		req.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+	}
+	if proto := firstValue(req.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		req.URL.Scheme = proto
+	}
+	if host := firstValue(req.Header.Get("X-Forwarded-Host")); host != "" {
+		req.Host = host
+	}
+	c.Next()
+}
+
+// forwardedFor returns the right-most address in the X-Forwarded-For
+// chain that isn't a trusted proxy: everything to the left of it
+// could have been supplied by the client.
+func (p *Proxy) forwardedFor(req *http.Request) net.IP {
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	var ip net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return nil
+		}
+		if !Contains(p.Trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func firstValue(header string) string {
+	return strings.TrimSpace(strings.Split(header, ",")[0])
+}
+
+// Scheme returns the scheme the client used to make the request.
+func Scheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// BaseURL returns the absolute url of the service as seen by the client.
+func BaseURL(r *http.Request, basePath string) string {
+	return Scheme(r) + "://" + r.Host + basePath
+}
@@ -0,0 +1,44 @@
+package acl
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+func TestProxy(t *testing.T) {
+	trusted, _ := ParseNetworks("10.0.0.0/8")
+	proxy := &Proxy{Trusted: trusted}
+
+	handle := func(remoteAddr string, headers map[string]string) (ip, baseURL string) {
+		r := router.NewRouter("")
+		r.Use(proxy.Handler)
+		r.For("/", func(c *router.Context) {
+			ip = ClientIP(c.Req).String()
+			baseURL = BaseURL(c.Req, "/yarr")
+		})
+		req := httptest.NewRequest("GET", "http://internal:7070/", nil)
+		req.RemoteAddr = remoteAddr
+		for key, val := range headers {
+			req.Header.Set(key, val)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return
+	}
+
+	headers := map[string]string{
+		"X-Forwarded-For":   "6.6.6.6, 1.2.3.4, 10.0.0.2",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "rss.example.com",
+	}
+	ip, baseURL := handle("10.0.0.1:1234", headers)
+	if ip != "1.2.3.4" || baseURL != "https://rss.example.com/yarr" {
+		t.Errorf("trusted proxy: got %s %s", ip, baseURL)
+	}
+
+	ip, baseURL = handle("5.5.5.5:1234", headers)
+	if ip != "5.5.5.5" || baseURL != "http://internal:7070/yarr" {
+		t.Errorf("untrusted proxy: got %s %s", ip, baseURL)
+	}
+}
@@ -0,0 +1,362 @@
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/activitypub"
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/worker"
+)
+
+// The server follows Fediverse accounts as a single ActivityPub actor
+// (@yarr@host of PublicURL), the accounts' servers deliver their posts
+// to its inbox. It's enabled by setting PublicURL, since the remote
+// servers have to reach it.
+
+const activityPubUser = "yarr"
+
+// the largest activity accepted by the inbox
+const maxActivitySize = 1 << 20
+
+var activityPubHTTP = &http.Client{Timeout: 30 * time.Second}
+
+// actorKeys caches the public keys of the followed accounts once they've
+// verified a signature, for the deliveries not to fetch the actor each time.
+type actorKeys struct {
+	mu   sync.Mutex
+	keys map[string]actorKey
+}
+
+type actorKey struct {
+	id  string
+	key *rsa.PublicKey
+}
+
+// get returns the cached key of the actor if it has the id.
+func (k *actorKeys) get(actorID, keyID string) *rsa.PublicKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cached, ok := k.keys[actorID]; ok && cached.id == keyID {
+		return cached.key
+	}
+	return nil
+}
+
+func (k *actorKeys) set(actorID, keyID string, key *rsa.PublicKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys == nil {
+		k.keys = make(map[string]actorKey)
+	}
+	k.keys[actorID] = actorKey{id: keyID, key: key}
+}
+
+func (k *actorKeys) forget(actorID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, actorID)
+}
+
+// sameHost tells whether the urls point to the same server.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
+
+func (s *Server) actorID() string {
+	return strings.TrimSuffix(s.PublicURL, "/") + "/activitypub/actor"
+}
+
+// activityPubClient returns the client signing the requests as the server's actor.
+func (s *Server) activityPubClient(db *storage.Storage) (*activitypub.Client, error) {
+	pem, err := db.ActivityPubKey(activitypub.GenerateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := activitypub.DecodePrivateKey(pem)
+	if err != nil {
+		return nil, err
+	}
+	return &activitypub.Client{
+		HTTP:      activityPubHTTP,
+		UserAgent: "Yarr/1.0",
+		KeyID:     s.actorID() + "#main-key",
+		Key:       key,
+	}, nil
+}
+
+func writeActivity(c *router.Context, contentType string, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.Header().Set("Content-Type", contentType)
+	c.Out.WriteHeader(http.StatusOK)
+	c.Out.Write(body)
+}
+
+func (s *Server) handleWebFinger(c *router.Context) {
+	public, err := url.Parse(s.PublicURL)
+	if s.PublicURL == "" || err != nil {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	subject := "acct:" + activityPubUser + "@" + public.Host
+	if c.Req.URL.Query().Get("resource") != subject && c.Req.URL.Query().Get("resource") != s.actorID() {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeActivity(c, "application/jrd+json", map[string]interface{}{
+		"subject": subject,
+		"links": []map[string]string{
+			{"rel": "self", "type": activitypub.ContentType, "href": s.actorID()},
+		},
+	})
+}
+
+func (s *Server) handleActivityPubActor(c *router.Context) {
+	if s.PublicURL == "" {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	client, err := s.activityPubClient(s.requestDB(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	pem, err := activitypub.EncodePublicKey(&client.Key.PublicKey)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeActivity(c, activitypub.ContentType, activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                s.actorID(),
+		Type:              "Application",
+		PreferredUsername: activityPubUser,
+		Name:              "yarr",
+		Summary:           "A feed reader following the accounts its user is interested in.",
+		Inbox:             strings.TrimSuffix(s.PublicURL, "/") + "/activitypub/inbox",
+		PublicKey: activitypub.PublicKey{
+			ID:           client.KeyID,
+			Owner:        s.actorID(),
+			PublicKeyPem: pem,
+		},
+	})
+}
+
+// handleActivityPubInbox takes the activities of the followed accounts:
+// the acceptance of the follow, and their new and edited posts.
+// The rest is acknowledged and ignored.
+func (s *Server) handleActivityPubInbox(c *router.Context) {
+	if s.PublicURL == "" {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	db := s.requestDB(c)
+	body, err := io.ReadAll(io.LimitReader(c.Req.Body, maxActivitySize))
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var activity activitypub.Activity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// only the followed accounts are listened to
+	follow, err := db.GetActivityPubFollow(string(activity.Actor))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.Out.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	client, err := s.activityPubClient(db)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx := c.Req.Context()
+	owner := ""
+	cached := false
+	var fetched *rsa.PublicKey
+	keyID, err := activitypub.Verify(c.Req, body, func(keyID string) (*rsa.PublicKey, error) {
+		if key := s.actorKeys.get(follow.ActorId, keyID); key != nil {
+			owner, cached = follow.ActorId, true
+			return key, nil
+		}
+		// the request isn't authenticated yet, the key is only looked
+		// up on the followed account's server
+		if !sameHost(keyID, follow.ActorId) {
+			return nil, fmt.Errorf("%s isn't on the server of %s", keyID, follow.ActorId)
+		}
+		actor, err := client.FetchActor(ctx, strings.SplitN(keyID, "#", 2)[0])
+		if err != nil {
+			return nil, err
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, fmt.Errorf("%s isn't the key of %s", keyID, actor.ID)
+		}
+		owner = actor.ID
+		fetched, err = activitypub.DecodePublicKey(actor.PublicKey.PublicKeyPem)
+		return fetched, err
+	})
+	if err == nil && owner != follow.ActorId {
+		err = fmt.Errorf("signed by %s on behalf of %s", owner, follow.ActorId)
+	}
+	if err != nil {
+		if cached {
+			// the key may have changed, it's fetched again next time
+			s.actorKeys.forget(follow.ActorId)
+		}
+		log.Printf("Refused an activity of %s: %s", follow.ActorId, err)
+		c.Out.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if fetched != nil {
+		s.actorKeys.set(follow.ActorId, keyID, fetched)
+	}
+
+	switch activity.Type {
+	case "Accept":
+		if err := db.AcceptActivityPubFollow(follow.ActorId); err != nil {
+			log.Print(err)
+		}
+	case "Reject":
+		log.Printf("%s has rejected the follow", follow.ActorId)
+	case "Create", "Update":
+		post, id := activity.ObjectRef()
+		if post == nil && id != "" {
+			if !sameHost(id, follow.ActorId) {
+				break
+			}
+			post = &activitypub.Object{}
+			if err := client.Fetch(ctx, id, post); err != nil {
+				log.Print(err)
+				break
+			}
+		}
+		if post != nil && string(post.AttributedTo) == follow.ActorId {
+			s.storePosts(db, follow.FeedId, []activitypub.Object{*post})
+		}
+	}
+	c.Out.WriteHeader(http.StatusAccepted)
+}
+
+// storePosts adds the posts to the feed of their author, unless it's been deleted.
+func (s *Server) storePosts(db *storage.Storage, feedId int64, posts []activitypub.Object) {
+	feed, err := db.GetFeed(feedId)
+	if err != nil || feed.DeletedAt != nil {
+		return
+	}
+	items := make([]parser.Item, 0, len(posts))
+	for _, post := range posts {
+		if post.IsPost() {
+			items = append(items, post.Item(feed.Title))
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	created, err := db.CreateItems(worker.ConvertItems(items, *feed))
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if created > 0 {
+		s.newItems(feed.Id, created)
+	}
+}
+
+// followActor subscribes to the account of the handle (@user@example.com)
+// with the latest posts of its outbox to begin with.
+func (s *Server) followActor(c *router.Context, db *storage.Storage, form FeedCreateForm) {
+	client, err := s.activityPubClient(db)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Req.Context(), time.Minute)
+	defer cancel()
+
+	var actor *activitypub.Actor
+	id, err := client.Resolve(ctx, form.Url)
+	if err == nil {
+		actor, err = client.FetchActor(ctx, id)
+	}
+	if err != nil {
+		log.Printf("Failed to find the account %s: %s", form.Url, err)
+		c.JSON(http.StatusOK, map[string]string{"status": "notfound"})
+		return
+	}
+	link := string(actor.URL)
+	if !htmlutil.IsAPossibleLink(link) {
+		link = actor.ID
+	}
+	feed, err := db.CreateFeed(actor.Title(), htmlutil.ExtractText(actor.Summary), link, actor.ID, "", form.FolderID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	follow := storage.ActivityPubFollow{
+		FeedId:   feed.Id,
+		ActorId:  actor.ID,
+		Inbox:    actor.Inbox,
+		FollowId: fmt.Sprintf("%s#follows/%d", s.actorID(), feed.Id),
+	}
+	if err := db.SaveActivityPubFollow(follow); err != nil {
+		writeError(c, err)
+		return
+	}
+	err = client.Deliver(ctx, actor.Inbox, map[string]interface{}{
+		"@context": activitypub.Context,
+		"id":       follow.FollowId,
+		"type":     "Follow",
+		"actor":    s.actorID(),
+		"object":   actor.ID,
+	})
+	if err != nil {
+		// the posts won't be delivered, following again retries
+		log.Printf("Failed to follow %s: %s", actor.ID, err)
+	}
+	if posts, err := client.Outbox(ctx, actor); err != nil {
+		log.Printf("Failed to fetch the posts of %s: %s", actor.ID, err)
+	} else {
+		s.storePosts(db, feed.Id, posts)
+	}
+	s.worker.FindFeedFavicon(*feed)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"feed":   feed,
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nkanaev/yarr/src/apicheck"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+func TestAPICompliance(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	db, _ := storage.New(":memory:")
+
+	folder, _ := db.CreateFolder("folder")
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", &folder.Id)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+	feeds := []*storage.Feed{feed1, feed2}
+	// enough items to span several pages of both APIs,
+	// with dates shared by several items
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := make([]storage.Item, 0)
+	for i := 0; i < 130; i++ {
+		items = append(items, storage.Item{
+			GUID:   fmt.Sprintf("item%d", i),
+			FeedId: feeds[i%2].Id,
+			Title:  fmt.Sprintf("title%d", i),
+			Date:   date.Add(time.Hour * time.Duration(i/3)),
+		})
+	}
+	db.CreateItems(items)
+	items, _ = db.ListItems(storage.ItemFilter{}, 200, false, false)
+	for i, item := range items {
+		switch i % 5 {
+		case 1, 2:
+			db.UpdateItemStatus(item.Id, storage.READ)
+		case 3:
+			db.UpdateItemStatus(item.Id, storage.STARRED)
+		}
+	}
+
+	server := NewServer(db, "127.0.0.1:8000")
+	server.Username = "user"
+	server.Password = "pass"
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	results, err := apicheck.Run(apicheck.Config{
+		URL:      ts.URL,
+		Username: "user",
+		Password: "pass",
+		Write:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("%s: %s", result.Name, result.Err)
+		}
+	}
+}
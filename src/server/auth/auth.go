@@ -22,12 +22,13 @@ func IsAuthenticated(req *http.Request, username, password string) bool {
 	return StringsEqual(parts[1], secret(username, password))
 }
 
-func Authenticate(rw http.ResponseWriter, username, password, basepath string) {
+func Authenticate(rw http.ResponseWriter, username, password, basepath string, secure bool) {
 	http.SetCookie(rw, &http.Cookie{
 		Name:    "auth",
 		Value:   username + ":" + secret(username, password),
 		Expires: time.Now().Add(time.Hour * 24 * 7), // 1 week,
 		Path:    basepath,
+		Secure:  secure,
 	})
 }
 
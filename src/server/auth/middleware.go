@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"log"
+	"net"
 	"net/http"
 	"strings"
 
 	"github.com/nkanaev/yarr/src/assets"
+	"github.com/nkanaev/yarr/src/server/acl"
 	"github.com/nkanaev/yarr/src/server/router"
 	"github.com/nkanaev/yarr/src/storage"
 )
@@ -15,6 +18,9 @@ type Middleware struct {
 	BasePath string
 	Public   []string
 	DB       *storage.Storage
+
+	// clients from these networks don't need to log in
+	Bypass []*net.IPNet
 }
 
 func unsafeMethod(method string) bool {
@@ -28,6 +34,10 @@ func (m *Middleware) Handler(c *router.Context) {
 			return
 		}
 	}
+	if acl.Contains(m.Bypass, acl.ClientIP(c.Req)) {
+		c.Next()
+		return
+	}
 	if IsAuthenticated(c.Req, m.Username, m.Password) {
 		c.Next()
 		return
@@ -40,23 +50,31 @@ func (m *Middleware) Handler(c *router.Context) {
 		return
 	}
 
+	// the login page follows the theme
+	settings, err := m.DB.GetSettings()
+	if err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	if c.Req.Method == "POST" {
 		username := c.Req.FormValue("username")
 		password := c.Req.FormValue("password")
 		if StringsEqual(username, m.Username) && StringsEqual(password, m.Password) {
-			Authenticate(c.Out, m.Username, m.Password, m.BasePath)
+			Authenticate(c.Out, m.Username, m.Password, m.BasePath, acl.Scheme(c.Req) == "https")
 			c.Redirect(rootUrl)
 			return
 		} else {
 			c.HTML(http.StatusOK, assets.Template("login.html"), map[string]interface{}{
 				"username": username,
 				"error":    "Invalid username/password",
-		        "settings": m.DB.GetSettings(),
+				"settings": settings,
 			})
 			return
 		}
 	}
 	c.HTML(http.StatusOK, assets.Template("login.html"), map[string]interface{}{
-        "settings": m.DB.GetSettings(),
-    })
+		"settings": settings,
+	})
 }
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+// handleBackup sends a snapshot of the live database, see storage.BackupTo.
+// The snapshot is written to a temporary file first: VACUUM INTO can't
+// write to the response, and the download shouldn't hold the database.
+func (s *Server) handleBackup(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	dir, err := os.MkdirTemp("", "yarr-backup-*")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "storage.db")
+	if err := db.BackupTo(path); err != nil {
+		writeError(c, err)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	filename := "yarr-" + time.Now().Format("2006-01-02") + ".db"
+	c.Out.Header().Set("Content-Type", "application/vnd.sqlite3")
+	c.Out.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Out.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	c.Out.Header().Set("Cache-Control", "no-store")
+	c.Out.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(c.Out, file); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nkanaev/yarr/src/bridge"
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/worker"
+)
+
+var bridgeHTTP = &http.Client{Timeout: 30 * time.Second}
+
+// bridgeClient returns the client of the RSS-Bridge instance
+// of the "rss_bridge_url" setting, nil if there's none.
+func bridgeClient(db *storage.Storage) (*bridge.Client, error) {
+	val, err := db.GetSettingsValue("rss_bridge_url")
+	if err != nil {
+		return nil, err
+	}
+	link, _ := val.(string)
+	if link == "" {
+		return nil, nil
+	}
+	return &bridge.Client{HTTP: bridgeHTTP, UserAgent: "Yarr/1.0", URL: link}, nil
+}
+
+// relinkBridgedFeeds points the bridged feeds to the current instance.
+func relinkBridgedFeeds(db *storage.Storage) {
+	client, err := bridgeClient(db)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if client == nil {
+		return
+	}
+	bridges, err := db.ListFeedBridges()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, fb := range bridges {
+		if err := db.UpdateFeedLink(fb.FeedId, client.FeedURL(fb.Bridge, fb.Context, fb.Params)); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// handleBridgeList lists the bridges of the instance with their parameters
+// (GET), and subscribes to the feed of a bridge (POST).
+func (s *Server) handleBridgeList(c *router.Context) {
+	db := s.requestDB(c)
+	client, err := bridgeClient(db)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if client == nil {
+		c.JSON(http.StatusNotFound, map[string]string{"error": "no RSS-Bridge instance is set up (rss_bridge_url)"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Req.Context(), time.Minute)
+	defer cancel()
+
+	switch c.Req.Method {
+	case "GET":
+		bridges, err := client.List(ctx)
+		if err != nil {
+			log.Print(err)
+			c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bridges)
+	case "POST":
+		var form BridgeFeedForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bridges, err := client.List(ctx)
+		if err != nil {
+			log.Print(err)
+			c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		b := bridge.Find(bridges, form.Bridge)
+		if b == nil {
+			writeError(c, &storage.ValidationError{Field: "bridge", Reason: "no such bridge"})
+			return
+		}
+		if err := b.Check(form.Context, form.Params); err != nil {
+			writeError(c, &storage.ValidationError{Field: "params", Reason: err.Error()})
+			return
+		}
+		link := client.FeedURL(b.Name, form.Context, form.Params)
+		result, err := worker.DiscoverFeed(link)
+		if err != nil || result.Feed == nil {
+			log.Printf("Failed to fetch the feed of %s: %v", b.Name, err)
+			c.JSON(http.StatusOK, map[string]string{"status": "notfound"})
+			return
+		}
+		feed, err := s.subscribe(db, result.Feed, link, form.FolderID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		err = db.SaveFeedBridge(storage.FeedBridge{
+			FeedId:  feed.Id,
+			Bridge:  b.Name,
+			Context: form.Context,
+			Params:  form.Params,
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"status": "success",
+			"feed":   feed,
+		})
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleFeedBridge(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	fb, err := db.GetFeedBridge(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fb)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nkanaev/yarr/src/client"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+func TestGoClient(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Content: "<p>one</p><script>x</script>", Date: time.Now()},
+		{GUID: "2", FeedId: feed.Id, Title: "second", Date: time.Now().Add(-time.Hour)},
+	})
+
+	srv := NewServer(db, "127.0.0.1:8000")
+	srv.Username, srv.Password = "user", "pass"
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+
+	if _, err := client.New(ts.URL, "user", "wrong"); err == nil {
+		t.Fatal("expected the login to fail")
+	}
+	c, err := client.New(ts.URL, "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feeds, err := c.Feeds()
+	if err != nil || len(feeds) != 1 || feeds[0].Title != "feed" {
+		t.Fatalf("unexpected feeds: %v %v", feeds, err)
+	}
+	items, hasMore, err := c.Items(client.ItemQuery{FeedId: &feed.Id, Status: client.Unread})
+	if err != nil || hasMore || len(items) != 2 || items[0].Title != "first" {
+		t.Fatalf("unexpected items: %v %v", items, err)
+	}
+	content, err := c.ItemContent(items[0].Id)
+	if err != nil || content != "<p>one</p>" {
+		t.Fatalf("unexpected content: %q %v", content, err)
+	}
+	text, err := c.ItemText(items[0].Id)
+	if err != nil || text != "one" {
+		t.Fatalf("unexpected text: %q %v", text, err)
+	}
+
+	if err := c.SetStatus(items[0].Id, client.Starred); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := c.Stats()
+	if err != nil || len(stats) != 1 || stats[0].Unread != 1 || stats[0].Starred != 1 {
+		t.Fatalf("unexpected stats: %v %v", stats, err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/server/acl"
+	"github.com/nkanaev/yarr/src/server/auth"
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+// LiveConfig holds the settings that can be changed while the server
+// is running, see Reload.
+type LiveConfig struct {
+	Username           string
+	Password           string
+	AllowedNetworks    []*net.IPNet
+	AuthBypassNetworks []*net.IPNet
+	TrustedProxies     []*net.IPNet
+}
+
+// Reload applies the new settings to the running server and re-reads
+// the refresh rate from the database. A refresh in progress is not
+// interrupted: the new rate takes effect for the next one.
+func (s *Server) Reload(cfg LiveConfig) {
+	s.mu.Lock()
+	s.Username = cfg.Username
+	s.Password = cfg.Password
+	s.AllowedNetworks = cfg.AllowedNetworks
+	s.AuthBypassNetworks = cfg.AuthBypassNetworks
+	s.TrustedProxies = cfg.TrustedProxies
+	s.mu.Unlock()
+
+	if s.db != nil {
+		if rate, err := s.db.GetSettingsValueInt64("refresh_rate"); err != nil {
+			log.Print(err)
+		} else {
+			s.worker.SetRefreshRate(rate)
+		}
+	}
+}
+
+func (s *Server) liveConfig() LiveConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return LiveConfig{
+		Username:           s.Username,
+		Password:           s.Password,
+		AllowedNetworks:    s.AllowedNetworks,
+		AuthBypassNetworks: s.AuthBypassNetworks,
+		TrustedProxies:     s.TrustedProxies,
+	}
+}
+
+func (s *Server) authEnabled() bool {
+	cfg := s.liveConfig()
+	return cfg.Username != "" && cfg.Password != ""
+}
+
+func (s *Server) proxyMiddleware(c *router.Context) {
+	cfg := s.liveConfig()
+	if len(cfg.TrustedProxies) == 0 {
+		c.Next()
+		return
+	}
+	(&acl.Proxy{Trusted: cfg.TrustedProxies}).Handler(c)
+}
+
+func (s *Server) aclMiddleware(c *router.Context) {
+	cfg := s.liveConfig()
+	if len(cfg.AllowedNetworks) == 0 {
+		c.Next()
+		return
+	}
+	(&acl.Middleware{Allowed: cfg.AllowedNetworks}).Handler(c)
+}
+
+func (s *Server) authMiddleware(c *router.Context) {
+	cfg := s.liveConfig()
+	if cfg.Username == "" || cfg.Password == "" {
+		c.Next()
+		return
+	}
+	a := &auth.Middleware{
+		BasePath: s.BasePath,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Public:   []string{"/static", "/manifest.json", "/fever", "/opml/mail", "/activitypub", "/.well-known/webfinger"}, // browsers fetch the manifest without credentials
+		DB:       s.requestDB(c),
+		Bypass:   cfg.AuthBypassNetworks,
+	}
+	a.Handler(c)
+}
+
+func (s *Server) handleReload(c *router.Context) {
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.ReloadConfig == nil {
+		c.Out.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	if err := s.ReloadConfig(); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c.Out.WriteHeader(http.StatusNoContent)
+}
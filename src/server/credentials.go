@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// handleFeedCredentials sets the credentials the feed is fetched with.
+// The secrets are write-only: GET tells which ones are set, not what they are.
+func (s *Server) handleFeedCredentials(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "GET":
+		creds, err := db.GetFeedCredentials(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if creds == nil {
+			creds = &storage.FeedCredentials{}
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"username":     creds.Username,
+			"has_password": creds.Password != "",
+			"header_name":  creds.HeaderName,
+		})
+	case "PUT":
+		var creds storage.FeedCredentials
+		if err := json.NewDecoder(c.Req.Body).Decode(&creds); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := db.SetFeedCredentials(id, creds); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if err := db.SetFeedCredentials(id, storage.FeedCredentials{}); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
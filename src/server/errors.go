@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// writeError answers with the status code matching a storage error.
+func writeError(c *router.Context, err error) {
+	var validationErr *storage.ValidationError
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &validationErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrDuplicate):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrConstraint):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrNoSecretKey):
+		status = http.StatusConflict
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// the request was abandoned or timed out, see Server.requestDB
+		status = http.StatusServiceUnavailable
+	}
+	if status == http.StatusInternalServerError {
+		log.Print(err)
+		c.JSON(status, map[string]string{"error": "Internal server error."})
+		return
+	}
+	c.JSON(status, map[string]string{"error": err.Error()})
+}
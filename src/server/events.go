@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/server/websocket"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// event is pushed to the WebSocket clients, either
+// "items" (new items of a feed) or "stats" (the counters
+// of all feeds, same as in /api/status).
+type event struct {
+	Type   string             `json:"type"`
+	FeedId int64              `json:"feed_id,omitempty"`
+	Count  int                `json:"count,omitempty"`
+	Stats  []storage.FeedStat `json:"stats,omitempty"`
+}
+
+// events fans the events out to the connected clients.
+type events struct {
+	mu   sync.Mutex
+	subs map[chan []byte]bool
+}
+
+// the events a client may lag behind before it's disconnected
+const eventBuffer = 64
+
+func (e *events) subscribe() chan []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.subs == nil {
+		e.subs = make(map[chan []byte]bool)
+	}
+	ch := make(chan []byte, eventBuffer)
+	e.subs[ch] = true
+	return ch
+}
+
+func (e *events) unsubscribe(ch chan []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.subs[ch] {
+		delete(e.subs, ch)
+		close(ch)
+	}
+}
+
+func (e *events) active() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.subs) > 0
+}
+
+// publish never blocks: the channels of the clients which don't
+// keep up are closed instead, they're expected to reconnect and
+// fetch the current state.
+func (e *events) publish(ev event) {
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subs {
+		select {
+		case ch <- msg:
+		default:
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *Server) publishStats(db *storage.Storage) {
+	if s.events.active() {
+		stats, err := db.FeedStats()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		s.events.publish(event{Type: "stats", Stats: stats})
+	}
+}
+
+// newItems is called by the worker after storing new items of a feed.
+func (s *Server) newItems(feedId int64, count int) {
+	if s.events.active() {
+		s.events.publish(event{Type: "items", FeedId: feedId, Count: count})
+		s.publishStats(s.db)
+	}
+}
+
+// setItemStatus changes the status of the item on behalf of a client.
+func (s *Server) setItemStatus(db *storage.Storage, id int64, status storage.ItemStatus) error {
+	if err := db.UpdateItemStatus(id, status); err != nil {
+		return err
+	}
+	if status == storage.STARRED {
+		// the status is changed already, the archive is a bonus
+		if val, err := db.GetSettingsValue("archive_starred"); err != nil {
+			log.Print(err)
+		} else if enabled, _ := val.(bool); enabled {
+			go s.archiveItem(id)
+		}
+	}
+	s.publishStats(db)
+	return nil
+}
+
+// wsCommand is sent by the WebSocket clients, it's answered with
+// a "result" message carrying the same id, and an error if failed.
+type wsCommand struct {
+	Id      int64  `json:"id"`
+	Command string `json:"command"`
+	ItemId  int64  `json:"item_id"`
+}
+
+type wsResult struct {
+	Type  string `json:"type"`
+	Id    int64  `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+var wsCommandStatus = map[string]storage.ItemStatus{
+	"mark_read":   storage.READ,
+	"mark_unread": storage.UNREAD,
+	"star":        storage.STARRED,
+	"unstar":      storage.READ,
+}
+
+// clients answer the pings with pongs, so a silent
+// connection after two intervals is considered dead
+const wsPingInterval = 30 * time.Second
+
+// handleWebSocket keeps a connection open for clients which would
+// otherwise poll /api/status: the events are pushed as they happen,
+// and item statuses are changed with commands.
+func (s *Server) handleWebSocket(c *router.Context) {
+	conn, err := websocket.Upgrade(c.Out, c.Req)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadTimeout(2 * wsPingInterval)
+	db := s.requestDB(c)
+
+	sub := s.events.subscribe()
+	defer s.events.unsubscribe(sub)
+
+	stats, err := db.FeedStats()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	initial, _ := json.Marshal(event{Type: "stats", Stats: stats})
+	if err := conn.WriteMessage(initial); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg, ok := <-sub:
+				if !ok || conn.WriteMessage(msg) != nil {
+					conn.Close()
+					return
+				}
+			case <-ticker.C:
+				conn.Ping()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var cmd wsCommand
+		result := wsResult{Type: "result"}
+		if err := json.Unmarshal(msg, &cmd); err != nil {
+			result.Error = "invalid command"
+		} else if status, ok := wsCommandStatus[cmd.Command]; !ok {
+			result.Id = cmd.Id
+			result.Error = "unknown command"
+		} else {
+			result.Id = cmd.Id
+			if err := s.setItemStatus(db, cmd.ItemId, status); errors.Is(err, storage.ErrNotFound) {
+				result.Error = "item not found"
+			} else if err != nil {
+				log.Print(err)
+				result.Error = "failed to update the item"
+			}
+		}
+		reply, _ := json.Marshal(result)
+		if err := conn.WriteMessage(reply); err != nil {
+			return
+		}
+	}
+}
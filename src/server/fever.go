@@ -53,10 +53,15 @@ type FeverFavicon struct {
 	Data string `json:"data"`
 }
 
-func writeFeverJSON(c *router.Context, data map[string]interface{}, lastRefreshed int64) {
+func writeFeverJSON(c *router.Context, db *storage.Storage, data map[string]interface{}) {
+	httpStates, err := db.ListHTTPStates()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	data["api_version"] = 3
 	data["auth"] = 1
-	data["last_refreshed_on_time"] = lastRefreshed
+	data["last_refreshed_on_time"] = getLastRefreshedOnTime(httpStates)
 	c.JSON(http.StatusOK, data)
 }
 
@@ -75,10 +80,10 @@ func getLastRefreshedOnTime(httpStates map[int64]storage.HTTPState) int64 {
 }
 
 func (s *Server) feverAuth(c *router.Context) bool {
-	if s.Username != "" && s.Password != "" {
+	if cfg := s.liveConfig(); cfg.Username != "" && cfg.Password != "" {
 		apiKey := c.Req.FormValue("api_key")
 		apiKey = strings.ToLower(apiKey)
-		md5HashValue := md5.Sum([]byte(fmt.Sprintf("%s:%s", s.Username, s.Password)))
+		md5HashValue := md5.Sum([]byte(fmt.Sprintf("%s:%s", cfg.Username, cfg.Password)))
 		hexMD5HashValue := fmt.Sprintf("%x", md5HashValue[:])
 		if !auth.StringsEqual(apiKey, hexMD5HashValue) {
 			return false
@@ -95,6 +100,7 @@ func formHasValue(values url.Values, value string) bool {
 }
 
 func (s *Server) handleFever(c *router.Context) {
+	db := s.requestDB(c)
 	c.Req.ParseForm()
 	if !s.feverAuth(c) {
 		c.JSON(http.StatusOK, map[string]interface{}{
@@ -123,11 +129,7 @@ func (s *Server) handleFever(c *router.Context) {
 	case formHasValue(c.Req.Form, "mark"):
 		s.feverMarkHandler(c)
 	default:
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"api_version":            3,
-			"auth":                   1,
-			"last_refreshed_on_time": getLastRefreshedOnTime(s.db.ListHTTPStates()),
-		})
+		writeFeverJSON(c, db, map[string]interface{}{})
 	}
 }
 
@@ -142,8 +144,11 @@ func joinInts(values []int64) string {
 	return result.String()
 }
 
-func feedGroups(db *storage.Storage) []*FeverFeedsGroup {
-	feeds := db.ListFeeds()
+func feedGroups(db *storage.Storage) ([]*FeverFeedsGroup, error) {
+	feeds, err := db.ListFeeds()
+	if err != nil {
+		return nil, err
+	}
 
 	groupFeeds := make(map[int64][]int64)
 	for _, feed := range feeds {
@@ -159,24 +164,48 @@ func feedGroups(db *storage.Storage) []*FeverFeedsGroup {
 			FeedIDs: joinInts(feedIds),
 		})
 	}
-	return result
+	return result, nil
 }
 
 func (s *Server) feverGroupsHandler(c *router.Context) {
-	folders := s.db.ListFolders()
+	db := s.requestDB(c)
+	folders, err := db.ListFolders()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	groups := make([]*FeverGroup, len(folders))
 	for i, folder := range folders {
 		groups[i] = &FeverGroup{ID: folder.Id, Title: folder.Title}
 	}
-	writeFeverJSON(c, map[string]interface{}{
+	feedsGroups, err := feedGroups(db)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeFeverJSON(c, db, map[string]interface{}{
 		"groups":       groups,
-		"feeds_groups": feedGroups(s.db),
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+		"feeds_groups": feedsGroups,
+	})
 }
 
 func (s *Server) feverFeedsHandler(c *router.Context) {
-	feeds := s.db.ListFeeds()
-	httpStates := s.db.ListHTTPStates()
+	db := s.requestDB(c)
+	feeds, err := db.ListFeeds()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	feedsGroups, err := feedGroups(db)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	httpStates, err := db.ListHTTPStates()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
 	feverFeeds := make([]*FeverFeed, len(feeds))
 	for i, feed := range feeds {
@@ -194,19 +223,29 @@ func (s *Server) feverFeedsHandler(c *router.Context) {
 			LastUpdated: lastUpdated,
 		}
 	}
-	writeFeverJSON(c, map[string]interface{}{
+	writeFeverJSON(c, db, map[string]interface{}{
 		"feeds":        feverFeeds,
-		"feeds_groups": feedGroups(s.db),
-	}, getLastRefreshedOnTime(httpStates))
+		"feeds_groups": feedsGroups,
+	})
 }
 
 func (s *Server) feverFaviconsHandler(c *router.Context) {
-	feeds := s.db.ListFeeds()
+	db := s.requestDB(c)
+	feeds, err := db.ListFeeds()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	favicons := make([]*FeverFavicon, len(feeds))
 	for i, feed := range feeds {
 		data := "data:image/gif;base64,R0lGODlhAQABAAAAACw="
 		if feed.HasIcon {
-			icon := s.db.GetFeed(feed.Id).Icon
+			withIcon, err := db.GetFeed(feed.Id)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			icon := withIcon.Icon
 			data = fmt.Sprintf(
 				"data:%s;base64,%s",
 				http.DetectContentType(*icon),
@@ -216,9 +255,9 @@ func (s *Server) feverFaviconsHandler(c *router.Context) {
 		favicons[i] = &FeverFavicon{ID: feed.Id, Data: data}
 	}
 
-	writeFeverJSON(c, map[string]interface{}{
+	writeFeverJSON(c, db, map[string]interface{}{
 		"favicons": favicons,
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+	})
 }
 
 // for memory pressure reasons, we only return a limited number of items
@@ -226,6 +265,7 @@ func (s *Server) feverFaviconsHandler(c *router.Context) {
 const listLimit = 50
 
 func (s *Server) feverItemsHandler(c *router.Context) {
+	db := s.requestDB(c)
 	filter := storage.ItemFilter{}
 	query := c.Req.URL.Query()
 
@@ -250,7 +290,11 @@ func (s *Server) feverItemsHandler(c *router.Context) {
 		}
 	}
 
-	items := s.db.ListItems(filter, listLimit, true, true)
+	items, err := db.ListItems(filter, listLimit, true, true)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
 	feverItems := make([]FeverItem, len(items))
 	for i, item := range items {
@@ -278,21 +322,27 @@ func (s *Server) feverItemsHandler(c *router.Context) {
 		}
 	}
 
-	totalItems := s.db.CountItems(storage.ItemFilter{})
+	totalItems, err := db.CountItems(storage.ItemFilter{})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
-	writeFeverJSON(c, map[string]interface{}{
+	writeFeverJSON(c, db, map[string]interface{}{
 		"items":       feverItems,
 		"total_items": totalItems,
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+	})
 }
 
 func (s *Server) feverLinksHandler(c *router.Context) {
-	writeFeverJSON(c, map[string]interface{}{
+	db := s.requestDB(c)
+	writeFeverJSON(c, db, map[string]interface{}{
 		"links": make([]interface{}, 0),
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+	})
 }
 
 func (s *Server) feverUnreadItemIDsHandler(c *router.Context) {
+	db := s.requestDB(c)
 	status := storage.UNREAD
 	itemIds := make([]int64, 0)
 
@@ -300,7 +350,11 @@ func (s *Server) feverUnreadItemIDsHandler(c *router.Context) {
 		Status: &status,
 	}
 	for {
-		items := s.db.ListItems(itemFilter, listLimit, true, false)
+		items, err := db.ListItems(itemFilter, listLimit, true, false)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 		if len(items) == 0 {
 			break
 		}
@@ -309,12 +363,13 @@ func (s *Server) feverUnreadItemIDsHandler(c *router.Context) {
 		}
 		itemFilter.After = &items[len(items)-1].Id
 	}
-	writeFeverJSON(c, map[string]interface{}{
+	writeFeverJSON(c, db, map[string]interface{}{
 		"unread_item_ids": joinInts(itemIds),
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+	})
 }
 
 func (s *Server) feverSavedItemIDsHandler(c *router.Context) {
+	db := s.requestDB(c)
 	status := storage.STARRED
 	itemIds := make([]int64, 0)
 
@@ -322,7 +377,11 @@ func (s *Server) feverSavedItemIDsHandler(c *router.Context) {
 		Status: &status,
 	}
 	for {
-		items := s.db.ListItems(itemFilter, listLimit, true, false)
+		items, err := db.ListItems(itemFilter, listLimit, true, false)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 		if len(items) == 0 {
 			break
 		}
@@ -331,12 +390,13 @@ func (s *Server) feverSavedItemIDsHandler(c *router.Context) {
 		}
 		itemFilter.After = &items[len(items)-1].Id
 	}
-	writeFeverJSON(c, map[string]interface{}{
+	writeFeverJSON(c, db, map[string]interface{}{
 		"saved_item_ids": joinInts(itemIds),
-	}, getLastRefreshedOnTime(s.db.ListHTTPStates()))
+	})
 }
 
 func (s *Server) feverMarkHandler(c *router.Context) {
+	db := s.requestDB(c)
 	id, err := strconv.ParseInt(c.Req.Form.Get("id"), 10, 64)
 	if err != nil {
 		log.Print("invalid id:", err)
@@ -359,7 +419,10 @@ func (s *Server) feverMarkHandler(c *router.Context) {
 			c.Out.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		s.db.UpdateItemStatus(id, status)
+		if err := s.setItemStatus(db, id, status); err != nil {
+			writeError(c, err)
+			return
+		}
 	case "feed":
 		if c.Req.Form.Get("as") != "read" {
 			c.Out.WriteHeader(http.StatusBadRequest)
@@ -370,7 +433,11 @@ func (s *Server) feverMarkHandler(c *router.Context) {
 			before := time.Unix(x, 0)
 			markFilter.Before = &before
 		}
-		s.db.MarkItemsRead(markFilter)
+		if err := db.MarkItemsRead(markFilter); err != nil {
+			writeError(c, err)
+			return
+		}
+		s.publishStats(db)
 	case "group":
 		if c.Req.Form.Get("as") != "read" {
 			c.Out.WriteHeader(http.StatusBadRequest)
@@ -381,7 +448,11 @@ func (s *Server) feverMarkHandler(c *router.Context) {
 			before := time.Unix(x, 0)
 			markFilter.Before = &before
 		}
-		s.db.MarkItemsRead(markFilter)
+		if err := db.MarkItemsRead(markFilter); err != nil {
+			writeError(c, err)
+			return
+		}
+		s.publishStats(db)
 	default:
 		c.Out.WriteHeader(http.StatusBadRequest)
 		return
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// handleFilterList lists (GET) and adds (POST) the filters
+// acting on the new items, see storage.Filter.
+func (s *Server) handleFilterList(c *router.Context) {
+	db := s.requestDB(c)
+	switch c.Req.Method {
+	case "GET":
+		filters, err := db.ListFilters()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, filters)
+	case "POST":
+		var filter storage.Filter
+		if err := json.NewDecoder(c.Req.Body).Decode(&filter); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		created, err := db.CreateFilter(filter)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleFilter(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "PUT":
+		var filter storage.Filter
+		if err := json.NewDecoder(c.Req.Body).Decode(&filter); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		filter.Id = id
+		if err := db.UpdateFilter(filter); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if err := db.DeleteFilter(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -1,21 +1,92 @@
 package server
 
-import "github.com/nkanaev/yarr/src/storage"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/watch"
+)
 
 type ItemUpdateForm struct {
 	Status *storage.ItemStatus `json:"status,omitempty"`
 }
 
+type PlaybackUpdateForm struct {
+	Position  float64    `json:"position"`
+	Duration  float64    `json:"duration"`
+	Completed bool       `json:"completed"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type ItemSnoozeForm struct {
+	Until time.Time `json:"until"`
+}
+
+type ItemTagForm struct {
+	Tag string `json:"tag"`
+}
+
+type ItemNoteForm struct {
+	Note string `json:"note"`
+}
+
+type NotifierCreateForm struct {
+	Name   string          `json:"name"`
+	Kind   string          `json:"kind"`
+	Config json.RawMessage `json:"config"`
+}
+
 type FolderCreateForm struct {
-	Title string `json:"title"`
+	Title    string `json:"title"`
+	ParentId *int64 `json:"parent_id,omitempty"`
 }
 
 type FolderUpdateForm struct {
-	Title      *string `json:"title,omitempty"`
-	IsExpanded *bool   `json:"is_expanded,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	IsExpanded  *bool   `json:"is_expanded,omitempty"`
+	CustomOrder *string `json:"custom_order,omitempty"`
+	// the id of the new parent folder, null moves the folder to the top level
+	ParentId json.RawMessage `json:"parent_id,omitempty"`
+
+	MuteSchedule *[]storage.MutePeriod `json:"mute_schedule,omitempty"`
 }
 
 type FeedCreateForm struct {
 	Url      string `json:"url"`
 	FolderID *int64 `json:"folder_id,omitempty"`
 }
+
+type FeedsBulkForm struct {
+	FeedIds         []int64                 `json:"feed_ids"`
+	Action          storage.FeedsBulkAction `json:"action"`
+	FolderId        *int64                  `json:"folder_id,omitempty"`
+	RefreshInterval int64                   `json:"refresh_interval,omitempty"`
+	DeliveryTimes   []string                `json:"delivery_times,omitempty"`
+}
+
+type FeedOrderForm struct {
+	Feeds []storage.FeedOrder `json:"feeds"`
+}
+
+type BridgeFeedForm struct {
+	Bridge   string            `json:"bridge"`
+	Context  string            `json:"context"`
+	Params   map[string]string `json:"params"`
+	FolderID *int64            `json:"folder_id,omitempty"`
+}
+
+type WatchFeedForm struct {
+	URL      string `json:"url"`
+	Title    string `json:"title,omitempty"`
+	FolderID *int64 `json:"folder_id,omitempty"`
+	watch.Rule
+}
+
+type FolderOrderForm struct {
+	FolderIds []int64 `json:"folder_ids"`
+}
+
+type FeedTrialForm struct {
+	Keep bool `json:"keep"`
+}
@@ -24,11 +24,17 @@ func (rw *gzipResponseWriter) Write(x []byte) (int, error) {
 }
 
 func (rw *gzipResponseWriter) WriteHeader(statusCode int) {
+	// if set, it's the length of the uncompressed content
+	rw.src.Header().Del("Content-Length")
 	rw.src.WriteHeader(statusCode)
 }
 
 func Middleware(c *router.Context) {
-	if !strings.Contains(c.Req.Header.Get("Accept-Encoding"), "gzip") {
+	// caches shouldn't serve the compressed responses to everyone
+	c.Out.Header().Add("Vary", "Accept-Encoding")
+
+	// upgraded connections (see the websocket package) need the original writer
+	if !strings.Contains(c.Req.Header.Get("Accept-Encoding"), "gzip") || c.Req.Header.Get("Upgrade") != "" {
 		c.Next()
 		return
 	}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/server/acl"
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/server/websocket"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/tracing"
+	"github.com/nkanaev/yarr/src/worker"
+)
+
+type routeStats struct {
+	Requests int64         `json:"requests"`
+	Queries  int64         `json:"queries"`
+	Duration time.Duration `json:"duration_ns"`
+	// the most queries a single request has made
+	MaxQueries int64 `json:"max_queries"`
+}
+
+type metrics struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+func (m *metrics) record(route string, stats storage.QueryStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routes == nil {
+		m.routes = make(map[string]*routeStats)
+	}
+	r := m.routes[route]
+	if r == nil {
+		r = &routeStats{}
+		m.routes[route] = r
+	}
+	r.Requests++
+	r.Queries += stats.Queries
+	r.Duration += stats.Duration
+	if stats.Queries > r.MaxQueries {
+		r.MaxQueries = stats.Queries
+	}
+}
+
+func (m *metrics) snapshot() map[string]routeStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]routeStats, len(m.routes))
+	for route, stats := range m.routes {
+		result[route] = *stats
+	}
+	return result
+}
+
+// timingWriter adds the database stats of the request as a
+// Server-Timing header right before the response headers are sent.
+type timingWriter struct {
+	http.ResponseWriter
+
+	tracker     *storage.QueryTracker
+	wroteHeader bool
+	status      int
+}
+
+func (w *timingWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = statusCode
+		stats := w.tracker.Stats()
+		w.Header().Set("Server-Timing", fmt.Sprintf(
+			`db;desc="queries: %d";dur=%.3f`,
+			stats.Queries, float64(stats.Duration)/float64(time.Millisecond),
+		))
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (s *Server) queryMetrics(c *router.Context) {
+	route := c.Req.Method + " " + c.Pattern
+	ctx, span := tracing.StartRemote(c.Req, route)
+	defer span.End()
+
+	// the writer of upgraded connections has to stay hijackable
+	if s.db == nil || websocket.IsUpgrade(c.Req) {
+		c.Req = c.Req.WithContext(ctx)
+		c.Next()
+		return
+	}
+
+	// only the queries of the storages derived from the request's
+	// context (see Server.requestDB) are accounted to the tracker
+	tracker := &storage.QueryTracker{}
+	c.Req = c.Req.WithContext(storage.TrackQueries(ctx, tracker))
+
+	out := &timingWriter{ResponseWriter: c.Out, tracker: tracker}
+	c.Out = out
+	c.Next()
+
+	stats := tracker.Stats()
+	s.metrics.record(route, stats)
+	span.SetAttr("http.method", c.Req.Method)
+	span.SetAttr("http.route", c.Pattern)
+	span.SetAttr("http.status_code", out.status)
+	span.SetAttr("http.client_ip", acl.ClientIP(c.Req).String())
+	span.SetAttr("db.queries", stats.Queries)
+	if out.status >= 500 {
+		span.SetError(fmt.Errorf("status code %d", out.status))
+	}
+}
+
+func (s *Server) handleMetrics(c *router.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"db":       s.db.QueryStats(),
+		"routes":   s.metrics.snapshot(),
+		"fetch":    worker.FetchStats(),
+		"pipeline": worker.PipelineStats(),
+	})
+}
+
+// handleBacklogMetrics returns the daily unread items of the last days
+// (30 by default), of all feeds or of a folder with its subfolders.
+func (s *Server) handleBacklogMetrics(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	days := int64(30)
+	if n, err := c.QueryInt64("days"); err == nil && n > 0 {
+		days = n
+	}
+	var folderId *int64
+	if id, err := c.QueryInt64("folder_id"); err == nil {
+		folderId = &id
+	}
+	loc, err := db.Location()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -int(days-1))
+	backlog, err := db.ListBacklog(folderId, since)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"days":     days,
+		"timezone": loc.String(),
+		"backlog":  backlog,
+	})
+}
+
+// handleReadingMetrics returns the items read per day and per feed over
+// the last days (30 by default), of all feeds or of a folder.
+func (s *Server) handleReadingMetrics(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	days := int64(30)
+	if n, err := c.QueryInt64("days"); err == nil && n > 0 {
+		days = n
+	}
+	var folderId *int64
+	if id, err := c.QueryInt64("folder_id"); err == nil {
+		folderId = &id
+	}
+	loc, err := db.Location()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	since := time.Now().AddDate(0, 0, -int(days-1))
+	history, err := db.ReadingHistory(folderId, since)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	feeds, err := db.ReadingByFeed(folderId, since)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"days":     days,
+		"timezone": loc.String(),
+		"history":  history,
+		"feeds":    feeds,
+	})
+}
@@ -0,0 +1,191 @@
+package opml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParseAny parses the subscriptions exported by another reader: an OPML
+// file, or the bookmarks of a browser with the built-in feed reader
+// (Firefox's "Live Bookmarks") as a bookmarks HTML file or a JSON backup.
+func ParseAny(r io.Reader) (Folder, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(1024)
+	head = bytes.TrimLeft(head, "\ufeff \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("{")):
+		return ParseBookmarksJSON(br)
+	case bytes.Contains(bytes.ToUpper(head), []byte("NETSCAPE-BOOKMARK-FILE")):
+		return ParseBookmarksHTML(br)
+	}
+	return Parse(br)
+}
+
+// ParseBookmarksHTML reads the live bookmarks (the links with the FEEDURL
+// attribute) of a bookmarks file in the Netscape format, exported by browsers.
+// The folders are kept, except for the ones without any feeds.
+func ParseBookmarksHTML(r io.Reader) (Folder, error) {
+	stack := []bookmarkLevel{{}}
+	var pending *bookmarkLevel
+	var title *strings.Builder
+	var feed *Feed
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				for len(stack) > 1 {
+					stack = closeLevel(stack)
+				}
+				return prune(stack[0].folder), nil
+			}
+			return Folder{}, z.Err()
+		case html.TextToken:
+			if title != nil {
+				title.Write(z.Text())
+			}
+		case html.StartTagToken:
+			token := z.Token()
+			switch token.DataAtom {
+			case atom.H3:
+				pending = &bookmarkLevel{flatten: attr(token, "personal_toolbar_folder") == "true"}
+				title = &strings.Builder{}
+			case atom.A:
+				if feedUrl := attr(token, "feedurl"); feedUrl != "" {
+					feed = &Feed{FeedUrl: feedUrl, SiteUrl: attr(token, "href")}
+					title = &strings.Builder{}
+				}
+			case atom.Dl:
+				if pending == nil {
+					// the top level list
+					pending = &bookmarkLevel{flatten: true}
+				}
+				stack = append(stack, *pending)
+				pending = nil
+			}
+		case html.EndTagToken:
+			token := z.Token()
+			switch token.DataAtom {
+			case atom.H3:
+				if pending != nil && title != nil {
+					pending.folder.Title = strings.TrimSpace(title.String())
+				}
+				title = nil
+			case atom.A:
+				if feed != nil && title != nil {
+					feed.Title = strings.TrimSpace(title.String())
+					top := &stack[len(stack)-1].folder
+					top.Feeds = append(top.Feeds, *feed)
+				}
+				feed, title = nil, nil
+			case atom.Dl:
+				if len(stack) > 1 {
+					stack = closeLevel(stack)
+				}
+			}
+		}
+	}
+}
+
+type bookmarkLevel struct {
+	folder Folder
+	// the browser's own folders, e.g. the toolbar, aren't kept
+	flatten bool
+}
+
+// closeLevel adds the innermost folder to its parent.
+func closeLevel(stack []bookmarkLevel) []bookmarkLevel {
+	last := stack[len(stack)-1]
+	stack = stack[:len(stack)-1]
+	parent := &stack[len(stack)-1].folder
+	if last.flatten {
+		parent.Feeds = append(parent.Feeds, last.folder.Feeds...)
+		parent.Folders = append(parent.Folders, last.folder.Folders...)
+	} else {
+		parent.Folders = append(parent.Folders, last.folder)
+	}
+	return stack
+}
+
+type bookmarkNode struct {
+	Title    string         `json:"title"`
+	Root     string         `json:"root"`
+	Children []bookmarkNode `json:"children"`
+	Annos    []struct {
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	} `json:"annos"`
+}
+
+func (n bookmarkNode) anno(name string) string {
+	for _, a := range n.Annos {
+		if a.Name == name {
+			if value, ok := a.Value.(string); ok {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// ParseBookmarksJSON reads the live bookmarks of a Firefox bookmarks backup.
+func ParseBookmarksJSON(r io.Reader) (Folder, error) {
+	var root bookmarkNode
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return Folder{}, err
+	}
+	var walk func(n bookmarkNode) Folder
+	walk = func(n bookmarkNode) Folder {
+		folder := Folder{Title: n.Title}
+		for _, child := range n.Children {
+			if feedUrl := child.anno("livemark/feedURI"); feedUrl != "" {
+				folder.Feeds = append(folder.Feeds, Feed{
+					Title:   child.Title,
+					FeedUrl: feedUrl,
+					SiteUrl: child.anno("livemark/siteURI"),
+				})
+			} else if len(child.Children) > 0 {
+				sub := walk(child)
+				if child.Root != "" {
+					folder.Feeds = append(folder.Feeds, sub.Feeds...)
+					folder.Folders = append(folder.Folders, sub.Folders...)
+				} else {
+					folder.Folders = append(folder.Folders, sub)
+				}
+			}
+		}
+		return folder
+	}
+	result := walk(root)
+	result.Title = ""
+	return prune(result), nil
+}
+
+// prune drops the folders without any feeds.
+func prune(f Folder) Folder {
+	folders := f.Folders
+	f.Folders = nil
+	for _, sub := range folders {
+		if sub = prune(sub); len(sub.Feeds) > 0 || len(sub.Folders) > 0 {
+			f.Folders = append(f.Folders, sub)
+		}
+	}
+	return f
+}
+
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
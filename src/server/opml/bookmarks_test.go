@@ -0,0 +1,86 @@
+package opml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBookmarksHTML(t *testing.T) {
+	have, err := ParseAny(strings.NewReader(`
+		<!DOCTYPE NETSCAPE-Bookmark-file-1>
+		<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+		<TITLE>Bookmarks</TITLE>
+		<H1>Bookmarks Menu</H1>
+		<DL><p>
+			<DT><H3 PERSONAL_TOOLBAR_FOLDER="true">Bookmarks Toolbar</H3>
+			<DL><p>
+				<DT><A HREF="https://foo.com/" FEEDURL="https://foo.com/feed.xml">Foo &amp; co</A>
+				<DT><A HREF="https://example.com/">not a feed</A>
+			</DL><p>
+			<DT><H3>News</H3>
+			<DL><p>
+				<DT><A HREF="https://bar.com/" FEEDURL="https://bar.com/rss">Bar</A>
+				<DT><H3>Empty</H3>
+				<DL><p>
+					<DT><A HREF="https://example.com/">not a feed</A>
+				</DL><p>
+			</DL><p>
+		</DL><p>
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Folder{
+		Feeds: []Feed{
+			{Title: "Foo & co", FeedUrl: "https://foo.com/feed.xml", SiteUrl: "https://foo.com/"},
+		},
+		Folders: []Folder{
+			{
+				Title: "News",
+				Feeds: []Feed{{Title: "Bar", FeedUrl: "https://bar.com/rss", SiteUrl: "https://bar.com/"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("invalid folder\nwant: %#v\nhave: %#v", want, have)
+	}
+}
+
+func TestParseBookmarksJSON(t *testing.T) {
+	have, err := ParseAny(strings.NewReader(`{
+		"title": "", "root": "placesRoot", "type": "text/x-moz-place-container",
+		"children": [
+			{"title": "menu", "root": "bookmarksMenuFolder", "children": [
+				{"title": "News", "children": [
+					{"title": "Bar", "annos": [
+						{"name": "livemark/feedURI", "value": "https://bar.com/rss"},
+						{"name": "livemark/siteURI", "value": "https://bar.com/"}
+					]},
+					{"title": "not a feed", "uri": "https://example.com/"}
+				]}
+			]},
+			{"title": "toolbar", "root": "toolbarFolder", "children": [
+				{"title": "Foo", "annos": [{"name": "livemark/feedURI", "value": "https://foo.com/feed.xml"}]}
+			]},
+			{"title": "unfiled", "root": "unfiledBookmarksFolder", "children": [
+				{"title": "Empty", "children": [{"title": "not a feed", "uri": "https://example.com/"}]}
+			]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Folder{
+		Feeds: []Feed{{Title: "Foo", FeedUrl: "https://foo.com/feed.xml"}},
+		Folders: []Folder{
+			{
+				Title: "News",
+				Feeds: []Feed{{Title: "Bar", FeedUrl: "https://bar.com/rss", SiteUrl: "https://bar.com/"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("invalid folder\nwant: %#v\nhave: %#v", want, have)
+	}
+}
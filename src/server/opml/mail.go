@@ -0,0 +1,104 @@
+package opml
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path"
+	"strings"
+)
+
+// MaxMailParts is the maximum number of MIME parts inspected in a message.
+var MaxMailParts = 64
+
+var ErrNoAttachment = errors.New("message has no opml attachment")
+
+// ParseMail reads an email message (RFC 5322, as relayed by email
+// gateways) and parses its OPML attachments.
+func ParseMail(r io.Reader) ([]Folder, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Folder, 0)
+	parts := 0
+	err = walkPart(msg.Header, msg.Body, &parts, func(doc io.Reader) error {
+		folder, err := Parse(doc)
+		if err != nil {
+			return err
+		}
+		result = append(result, folder)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrNoAttachment
+	}
+	return result, nil
+}
+
+// header is either a mail.Header or a textproto.MIMEHeader.
+type header interface {
+	Get(key string) string
+}
+
+func walkPart(h header, body io.Reader, parts *int, onOPML func(io.Reader) error) error {
+	*parts++
+	if *parts > MaxMailParts {
+		return errors.New("message has too many parts")
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(part.Header, part, parts, onOPML); err != nil {
+				return err
+			}
+		}
+	}
+	if !isOPMLPart(h, mediaType, params) {
+		return nil
+	}
+	// multipart.Part decodes quoted-printable by itself and drops the header
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	return onOPML(body)
+}
+
+func isOPMLPart(h header, mediaType string, params map[string]string) bool {
+	switch mediaType {
+	case "text/x-opml", "text/x-opml+xml", "application/x-opml", "application/x-opml+xml":
+		return true
+	}
+	filename := params["name"]
+	if _, dispParams, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && dispParams["filename"] != "" {
+		filename = dispParams["filename"]
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".opml":
+		return true
+	case ".xml":
+		return mediaType == "text/xml" || mediaType == "application/xml" || mediaType == "application/octet-stream"
+	}
+	return false
+}
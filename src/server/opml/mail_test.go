@@ -0,0 +1,46 @@
+package opml
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseMail(t *testing.T) {
+	doc := `<?xml version="1.0"?><opml version="1.1"><body>` +
+		`<outline type="rss" text="foo" xmlUrl="https://foo.com/feed.xml"/>` +
+		`</body></opml>`
+	msg := strings.Join([]string{
+		"From: me@example.com",
+		"To: yarr@example.com",
+		"Subject: subscriptions",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: text/plain",
+		"",
+		"see attached",
+		"--b1",
+		`Content-Type: application/octet-stream; name="feeds.opml"`,
+		"Content-Transfer-Encoding: base64",
+		`Content-Disposition: attachment; filename="feeds.opml"`,
+		"",
+		base64.StdEncoding.EncodeToString([]byte(doc)),
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	folders, err := ParseMail(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 1 || len(folders[0].Feeds) != 1 || folders[0].Feeds[0].FeedUrl != "https://foo.com/feed.xml" {
+		t.Fatalf("unexpected result: %#v", folders)
+	}
+
+	plain := "From: me@example.com\r\nContent-Type: text/plain\r\n\r\nno attachment\r\n"
+	if _, err := ParseMail(strings.NewReader(plain)); err != ErrNoAttachment {
+		t.Fatalf("expected ErrNoAttachment, got %v", err)
+	}
+}
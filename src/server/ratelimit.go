@@ -0,0 +1,153 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/server/acl"
+	"github.com/nkanaev/yarr/src/server/auth"
+	"github.com/nkanaev/yarr/src/server/router"
+)
+
+// the least recently seen clients are forgotten past this number
+const maxTrackedClients = 1000
+
+type clientStats struct {
+	Client    string    `json:"client"`
+	Requests  int64     `json:"requests"`
+	Limited   int64     `json:"limited"`
+	LastSeen  time.Time `json:"last_seen"`
+	UserAgent string    `json:"user_agent"`
+}
+
+type clientState struct {
+	clientStats
+
+	// token bucket holding up to a minute worth of requests
+	tokens  float64
+	updated time.Time
+}
+
+type clientUsage struct {
+	mu      sync.Mutex
+	clients map[string]*clientState
+}
+
+// apiClient names the client making an API request by the way it
+// authenticates and its credentials: the user the Fever API key or the
+// web session belongs to. The requests without valid credentials (auth
+// disabled, a bypassed network, a wrong Fever key) go by their address.
+func (s *Server) apiClient(c *router.Context) (string, bool) {
+	path := strings.TrimPrefix(c.Req.URL.Path, s.BasePath)
+	cfg := s.liveConfig()
+	hasAuth := cfg.Username != "" && cfg.Password != ""
+	switch {
+	case strings.HasPrefix(path, "/fever/"):
+		if hasAuth && s.feverAuth(c) {
+			return "fever " + cfg.Username, true
+		}
+		return "fever " + acl.ClientIP(c.Req).String(), true
+	case strings.HasPrefix(path, "/api/"):
+		if hasAuth && auth.IsAuthenticated(c.Req, cfg.Username, cfg.Password) {
+			return "web " + cfg.Username, true
+		}
+		return "web " + acl.ClientIP(c.Req).String(), true
+	}
+	return "", false
+}
+
+// allow records the request and reports whether the client is within
+// the limit of requests per minute (unlimited if zero). If not, it returns
+// how long until the next request is allowed.
+func (u *clientUsage) allow(client, userAgent string, limit int64, now time.Time) (bool, time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.clients == nil {
+		u.clients = make(map[string]*clientState)
+	}
+	state := u.clients[client]
+	if state == nil {
+		if len(u.clients) >= maxTrackedClients {
+			u.forgetOldest()
+		}
+		state = &clientState{
+			clientStats: clientStats{Client: client},
+			tokens:      float64(limit),
+			updated:     now,
+		}
+		u.clients[client] = state
+	}
+	state.Requests++
+	state.LastSeen = now
+	state.UserAgent = userAgent
+	if limit <= 0 {
+		return true, 0
+	}
+
+	perSecond := float64(limit) / 60
+	state.tokens = math.Min(float64(limit), state.tokens+now.Sub(state.updated).Seconds()*perSecond)
+	state.updated = now
+	if state.tokens < 1 {
+		state.Limited++
+		wait := time.Duration((1 - state.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	state.tokens--
+	return true, 0
+}
+
+func (u *clientUsage) forgetOldest() {
+	var oldest *clientState
+	for _, state := range u.clients {
+		if oldest == nil || state.LastSeen.Before(oldest.LastSeen) {
+			oldest = state
+		}
+	}
+	if oldest != nil {
+		delete(u.clients, oldest.Client)
+	}
+}
+
+// snapshot returns the clients, the busiest first.
+func (u *clientUsage) snapshot() []clientStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	result := make([]clientStats, 0, len(u.clients))
+	for _, state := range u.clients {
+		result = append(result, state.clientStats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Client < result[j].Client
+	})
+	return result
+}
+
+func (s *Server) rateLimitMiddleware(c *router.Context) {
+	client, ok := s.apiClient(c)
+	if !ok {
+		c.Next()
+		return
+	}
+	allowed, wait := s.clients.allow(client, c.Req.UserAgent(), s.RateLimit, time.Now())
+	if !allowed {
+		c.Out.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, map[string]string{"error": "too many requests"})
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) handleClientMetrics(c *router.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"limit":   s.RateLimit,
+		"clients": s.clients.snapshot(),
+	})
+}
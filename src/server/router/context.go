@@ -15,6 +15,9 @@ type Context struct {
 
 	Vars map[string]string
 
+	// the route the request was matched against, e.g. "/api/items/:id"
+	Pattern string
+
 	chain []Handler
 	index int
 }
@@ -37,6 +40,8 @@ func (c *Context) JSON(status int, data interface{}) {
 
 func (c *Context) HTML(status int, tmpl *template.Template, data interface{}) {
 	c.Out.Header().Set("Content-Type", "text/html")
+	// the pages link the assets by the hash of their content
+	c.Out.Header().Set("Cache-Control", "no-cache")
 	c.Out.WriteHeader(status)
 	tmpl.Execute(c.Out, data)
 }
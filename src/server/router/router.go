@@ -15,6 +15,7 @@ type Router struct {
 }
 
 type Route struct {
+	path  string
 	regex *regexp.Regexp
 	chain []Handler
 }
@@ -37,6 +38,7 @@ func (r *Router) For(path string, handler Handler) {
 	chain = append(chain, handler)
 
 	x := Route{}
+	x.path = path
 	x.regex = routeRegexp(path)
 	x.chain = chain
 	r.routes = append(r.routes, x)
@@ -76,6 +78,7 @@ func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	context.Req = req
 	context.Out = rw
 	context.Vars = regexGroups(path, route.regex)
+	context.Pattern = route.path
 	context.index = -1
 	context.chain = route.chain
 	context.Next()
@@ -3,19 +3,27 @@ package server
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"path/filepath"
-	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/nkanaev/yarr/src/activitypub"
 	"github.com/nkanaev/yarr/src/assets"
 	"github.com/nkanaev/yarr/src/content/htmlutil"
 	"github.com/nkanaev/yarr/src/content/readability"
 	"github.com/nkanaev/yarr/src/content/sanitizer"
 	"github.com/nkanaev/yarr/src/content/silo"
+	"github.com/nkanaev/yarr/src/forge"
+	"github.com/nkanaev/yarr/src/notify"
+	"github.com/nkanaev/yarr/src/parser"
 	"github.com/nkanaev/yarr/src/server/auth"
 	"github.com/nkanaev/yarr/src/server/gzip"
 	"github.com/nkanaev/yarr/src/server/opml"
@@ -27,46 +35,102 @@ import (
 func (s *Server) handler() http.Handler {
 	r := router.NewRouter(s.BasePath)
 
+	r.Use(s.proxyMiddleware)
+	r.Use(s.queryMetrics)
+	r.Use(s.aclMiddleware)
 	r.Use(gzip.Middleware)
-
-	if s.Username != "" && s.Password != "" {
-		a := &auth.Middleware{
-			BasePath: s.BasePath,
-			Username: s.Username,
-			Password: s.Password,
-			Public:   []string{"/static", "/fever"},
-			DB:       s.db,
-		}
-		r.Use(a.Handler)
-	}
+	r.Use(s.authMiddleware)
+	r.Use(s.rateLimitMiddleware)
 
 	r.For("/", s.handleIndex)
 	r.For("/manifest.json", s.handleManifest)
+	r.For("/sw.js", s.handleServiceWorker)
 	r.For("/static/*path", s.handleStatic)
 	r.For("/api/status", s.handleStatus)
+	r.For("/api/metrics", s.handleMetrics)
+	r.For("/api/metrics/clients", s.handleClientMetrics)
+	r.For("/api/metrics/backlog", s.handleBacklogMetrics)
+	r.For("/api/metrics/reading", s.handleReadingMetrics)
+	r.For("/api/ws", s.handleWebSocket)
+	r.For("/api/reload", s.handleReload)
 	r.For("/api/folders", s.handleFolderList)
+	r.For("/api/folders/order", s.handleFolderOrder)
 	r.For("/api/folders/:id", s.handleFolder)
 	r.For("/api/feeds", s.handleFeedList)
 	r.For("/api/feeds/refresh", s.handleFeedRefresh)
 	r.For("/api/feeds/errors", s.handleFeedErrors)
+	r.For("/api/feeds/bandwidth", s.handleFeedBandwidth)
+	r.For("/api/feeds/suggestions", s.handleFeedSuggestionList)
+	r.For("/api/feeds/suggestions/:id", s.handleFeedSuggestion)
+	r.For("/api/feeds/bulk", s.handleFeedsBulk)
+	r.For("/api/feeds/order", s.handleFeedOrder)
+	r.For("/api/feeds/trials", s.handleFeedTrialList)
+	r.For("/api/feeds/trials/:id", s.handleFeedTrial)
+	r.For("/api/feeds/deleted", s.handleDeletedFeedList)
+	r.For("/api/feeds/deleted/:id", s.handleDeletedFeed)
 	r.For("/api/feeds/:id/icon", s.handleFeedIcon)
+	r.For("/api/feeds/:id/diff", s.handleFeedDiff)
+	r.For("/api/feeds/:id/errors", s.handleFeedErrorHistory)
+	r.For("/api/feeds/:id/stats", s.handleFeedStats)
+	r.For("/api/feeds/:id/bridge", s.handleFeedBridge)
+	r.For("/api/feeds/:id/credentials", s.handleFeedCredentials)
+	r.For("/api/feeds/:id/watch", s.handleFeedWatch)
+	r.For("/api/feeds/:id/rules", s.handleFeedRuleList)
 	r.For("/api/feeds/:id", s.handleFeed)
+	r.For("/api/bridges", s.handleBridgeList)
+	r.For("/api/watches", s.handleWatchCreate)
+	r.For("/api/rules/:id", s.handleFeedRule)
 	r.For("/api/items", s.handleItemList)
+	r.For("/api/items/states", s.handleItemStates)
+	r.For("/api/items/bundle", s.handleItemBundle)
 	r.For("/api/items/:id", s.handleItem)
+	r.For("/api/items/:id/playback", s.handleItemPlayback)
+	r.For("/api/items/:id/chapters", s.handleItemChapters)
+	r.For("/api/items/:id/download", s.handleItemDownload)
+	r.For("/api/items/:id/enclosure", s.handleItemEnclosure)
+	r.For("/api/items/:id/snapshot", s.handleItemSnapshot)
+	r.For("/api/items/:id/snooze", s.handleItemSnooze)
+	r.For("/api/items/:id/tags", s.handleItemTags)
+	r.For("/api/items/:id/note", s.handleItemNote)
+	r.For("/api/items/:id/content", s.handleItemContent)
+	r.For("/api/archive", s.handleArchiveList)
+	r.For("/api/archive/:id", s.handleArchivedItem)
+	r.For("/api/tags", s.handleTagList)
+	r.For("/api/searches", s.handleSavedSearchList)
+	r.For("/api/searches/:id", s.handleSavedSearch)
+	r.For("/api/downloads", s.handleDownloadList)
 	r.For("/api/settings", s.handleSettings)
+	r.For("/api/backup", s.handleBackup)
+	r.For("/api/notifiers", s.handleNotifierList)
+	r.For("/api/notifiers/:id", s.handleNotifier)
+	r.For("/api/notifiers/:id/test", s.handleNotifierTest)
+	r.For("/api/filters", s.handleFilterList)
+	r.For("/api/filters/:id", s.handleFilter)
+	r.For("/api/notifications/routes", s.handleNotificationRouteList)
+	r.For("/api/notifications/routes/:id", s.handleNotificationRoute)
 	r.For("/opml/import", s.handleOPMLImport)
 	r.For("/opml/export", s.handleOPMLExport)
+	r.For("/opml/mail", s.handleOPMLMail)
 	r.For("/page", s.handlePageCrawl)
 	r.For("/logout", s.handleLogout)
 	r.For("/fever/", s.handleFever)
+	r.For("/.well-known/webfinger", s.handleWebFinger)
+	r.For("/activitypub/actor", s.handleActivityPubActor)
+	r.For("/activitypub/inbox", s.handleActivityPubInbox)
 
 	return r
 }
 
 func (s *Server) handleIndex(c *router.Context) {
+	db := s.requestDB(c)
+	settings, err := db.GetSettings()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	c.HTML(http.StatusOK, assets.Template("index.html"), map[string]interface{}{
-		"settings":      s.db.GetSettings(),
-		"authenticated": s.Username != "" && s.Password != "",
+		"settings":      settings,
+		"authenticated": s.authEnabled(),
 	})
 }
 
@@ -77,7 +141,31 @@ func (s *Server) handleStatic(c *router.Context) {
 		c.Out.WriteHeader(http.StatusNotFound)
 		return
 	}
-	http.StripPrefix(s.BasePath+"/static/", http.FileServer(http.FS(assets.FS))).ServeHTTP(c.Out, c.Req)
+	// the hashed names (see assets.Hashed) change along with the content
+	path, hash := assets.Unhashed(c.Vars["path"])
+	current, err := assets.Hash(path)
+	if err != nil {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	file, err := assets.FS.Open(path)
+	if err != nil {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if hash == current {
+		c.Out.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Out.Header().Set("Cache-Control", "no-cache")
+	}
+	c.Out.Header().Set("ETag", `"`+current+`"`)
+	http.ServeContent(c.Out, c.Req, path, time.Time{}, content)
 }
 
 func (s *Server) handleManifest(c *router.Context) {
@@ -87,27 +175,89 @@ func (s *Server) handleManifest(c *router.Context) {
 		"short_name":  "yarr",
 		"description": "yet another rss reader",
 		"display":     "standalone",
-		"start_url":   s.BasePath,
+		"start_url":   s.BasePath + "/",
+		"scope":       s.BasePath + "/",
 		"icons": []map[string]interface{}{
 			{
 				"src":   s.BasePath + "/static/graphicarts/favicon.png",
 				"sizes": "64x64",
 				"type":  "image/png",
 			},
+			{
+				"src":   s.BasePath + "/static/graphicarts/favicon.svg",
+				"sizes": "any",
+				"type":  "image/svg+xml",
+			},
 		},
 	})
 }
 
+// handleServiceWorker serves the service worker script from the root,
+// for it to control the whole app.
+func (s *Server) handleServiceWorker(c *router.Context) {
+	script, err := fs.ReadFile(assets.FS, "javascripts/sw.js")
+	if err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	c.Out.Header().Set("Content-Type", "text/javascript")
+	c.Out.Header().Set("Cache-Control", "no-cache")
+	c.Out.WriteHeader(http.StatusOK)
+	c.Out.Write(script)
+}
+
 func (s *Server) handleStatus(c *router.Context) {
+	db := s.requestDB(c)
+	stats, err := db.FeedStats()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	folderStats, err := db.FolderStats()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	tagStats, err := db.ListTags()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	searchStats, err := db.SavedSearchStats()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	trials, err := db.ListFeedTrials(time.Now())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	deleted, err := db.ListDeletedFeeds()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, map[string]interface{}{
-		"running": s.worker.FeedsPending(),
-		"stats":   s.db.FeedStats(),
+		"running":      s.worker.FeedsPending(),
+		"stats":        stats,
+		"folder_stats": folderStats,
+		"tag_stats":    tagStats,
+		"search_stats": searchStats,
+		"trials":       trials,
+		"deleted":      deleted,
 	})
 }
 
 func (s *Server) handleFolderList(c *router.Context) {
+	db := s.requestDB(c)
 	if c.Req.Method == "GET" {
-		list := s.db.ListFolders()
+		list, err := db.ListFolders()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 		c.JSON(http.StatusOK, list)
 	} else if c.Req.Method == "POST" {
 		var body FolderCreateForm
@@ -120,7 +270,22 @@ func (s *Server) handleFolderList(c *router.Context) {
 			c.JSON(http.StatusBadRequest, map[string]string{"error": "Folder title missing."})
 			return
 		}
-		folder := s.db.CreateFolder(body.Title)
+		if err := storage.ValidateTitle("title", body.Title); err != nil {
+			writeError(c, err)
+			return
+		}
+		folder, err := db.CreateFolder(body.Title)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if body.ParentId != nil {
+			if err := db.MoveFolder(folder.Id, body.ParentId); err != nil {
+				writeError(c, err)
+				return
+			}
+			folder.ParentId = body.ParentId
+		}
 		c.JSON(http.StatusCreated, folder)
 	} else {
 		c.Out.WriteHeader(http.StatusMethodNotAllowed)
@@ -128,6 +293,7 @@ func (s *Server) handleFolderList(c *router.Context) {
 }
 
 func (s *Server) handleFolder(c *router.Context) {
+	db := s.requestDB(c)
 	id, err := c.VarInt64("id")
 	if err != nil {
 		c.Out.WriteHeader(http.StatusBadRequest)
@@ -141,14 +307,46 @@ func (s *Server) handleFolder(c *router.Context) {
 			return
 		}
 		if body.Title != nil {
-			s.db.RenameFolder(id, *body.Title)
+			if err := db.RenameFolder(id, *body.Title); err != nil {
+				writeError(c, err)
+				return
+			}
+		}
+		if body.ParentId != nil {
+			var parentId *int64
+			if err := json.Unmarshal(body.ParentId, &parentId); err != nil {
+				c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid parent folder."})
+				return
+			}
+			if err := db.MoveFolder(id, parentId); err != nil {
+				writeError(c, err)
+				return
+			}
+		}
+		if body.MuteSchedule != nil {
+			if err := db.UpdateFolderMuteSchedule(id, *body.MuteSchedule); err != nil {
+				writeError(c, err)
+				return
+			}
 		}
 		if body.IsExpanded != nil {
-			s.db.ToggleFolderExpanded(id, *body.IsExpanded)
+			if err := db.ToggleFolderExpanded(id, *body.IsExpanded); err != nil {
+				writeError(c, err)
+				return
+			}
+		}
+		if body.CustomOrder != nil {
+			if err := db.UpdateFolderCustomOrder(id, *body.CustomOrder); err != nil {
+				writeError(c, err)
+				return
+			}
 		}
 		c.Out.WriteHeader(http.StatusOK)
 	} else if c.Req.Method == "DELETE" {
-		s.db.DeleteFolder(id)
+		if err := db.DeleteFolder(id); err != nil {
+			writeError(c, err)
+			return
+		}
 		c.Out.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -163,30 +361,191 @@ func (s *Server) handleFeedRefresh(c *router.Context) {
 }
 
 func (s *Server) handleFeedErrors(c *router.Context) {
-	errors := s.db.GetFeedErrors()
+	db := s.requestDB(c)
+	errors, err := db.GetFeedErrors()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, errors)
 }
 
+func (s *Server) handleFeedBandwidth(c *router.Context) {
+	db := s.requestDB(c)
+	days := int64(30)
+	if n, err := c.QueryInt64("days"); err == nil && n > 0 {
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -int(days-1))
+	feeds, err := db.FeedBandwidthTotals(since)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"days":  days,
+		"feeds": feeds,
+	})
+}
+
+func (s *Server) handleFeedSuggestionList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	suggestions, err := db.ListFeedSuggestions()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// handleFeedSuggestion applies (POST) or dismisses (DELETE) the
+// suggestion for the feed.
+func (s *Server) handleFeedSuggestion(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "POST":
+		err = db.ApplyFeedSuggestion(id)
+	case "DELETE":
+		err = db.DeleteFeedSuggestion(id)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusNoContent)
+}
+
+// handleFeedDiff fetches the feed and reports the entries
+// missing locally, see worker.DiffFeed.
+func (s *Server) handleFeedDiff(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	feed, err := db.GetFeed(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	diff, err := worker.DiffFeed(db, *feed)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func (s *Server) handleFeedStats(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := db.GetFeed(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	stats, err := db.GetFeedStats(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// handleFeedErrorHistory tells transient failures from persistent ones:
+// since is set while the feed keeps failing, history lists the recent errors.
+func (s *Server) handleFeedErrorHistory(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := db.GetFeed(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	since, err := db.GetFeedErrorSince(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	history, err := db.GetFeedErrorHistory(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"since":   since,
+		"history": history,
+	})
+}
+
 type feedicon struct {
 	ctype string
 	bytes []byte
 	etag  string
 }
 
+func iconCacheKey(feedId int64) string {
+	return "icon:" + strconv.FormatInt(feedId, 10)
+}
+
+// iconChanged is called by the worker after a different icon has
+// been found for the feed, see handleFeedIcon.
+func (s *Server) iconChanged(feedId int64) {
+	s.cache_mutex.Lock()
+	delete(s.cache, iconCacheKey(feedId))
+	s.cache_mutex.Unlock()
+}
+
 func (s *Server) handleFeedIcon(c *router.Context) {
+	db := s.requestDB(c)
 	id, err := c.VarInt64("id")
 	if err != nil {
 		c.Out.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	cachekey := "icon:" + strconv.FormatInt(id, 10)
+	cachekey := iconCacheKey(id)
 	s.cache_mutex.Lock()
 	cachedat := s.cache[cachekey]
 	s.cache_mutex.Unlock()
 	if cachedat == nil {
-		feed := s.db.GetFeed(id)
-		if feed == nil || feed.Icon == nil {
+		feed, err := db.GetFeed(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if feed.Icon == nil {
 			c.Out.WriteHeader(http.StatusNotFound)
 			return
 		}
@@ -219,8 +578,13 @@ func (s *Server) handleFeedIcon(c *router.Context) {
 }
 
 func (s *Server) handleFeedList(c *router.Context) {
+	db := s.requestDB(c)
 	if c.Req.Method == "GET" {
-		list := s.db.ListFeeds()
+		list, err := db.ListFeeds()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 		c.JSON(http.StatusOK, list)
 	} else if c.Req.Method == "POST" {
 		var form FeedCreateForm
@@ -230,7 +594,23 @@ func (s *Server) handleFeedList(c *router.Context) {
 			return
 		}
 
-		result, err := worker.DiscoverFeed(form.Url)
+		if s.PublicURL != "" && activitypub.IsHandle(form.Url) {
+			s.followActor(c, db, form)
+			return
+		}
+
+		var result *worker.DiscoverResult
+		var err error
+		if source := forge.FromURL(form.Url); source != nil {
+			token, err := db.ForgeToken(source.Host())
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			result, err = worker.DiscoverForgeFeed(*source, token)
+		} else {
+			result, err = worker.DiscoverFeed(form.Url)
+		}
 		switch {
 		case err != nil:
 			log.Printf("Faild to discover feed for %s: %s", form.Url, err)
@@ -238,22 +618,11 @@ func (s *Server) handleFeedList(c *router.Context) {
 		case len(result.Sources) > 0:
 			c.JSON(http.StatusOK, map[string]interface{}{"status": "multiple", "choice": result.Sources})
 		case result.Feed != nil:
-			feed := s.db.CreateFeed(
-				result.Feed.Title,
-				"",
-				result.Feed.SiteURL,
-				result.FeedLink,
-				"",
-				form.FolderID,
-			)
-			items := worker.ConvertItems(result.Feed.Items, *feed)
-			if len(items) > 0 {
-				s.db.CreateItems(items)
-				s.db.SetFeedSize(feed.Id, len(items))
-				s.db.SyncSearch()
+			feed, err := s.subscribe(db, result.Feed, result.FeedLink, form.FolderID)
+			if err != nil {
+				writeError(c, err)
+				return
 			}
-			s.worker.FindFeedFavicon(*feed)
-
 			c.JSON(http.StatusOK, map[string]interface{}{
 				"status": "success",
 				"feed":   feed,
@@ -264,91 +633,636 @@ func (s *Server) handleFeedList(c *router.Context) {
 	}
 }
 
-func (s *Server) handleFeed(c *router.Context) {
-	id, err := c.VarInt64("id")
+// subscribe creates the feed with its current items.
+func (s *Server) subscribe(db *storage.Storage, parsed *parser.Feed, feedLink string, folderId *int64) (*storage.Feed, error) {
+	feed, err := db.CreateFeed(parsed.Title, "", parsed.SiteURL, feedLink, "", folderId)
 	if err != nil {
-		c.Out.WriteHeader(http.StatusBadRequest)
-		return
+		return nil, err
 	}
-	if c.Req.Method == "PUT" {
-		feed := s.db.GetFeed(id)
-		if feed == nil {
-			c.Out.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		body := make(map[string]interface{})
-		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+	items := worker.ConvertItems(parsed.Items, *feed)
+	if len(items) > 0 {
+		if _, err := db.CreateItems(items); err != nil {
 			log.Print(err)
-			c.Out.WriteHeader(http.StatusBadRequest)
-			return
 		}
-		if title, ok := body["title"]; ok {
-			if reflect.TypeOf(title).Kind() == reflect.String {
-				s.db.RenameFeed(id, title.(string))
-			}
+		if err := db.SetFeedSize(feed.Id, len(items)); err != nil {
+			log.Print(err)
 		}
-		if f_id, ok := body["folder_id"]; ok {
-			if f_id == nil {
-				s.db.UpdateFeedFolder(id, nil)
-			} else if reflect.TypeOf(f_id).Kind() == reflect.Float64 {
-				folderId := int64(f_id.(float64))
-				s.db.UpdateFeedFolder(id, &folderId)
-			}
+	}
+	s.worker.FindFeedFavicon(*feed)
+	// the feed is subscribed to already, the trial is a bonus
+	if days, err := db.GetSettingsValueInt64("trial_days"); err != nil {
+		log.Print(err)
+	} else if days > 0 && folderId == nil {
+		if err := db.StartFeedTrial(feed.Id, time.Now().AddDate(0, 0, int(days))); err != nil {
+			log.Print(err)
+		} else if trial, err := db.GetFeed(feed.Id); err == nil {
+			trial.Icon = nil
+			feed = trial
 		}
-		if link, ok := body["feed_link"]; ok {
-			if reflect.TypeOf(link).Kind() == reflect.String {
-				s.db.UpdateFeedLink(id, link.(string))
-			}
+	}
+	return feed, nil
+}
+
+// handleFeedTrialList lists the pending decisions: the new subscriptions
+// whose trial is over (see the "trial_days" setting).
+func (s *Server) handleFeedTrialList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	trials, err := db.ListFeedTrials(time.Now())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, trials)
+}
+
+// handleFeedTrial ends the trial of the feed: {"keep": true} keeps it,
+// {"keep": false} unsubscribes from it.
+func (s *Server) handleFeedTrial(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var form FeedTrialForm
+	if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	feed, err := db.GetFeed(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if feed.TrialUntil == nil {
+		writeError(c, &storage.ValidationError{Field: "feed_id", Reason: "the feed isn't on trial"})
+		return
+	}
+	if form.Keep {
+		if err := db.KeepFeed(id); err != nil {
+			writeError(c, err)
+			return
 		}
-		c.Out.WriteHeader(http.StatusOK)
-	} else if c.Req.Method == "DELETE" {
-		s.db.DeleteFeed(id)
 		c.Out.WriteHeader(http.StatusNoContent)
-	} else {
+		return
+	}
+	counts, err := db.DeleteFeed(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+// handleDeletedFeedList lists the deleted feeds which can still be restored.
+func (s *Server) handleDeletedFeedList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
 		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	deleted, err := db.ListDeletedFeeds()
+	if err != nil {
+		writeError(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, deleted)
 }
 
-func (s *Server) handleItem(c *router.Context) {
+// handleDeletedFeed restores the deleted feed (POST) or purges it right away (DELETE).
+func (s *Server) handleDeletedFeed(c *router.Context) {
+	db := s.requestDB(c)
 	id, err := c.VarInt64("id")
 	if err != nil {
 		c.Out.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if c.Req.Method == "GET" {
-		item := s.db.GetItem(id)
-		if item == nil {
-			c.Out.WriteHeader(http.StatusBadRequest)
+	switch c.Req.Method {
+	case "POST":
+		if err := db.RestoreFeed(id); err != nil {
+			writeError(c, err)
 			return
 		}
-
-		// runtime fix for relative links
-		if !htmlutil.IsAPossibleLink(item.Link) {
-			if feed := s.db.GetFeed(item.FeedId); feed != nil {
-				item.Link = htmlutil.AbsoluteUrl(item.Link, feed.Link)
-			}
+		c.Out.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		feed, err := db.GetFeed(id)
+		if err == nil && feed.DeletedAt == nil {
+			err = storage.ErrNotFound
 		}
-
-		item.Content = sanitizer.Sanitize(item.Link, item.Content)
-
-		c.JSON(http.StatusOK, item)
-	} else if c.Req.Method == "PUT" {
-		var body ItemUpdateForm
-		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
-			log.Print(err)
-			c.Out.WriteHeader(http.StatusBadRequest)
+		if err != nil {
+			writeError(c, err)
 			return
 		}
-		if body.Status != nil {
-			s.db.UpdateItemStatus(id, *body.Status)
+		counts, err := db.DeleteFeed(id)
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		c.Out.WriteHeader(http.StatusOK)
-	} else {
+		c.JSON(http.StatusOK, counts)
+	default:
 		c.Out.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *Server) handleItemList(c *router.Context) {
+// handleFeedOrder takes the feeds in their new order,
+// e.g. the ones of the folder a feed is dragged to.
+func (s *Server) handleFeedOrder(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "PUT" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var form FeedOrderForm
+	if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := db.ReorderFeeds(form.Feeds); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusOK)
+}
+
+// handleFolderOrder takes the folders in their new order,
+// e.g. the subfolders of the folder one is dragged to.
+func (s *Server) handleFolderOrder(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "PUT" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var form FolderOrderForm
+	if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := db.ReorderFolders(form.FolderIds); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleFeedsBulk(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var form FeedsBulkForm
+	if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !form.Action.IsValid() || form.RefreshInterval < 0 {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := storage.ValidateDeliveryTimes(form.DeliveryTimes); err != nil {
+		writeError(c, err)
+		return
+	}
+	// the errors of moving and deleting are told apart, e.g. a missing feed
+	switch form.Action {
+	case storage.BulkMove:
+		if err := db.MoveFeeds(form.FeedIds, form.FolderId); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusOK)
+		return
+	case storage.BulkDelete:
+		counts, err := db.DeleteFeeds(form.FeedIds)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, counts)
+		return
+	}
+	err := db.UpdateFeedsBulk(form.FeedIds, storage.FeedsBulkUpdate{
+		Action:          form.Action,
+		FolderId:        form.FolderId,
+		RefreshInterval: form.RefreshInterval,
+		DeliveryTimes:   form.DeliveryTimes,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleFeed(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		feed, err := db.GetFeed(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		feed.Icon = nil
+		stats, err := db.GetFeedStats(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			*storage.Feed
+			Stats *storage.FeedStats `json:"stats"`
+		}{feed, stats})
+	} else if c.Req.Method == "PUT" {
+		if _, err := db.GetFeed(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		body := make(map[string]interface{})
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// the values of the wrong types are ignored
+		var update storage.FeedUpdate
+		if title, ok := body["title"].(string); ok {
+			update.Title = &title
+		}
+		if f_id, ok := body["folder_id"]; ok {
+			if f_id == nil {
+				update.SetFolder = true
+			} else if f_id, ok := f_id.(float64); ok {
+				folderId := int64(f_id)
+				update.SetFolder, update.FolderId = true, &folderId
+			}
+		}
+		if link, ok := body["feed_link"].(string); ok {
+			update.FeedLink = &link
+		}
+		if enabled, ok := body["download_enclosures"].(bool); ok {
+			update.DownloadEnclosures = &enabled
+		}
+		if order, ok := body["custom_order"].(string); ok {
+			update.CustomOrder = &order
+		}
+		if paused, ok := body["paused"].(bool); ok {
+			update.Paused = &paused
+		}
+		if interval, ok := body["refresh_interval"].(float64); ok && interval >= 0 {
+			refreshInterval := int64(interval)
+			update.RefreshInterval = &refreshInterval
+		}
+		if language, ok := body["accept_language"].(string); ok {
+			update.AcceptLanguage = &language
+		}
+		if strategy, ok := body["guid_strategy"].(string); ok {
+			update.GUIDStrategy = &strategy
+		}
+		if limit, ok := body["daily_limit"].(float64); ok {
+			dailyLimit := int64(limit)
+			update.DailyLimit = &dailyLimit
+		}
+		if deliveryTimes, ok := stringList(body["delivery_times"]); ok {
+			update.DeliveryTimes = deliveryTimes
+		}
+		// null follows the "retention" setting
+		if value, ok := body["retention"]; ok {
+			update.SetRetention = true
+			if value != nil {
+				update.Retention = &storage.Retention{}
+				data, _ := json.Marshal(value)
+				if err := json.Unmarshal(data, update.Retention); err != nil {
+					writeError(c, &storage.ValidationError{Field: "retention", Reason: "must be an object with days and items"})
+					return
+				}
+			}
+		}
+		if iframeHosts, ok := hostList(body["iframe_hosts"]); ok {
+			update.IframeHosts = iframeHosts
+		}
+		if blockedHosts, ok := hostList(body["blocked_hosts"]); ok {
+			update.BlockedHosts = blockedHosts
+		}
+		if allowedHosts, ok := hostList(body["allowed_hosts"]); ok {
+			update.AllowedHosts = allowedHosts
+		}
+		if err := db.UpdateFeed(id, update); err != nil {
+			writeError(c, err)
+			return
+		}
+		if update.DownloadEnclosures != nil && *update.DownloadEnclosures && s.downloader != nil {
+			s.downloader.Notify()
+		}
+		c.Out.WriteHeader(http.StatusOK)
+	} else if c.Req.Method == "DELETE" {
+		// the feed can be restored for a while, unless it's deleted for good
+		if c.Req.URL.Query().Get("permanent") != "true" {
+			if err := db.TrashFeed(id); err != nil {
+				writeError(c, err)
+				return
+			}
+			c.Out.WriteHeader(http.StatusNoContent)
+			return
+		}
+		counts, err := db.DeleteFeed(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, counts)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// stringList converts a decoded JSON array of strings,
+// ok is false for any other value.
+func stringList(value interface{}) (list []string, ok bool) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list = make([]string, 0, len(values))
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, str)
+	}
+	return list, true
+}
+
+func (s *Server) handleItem(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		format, ok := contentFormat(c)
+		if !ok {
+			return
+		}
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		// clients loading the content separately (see handleItemContent)
+		// skip it with ?content=false
+		if c.Req.URL.Query().Get("content") == "false" {
+			fixItemLink(db, item)
+			item.Content = ""
+		} else {
+			content, err := itemContent(db, item)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			item.Content = format(content)
+		}
+		if item.Podcast, err = db.GetItemPodcast(id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			writeError(c, err)
+			return
+		}
+		if item.Snapshot, err = db.GetItemSnapshot(id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, item)
+	} else if c.Req.Method == "PUT" {
+		var body ItemUpdateForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body.Status != nil {
+			if err := s.setItemStatus(db, id, *body.Status); err != nil {
+				writeError(c, err)
+				return
+			}
+		}
+		c.Out.WriteHeader(http.StatusOK)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// fixItemLink resolves the relative link of the item,
+// returning its feed (nil if not found).
+func fixItemLink(db *storage.Storage, item *storage.Item) *storage.Feed {
+	feed, err := db.GetFeed(item.FeedId)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Print(err)
+		}
+		return nil
+	}
+	if !htmlutil.IsAPossibleLink(item.Link) {
+		// runtime fix for relative links
+		item.Link = htmlutil.AbsoluteUrl(item.Link, feed.Link)
+	}
+	return feed
+}
+
+// hostList reads a list of hosts from the request body, the blank ones are skipped.
+func hostList(val interface{}) ([]string, bool) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	hosts := make([]string, 0, len(list))
+	for _, host := range list {
+		if host, ok := host.(string); ok && strings.TrimSpace(host) != "" {
+			hosts = append(hosts, strings.TrimSpace(host))
+		}
+	}
+	return hosts, true
+}
+
+// sanitizeOptions tweaks the sanitization for the feed (if known):
+// the hosts blocked by the settings, along with the feed's own ones.
+func sanitizeOptions(blockedHosts []string, feed *storage.Feed) sanitizer.Options {
+	opts := sanitizer.Options{BlockedHosts: blockedHosts}
+	if feed != nil {
+		opts.IframeHosts = feed.IframeHosts
+		opts.AllowedHosts = feed.AllowedHosts
+		if len(feed.BlockedHosts) > 0 {
+			opts.BlockedHosts = append(append([]string{}, blockedHosts...), feed.BlockedHosts...)
+		}
+	}
+	return opts
+}
+
+// itemContent returns the sanitized content of the item.
+func itemContent(db *storage.Storage, item *storage.Item) (string, error) {
+	blockedHosts, err := db.BlockedHosts()
+	if err != nil {
+		return "", err
+	}
+	opts := sanitizeOptions(blockedHosts, fixItemLink(db, item))
+	return sanitizer.SanitizeWithOptions(item.Link, item.Content, opts), nil
+}
+
+var contentFormats = map[string]func(string) string{
+	"html":     func(content string) string { return content },
+	"text":     htmlutil.PlainText,
+	"markdown": htmlutil.Markdown,
+}
+
+// contentFormat returns the conversion of the sanitized content
+// requested with ?format=html|text|markdown (html if omitted),
+// writing the error response if the format is unknown.
+func contentFormat(c *router.Context) (func(string) string, bool) {
+	name := c.Req.URL.Query().Get("format")
+	if name == "" {
+		name = "html"
+	}
+	format, ok := contentFormats[name]
+	if !ok {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown format."})
+	}
+	return format, ok
+}
+
+func (s *Server) handleItemContent(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	format, ok := contentFormat(c)
+	if !ok {
+		return
+	}
+	item, err := db.GetItem(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	content, err := itemContent(db, item)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"content":       format(content),
+		"original_size": item.OriginalSize,
+	})
+}
+
+const (
+	bundleSize    = 100
+	maxBundleSize = 500
+)
+
+// handleItemBundle serves the latest unread items along with their
+// content, for the service worker to keep them readable offline.
+// The clients which already have the items up to ?since_id=
+// get only the newer ones, the ids of all of them are listed
+// in unread_ids to tell which of the rest to keep.
+func (s *Server) handleItemBundle(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	limit := bundleSize
+	if n, err := c.QueryInt64("limit"); err == nil && n > 0 {
+		limit = int(n)
+		if limit > maxBundleSize {
+			limit = maxBundleSize
+		}
+	}
+	sinceID, _ := c.QueryInt64("since_id")
+
+	unread := storage.UNREAD
+	items, err := db.ListItems(storage.ItemFilter{Status: &unread}, limit, true, true)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	hash := md5.New()
+	fmt.Fprintf(hash, "%d:%d", limit, sinceID)
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+		fmt.Fprintf(hash, ",%d:%d", item.Id, item.Status)
+	}
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil))
+	c.Out.Header().Set("ETag", etag)
+	c.Out.Header().Set("Cache-Control", "no-cache")
+	if c.Req.Header.Get("If-None-Match") == etag {
+		c.Out.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	feeds, err := db.ListFeeds()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	feedsById := make(map[int64]storage.Feed, len(feeds))
+	for _, feed := range feeds {
+		feedsById[feed.Id] = feed
+	}
+	blockedHosts, err := db.BlockedHosts()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	bundle := make([]storage.Item, 0, len(items))
+	for _, item := range items {
+		if item.Id <= sinceID {
+			continue
+		}
+		var itemFeed *storage.Feed
+		if feed, ok := feedsById[item.FeedId]; ok {
+			if !htmlutil.IsAPossibleLink(item.Link) {
+				item.Link = htmlutil.AbsoluteUrl(item.Link, feed.Link)
+			}
+			itemFeed = &feed
+		}
+		opts := sanitizeOptions(blockedHosts, itemFeed)
+		item.Content = sanitizer.SanitizeWithOptions(item.Link, item.Content, opts)
+		bundle = append(bundle, item)
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"items":      bundle,
+		"unread_ids": ids,
+		"feeds":      feeds,
+	})
+}
+
+func (s *Server) handleItemList(c *router.Context) {
+	db := s.requestDB(c)
 	if c.Req.Method == "GET" {
 		perPage := 20
 		query := c.Req.URL.Query()
@@ -357,67 +1271,785 @@ func (s *Server) handleItemList(c *router.Context) {
 		if folderID, err := c.QueryInt64("folder_id"); err == nil {
 			filter.FolderID = &folderID
 		}
-		if feedID, err := c.QueryInt64("feed_id"); err == nil {
-			filter.FeedID = &feedID
+		if feedID, err := c.QueryInt64("feed_id"); err == nil {
+			filter.FeedID = &feedID
+		}
+		if after, err := c.QueryInt64("after"); err == nil {
+			filter.After = &after
+		}
+		if status := query.Get("status"); len(status) != 0 {
+			statusValue := storage.StatusValues[status]
+			filter.Status = &statusValue
+		}
+		if search := query.Get("search"); len(search) != 0 {
+			filter.Search = &search
+			filter.SearchField = query.Get("search_in")
+		}
+		if query.Get("snoozed") == "true" {
+			filter.Snoozed = true
+		}
+		if tag := query.Get("tag"); len(tag) != 0 {
+			filter.Tag = &tag
+		}
+		if language := query.Get("language"); len(language) != 0 {
+			filter.Language = &language
+		}
+		if bbox := query.Get("bbox"); len(bbox) != 0 {
+			box, err := storage.ParseBoundingBox(bbox)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			filter.Within = box
+		}
+		switch sort := storage.ItemSort(query.Get("sort")); sort {
+		case storage.SortUpdated, storage.SortArrived, storage.SortRead:
+			filter.SortBy = sort
+		}
+		if searchID, err := c.QueryInt64("search_id"); err == nil {
+			saved, err := db.GetSavedSearch(searchID)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			filter = saved.Narrow(filter)
+		}
+		// a single feed is shown in full
+		val, err := db.GetSettingsValue("collapse_duplicates")
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		collapse, _ := val.(bool)
+		if value := query.Get("collapse_duplicates"); value != "" {
+			collapse = value == "true"
+		}
+		filter.CollapseDuplicates = collapse && filter.FeedID == nil
+		newestFirst := query.Get("oldest_first") != "true"
+
+		// search results ranked by relevance are paged with offset,
+		// the rest with the after cursor
+		var items []storage.Item
+		relevance := filter.Search != nil && query.Get("sort") == "relevance"
+		if relevance {
+			offset, _ := c.QueryInt64("offset")
+			items, err = db.SearchItems(filter, perPage+1, int(offset))
+		} else {
+			items, err = db.ListItems(filter, perPage+1, newestFirst, false)
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		hasMore := false
+		if len(items) == perPage+1 {
+			hasMore = true
+			items = items[:perPage]
+		}
+		if filter.Search != nil && !relevance {
+			ids := make([]int64, len(items))
+			for i, item := range items {
+				ids[i] = item.Id
+			}
+			matches, err := db.SearchMatches(ids, *filter.Search, filter.SearchField)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			for i := range items {
+				items[i].Match = matches[items[i].Id]
+			}
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"list":     items,
+			"has_more": hasMore,
+		})
+	} else if c.Req.Method == "PUT" {
+		query := c.Req.URL.Query()
+		filter := storage.ItemFilter{}
+
+		if folderID, err := c.QueryInt64("folder_id"); err == nil {
+			filter.FolderID = &folderID
+		}
+		if feedID, err := c.QueryInt64("feed_id"); err == nil {
+			filter.FeedID = &feedID
+		}
+		if tag := query.Get("tag"); len(tag) != 0 {
+			filter.Tag = &tag
+		}
+		if search := query.Get("search"); len(search) != 0 {
+			filter.Search = &search
+			filter.SearchField = query.Get("search_in")
+		}
+		if searchID, err := c.QueryInt64("search_id"); err == nil {
+			saved, err := db.GetSavedSearch(searchID)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+			filter = saved.Narrow(filter)
+		}
+		err := db.MarkItemsRead(storage.MarkFilter{
+			FolderID:    filter.FolderID,
+			FeedID:      filter.FeedID,
+			Tag:         filter.Tag,
+			Search:      filter.Search,
+			SearchField: filter.SearchField,
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		s.publishStats(db)
+		c.Out.WriteHeader(http.StatusOK)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleItemChapters(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	podcast, err := db.GetItemPodcast(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if podcast.ChaptersURL == "" {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if podcast.Chapters == nil {
+		chapters, err := worker.GetChapters(podcast.ChaptersURL)
+		if err != nil {
+			log.Printf("Failed to fetch chapters %s: %s", podcast.ChaptersURL, err)
+			c.Out.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if err := db.SetItemChapters(id, chapters); err != nil {
+			writeError(c, err)
+			return
+		}
+		podcast.Chapters = chapters
+	}
+	c.JSON(http.StatusOK, podcast.Chapters)
+}
+
+func (s *Server) handleItemPlayback(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		playback, err := db.GetPlayback(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, playback)
+	} else if c.Req.Method == "PUT" {
+		var body PlaybackUpdateForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := db.GetItem(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		playback := storage.Playback{
+			Position:  body.Position,
+			Duration:  body.Duration,
+			Completed: body.Completed,
+		}
+		if body.UpdatedAt != nil {
+			playback.UpdatedAt = *body.UpdatedAt
+		}
+		if err := db.UpdatePlayback(id, playback); err != nil {
+			writeError(c, err)
+			return
+		}
+		stored, err := db.GetPlayback(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, stored)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleItemDownload(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		download, err := db.GetDownload(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, download)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleItemEnclosure(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	download, err := db.GetDownload(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if s.downloader == nil || download.Status != storage.DownloadDone {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	http.ServeFile(c.Out, c.Req, s.downloader.Path(*download))
+}
+
+// snapshotPolicy only lets the archived copy display its inlined images.
+const snapshotPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; frame-ancestors 'self'"
+
+func (s *Server) handleItemSnapshot(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		snapshot, err := db.GetItemSnapshot(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		title := item.Title
+		c.Out.Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.Out.Header().Set("Content-Security-Policy", snapshotPolicy)
+		fmt.Fprintf(
+			c.Out,
+			"<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>%s</title></head><body>%s</body></html>",
+			html.EscapeString(title), snapshot.Content,
+		)
+	} else if c.Req.Method == "POST" {
+		if _, err := db.GetItem(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		if err := s.archiveItem(id); err != nil {
+			c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		snapshot, err := db.GetItemSnapshot(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+	} else if c.Req.Method == "DELETE" {
+		if err := db.DeleteItemSnapshot(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleItemSnooze(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "POST" {
+		var body ItemSnoozeForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := db.SnoozeItem(id, body.Until); err != nil {
+			writeError(c, err)
+			return
+		}
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, item)
+	} else if c.Req.Method == "DELETE" {
+		if err := db.UnsnoozeItem(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleItemTags(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, append([]string{}, item.Tags...))
+	} else if c.Req.Method == "POST" {
+		var body ItemTagForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := db.AddItemTag(id, body.Tag); err != nil {
+			writeError(c, err)
+			return
+		}
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, item)
+	} else if c.Req.Method == "DELETE" {
+		if err := db.RemoveItemTag(id, c.Req.URL.Query().Get("tag")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleItemNote sets (PUT) or removes (DELETE) the private note of the item.
+func (s *Server) handleItemNote(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "GET":
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, ItemNoteForm{Note: item.Note})
+	case "PUT":
+		var body ItemNoteForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := db.SetItemNote(id, body.Note); err != nil {
+			writeError(c, err)
+			return
+		}
+		item, err := db.GetItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, item)
+	case "DELETE":
+		if err := db.SetItemNote(id, ""); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+const archivePageSize = 50
+
+// handleArchiveList lists the copies of the starred items (see
+// storage.ArchivedItem), paged with the after cursor.
+func (s *Server) handleArchiveList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "GET" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	after, _ := c.QueryInt64("after")
+	list, err := db.ListArchivedItems(after, archivePageSize+1)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	hasMore := false
+	if len(list) > archivePageSize {
+		hasMore = true
+		list = list[:archivePageSize]
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"list":     list,
+		"has_more": hasMore,
+	})
+}
+
+// handleArchivedItem returns the archived item with its (sanitized)
+// content, or removes it from the archive.
+func (s *Server) handleArchivedItem(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "GET":
+		archived, err := db.GetArchivedItem(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		blockedHosts, err := db.BlockedHosts()
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		if after, err := c.QueryInt64("after"); err == nil {
-			filter.After = &after
+		// the feed's own sanitization options may be gone along with it
+		opts := sanitizeOptions(blockedHosts, nil)
+		archived.Content = sanitizer.SanitizeWithOptions(archived.Link, archived.Content, opts)
+		c.JSON(http.StatusOK, archived)
+	case "DELETE":
+		if err := db.DeleteArchivedItem(id); err != nil {
+			writeError(c, err)
+			return
 		}
-		if status := query.Get("status"); len(status) != 0 {
-			statusValue := storage.StatusValues[status]
-			filter.Status = &statusValue
+		c.Out.WriteHeader(http.StatusNoContent)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTagList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method == "GET" {
+		tags, err := db.ListTags()
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		if search := query.Get("search"); len(search) != 0 {
-			filter.Search = &search
+		c.JSON(http.StatusOK, tags)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleSavedSearchList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method == "GET" {
+		searches, err := db.ListSavedSearches()
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		newestFirst := query.Get("oldest_first") != "true"
+		c.JSON(http.StatusOK, searches)
+	} else if c.Req.Method == "POST" {
+		var body storage.SavedSearch
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		search, err := db.CreateSavedSearch(body)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, search)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
 
-		items := s.db.ListItems(filter, perPage+1, newestFirst, false)
-		hasMore := false
-		if len(items) == perPage+1 {
-			hasMore = true
-			items = items[:perPage]
+func (s *Server) handleSavedSearch(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method == "GET" {
+		search, err := db.GetSavedSearch(id)
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		c.JSON(http.StatusOK, map[string]interface{}{
-			"list":     items,
-			"has_more": hasMore,
-		})
+		c.JSON(http.StatusOK, search)
 	} else if c.Req.Method == "PUT" {
-		filter := storage.MarkFilter{}
+		var body storage.SavedSearch
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body.Id = id
+		if err := db.UpdateSavedSearch(body); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	} else if c.Req.Method == "DELETE" {
+		if err := db.DeleteSavedSearch(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
 
-		if folderID, err := c.QueryInt64("folder_id"); err == nil {
-			filter.FolderID = &folderID
+// archiveItem stores a local copy of the page the item links to.
+func (s *Server) archiveItem(id int64) error {
+	item, err := s.db.GetItem(id)
+	if err != nil {
+		return err
+	}
+	link := item.Link
+	if feed, err := s.db.GetFeed(item.FeedId); err == nil && !htmlutil.IsAPossibleLink(link) {
+		link = htmlutil.AbsoluteUrl(link, feed.Link)
+	}
+	content, err := worker.Snapshot(link)
+	if err != nil {
+		log.Printf("Failed to archive %s: %s", link, err)
+		return err
+	}
+	if err := s.db.SaveItemSnapshot(id, content); err != nil {
+		log.Printf("Failed to save snapshot of %s: %s", link, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleDownloadList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method == "GET" {
+		size, err := db.DownloadsSize()
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		if feedID, err := c.QueryInt64("feed_id"); err == nil {
-			filter.FeedID = &feedID
+		list, err := db.ListDownloads()
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-		s.db.MarkItemsRead(filter)
-		c.Out.WriteHeader(http.StatusOK)
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"enabled": s.downloader != nil,
+			"size":    size,
+			"quota":   s.DownloadQuota,
+			"list":    list,
+		})
 	} else {
 		c.Out.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleSettings(c *router.Context) {
+	db := s.requestDB(c)
 	if c.Req.Method == "GET" {
-		c.JSON(http.StatusOK, s.db.GetSettings())
+		settings, err := db.GetSettings()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, settings)
 	} else if c.Req.Method == "PUT" {
 		settings := make(map[string]interface{})
 		if err := json.NewDecoder(c.Req.Body).Decode(&settings); err != nil {
 			c.Out.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		if s.db.UpdateSettings(settings) {
-			if _, ok := settings["refresh_rate"]; ok {
-				s.worker.SetRefreshRate(s.db.GetSettingsValueInt64("refresh_rate"))
+		if err := db.UpdateSettings(settings); err != nil {
+			writeError(c, err)
+			return
+		}
+		if _, ok := settings["refresh_rate"]; ok {
+			refreshRate, err := db.GetSettingsValueInt64("refresh_rate")
+			if err != nil {
+				writeError(c, err)
+				return
 			}
-			c.Out.WriteHeader(http.StatusOK)
-		} else {
+			s.worker.SetRefreshRate(refreshRate)
+		}
+		if _, ok := settings["rss_bridge_url"]; ok {
+			relinkBridgedFeeds(db)
+		}
+		c.Out.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) handleNotifierList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method == "GET" {
+		notifiers, err := db.ListNotifiers()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"kinds":     notify.Kinds(),
+			"notifiers": notifiers,
+		})
+	} else if c.Req.Method == "POST" {
+		var body NotifierCreateForm
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err := notify.NewNotifier(body.Kind, body.Config); err != nil {
+			writeError(c, &storage.ValidationError{Field: "config", Reason: err.Error()})
+			return
+		}
+		notifier, err := db.CreateNotifier(body.Name, body.Kind, body.Config)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, notifier)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleNotifier(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method != "DELETE" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := db.DeleteNotifier(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusNoContent)
+}
+
+// handleNotifierTest sends a sample notification through the notifier,
+// replying with 502 and the error if it fails.
+func (s *Server) handleNotifierTest(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	notifier, err := db.GetNotifier(id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	item := notify.Item{Title: "Test notification", Link: s.GetAddr(), FeedTitle: "yarr"}
+	if err := worker.SendNotification(*notifier, []notify.Item{item}); err != nil {
+		c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	c.Out.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleNotificationRouteList(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method == "GET" {
+		routes, err := db.ListNotificationRoutes()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, routes)
+	} else if c.Req.Method == "POST" {
+		var body storage.NotificationRoute
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			log.Print(err)
 			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		route, err := db.CreateNotificationRoute(body)
+		if err != nil {
+			writeError(c, err)
+			return
 		}
+		c.JSON(http.StatusCreated, route)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+func (s *Server) handleNotificationRoute(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if c.Req.Method != "DELETE" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := db.DeleteNotificationRoute(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Out.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) handleOPMLImport(c *router.Context) {
 	if c.Req.Method == "POST" {
 		file, _, err := c.Req.FormFile("opml")
@@ -425,40 +2057,159 @@ func (s *Server) handleOPMLImport(c *router.Context) {
 			log.Print(err)
 			return
 		}
-		doc, err := opml.Parse(file)
+		doc, err := opml.ParseAny(file)
 		if err != nil {
 			log.Print(err)
 			c.Out.WriteHeader(http.StatusBadRequest)
 			return
 		}
+		s.importOPML(doc)
+		c.Out.WriteHeader(http.StatusOK)
+	} else {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// importOPMLFolder creates the folder (nested in the parent if not nil)
+// along with its subfolders, and subscribes to their feeds.
+func (s *Server) importOPMLFolder(f opml.Folder, parentId *int64) []storage.Feed {
+	imported := make([]storage.Feed, 0)
+	folder, err := s.db.CreateFolder(f.Title)
+	if err != nil {
+		log.Printf("Failed to import the folder %s: %s", f.Title, err)
+		// the feeds are kept in the parent folder
+		for _, ff := range f.AllFeeds() {
+			feed, err := s.db.CreateFeed(ff.Title, "", ff.SiteUrl, ff.FeedUrl, ff.CustomOrder, parentId)
+			if err != nil {
+				log.Printf("Failed to import %s: %s", ff.FeedUrl, err)
+				continue
+			}
+			imported = append(imported, *feed)
+		}
+		return imported
+	}
+	if parentId != nil {
+		if err := s.db.MoveFolder(folder.Id, parentId); err != nil {
+			log.Printf("Failed to move folder %s: %s", f.Title, err)
+		}
+	}
+	for _, ff := range f.Feeds {
+		feed, err := s.db.CreateFeed(ff.Title, "", ff.SiteUrl, ff.FeedUrl, ff.CustomOrder, &folder.Id)
+		if err != nil {
+			log.Printf("Failed to import %s: %s", ff.FeedUrl, err)
+			continue
+		}
+		imported = append(imported, *feed)
+	}
+	for _, subfolder := range f.Folders {
+		imported = append(imported, s.importOPMLFolder(subfolder, &folder.Id)...)
+	}
+	return imported
+}
+
+func (s *Server) importOPML(docs ...opml.Folder) {
+	imported := make([]storage.Feed, 0)
+	for _, doc := range docs {
 		for _, f := range doc.Feeds {
-			s.db.CreateFeed(f.Title, "", f.SiteUrl, f.FeedUrl, f.CustomOrder, nil)
+			feed, err := s.db.CreateFeed(f.Title, "", f.SiteUrl, f.FeedUrl, f.CustomOrder, nil)
+			if err != nil {
+				log.Printf("Failed to import %s: %s", f.FeedUrl, err)
+				continue
+			}
+			imported = append(imported, *feed)
 		}
 		for _, f := range doc.Folders {
-			folder := s.db.CreateFolder(f.Title)
-			for _, ff := range f.AllFeeds() {
-				s.db.CreateFeed(ff.Title, "", ff.SiteUrl, ff.FeedUrl, ff.CustomOrder, &folder.Id)
-			}
+			imported = append(imported, s.importOPMLFolder(f, nil)...)
 		}
+	}
 
-		s.worker.FindFavicons()
-		s.worker.RefreshFeeds()
+	s.worker.FindFavicons()
+	s.worker.RefreshFeeds()
+	s.worker.CheckImportedFeeds(imported)
+}
 
-		c.Out.WriteHeader(http.StatusOK)
-	} else {
+// handleOPMLMail imports the OPML attachments of an email message,
+// posted in raw form by an email gateway (e.g. a mail server piping
+// messages to curl). It's authenticated by a token instead of the
+// login, since gateways can't log in.
+func (s *Server) handleOPMLMail(c *router.Context) {
+	if s.MailToken == "" {
+		c.Out.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !auth.StringsEqual(c.Req.URL.Query().Get("token"), s.MailToken) {
+		c.Out.WriteHeader(http.StatusForbidden)
+		return
+	}
+	docs, err := opml.ParseMail(http.MaxBytesReader(c.Out, c.Req.Body, maxMailSize))
+	if err != nil {
+		log.Print(err)
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	feeds := 0
+	for _, doc := range docs {
+		feeds += len(doc.AllFeeds())
+	}
+	s.importOPML(docs...)
+	c.JSON(http.StatusOK, map[string]int{"feeds": feeds})
+}
+
+// handleItemStates exports the read/starred state of items, or imports
+// such an export, e.g. when moving to another instance.
+func (s *Server) handleItemStates(c *router.Context) {
+	db := s.requestDB(c)
+	switch c.Req.Method {
+	case "GET":
+		states, err := db.ExportItemStates()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.Header().Set("Content-Disposition", `attachment; filename="states.json"`)
+		c.JSON(http.StatusOK, states)
+	case "POST":
+		var states storage.ItemStates
+		if err := json.NewDecoder(c.Req.Body).Decode(&states); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		applied, pending, err := db.ImportItemStates(states)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]int{"applied": applied, "pending": pending})
+	default:
 		c.Out.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleOPMLExport(c *router.Context) {
+	db := s.requestDB(c)
 	if c.Req.Method == "GET" {
+		feeds, err := db.ListFeeds()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		folders, err := db.ListFolders()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 		c.Out.Header().Set("Content-Type", "application/xml; charset=utf-8")
 		c.Out.Header().Set("Content-Disposition", `attachment; filename="subscriptions.opml"`)
 
 		doc := opml.Folder{}
 
 		feedsByFolderID := make(map[int64][]*storage.Feed)
-		for _, feed := range s.db.ListFeeds() {
+		for _, feed := range feeds {
 			feed := feed
 			if feed.FolderId == nil {
 				doc.Feeds = append(doc.Feeds, opml.Feed{
@@ -473,20 +2224,36 @@ func (s *Server) handleOPMLExport(c *router.Context) {
 			}
 		}
 
-		for _, folder := range s.db.ListFolders() {
-			folderFeeds := feedsByFolderID[folder.Id]
-			if len(folderFeeds) == 0 {
-				continue
+		foldersByParentID := make(map[int64][]storage.Folder)
+		var topFolders []storage.Folder
+		for _, folder := range folders {
+			if folder.ParentId == nil {
+				topFolders = append(topFolders, folder)
+			} else {
+				foldersByParentID[*folder.ParentId] = append(foldersByParentID[*folder.ParentId], folder)
 			}
+		}
+		var outline func(folder storage.Folder) opml.Folder
+		outline = func(folder storage.Folder) opml.Folder {
 			opmlfolder := opml.Folder{Title: folder.Title}
-			for _, feed := range folderFeeds {
+			for _, subfolder := range foldersByParentID[folder.Id] {
+				if sub := outline(subfolder); len(sub.AllFeeds()) > 0 {
+					opmlfolder.Folders = append(opmlfolder.Folders, sub)
+				}
+			}
+			for _, feed := range feedsByFolderID[folder.Id] {
 				opmlfolder.Feeds = append(opmlfolder.Feeds, opml.Feed{
 					Title:   feed.Title,
 					FeedUrl: feed.FeedLink,
 					SiteUrl: feed.Link,
 				})
 			}
-			doc.Folders = append(doc.Folders, opmlfolder)
+			return opmlfolder
+		}
+		for _, folder := range topFolders {
+			if opmlfolder := outline(folder); len(opmlfolder.AllFeeds()) > 0 {
+				doc.Folders = append(doc.Folders, opmlfolder)
+			}
 		}
 
 		c.Out.Write([]byte(doc.OPML()))
@@ -494,14 +2261,21 @@ func (s *Server) handleOPMLExport(c *router.Context) {
 }
 
 func (s *Server) handlePageCrawl(c *router.Context) {
+	db := s.requestDB(c)
 	url := c.Req.URL.Query().Get("url")
+	blockedHosts, err := db.BlockedHosts()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	opts := sanitizeOptions(blockedHosts, nil)
 
 	if newUrl := silo.RedirectURL(url); newUrl != "" {
 		url = newUrl
 	}
 	if content := silo.VideoIFrame(url); content != "" {
 		c.JSON(http.StatusOK, map[string]string{
-			"content": sanitizer.Sanitize(url, content),
+			"content": sanitizer.SanitizeWithOptions(url, content, opts),
 		})
 		return
 	}
@@ -519,7 +2293,7 @@ func (s *Server) handlePageCrawl(c *router.Context) {
 		})
 		return
 	}
-	content = sanitizer.Sanitize(url, content)
+	content = sanitizer.SanitizeWithOptions(url, content, opts)
 	c.JSON(http.StatusOK, map[string]string{
 		"content": content,
 	})
@@ -1,15 +1,28 @@
 package server
 
 import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/nkanaev/yarr/src/activitypub"
+	"github.com/nkanaev/yarr/src/assets"
+	"github.com/nkanaev/yarr/src/server/auth"
 	"github.com/nkanaev/yarr/src/storage"
 )
 
@@ -52,6 +65,96 @@ func TestStaticBanTemplates(t *testing.T) {
 	}
 }
 
+func TestStaticCaching(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	get := func(url string, header ...string) *http.Response {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", url, nil)
+		if len(header) == 2 {
+			request.Header.Set(header[0], header[1])
+		}
+		handler.ServeHTTP(recorder, request)
+		return recorder.Result()
+	}
+
+	index := get("/")
+	if cc := index.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("index: unexpected cache-control: %q", cc)
+	}
+	body, _ := io.ReadAll(index.Body)
+	match := regexp.MustCompile(`\./static/javascripts/app\.([0-9a-f]{10})\.js`).FindSubmatch(body)
+	if match == nil {
+		t.Fatal("hashed app.js not linked")
+	}
+	hash := string(match[1])
+
+	hashed := get("/static/javascripts/app." + hash + ".js")
+	if hashed.StatusCode != 200 {
+		t.Fatalf("hashed: unexpected status: %d", hashed.StatusCode)
+	}
+	if cc := hashed.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("hashed: unexpected cache-control: %q", cc)
+	}
+	if ct := hashed.Header.Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("hashed: unexpected content-type: %q", ct)
+	}
+
+	for _, url := range []string{"/static/javascripts/app.js", "/static/javascripts/app.0123456789.js"} {
+		response := get(url)
+		if response.StatusCode != 200 {
+			t.Fatalf("%s: unexpected status: %d", url, response.StatusCode)
+		}
+		if cc := response.Header.Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("%s: unexpected cache-control: %q", url, cc)
+		}
+	}
+
+	if status := get("/static/javascripts/app.js", "If-None-Match", `"`+hash+`"`).StatusCode; status != 304 {
+		t.Errorf("revalidation: unexpected status: %d", status)
+	}
+	if status := get("/static/javascripts/").StatusCode; status != 404 {
+		t.Errorf("directory: unexpected status: %d", status)
+	}
+}
+
+func TestThemesOverlay(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "stylesheets"), 0755)
+	os.WriteFile(filepath.Join(dir, "stylesheets", "custom.css"), []byte("body { color: red }"), 0644)
+	os.Symlink(filepath.Join(dir, "..", "outside.css"), filepath.Join(dir, "stylesheets", "outside.css"))
+	os.WriteFile(filepath.Join(dir, "..", "outside.css"), []byte("secret"), 0644)
+	if err := assets.SetOverlay(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer assets.SetOverlay("")
+
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	get := func(url string) (int, string) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		return recorder.Code, recorder.Body.String()
+	}
+
+	if _, body := get("/"); !strings.Contains(body, "./static/stylesheets/custom.") {
+		t.Error("custom stylesheet not linked")
+	}
+	if code, body := get("/static/stylesheets/custom.css"); code != 200 || body != "body { color: red }" {
+		t.Errorf("custom stylesheet: unexpected response: %d %q", code, body)
+	}
+	if code, _ := get("/static/javascripts/app.js"); code != 200 {
+		t.Errorf("built-in script: unexpected status: %d", code)
+	}
+	if code, body := get("/static/stylesheets/outside.css"); code != 404 || strings.Contains(body, "secret") {
+		t.Errorf("symlink out of the directory: unexpected response: %d %q", code, body)
+	}
+}
+
 func TestIndexGzipped(t *testing.T) {
 	log.SetOutput(io.Discard)
 	db, _ := storage.New(":memory:")
@@ -79,7 +182,7 @@ func TestFeedIcons(t *testing.T) {
 	log.SetOutput(io.Discard)
 	db, _ := storage.New(":memory:")
 	icon := []byte("test")
-	feed := db.CreateFeed("", "", "", "", nil)
+	feed, _ := db.CreateFeed("", "", "", "http://example.com/feed.xml", "", nil)
 	db.UpdateFeedIcon(feed.Id, &icon)
 	log.SetOutput(os.Stderr)
 
@@ -87,7 +190,8 @@ func TestFeedIcons(t *testing.T) {
 	url := fmt.Sprintf("/api/feeds/%d/icon", feed.Id)
 	request := httptest.NewRequest("GET", url, nil)
 
-	handler := NewServer(db, "127.0.0.1:8000").handler()
+	server := NewServer(db, "127.0.0.1:8000")
+	handler := server.handler()
 	handler.ServeHTTP(recorder, request)
 	response := recorder.Result()
 
@@ -111,4 +215,1013 @@ func TestFeedIcons(t *testing.T) {
 	if response2.StatusCode != http.StatusNotModified {
 		t.Fatal("got", response2.StatusCode)
 	}
+
+	// a different icon found by the worker
+	db.ReplaceFeedIcon(feed.Id, []byte("new icon"), storage.IconSource{})
+	server.iconChanged(feed.Id)
+	recorder3 := httptest.NewRecorder()
+	handler.ServeHTTP(recorder3, request2)
+	if recorder3.Code != http.StatusOK || recorder3.Body.String() != "new icon" {
+		t.Fatalf("changed icon not served: %d %q", recorder3.Code, recorder3.Body.String())
+	}
+}
+
+func TestFolderErrorStatus(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	folder, _ := db.CreateFolder("folder1")
+	db.CreateFolder("folder2")
+	log.SetOutput(os.Stderr)
+
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	cases := []struct {
+		method, url, body string
+		status            int
+	}{
+		{"PUT", fmt.Sprintf("/api/folders/%d", folder.Id), `{"title": "folder2"}`, http.StatusConflict},
+		{"PUT", fmt.Sprintf("/api/folders/%d", folder.Id), `{"title": " "}`, http.StatusBadRequest},
+		{"PUT", "/api/folders/100500", `{"title": "folder3"}`, http.StatusNotFound},
+		{"DELETE", "/api/folders/100500", ``, http.StatusNotFound},
+		{"POST", "/api/feeds/bulk", `{"action": "move", "feed_ids": [100500]}`, http.StatusNotFound},
+		{"POST", "/api/feeds/bulk", `{"action": "delete", "feed_ids": [100500]}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		handler.ServeHTTP(recorder, request)
+		if recorder.Result().StatusCode != tc.status {
+			t.Errorf("%s %s %s: expected %d, got %d", tc.method, tc.url, tc.body, tc.status, recorder.Result().StatusCode)
+		}
+	}
+}
+
+func TestNestedFolders(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	parent, _ := db.CreateFolder("parent")
+	child, _ := db.CreateFolder("child")
+	db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", &child.Id)
+	log.SetOutput(os.Stderr)
+
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder
+	}
+	cases := []struct {
+		url, body string
+		status    int
+	}{
+		{fmt.Sprintf("/api/folders/%d", child.Id), fmt.Sprintf(`{"parent_id": %d}`, parent.Id), http.StatusOK},
+		{fmt.Sprintf("/api/folders/%d", parent.Id), fmt.Sprintf(`{"parent_id": %d}`, child.Id), http.StatusBadRequest},
+		{fmt.Sprintf("/api/folders/%d", parent.Id), `{"parent_id": 100500}`, http.StatusUnprocessableEntity},
+		{fmt.Sprintf("/api/folders/%d", parent.Id), `{"parent_id": "x"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		if have := request("PUT", tc.url, tc.body).Code; have != tc.status {
+			t.Errorf("PUT %s %s: expected %d, got %d", tc.url, tc.body, tc.status, have)
+		}
+	}
+
+	export := request("GET", "/opml/export", "").Body.String()
+	if !regexp.MustCompile(`<outline text="parent">\s*<outline text="child">\s*<outline type="rss"`).MatchString(export) {
+		t.Errorf("folders not nested in the export:\n%s", export)
+	}
+
+	if have := request("PUT", fmt.Sprintf("/api/folders/%d", child.Id), `{"parent_id": null}`).Code; have != http.StatusOK {
+		t.Fatalf("moving to the top level: got %d", have)
+	}
+	folders, _ := db.ListFolders()
+	for _, folder := range folders {
+		if folder.ParentId != nil {
+			t.Errorf("folder not moved to the top level: %#v", folder)
+		}
+	}
+}
+
+func TestFeedRetention(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	log.SetOutput(os.Stderr)
+
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	url := fmt.Sprintf("/api/feeds/%d", feed.Id)
+	cases := []struct {
+		body   string
+		status int
+		want   *storage.Retention
+	}{
+		{`{"retention": {"days": 30}}`, http.StatusOK, &storage.Retention{Days: 30}},
+		{`{"retention": {"days": -1}}`, http.StatusBadRequest, &storage.Retention{Days: 30}},
+		{`{"retention": "forever"}`, http.StatusBadRequest, &storage.Retention{Days: 30}},
+		{`{"retention": null}`, http.StatusOK, nil},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("PUT", url, strings.NewReader(tc.body)))
+		if recorder.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.body, tc.status, recorder.Code)
+		}
+		feed, _ := db.GetFeed(feed.Id)
+		if !reflect.DeepEqual(feed.Retention, tc.want) {
+			t.Errorf("%s: want %v, have %v", tc.body, tc.want, feed.Retention)
+		}
+	}
+}
+
+func TestFeedUpdate(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	log.SetOutput(os.Stderr)
+
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	url := fmt.Sprintf("/api/feeds/%d", feed.Id)
+	put := func(body string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("PUT", url, strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	// nothing is stored if any of the values is invalid
+	if code := put(`{"title": "renamed", "daily_limit": 5, "accept_language": "en_US"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+	if feed, _ := db.GetFeed(feed.Id); feed.Title != "feed" || feed.DailyLimit != 0 {
+		t.Fatalf("feed partially updated: %#v", feed)
+	}
+
+	if code := put(`{"title": "renamed", "daily_limit": 5, "accept_language": " de, en;q=0.5 "}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	feed, _ = db.GetFeed(feed.Id)
+	if feed.Title != "renamed" || feed.DailyLimit != 5 || feed.AcceptLanguage != "de, en;q=0.5" {
+		t.Fatalf("unexpected feed: %#v", feed)
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/feeds", nil))
+	timing := recorder.Result().Header.Get("Server-Timing")
+	if !strings.HasPrefix(timing, `db;desc="queries: 1"`) {
+		t.Fatalf("invalid Server-Timing header: %q", timing)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/metrics", nil))
+	var body struct {
+		Routes map[string]struct {
+			Requests int64 `json:"requests"`
+			Queries  int64 `json:"queries"`
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(recorder.Result().Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if stats := body.Routes["GET /api/feeds"]; stats.Requests != 1 || stats.Queries != 1 {
+		t.Fatalf("invalid route stats: %#v", body.Routes)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	server := NewServer(db, "127.0.0.1:8000")
+	server.RateLimit = 2
+	handler := server.handler()
+
+	request := func(remoteAddr string) *http.Response {
+		req := httptest.NewRequest("GET", "/api/feeds", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", "reader/1.0")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Result()
+	}
+	for i := 0; i < 2; i++ {
+		if res := request("10.0.0.1:1234"); res.StatusCode != http.StatusOK {
+			t.Fatalf("want 200, have %d", res.StatusCode)
+		}
+	}
+	res := request("10.0.0.1:1234")
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "30" {
+		t.Fatalf("want 429 with Retry-After 30, have %d %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+	// other clients aren't affected
+	if res := request("10.0.0.2:1234"); res.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, have %d", res.StatusCode)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/metrics/clients", nil))
+	var body struct {
+		Clients []clientStats `json:"clients"`
+	}
+	if err := json.NewDecoder(recorder.Result().Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Clients) == 0 {
+		t.Fatal("no clients")
+	}
+	busiest := body.Clients[0]
+	if busiest.Client != "web 10.0.0.1" || busiest.Requests != 3 || busiest.Limited != 1 || busiest.UserAgent != "reader/1.0" {
+		t.Fatalf("invalid client stats: %#v", busiest)
+	}
+}
+
+func TestRateLimitByCredentials(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	server := NewServer(db, "127.0.0.1:8000")
+	server.Username, server.Password = "user", "pass"
+	server.RateLimit = 1
+	handler := server.handler()
+
+	login := httptest.NewRecorder()
+	auth.Authenticate(login, "user", "pass", "", false)
+	cookie := login.Result().Cookies()[0]
+	web := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/api/feeds", nil)
+		req.RemoteAddr = remoteAddr
+		req.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Result().StatusCode
+	}
+	fever := func(remoteAddr, apiKey string) int {
+		req := httptest.NewRequest("POST", "/fever/?api", strings.NewReader("api_key="+apiKey))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Result().StatusCode
+	}
+
+	// the session is limited wherever it comes from
+	if have := web("10.0.0.1:1234"); have != http.StatusOK {
+		t.Fatalf("want 200, have %d", have)
+	}
+	if have := web("10.0.0.2:1234"); have != http.StatusTooManyRequests {
+		t.Fatalf("want 429 for the same session, have %d", have)
+	}
+
+	// so is the Fever API key, apart from the web session
+	apiKey := fmt.Sprintf("%x", md5.Sum([]byte("user:pass")))
+	if have := fever("10.0.0.1:1234", apiKey); have != http.StatusOK {
+		t.Fatalf("want 200, have %d", have)
+	}
+	if have := fever("10.0.0.2:1234", apiKey); have != http.StatusTooManyRequests {
+		t.Fatalf("want 429 for the same API key, have %d", have)
+	}
+	// wrong keys go by the address
+	if have := fever("10.0.0.1:1234", "wrong"); have != http.StatusOK {
+		t.Fatalf("want 200, have %d", have)
+	}
+	if have := fever("10.0.0.1:1234", "wrong2"); have != http.StatusTooManyRequests {
+		t.Fatalf("want 429 for the same address, have %d", have)
+	}
+
+	clients := make([]string, 0)
+	for _, stats := range server.clients.snapshot() {
+		clients = append(clients, stats.Client)
+	}
+	sort.Strings(clients)
+	if want := []string{"fever 10.0.0.1", "fever user", "web user"}; !reflect.DeepEqual(clients, want) {
+		t.Fatalf("want clients %v, have %v", want, clients)
+	}
+}
+
+func TestReload(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	server := NewServer(db, "127.0.0.1:8000")
+	handler := server.handler()
+
+	status := func() int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/feeds", nil))
+		return recorder.Result().StatusCode
+	}
+	if status() != http.StatusOK {
+		t.Fatal("expected unauthenticated access")
+	}
+
+	server.Reload(LiveConfig{Username: "user", Password: "pass"})
+	if have := status(); have != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after enabling auth, got %d", have)
+	}
+
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	server.Reload(LiveConfig{AllowedNetworks: []*net.IPNet{network}})
+	if have := status(); have != http.StatusForbidden {
+		t.Fatalf("expected 403 after restricting networks, got %d", have)
+	}
+}
+
+func TestOPMLMail(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	defer log.SetOutput(os.Stderr)
+	server := NewServer(db, "127.0.0.1:8000")
+	server.Username, server.Password = "user", "pass"
+	handler := server.handler()
+
+	msg := "Content-Type: text/x-opml\r\n\r\n" +
+		`<opml><body><outline type="rss" text="local" xmlUrl="http://127.0.0.1:1/feed.xml"/></body></opml>`
+	post := func(url string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", url, strings.NewReader(msg)))
+		return recorder.Result().StatusCode
+	}
+	if have := post("/opml/mail?token="); have != http.StatusNotFound {
+		t.Fatalf("expected 404 without a configured token, got %d", have)
+	}
+	server.MailToken = "secret"
+	if have := post("/opml/mail?token=wrong"); have != http.StatusForbidden {
+		t.Fatalf("expected 403 for a wrong token, got %d", have)
+	}
+	if have := post("/opml/mail?token=secret"); have != http.StatusOK {
+		t.Fatalf("expected 200, got %d", have)
+	}
+	if feeds, _ := db.ListFeeds(); len(feeds) != 1 || feeds[0].FeedLink != "http://127.0.0.1:1/feed.xml" {
+		t.Fatalf("feed not imported: %#v", feeds)
+	}
+}
+
+func TestItemTags(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{{GUID: "tagged", FeedId: feed.Id}, {GUID: "other", FeedId: feed.Id}})
+	items, _ := db.ListItems(storage.ItemFilter{}, 2, true, false)
+	item := items[0]
+	if item.GUID != "tagged" {
+		item = items[1]
+	}
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder
+	}
+
+	url := fmt.Sprintf("/api/items/%d/tags", item.Id)
+	if code := request("POST", url, `{"tag": "Recipes"}`).Code; code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := request("POST", url, `{"tag": ""}`).Code; code != http.StatusBadRequest {
+		t.Errorf("empty tag: unexpected status: %d", code)
+	}
+	if code := request("POST", "/api/items/100500/tags", `{"tag": "recipes"}`).Code; code != http.StatusNotFound {
+		t.Errorf("missing item: unexpected status: %d", code)
+	}
+
+	var list struct {
+		List []storage.Item `json:"list"`
+	}
+	json.NewDecoder(request("GET", "/api/items?tag=recipes", "").Body).Decode(&list)
+	if len(list.List) != 1 || list.List[0].Id != item.Id || !reflect.DeepEqual(list.List[0].Tags, []string{"recipes"}) {
+		t.Errorf("unexpected items: %#v", list.List)
+	}
+	var stats []storage.TagStat
+	json.NewDecoder(request("GET", "/api/tags", "").Body).Decode(&stats)
+	if want := []storage.TagStat{{Tag: "recipes", TotalCount: 1, UnreadCount: 1}}; !reflect.DeepEqual(stats, want) {
+		t.Errorf("want %#v, have %#v", want, stats)
+	}
+
+	if code := request("DELETE", url+"?tag=recipes", "").Code; code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	var tags []string
+	json.NewDecoder(request("GET", url, "").Body).Decode(&tags)
+	if tags == nil || len(tags) != 0 {
+		t.Errorf("tags not removed: %#v", tags)
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{
+		{GUID: "1", FeedId: feed.Id, Title: "golang news"},
+		{GUID: "2", FeedId: feed.Id, Title: "other news"},
+	})
+	db.SyncSearch()
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder
+	}
+
+	recorder := request("POST", "/api/searches", `{"title": "Go", "query": "golang"}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status: %d", recorder.Code)
+	}
+	var search storage.SavedSearch
+	json.NewDecoder(recorder.Body).Decode(&search)
+	if code := request("POST", "/api/searches", `{"title": ""}`).Code; code != http.StatusBadRequest {
+		t.Errorf("empty title: unexpected status: %d", code)
+	}
+
+	var list struct {
+		List []storage.Item `json:"list"`
+	}
+	json.NewDecoder(request("GET", fmt.Sprintf("/api/items?search_id=%d", search.Id), "").Body).Decode(&list)
+	if len(list.List) != 1 || list.List[0].Title != "golang news" {
+		t.Errorf("unexpected items: %#v", list.List)
+	}
+	if code := request("GET", "/api/items?search_id=100500", "").Code; code != http.StatusNotFound {
+		t.Errorf("missing search: unexpected status: %d", code)
+	}
+
+	var status struct {
+		SearchStats []storage.SavedSearchStat `json:"search_stats"`
+	}
+	json.NewDecoder(request("GET", "/api/status", "").Body).Decode(&status)
+	if want := []storage.SavedSearchStat{{SearchId: search.Id, UnreadCount: 1}}; !reflect.DeepEqual(status.SearchStats, want) {
+		t.Errorf("want %#v, have %#v", want, status.SearchStats)
+	}
+
+	request("PUT", fmt.Sprintf("/api/items?search_id=%d", search.Id), "")
+	if stats, _ := db.FeedStats(); len(stats) != 1 || stats[0].UnreadCount != 1 {
+		t.Errorf("want only the matching item marked read, have %#v", stats)
+	}
+
+	url := fmt.Sprintf("/api/searches/%d", search.Id)
+	if code := request("PUT", url, `{"title": "News", "query": "news"}`).Code; code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	json.NewDecoder(request("GET", url, "").Body).Decode(&search)
+	if search.Title != "News" || search.Query != "news" {
+		t.Errorf("search not updated: %#v", search)
+	}
+	if code := request("DELETE", url, "").Code; code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := request("GET", url, "").Code; code != http.StatusNotFound {
+		t.Errorf("deleted search: unexpected status: %d", code)
+	}
+}
+
+func TestItemBundle(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{
+		{GUID: "1", FeedId: feed.Id, Link: "/1", Content: `<p>one</p><script>alert(1)</script>`},
+		{GUID: "2", FeedId: feed.Id, Link: "/2", Content: `<p>two</p>`},
+		{GUID: "3", FeedId: feed.Id, Link: "/3", Content: `<p>three</p>`},
+	})
+	items, _ := db.ListItems(storage.ItemFilter{}, 3, false, false)
+	db.UpdateItemStatus(items[2].Id, storage.READ)
+	server := NewServer(db, "127.0.0.1:8000")
+	server.Username = "user"
+	server.Password = "pass"
+	handler := server.handler()
+	login := httptest.NewRecorder()
+	auth.Authenticate(login, "user", "pass", "", false)
+	cookie := login.Result().Cookies()[0]
+
+	get := func(url string, header ...string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", url, nil)
+		request.AddCookie(cookie)
+		if len(header) == 2 {
+			request.Header.Set(header[0], header[1])
+		}
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	var bundle struct {
+		Items     []storage.Item `json:"items"`
+		UnreadIds []int64        `json:"unread_ids"`
+		Feeds     []storage.Feed `json:"feeds"`
+	}
+	recorder := get("/api/items/bundle")
+	json.NewDecoder(recorder.Body).Decode(&bundle)
+	if len(bundle.Items) != 2 || len(bundle.UnreadIds) != 2 || len(bundle.Feeds) != 1 {
+		t.Fatalf("unexpected bundle: %#v", bundle)
+	}
+	if item := bundle.Items[1]; item.Content != "<p>one</p>" || item.Link != "http://example.com/1" {
+		t.Errorf("unexpected item: %#v", item)
+	}
+
+	etag := recorder.Header().Get("ETag")
+	if code := get("/api/items/bundle", "If-None-Match", etag).Code; code != http.StatusNotModified {
+		t.Errorf("unchanged bundle: unexpected status: %d", code)
+	}
+	db.UpdateItemStatus(items[0].Id, storage.READ)
+	if code := get("/api/items/bundle", "If-None-Match", etag).Code; code != http.StatusOK {
+		t.Errorf("changed bundle: unexpected status: %d", code)
+	}
+
+	json.NewDecoder(get(fmt.Sprintf("/api/items/bundle?since_id=%d", items[0].Id)).Body).Decode(&bundle)
+	if len(bundle.Items) != 1 || bundle.Items[0].Id != items[1].Id {
+		t.Errorf("unexpected items since %d: %#v", items[0].Id, bundle.Items)
+	}
+
+	// without logging in
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/manifest.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("manifest: unexpected status: %d", recorder.Code)
+	}
+	recorder = get("/sw.js")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Header().Get("Content-Type"), "javascript") {
+		t.Errorf("service worker: unexpected response: %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestItemContent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{{
+		GUID:    "item",
+		FeedId:  feed.Id,
+		Link:    "/post",
+		Content: `<p>text</p><script>alert(1)</script>`,
+	}, {
+		GUID:    "links",
+		FeedId:  feed.Id,
+		Link:    "/links",
+		Content: `<p>see <a href="/other">the other post</a></p>`,
+	}})
+	items, _ := db.ListItems(storage.ItemFilter{}, 2, true, false)
+	item, links := items[0], items[1]
+	if item.GUID != "item" {
+		item, links = links, item
+	}
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	get := func(url string, out interface{}) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: status code %d", url, recorder.Code)
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var details storage.Item
+	get(fmt.Sprintf("/api/items/%d?content=false", item.Id), &details)
+	if details.Content != "" || details.Link != "http://example.com/post" {
+		t.Errorf("unexpected item: %#v", details)
+	}
+
+	var content struct {
+		Content string `json:"content"`
+	}
+	get(fmt.Sprintf("/api/items/%d/content", item.Id), &content)
+	if content.Content != "<p>text</p>" {
+		t.Errorf("unexpected content: %q", content.Content)
+	}
+
+	get(fmt.Sprintf("/api/items/%d/content?format=markdown", links.Id), &content)
+	if content.Content != "see [the other post](http://example.com/other)" {
+		t.Errorf("unexpected markdown: %q", content.Content)
+	}
+	get(fmt.Sprintf("/api/items/%d?format=text", links.Id), &details)
+	if details.Content != "see the other post (http://example.com/other)" {
+		t.Errorf("unexpected text: %q", details.Content)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/items/100500/content", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("want 404 for a missing item, have %d", recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", fmt.Sprintf("/api/items/%d/content?format=pdf", item.Id), nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("want 400 for an unknown format, have %d", recorder.Code)
+	}
+}
+
+func TestBlockedHosts(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{{
+		GUID:    "item",
+		FeedId:  feed.Id,
+		Link:    "http://example.com/post",
+		Content: `<p><img src="https://pixel.tracker.com/1.gif"><img src="https://cdn.example.net/a.png">text</p>`,
+	}})
+	items, _ := db.ListItems(storage.ItemFilter{}, 1, true, false)
+	item := items[0]
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder
+	}
+	content := func() string {
+		var out struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(request("GET", fmt.Sprintf("/api/items/%d/content", item.Id), "").Body).Decode(&out)
+		return out.Content
+	}
+
+	if code := request("PUT", "/api/settings", `{"blocked_hosts": ["Tracker.com", "example.net"]}`).Code; code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := request("PUT", "/api/settings", `{"blocked_hosts": ["https://tracker.com/"]}`).Code; code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a url, got %d", code)
+	}
+	if have := content(); have != "<p>text</p>" {
+		t.Errorf("unexpected content: %q", have)
+	}
+
+	// the feed's exceptions
+	url := fmt.Sprintf("/api/feeds/%d", feed.Id)
+	if code := request("PUT", url, `{"allowed_hosts": ["cdn.example.net"]}`).Code; code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if want, have := `<p><img src="https://cdn.example.net/a.png" loading="lazy">text</p>`, content(); have != want {
+		t.Errorf("want %q, have %q", want, have)
+	}
+	if code := request("PUT", url, `{"blocked_hosts": ["example.net/a"]}`).Code; code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid host, got %d", code)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{{GUID: "item", FeedId: feed.Id, Status: storage.UNREAD}})
+	items, _ := db.ListItems(storage.ItemFilter{}, 1, true, false)
+	item := items[0]
+
+	s := NewServer(db, "127.0.0.1:8000")
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /api/ws HTTP/1.1\r\n"+
+		"Host: "+strings.TrimPrefix(server.URL, "http://")+"\r\n"+
+		"Accept-Encoding: gzip\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+
+	type message struct {
+		Type   string             `json:"type"`
+		Id     int64              `json:"id"`
+		Error  string             `json:"error"`
+		FeedId int64              `json:"feed_id"`
+		Stats  []storage.FeedStat `json:"stats"`
+	}
+	read := func() message {
+		var head [2]byte
+		io.ReadFull(r, head[:])
+		size := int(head[1] & 0x7f)
+		if size == 126 {
+			var ext [2]byte
+			io.ReadFull(r, ext[:])
+			size = int(ext[0])<<8 | int(ext[1])
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatal(err)
+		}
+		var msg message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatalf("%s: %q", err, payload)
+		}
+		return msg
+	}
+	write := func(text string) {
+		// client frames are masked, a zero mask keeps the payload as is
+		conn.Write(append([]byte{0x81, 0x80 | byte(len(text)), 0, 0, 0, 0}, text...))
+	}
+
+	if msg := read(); msg.Type != "stats" || len(msg.Stats) != 1 || msg.Stats[0].UnreadCount != 1 {
+		t.Fatalf("expected the initial stats, have %#v", msg)
+	}
+
+	write(fmt.Sprintf(`{"id": 7, "command": "star", "item_id": %d}`, item.Id))
+	got := map[string]message{}
+	for len(got) < 2 {
+		msg := read()
+		got[msg.Type] = msg
+	}
+	if got["result"].Id != 7 || got["result"].Error != "" {
+		t.Fatalf("unexpected result: %#v", got["result"])
+	}
+	if stats := got["stats"].Stats; len(stats) != 1 || stats[0].StarredCount != 1 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+
+	write(`{"id": 8, "command": "delete"}`)
+	if msg := read(); msg.Id != 8 || msg.Error == "" {
+		t.Fatalf("expected an error, have %#v", msg)
+	}
+
+	s.newItems(feed.Id, 1)
+	if msg := read(); msg.Type != "items" || msg.FeedId != feed.Id {
+		t.Fatalf("expected new items, have %#v", msg)
+	}
+}
+
+func TestActivityPubInbox(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	pem, _ := activitypub.GenerateKey()
+	key, _ := activitypub.DecodePrivateKey(pem)
+	publicPem, _ := activitypub.EncodePublicKey(&key.PublicKey)
+	fetches := 0
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", activitypub.ContentType)
+		json.NewEncoder(w).Encode(activitypub.Actor{
+			ID:        remote.URL + "/users/alice",
+			Type:      "Person",
+			Inbox:     remote.URL + "/users/alice/inbox",
+			PublicKey: activitypub.PublicKey{ID: remote.URL + "/users/alice#main-key", PublicKeyPem: publicPem},
+		})
+	}))
+	defer remote.Close()
+	actorId := remote.URL + "/users/alice"
+
+	db, _ := storage.New(":memory:")
+	feed, _ := db.CreateFeed("alice", "", actorId, actorId, "", nil)
+	db.SaveActivityPubFollow(storage.ActivityPubFollow{FeedId: feed.Id, ActorId: actorId, Inbox: actorId + "/inbox"})
+	server := NewServer(db, "127.0.0.1:8000")
+	handler := server.handler()
+
+	deliver := func(keyID string, key *rsa.PrivateKey) int {
+		body := `{"type": "Create", "actor": "` + actorId + `", "object": {
+			"id": "` + actorId + `/statuses/1", "type": "Note", "attributedTo": "` + actorId + `",
+			"content": "<p>hello</p>", "published": "2024-01-01T00:00:00Z"}}`
+		request := httptest.NewRequest("POST", "/activitypub/inbox", strings.NewReader(body))
+		if key != nil {
+			activitypub.Sign(request, []byte(body), keyID, key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Result().StatusCode
+	}
+
+	if have := deliver("", nil); have != http.StatusNotFound {
+		t.Fatalf("expected 404 without a public url, got %d", have)
+	}
+	server.PublicURL = "https://reader.example.com"
+	if have := deliver("", nil); have != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unsigned activity, got %d", have)
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if have := deliver(actorId+"#main-key", other); have != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong key, got %d", have)
+	}
+	if have := deliver(actorId+"#main-key", key); have != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", have)
+	}
+	items, _ := db.ListItems(storage.ItemFilter{FeedID: &feed.Id}, 10, false, true)
+	if len(items) != 1 || items[0].Content != "<p>hello</p>" || items[0].Link != actorId+"/statuses/1" {
+		t.Fatalf("post not stored: %#v", items)
+	}
+
+	// the key is cached, and only looked up on the actor's server
+	fetches = 0
+	if have := deliver(actorId+"#main-key", key); have != http.StatusAccepted || fetches != 0 {
+		t.Fatalf("expected 202 without fetching the key, got %d after %d fetches", have, fetches)
+	}
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	}))
+	defer internal.Close()
+	if have := deliver(internal.URL+"/key", key); have != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a key on another server, got %d", have)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/activitypub/actor", nil))
+	var actor activitypub.Actor
+	json.NewDecoder(recorder.Body).Decode(&actor)
+	if actor.ID != "https://reader.example.com/activitypub/actor" || actor.PublicKey.PublicKeyPem == "" {
+		t.Fatalf("unexpected actor: %#v", actor)
+	}
+}
+
+func TestBridgeFeeds(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	instance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch query.Get("action") {
+		case "list":
+			w.Write([]byte(`{"bridges": {"InstagramBridge": {"status": "active", "name": "Instagram Bridge",
+				"parameters": {"Username": {"u": {"name": "username", "required": true}}}}}}`))
+		case "display":
+			w.Header().Set("Content-Type", "application/atom+xml")
+			w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title>` + query.Get("u") + `</title>
+				<entry><id>post1</id><title>post</title><link href="https://example.com/p/1"/></entry></feed>`))
+		}
+	}))
+	defer instance.Close()
+
+	db, _ := storage.New(":memory:")
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	request := func(method, url, body string) *http.Response {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder.Result()
+	}
+
+	if res := request("GET", "/api/bridges", ""); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 without an instance, got %d", res.StatusCode)
+	}
+	if res := request("PUT", "/api/settings", `{"rss_bridge_url": "`+instance.URL+`"}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	if res := request("GET", "/api/bridges", ""); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	if res := request("POST", "/api/bridges", `{"bridge": "InstagramBridge", "context": "Username", "params": {}}`); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a missing parameter, got %d", res.StatusCode)
+	}
+	res := request("POST", "/api/bridges", `{"bridge": "InstagramBridge", "context": "Username", "params": {"u": "someone"}}`)
+	var body struct {
+		Status string       `json:"status"`
+		Feed   storage.Feed `json:"feed"`
+	}
+	json.NewDecoder(res.Body).Decode(&body)
+	if body.Status != "success" || body.Feed.Title != "someone" || !strings.HasPrefix(body.Feed.FeedLink, instance.URL) {
+		t.Fatalf("unexpected response: %#v", body)
+	}
+	if items, _ := db.ListItems(storage.ItemFilter{FeedID: &body.Feed.Id}, 10, false, false); len(items) != 1 {
+		t.Fatalf("expected the items of the feed, got %#v", items)
+	}
+	if res := request("GET", fmt.Sprintf("/api/feeds/%d/bridge", body.Feed.Id), ""); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+
+	// the feeds follow the instance
+	request("PUT", "/api/settings", `{"rss_bridge_url": "https://bridge.example.com/"}`)
+	feed, _ := db.GetFeed(body.Feed.Id)
+	if !strings.HasPrefix(feed.FeedLink, "https://bridge.example.com/?") || !strings.Contains(feed.FeedLink, "u=someone") {
+		t.Fatalf("feed not relinked: %s", feed.FeedLink)
+	}
+}
+
+func TestStarredArchive(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	log.SetOutput(os.Stderr)
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]storage.Item{
+		{GUID: "1", FeedId: feed.Id, Title: "starred", Link: "http://example.com/1", Content: `<p onclick="alert(1)">text</p>`},
+	})
+	items, _ := db.ListItems(storage.ItemFilter{}, 1, true, false)
+	db.UpdateItemStatus(items[0].Id, storage.STARRED)
+	db.DeleteFeed(feed.Id)
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/archive", nil))
+	var page struct {
+		List []storage.ArchivedItem `json:"list"`
+	}
+	json.NewDecoder(recorder.Body).Decode(&page)
+	if len(page.List) != 1 || page.List[0].Title != "starred" || page.List[0].ItemId != nil {
+		t.Fatalf("unexpected archive: %#v", page.List)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d", page.List[0].Id), nil))
+	var archived storage.ArchivedItem
+	json.NewDecoder(recorder.Body).Decode(&archived)
+	if archived.Content != "<p>text</p>" {
+		t.Errorf("unexpected content: %q", archived.Content)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", fmt.Sprintf("/api/archive/%d", page.List[0].Id), nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("unexpected status: %d", recorder.Code)
+	}
+}
+
+func TestWatchFeeds(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Widget</title></head><body><p class="price">$120</p></body></html>`))
+	}))
+	defer page.Close()
+
+	db, _ := storage.New(":memory:")
+	handler := NewServer(db, "127.0.0.1:8000").handler()
+	request := func(method, url, body string) *http.Response {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return recorder.Result()
+	}
+
+	if res := request("POST", "/api/watches", `{"url": "`+page.URL+`", "selector": "p[", "condition": "changed"}`); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid selector, got %d", res.StatusCode)
+	}
+	if res := request("POST", "/api/watches", `{"url": "`+page.URL+`", "selector": "table", "condition": "changed"}`); res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a value not on the page, got %d", res.StatusCode)
+	}
+	res := request("POST", "/api/watches", `{"url": "`+page.URL+`", "selector": ".price", "condition": "below", "threshold": 100}`)
+	var body struct {
+		Status string       `json:"status"`
+		Feed   storage.Feed `json:"feed"`
+		Value  string       `json:"value"`
+	}
+	json.NewDecoder(res.Body).Decode(&body)
+	if body.Status != "success" || body.Feed.Title != "Widget" || body.Value != "$120" {
+		t.Fatalf("unexpected response: %#v", body)
+	}
+
+	res = request("PUT", fmt.Sprintf("/api/feeds/%d/watch", body.Feed.Id), `{"selector": "body .price", "condition": "changed"}`)
+	var fw storage.FeedWatch
+	json.NewDecoder(res.Body).Decode(&fw)
+	if fw.Selector != "body .price" || fw.Condition != "changed" || fw.Value == nil || *fw.Value != "$120" {
+		t.Fatalf("unexpected watch: %#v", fw)
+	}
+	if res := request("GET", "/api/feeds/12345/watch", ""); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", res.StatusCode)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	log.SetOutput(io.Discard)
+	db, _ := storage.New(":memory:")
+	defer log.SetOutput(os.Stderr)
+	db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	server := NewServer(db, "127.0.0.1:8000")
+	server.Username, server.Password = "user", "pass"
+	handler := server.handler()
+
+	get := func(authenticated bool) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/api/backup", nil)
+		if authenticated {
+			login := httptest.NewRecorder()
+			auth.Authenticate(login, "user", "pass", "", false)
+			request.AddCookie(login.Result().Cookies()[0])
+		}
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+	if have := get(false).Code; have != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", have)
+	}
+	res := get(true)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+	if !strings.HasPrefix(res.Body.String(), "SQLite format 3\x00") {
+		t.Fatalf("expected a database file, got %q", res.Body.String()[:16])
+	}
+	if cd := res.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+		t.Errorf("unexpected content-disposition: %q", cd)
+	}
 }
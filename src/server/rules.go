@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// handleFeedRuleList lists (GET) and adds (POST) the rules
+// rewriting the contents of the feed's items.
+func (s *Server) handleFeedRuleList(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "GET":
+		rules, err := db.ListFeedRules(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	case "POST":
+		var rule storage.FeedRule
+		if err := json.NewDecoder(c.Req.Body).Decode(&rule); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rule.FeedId = id
+		created, err := db.CreateFeedRule(rule)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleFeedRule(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "PUT":
+		var rule storage.FeedRule
+		if err := json.NewDecoder(c.Req.Body).Decode(&rule); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rule.Id = id
+		if err := db.UpdateFeedRule(rule); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if err := db.DeleteFeedRule(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Out.WriteHeader(http.StatusNoContent)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
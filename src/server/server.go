@@ -2,9 +2,11 @@ package server
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"sync"
 
+	"github.com/nkanaev/yarr/src/server/router"
 	"github.com/nkanaev/yarr/src/storage"
 	"github.com/nkanaev/yarr/src/worker"
 )
@@ -18,22 +20,57 @@ type Server struct {
 
 	BasePath string
 
+	// ReloadConfig, if set, re-reads the configuration and passes it
+	// to Reload. It backs the POST /api/reload endpoint.
+	ReloadConfig func() error
+
+	// guards the settings below, which may change at runtime (see Reload)
+	mu sync.RWMutex
 	// auth
 	Username string
 	Password string
+	// networks allowed to access the server (all if empty),
+	// and networks exempt from authentication
+	AllowedNetworks    []*net.IPNet
+	AuthBypassNetworks []*net.IPNet
+	// reverse proxies allowed to set X-Forwarded-* headers
+	TrustedProxies []*net.IPNet
 	// https
 	CertFile string
 	KeyFile  string
+	// token for importing OPML files sent by email, see handleOPMLMail
+	MailToken string
+	// enclosure downloads
+	DownloadDir   string
+	DownloadQuota int64
+	// API requests allowed per minute and client (unlimited if 0),
+	// see rateLimitMiddleware
+	RateLimit int64
+	// the url the server is reachable at from the internet, including
+	// the base path; enables following Fediverse accounts, see activitypub.go
+	PublicURL string
+
+	downloader *worker.Downloader
+	metrics    metrics
+	clients    clientUsage
+	events     events
+	actorKeys  actorKeys
 }
 
+// maximum size of an email posted to /opml/mail
+const maxMailSize = 10 << 20
+
 func NewServer(db *storage.Storage, addr string) *Server {
-	return &Server{
+	s := &Server{
 		db:          db,
 		Addr:        addr,
 		worker:      worker.NewWorker(db),
 		cache:       make(map[string]interface{}),
 		cache_mutex: &sync.Mutex{},
 	}
+	s.worker.SetNewItemsHandler(s.newItems)
+	s.worker.SetIconChangedHandler(s.iconChanged)
+	return s
 }
 
 func (h *Server) GetAddr() string {
@@ -44,18 +81,39 @@ func (h *Server) GetAddr() string {
 	return proto + "://" + h.Addr + h.BasePath
 }
 
+// requestDB returns the storage bound to the context of the request,
+// so that its queries are abandoned once the client goes away.
+func (s *Server) requestDB(c *router.Context) *storage.Storage {
+	return s.db.WithContext(c.Req.Context())
+}
+
 func (s *Server) Start() {
-	refreshRate := s.db.GetSettingsValueInt64("refresh_rate")
-	s.worker.FindFavicons()
+	refreshRate, err := s.db.GetSettingsValueInt64("refresh_rate")
+	if err != nil {
+		log.Print(err)
+	}
+	// index items stored by versions that didn't do it on insert
+	if err := s.db.SyncSearch(); err != nil {
+		log.Print(err)
+	}
+	s.worker.StartIconChecker()
 	s.worker.StartFeedCleaner()
+	s.worker.StartSnoozer()
 	s.worker.SetRefreshRate(refreshRate)
+	s.worker.StartDigest()
+	s.worker.StartNotifier()
+	s.worker.StartBacklog()
+	if s.DownloadDir != "" {
+		s.downloader = worker.NewDownloader(s.db, s.DownloadDir, s.DownloadQuota)
+		s.worker.SetDownloader(s.downloader)
+		s.downloader.Start()
+	}
 	if refreshRate > 0 {
 		s.worker.RefreshFeeds()
 	}
 
 	httpserver := &http.Server{Addr: s.Addr, Handler: s.handler()}
 
-	var err error
 	if s.CertFile != "" && s.KeyFile != "" {
 		err = httpserver.ListenAndServeTLS(s.CertFile, s.KeyFile)
 	} else {
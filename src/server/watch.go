@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nkanaev/yarr/src/server/router"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/watch"
+	"github.com/nkanaev/yarr/src/worker"
+)
+
+// previewWatch checks the rule on the page, responding with the problem
+// if it doesn't hold. The value found there is returned.
+func previewWatch(c *router.Context, link string, rule watch.Rule) (value, title string, ok bool) {
+	if err := rule.Validate(); err != nil {
+		writeError(c, &storage.ValidationError{Field: "rule", Reason: err.Error()})
+		return "", "", false
+	}
+	value, title, err := worker.PreviewWatch(link, rule)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return "", "", false
+	}
+	return value, title, true
+}
+
+// handleWatchCreate subscribes to a value on a web page: the feed gets
+// an item whenever the rule is triggered, see package watch.
+func (s *Server) handleWatchCreate(c *router.Context) {
+	db := s.requestDB(c)
+	if c.Req.Method != "POST" {
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var form WatchFeedForm
+	if err := json.NewDecoder(c.Req.Body).Decode(&form); err != nil {
+		log.Print(err)
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := storage.ValidateFeedLink(form.URL); err != nil {
+		writeError(c, err)
+		return
+	}
+	value, title, ok := previewWatch(c, form.URL, form.Rule)
+	if !ok {
+		return
+	}
+	if form.Title != "" {
+		title = form.Title
+	} else if title == "" {
+		title = form.URL
+	}
+	feed, err := db.CreateFeed(title, "", form.URL, form.URL, "", form.FolderID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	err = db.SaveFeedWatch(storage.FeedWatch{
+		FeedId:    feed.Id,
+		Selector:  form.Selector,
+		Attr:      form.Attr,
+		Pattern:   form.Pattern,
+		Condition: string(form.Condition),
+		Threshold: form.Threshold,
+	})
+	if err == nil {
+		err = db.SetFeedWatchValue(feed.Id, value, time.Now())
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	s.worker.FindFeedFavicon(*feed)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"feed":   feed,
+		"value":  value,
+	})
+}
+
+// handleFeedWatch returns (GET) or changes (PUT) the rule of a watching feed.
+// The value on the page is checked again with the new rule.
+func (s *Server) handleFeedWatch(c *router.Context) {
+	db := s.requestDB(c)
+	id, err := c.VarInt64("id")
+	if err != nil {
+		c.Out.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch c.Req.Method {
+	case "GET":
+		fw, err := db.GetFeedWatch(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, fw)
+	case "PUT":
+		var rule watch.Rule
+		if err := json.NewDecoder(c.Req.Body).Decode(&rule); err != nil {
+			log.Print(err)
+			c.Out.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		feed, err := db.GetFeed(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if _, err := db.GetFeedWatch(id); err != nil {
+			writeError(c, err)
+			return
+		}
+		value, _, ok := previewWatch(c, feed.FeedLink, rule)
+		if !ok {
+			return
+		}
+		fw := storage.FeedWatch{
+			FeedId:    id,
+			Selector:  rule.Selector,
+			Attr:      rule.Attr,
+			Pattern:   rule.Pattern,
+			Condition: string(rule.Condition),
+			Threshold: rule.Threshold,
+		}
+		if err := db.SaveFeedWatch(fw); err != nil {
+			writeError(c, err)
+			return
+		}
+		if err := db.SetFeedWatchValue(id, value, time.Now()); err != nil {
+			writeError(c, err)
+			return
+		}
+		updated, err := db.GetFeedWatch(id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	default:
+		c.Out.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
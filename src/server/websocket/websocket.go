@@ -0,0 +1,321 @@
+// Package websocket implements the server side of the WebSocket
+// protocol (RFC 6455), limited to what the API needs: no extensions,
+// no subprotocols, messages are read whole.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// close status codes
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeTooLarge      = 1009
+)
+
+// MaxMessageSize limits the size of the messages read from the client.
+var MaxMessageSize = 64 << 10
+
+const writeTimeout = 10 * time.Second
+
+// ErrClosed is returned by ReadMessage once the client has closed the connection.
+var ErrClosed = errors.New("websocket: connection closed")
+
+type protocolError struct {
+	code   uint16
+	reason string
+}
+
+func (e *protocolError) Error() string {
+	return "websocket: " + e.reason
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUpgrade reports whether the client asks to switch to WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// sameOrigin reports whether the page opening the connection (if any,
+// non-browser clients don't send Origin) is served by this host.
+// Cookies are sent along with cross-site WebSocket handshakes,
+// so without the check any site could act on behalf of the user.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Upgrade completes the handshake and takes over the connection.
+// If the request isn't a valid handshake, the error response is
+// written and an error returned.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, reason string) (*Conn, error) {
+		http.Error(w, reason, status)
+		return nil, fmt.Errorf("websocket: %s", strings.ToLower(reason))
+	}
+	if r.Method != "GET" {
+		return fail(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+	if !IsUpgrade(r) {
+		return fail(http.StatusBadRequest, "Not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "Unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "Invalid key")
+	}
+	if !sameOrigin(r) {
+		return fail(http.StatusForbidden, "Cross-origin request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "Connection can't be upgraded")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(writeTimeout))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, r: rw.Reader}, nil
+}
+
+// Conn is an established connection. ReadMessage must be called
+// from a single goroutine, the writes may be concurrent.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// see SetReadTimeout
+	readTimeout time.Duration
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// SetReadTimeout makes ReadMessage fail if the client sends nothing,
+// not even a ping or pong, for the given duration (0 disables it).
+func (c *Conn) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+func (c *Conn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	if c.readTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	var head [2]byte
+	if _, err = io.ReadFull(c.r, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0f
+	if head[0]&0x70 != 0 {
+		err = &protocolError{closeProtocolError, "reserved bits set"}
+		return
+	}
+	if head[1]&0x80 == 0 {
+		err = &protocolError{closeProtocolError, "unmasked client frame"}
+		return
+	}
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= opClose && (size > 125 || !fin) {
+		err = &protocolError{closeProtocolError, "invalid control frame"}
+		return
+	}
+	if size > uint64(MaxMessageSize) {
+		err = &protocolError{closeTooLarge, "message too large"}
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// ReadMessage returns the next text or binary message, answering
+// pings in the meantime. ErrClosed is returned when the client
+// closes the connection. On protocol violations the connection
+// is closed with the corresponding status code.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err == nil {
+			switch op {
+			case opPing:
+				if err := c.writeFrame(opPong, payload); err != nil {
+					return nil, err
+				}
+				continue
+			case opPong:
+				continue
+			case opClose:
+				// echo the status code
+				if len(payload) >= 2 {
+					payload = payload[:2]
+				}
+				c.writeFrame(opClose, payload)
+				c.conn.Close()
+				return nil, ErrClosed
+			case opText, opBinary:
+				if started {
+					err = &protocolError{closeProtocolError, "expected a continuation frame"}
+				}
+				started = true
+				message = payload
+			case opContinuation:
+				if !started {
+					err = &protocolError{closeProtocolError, "unexpected continuation frame"}
+				}
+				message = append(message, payload...)
+			default:
+				err = &protocolError{closeProtocolError, fmt.Sprintf("unknown opcode %d", op)}
+			}
+		}
+		if err == nil && len(message) > MaxMessageSize {
+			err = &protocolError{closeTooLarge, "message too large"}
+		}
+		if err != nil {
+			if perr, ok := err.(*protocolError); ok {
+				c.closeWith(perr.code)
+			}
+			return nil, err
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if op == opClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|op)
+	switch size := len(payload); {
+	case size <= 125:
+		frame = append(frame, byte(size))
+	case size <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(size))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(size))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// WriteMessage sends the data as a text message.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping, the client is expected to answer with a pong.
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) closeWith(code uint16) error {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	c.writeFrame(opClose, payload[:])
+	return c.conn.Close()
+}
+
+// Close sends a close frame and closes the connection.
+func (c *Conn) Close() error {
+	return c.closeWith(closeNormal)
+}
@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func dial(t *testing.T, server *httptest.Server, header string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: "+strings.TrimPrefix(server.URL, "http://")+"\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n"+header+"\r\n")
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, r, res
+}
+
+func writeClientFrame(conn net.Conn, fin bool, op byte, payload []byte) {
+	head := op
+	if fin {
+		head |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{head}
+	if len(payload) > 125 {
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|byte(len(payload)))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	size := int(head[1] & 0x7f)
+	if size == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(msg)
+		}
+	}))
+}
+
+func TestEcho(t *testing.T) {
+	server := echoServer()
+	defer server.Close()
+
+	conn, r, res := dial(t, server, "")
+	defer conn.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status: %d", res.StatusCode)
+	}
+	// the example from RFC 6455
+	if have := res.Header.Get("Sec-WebSocket-Accept"); have != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("invalid accept key: %s", have)
+	}
+
+	writeClientFrame(conn, false, opText, []byte("hello, "))
+	writeClientFrame(conn, true, opPing, []byte("ping"))
+	writeClientFrame(conn, true, opContinuation, []byte("world"))
+
+	if op, payload := readServerFrame(t, r); op != opPong || string(payload) != "ping" {
+		t.Fatalf("expected a pong, have %d %q", op, payload)
+	}
+	if op, payload := readServerFrame(t, r); op != opText || string(payload) != "hello, world" {
+		t.Fatalf("expected the message back, have %d %q", op, payload)
+	}
+
+	long := strings.Repeat("x", 300)
+	writeClientFrame(conn, true, opText, []byte(long))
+	if _, payload := readServerFrame(t, r); string(payload) != long {
+		t.Fatalf("invalid message: %q", payload)
+	}
+
+	writeClientFrame(conn, true, opClose, []byte{0x03, 0xe8})
+	if op, payload := readServerFrame(t, r); op != opClose || binary.BigEndian.Uint16(payload) != closeNormal {
+		t.Fatalf("expected a close frame, have %d %v", op, payload)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	server := echoServer()
+	defer server.Close()
+
+	conn, r, _ := dial(t, server, "")
+	defer conn.Close()
+	// unmasked
+	conn.Write([]byte{0x81, 0x01, 'x'})
+	if op, payload := readServerFrame(t, r); op != opClose || binary.BigEndian.Uint16(payload) != closeProtocolError {
+		t.Fatalf("expected a protocol error, have %d %v", op, payload)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	server := echoServer()
+	defer server.Close()
+
+	_, _, res := dial(t, server, "Origin: https://example.com\r\n")
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin handshake not rejected: %d", res.StatusCode)
+	}
+
+	_, _, res = dial(t, server, "Origin: "+server.URL+"\r\n")
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("same-origin handshake rejected: %d", res.StatusCode)
+	}
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain request not rejected: %d", res.StatusCode)
+	}
+}
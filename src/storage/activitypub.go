@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// ActivityPubFollow is a Fediverse account followed via ActivityPub.
+// Its posts are delivered to the server's inbox, so the feed isn't fetched.
+type ActivityPubFollow struct {
+	FeedId   int64  `json:"feed_id"`
+	ActorId  string `json:"actor_id"`
+	Inbox    string `json:"inbox"`
+	FollowId string `json:"follow_id"`
+	// set once the account's server has accepted the follow
+	Accepted bool `json:"accepted"`
+}
+
+// ActivityPubKey returns the private key (in PEM) the server signs
+// its requests with, creating it with generate on first use.
+func (s *Storage) ActivityPubKey(generate func() (string, error)) (string, error) {
+	var key string
+	err := s.db.QueryRow(`select private_key from activitypub_keys where id = 1`).Scan(&key)
+	if err != sql.ErrNoRows {
+		return key, err
+	}
+	if key, err = generate(); err != nil {
+		return "", err
+	}
+	// whichever key is stored first wins
+	if _, err = s.db.Exec(`insert or ignore into activitypub_keys (id, private_key) values (1, ?)`, key); err != nil {
+		return "", err
+	}
+	err = s.db.QueryRow(`select private_key from activitypub_keys where id = 1`).Scan(&key)
+	return key, err
+}
+
+// SaveActivityPubFollow records the follow of the feed, replacing the previous one.
+func (s *Storage) SaveActivityPubFollow(follow ActivityPubFollow) error {
+	_, err := s.db.Exec(`
+		insert into activitypub_follows (feed_id, actor_id, inbox, follow_id, accepted)
+		values (?, ?, ?, ?, ?)
+		on conflict (feed_id) do update set
+			actor_id = excluded.actor_id,
+			inbox = excluded.inbox,
+			follow_id = excluded.follow_id,
+			accepted = excluded.accepted`,
+		follow.FeedId, follow.ActorId, follow.Inbox, follow.FollowId, follow.Accepted,
+	)
+	return wrapError(err)
+}
+
+// GetActivityPubFollow returns the follow of the actor, ErrNotFound if it isn't followed.
+func (s *Storage) GetActivityPubFollow(actorId string) (*ActivityPubFollow, error) {
+	var follow ActivityPubFollow
+	err := s.db.QueryRow(`
+		select feed_id, actor_id, inbox, follow_id, accepted
+		from activitypub_follows where actor_id = ?`,
+		actorId,
+	).Scan(&follow.FeedId, &follow.ActorId, &follow.Inbox, &follow.FollowId, &follow.Accepted)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &follow, nil
+}
+
+// AcceptActivityPubFollow marks the follow of the actor as accepted.
+func (s *Storage) AcceptActivityPubFollow(actorId string) error {
+	return s.execOne(`update activitypub_follows set accepted = true where actor_id = ?`, actorId)
+}
+
+// ActivityPubFeeds returns the ids of the feeds followed via ActivityPub.
+func (s *Storage) ActivityPubFeeds() (map[int64]bool, error) {
+	result := make(map[int64]bool)
+	rows, err := s.db.Query(`select feed_id from activitypub_follows`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result[id] = true
+	}
+	return result, wrapError(rows.Err())
+}
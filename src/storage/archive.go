@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"time"
+)
+
+// ArchivedItem is a copy of a starred item along with the details of its
+// feed, made when it's starred (see m49_starred_archive). Unlike the item,
+// it stays after the feed is deleted and isn't subject to the purges of
+// old items; unstarring the item removes it.
+type ArchivedItem struct {
+	Id int64 `json:"id"`
+	// nil once the item is gone
+	ItemId      *int64    `json:"item_id"`
+	GUID        string    `json:"guid"`
+	FeedTitle   string    `json:"feed_title"`
+	FeedLink    string    `json:"feed_link"`
+	SiteLink    string    `json:"site_link"`
+	FolderTitle string    `json:"folder_title,omitempty"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Author      string    `json:"author,omitempty"`
+	Date        time.Time `json:"date"`
+	Content     string    `json:"content,omitempty"`
+	ImageURL    *string   `json:"image"`
+	AudioURL    *string   `json:"podcast_url"`
+	StarredAt   time.Time `json:"starred_at"`
+}
+
+const archivedItemCols = `
+	id, item_id, guid, feed_title, feed_link, site_link, ifnull(folder_title, ''),
+	title, link, ifnull(author, ''), date, image, podcast_url, starred_at`
+
+func scanArchivedItem(row interface{ Scan(...interface{}) error }, withContent bool) (ArchivedItem, error) {
+	var x ArchivedItem
+	dest := []interface{}{
+		&x.Id, &x.ItemId, &x.GUID, &x.FeedTitle, &x.FeedLink, &x.SiteLink, &x.FolderTitle,
+		&x.Title, &x.Link, &x.Author, &x.Date, &x.ImageURL, &x.AudioURL, &x.StarredAt,
+	}
+	if withContent {
+		dest = append(dest, &x.Content)
+	}
+	return x, row.Scan(dest...)
+}
+
+// ListArchivedItems returns the archived items, the most recently starred
+// first, starting after the one with the given id (if not zero).
+func (s *Storage) ListArchivedItems(after int64, limit int) ([]ArchivedItem, error) {
+	result := make([]ArchivedItem, 0)
+	cond, args := "1", []interface{}{}
+	if after != 0 {
+		cond = "(starred_at, id) < (select starred_at, id from archived_items where id = ?)"
+		args = append(args, after)
+	}
+	rows, err := s.db.Query(`
+		select `+archivedItemCols+`
+		from archived_items
+		where `+cond+`
+		order by starred_at desc, id desc
+		limit ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		x, err := scanArchivedItem(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, x)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetArchivedItem(id int64) (*ArchivedItem, error) {
+	x, err := scanArchivedItem(s.db.QueryRow(`
+		select `+archivedItemCols+`, ifnull(content, '')
+		from archived_items where id = ?`, id,
+	), true)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &x, nil
+}
+
+// DeleteArchivedItem removes the copy of an item from the archive.
+// The item itself, if it's still around, stays starred.
+func (s *Storage) DeleteArchivedItem(id int64) error {
+	return s.execOne(`delete from archived_items where id = ?`, id)
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func getArchivedGuids(list []ArchivedItem, err error) []string {
+	if err != nil {
+		panic(err)
+	}
+	guids := make([]string, len(list))
+	for i, x := range list {
+		guids[i] = x.GUID
+	}
+	return guids
+}
+
+func TestStarredArchive(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	// the most recently starred first
+	have := getArchivedGuids(db.ListArchivedItems(0, 10))
+	if want := []string{"item013", "item212", "item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	// unstarring takes the item out of the archive
+	db.UpdateItemStatus(getItem(db, "item212").Id, READ)
+	db.UpdateItemStatus(getItem(db, "item111").Id, STARRED)
+	list, _ := db.ListArchivedItems(0, 10)
+	have = getArchivedGuids(list, nil)
+	if want := []string{"item111", "item013", "item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	have = getArchivedGuids(db.ListArchivedItems(list[0].Id, 10))
+	if want := []string{"item013", "item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	// the archive outlives the feed
+	if _, err := db.DeleteFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+	archived, err := db.GetArchivedItem(list[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.ItemId != nil || archived.FeedTitle != "feed11" || archived.FolderTitle != "folder1" || archived.Title != "title111" {
+		t.Errorf("unexpected archived item: %#v", archived)
+	}
+	if list, _ := db.ListArchivedItems(0, 10); len(list) != 3 {
+		t.Errorf("want 3 archived items, have %d", len(list))
+	}
+
+	if err := db.DeleteArchivedItem(archived.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetArchivedItem(archived.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+}
+
+func TestStarredArchiveAudio(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]Item{{GUID: "1", FeedId: feed.Id, Enclosures: []Enclosure{
+		{URL: "http://example.com/cover.jpg", Type: "image/jpeg"},
+		{URL: "http://example.com/episode.mp3", Type: "audio/mpeg"},
+	}}})
+	db.UpdateItemStatus(getItem(db, "1").Id, STARRED)
+
+	list, _ := db.ListArchivedItems(0, 10)
+	if len(list) != 1 || list[0].AudioURL == nil || *list[0].AudioURL != "http://example.com/episode.mp3" {
+		t.Fatalf("want the audio enclosure archived, have %#v", list)
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// How long the daily backlog snapshots are kept.
+var BacklogRetention = time.Hour * 24 * 365
+
+// BacklogPoint is the number of unread items at the end of a day
+// (or, for the current day, at the last snapshot).
+type BacklogPoint struct {
+	Day    string `json:"day"`
+	Unread int64  `json:"unread"`
+}
+
+// RecordBacklog snapshots the number of unread items per folder as the
+// backlog of the given day (in the user's time zone, see Location),
+// replacing the previous snapshot of the day. Called periodically,
+// the last snapshot of a day ends up being kept.
+func (s *Storage) RecordBacklog(now time.Time) error {
+	loc, err := s.Location()
+	if err != nil {
+		return err
+	}
+	now = now.In(loc)
+	day := now.Format("2006-01-02")
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`delete from backlog_snapshots where day = ? or day < ?`,
+		day, now.Add(-BacklogRetention).Format("2006-01-02"))
+	if err == nil {
+		_, err = tx.Exec(`
+			insert into backlog_snapshots (day, folder_id, unread)
+			select ?, ifnull(f.folder_id, 0), sum(ifnull(c.unread, 0))
+			from feeds f
+			left join feed_counts c on c.feed_id = f.id
+			where f.deleted_at is null
+			group by f.folder_id`,
+			day,
+		)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListBacklog returns the daily backlog of the folder (with its subfolders)
+// since the given day, the oldest first. A nil folder stands for all feeds.
+func (s *Storage) ListBacklog(folderId *int64, since time.Time) ([]BacklogPoint, error) {
+	result := make([]BacklogPoint, 0)
+	loc, err := s.Location()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	cond, args := "1", []interface{}{since.In(loc).Format("2006-01-02")}
+	if folderId != nil {
+		cond = `folder_id in (
+			with recursive ` + folderAncestors + `
+			select folder_id from folder_ancestors where ancestor_id = ?
+		)`
+		args = append(args, *folderId)
+	}
+	rows, err := s.db.Query(`
+		select day, sum(unread) from backlog_snapshots
+		where day >= ? and `+cond+`
+		group by day
+		order by day`,
+		args...,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p BacklogPoint
+		if err := rows.Scan(&p.Day, &p.Unread); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// BacklogNotification is due when the unread items in the scope of a
+// route (see NotificationRoute.BacklogThreshold) reach its threshold.
+type BacklogNotification struct {
+	Route    NotificationRoute
+	Notifier Notifier
+	// the feed's or the folder's title, empty for all feeds
+	Scope  string
+	Unread int64
+}
+
+// PendingBacklogNotifications returns the backlog routes whose threshold
+// has been reached since they last notified, see MarkBacklogNotified.
+// The routes whose backlog has gone below the threshold are re-armed.
+func (s *Storage) PendingBacklogNotifications() ([]BacklogNotification, error) {
+	result := make([]BacklogNotification, 0)
+	rows, err := s.db.Query(`
+		select
+			r.id, r.notifier_id, r.feed_id, r.folder_id, r.backlog_threshold, r.backlog_triggered,
+			n.name, n.kind, n.config,
+			coalesce((select title from feeds where id = r.feed_id), (select title from folders where id = r.folder_id), ''),
+			(select ifnull(sum(c.unread), 0)
+			 from feeds f join feed_counts c on c.feed_id = f.id
+			 where f.deleted_at is null
+			   and (r.feed_id is null or f.id = r.feed_id)
+			   and (r.folder_id is null or f.folder_id in (
+			     with recursive ` + folderAncestors + `
+			     select folder_id from folder_ancestors where ancestor_id = r.folder_id
+			   )))
+		from notification_routes r
+		join notifiers n on n.id = r.notifier_id
+		where r.backlog_threshold > 0
+		order by r.id
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	rearm := make([]int64, 0)
+	for rows.Next() {
+		var b BacklogNotification
+		var config string
+		var triggered bool
+		r := &b.Route
+		err := rows.Scan(
+			&r.Id, &r.NotifierId, &r.FeedId, &r.FolderId, &r.BacklogThreshold, &triggered,
+			&b.Notifier.Name, &b.Notifier.Kind, &config,
+			&b.Scope, &b.Unread,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		b.Notifier.Id = r.NotifierId
+		b.Notifier.Config = json.RawMessage(config)
+		switch {
+		case b.Unread >= r.BacklogThreshold && !triggered:
+			result = append(result, b)
+		case b.Unread < r.BacklogThreshold && triggered:
+			rearm = append(rearm, r.Id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, wrapError(err)
+	}
+	rows.Close()
+	for _, id := range rearm {
+		if _, err := s.db.Exec(`update notification_routes set backlog_triggered = 0 where id = ?`, id); err != nil {
+			return nil, wrapError(err)
+		}
+	}
+	return result, nil
+}
+
+// MarkBacklogNotified keeps the route from notifying again
+// until its backlog goes below the threshold.
+func (s *Storage) MarkBacklogNotified(routeId int64) error {
+	return s.execOne(`update notification_routes set backlog_triggered = 1 where id = ?`, routeId)
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBacklog(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	sub, _ := db.CreateFolder("sub")
+	db.MoveFolder(sub.Id, &scope.folder1.Id)
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/sub.xml", "", &sub.Id)
+	db.CreateItems([]Item{{GUID: "sub1", FeedId: feed.Id, Title: "sub1", Date: time.Now()}})
+
+	yesterday := time.Date(2024, 3, 1, 23, 0, 0, 0, time.Local)
+	today := yesterday.Add(2 * time.Hour)
+	if err := db.RecordBacklog(yesterday); err != nil {
+		t.Fatal(err)
+	}
+	db.UpdateItemStatus(getItem(db, "item111").Id, READ)
+	// the last snapshot of the day is kept
+	db.RecordBacklog(today)
+	db.UpdateItemStatus(getItem(db, "item121").Id, READ)
+	if err := db.RecordBacklog(today); err != nil {
+		t.Fatal(err)
+	}
+
+	since := yesterday.AddDate(0, 0, -7)
+	want := []BacklogPoint{{"2024-03-01", 4}, {"2024-03-02", 2}}
+	if have, _ := db.ListBacklog(nil, since); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	// with the subfolder
+	want = []BacklogPoint{{"2024-03-01", 3}, {"2024-03-02", 1}}
+	if have, _ := db.ListBacklog(&scope.folder1.Id, since); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	want = []BacklogPoint{{"2024-03-02", 1}}
+	if have, _ := db.ListBacklog(&sub.Id, today); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestBacklogNotifications(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	notifier, _ := db.CreateNotifier("hook", "webhook", []byte(`{"url":"http://example.com"}`))
+
+	if _, err := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, Match: "go", BacklogThreshold: 2}); err == nil {
+		t.Fatal("expected an error for a backlog route with a match")
+	}
+	route, err := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, FolderId: &scope.folder1.Id, BacklogThreshold: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// folder1 has 2 unread items
+	if pending, _ := db.PendingBacklogNotifications(); len(pending) != 0 {
+		t.Fatalf("unexpected notifications: %v", pending)
+	}
+
+	// the items don't go through backlog routes
+	db.CreateItems([]Item{{GUID: "new111", FeedId: scope.feed11.Id, Title: "new111", Date: time.Now()}})
+	if pending, _ := db.PendingNotifications(time.Now()); len(pending) != 0 {
+		t.Fatalf("unexpected notifications: %v", pending)
+	}
+
+	pending, _ := db.PendingBacklogNotifications()
+	if len(pending) != 1 || pending[0].Route.Id != route.Id || pending[0].Scope != "folder1" || pending[0].Unread != 3 {
+		t.Fatalf("unexpected notifications: %v", pending)
+	}
+	// a failed notification is retried
+	if pending, _ := db.PendingBacklogNotifications(); len(pending) != 1 {
+		t.Fatalf("want the notification again, have %v", pending)
+	}
+	db.MarkBacklogNotified(route.Id)
+	if pending, _ := db.PendingBacklogNotifications(); len(pending) != 0 {
+		t.Fatalf("unexpected notifications: %v", pending)
+	}
+
+	// going below the threshold re-arms the route
+	db.UpdateItemStatus(getItem(db, "new111").Id, READ)
+	db.PendingBacklogNotifications()
+	db.UpdateItemStatus(getItem(db, "new111").Id, UNREAD)
+	if pending, _ := db.PendingBacklogNotifications(); len(pending) != 1 {
+		t.Fatalf("want the notification again, have %v", pending)
+	}
+}
@@ -0,0 +1,10 @@
+package storage
+
+// BackupTo writes a consistent copy of the database to the path (which
+// must not exist yet) with VACUUM INTO, while the database stays in use.
+// The copy is compacted, and its secrets are still encrypted with
+// SecretKey, which has to be backed up on its own.
+func (s *Storage) BackupTo(path string) error {
+	_, err := s.db.Exec(`vacuum into ?`, path)
+	return err
+}
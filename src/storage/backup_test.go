@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupTo(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.BackupTo(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.BackupTo(path); err == nil {
+		t.Error("want an error when the backup exists")
+	}
+
+	backup, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeds, _ := db.ListFeeds()
+	backupFeeds, _ := backup.ListFeeds()
+	if len(backupFeeds) == 0 || len(backupFeeds) != len(feeds) {
+		t.Errorf("want %d feeds in the backup, have %d", len(feeds), len(backupFeeds))
+	}
+	if report, err := backup.CheckIntegrity(); err != nil || !report.OK() {
+		t.Errorf("want a sound backup, have %v (%v)", report, err)
+	}
+}
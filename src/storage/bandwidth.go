@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"time"
+)
+
+// How long the daily bandwidth history is kept.
+var FeedBandwidthRetention = time.Hour * 24 * 180
+
+type FeedBandwidthRecord struct {
+	Day      string `json:"day"`
+	Bytes    int64  `json:"bytes"`
+	Requests int64  `json:"requests"`
+}
+
+type FeedBandwidth struct {
+	FeedId   int64 `json:"feed_id"`
+	Bytes    int64 `json:"bytes"`
+	Requests int64 `json:"requests"`
+}
+
+// RecordFeedBandwidth adds a request, which downloaded the given
+// number of bytes, to the feed's total for the current day.
+func (s *Storage) RecordFeedBandwidth(feedId int64, bytes int64) error {
+	_, err := s.db.Exec(`
+		insert into feed_bandwidth (feed_id, day, bytes, requests)
+		values (?, date('now'), ?, 1)
+		on conflict (feed_id, day) do update set
+		 bytes = bytes + excluded.bytes,
+		 requests = requests + 1`,
+		feedId, bytes,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+	_, err = s.db.Exec(
+		`delete from feed_bandwidth where feed_id = ? and day < ?`,
+		feedId, time.Now().Add(-FeedBandwidthRetention).UTC().Format("2006-01-02"),
+	)
+	return wrapError(err)
+}
+
+func (s *Storage) ListFeedBandwidth(feedId int64) ([]FeedBandwidthRecord, error) {
+	result := make([]FeedBandwidthRecord, 0)
+	rows, err := s.db.Query(`
+		select day, bytes, requests from feed_bandwidth
+		where feed_id = ?
+		order by day
+	`, feedId)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r FeedBandwidthRecord
+		if err = rows.Scan(&r.Day, &r.Bytes, &r.Requests); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// FeedBandwidthTotals returns the traffic of each feed since the given
+// day, the most expensive feeds first.
+func (s *Storage) FeedBandwidthTotals(since time.Time) ([]FeedBandwidth, error) {
+	result := make([]FeedBandwidth, 0)
+	rows, err := s.db.Query(`
+		select feed_id, sum(bytes), sum(requests) from feed_bandwidth
+		where day >= ?
+		group by feed_id
+		order by sum(bytes) desc, feed_id
+	`, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r FeedBandwidth
+		if err = rows.Scan(&r.FeedId, &r.Bytes, &r.Requests); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedBandwidth(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://test.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://test.com/feed2.xml", "", nil)
+
+	db.RecordFeedBandwidth(feed1.Id, 100)
+	db.RecordFeedBandwidth(feed1.Id, 0)
+	db.RecordFeedBandwidth(feed2.Id, 500)
+
+	history, _ := db.ListFeedBandwidth(feed1.Id)
+	if len(history) != 1 || history[0].Bytes != 100 || history[0].Requests != 2 {
+		t.Fatalf("unexpected history: %#v", history)
+	}
+
+	db.db.Exec(`insert into feed_bandwidth (feed_id, day, bytes, requests) values (?, '2000-01-01', 1000, 1)`, feed1.Id)
+	totals, _ := db.FeedBandwidthTotals(time.Now().AddDate(0, 0, -1))
+	want := []FeedBandwidth{
+		{FeedId: feed2.Id, Bytes: 500, Requests: 1},
+		{FeedId: feed1.Id, Bytes: 100, Requests: 2},
+	}
+	if len(totals) != 2 || totals[0] != want[0] || totals[1] != want[1] {
+		t.Fatalf("unexpected totals: %#v", totals)
+	}
+
+	db.RecordFeedBandwidth(feed1.Id, 1)
+	if history, _ := db.ListFeedBandwidth(feed1.Id); len(history) != 1 {
+		t.Fatalf("outdated records must be removed: %#v", history)
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"encoding/json"
+)
+
+// FeedBridge is how a feed is made by RSS-Bridge (see the "rss_bridge_url"
+// setting): the bridge, its context and parameters. The feed's link is
+// built of them, so it follows the instance when it's changed.
+type FeedBridge struct {
+	FeedId  int64             `json:"feed_id"`
+	Bridge  string            `json:"bridge"`
+	Context string            `json:"context"`
+	Params  map[string]string `json:"params"`
+}
+
+// SaveFeedBridge records the bridge of the feed, replacing the previous one.
+func (s *Storage) SaveFeedBridge(fb FeedBridge) error {
+	params, err := json.Marshal(fb.Params)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		insert into feed_bridges (feed_id, bridge, context, params)
+		values (?, ?, ?, ?)
+		on conflict (feed_id) do update set
+			bridge = excluded.bridge,
+			context = excluded.context,
+			params = excluded.params`,
+		fb.FeedId, fb.Bridge, fb.Context, string(params),
+	)
+	return wrapError(err)
+}
+
+// GetFeedBridge returns the bridge of the feed, ErrNotFound if it isn't bridged.
+func (s *Storage) GetFeedBridge(feedId int64) (*FeedBridge, error) {
+	var fb FeedBridge
+	var params string
+	err := s.db.QueryRow(`
+		select feed_id, bridge, context, params from feed_bridges where feed_id = ?`,
+		feedId,
+	).Scan(&fb.FeedId, &fb.Bridge, &fb.Context, &params)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if err := json.Unmarshal([]byte(params), &fb.Params); err != nil {
+		return nil, err
+	}
+	return &fb, nil
+}
+
+// ListFeedBridges returns the bridges of all the bridged feeds.
+func (s *Storage) ListFeedBridges() ([]FeedBridge, error) {
+	result := make([]FeedBridge, 0)
+	rows, err := s.db.Query(`select feed_id, bridge, context, params from feed_bridges order by feed_id`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fb FeedBridge
+		var params string
+		if err := rows.Scan(&fb.FeedId, &fb.Bridge, &fb.Context, &params); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &fb.Params); err != nil {
+			return nil, err
+		}
+		result = append(result, fb)
+	}
+	return result, wrapError(rows.Err())
+}
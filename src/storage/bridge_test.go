@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFeedBridges(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("someone", "", "", "https://bridge.example.com/?bridge=InstagramBridge&u=someone", "", nil)
+	other, _ := db.CreateFeed("other", "", "", "https://example.com/feed.xml", "", nil)
+
+	fb := FeedBridge{FeedId: feed.Id, Bridge: "InstagramBridge", Context: "Username", Params: map[string]string{"u": "someone"}}
+	if err := db.SaveFeedBridge(fb); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := db.GetFeedBridge(feed.Id); !reflect.DeepEqual(*have, fb) {
+		t.Errorf("want %#v, have %#v", fb, have)
+	}
+	if _, err := db.GetFeedBridge(other.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	if err := db.SaveFeedBridge(FeedBridge{FeedId: 100500, Bridge: "InstagramBridge"}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("want ErrConstraint, have %v", err)
+	}
+	if have, _ := db.ListFeedBridges(); !reflect.DeepEqual(have, []FeedBridge{fb}) {
+		t.Errorf("unexpected bridges: %#v", have)
+	}
+
+	db.DeleteFeed(feed.Id)
+	if have, _ := db.ListFeedBridges(); len(have) != 0 {
+		t.Errorf("bridge not deleted with the feed: %#v", have)
+	}
+}
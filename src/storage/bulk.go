@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"strings"
+)
+
+type FeedsBulkAction string
+
+const (
+	BulkMove            FeedsBulkAction = "move"
+	BulkRefreshInterval FeedsBulkAction = "refresh_interval"
+	BulkPause           FeedsBulkAction = "pause"
+	BulkResume          FeedsBulkAction = "resume"
+	BulkDelete          FeedsBulkAction = "delete"
+	BulkDeliveryTimes   FeedsBulkAction = "delivery_times"
+)
+
+// FeedsBulkUpdate describes an operation applied to many feeds at once.
+// FolderId is used by BulkMove (nil moves feeds out of any folder),
+// RefreshInterval by BulkRefreshInterval, DeliveryTimes by BulkDeliveryTimes.
+type FeedsBulkUpdate struct {
+	Action          FeedsBulkAction
+	FolderId        *int64
+	RefreshInterval int64
+	DeliveryTimes   []string
+}
+
+func (a FeedsBulkAction) IsValid() bool {
+	switch a {
+	case BulkMove, BulkRefreshInterval, BulkPause, BulkResume, BulkDelete, BulkDeliveryTimes:
+		return true
+	}
+	return false
+}
+
+// UpdateFeedsBulk applies the update to all of the given feeds in a single
+// transaction: either every feed is updated or none is.
+func (s *Storage) UpdateFeedsBulk(feedIds []int64, update FeedsBulkUpdate) error {
+	if len(feedIds) == 0 {
+		return nil
+	}
+
+	var query string
+	var args []interface{}
+	switch update.Action {
+	case BulkMove:
+		return s.MoveFeeds(feedIds, update.FolderId)
+	case BulkDelete:
+		_, err := s.DeleteFeeds(feedIds)
+		return err
+	case BulkRefreshInterval:
+		query, args = `update feeds set refresh_interval = ?`, []interface{}{update.RefreshInterval}
+	case BulkPause:
+		query = `update feeds set paused = true`
+	case BulkResume:
+		query = `update feeds set paused = false`
+	case BulkDeliveryTimes:
+		if err := ValidateDeliveryTimes(update.DeliveryTimes); err != nil {
+			return err
+		}
+		times := strings.Join(normalizeDeliveryTimes(update.DeliveryTimes), " ")
+		query, args = `update feeds set delivery_times = ?`, []interface{}{times}
+	default:
+		return &ValidationError{"action", "unknown action"}
+	}
+	query += ` where id in (` + placeholders(len(feedIds)) + `)`
+	for _, id := range feedIds {
+		args = append(args, id)
+	}
+	_, err := s.db.Exec(query, args...)
+	return wrapError(err)
+}
+
+// MoveFeeds moves the feeds into the folder (out of any folder if nil)
+// in a single transaction. Nothing is moved if any of the feeds doesn't exist
+// (ErrNotFound) or the folder doesn't (ErrConstraint).
+func (s *Storage) MoveFeeds(feedIds []int64, folderId *int64) error {
+	ids := uniqueIds(feedIds)
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{folderId}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		`update feeds set folder_id = ?, folder_modified = true where id in (`+placeholders(len(ids))+`)`,
+		args...,
+	)
+	if err == nil {
+		var n int64
+		if n, err = result.RowsAffected(); err == nil && n != int64(len(ids)) {
+			err = ErrNotFound
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return wrapError(err)
+	}
+	return tx.Commit()
+}
+
+// DeleteFeeds removes the feeds for good like DeleteFeed, in a single
+// transaction. Nothing is deleted if any of the feeds doesn't exist (ErrNotFound).
+// Returns the number of deleted rows per table.
+func (s *Storage) DeleteFeeds(feedIds []int64) (map[string]int64, error) {
+	total := make(map[string]int64, len(feedDependents))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range uniqueIds(feedIds) {
+		var counts map[string]int64
+		counts, err = deleteFeed(tx, id)
+		if err == nil && counts["feeds"] == 0 {
+			err = ErrNotFound
+		}
+		if err != nil {
+			break
+		}
+		for table, n := range counts {
+			total[table] += n
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, wrapError(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateFeedsBulk(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	ids := []int64{scope.feed11.Id, scope.feed21.Id}
+
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkMove, FolderId: &scope.folder2.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkPause}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkRefreshInterval, RefreshInterval: 60}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		feed, _ := db.GetFeed(id)
+		if *feed.FolderId != scope.folder2.Id || !feed.Paused || feed.RefreshInterval != 60 {
+			t.Fatalf("feed not updated: %#v", feed)
+		}
+	}
+	if feed, _ := db.GetFeed(scope.feed12.Id); feed.Paused || feed.RefreshInterval != 0 {
+		t.Fatalf("unrelated feed updated: %#v", feed)
+	}
+
+	var validationErr *ValidationError
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: "unknown"}); !errors.As(err, &validationErr) {
+		t.Fatalf("unknown action: want a validation error, have %v", err)
+	}
+
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkDelete}); err != nil {
+		t.Fatal(err)
+	}
+	if feeds, _ := db.ListFeeds(); len(feeds) != 2 {
+		t.Fatalf("expected 2 feeds left, got %d", len(feeds))
+	}
+}
+
+func TestUpdateFeedsBulkDeliveryTimes(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	ids := []int64{scope.feed11.Id, scope.feed12.Id}
+
+	var validationErr *ValidationError
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkDeliveryTimes, DeliveryTimes: []string{"7am"}}); !errors.As(err, &validationErr) {
+		t.Fatalf("invalid delivery times: want a validation error, have %v", err)
+	}
+	if err := db.UpdateFeedsBulk(ids, FeedsBulkUpdate{Action: BulkDeliveryTimes, DeliveryTimes: []string{"07:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if feed, _ := db.GetFeed(id); len(feed.DeliveryTimes) != 1 || feed.DeliveryTimes[0] != "07:00" {
+			t.Fatalf("feed not updated: %#v", feed)
+		}
+	}
+}
+
+func TestMoveAndDeleteFeeds(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	ids := []int64{scope.feed11.Id, scope.feed01.Id}
+
+	if err := db.MoveFeeds(append(ids, 100500), &scope.folder2.Id); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+	if feed, _ := db.GetFeed(scope.feed01.Id); feed.FolderId != nil {
+		t.Fatalf("nothing must be moved if a feed is missing: %#v", feed)
+	}
+	missing := int64(100500)
+	if err := db.MoveFeeds(ids, &missing); !errors.Is(err, ErrConstraint) {
+		t.Fatalf("want ErrConstraint, have %v", err)
+	}
+	if err := db.MoveFeeds(append(ids, scope.feed11.Id), &scope.folder2.Id); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if feed, _ := db.GetFeed(id); feed.FolderId == nil || *feed.FolderId != scope.folder2.Id || !feed.FolderModified {
+			t.Fatalf("feed not moved: %#v", feed)
+		}
+	}
+
+	if _, err := db.DeleteFeeds(append(ids, 100500)); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+	if feeds, _ := db.ListFeeds(); len(feeds) != 4 {
+		t.Fatalf("nothing must be deleted if a feed is missing, have %d feeds", len(feeds))
+	}
+	counts, err := db.DeleteFeeds(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts["feeds"] != 2 || counts["items"] != 6 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+	if feeds, _ := db.ListFeeds(); len(feeds) != 2 {
+		t.Fatalf("expected 2 feeds left, got %d", len(feeds))
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SecretKey encrypts the secrets stored in the database (see
+// FeedCredentials), so that a copy of the database file alone doesn't
+// give them away. It's an AES-256 key kept outside of the database.
+var SecretKey []byte
+
+// ErrNoSecretKey is returned when storing or reading a secret without SecretKey.
+var ErrNoSecretKey = errors.New("no secret key is set up to encrypt the credentials")
+
+// SecretKeySize is the size of SecretKey in bytes.
+const SecretKeySize = 32
+
+// FeedCredentials authenticate the requests of a feed: either with
+// HTTP basic auth, or with a header (e.g. "Authorization: Bearer ...").
+// The password and the header value are encrypted with SecretKey.
+type FeedCredentials struct {
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	HeaderName  string `json:"header_name,omitempty"`
+	HeaderValue string `json:"header_value,omitempty"`
+}
+
+func (c FeedCredentials) empty() bool {
+	return c.Username == "" && c.Password == "" && c.HeaderName == "" && c.HeaderValue == ""
+}
+
+func validateFeedCredentials(c FeedCredentials) error {
+	if c.Username != "" && c.HeaderName != "" {
+		return &ValidationError{"credentials", "either basic auth or a header, not both"}
+	}
+	if strings.Contains(c.Username, ":") {
+		return &ValidationError{"username", "must not contain a colon"}
+	}
+	if c.HeaderName == "" && c.HeaderValue != "" {
+		return &ValidationError{"header_name", "must not be empty"}
+	}
+	for _, r := range c.HeaderName {
+		// the token characters of RFC 7230
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return &ValidationError{"header_name", fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	if strings.ContainsAny(c.HeaderValue, "\r\n") || strings.ContainsAny(c.Username+c.Password, "\r\n") {
+		return &ValidationError{"credentials", "must not contain line breaks"}
+	}
+	return nil
+}
+
+// SetFeedCredentials stores the credentials of the feed, empty ones remove them.
+func (s *Storage) SetFeedCredentials(feedId int64, c FeedCredentials) error {
+	c.Username = strings.TrimSpace(c.Username)
+	c.HeaderName = strings.TrimSpace(c.HeaderName)
+	if err := validateFeedCredentials(c); err != nil {
+		return err
+	}
+	var password, headerValue string
+	if !c.empty() {
+		var err error
+		if password, err = encryptSecret(c.Password, feedId); err != nil {
+			return err
+		}
+		if headerValue, err = encryptSecret(c.HeaderValue, feedId); err != nil {
+			return err
+		}
+	}
+	return s.execOne(`
+		update feeds
+		set auth_username = ?, auth_password = ?, auth_header = ?, auth_header_value = ?
+		where id = ?`,
+		c.Username, password, c.HeaderName, headerValue, feedId,
+	)
+}
+
+// GetFeedCredentials returns the decrypted credentials of the feed,
+// nil if it has none.
+func (s *Storage) GetFeedCredentials(feedId int64) (*FeedCredentials, error) {
+	var c FeedCredentials
+	var password, headerValue string
+	err := s.db.QueryRow(`
+		select auth_username, auth_password, auth_header, auth_header_value
+		from feeds where id = ?`, feedId,
+	).Scan(&c.Username, &password, &c.HeaderName, &headerValue)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if c.Username == "" && c.HeaderName == "" {
+		return nil, nil
+	}
+	if c.Password, err = decryptSecret(password, feedId); err != nil {
+		return nil, err
+	}
+	if c.HeaderValue, err = decryptSecret(headerValue, feedId); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// the version of the encryption scheme, should it ever change
+const secretPrefix = "v1:"
+
+// encryptSecret encrypts the value with AES-GCM. The feed id is
+// authenticated along with it: the value can't be moved to another feed.
+func encryptSecret(value string, feedId int64) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(strconv.FormatInt(feedId, 10)))
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(value string, feedId int64) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil || !strings.HasPrefix(value, secretPrefix) || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("malformed secret")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(feedId, 10)))
+	if err != nil {
+		return "", errors.New("failed to decrypt the credentials (was the secret key changed?)")
+	}
+	return string(plain), nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	if len(SecretKey) == 0 {
+		return nil, ErrNoSecretKey
+	}
+	block, err := aes.NewCipher(SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFeedCredentials(t *testing.T) {
+	defer func(key []byte) { SecretKey = key }(SecretKey)
+	SecretKey = bytes.Repeat([]byte{1}, SecretKeySize)
+
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+
+	if creds, err := db.GetFeedCredentials(feed.Id); err != nil || creds != nil {
+		t.Fatalf("want no credentials, have %v (%v)", creds, err)
+	}
+
+	want := FeedCredentials{Username: "user", Password: "secret"}
+	if err := db.SetFeedCredentials(feed.Id, want); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := db.GetFeedCredentials(feed.Id)
+	if err != nil || creds == nil || *creds != want {
+		t.Fatalf("want %v, have %v (%v)", want, creds, err)
+	}
+	if f, _ := db.GetFeed(feed.Id); !f.HasCredentials {
+		t.Error("want the feed to have credentials")
+	}
+
+	// encrypted at rest
+	var stored string
+	db.db.QueryRow(`select auth_password from feeds where id = ?`, feed.Id).Scan(&stored)
+	if stored == "" || strings.Contains(stored, "secret") {
+		t.Errorf("unexpected stored password: %q", stored)
+	}
+
+	// the secret doesn't decrypt with another key, nor for another feed
+	other, _ := db.CreateFeed("other", "", "", "http://example.com/other.xml", "", nil)
+	db.db.Exec(`update feeds set auth_username = 'user', auth_password = ? where id = ?`, stored, other.Id)
+	if _, err := db.GetFeedCredentials(other.Id); err == nil {
+		t.Error("want the copied secret to be rejected")
+	}
+	SecretKey = bytes.Repeat([]byte{2}, SecretKeySize)
+	if _, err := db.GetFeedCredentials(feed.Id); err == nil {
+		t.Error("want an error with another key")
+	}
+
+	if err := db.SetFeedCredentials(feed.Id, FeedCredentials{Username: "a", HeaderName: "X-Token"}); err == nil {
+		t.Error("want an error for basic auth along with a header")
+	}
+	if err := db.SetFeedCredentials(feed.Id, FeedCredentials{HeaderName: "Bad Header", HeaderValue: "x"}); err == nil {
+		t.Error("want an error for an invalid header name")
+	}
+
+	if err := db.SetFeedCredentials(feed.Id, FeedCredentials{}); err != nil {
+		t.Fatal(err)
+	}
+	if creds, _ := db.GetFeedCredentials(feed.Id); creds != nil {
+		t.Errorf("want the credentials removed, have %v", creds)
+	}
+
+	SecretKey = nil
+	if err := db.SetFeedCredentials(feed.Id, want); err != ErrNoSecretKey {
+		t.Errorf("want ErrNoSecretKey, have %v", err)
+	}
+}
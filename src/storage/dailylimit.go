@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MaxDailyLimit is the largest per-feed limit of new unread items per day.
+const MaxDailyLimit = 1000
+
+// UpdateFeedDailyLimit sets how many of the feed's new items per day
+// are left unread, the rest is stored as read. 0 disables the limit.
+func (s *Storage) UpdateFeedDailyLimit(feedId int64, limit int64) error {
+	return s.UpdateFeed(feedId, FeedUpdate{DailyLimit: &limit})
+}
+
+// dailyAllowances returns the number of new items the feeds with
+// a daily limit may still get as unread on now's day (in now's location).
+func dailyAllowances(tx *sql.Tx, now time.Time) (map[int64]int64, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	rows, err := tx.Query(`
+		select f.id, f.daily_limit - (
+			select count(*) from items i where i.feed_id = f.id and i.date_arrived >= ?
+		)
+		from feeds f
+		where f.daily_limit > 0
+	`, today.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int64]int64)
+	for rows.Next() {
+		var id, left int64
+		if err := rows.Scan(&id, &left); err != nil {
+			return nil, err
+		}
+		result[id] = left
+	}
+	return result, rows.Err()
+}
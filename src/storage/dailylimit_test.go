@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFeedDailyLimit(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("firehose", "", "", "http://example.com/feed.xml", "", nil)
+	if err := db.UpdateFeedDailyLimit(feed.Id, 2); err != nil {
+		t.Fatal(err)
+	}
+	var validationErr *ValidationError
+	if err := db.UpdateFeedDailyLimit(feed.Id, -1); !errors.As(err, &validationErr) {
+		t.Errorf("want a validation error, have %v", err)
+	}
+
+	items := func(guids ...string) []Item {
+		list := make([]Item, len(guids))
+		for i, guid := range guids {
+			list[i] = Item{GUID: guid, FeedId: feed.Id, Title: guid, Date: time.Now().Add(time.Duration(i) * time.Minute)}
+		}
+		return list
+	}
+	db.CreateItems(items("item1"))
+	// the update of an existing item doesn't count
+	db.CreateItems(items("item1", "item2", "item3"))
+	db.CreateItems(items("item4"))
+
+	unread := UNREAD
+	have := getItemGuids(db.ListItems(ItemFilter{FeedID: &feed.Id, Status: &unread}, 10, false, false))
+	if len(have) != 2 || have[0] != "item1" || have[1] != "item2" {
+		t.Errorf("want item1 and item2 unread, have %v", have)
+	}
+	if items, _ := db.ListItems(ItemFilter{FeedID: &feed.Id}, 10, false, false); len(items) != 4 {
+		t.Errorf("want all 4 items stored, have %d", len(items))
+	}
+
+	// the limit is per feed
+	other, _ := db.CreateFeed("other", "", "", "http://example.com/other.xml", "", nil)
+	list := make([]Item, 5)
+	for i := range list {
+		list[i] = Item{GUID: "other" + strconv.Itoa(i), FeedId: other.Id, Title: "other"}
+	}
+	db.CreateItems(list)
+	if items, _ := db.ListItems(ItemFilter{FeedID: &other.Id, Status: &unread}, 10, false, false); len(items) != 5 {
+		t.Errorf("want 5 unread items, have %d", len(items))
+	}
+}
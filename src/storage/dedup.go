@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+)
+
+// dedupHash identifies the same article published in several feeds
+// (e.g. a site's main feed and its per-category ones, or aggregators).
+// It's the hash of the item's link, normalized for the usual differences
+// between feeds: the scheme, the "www." prefix, the fragment, the
+// tracking parameters and the trailing slash. The items without a link
+// are identified by their title and text instead. Returns an empty string
+// if there's nothing to tell the item by.
+func dedupHash(link, title, content string) string {
+	key := normalizeLink(link)
+	if key == "" {
+		text := strings.Join(strings.Fields(strings.ToLower(
+			title+" "+htmlutil.ExtractText(content),
+		)), " ")
+		if text == "" {
+			return ""
+		}
+		key = "text:" + text
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || lower == "fbclid" || lower == "gclid" {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	result := host + strings.TrimRight(u.EscapedPath(), "/")
+	if len(params) > 0 {
+		result += "?" + strings.Join(params, "&")
+	}
+	return result
+}
+
+// collapsedDuplicates is the predicate of ItemFilter.CollapseDuplicates:
+// an item is hidden if a copy of it arrived earlier in another feed,
+// unless that feed is in the trash.
+const collapsedDuplicates = `(i.dedup_hash is null or not exists (
+	select 1 from items d
+	where d.dedup_hash = i.dedup_hash and d.id < i.id and d.feed_id != i.feed_id
+	  and d.feed_id not in (select id from feeds where deleted_at is not null)
+))`
+
+// backfillDedupHashes computes the hashes of the items stored before
+// they were introduced.
+func backfillDedupHashes(tx *sql.Tx) error {
+	rows, err := tx.Query(`select id, link, title, content from items where dedup_hash is null`)
+	if err != nil {
+		return err
+	}
+	hashes := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var link, title, content sql.NullString
+		if err := rows.Scan(&id, &link, &title, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		if hash := dedupHash(link.String, title.String, content.String); hash != "" {
+			hashes[id] = hash
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, hash := range hashes {
+		if _, err := tx.Exec(`update items set dedup_hash = ? where id = ?`, hash, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
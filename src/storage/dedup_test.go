@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupHash(t *testing.T) {
+	same := []string{
+		"https://example.com/post/",
+		"http://www.EXAMPLE.com/post",
+		"https://example.com/post#comments",
+		"https://example.com/post?utm_source=rss&utm_medium=feed",
+	}
+	want := dedupHash(same[0], "", "")
+	for _, link := range same[1:] {
+		if have := dedupHash(link, "title", "content"); have != want {
+			t.Errorf("%q: expected the same hash as %q", link, same[0])
+		}
+	}
+	if dedupHash("https://example.com/post?id=1", "", "") == dedupHash("https://example.com/post?id=2", "", "") {
+		t.Error("expected the query to tell the links apart")
+	}
+	if dedupHash("", "Title", "<p>some  text</p>") != dedupHash("", "title", "some text") {
+		t.Error("expected the items without links to be told by their text")
+	}
+	if have := dedupHash("", " ", ""); have != "" {
+		t.Errorf("expected no hash, got %q", have)
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+	now := time.Now()
+
+	db.CreateItems([]Item{
+		{GUID: "a", FeedId: feed1.Id, Title: "shared", Link: "http://example.com/shared", Date: now},
+		{GUID: "b", FeedId: feed1.Id, Title: "only1", Link: "http://example.com/only1", Date: now.Add(time.Minute)},
+	})
+	db.CreateItems([]Item{
+		{GUID: "c", FeedId: feed2.Id, Title: "shared", Link: "https://www.example.com/shared?utm_source=rss", Date: now},
+		{GUID: "d", FeedId: feed2.Id, Title: "only2", Link: "http://example.com/only2", Date: now.Add(time.Minute)},
+	})
+
+	have := getItemGuids(db.ListItems(ItemFilter{}, 10, false, false))
+	if want := []string{"a", "c", "b", "d"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	have = getItemGuids(db.ListItems(ItemFilter{CollapseDuplicates: true}, 10, false, false))
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if count, _ := db.CountItems(ItemFilter{CollapseDuplicates: true}); count != 3 {
+		t.Errorf("want 3 items, have %d", count)
+	}
+
+	// the copy shows up once the first one's feed is in the trash
+	db.TrashFeed(feed1.Id)
+	have = getItemGuids(db.ListItems(ItemFilter{CollapseDuplicates: true}, 10, false, false))
+	if want := []string{"c", "d"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxDeliveryTimes limits the number of daily deliveries of a feed.
+const MaxDeliveryTimes = 24
+
+const deliveryTimeLayout = "15:04"
+
+// ValidateDeliveryTimes checks the daily delivery times of a feed,
+// given in the local time as "HH:MM". An empty list is valid and
+// means the new items are delivered right away.
+func ValidateDeliveryTimes(times []string) error {
+	if len(times) > MaxDeliveryTimes {
+		return &ValidationError{"delivery_times", fmt.Sprintf("must have at most %d entries", MaxDeliveryTimes)}
+	}
+	for _, t := range times {
+		if _, err := time.Parse(deliveryTimeLayout, t); err != nil || len(t) != len(deliveryTimeLayout) {
+			return &ValidationError{"delivery_times", fmt.Sprintf("%q is not a HH:MM time", t)}
+		}
+	}
+	return nil
+}
+
+// normalizeDeliveryTimes sorts the times and drops the duplicates.
+func normalizeDeliveryTimes(times []string) []string {
+	result := make([]string, 0, len(times))
+	seen := make(map[string]bool)
+	for _, t := range times {
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// UpdateFeedDeliveryTimes sets the times the feed's new items are
+// delivered at, see NextDelivery. Items already held back are
+// delivered at the time scheduled when they arrived.
+func (s *Storage) UpdateFeedDeliveryTimes(feedId int64, times []string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{DeliveryTimes: nonNil(times)})
+}
+
+// NextDelivery returns the first of the daily delivery times
+// after now, in now's location.
+func NextDelivery(times []string, now time.Time) time.Time {
+	var next time.Time
+	for _, t := range times {
+		parsed, err := time.Parse(deliveryTimeLayout, t)
+		if err != nil {
+			continue
+		}
+		at := time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// deliveries returns the next delivery of the feeds with a schedule.
+// Their new items are held back (as snoozed) until then.
+func deliveries(tx *sql.Tx, now time.Time) (map[int64]time.Time, error) {
+	rows, err := tx.Query(`select id, delivery_times from feeds where delivery_times != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int64]time.Time)
+	for rows.Next() {
+		var id int64
+		var times string
+		if err := rows.Scan(&id, &times); err != nil {
+			return nil, err
+		}
+		if next := NextDelivery(strings.Fields(times), now); !next.IsZero() {
+			result[id] = next.UTC()
+		}
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateDeliveryTimes(t *testing.T) {
+	for _, times := range [][]string{nil, {"07:00"}, {"00:00", "23:59"}} {
+		if err := ValidateDeliveryTimes(times); err != nil {
+			t.Errorf("%v: unexpected error %s", times, err)
+		}
+	}
+	for _, times := range [][]string{{"7:00"}, {"24:00"}, {"07:60"}, {"noon"}, {""}} {
+		if err := ValidateDeliveryTimes(times); err == nil {
+			t.Errorf("%v: expected an error", times)
+		}
+	}
+}
+
+func TestNextDelivery(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		times []string
+		want  time.Time
+	}{
+		{[]string{"07:00", "18:30"}, time.Date(2020, 1, 1, 18, 30, 0, 0, time.UTC)},
+		{[]string{"07:00"}, time.Date(2020, 1, 2, 7, 0, 0, 0, time.UTC)},
+		{[]string{"12:00"}, time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)},
+		{nil, time.Time{}},
+	}
+	for _, c := range cases {
+		if have := NextDelivery(c.times, now); !have.Equal(c.want) {
+			t.Errorf("%v: want %s, have %s", c.times, c.want, have)
+		}
+	}
+}
+
+func TestCreateItemsHeldForDelivery(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	if err := db.UpdateFeedDeliveryTimes(feed.Id, []string{"23:59", "07:00", "07:00"}); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(feed.Id); !reflect.DeepEqual(feed.DeliveryTimes, []string{"07:00", "23:59"}) {
+		t.Fatalf("unexpected delivery times: %v", feed.DeliveryTimes)
+	}
+
+	db.CreateItems([]Item{{GUID: "item1", FeedId: feed.Id, Title: "title1", Date: time.Now()}})
+	item := getItem(db, "item1")
+	held, _ := db.GetItem(item.Id)
+	if held.Status != READ || held.SnoozedUntil == nil {
+		t.Fatalf("item not held back: %#v", held)
+	}
+	if stats, _ := db.FeedStats(); stats[0].UnreadCount != 0 {
+		t.Fatalf("held item counted as unread")
+	}
+
+	db.WakeSnoozedItems(*held.SnoozedUntil)
+	if item, _ := db.GetItem(item.Id); item.Status != UNREAD {
+		t.Fatalf("item not delivered, status %v", item.Status)
+	}
+
+	if err := db.UpdateFeedDeliveryTimes(feed.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	db.CreateItems([]Item{{GUID: "item2", FeedId: feed.Id, Title: "title2", Date: time.Now()}})
+	if status := getItem(db, "item2").Status; status != UNREAD {
+		t.Fatalf("item held back without a schedule, status %v", item.Status)
+	}
+}
+
+func TestNotificationsOfHeldItems(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.UpdateFeedDeliveryTimes(feed.Id, []string{"07:00"})
+	notifier, _ := db.CreateNotifier("hook", "webhook", nil)
+	route, _ := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id})
+
+	db.CreateItems([]Item{{GUID: "item1", FeedId: feed.Id, Title: "title1", Date: time.Now()}})
+	var queued int
+	db.db.QueryRow(`select count(*) from notification_queue`).Scan(&queued)
+	if queued != 0 {
+		t.Fatalf("held item queued for the notifications on arrival")
+	}
+
+	held, _ := db.GetItem(getItem(db, "item1").Id)
+	if woken, err := db.WakeSnoozedItems(*held.SnoozedUntil); err != nil || woken != 1 {
+		t.Fatalf("want 1 item delivered, have %d (%v)", woken, err)
+	}
+	want := map[int64][]string{route.Id: {"title1"}}
+	if have := pendingTitles(db.PendingNotifications(*held.SnoozedUntil)); !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Digest feeds are system feeds holding one synthetic item per day
+// that bundles the headlines of their folder. They are identified
+// by a feed link with the digestScheme prefix and are never fetched.
+const digestScheme = "yarr-digest:"
+
+func DigestFeedLink(folderId int64) string {
+	return fmt.Sprintf("%s%d", digestScheme, folderId)
+}
+
+func IsSystemFeed(feed Feed) bool {
+	return strings.HasPrefix(feed.FeedLink, digestScheme)
+}
+
+// GetDigestFeed returns the digest feed of the folder, creating it if needed.
+func (s *Storage) GetDigestFeed(folder Folder) (*Feed, error) {
+	return s.CreateFeed(folder.Title+" digest", "", "", DigestFeedLink(folder.Id), "", &folder.Id)
+}
+
+// ListHeadlines returns the items of the folder's feeds published
+// within [since, until), excluding digest items themselves.
+func (s *Storage) ListHeadlines(folderId int64, since, until time.Time) ([]Item, error) {
+	result := make([]Item, 0)
+	rows, err := s.db.Query(`
+		select i.id, i.guid, i.feed_id, i.title, i.link, i.date, i.status
+		from items i
+		join feeds f on f.id = i.feed_id
+		where f.folder_id = ? and f.feed_link not like ? and f.deleted_at is null
+		  and i.date >= ? and i.date < ?
+		order by f.title collate nocase, i.date
+	`, folderId, digestScheme+"%", since.UTC(), until.UTC())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var x Item
+		err = rows.Scan(&x.Id, &x.GUID, &x.FeedId, &x.Title, &x.Link, &x.Date, &x.Status)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, x)
+	}
+	return result, wrapError(rows.Err())
+}
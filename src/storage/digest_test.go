@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListHeadlines(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	digest, _ := db.GetDigestFeed(*scope.folder1)
+	if digest == nil || !IsSystemFeed(*digest) {
+		t.Fatalf("invalid digest feed: %#v", digest)
+	}
+	db.CreateItems([]Item{{GUID: "digest", FeedId: digest.Id, Title: "digest", Date: time.Now().Add(time.Hour * 24)}})
+
+	now := time.Now()
+	since := now.Add(time.Hour * 12)
+	until := now.Add(time.Hour*24*2 + time.Hour*12)
+	have := getItemGuids(db.ListHeadlines(scope.folder1.Id, since, until))
+	want := []string{"item111", "item112"}
+	if len(have) != len(want) || have[0] != want[0] || have[1] != want[1] {
+		t.Fatalf("invalid headlines\nhave: %v\nwant: %v", have, want)
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"time"
+)
+
+type DownloadStatus string
+
+const (
+	DownloadQueued      DownloadStatus = "queued"
+	DownloadInProgress  DownloadStatus = "downloading"
+	DownloadDone        DownloadStatus = "done"
+	DownloadFailed      DownloadStatus = "failed"
+	DownloadQuotaExceed DownloadStatus = "quota_exceeded"
+)
+
+type Download struct {
+	ItemId    int64          `json:"item_id"`
+	URL       string         `json:"url"`
+	Status    DownloadStatus `json:"status"`
+	Path      string         `json:"-"`
+	Size      int64          `json:"size"`
+	Attempts  int            `json:"attempts"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (s *Storage) UpdateFeedDownloadEnclosures(feedId int64, enabled bool) error {
+	return s.UpdateFeed(feedId, FeedUpdate{DownloadEnclosures: &enabled})
+}
+
+// QueueEnclosureDownloads schedules the first audio enclosure of items
+// from feeds with downloads enabled, which haven't been queued yet.
+func (s *Storage) QueueEnclosureDownloads() error {
+	_, err := s.db.Exec(`
+		insert into downloads (item_id, url, status, updated_at)
+		select i.id, e.url, ?, ?
+		from items i
+		join feeds f on f.id = i.feed_id
+		join item_enclosures e on e.item_id = i.id
+		where f.download_enclosures and f.deleted_at is null
+		  and e.position = (
+		    select min(position) from item_enclosures
+		    where item_id = i.id and type like 'audio/%'
+		  )
+		  and not exists (select 1 from downloads d where d.item_id = i.id)
+	`, DownloadQueued, time.Now().UTC())
+	return wrapError(err)
+}
+
+// ListPendingDownloads returns queued downloads, as well as failed (or
+// interrupted) ones which haven't exhausted the retries and are due for
+// another attempt.
+func (s *Storage) ListPendingDownloads(maxAttempts int, retryAfter time.Duration, limit int) ([]Download, error) {
+	result := make([]Download, 0)
+	rows, err := s.db.Query(`
+		select item_id, url, status, path, size, attempts, error, updated_at
+		from downloads
+		where status = ?
+		   or (status in (?, ?) and attempts < ? and updated_at < ?)
+		order by item_id
+		limit ?
+	`, DownloadQueued, DownloadFailed, DownloadInProgress, maxAttempts, time.Now().UTC().Add(-retryAfter), limit)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Download
+		err = rows.Scan(&d.ItemId, &d.URL, &d.Status, &d.Path, &d.Size, &d.Attempts, &d.Error, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetDownload(itemId int64) (*Download, error) {
+	var d Download
+	err := s.db.QueryRow(`
+		select item_id, url, status, path, size, attempts, error, updated_at
+		from downloads
+		where item_id = ?
+	`, itemId).Scan(&d.ItemId, &d.URL, &d.Status, &d.Path, &d.Size, &d.Attempts, &d.Error, &d.UpdatedAt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &d, nil
+}
+
+func (s *Storage) ListDownloads() ([]Download, error) {
+	result := make([]Download, 0)
+	rows, err := s.db.Query(`
+		select item_id, url, status, path, size, attempts, error, updated_at
+		from downloads
+		order by item_id desc
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Download
+		err = rows.Scan(&d.ItemId, &d.URL, &d.Status, &d.Path, &d.Size, &d.Attempts, &d.Error, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) SetDownloadStarted(itemId int64) error {
+	return s.execOne(`
+		update downloads set status = ?, attempts = attempts + 1, updated_at = ?
+		where item_id = ?
+	`, DownloadInProgress, time.Now().UTC(), itemId)
+}
+
+func (s *Storage) SetDownloadDone(itemId int64, path string, size int64) error {
+	return s.execOne(`
+		update downloads set status = ?, path = ?, size = ?, error = '', updated_at = ?
+		where item_id = ?
+	`, DownloadDone, path, size, time.Now().UTC(), itemId)
+}
+
+func (s *Storage) SetDownloadError(itemId int64, status DownloadStatus, downloadErr error) error {
+	return s.execOne(`
+		update downloads set status = ?, error = ?, updated_at = ?
+		where item_id = ?
+	`, status, downloadErr.Error(), time.Now().UTC(), itemId)
+}
+
+// RequeueQuotaExceededDownloads puts the downloads which didn't fit into
+// the quota back into the queue, once there's room for them again.
+func (s *Storage) RequeueQuotaExceededDownloads() error {
+	_, err := s.db.Exec(`
+		update downloads set status = ?, error = '', updated_at = ?
+		where status = ?
+	`, DownloadQueued, time.Now().UTC(), DownloadQuotaExceed)
+	return wrapError(err)
+}
+
+// DownloadsSize returns the total size of the downloaded files.
+func (s *Storage) DownloadsSize() (int64, error) {
+	var size int64
+	err := s.db.QueryRow(`select ifnull(sum(size), 0) from downloads where status = ?`, DownloadDone).Scan(&size)
+	return size, wrapError(err)
+}
+
+// DownloadedFiles returns the names of the downloaded files, the rows
+// of which are still around.
+func (s *Storage) DownloadedFiles() (map[string]bool, error) {
+	rows, err := s.db.Query(`select path from downloads where path != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]bool)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		result[path] = true
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEnclosureDownloads(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://test.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://test.com/feed2.xml", "", nil)
+
+	audio := []Enclosure{
+		{URL: "http://test.com/cover.jpg", Type: "image/jpeg"},
+		{URL: "http://test.com/audio.mp3", Type: "audio/mpeg"},
+		{URL: "http://test.com/audio.ogg", Type: "audio/ogg"},
+	}
+	db.CreateItems([]Item{
+		{GUID: "item11", FeedId: feed1.Id, Enclosures: audio},
+		{GUID: "item12", FeedId: feed1.Id, Enclosures: audio[:1]},
+		{GUID: "item21", FeedId: feed2.Id, Enclosures: audio},
+	})
+
+	db.QueueEnclosureDownloads()
+	if list, _ := db.ListDownloads(); len(list) != 0 {
+		t.Fatal("downloads must be enabled per feed")
+	}
+
+	db.UpdateFeedDownloadEnclosures(feed1.Id, true)
+	db.QueueEnclosureDownloads()
+	db.QueueEnclosureDownloads()
+
+	pending, _ := db.ListPendingDownloads(3, time.Hour, 10)
+	if len(pending) != 1 || pending[0].ItemId != getItem(db, "item11").Id || pending[0].URL != "http://test.com/audio.mp3" {
+		t.Fatalf("unexpected pending downloads: %#v", pending)
+	}
+	itemId := pending[0].ItemId
+
+	db.SetDownloadStarted(itemId)
+	db.SetDownloadError(itemId, DownloadFailed, errors.New("timeout"))
+	if pending, _ := db.ListPendingDownloads(3, time.Hour, 10); len(pending) != 0 {
+		t.Fatal("failed download must not be retried right away")
+	}
+	if pending, _ := db.ListPendingDownloads(3, -time.Hour, 10); len(pending) != 1 {
+		t.Fatal("failed download must be retried eventually")
+	}
+	if pending, _ := db.ListPendingDownloads(1, -time.Hour, 10); len(pending) != 0 {
+		t.Fatal("failed download must not be retried after max attempts")
+	}
+
+	db.SetDownloadDone(itemId, "1.mp3", 1024)
+	download, _ := db.GetDownload(itemId)
+	if download == nil || download.Status != DownloadDone || download.Path != "1.mp3" {
+		t.Fatalf("unexpected download: %#v", download)
+	}
+	if size, _ := db.DownloadsSize(); size != 1024 {
+		t.Fatalf("unexpected downloads size: %d", size)
+	}
+
+	// quota exceeded downloads wait for the room to be freed
+	db.SetDownloadStarted(itemId)
+	db.SetDownloadError(itemId, DownloadQuotaExceed, errors.New("download quota exceeded"))
+	if pending, _ := db.ListPendingDownloads(3, -time.Hour, 10); len(pending) != 0 {
+		t.Fatal("quota exceeded download must not be retried on its own")
+	}
+	if err := db.RequeueQuotaExceededDownloads(); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ = db.ListPendingDownloads(3, time.Hour, 10)
+	if len(pending) != 1 || pending[0].ItemId != itemId || pending[0].Status != DownloadQueued {
+		t.Fatalf("want quota exceeded download requeued, have %#v", pending)
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Enclosure is a media file of an item (an audio file, a video, an
+// image), in the order the feed lists them. The first audio one is the
+// one the UI plays, and the one downloaded (see QueueEnclosureDownloads).
+type Enclosure struct {
+	URL string `json:"url"`
+	// the MIME type, empty if the feed doesn't say
+	Type string `json:"type,omitempty"`
+	// the size in bytes, 0 if the feed doesn't say
+	Length int64 `json:"length,omitempty"`
+}
+
+// setItemEnclosures replaces the enclosures of the item.
+func setItemEnclosures(tx *sql.Tx, itemId int64, enclosures []Enclosure) error {
+	if _, err := tx.Exec(`delete from item_enclosures where item_id = ?`, itemId); err != nil {
+		return err
+	}
+	for i, e := range enclosures {
+		_, err := tx.Exec(`
+			insert into item_enclosures (item_id, position, url, type, length)
+			values (?, ?, ?, ?, ?)`,
+			itemId, i, e.URL, e.Type, e.Length,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ItemEnclosures returns the enclosures of the items, in order.
+func (s *Storage) ItemEnclosures(itemIds []int64) (map[int64][]Enclosure, error) {
+	result := make(map[int64][]Enclosure)
+	if len(itemIds) == 0 {
+		return result, nil
+	}
+	qmarks := make([]string, len(itemIds))
+	args := make([]interface{}, len(itemIds))
+	for i, id := range itemIds {
+		qmarks[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(`
+		select item_id, url, type, length from item_enclosures
+		where item_id in (`+strings.Join(qmarks, ",")+`)
+		order by item_id, position
+	`, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemId int64
+		var e Enclosure
+		if err := rows.Scan(&itemId, &e.URL, &e.Type, &e.Length); err != nil {
+			return nil, wrapError(err)
+		}
+		result[itemId] = append(result[itemId], e)
+	}
+	return result, wrapError(rows.Err())
+}
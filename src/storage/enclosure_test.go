@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItemEnclosures(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	enclosures := []Enclosure{
+		{URL: "http://example.com/ep1.mp3", Type: "audio/mpeg", Length: 1024},
+		{URL: "http://example.com/ep1.ogg", Type: "audio/ogg"},
+		{URL: "http://example.com/ep1.jpg"},
+	}
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "1", Date: time.Now(), Enclosures: enclosures},
+		{GUID: "2", FeedId: feed.Id, Title: "2", Date: time.Now()},
+	})
+
+	item := getItem(db, "1")
+	if have, _ := db.GetItem(item.Id); !reflect.DeepEqual(have.Enclosures, enclosures) {
+		t.Errorf("want %v, have %v", enclosures, have)
+	}
+	items, _ := db.ListItems(ItemFilter{}, 10, true, false)
+	for _, item := range items {
+		want := enclosures
+		if item.GUID == "2" {
+			want = nil
+		}
+		if !reflect.DeepEqual(item.Enclosures, want) {
+			t.Errorf("%s: want %v, have %v", item.GUID, want, item.Enclosures)
+		}
+	}
+
+	// an edited item replaces its enclosures
+	updated := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "1", Date: time.Now(), DateUpdated: &updated, Enclosures: enclosures[1:2]},
+	})
+	if have, _ := db.GetItem(item.Id); !reflect.DeepEqual(have.Enclosures, enclosures[1:2]) {
+		t.Errorf("want %v, have %v", enclosures[1:2], have)
+	}
+
+	db.DeleteFeed(feed.Id)
+	var count int
+	db.db.QueryRow(`select count(*) from item_enclosures`).Scan(&count)
+	if count != 0 {
+		t.Errorf("want the enclosures deleted with the feed, have %d", count)
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	db := testDB()
+	folder1, _ := db.CreateFolder("folder1")
+	folder2, _ := db.CreateFolder("folder2")
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+
+	if err := db.RenameFolder(folder1.Id, "folder2"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+	if err := db.UpdateFeedLink(feed1.Id, feed2.FeedLink); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+	if err := db.RenameFeed(100500, "title"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := db.DeleteFolder(100500); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err := db.GetItem(100500); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := db.UpdateItemStatus(100500, READ); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := db.ToggleFolderExpanded(100500, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	missing := int64(100500)
+	if err := db.UpdateFeedFolder(feed1.Id, &missing); !errors.Is(err, ErrConstraint) {
+		t.Errorf("expected constraint error, got %v", err)
+	}
+	if err := db.RenameFeed(feed1.Id, ""); !errors.Is(err, ErrConstraint) {
+		t.Errorf("expected validation error to match ErrConstraint, got %v", err)
+	}
+	if err := db.UpdateFeedFolder(feed1.Id, &folder2.Id); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
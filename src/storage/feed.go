@@ -2,7 +2,9 @@ package storage
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"strings"
+	"time"
 )
 
 type Feed struct {
@@ -15,9 +17,62 @@ type Feed struct {
 	Icon        *[]byte `json:"icon,omitempty"`
 	HasIcon     bool    `json:"has_icon"`
 	CustomOrder string  `json:"custom_order"`
+
+	// hosts trusted to be embedded in the feed's items
+	IframeHosts []string `json:"iframe_hosts,omitempty"`
+	// hosts whose images, embeds and links are stripped from the feed's items
+	// in addition to the "blocked_hosts" setting, and the ones exempt from it
+	BlockedHosts []string `json:"blocked_hosts,omitempty"`
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+
+	DownloadEnclosures bool `json:"download_enclosures"`
+
+	// paused feeds are skipped during refresh
+	Paused bool `json:"paused"`
+	// minimum number of minutes between refreshes, 0 to follow the global rate
+	RefreshInterval int64 `json:"refresh_interval"`
+	// Accept-Language header sent when fetching the feed, for sites
+	// that serve a different translation depending on it
+	AcceptLanguage string `json:"accept_language"`
+	// the language of the content (e.g. "ar"), if the feed says so
+	Language string `json:"language"`
+	// how the feed's items are identified, see ItemGUID
+	GUIDStrategy string `json:"guid_strategy"`
+	// whether the feed is fetched with credentials, see GetFeedCredentials
+	HasCredentials bool `json:"has_credentials"`
+	// local times ("07:00") the new items are delivered at, see NextDelivery
+	DeliveryTimes []string `json:"delivery_times,omitempty"`
+	// how long the read items are kept, nil to follow the setting
+	Retention *Retention `json:"retention,omitempty"`
+	// the number of new items per day left unread, 0 for all of them
+	DailyLimit int64 `json:"daily_limit"`
+	// the end of the trial of a new subscription, see StartFeedTrial
+	TrialUntil *time.Time `json:"trial_until,omitempty"`
+	// set for the feeds waiting to be purged, see TrashFeed;
+	// ListFeeds leaves them out
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// set once the user renames/moves the feed, so that imports keep their choice
+	TitleModified  bool `json:"title_modified"`
+	FolderModified bool `json:"folder_modified"`
+}
+
+// splitFields parses a space separated list column.
+func splitFields(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Fields(list)
 }
 
-func (s *Storage) CreateFeed(title, description, link, feedLink, customOrder string, folderId *int64) *Feed {
+// CreateFeed subscribes to the feed, in the default folder (see
+// DefaultFolderId) if the folder is nil.
+func (s *Storage) CreateFeed(title, description, link, feedLink, customOrder string, folderId *int64) (*Feed, error) {
+	if err := ValidateFeedLink(feedLink); err != nil {
+		return nil, err
+	}
+	title = cleanText(title, MaxTitleLength)
+	description = cleanText(description, 0)
 	if title == "" {
 		title = feedLink
 	}
@@ -37,115 +92,315 @@ func (s *Storage) CreateFeed(title, description, link, feedLink, customOrder str
 		// respected. "xxxxxxxxx" should be "low enough" to not conflict with
 		// feeds without a custom order
 
-		customOrder = "xxxxxxxxx"
+		customOrder = DefaultCustomOrder
+	}
+	if folderId == nil {
+		var err error
+		if folderId, err = s.DefaultFolderId(); err != nil {
+			return nil, err
+		}
 	}
+	// re-adding an existing feed (e.g. OPML re-import) moves it to the
+	// given folder, unless the user has moved it themselves
 	row := s.db.QueryRow(`
 		insert into feeds (title, description, link, feed_link, folder_id, custom_order)
 		values (?, ?, ?, ?, ?, ?)
-		on conflict (feed_link) do update set folder_id = ?
-        returning id`,
+		on conflict (feed_link) do update set
+			folder_id = case when folder_modified then folder_id else excluded.folder_id end,
+			deleted_at = null
+        returning id, title, folder_id, title_modified, folder_modified`,
 		title, description, link, feedLink, folderId, customOrder,
-		folderId,
 	)
 
-	var id int64
-	err := row.Scan(&id)
-	if err != nil {
-		log.Print(err)
-		return nil
-	}
-	return &Feed{
-		Id:          id,
+	feed := &Feed{
 		Title:       title,
 		Description: description,
 		Link:        link,
 		FeedLink:    feedLink,
-		FolderId:    folderId,
 		CustomOrder: customOrder,
 	}
+	err := row.Scan(&feed.Id, &feed.Title, &feed.FolderId, &feed.TitleModified, &feed.FolderModified)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return feed, nil
 }
 
-func (s *Storage) DeleteFeed(feedId int64) bool {
-	result, err := s.db.Exec(`delete from feeds where id = ?`, feedId)
+// feedDependents lists the tables referencing a feed's items or the feed
+// itself, in the order they have to be cleaned up before the feed row.
+var feedDependents = []struct {
+	table string
+	where string
+}{
+	{"item_podcast", "item_id in (select id from items where feed_id = ?)"},
+	{"playback", "item_id in (select id from items where feed_id = ?)"},
+	{"downloads", "item_id in (select id from items where feed_id = ?)"},
+	{"item_notes", "item_id in (select id from items where feed_id = ?)"},
+	{"item_enclosures", "item_id in (select id from items where feed_id = ?)"},
+	{"items", "feed_id = ?"},
+	{"feed_counts", "feed_id = ?"},
+	{"http_states", "feed_id = ?"},
+	{"feed_errors", "feed_id = ?"},
+	{"feed_error_history", "feed_id = ?"},
+	{"feed_sizes", "feed_id = ?"},
+	{"feed_size_history", "feed_id = ?"},
+	{"activitypub_follows", "feed_id = ?"},
+	{"feed_bridges", "feed_id = ?"},
+	{"feed_watches", "feed_id = ?"},
+	{"feed_rules", "feed_id = ?"},
+	{"filters", "feed_id = ?"},
+	{"feeds", "id = ?"},
+}
+
+// deleteFeed removes the feed and everything attached to it,
+// returning the number of deleted rows per table.
+func deleteFeed(tx *sql.Tx, feedId int64) (map[string]int64, error) {
+	counts := make(map[string]int64, len(feedDependents))
+	for _, dep := range feedDependents {
+		result, err := tx.Exec(`delete from `+dep.table+` where `+dep.where, feedId)
+		if err != nil {
+			return nil, err
+		}
+		if counts[dep.table], err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// DeleteFeed removes the feed with its items, errors, sizes and icon in a
+// single transaction. Returns the number of deleted rows per table,
+// ErrNotFound if the feed doesn't exist.
+func (s *Storage) DeleteFeed(feedId int64) (map[string]int64, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Print(err)
-		return false
+		return nil, err
+	}
+	counts, err := deleteFeed(tx, feedId)
+	if err == nil && counts["feeds"] == 0 {
+		err = ErrNotFound
 	}
-	nrows, err := result.RowsAffected()
 	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Print(err)
-		}
-		return false
+		tx.Rollback()
+		return nil, wrapError(err)
 	}
-	return nrows == 1
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return counts, nil
 }
 
-func (s *Storage) RenameFeed(feedId int64, newTitle string) bool {
-	_, err := s.db.Exec(`update feeds set title = ? where id = ?`, newTitle, feedId)
-	return err == nil
+func (s *Storage) RenameFeed(feedId int64, newTitle string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{Title: &newTitle})
 }
 
-func (s *Storage) UpdateFeedFolder(feedId int64, newFolderId *int64) bool {
-	_, err := s.db.Exec(`update feeds set folder_id = ? where id = ?`, newFolderId, feedId)
-	return err == nil
+func (s *Storage) UpdateFeedFolder(feedId int64, newFolderId *int64) error {
+	return s.UpdateFeed(feedId, FeedUpdate{SetFolder: true, FolderId: newFolderId})
 }
 
-func (s *Storage) UpdateFeedLink(feedId int64, newLink string) bool {
-	_, err := s.db.Exec(`update feeds set feed_link = ? where id = ?`, newLink, feedId)
-	return err == nil
+func (s *Storage) UpdateFeedLink(feedId int64, newLink string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{FeedLink: &newLink})
 }
 
-func (s *Storage) UpdateFeedIcon(feedId int64, icon *[]byte) bool {
-	_, err := s.db.Exec(`update feeds set icon = ? where id = ?`, icon, feedId)
-	return err == nil
+func (s *Storage) UpdateFeedIframeHosts(feedId int64, hosts []string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{IframeHosts: nonNil(hosts)})
 }
 
-func (s *Storage) ListFeeds() []Feed {
-	result := make([]Feed, 0)
-	rows, err := s.db.Query(`
-		select id, folder_id, title, description, link, feed_link,
-		       ifnull(length(icon), 0) > 0 as has_icon
-		from feeds
-		order by title collate nocase
-	`)
+func (s *Storage) UpdateFeedBlockedHosts(feedId int64, hosts []string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{BlockedHosts: nonNil(hosts)})
+}
+
+func (s *Storage) UpdateFeedAllowedHosts(feedId int64, hosts []string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{AllowedHosts: nonNil(hosts)})
+}
+
+func (s *Storage) UpdateFeedAcceptLanguage(feedId int64, language string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{AcceptLanguage: &language})
+}
+
+// SetFeedLanguage records the language the feed says its content is in.
+func (s *Storage) SetFeedLanguage(feedId int64, language string) error {
+	return s.execOne(`update feeds set language = ? where id = ?`, language, feedId)
+}
+
+func (s *Storage) UpdateFeedCustomOrder(feedId int64, customOrder string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{CustomOrder: &customOrder})
+}
+
+func (s *Storage) UpdateFeedIcon(feedId int64, icon *[]byte) error {
+	return s.execOne(`update feeds set icon = ? where id = ?`, icon, feedId)
+}
+
+// FeedUpdate describes the changes of the feed's settings made at once,
+// the nil fields are left as they are. SetFolder and SetRetention tell
+// the nil FolderId and Retention (see UpdateFeedRetention) apart.
+type FeedUpdate struct {
+	Title              *string
+	SetFolder          bool
+	FolderId           *int64
+	FeedLink           *string
+	DownloadEnclosures *bool
+	CustomOrder        *string
+	Paused             *bool
+	RefreshInterval    *int64
+	AcceptLanguage     *string
+	GUIDStrategy       *string
+	DailyLimit         *int64
+	DeliveryTimes      []string
+	SetRetention       bool
+	Retention          *Retention
+	IframeHosts        []string
+	BlockedHosts       []string
+	AllowedHosts       []string
+}
+
+// UpdateFeed validates the whole update before applying it in a single
+// transaction: either every change is stored or none is.
+func (s *Storage) UpdateFeed(feedId int64, update FeedUpdate) error {
+	cols := make([]string, 0)
+	args := make([]interface{}, 0)
+	set := func(col string, value interface{}) {
+		cols = append(cols, col+" = ?")
+		args = append(args, value)
+	}
+
+	if update.Title != nil {
+		if err := ValidateTitle("title", *update.Title); err != nil {
+			return err
+		}
+		set("title", *update.Title)
+		set("title_modified", true)
+	}
+	if update.SetFolder {
+		set("folder_id", update.FolderId)
+		set("folder_modified", true)
+	}
+	if update.FeedLink != nil {
+		if err := ValidateFeedLink(*update.FeedLink); err != nil {
+			return err
+		}
+		set("feed_link", *update.FeedLink)
+	}
+	if update.DownloadEnclosures != nil {
+		set("download_enclosures", *update.DownloadEnclosures)
+	}
+	if update.CustomOrder != nil {
+		set("custom_order", *update.CustomOrder)
+	}
+	if update.Paused != nil {
+		set("paused", *update.Paused)
+	}
+	if update.RefreshInterval != nil {
+		set("refresh_interval", *update.RefreshInterval)
+	}
+	if update.AcceptLanguage != nil {
+		language := strings.TrimSpace(*update.AcceptLanguage)
+		if err := ValidateAcceptLanguage(language); err != nil {
+			return err
+		}
+		set("accept_language", language)
+	}
+	if update.GUIDStrategy != nil {
+		if !guidStrategies[*update.GUIDStrategy] {
+			return &ValidationError{"guid_strategy", fmt.Sprintf("unknown strategy %q", *update.GUIDStrategy)}
+		}
+		set("guid_strategy", *update.GUIDStrategy)
+	}
+	if update.DailyLimit != nil {
+		if limit := *update.DailyLimit; limit < 0 || limit > MaxDailyLimit {
+			return &ValidationError{"daily_limit", fmt.Sprintf("must be between 0 and %d", MaxDailyLimit)}
+		}
+		set("daily_limit", *update.DailyLimit)
+	}
+	if update.DeliveryTimes != nil {
+		if err := ValidateDeliveryTimes(update.DeliveryTimes); err != nil {
+			return err
+		}
+		set("delivery_times", strings.Join(normalizeDeliveryTimes(update.DeliveryTimes), " "))
+	}
+	if update.SetRetention {
+		value, err := retentionValue(update.Retention)
+		if err != nil {
+			return err
+		}
+		set("retention", value)
+	}
+	if update.IframeHosts != nil {
+		set("iframe_hosts", strings.Join(update.IframeHosts, " "))
+	}
+	if update.BlockedHosts != nil {
+		hosts := cleanHosts(update.BlockedHosts)
+		if err := ValidateHosts("blocked_hosts", hosts); err != nil {
+			return err
+		}
+		set("blocked_hosts", strings.Join(hosts, " "))
+	}
+	if update.AllowedHosts != nil {
+		hosts := cleanHosts(update.AllowedHosts)
+		if err := ValidateHosts("allowed_hosts", hosts); err != nil {
+			return err
+		}
+		set("allowed_hosts", strings.Join(hosts, " "))
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Print(err)
-		return result
+		return err
 	}
-	for rows.Next() {
-		var f Feed
-		err = rows.Scan(
-			&f.Id,
-			&f.FolderId,
-			&f.Title,
-			&f.Description,
-			&f.Link,
-			&f.FeedLink,
-			&f.HasIcon,
-		)
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`update feeds set `+strings.Join(cols, ", ")+` where id = ?`, append(args, feedId)...)
+	if err != nil {
+		return wrapError(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if update.FeedLink != nil {
+		// the validators belong to the previous url
+		_, err := tx.Exec(`update http_states set last_modified = '', etag = '' where feed_id = ?`, feedId)
 		if err != nil {
-			log.Print(err)
-			return result
+			return wrapError(err)
 		}
-		result = append(result, f)
 	}
-	return result
+	if update.GUIDStrategy != nil {
+		if err := rekeyItems(tx, &feedId, *update.GUIDStrategy); err != nil {
+			return err
+		}
+	}
+	return wrapError(tx.Commit())
+}
+
+// nonNil makes the empty list clear the value in a FeedUpdate.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
 }
 
-func (s *Storage) ListFeedsMissingIcons() []Feed {
+func (s *Storage) ListFeeds() ([]Feed, error) {
 	result := make([]Feed, 0)
 	rows, err := s.db.Query(`
-		select id, folder_id, title, description, link, feed_link
+		select id, folder_id, title, description, link, feed_link,
+		       ifnull(length(icon), 0) > 0 as has_icon, custom_order, iframe_hosts,
+		       blocked_hosts, allowed_hosts, download_enclosures, paused, refresh_interval, accept_language, guid_strategy,
+		       language, delivery_times, retention, daily_limit, title_modified, folder_modified, trial_until,
+		       auth_username != '' or auth_header != '' as has_credentials
 		from feeds
-		where icon is null
+		where deleted_at is null
+		order by custom_order, title collate nocase
 	`)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var f Feed
+		var iframeHosts, blockedHosts, allowedHosts, deliveryTimes, retention string
 		err = rows.Scan(
 			&f.Id,
 			&f.FolderId,
@@ -153,75 +408,174 @@ func (s *Storage) ListFeedsMissingIcons() []Feed {
 			&f.Description,
 			&f.Link,
 			&f.FeedLink,
+			&f.HasIcon,
+			&f.CustomOrder,
+			&iframeHosts,
+			&blockedHosts,
+			&allowedHosts,
+			&f.DownloadEnclosures,
+			&f.Paused,
+			&f.RefreshInterval,
+			&f.AcceptLanguage,
+			&f.GUIDStrategy,
+			&f.Language,
+			&deliveryTimes,
+			&retention,
+			&f.DailyLimit,
+			&f.TitleModified,
+			&f.FolderModified,
+			&f.TrialUntil,
+			&f.HasCredentials,
 		)
 		if err != nil {
-			log.Print(err)
-			return result
+			return nil, err
+		}
+		f.IframeHosts = splitFields(iframeHosts)
+		f.BlockedHosts = splitFields(blockedHosts)
+		f.AllowedHosts = splitFields(allowedHosts)
+		f.DeliveryTimes = splitFields(deliveryTimes)
+		if f.Retention, err = parseRetention(retention); err != nil {
+			return nil, err
 		}
 		result = append(result, f)
 	}
-	return result
+	return result, rows.Err()
 }
 
-func (s *Storage) GetFeed(id int64) *Feed {
+// GetFeed returns the feed with its icon, ErrNotFound if it doesn't exist.
+func (s *Storage) GetFeed(id int64) (*Feed, error) {
 	var f Feed
+	var iframeHosts, blockedHosts, allowedHosts, deliveryTimes, retention string
 	err := s.db.QueryRow(`
 		select
 			id, folder_id, title, link, feed_link,
-			icon, ifnull(icon, '') != '' as has_icon
+			icon, ifnull(icon, '') != '' as has_icon, custom_order, iframe_hosts,
+			blocked_hosts, allowed_hosts, download_enclosures, paused, refresh_interval, accept_language, guid_strategy,
+			language, delivery_times, retention, daily_limit, title_modified, folder_modified, trial_until,
+			auth_username != '' or auth_header != '' as has_credentials, deleted_at
 		from feeds where id = ?
 	`, id).Scan(
 		&f.Id, &f.FolderId, &f.Title, &f.Link, &f.FeedLink,
-		&f.Icon, &f.HasIcon,
+		&f.Icon, &f.HasIcon, &f.CustomOrder, &iframeHosts,
+		&blockedHosts, &allowedHosts, &f.DownloadEnclosures, &f.Paused, &f.RefreshInterval, &f.AcceptLanguage, &f.GUIDStrategy,
+		&f.Language, &deliveryTimes, &retention, &f.DailyLimit, &f.TitleModified, &f.FolderModified, &f.TrialUntil,
+		&f.HasCredentials, &f.DeletedAt,
 	)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Print(err)
-		}
-		return nil
+		return nil, wrapError(err)
+	}
+	f.IframeHosts = splitFields(iframeHosts)
+	f.BlockedHosts = splitFields(blockedHosts)
+	f.AllowedHosts = splitFields(allowedHosts)
+	f.DeliveryTimes = splitFields(deliveryTimes)
+	if f.Retention, err = parseRetention(retention); err != nil {
+		return nil, err
 	}
-	return &f
+	return &f, nil
 }
 
-func (s *Storage) ResetFeedErrors() {
-	if _, err := s.db.Exec(`delete from feed_errors`); err != nil {
-		log.Print(err)
-	}
+// the number of errors kept per feed, see GetFeedErrorHistory
+const feedErrorHistorySize = 50
+
+type FeedError struct {
+	Error string    `json:"error"`
+	Date  time.Time `json:"date"`
 }
 
-func (s *Storage) SetFeedError(feedID int64, lastError error) {
+// ClearFeedError marks the feed as refreshed successfully, which ends
+// its failing streak. The past errors are kept in the history.
+func (s *Storage) ClearFeedError(feedID int64) error {
+	_, err := s.db.Exec(`delete from feed_errors where feed_id = ?`, feedID)
+	return err
+}
+
+// SetFeedError replaces the latest error of the feed and adds it to
+// the history. The time of the first of consecutive errors is kept,
+// see GetFeedErrorSince.
+func (s *Storage) SetFeedError(feedID int64, lastError error) error {
+	now := time.Now().UTC()
 	_, err := s.db.Exec(`
-		insert into feed_errors (feed_id, error)
-		values (?, ?)
+		insert into feed_errors (feed_id, error, since)
+		values (?, ?, ?)
 		on conflict (feed_id) do update set error = excluded.error`,
-		feedID, lastError.Error(),
+		feedID, lastError.Error(), now,
 	)
 	if err != nil {
-		log.Print(err)
+		return wrapError(err)
+	}
+	_, err = s.db.Exec(`
+		insert into feed_error_history (feed_id, error, date) values (?, ?, ?)`,
+		feedID, lastError.Error(), now,
+	)
+	if err != nil {
+		return wrapError(err)
+	}
+	_, err = s.db.Exec(`
+		delete from feed_error_history
+		where feed_id = ? and id not in (
+			select id from feed_error_history where feed_id = ? order by id desc limit ?
+		)`,
+		feedID, feedID, feedErrorHistorySize,
+	)
+	return err
+}
+
+// GetFeedErrorHistory returns the recent errors of the feed, latest first,
+// including the ones from before its last successful refresh.
+func (s *Storage) GetFeedErrorHistory(feedID int64) ([]FeedError, error) {
+	result := make([]FeedError, 0)
+	rows, err := s.db.Query(`
+		select error, date from feed_error_history
+		where feed_id = ?
+		order by id desc`,
+		feedID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e FeedError
+		if err := rows.Scan(&e.Error, &e.Date); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// GetFeedErrorSince returns the time the feed has been failing since,
+// nil if its last refresh succeeded (or the time is unknown).
+func (s *Storage) GetFeedErrorSince(feedID int64) (*time.Time, error) {
+	var since *time.Time
+	err := s.db.QueryRow(`select since from feed_errors where feed_id = ?`, feedID).Scan(&since)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
+	return since, err
 }
 
-func (s *Storage) GetFeedErrors() map[int64]string {
+func (s *Storage) GetFeedErrors() (map[int64]string, error) {
 	errors := make(map[int64]string)
 
 	rows, err := s.db.Query(`select feed_id, error from feed_errors`)
 	if err != nil {
-		log.Print(err)
-		return errors
+		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var id int64
 		var error string
 		if err = rows.Scan(&id, &error); err != nil {
-			log.Print(err)
+			return nil, err
 		}
 		errors[id] = error
 	}
-	return errors
+	return errors, rows.Err()
 }
 
-func (s *Storage) SetFeedSize(feedId int64, size int) {
+func (s *Storage) SetFeedSize(feedId int64, size int) error {
 	_, err := s.db.Exec(`
 		insert into feed_sizes (feed_id, size)
 		values (?, ?)
@@ -229,6 +583,7 @@ func (s *Storage) SetFeedSize(feedId int64, size int) {
 		feedId, size,
 	)
 	if err != nil {
-		log.Print(err)
+		return wrapError(err)
 	}
+	return s.recordFeedSize(feedId, size)
 }
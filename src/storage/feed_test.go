@@ -1,17 +1,18 @@
 package storage
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
 
 func TestCreateFeed(t *testing.T) {
 	db := testDB()
-	feed1 := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", nil)
+	feed1, _ := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", "", nil)
 	if feed1 == nil || feed1.Id == 0 {
 		t.Fatal("expected feed")
 	}
-	feed2 := db.GetFeed(feed1.Id)
+	feed2, _ := db.GetFeed(feed1.Id)
 	if feed2 == nil || !reflect.DeepEqual(feed1, feed2) {
 		t.Fatal("invalid feed")
 	}
@@ -19,30 +20,54 @@ func TestCreateFeed(t *testing.T) {
 
 func TestCreateFeedSameLink(t *testing.T) {
 	db := testDB()
-	feed1 := db.CreateFeed("title", "", "", "http://example1.com/feed.xml", nil)
+	feed1, _ := db.CreateFeed("title", "", "", "http://example1.com/feed.xml", "", nil)
 	if feed1 == nil || feed1.Id == 0 {
 		t.Fatal("expected feed")
 	}
 
 	for i := 0; i < 10; i++ {
-		db.CreateFeed("title", "", "", "http://example2.com/feed.xml", nil)
+		db.CreateFeed("title", "", "", "http://example2.com/feed.xml", "", nil)
 	}
 
-	feed2 := db.CreateFeed("title", "", "http://example.com", "http://example1.com/feed.xml", nil)
+	feed2, _ := db.CreateFeed("title", "", "http://example.com", "http://example1.com/feed.xml", "", nil)
 	if feed1.Id != feed2.Id {
 		t.Fatalf("expected the same feed.\nwant: %#v\nhave: %#v", feed1, feed2)
 	}
 }
 
+func TestCreateFeedKeepsUserChanges(t *testing.T) {
+	db := testDB()
+	folder1, _ := db.CreateFolder("folder1")
+	folder2, _ := db.CreateFolder("folder2")
+	feed, _ := db.CreateFeed("title", "", "", "http://example.com/feed.xml", "", &folder1.Id)
+
+	// not modified by the user: re-import moves the feed
+	feed, _ = db.CreateFeed("imported", "", "", "http://example.com/feed.xml", "", &folder2.Id)
+	if *feed.FolderId != folder2.Id || feed.Title != "title" {
+		t.Fatalf("unexpected feed: %#v", feed)
+	}
+
+	db.RenameFeed(feed.Id, "renamed")
+	db.UpdateFeedFolder(feed.Id, &folder1.Id)
+
+	feed, _ = db.CreateFeed("imported", "", "", "http://example.com/feed.xml", "", nil)
+	if feed.Title != "renamed" || feed.FolderId == nil || *feed.FolderId != folder1.Id {
+		t.Fatalf("user changes were overwritten: %#v", feed)
+	}
+	if !feed.TitleModified || !feed.FolderModified {
+		t.Fatalf("expected modified flags: %#v", feed)
+	}
+}
+
 func TestReadFeed(t *testing.T) {
 	db := testDB()
-	if db.GetFeed(100500) != nil {
-		t.Fatal("cannot get nonexistent feed")
+	if _, err := db.GetFeed(100500); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound for a nonexistent feed, have %v", err)
 	}
 
-	feed1 := db.CreateFeed("feed 1", "", "http://example1.com", "http://example1.com/feed.xml", nil)
-	feed2 := db.CreateFeed("feed 2", "", "http://example2.com", "http://example2.com/feed.xml", nil)
-	feeds := db.ListFeeds()
+	feed1, _ := db.CreateFeed("feed 1", "", "http://example1.com", "http://example1.com/feed.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed 2", "", "http://example2.com", "http://example2.com/feed.xml", "", nil)
+	feeds, _ := db.ListFeeds()
 	if !reflect.DeepEqual(feeds, []Feed{*feed1, *feed2}) {
 		t.Fatalf("invalid feed list: %#v", feeds)
 	}
@@ -50,15 +75,25 @@ func TestReadFeed(t *testing.T) {
 
 func TestUpdateFeed(t *testing.T) {
 	db := testDB()
-	feed1 := db.CreateFeed("feed 1", "", "http://example1.com", "http://example1.com/feed.xml", nil)
-	folder := db.CreateFolder("test")
+	feed1, _ := db.CreateFeed("feed 1", "", "http://example1.com", "http://example1.com/feed.xml", "", nil)
+	folder, _ := db.CreateFolder("test")
 	icon := []byte("icon")
 
 	db.RenameFeed(feed1.Id, "newtitle")
 	db.UpdateFeedFolder(feed1.Id, &folder.Id)
 	db.UpdateFeedIcon(feed1.Id, &icon)
+	db.UpdateFeedIframeHosts(feed1.Id, []string{"example.com", "embed.example.org"})
+	if err := db.UpdateFeedIcon(100500, &icon); err != ErrNotFound {
+		t.Errorf("want ErrNotFound for a nonexistent feed, have %v", err)
+	}
+	if err := db.UpdateFeedAcceptLanguage(feed1.Id, " de-CH, de;q=0.9 "); err != nil {
+		t.Fatal(err)
+	}
+	if db.UpdateFeedAcceptLanguage(feed1.Id, "de\r\nX-Injected: 1") == nil {
+		t.Error("accepted invalid accept-language")
+	}
 
-	feed2 := db.GetFeed(feed1.Id)
+	feed2, _ := db.GetFeed(feed1.Id)
 	if feed2.Title != "newtitle" {
 		t.Error("invalid title")
 	}
@@ -68,20 +103,96 @@ func TestUpdateFeed(t *testing.T) {
 	if !feed2.HasIcon || string(*feed2.Icon) != "icon" {
 		t.Error("invalid icon")
 	}
+	if !reflect.DeepEqual(feed2.IframeHosts, []string{"example.com", "embed.example.org"}) {
+		t.Errorf("invalid iframe hosts: %#v", feed2.IframeHosts)
+	}
+	if feed2.AcceptLanguage != "de-CH, de;q=0.9" {
+		t.Errorf("invalid accept-language: %q", feed2.AcceptLanguage)
+	}
 }
 
 func TestDeleteFeed(t *testing.T) {
 	db := testDB()
-	feed1 := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", nil)
+	feed1, _ := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", "", nil)
 
-	if db.DeleteFeed(100500) {
-		t.Error("cannot delete what does not exist")
+	if _, err := db.DeleteFeed(100500); err != ErrNotFound {
+		t.Errorf("want ErrNotFound for a nonexistent feed, have %v", err)
 	}
 
-	if !db.DeleteFeed(feed1.Id) {
-		t.Fatal("did not delete existing feed")
+	if _, err := db.DeleteFeed(feed1.Id); err != nil {
+		t.Fatal(err)
 	}
-	if db.GetFeed(feed1.Id) != nil {
+	if _, err := db.GetFeed(feed1.Id); err != ErrNotFound {
 		t.Fatal("feed still exists")
 	}
 }
+
+func TestDeleteFeedCascade(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+	other, _ := db.CreateFeed("other", "", "http://example.com", "http://example.com/other.xml", "", nil)
+	db.CreateItems([]Item{
+		{GUID: "item1", FeedId: feed.Id, Title: "item1"},
+		{GUID: "item2", FeedId: feed.Id, Title: "item2"},
+		{GUID: "item3", FeedId: other.Id, Title: "item3"},
+	})
+	db.SetFeedSize(feed.Id, 2)
+	db.SetFeedError(feed.Id, errors.New("failed"))
+	db.UpdatePlayback(getItem(db, "item1").Id, Playback{Position: 10})
+
+	counts, _ := db.DeleteFeed(feed.Id)
+	want := map[string]int64{"feeds": 1, "items": 2, "feed_errors": 1, "feed_error_history": 1, "feed_sizes": 1, "feed_size_history": 1, "playback": 1}
+	for table, n := range want {
+		if counts[table] != n {
+			t.Errorf("expected %d deleted rows from %s, got %d", n, table, counts[table])
+		}
+	}
+
+	var orphans int
+	db.db.QueryRow(`
+		select (select count(*) from items where feed_id = ?) +
+		       (select count(*) from feed_sizes where feed_id = ?) +
+		       (select count(*) from playback)
+	`, feed.Id, feed.Id).Scan(&orphans)
+	if orphans != 0 {
+		t.Fatalf("found %d orphaned rows", orphans)
+	}
+	if getItem(db, "item3") == nil {
+		t.Fatal("deleted items of another feed")
+	}
+}
+
+func TestFeedErrorHistory(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("title", "", "http://example.com", "http://example.com/feed.xml", "", nil)
+
+	db.SetFeedError(feed.Id, errors.New("timeout"))
+	first, _ := db.GetFeedErrorSince(feed.Id)
+	if first == nil {
+		t.Fatal("failing since not set")
+	}
+	db.SetFeedError(feed.Id, errors.New("status code 502"))
+	if since, _ := db.GetFeedErrorSince(feed.Id); since == nil || !since.Equal(*first) {
+		t.Errorf("want failing since %v, have %v", first, since)
+	}
+	if errs, _ := db.GetFeedErrors(); errs[feed.Id] != "status code 502" {
+		t.Errorf("invalid latest error: %#v", errs)
+	}
+
+	// a successful refresh ends the failing streak, not the history
+	db.ClearFeedError(feed.Id)
+	if since, _ := db.GetFeedErrorSince(feed.Id); since != nil {
+		t.Errorf("still failing since %v", since)
+	}
+	history, _ := db.GetFeedErrorHistory(feed.Id)
+	if len(history) != 2 || history[0].Error != "status code 502" || history[1].Error != "timeout" {
+		t.Fatalf("invalid history: %#v", history)
+	}
+
+	for i := 0; i < feedErrorHistorySize; i++ {
+		db.SetFeedError(feed.Id, errors.New("failed"))
+	}
+	if history, _ := db.GetFeedErrorHistory(feed.Id); len(history) != feedErrorHistorySize {
+		t.Errorf("want %d errors kept, have %d", feedErrorHistorySize, len(history))
+	}
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+type FeedTrend string
+
+const (
+	TrendGrowing   FeedTrend = "growing"
+	TrendStable    FeedTrend = "stable"
+	TrendShrinking FeedTrend = "shrinking"
+	TrendDead      FeedTrend = "dead"
+)
+
+// A feed without new items for this long is considered dead.
+var FeedDeadAfter = time.Hour * 24 * 90
+
+// How long the daily size history is kept.
+var FeedSizeHistoryRetention = time.Hour * 24 * 180
+
+// The period the weekly number of new items is averaged over.
+var FeedActivityWindow = time.Hour * 24 * 7 * 12
+
+type FeedSizeRecord struct {
+	Day   string `json:"day"`
+	Size  int    `json:"size"`
+	Items int    `json:"items"`
+}
+
+type FeedStats struct {
+	// number of entries in the latest fetched feed document
+	Size int `json:"size"`
+	// number of items stored for the feed
+	Items       int        `json:"items"`
+	Unread      int        `json:"unread"`
+	LastArrived *time.Time `json:"last_arrived,omitempty"`
+	// the time the feed was last fetched, successfully or not
+	LastRefreshed *time.Time `json:"last_refreshed,omitempty"`
+	// new items per week, averaged over FeedActivityWindow
+	// or the time since the first item arrived if shorter
+	ItemsPerWeek float64          `json:"items_per_week"`
+	History      []FeedSizeRecord `json:"history"`
+	Trend        FeedTrend        `json:"trend"`
+	// bytes downloaded per day
+	Bandwidth []FeedBandwidthRecord `json:"bandwidth"`
+}
+
+func (s *Storage) recordFeedSize(feedId int64, size int) error {
+	_, err := s.db.Exec(`
+		insert into feed_size_history (feed_id, day, size, items)
+		values (?, date('now'), ?, (select count(*) from items where feed_id = ?))
+		on conflict (feed_id, day) do update set size = excluded.size, items = excluded.items`,
+		feedId, size, feedId,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`delete from feed_size_history where feed_id = ? and day < ?`,
+		feedId, time.Now().Add(-FeedSizeHistoryRetention).UTC().Format("2006-01-02"),
+	)
+	return err
+}
+
+func (s *Storage) GetFeedSize(feedId int64) (int, error) {
+	var size int
+	err := s.db.QueryRow(`select size from feed_sizes where feed_id = ?`, feedId).Scan(&size)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s *Storage) ListFeedSizeHistory(feedId int64) ([]FeedSizeRecord, error) {
+	result := make([]FeedSizeRecord, 0)
+	rows, err := s.db.Query(`
+		select day, size, items from feed_size_history
+		where feed_id = ?
+		order by day
+	`, feedId)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r FeedSizeRecord
+		if err = rows.Scan(&r.Day, &r.Size, &r.Items); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetFeedStats(feedId int64) (*FeedStats, error) {
+	var err error
+	stats := &FeedStats{}
+	if stats.Size, err = s.GetFeedSize(feedId); err != nil {
+		return nil, err
+	}
+	if stats.History, err = s.ListFeedSizeHistory(feedId); err != nil {
+		return nil, err
+	}
+	if stats.Bandwidth, err = s.ListFeedBandwidth(feedId); err != nil {
+		return nil, err
+	}
+	err = s.db.QueryRow(`
+		select count(*), ifnull(sum(case status when ? then 1 else 0 end), 0)
+		from items where feed_id = ?
+	`, UNREAD, feedId).Scan(&stats.Items, &stats.Unread)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	state, err := s.GetHTTPState(feedId)
+	if err == nil && !state.LastRefreshed.IsZero() {
+		stats.LastRefreshed = &state.LastRefreshed
+	} else if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	// not using max(): aggregates lose the column type needed to scan into time.Time
+	var lastArrived time.Time
+	err = s.db.QueryRow(`
+		select date_arrived from items
+		where feed_id = ? and date_arrived is not null
+		order by date_arrived desc limit 1
+	`, feedId).Scan(&lastArrived)
+	if err == nil {
+		stats.LastArrived = &lastArrived
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	stats.Trend = feedTrend(stats.LastArrived, stats.History)
+	if stats.ItemsPerWeek, err = s.feedItemsPerWeek(feedId, time.Now()); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *Storage) feedItemsPerWeek(feedId int64, now time.Time) (float64, error) {
+	var firstArrived time.Time
+	err := s.db.QueryRow(`
+		select date_arrived from items
+		where feed_id = ? and date_arrived is not null
+		order by date_arrived limit 1
+	`, feedId).Scan(&firstArrived)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	since := now.Add(-FeedActivityWindow)
+	if firstArrived.After(since) {
+		since = firstArrived
+	}
+	var count int
+	err = s.db.QueryRow(`
+		select count(*) from items where feed_id = ? and date_arrived >= ?
+	`, feedId, since.UTC()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	weeks := now.Sub(since).Hours() / (24 * 7)
+	if weeks < 1 {
+		weeks = 1
+	}
+	return math.Round(float64(count)/weeks*10) / 10, nil
+}
+
+// feedTrend compares the oldest and the newest recorded item counts,
+// unless the feed hasn't produced anything for FeedDeadAfter.
+func feedTrend(lastArrived *time.Time, history []FeedSizeRecord) FeedTrend {
+	if lastArrived == nil || time.Since(*lastArrived) > FeedDeadAfter {
+		return TrendDead
+	}
+	if len(history) < 2 {
+		return TrendStable
+	}
+	first, last := history[0].Items, history[len(history)-1].Items
+	switch {
+	case last > first:
+		return TrendGrowing
+	case last < first:
+		return TrendShrinking
+	}
+	return TrendStable
+}
+
+// ListDormantFeeds returns the feeds which have items,
+// but haven't received a new one since the given time.
+func (s *Storage) ListDormantFeeds(since time.Time) (map[int64]bool, error) {
+	result := make(map[int64]bool)
+	rows, err := s.db.Query(`
+		select feed_id from items
+		group by feed_id
+		having max(ifnull(date_arrived, date)) < ?
+	`, since.UTC())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var feedId int64
+		if err = rows.Scan(&feedId); err != nil {
+			return nil, err
+		}
+		result[feedId] = true
+	}
+	return result, wrapError(rows.Err())
+}
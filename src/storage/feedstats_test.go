@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetFeedStats(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+
+	if stats, _ := db.GetFeedStats(feed.Id); stats.Trend != TrendDead || stats.Items != 0 {
+		t.Fatalf("expected empty feed to be dead: %#v", stats)
+	}
+
+	db.CreateItems([]Item{
+		{GUID: "item1", FeedId: feed.Id, Title: "item1", Date: time.Now()},
+		{GUID: "item2", FeedId: feed.Id, Title: "item2", Date: time.Now()},
+	})
+	db.SetFeedSize(feed.Id, 2)
+
+	db.UpdateItemStatus(getItem(db, "item1").Id, READ)
+	db.SetHTTPStateRefreshed(feed.Id)
+
+	stats, _ := db.GetFeedStats(feed.Id)
+	if stats.Size != 2 || stats.Items != 2 || stats.Unread != 1 || stats.LastArrived == nil || stats.LastRefreshed == nil {
+		t.Fatalf("invalid stats: %#v", stats)
+	}
+	// both items arrived within the first week
+	if stats.ItemsPerWeek != 2 {
+		t.Fatalf("want 2 items per week, have %v", stats.ItemsPerWeek)
+	}
+	if len(stats.History) != 1 || stats.History[0].Size != 2 || stats.History[0].Items != 2 {
+		t.Fatalf("invalid history: %#v", stats.History)
+	}
+	if stats.Trend != TrendStable {
+		t.Fatalf("expected stable trend, got %s", stats.Trend)
+	}
+}
+
+func TestFeedTrend(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-FeedDeadAfter - time.Hour)
+	history := []FeedSizeRecord{{Day: "2020-01-01", Items: 10}, {Day: "2020-01-02", Items: 20}}
+	if trend := feedTrend(&now, history); trend != TrendGrowing {
+		t.Errorf("expected growing, got %s", trend)
+	}
+	history[1].Items = 5
+	if trend := feedTrend(&now, history); trend != TrendShrinking {
+		t.Errorf("expected shrinking, got %s", trend)
+	}
+	if trend := feedTrend(&old, history); trend != TrendDead {
+		t.Errorf("expected dead, got %s", trend)
+	}
+}
+
+func TestListDormantFeeds(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+	db.CreateFeed("feed3", "", "", "http://example.com/feed3.xml", "", nil)
+
+	db.CreateItems([]Item{
+		{GUID: "item1", FeedId: feed1.Id, Title: "item1", Date: time.Now()},
+		{GUID: "item2", FeedId: feed2.Id, Title: "item2", Date: time.Now()},
+	})
+	old := time.Now().Add(-time.Hour * 24 * 365)
+	db.db.Exec(`update items set date_arrived = ? where feed_id = ?`, old, feed1.Id)
+
+	dormant, _ := db.ListDormantFeeds(time.Now().Add(-time.Hour * 24 * 90))
+	if len(dormant) != 1 || !dormant[feed1.Id] {
+		t.Fatalf("unexpected dormant feeds: %#v", dormant)
+	}
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+)
+
+// Filter acts on the new items matching it as they're stored: it marks
+// them read, stars or tags them. The items already stored are left alone.
+type Filter struct {
+	Id int64 `json:"id"`
+	// the feed whose items are filtered, nil for all feeds
+	FeedId *int64 `json:"feed_id"`
+	// "title", "content" (its text) or "any" of them
+	Field string `json:"field"`
+	// a case-insensitive text to look for, or a regular
+	// expression (see package regexp) if Regex is set
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+	// "read", "star" or "tag" (with Tag)
+	Action string `json:"action"`
+	Tag    string `json:"tag,omitempty"`
+}
+
+func validateFilter(f *Filter) error {
+	switch f.Field {
+	case "title", "content", "any":
+	default:
+		return &ValidationError{"field", `must be "title", "content" or "any"`}
+	}
+	if f.Pattern == "" {
+		return &ValidationError{"pattern", "must not be empty"}
+	}
+	if f.Regex {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return &ValidationError{"pattern", err.Error()}
+		}
+	}
+	switch f.Action {
+	case "read", "star":
+		f.Tag = ""
+	case "tag":
+		tag, err := normalizeTag(f.Tag)
+		if err != nil {
+			return err
+		}
+		f.Tag = tag
+	default:
+		return &ValidationError{"action", `must be "read", "star" or "tag"`}
+	}
+	return nil
+}
+
+func (s *Storage) ListFilters() ([]Filter, error) {
+	result := make([]Filter, 0)
+	rows, err := s.db.Query(`
+		select id, feed_id, field, pattern, regex, action, tag
+		from filters
+		order by id`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f Filter
+		if err := rows.Scan(&f.Id, &f.FeedId, &f.Field, &f.Pattern, &f.Regex, &f.Action, &f.Tag); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// CreateFilter adds the filter, ErrConstraint is returned if its feed doesn't exist.
+func (s *Storage) CreateFilter(f Filter) (*Filter, error) {
+	if err := validateFilter(&f); err != nil {
+		return nil, err
+	}
+	err := s.db.QueryRow(`
+		insert into filters (feed_id, field, pattern, regex, action, tag)
+		values (?, ?, ?, ?, ?, ?)
+		returning id`,
+		f.FeedId, f.Field, f.Pattern, f.Regex, f.Action, f.Tag,
+	).Scan(&f.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &f, nil
+}
+
+func (s *Storage) UpdateFilter(f Filter) error {
+	if err := validateFilter(&f); err != nil {
+		return err
+	}
+	return s.execOne(`
+		update filters
+		set feed_id = ?, field = ?, pattern = ?, regex = ?, action = ?, tag = ?
+		where id = ?`,
+		f.FeedId, f.Field, f.Pattern, f.Regex, f.Action, f.Tag, f.Id,
+	)
+}
+
+func (s *Storage) DeleteFilter(id int64) error {
+	return s.execOne(`delete from filters where id = ?`, id)
+}
+
+// itemFilter is a filter ready to be matched against the items.
+type itemFilter struct {
+	Filter
+	re *regexp.Regexp
+}
+
+func loadItemFilters(tx *sql.Tx) ([]itemFilter, error) {
+	rows, err := tx.Query(`select id, feed_id, field, pattern, regex, action, tag from filters order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []itemFilter
+	for rows.Next() {
+		var f itemFilter
+		if err := rows.Scan(&f.Id, &f.FeedId, &f.Field, &f.Pattern, &f.Regex, &f.Action, &f.Tag); err != nil {
+			return nil, err
+		}
+		if f.Regex {
+			if f.re, err = regexp.Compile(f.Pattern); err != nil {
+				return nil, err
+			}
+		} else {
+			f.Pattern = strings.ToLower(f.Pattern)
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+func (f itemFilter) match(text string) bool {
+	if f.re != nil {
+		return f.re.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), f.Pattern)
+}
+
+// filterActions are what the filters matching an item do to it.
+type filterActions struct {
+	read, star bool
+	tags       []string
+}
+
+func applyItemFilters(filters []itemFilter, item Item) filterActions {
+	var actions filterActions
+	var text *string
+	for _, f := range filters {
+		if f.FeedId != nil && *f.FeedId != item.FeedId {
+			continue
+		}
+		matched := false
+		if f.Field != "content" {
+			matched = f.match(item.Title)
+		}
+		if !matched && f.Field != "title" {
+			if text == nil {
+				extracted := htmlutil.ExtractText(item.Content)
+				text = &extracted
+			}
+			matched = f.match(*text)
+		}
+		if !matched {
+			continue
+		}
+		switch f.Action {
+		case "read":
+			actions.read = true
+		case "star":
+			actions.star = true
+		case "tag":
+			actions.tags = append(actions.tags, f.Tag)
+		}
+	}
+	return actions
+}
+
+// applyFilterActions acts on the new item, starring wins over marking read.
+func applyFilterActions(tx *sql.Tx, itemId int64, actions filterActions) error {
+	var status *ItemStatus
+	switch {
+	case actions.star:
+		starred := STARRED
+		status = &starred
+	case actions.read:
+		read := READ
+		status = &read
+	}
+	if status != nil {
+		_, err := tx.Exec(`update items set status = ?, snoozed_until = null where id = ?`, *status, itemId)
+		if err != nil {
+			return err
+		}
+	}
+	for _, tag := range actions.tags {
+		_, err := tx.Exec(`insert or ignore into item_tags (item_id, tag) values (?, ?)`, itemId, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+
+	for _, f := range []Filter{
+		{Field: "author", Pattern: "x", Action: "read"},
+		{Field: "title", Pattern: "", Action: "read"},
+		{Field: "title", Pattern: "(", Regex: true, Action: "read"},
+		{Field: "title", Pattern: "x", Action: "delete"},
+		{Field: "title", Pattern: "x", Action: "tag"},
+	} {
+		if _, err := db.CreateFilter(f); err == nil {
+			t.Errorf("%#v: want a validation error", f)
+		}
+	}
+
+	sponsored, err := db.CreateFilter(Filter{FeedId: &feed1.Id, Field: "title", Pattern: "(?i)^sponsored", Regex: true, Action: "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateFilter(Filter{Field: "content", Pattern: "Go 1.", Action: "star"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateFilter(Filter{Field: "any", Pattern: "RELEASE", Action: "tag", Tag: " Releases "}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "ad1", FeedId: feed1.Id, Title: "Sponsored: a widget", Date: now},
+		{GUID: "ad2", FeedId: feed2.Id, Title: "Sponsored: a widget", Date: now},
+		{GUID: "go", FeedId: feed2.Id, Title: "News", Content: "<p>The <b>Go 1.</b>22 release</p>", Date: now},
+		{GUID: "plain", FeedId: feed1.Id, Title: "Plain", Content: "<a href='http://go1.example.com'>link</a>", Date: now},
+	})
+	statuses := map[string]ItemStatus{}
+	ids := map[string]int64{}
+	items, _ := db.ListItems(ItemFilter{}, 10, false, false)
+	for _, item := range items {
+		statuses[item.GUID] = item.Status
+		ids[item.GUID] = item.Id
+	}
+	want := map[string]ItemStatus{"ad1": READ, "ad2": UNREAD, "go": STARRED, "plain": UNREAD}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("want %v, have %v", want, statuses)
+	}
+	tags, _ := db.ItemTags([]int64{ids["go"], ids["plain"]})
+	if !reflect.DeepEqual(tags[ids["go"]], []string{"releases"}) || len(tags[ids["plain"]]) != 0 {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	// the stored items are left alone
+	sponsored.Action = "star"
+	if err := db.UpdateFilter(*sponsored); err != nil {
+		t.Fatal(err)
+	}
+	updated := now.Add(time.Hour)
+	db.CreateItems([]Item{{GUID: "ad1", FeedId: feed1.Id, Title: "Sponsored: a widget", Date: now, DateUpdated: &updated}})
+	if item := getItem(db, "ad1"); item.Status != READ {
+		t.Errorf("want the stored item left read, have %v", item.Status)
+	}
+
+	if filters, _ := db.ListFilters(); len(filters) != 3 {
+		t.Errorf("unexpected filters: %#v", filters)
+	}
+	if _, err := db.DeleteFeed(feed1.Id); err != nil {
+		t.Fatal(err)
+	}
+	if filters, _ := db.ListFilters(); len(filters) != 2 {
+		t.Errorf("want the filter of the feed deleted with it, have %#v", filters)
+	}
+	if err := db.DeleteFilter(sponsored.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+}
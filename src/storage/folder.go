@@ -1,16 +1,39 @@
 package storage
 
 import (
-	"log"
+	"database/sql"
+	"sort"
 )
 
 type Folder struct {
-	Id         int64  `json:"id"`
-	Title      string `json:"title"`
-	IsExpanded bool   `json:"is_expanded"`
+	Id          int64  `json:"id"`
+	Title       string `json:"title"`
+	IsExpanded  bool   `json:"is_expanded"`
+	CustomOrder string `json:"custom_order"`
+	// the folder it's nested in, nil at the top level
+	ParentId *int64 `json:"parent_id"`
+
+	// quiet periods, see MutePeriod
+	MuteSchedule []MutePeriod `json:"mute_schedule,omitempty"`
 }
 
-func (s *Storage) CreateFolder(title string) *Folder {
+// folderAncestors is a common table expression pairing every folder
+// with itself and each of the folders it's nested in, so that the
+// folder filters and counters include the subfolders.
+const folderAncestors = `
+	folder_ancestors(folder_id, ancestor_id) as (
+		select id, id from folders
+		union
+		select a.folder_id, f.parent_id
+		from folder_ancestors a
+		join folders f on f.id = a.ancestor_id
+		where f.parent_id is not null
+	)`
+
+func (s *Storage) CreateFolder(title string) (*Folder, error) {
+	if err := ValidateTitle("title", title); err != nil {
+		return nil, err
+	}
 	expanded := true
 	row := s.db.QueryRow(`
 		insert into folders (title, is_expanded) values (?, ?)
@@ -21,52 +44,158 @@ func (s *Storage) CreateFolder(title string) *Folder {
 		title,
 	)
 	var id int64
-	err := row.Scan(&id)
+	if err := row.Scan(&id); err != nil {
+		return nil, wrapError(err)
+	}
+	return &Folder{Id: id, Title: title, IsExpanded: expanded, CustomOrder: DefaultCustomOrder}, nil
+}
 
+// DeleteFolder deletes the folder in a single transaction, its subfolders
+// are moved to its parent and its feeds out of any folder.
+func (s *Storage) DeleteFolder(folderId int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		update folders
+		set parent_id = (select parent_id from folders where id = ?)
+		where parent_id = ?
+	`, folderId, folderId)
+	if err == nil {
+		var result sql.Result
+		if result, err = tx.Exec(`delete from folders where id = ?`, folderId); err == nil {
+			var n int64
+			if n, err = result.RowsAffected(); err == nil && n == 0 {
+				err = ErrNotFound
+			}
+		}
+	}
 	if err != nil {
-		log.Print(err)
-		return nil
+		tx.Rollback()
+		return wrapError(err)
 	}
-	return &Folder{Id: id, Title: title, IsExpanded: expanded}
+	return tx.Commit()
 }
 
-func (s *Storage) DeleteFolder(folderId int64) bool {
-	_, err := s.db.Exec(`delete from folders where id = ?`, folderId)
-	if err != nil {
-		log.Print(err)
+// MoveFolder nests the folder in the parent folder,
+// or moves it to the top level if parentId is nil.
+func (s *Storage) MoveFolder(folderId int64, parentId *int64) error {
+	if parentId != nil {
+		var cycle bool
+		err := s.db.QueryRow(`
+			with recursive `+folderAncestors+`
+			select exists (
+				select 1 from folder_ancestors where folder_id = ? and ancestor_id = ?
+			)
+		`, *parentId, folderId).Scan(&cycle)
+		if err != nil {
+			return wrapError(err)
+		}
+		if cycle {
+			return &ValidationError{"parent_id", "can't move a folder into itself or its subfolders"}
+		}
 	}
-	return err == nil
+	return s.execOne(`update folders set parent_id = ? where id = ?`, parentId, folderId)
 }
 
-func (s *Storage) RenameFolder(folderId int64, newTitle string) bool {
-	_, err := s.db.Exec(`update folders set title = ? where id = ?`, newTitle, folderId)
-	return err == nil
+func (s *Storage) RenameFolder(folderId int64, newTitle string) error {
+	if err := ValidateTitle("title", newTitle); err != nil {
+		return err
+	}
+	return s.execOne(`update folders set title = ? where id = ?`, newTitle, folderId)
 }
 
-func (s *Storage) ToggleFolderExpanded(folderId int64, isExpanded bool) bool {
-	_, err := s.db.Exec(`update folders set is_expanded = ? where id = ?`, isExpanded, folderId)
-	return err == nil
+func (s *Storage) ToggleFolderExpanded(folderId int64, isExpanded bool) error {
+	return s.execOne(`update folders set is_expanded = ? where id = ?`, isExpanded, folderId)
 }
 
-func (s *Storage) ListFolders() []Folder {
-	result := make([]Folder, 0, 0)
+func (s *Storage) UpdateFolderCustomOrder(folderId int64, customOrder string) error {
+	return s.execOne(`update folders set custom_order = ? where id = ?`, customOrder, folderId)
+}
+
+func (s *Storage) ListFolders() ([]Folder, error) {
 	rows, err := s.db.Query(`
-		select id, title, is_expanded
+		select id, title, is_expanded, custom_order, parent_id, mute_schedule
 		from folders
-		order by title collate nocase
+		order by custom_order, title collate nocase
 	`)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
+	result := make([]Folder, 0)
 	for rows.Next() {
 		var f Folder
-		err = rows.Scan(&f.Id, &f.Title, &f.IsExpanded)
+		var muteSchedule string
+		err = rows.Scan(&f.Id, &f.Title, &f.IsExpanded, &f.CustomOrder, &f.ParentId, &muteSchedule)
 		if err != nil {
-			log.Print(err)
-			return result
+			return nil, wrapError(err)
+		}
+		if f.MuteSchedule, err = parseMuteSchedule(muteSchedule); err != nil {
+			return nil, err
 		}
 		result = append(result, f)
 	}
-	return result
+	return result, wrapError(rows.Err())
+}
+
+// FolderStat holds the counters of a folder,
+// including the feeds of its subfolders.
+type FolderStat struct {
+	FolderId     int64 `json:"folder_id"`
+	UnreadCount  int64 `json:"unread"`
+	StarredCount int64 `json:"starred"`
+}
+
+// FolderStats rolls the feed counters up the folder tree,
+// the unread items of muted feeds aren't counted.
+func (s *Storage) FolderStats() ([]FolderStat, error) {
+	rows, err := s.db.Query(`
+		with recursive ` + folderAncestors + `
+		select f.id, a.ancestor_id
+		from feeds f
+		join folder_ancestors a on a.folder_id = f.folder_id
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	folders := make(map[int64][]int64)
+	for rows.Next() {
+		var feedId, folderId int64
+		if err := rows.Scan(&feedId, &folderId); err != nil {
+			rows.Close()
+			return nil, wrapError(err)
+		}
+		folders[feedId] = append(folders[feedId], folderId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	feedStats, err := s.FeedStats()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[int64]*FolderStat)
+	for _, feedStat := range feedStats {
+		for _, folderId := range folders[feedStat.FeedId] {
+			stat, ok := stats[folderId]
+			if !ok {
+				stat = &FolderStat{FolderId: folderId}
+				stats[folderId] = stat
+			}
+			if !feedStat.Muted {
+				stat.UnreadCount += feedStat.UnreadCount
+			}
+			stat.StarredCount += feedStat.StarredCount
+		}
+	}
+	result := make([]FolderStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FolderId < result[j].FolderId })
+	return result, nil
 }
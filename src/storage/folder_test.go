@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNestedFolders(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	if err := db.MoveFolder(scope.folder2.Id, &scope.folder1.Id); err != nil {
+		t.Fatal(err)
+	}
+	folders, _ := db.ListFolders()
+	for _, folder := range folders {
+		if folder.Id == scope.folder2.Id && (folder.ParentId == nil || *folder.ParentId != scope.folder1.Id) {
+			t.Fatalf("folder not moved: %#v", folder)
+		}
+	}
+
+	var validationErr *ValidationError
+	if err := db.MoveFolder(scope.folder1.Id, &scope.folder2.Id); !errors.As(err, &validationErr) {
+		t.Errorf("moving into a subfolder: want a validation error, have %v", err)
+	}
+	if err := db.MoveFolder(scope.folder1.Id, &scope.folder1.Id); !errors.As(err, &validationErr) {
+		t.Errorf("moving into itself: want a validation error, have %v", err)
+	}
+	missing := int64(100500)
+	if err := db.MoveFolder(scope.folder1.Id, &missing); !errors.Is(err, ErrConstraint) {
+		t.Errorf("moving into a missing folder: want ErrConstraint, have %v", err)
+	}
+
+	items, _ := db.ListItems(ItemFilter{FolderID: &scope.folder1.Id}, 10, true, false)
+	if len(items) != 7 {
+		t.Errorf("want the 7 items of the folder and the subfolder, have %d", len(items))
+	}
+
+	want := map[int64]FolderStat{
+		scope.folder1.Id: {FolderId: scope.folder1.Id, UnreadCount: 2, StarredCount: 2},
+		scope.folder2.Id: {FolderId: scope.folder2.Id, UnreadCount: 0, StarredCount: 1},
+	}
+	stats, _ := db.FolderStats()
+	if len(stats) != len(want) {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+	for _, stat := range stats {
+		if stat != want[stat.FolderId] {
+			t.Errorf("want %#v, have %#v", want[stat.FolderId], stat)
+		}
+	}
+
+	always := []MutePeriod{{From: "00:00", To: "00:00"}}
+	if err := db.UpdateFolderMuteSchedule(scope.folder1.Id, always); err != nil {
+		t.Fatal(err)
+	}
+	if muted, _ := db.MutedFeeds(time.Now()); !muted[scope.feed21.Id] {
+		t.Error("feed in the subfolder not muted")
+	}
+	stats, _ = db.FolderStats()
+	for _, stat := range stats {
+		if stat.UnreadCount != 0 {
+			t.Errorf("unread items of muted feeds counted: %#v", stat)
+		}
+	}
+
+	if err := db.DeleteFolder(scope.folder1.Id); err != nil {
+		t.Fatal(err)
+	}
+	if folders, _ := db.ListFolders(); len(folders) != 1 || folders[0].ParentId != nil {
+		t.Fatalf("subfolder not moved up: %#v", folders)
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BoundingBox is an area of the map in degrees, see ItemFilter.Within.
+// West is greater than East for the areas crossing the antimeridian.
+type BoundingBox struct {
+	West  float64 `json:"west"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	North float64 `json:"north"`
+}
+
+// ParseBoundingBox parses the "west,south,east,north" form
+// (e.g. "5.9,45.8,10.5,47.8"), the order used by GeoJSON and OpenStreetMap.
+func ParseBoundingBox(val string) (*BoundingBox, error) {
+	parts := strings.Split(val, ",")
+	if len(parts) != 4 {
+		return nil, &ValidationError{"bbox", "must be west,south,east,north"}
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, &ValidationError{"bbox", "must be west,south,east,north"}
+		}
+		coords[i] = coord
+	}
+	box := &BoundingBox{West: coords[0], South: coords[1], East: coords[2], North: coords[3]}
+	if box.West < -180 || box.West > 180 || box.East < -180 || box.East > 180 {
+		return nil, &ValidationError{"bbox", "the longitudes must be within [-180, 180]"}
+	}
+	if box.South < -90 || box.North > 90 || box.South > box.North {
+		return nil, &ValidationError{"bbox", "the latitudes must be within [-90, 90], south first"}
+	}
+	return box, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItemsWithin(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+	located := func(guid string, lat, lon float64) Item {
+		now = now.Add(time.Minute)
+		return Item{GUID: guid, FeedId: feed.Id, Date: now, Latitude: &lat, Longitude: &lon}
+	}
+	db.CreateItems([]Item{
+		located("zurich", 47.37, 8.54),
+		located("sydney", -33.87, 151.21),
+		located("fiji", -17.71, 178.06),
+		located("samoa", -13.76, -172.1),
+		{GUID: "nowhere", FeedId: feed.Id, Date: now},
+	})
+
+	cases := []struct {
+		bbox string
+		want []string
+	}{
+		{"5.9,45.8,10.5,47.8", []string{"zurich"}},
+		{"-180,-90,180,90", []string{"zurich", "sydney", "fiji", "samoa"}},
+		// across the antimeridian
+		{"170,-20,-170,-10", []string{"fiji", "samoa"}},
+	}
+	for _, tc := range cases {
+		box, err := ParseBoundingBox(tc.bbox)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := getItemGuids(db.ListItems(ItemFilter{Within: box}, 10, false, false))
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s: want %v, have %v", tc.bbox, tc.want, have)
+		}
+	}
+
+	item, _ := db.GetItem(getItem(db, "zurich").Id)
+	if item.Latitude == nil || *item.Latitude != 47.37 || *item.Longitude != 8.54 {
+		t.Errorf("unexpected location: %v, %v", item.Latitude, item.Longitude)
+	}
+
+	var validationErr *ValidationError
+	for _, bbox := range []string{"", "1,2,3", "a,b,c,d", "0,10,10,0", "-190,0,0,10"} {
+		if _, err := ParseBoundingBox(bbox); !errors.As(err, &validationErr) {
+			t.Errorf("%q: want a validation error, have %v", bbox, err)
+		}
+	}
+}
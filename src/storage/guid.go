@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Strategies for deriving an item's identity, set per feed for
+// publishers whose guids are missing, unstable or reused.
+const (
+	// GUIDAuto uses the guid, falling back to the link and then to the content hash.
+	GUIDAuto = ""
+	// GUIDLink ignores the guid and uses the link.
+	GUIDLink = "link"
+	// GUIDHash uses the hash of the title and content.
+	GUIDHash = "hash"
+)
+
+var guidStrategies = map[string]bool{GUIDAuto: true, GUIDLink: true, GUIDHash: true}
+
+const hashGUIDPrefix = "sha1:"
+
+func contentHash(title, content string) string {
+	sum := sha1.Sum([]byte(title + "\x00" + content))
+	return hashGUIDPrefix + hex.EncodeToString(sum[:])
+}
+
+// ItemGUID returns the identity of a fetched item under the given strategy.
+// Every strategy falls back to the next one if the value it relies on is empty.
+func ItemGUID(strategy, guid, link, title, content string) string {
+	guid = strings.TrimSpace(guid)
+	link = strings.TrimSpace(link)
+	switch strategy {
+	case GUIDLink:
+		guid = ""
+	case GUIDHash:
+		guid, link = "", ""
+	}
+	if guid != "" {
+		return guid
+	}
+	if link != "" {
+		return link
+	}
+	return contentHash(title, content)
+}
+
+// disambiguateGUID handles publishers reusing a guid for a different
+// article: if the feed already has an item with the same guid but with
+// a different link and title, the new item gets a guid of its own,
+// derived from its link.
+func disambiguateGUID(tx *sql.Tx, item Item) (string, error) {
+	if item.Link == "" || item.Link == item.GUID || strings.HasPrefix(item.GUID, hashGUIDPrefix) {
+		return item.GUID, nil
+	}
+	var link, title string
+	err := tx.QueryRow(
+		`select link, title from items where feed_id = ? and guid = ?`,
+		item.FeedId, item.GUID,
+	).Scan(&link, &title)
+	if err == sql.ErrNoRows {
+		return item.GUID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if link == "" || link == item.Link || title == item.Title {
+		return item.GUID, nil
+	}
+	sum := sha1.Sum([]byte(item.Link))
+	return fmt.Sprintf("%s#%s", item.GUID, hex.EncodeToString(sum[:4])), nil
+}
+
+// UpdateFeedGUIDStrategy changes how the feed's items are identified
+// and re-keys the existing items, so that they aren't fetched again
+// as new ones.
+func (s *Storage) UpdateFeedGUIDStrategy(feedId int64, strategy string) error {
+	return s.UpdateFeed(feedId, FeedUpdate{GUIDStrategy: &strategy})
+}
+
+// rekeyItems recomputes the guids of stored items (of a single feed,
+// if feedId is given). The original guid isn't stored, so items keep
+// it unless the strategy ignores it or it's empty. Items whose new guid
+// is already taken keep the old one.
+func rekeyItems(tx *sql.Tx, feedId *int64, strategy string) error {
+	// nullable in the schemas of the older versions
+	query := `select id, guid, ifnull(link, ''), ifnull(title, ''), ifnull(content, '') from items`
+	args := []interface{}{}
+	if feedId != nil {
+		query += ` where feed_id = ?`
+		args = append(args, *feedId)
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return wrapError(err)
+	}
+	updates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var guid, link, title, content string
+		if err := rows.Scan(&id, &guid, &link, &title, &content); err != nil {
+			rows.Close()
+			return wrapError(err)
+		}
+		if strategy == GUIDAuto && guid != "" {
+			continue
+		}
+		if newGUID := ItemGUID(strategy, guid, link, title, content); newGUID != guid {
+			updates[id] = newGUID
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return wrapError(err)
+	}
+	for id, guid := range updates {
+		_, err := tx.Exec(`update or ignore items set guid = ? where id = ?`, guid, id)
+		if err != nil {
+			return wrapError(err)
+		}
+	}
+	return nil
+}
+
+// LookupItems returns the stored counterparts of fetched items (nil for
+// the ones not stored), identifying them the way CreateItems does.
+func (s *Storage) LookupItems(items []Item) ([]*Item, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := make([]*Item, len(items))
+	for i, item := range items {
+		guid, err := disambiguateGUID(tx, item)
+		if err != nil {
+			return nil, err
+		}
+		x := &Item{}
+		err = tx.QueryRow(`
+			select id, guid, feed_id, title, link, date, date_arrived, snoozed_until, status
+			from items where feed_id = ? and guid = ?`,
+			item.FeedId, guid,
+		).Scan(
+			&x.Id, &x.GUID, &x.FeedId, &x.Title, &x.Link,
+			&x.Date, &x.DateArrived, &x.SnoozedUntil, &x.Status,
+		)
+		switch err {
+		case nil:
+			result[i] = x
+		case sql.ErrNoRows:
+		default:
+			return nil, err
+		}
+	}
+	return result, nil
+}
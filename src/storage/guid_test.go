@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestItemGUID(t *testing.T) {
+	hash := contentHash("title", "content")
+	cases := []struct {
+		strategy, guid, link, want string
+	}{
+		{GUIDAuto, "guid", "http://example.com/1", "guid"},
+		{GUIDAuto, " ", "http://example.com/1", "http://example.com/1"},
+		{GUIDAuto, "", "", hash},
+		{GUIDLink, "guid", "http://example.com/1", "http://example.com/1"},
+		{GUIDLink, "guid", "", hash},
+		{GUIDHash, "guid", "http://example.com/1", hash},
+	}
+	for _, tc := range cases {
+		if have := ItemGUID(tc.strategy, tc.guid, tc.link, "title", "content"); have != tc.want {
+			t.Errorf("%q %q %q: expected %q, got %q", tc.strategy, tc.guid, tc.link, tc.want, have)
+		}
+	}
+}
+
+func TestReusedGUID(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Link: "http://example.com/first", Date: now},
+	})
+	// the same article with a fixed link, and a different article reusing the guid
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Link: "http://example.com/first-fixed", Date: now},
+		{GUID: "1", FeedId: feed.Id, Title: "second", Link: "http://example.com/second", Date: now},
+	})
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "second", Link: "http://example.com/second", Date: now},
+	})
+
+	items, _ := db.ListItems(ItemFilter{FeedID: &feed.Id}, 10, false, false)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %#v", getItemGuids(items, nil))
+	}
+	for _, item := range items {
+		if item.Title == "second" && !strings.HasPrefix(item.GUID, "1#") {
+			t.Errorf("expected the reused guid to be disambiguated, got %q", item.GUID)
+		}
+	}
+}
+
+func TestUpdateFeedGUIDStrategy(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Link: "http://example.com/first", Date: time.Now()},
+	})
+
+	if err := db.UpdateFeedGUIDStrategy(feed.Id, "bogus"); err == nil {
+		t.Fatal("accepted unknown strategy")
+	}
+	if err := db.UpdateFeedGUIDStrategy(feed.Id, GUIDLink); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(feed.Id); feed.GUIDStrategy != GUIDLink {
+		t.Fatal("strategy not saved")
+	}
+	if getItem(db, "http://example.com/first") == nil {
+		t.Fatal("expected existing items to be re-keyed")
+	}
+}
+
+func TestLookupItems(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Link: "http://example.com/first", Date: now},
+	})
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "second", Link: "http://example.com/second", Date: now},
+	})
+
+	found, err := db.LookupItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "first", Link: "http://example.com/first"},
+		{GUID: "1", FeedId: feed.Id, Title: "second", Link: "http://example.com/second"},
+		{GUID: "1", FeedId: feed.Id, Title: "third", Link: "http://example.com/third"},
+		{GUID: "2", FeedId: feed.Id, Title: "fourth", Link: "http://example.com/fourth"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found[0] == nil || found[0].Title != "first" {
+		t.Errorf("first item not found: %#v", found[0])
+	}
+	if found[1] == nil || found[1].Title != "second" || !strings.HasPrefix(found[1].GUID, "1#") {
+		t.Errorf("item with a reused guid not found: %#v", found[1])
+	}
+	if found[2] != nil || found[3] != nil {
+		t.Errorf("unexpected items found: %#v, %#v", found[2], found[3])
+	}
+}
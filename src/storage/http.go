@@ -1,7 +1,6 @@
 package storage
 
 import (
-	"log"
 	"time"
 )
 
@@ -13,13 +12,13 @@ type HTTPState struct {
 	Etag         string
 }
 
-func (s *Storage) ListHTTPStates() map[int64]HTTPState {
+func (s *Storage) ListHTTPStates() (map[int64]HTTPState, error) {
 	result := make(map[int64]HTTPState)
 	rows, err := s.db.Query(`select feed_id, last_refreshed, last_modified, etag from http_states`)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var state HTTPState
 		err = rows.Scan(
@@ -29,35 +28,34 @@ func (s *Storage) ListHTTPStates() map[int64]HTTPState {
 			&state.Etag,
 		)
 		if err != nil {
-			log.Print(err)
-			return result
+			return nil, err
 		}
 		result[state.FeedID] = state
 	}
-	return result
+	return result, wrapError(rows.Err())
 }
 
-func (s *Storage) GetHTTPState(feedID int64) *HTTPState {
-	row := s.db.QueryRow(`
+// GetHTTPState returns ErrNotFound if the feed hasn't been fetched yet.
+func (s *Storage) GetHTTPState(feedID int64) (*HTTPState, error) {
+	var state HTTPState
+	err := s.db.QueryRow(`
 		select feed_id, last_refreshed, last_modified, etag
 		from http_states where feed_id = ?
-	`, feedID)
-
-	if row == nil {
-		return nil
-	}
-
-	var state HTTPState
-	row.Scan(
+	`, feedID).Scan(
 		&state.FeedID,
 		&state.LastRefreshed,
 		&state.LastModified,
 		&state.Etag,
 	)
-	return &state
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &state, nil
 }
 
-func (s *Storage) SetHTTPState(feedID int64, lastModified, etag string) {
+// SetHTTPState stores the validators of the last downloaded document,
+// empty ones drop the previous values.
+func (s *Storage) SetHTTPState(feedID int64, lastModified, etag string) error {
 	_, err := s.db.Exec(`
 		insert into http_states (feed_id, last_modified, etag, last_refreshed)
 		values (?, ?, ?, datetime())
@@ -67,7 +65,24 @@ func (s *Storage) SetHTTPState(feedID int64, lastModified, etag string) {
 		// upsert
 		lastModified, etag,
 	)
-	if err != nil {
-		log.Print(err)
-	}
+	return wrapError(err)
+}
+
+// SetHTTPStateRefreshed records that the feed has just been fetched,
+// regardless of whether the server provided caching headers.
+func (s *Storage) SetHTTPStateRefreshed(feedID int64) error {
+	_, err := s.db.Exec(`
+		insert into http_states (feed_id, last_modified, etag, last_refreshed)
+		values (?, '', '', datetime())
+		on conflict (feed_id) do update set last_refreshed = datetime()`,
+		feedID,
+	)
+	return wrapError(err)
+}
+
+// ResetHTTPState forgets the validators of the feed, so that
+// the next fetch is unconditional.
+func (s *Storage) ResetHTTPState(feedID int64) error {
+	_, err := s.db.Exec(`update http_states set last_modified = '', etag = '' where feed_id = ?`, feedID)
+	return wrapError(err)
 }
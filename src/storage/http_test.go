@@ -0,0 +1,30 @@
+package storage
+
+import "testing"
+
+func TestHTTPState(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+
+	if state, _ := db.GetHTTPState(feed.Id); state != nil {
+		t.Fatalf("unexpected state of a new feed: %#v", state)
+	}
+
+	db.SetHTTPState(feed.Id, "Mon, 02 Jan 2006 15:04:05 GMT", `"v1"`)
+	state, _ := db.GetHTTPState(feed.Id)
+	if state == nil || state.Etag != `"v1"` || state.LastModified != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Fatalf("invalid state: %#v", state)
+	}
+	if state.LastRefreshed.IsZero() {
+		t.Error("refresh time not set")
+	}
+
+	// the validators don't carry over to another url
+	if err := db.UpdateFeedLink(feed.Id, "http://example.com/other.xml"); err != nil {
+		t.Fatal(err)
+	}
+	state, _ = db.GetHTTPState(feed.Id)
+	if state == nil || state.Etag != "" || state.LastModified != "" {
+		t.Errorf("validators not reset: %#v", state)
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"time"
+)
+
+// IconState tracks the favicon lookups of a feed.
+type IconState struct {
+	FeedId    int64     `json:"feed_id"`
+	Attempts  int       `json:"attempts"`
+	NextCheck time.Time `json:"next_check"`
+	Error     string    `json:"error,omitempty"`
+
+	// where the icon has been found, see ReplaceFeedIcon
+	Source IconSource `json:"source"`
+	// when a different icon was found last
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+// IconSource is the url an icon has been fetched from, along with
+// the validators of the response for the conditional rechecks.
+type IconSource struct {
+	URL          string `json:"url,omitempty"`
+	Etag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ListFeedsDueIconCheck returns the feeds which have never been looked
+// up for an icon, as well as those scheduled for a (re)check by now.
+func (s *Storage) ListFeedsDueIconCheck(now time.Time) ([]Feed, error) {
+	result := make([]Feed, 0)
+	rows, err := s.db.Query(`
+		select f.id, f.folder_id, f.title, f.description, f.link, f.feed_link,
+		       ifnull(length(f.icon), 0) > 0 as has_icon
+		from feeds f
+		left join icon_states s on s.feed_id = f.id
+		where f.deleted_at is null and ((s.feed_id is null and f.icon is null) or s.next_check <= ?)
+		order by f.id
+	`, now.UTC())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f Feed
+		err = rows.Scan(
+			&f.Id,
+			&f.FolderId,
+			&f.Title,
+			&f.Description,
+			&f.Link,
+			&f.FeedLink,
+			&f.HasIcon,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetIconState(feedId int64) (*IconState, error) {
+	var state IconState
+	err := s.db.QueryRow(`
+		select feed_id, attempts, next_check, error, url, etag, last_modified, changed_at
+		from icon_states where feed_id = ?
+	`, feedId).Scan(
+		&state.FeedId, &state.Attempts, &state.NextCheck, &state.Error,
+		&state.Source.URL, &state.Source.Etag, &state.Source.LastModified, &state.ChangedAt,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &state, nil
+}
+
+// SetIconState records the outcome of a lookup and when to do the next one.
+func (s *Storage) SetIconState(feedId int64, attempts int, nextCheck time.Time, checkErr error) error {
+	errmsg := ""
+	if checkErr != nil {
+		errmsg = checkErr.Error()
+	}
+	_, err := s.db.Exec(`
+		insert into icon_states (feed_id, attempts, next_check, error)
+		values (?, ?, ?, ?)
+		on conflict (feed_id) do update set
+		 attempts = excluded.attempts,
+		 next_check = excluded.next_check,
+		 error = excluded.error
+	`, feedId, attempts, nextCheck.UTC(), errmsg)
+	return wrapError(err)
+}
+
+// ReplaceFeedIcon stores the icon fetched from the source, reporting
+// whether it differs from the previous one. The outcome of the lookup
+// is to be recorded with SetIconState.
+func (s *Storage) ReplaceFeedIcon(feedId int64, icon []byte, source IconSource) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		update feeds set icon = ?
+		where id = ? and (icon is null or icon != ?)
+	`, icon, feedId, icon)
+	if err != nil {
+		return false, wrapError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	changed := n > 0
+	now := time.Now().UTC()
+	_, err = tx.Exec(`
+		insert into icon_states (feed_id, next_check, url, etag, last_modified, changed_at)
+		values (?, ?, ?, ?, ?, case when ? then ? end)
+		on conflict (feed_id) do update set
+		 url = excluded.url,
+		 etag = excluded.etag,
+		 last_modified = excluded.last_modified,
+		 changed_at = ifnull(excluded.changed_at, changed_at)
+	`, feedId, now, source.URL, source.Etag, source.LastModified, changed, now)
+	if err != nil {
+		return false, wrapError(err)
+	}
+	return changed, tx.Commit()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIconChecks(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://test.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://test.com/feed2.xml", "", nil)
+	feed3, _ := db.CreateFeed("feed3", "", "", "http://test.com/feed3.xml", "", nil)
+	icon := []byte("icon")
+	db.UpdateFeedIcon(feed3.Id, &icon)
+	// trashed feeds aren't looked up
+	feed4, _ := db.CreateFeed("feed4", "", "", "http://test.com/feed4.xml", "", nil)
+	db.TrashFeed(feed4.Id)
+
+	now := time.Now()
+	dueIds := func(now time.Time) []int64 {
+		ids := make([]int64, 0)
+		feeds, _ := db.ListFeedsDueIconCheck(now)
+		for _, feed := range feeds {
+			ids = append(ids, feed.Id)
+		}
+		return ids
+	}
+	if have := dueIds(now); len(have) != 2 || have[0] != feed1.Id || have[1] != feed2.Id {
+		t.Fatalf("feeds without icons must be due: %v", have)
+	}
+
+	db.SetIconState(feed1.Id, 0, now.Add(time.Hour*24*30), nil)
+	db.SetIconState(feed2.Id, 2, now.Add(time.Hour), errors.New("timeout"))
+	if have := dueIds(now); len(have) != 0 {
+		t.Fatalf("expected nothing due, got %v", have)
+	}
+	if have := dueIds(now.Add(time.Hour * 2)); len(have) != 1 || have[0] != feed2.Id {
+		t.Fatalf("expected failed lookup to be retried, got %v", have)
+	}
+
+	state, _ := db.GetIconState(feed2.Id)
+	if state == nil || state.Attempts != 2 || state.Error != "timeout" {
+		t.Fatalf("unexpected state: %#v", state)
+	}
+	if _, err := db.GetIconState(feed3.Id); err != ErrNotFound {
+		t.Fatalf("expected no state, got %v", err)
+	}
+}
+
+func TestReplaceFeedIcon(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/feed.xml", "", nil)
+
+	source := IconSource{URL: "http://test.com/favicon.ico", Etag: `"v1"`}
+	if changed, err := db.ReplaceFeedIcon(feed.Id, []byte("icon"), source); err != nil || !changed {
+		t.Fatalf("first icon: changed %v, err %v", changed, err)
+	}
+	state, _ := db.GetIconState(feed.Id)
+	if state == nil || state.Source != source || state.ChangedAt == nil {
+		t.Fatalf("unexpected state: %#v", state)
+	}
+	changedAt := *state.ChangedAt
+
+	source.Etag = `"v2"`
+	if changed, err := db.ReplaceFeedIcon(feed.Id, []byte("icon"), source); err != nil || changed {
+		t.Fatalf("same icon: changed %v, err %v", changed, err)
+	}
+	state, _ = db.GetIconState(feed.Id)
+	if state.Source.Etag != `"v2"` || !state.ChangedAt.Equal(changedAt) {
+		t.Fatalf("unexpected state: %#v", state)
+	}
+
+	if changed, err := db.ReplaceFeedIcon(feed.Id, []byte("new icon"), source); err != nil || !changed {
+		t.Fatalf("new icon: changed %v, err %v", changed, err)
+	}
+	if feed, _ := db.GetFeed(feed.Id); feed == nil || string(*feed.Icon) != "new icon" {
+		t.Fatalf("icon not replaced: %#v", feed)
+	}
+}
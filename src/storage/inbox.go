@@ -0,0 +1,57 @@
+package storage
+
+// InboxFolder is created for the new subscriptions when the
+// "default_folder" setting is DefaultFolderInbox.
+const InboxFolder = "Inbox"
+
+// DefaultFolderInbox is the value of the "default_folder" setting
+// putting the new subscriptions in the InboxFolder.
+const DefaultFolderInbox = "inbox"
+
+// ValidateDefaultFolder checks the "default_folder" setting: where the
+// subscriptions without a folder land. Either empty (at the top level),
+// DefaultFolderInbox, or the id of a folder.
+func ValidateDefaultFolder(val interface{}) error {
+	switch v := val.(type) {
+	case string:
+		if v == "" || v == DefaultFolderInbox {
+			return nil
+		}
+	case float64:
+		if v > 0 && v == float64(int64(v)) {
+			return nil
+		}
+	}
+	return &ValidationError{"default_folder", `must be empty, "inbox" or the id of a folder`}
+}
+
+// DefaultFolderId returns the folder the subscriptions without a folder
+// land in (creating the inbox if needed), nil for the top level. The
+// top level is used as well if the folder set up has been deleted.
+func (s *Storage) DefaultFolderId() (*int64, error) {
+	val, err := s.GetSettingsValue("default_folder")
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case string:
+		if v != DefaultFolderInbox {
+			return nil, nil
+		}
+		folder, err := s.CreateFolder(InboxFolder)
+		if err != nil {
+			return nil, err
+		}
+		return &folder.Id, nil
+	case float64:
+		id := int64(v)
+		var exists bool
+		if err := s.db.QueryRow(`select exists (select 1 from folders where id = ?)`, id).Scan(&exists); err != nil {
+			return nil, wrapError(err)
+		}
+		if exists {
+			return &id, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import "testing"
+
+func TestDefaultFolder(t *testing.T) {
+	db := testDB()
+
+	feed, _ := db.CreateFeed("top", "", "", "http://example.com/top.xml", "", nil)
+	if feed.FolderId != nil {
+		t.Errorf("want the feed at the top level, have folder %d", *feed.FolderId)
+	}
+
+	if err := db.UpdateSettings(map[string]interface{}{"default_folder": DefaultFolderInbox}); err != nil {
+		t.Fatal("failed to set the default folder")
+	}
+	feed, _ = db.CreateFeed("inbox", "", "", "http://example.com/inbox.xml", "", nil)
+	inbox, _ := db.CreateFolder(InboxFolder)
+	if feed.FolderId == nil || *feed.FolderId != inbox.Id {
+		t.Errorf("want the feed in the inbox, have %v", feed.FolderId)
+	}
+
+	// a folder given explicitly wins
+	other, _ := db.CreateFolder("other")
+	feed, _ = db.CreateFeed("other", "", "", "http://example.com/other.xml", "", &other.Id)
+	if feed.FolderId == nil || *feed.FolderId != other.Id {
+		t.Errorf("want the feed in its folder, have %v", feed.FolderId)
+	}
+
+	if err := db.UpdateSettings(map[string]interface{}{"default_folder": float64(other.Id)}); err != nil {
+		t.Fatal("failed to set the default folder")
+	}
+	if id, _ := db.DefaultFolderId(); id == nil || *id != other.Id {
+		t.Errorf("want the default folder %d, have %v", other.Id, id)
+	}
+	if err := db.DeleteFolder(other.Id); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := db.DefaultFolderId(); id != nil {
+		t.Errorf("want the top level once the folder is deleted, have %d", *id)
+	}
+
+	for _, val := range []interface{}{"elsewhere", float64(-1), 1.5, true} {
+		if db.UpdateSettings(map[string]interface{}{"default_folder": val}) == nil {
+			t.Errorf("%#v: want the setting rejected", val)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Severity ranks the incidents announced by status pages,
+// see parser.DetectIncidents.
+type Severity int
+
+const (
+	// not an incident, or any severity in NotificationRoute.MinSeverity
+	SeverityNone        Severity = 0
+	SeverityMaintenance Severity = 1
+	SeverityMinor       Severity = 2
+	SeverityMajor       Severity = 3
+	SeverityCritical    Severity = 4
+)
+
+var SeverityRepresentations = map[Severity]string{
+	SeverityNone:        "",
+	SeverityMaintenance: "maintenance",
+	SeverityMinor:       "minor",
+	SeverityMajor:       "major",
+	SeverityCritical:    "critical",
+}
+
+var SeverityValues = map[string]Severity{
+	"":            SeverityNone,
+	"maintenance": SeverityMaintenance,
+	"minor":       SeverityMinor,
+	"major":       SeverityMajor,
+	"critical":    SeverityCritical,
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SeverityRepresentations[s])
+}
+
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	severity, ok := SeverityValues[str]
+	if !ok {
+		return &ValidationError{"severity", fmt.Sprintf("unknown severity %q", str)}
+	}
+	*s = severity
+	return nil
+}
+
+// Incident is the state of an incident announced by a status page.
+type Incident struct {
+	// the latest update, e.g. "investigating" or "resolved"
+	Status   string   `json:"status,omitempty"`
+	Severity Severity `json:"severity"`
+}
+
+const incidentCols = "i.incident_status, i.incident_severity"
+
+type incidentScanner struct {
+	status   sql.NullString
+	severity sql.NullInt64
+}
+
+func (s *incidentScanner) dest() []interface{} {
+	return []interface{}{&s.status, &s.severity}
+}
+
+func (s *incidentScanner) value() *Incident {
+	if !s.severity.Valid {
+		return nil
+	}
+	return &Incident{Status: s.status.String, Severity: Severity(s.severity.Int64)}
+}
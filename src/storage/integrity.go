@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ForeignKeyViolation struct {
+	Table  string
+	RowId  int64
+	Parent string
+}
+
+type IntegrityReport struct {
+	// output of `pragma integrity_check`, empty if the database is fine
+	Errors     []string
+	Violations []ForeignKeyViolation
+}
+
+func (r *IntegrityReport) OK() bool {
+	return len(r.Errors) == 0 && len(r.Violations) == 0
+}
+
+func (r *IntegrityReport) String() string {
+	if r.OK() {
+		return "ok"
+	}
+	lines := make([]string, 0, len(r.Errors)+len(r.Violations))
+	lines = append(lines, r.Errors...)
+	for _, v := range r.Violations {
+		lines = append(lines, fmt.Sprintf("%s row %d references missing %s", v.Table, v.RowId, v.Parent))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckIntegrity reports corruption and rows referencing missing parents.
+func (s *Storage) CheckIntegrity() (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+
+	rows, err := s.db.Query(`pragma integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if line != "ok" {
+			report.Errors = append(report.Errors, line)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`select "table", ifnull(rowid, 0), parent from pragma_foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var v ForeignKeyViolation
+		if err = rows.Scan(&v.Table, &v.RowId, &v.Parent); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Violations = append(report.Violations, v)
+	}
+	return report, rows.Err()
+}
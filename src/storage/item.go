@@ -1,14 +1,14 @@
 package storage
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 	"time"
-
-	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"unicode/utf8"
 )
 
 type ItemStatus int
@@ -50,11 +50,69 @@ type Item struct {
 	FeedId   int64      `json:"feed_id"`
 	Title    string     `json:"title"`
 	Link     string     `json:"link"`
+	Author   string     `json:"author,omitempty"`
 	Content  string     `json:"content,omitempty"`
 	Date     time.Time  `json:"date"`
 	Status   ItemStatus `json:"status"`
 	ImageURL *string    `json:"image"`
-	AudioURL *string    `json:"podcast_url"`
+
+	// the language of the content, the feed's unless the item says otherwise
+	Language string `json:"language,omitempty"`
+	// all of the media files, see Enclosure
+	Enclosures []Enclosure `json:"enclosures,omitempty"`
+
+	// when the item was first fetched, nil for items stored before it was recorded
+	DateArrived *time.Time `json:"date_arrived,omitempty"`
+	// when the publisher last changed the item, if the feed says so
+	DateUpdated *time.Time `json:"date_updated,omitempty"`
+
+	// size of the content before truncation, nil if the content is intact
+	OriginalSize *int `json:"original_size,omitempty"`
+	// when a snoozed item becomes unread again, see SnoozeItem
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// when the user read the item, see UpdateItemStatus
+	ReadAt *time.Time `json:"read_at,omitempty"`
+
+	// where the item is about (in degrees), if the feed says so
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+
+	Playback *Playback     `json:"playback,omitempty"`
+	Podcast  *ItemPodcast  `json:"podcast,omitempty"`
+	Snapshot *ItemSnapshot `json:"snapshot,omitempty"`
+	Tags     []string      `json:"tags,omitempty"`
+	// the user's own, see SetItemNote
+	Note string `json:"note,omitempty"`
+	// set for the items of status pages
+	Incident *Incident `json:"incident,omitempty"`
+
+	// set for search results, see SearchMatches
+	Match *SearchMatch `json:"match,omitempty"`
+}
+
+// ItemSort is the date items are listed by.
+type ItemSort string
+
+const (
+	SortPublished ItemSort = ""
+	SortUpdated   ItemSort = "updated"
+	// when yarr first saw the item, so that backfilled old posts
+	// don't end up below the ones already read
+	SortArrived ItemSort = "arrived"
+	// when the user read the item, listing only the read ones
+	SortRead ItemSort = "read"
+)
+
+func (s ItemSort) column() string {
+	switch s {
+	case SortUpdated:
+		return "ifnull(i.date_updated, i.date)"
+	case SortArrived:
+		return "ifnull(i.date_arrived, i.date)"
+	case SortRead:
+		return "i.read_at"
+	}
+	return "i.date"
 }
 
 type ItemFilter struct {
@@ -67,11 +125,30 @@ type ItemFilter struct {
 	SinceID  *int64
 	MaxID    *int64
 	Before   *time.Time
+
+	// also determines the order of the After cursor
+	SortBy ItemSort
+	// limits Search to the title or content, see SearchTitle
+	SearchField string
+	// only the items waiting to become unread again
+	Snoozed bool
+	// only the items with the tag, see AddItemTag
+	Tag *string
+	// only the items located within the area
+	Within *BoundingBox
+	// only the first copy of the items found in several feeds, see dedupHash
+	CollapseDuplicates bool
+	// only the items in the language ("en" matches "en-US" as well)
+	Language *string
 }
 
 type MarkFilter struct {
 	FolderID *int64
 	FeedID   *int64
+	Tag      *string
+	// see ItemFilter.Search
+	Search      *string
+	SearchField string
 
 	Before *time.Time
 }
@@ -94,52 +171,198 @@ func (list ItemList) Swap(i, j int) {
 	list[i], list[j] = list[j], list[i]
 }
 
-func (s *Storage) CreateItems(items []Item) bool {
+// MaxItemContentSize is the maximum number of bytes of item content
+// kept in the database. Larger contents (usually inlined base64 images)
+// are truncated. Zero disables the limit.
+var MaxItemContentSize = 1 << 20
+
+const truncationMarker = `<p><em>[content truncated]</em></p>`
+
+// truncateContent cuts the content down to the limit,
+// avoiding splitting html tags and utf-8 sequences.
+func truncateContent(content string, limit int) (string, bool) {
+	if limit <= 0 || len(content) <= limit {
+		return content, false
+	}
+	cut := limit - len(truncationMarker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	content = content[:cut]
+	if lt := strings.LastIndexByte(content, '<'); lt > strings.LastIndexByte(content, '>') {
+		content = content[:lt]
+	}
+	return content + truncationMarker, true
+}
+
+// CreateItems stores the items, updating the ones edited since they
+// were stored. Returns the number of new items.
+func (s *Storage) CreateItems(items []Item) (int, error) {
+	// read before the transaction holds the connection
+	loc, err := s.Location()
+	if err != nil {
+		return 0, err
+	}
+	local := time.Now().In(loc)
+
 	tx, err := s.db.Begin()
 	if err != nil {
-		log.Print(err)
-		return false
+		return 0, err
 	}
 
-	now := time.Now().UTC()
+	now := local.UTC()
+
+	held, err := deliveries(tx, local)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	allowed, err := dailyAllowances(tx, local)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	filters, err := loadItemFilters(tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
 	itemsSorted := ItemList(items)
 	sort.Sort(itemsSorted)
 
+	created := 0
 	for _, item := range itemsSorted {
-		_, err = tx.Exec(`
-			insert into items (
-				guid, feed_id, title, link, date,
-				content, image, podcast_url,
-				date_arrived, status
-			)
-			values (?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%f', ?), ?, ?, ?, ?, ?)
-			on conflict (feed_id, guid) do nothing`,
-			item.GUID, item.FeedId, item.Title, item.Link, item.Date,
-			item.Content, item.ImageURL, item.AudioURL,
-			now, UNREAD,
-		)
-		if err != nil {
-			log.Print(err)
-			if err = tx.Rollback(); err != nil {
-				log.Print(err)
-				return false
+		item.Title = cleanText(item.Title, MaxTitleLength)
+		item.Author = cleanText(item.Author, MaxTitleLength)
+		item.Content = cleanText(item.Content, 0)
+		var originalSize *int
+		if content, truncated := truncateContent(item.Content, MaxItemContentSize); truncated {
+			size := len(item.Content)
+			originalSize = &size
+			item.Content = content
+		}
+		status, snoozedUntil := UNREAD, (*time.Time)(nil)
+		left, limited := allowed[item.FeedId]
+		if next, ok := held[item.FeedId]; ok {
+			status, snoozedUntil = READ, &next
+		} else if limited && left <= 0 {
+			// over the daily limit of the feed
+			status = READ
+		}
+		var incidentStatus, incidentSeverity interface{}
+		if item.Incident != nil {
+			incidentStatus, incidentSeverity = item.Incident.Status, item.Incident.Severity
+		}
+		item.GUID, err = disambiguateGUID(tx, item)
+		if err == nil {
+			var id int64
+			var isNew bool
+			err = tx.QueryRow(`
+				insert into items (
+					guid, feed_id, title, link, author, language, date, date_updated,
+					content, image,
+					date_arrived, status, snoozed_until, original_size,
+					latitude, longitude, dedup_hash,
+					incident_status, incident_severity
+				)
+				values (
+					?, ?, ?, ?, ?, ?,
+					strftime('%Y-%m-%d %H:%M:%f', ?), strftime('%Y-%m-%d %H:%M:%f', ?),
+					?, ?, ?, ?, ?, ?,
+					?, ?, nullif(?, ''),
+					?, ?
+				)
+				on conflict (feed_id, guid) do update set
+					title = excluded.title,
+					link = excluded.link,
+					author = excluded.author,
+					language = excluded.language,
+					content = excluded.content,
+					date_updated = excluded.date_updated,
+					original_size = excluded.original_size,
+					latitude = excluded.latitude,
+					longitude = excluded.longitude,
+					dedup_hash = excluded.dedup_hash,
+					incident_status = excluded.incident_status,
+					incident_severity = excluded.incident_severity
+				where excluded.date_updated > ifnull(items.date_updated, '')
+				returning id, date_arrived = ?`,
+				item.GUID, item.FeedId, item.Title, item.Link, item.Author, item.Language, item.Date, item.DateUpdated,
+				item.Content, item.ImageURL,
+				now, status, snoozedUntil, originalSize,
+				item.Latitude, item.Longitude, dedupHash(item.Link, item.Title, item.Content),
+				incidentStatus, incidentSeverity,
+				now,
+			).Scan(&id, &isNew)
+			switch err {
+			case nil:
+				// new or edited item
+				err = indexItem(tx, id, item.Title, item.Author, item.Content)
+				if err == nil {
+					err = setItemEnclosures(tx, id, item.Enclosures)
+				}
+				if err == nil && isNew {
+					created++
+					if limited {
+						allowed[item.FeedId]--
+					}
+					var actions filterActions
+					if len(filters) > 0 {
+						actions = applyItemFilters(filters, item)
+						err = applyFilterActions(tx, id, actions)
+					}
+					// the items marked read by a filter aren't worth a notification
+					if err == nil && (!limited || left > 0) && (!actions.read || actions.star) {
+						if snoozedUntil != nil && !actions.star {
+							// held for delivery, see WakeSnoozedItems (the
+							// starred ones were let through by the filter)
+							_, err = tx.Exec(`update items set notify_on_wake = 1 where id = ?`, id)
+						} else {
+							err = queueNotifications(tx, id, now)
+						}
+					}
+				}
+			case sql.ErrNoRows:
+				err = nil
 			}
-			return false
 		}
+		if err == nil && item.Podcast != nil {
+			err = createItemPodcast(tx, item)
+		}
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err = applyImportedStates(tx); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 	if err = tx.Commit(); err != nil {
-		log.Print(err)
-		return false
+		return 0, err
 	}
-	return true
+	return created, nil
 }
 
+// itemLanguage is the language of the item i, falling back to its feed's.
+const itemLanguage = "ifnull(nullif(i.language, ''), (select language from feeds where id = i.feed_id))"
+
 func listQueryPredicate(filter ItemFilter, newestFirst bool) (string, []interface{}) {
-	cond := make([]string, 0)
+	// the items of the deleted feeds are hidden until they're purged
+	cond := []string{"i.feed_id not in (select id from feeds where deleted_at is not null)"}
 	args := make([]interface{}, 0)
 	if filter.FolderID != nil {
-		cond = append(cond, "i.feed_id in (select id from feeds where folder_id = ?)")
+		// including the subfolders
+		cond = append(cond, `i.feed_id in (
+			with recursive `+folderAncestors+`
+			select f.id from feeds f
+			join folder_ancestors a on a.folder_id = f.folder_id
+			where a.ancestor_id = ?
+		)`)
 		args = append(args, *filter.FolderID)
 	}
 	if filter.FeedID != nil {
@@ -150,22 +373,34 @@ func listQueryPredicate(filter ItemFilter, newestFirst bool) (string, []interfac
 		cond = append(cond, "i.status = ?")
 		args = append(args, *filter.Status)
 	}
+	if filter.Snoozed {
+		cond = append(cond, "i.snoozed_until is not null")
+	}
+	if filter.SortBy == SortRead {
+		cond = append(cond, "i.read_at is not null")
+	}
+	if filter.Language != nil {
+		language := strings.ToLower(*filter.Language)
+		cond = append(cond, "(lower("+itemLanguage+") = ? or lower("+itemLanguage+") like ? escape '\\')")
+		args = append(args, language, strings.NewReplacer("%", "\\%", "_", "\\_").Replace(language)+"-%")
+	}
+	if filter.Tag != nil {
+		// an invalid tag matches nothing
+		tag, _ := normalizeTag(*filter.Tag)
+		cond = append(cond, "i.id in (select item_id from item_tags where tag = ?)")
+		args = append(args, tag)
+	}
 	if filter.Search != nil {
-		words := strings.Fields(*filter.Search)
-		terms := make([]string, len(words))
-		for idx, word := range words {
-			terms[idx] = word + "*"
-		}
-
 		cond = append(cond, "i.search_rowid in (select rowid from search where search match ?)")
-		args = append(args, strings.Join(terms, " "))
+		args = append(args, searchQuery(*filter.Search, filter.SearchField))
 	}
 	if filter.After != nil {
 		compare := ">"
 		if newestFirst {
 			compare = "<"
 		}
-		cond = append(cond, fmt.Sprintf("(i.date, i.id) %s (select date, id from items where id = ?)", compare))
+		column := filter.SortBy.column()
+		cond = append(cond, fmt.Sprintf("(%s, i.id) %s (select %s, i.id from items i where i.id = ?)", column, compare, column))
 		args = append(args, *filter.After)
 	}
 	if filter.IDs != nil && len(*filter.IDs) > 0 {
@@ -190,39 +425,47 @@ func listQueryPredicate(filter ItemFilter, newestFirst bool) (string, []interfac
 		cond = append(cond, "i.date < ?")
 		args = append(args, filter.Before)
 	}
-
-	predicate := "1"
-	if len(cond) > 0 {
-		predicate = strings.Join(cond, " and ")
+	if box := filter.Within; box != nil {
+		cond = append(cond, "i.latitude between ? and ?")
+		args = append(args, box.South, box.North)
+		if box.West <= box.East {
+			cond = append(cond, "i.longitude between ? and ?")
+		} else {
+			// across the antimeridian
+			cond = append(cond, "(i.longitude >= ? or i.longitude <= ?)")
+		}
+		args = append(args, box.West, box.East)
+	}
+	if filter.CollapseDuplicates {
+		cond = append(cond, collapsedDuplicates)
 	}
 
-	return predicate, args
+	return strings.Join(cond, " and "), args
 }
 
-func (s *Storage) CountItems(filter ItemFilter) int {
+func (s *Storage) CountItems(filter ItemFilter) (int, error) {
 	predicate, args := listQueryPredicate(filter, false)
 
 	var count int
 	query := fmt.Sprintf(`
 		select count(*)
-		from items
+		from items i
 		where %s
 		`, predicate)
 	err := s.db.QueryRow(query, args...).Scan(&count)
 	if err != nil {
-		log.Print(err)
-		return 0
+		return 0, wrapError(err)
 	}
-	return count
+	return count, nil
 }
 
-func (s *Storage) ListItems(filter ItemFilter, limit int, newestFirst bool, withContent bool) []Item {
+func (s *Storage) ListItems(filter ItemFilter, limit int, newestFirst bool, withContent bool) ([]Item, error) {
 	predicate, args := listQueryPredicate(filter, newestFirst)
 	result := make([]Item, 0, 0)
 
-	order := "i.date desc, i.id desc"
+	order := filter.SortBy.column() + " desc, i.id desc"
 	if !newestFirst {
-		order = "i.date asc, i.id asc"
+		order = filter.SortBy.column() + " asc, i.id asc"
 	}
 	if filter.IDs != nil || filter.SinceID != nil {
 		order = "i.id asc"
@@ -231,12 +474,13 @@ func (s *Storage) ListItems(filter ItemFilter, limit int, newestFirst bool, with
 		order = "i.id desc"
 	}
 
-	selectCols := "i.id, i.guid, i.feed_id, i.title, i.link, i.date, i.status, i.image, i.podcast_url"
+	selectCols := "i.id, i.guid, i.feed_id, i.title, i.link, ifnull(i.author, ''), " + itemLanguage + ", i.date, i.date_arrived, i.date_updated, i.snoozed_until, i.read_at, i.status, i.image, i.latitude, i.longitude"
 	if withContent {
 		selectCols += ", i.content"
 	} else {
 		selectCols += ", '' as content"
 	}
+	selectCols += ", " + incidentCols + ", " + playbackCols
 
 	customOrder := ""
 	if filter.Status != nil && *filter.Status == UNREAD {
@@ -252,136 +496,237 @@ func (s *Storage) ListItems(filter ItemFilter, limit int, newestFirst bool, with
 		from items i
 		inner join feeds f
 		on f.id = i.feed_id
+		left join playback p
+		on p.item_id = i.id
 		where %s
 		order by %s%s
 		limit %d
 		`, selectCols, predicate, customOrder, order, limit)
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var x Item
-		err = rows.Scan(
+		var incident incidentScanner
+		var playback playbackScanner
+		dest := []interface{}{
 			&x.Id, &x.GUID, &x.FeedId,
-			&x.Title, &x.Link, &x.Date,
-			&x.Status, &x.ImageURL, &x.AudioURL, &x.Content,
-		)
+			&x.Title, &x.Link, &x.Author, &x.Language, &x.Date, &x.DateArrived, &x.DateUpdated, &x.SnoozedUntil, &x.ReadAt,
+			&x.Status, &x.ImageURL, &x.Latitude, &x.Longitude, &x.Content,
+		}
+		dest = append(dest, incident.dest()...)
+		err = rows.Scan(append(dest, playback.dest()...)...)
 		if err != nil {
-			log.Print(err)
-			return result
+			return nil, wrapError(err)
 		}
+		x.Incident = incident.value()
+		x.Playback = playback.value()
 		result = append(result, x)
 	}
-	return result
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+	if err := s.loadItemDetails(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (s *Storage) GetItem(id int64) *Item {
+// loadItemDetails fills in the tags, notes and enclosures of the items.
+func (s *Storage) loadItemDetails(items []Item) error {
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+	tags, err := s.ItemTags(ids)
+	if err != nil {
+		return err
+	}
+	notes, err := s.ItemNotes(ids)
+	if err != nil {
+		return err
+	}
+	enclosures, err := s.ItemEnclosures(ids)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].Tags = tags[items[i].Id]
+		items[i].Note = notes[items[i].Id]
+		items[i].Enclosures = enclosures[items[i].Id]
+	}
+	return nil
+}
+
+func (s *Storage) GetItem(id int64) (*Item, error) {
 	i := &Item{}
-	err := s.db.QueryRow(`
+	var incident incidentScanner
+	var playback playbackScanner
+	dest := []interface{}{
+		&i.Id, &i.GUID, &i.FeedId, &i.Title, &i.Link, &i.Author, &i.Language, &i.Content,
+		&i.Date, &i.DateArrived, &i.DateUpdated, &i.SnoozedUntil, &i.ReadAt,
+		&i.Status, &i.ImageURL, &i.OriginalSize,
+		&i.Latitude, &i.Longitude,
+	}
+	dest = append(dest, incident.dest()...)
+	err := s.db.QueryRow(fmt.Sprintf(`
 		select
-			i.id, i.guid, i.feed_id, i.title, i.link, i.content,
-			i.date, i.status, i.image, i.podcast_url
+			i.id, i.guid, i.feed_id, i.title, i.link, ifnull(i.author, ''), %s, i.content,
+			i.date, i.date_arrived, i.date_updated, i.snoozed_until, i.read_at,
+			i.status, i.image, i.original_size,
+			i.latitude, i.longitude,
+			%s, %s
 		from items i
+		left join playback p on p.item_id = i.id
 		where i.id = ?
-	`, id).Scan(
-		&i.Id, &i.GUID, &i.FeedId, &i.Title, &i.Link, &i.Content,
-		&i.Date, &i.Status, &i.ImageURL, &i.AudioURL,
-	)
+	`, itemLanguage, incidentCols, playbackCols), id).Scan(append(dest, playback.dest()...)...)
 	if err != nil {
-		log.Print(err)
-		return nil
+		return nil, wrapError(err)
 	}
-	return i
+	i.Incident = incident.value()
+	i.Playback = playback.value()
+	items := []Item{*i}
+	if err := s.loadItemDetails(items); err != nil {
+		return nil, err
+	}
+	return &items[0], nil
 }
 
-func (s *Storage) UpdateItemStatus(item_id int64, status ItemStatus) bool {
-	_, err := s.db.Exec(`update items set status = ? where id = ?`, status, item_id)
-	return err == nil
+// UpdateItemStatus changes the status of the item on behalf of the user,
+// recording when it's read (a snoozed item wasn't) in its ReadAt.
+func (s *Storage) UpdateItemStatus(item_id int64, status ItemStatus) error {
+	// changing the status manually cancels the snooze
+	err := s.execOne(`
+		update items
+		set status = ?,
+		    snoozed_until = null,
+		    notify_on_wake = 0,
+		    read_at = case
+		      when ? = ? then null
+		      when status = ? or snoozed_until is not null then strftime('%Y-%m-%d %H:%M:%f', ?)
+		      else read_at
+		    end
+		where id = ?`,
+		status, status, UNREAD, UNREAD, time.Now().UTC(), item_id,
+	)
+	if err == nil && status != UNREAD {
+		err = s.recordFeedRead(item_id)
+	}
+	return err
 }
 
-func (s *Storage) MarkItemsRead(filter MarkFilter) bool {
+func (s *Storage) MarkItemsRead(filter MarkFilter) error {
 	predicate, args := listQueryPredicate(ItemFilter{
-		FolderID: filter.FolderID,
-		FeedID:   filter.FeedID,
-		Before:   filter.Before,
+		FolderID:    filter.FolderID,
+		FeedID:      filter.FeedID,
+		Tag:         filter.Tag,
+		Search:      filter.Search,
+		SearchField: filter.SearchField,
+		Before:      filter.Before,
 	}, false)
 	query := fmt.Sprintf(`
-		update items as i set status = %d
+		update items as i
+		set status = %d,
+		    read_at = case when i.status = %d then strftime('%%Y-%%m-%%d %%H:%%M:%%f', ?) else i.read_at end
 		where %s and i.status != %d
-		`, READ, predicate, STARRED)
-	_, err := s.db.Exec(query, args...)
-	if err != nil {
-		log.Print(err)
-	}
-	return err == nil
+		`, READ, UNREAD, predicate, STARRED)
+	_, err := s.db.Exec(query, append([]interface{}{time.Now().UTC()}, args...)...)
+	return wrapError(err)
 }
 
 type FeedStat struct {
 	FeedId       int64 `json:"feed_id"`
 	UnreadCount  int64 `json:"unread"`
 	StarredCount int64 `json:"starred"`
+	// whether the feed's folder is in a quiet period, see MutePeriod
+	Muted bool `json:"muted,omitempty"`
 }
 
-func (s *Storage) FeedStats() []FeedStat {
-	result := make([]FeedStat, 0)
-	rows, err := s.db.Query(fmt.Sprintf(`
-		select
-			feed_id,
-			sum(case status when %d then 1 else 0 end),
-			sum(case status when %d then 1 else 0 end)
-		from items
-		group by feed_id
-	`, UNREAD, STARRED))
+// RebuildFeedCounts recounts the unread and starred items of the feeds,
+// in case the counters maintained by the triggers got out of sync.
+func (s *Storage) RebuildFeedCounts() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`delete from feed_counts`)
+	if err == nil {
+		_, err = tx.Exec(`
+			insert into feed_counts (feed_id, unread, starred)
+			select
+				feed_id,
+				sum(case status when ? then 1 else 0 end),
+				sum(case status when ? then 1 else 0 end)
+			from items
+			group by feed_id`,
+			UNREAD, STARRED,
+		)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) FeedStats() ([]FeedStat, error) {
+	muted, err := s.MutedFeeds(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// maintained by triggers, see m41_feed_counts
+	rows, err := s.db.Query(`
+		select feed_id, unread, starred from feed_counts
+		where feed_id not in (select id from feeds where deleted_at is not null)
+	`)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
+	result := make([]FeedStat, 0)
 	for rows.Next() {
 		stat := FeedStat{}
-		rows.Scan(&stat.FeedId, &stat.UnreadCount, &stat.StarredCount)
+		if err := rows.Scan(&stat.FeedId, &stat.UnreadCount, &stat.StarredCount); err != nil {
+			return nil, wrapError(err)
+		}
+		stat.Muted = muted[stat.FeedId]
 		result = append(result, stat)
 	}
-	return result
+	return result, wrapError(rows.Err())
 }
 
-func (s *Storage) SyncSearch() {
+func (s *Storage) SyncSearch() error {
 	rows, err := s.db.Query(`
-		select id, title, content
+		select id, ifnull(title, ''), ifnull(author, ''), ifnull(content, '')
 		from items
 		where search_rowid is null;
 	`)
 	if err != nil {
-		log.Print(err)
-		return
+		return wrapError(err)
 	}
 
 	items := make([]Item, 0)
 	for rows.Next() {
 		var item Item
-		rows.Scan(&item.Id, &item.Title, &item.Content)
+		if err := rows.Scan(&item.Id, &item.Title, &item.Author, &item.Content); err != nil {
+			rows.Close()
+			return wrapError(err)
+		}
 		items = append(items, item)
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return wrapError(err)
+	}
 
 	for _, item := range items {
-		result, err := s.db.Exec(`
-			insert into search (title, description, content) values (?, "", ?)`,
-			item.Title, htmlutil.ExtractText(item.Content),
-		)
-		if err != nil {
-			log.Print(err)
-			return
-		}
-		if numrows, err := result.RowsAffected(); err == nil && numrows == 1 {
-			if rowId, err := result.LastInsertId(); err == nil {
-				s.db.Exec(
-					`update items set search_rowid = ? where id = ?`,
-					rowId, item.Id,
-				)
-			}
+		if err := indexItem(s.db, item.Id, item.Title, item.Author, item.Content); err != nil {
+			return wrapError(err)
 		}
 	}
+	return nil
 }
 
 var (
@@ -389,15 +734,23 @@ var (
 	itemsKeepDays = 90
 )
 
+// RetentionCutoff returns the arrival date before which items may be
+// deleted by DeleteOldItems, see its rules.
+func RetentionCutoff(now time.Time) time.Time {
+	return now.UTC().Add(-time.Hour * time.Duration(24*itemsKeepDays))
+}
+
 // Delete old articles from the database to cleanup space.
 //
 // The rules:
-//   - Never delete starred entries.
+//   - Never delete starred or tagged entries.
 //   - Keep at least the same amount of articles the feed provides (default: 50).
 //     This prevents from deleting items for rarely updated and/or ever-growing
 //     feeds which might eventually reappear as unread.
 //   - Keep entries for a certain period (default: 90 days).
-func (s *Storage) DeleteOldItems() {
+//   - The read entries of the feeds with a Retention are kept
+//     according to it instead.
+func (s *Storage) DeleteOldItems() error {
 	rows, err := s.db.Query(`
 		select
 			i.feed_id,
@@ -410,44 +763,70 @@ func (s *Storage) DeleteOldItems() {
 	`, itemsKeepSize, STARRED)
 
 	if err != nil {
-		log.Print(err)
-		return
+		return wrapError(err)
 	}
 
 	feedLimits := make(map[int64]int64, 0)
 	for rows.Next() {
-		var feedId, limit int64
-		rows.Scan(&feedId, &limit, nil)
+		var feedId, limit, count int64
+		if err := rows.Scan(&feedId, &limit, &count); err != nil {
+			rows.Close()
+			return wrapError(err)
+		}
 		feedLimits[feedId] = limit
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return wrapError(err)
+	}
 
+	retentions, err := s.feedRetentions()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
 	for feedId, limit := range feedLimits {
+		// the read items of the feeds with a retention are left to it
+		retention, hasRetention := retentions[feedId]
+		spared := STARRED
+		if hasRetention {
+			spared = READ
+		}
 		result, err := s.db.Exec(`
 			delete from items
 			where id in (
 				select i.id
 				from items i
-				where i.feed_id = ? and status != ?
+				where i.feed_id = ? and status != ? and status != ?
 				order by date desc
 				limit -1 offset ?
-			) and date_arrived < ?
+			) and date_arrived < ? and snoozed_until is null
+			and id not in (select item_id from item_tags)
+			and id not in (select item_id from item_notes)
 			`,
 			feedId,
 			STARRED,
+			spared,
 			limit,
-			time.Now().UTC().Add(-time.Hour*time.Duration(24*itemsKeepDays)),
+			RetentionCutoff(now),
 		)
 		if err != nil {
-			log.Print(err)
-			return
+			return wrapError(err)
 		}
 		numDeleted, err := result.RowsAffected()
 		if err != nil {
-			log.Print(err)
-			return
+			return err
+		}
+		if hasRetention {
+			numPurged, err := s.purgeReadItems(feedId, retention, now)
+			if err != nil {
+				return err
+			}
+			numDeleted += numPurged
 		}
 		if numDeleted > 0 {
 			log.Printf("Deleted %d old items (feed: %d)", numDeleted, feedId)
 		}
 	}
+	return nil
 }
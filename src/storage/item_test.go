@@ -4,6 +4,7 @@ import (
 	"log"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -34,13 +35,13 @@ type testItemScope struct {
 }
 
 func testItemsSetup(db *Storage) testItemScope {
-	folder1 := db.CreateFolder("folder1")
-	folder2 := db.CreateFolder("folder2")
+	folder1, _ := db.CreateFolder("folder1")
+	folder2, _ := db.CreateFolder("folder2")
 
-	feed11 := db.CreateFeed("feed11", "", "", "http://test.com/feed11.xml", &folder1.Id)
-	feed12 := db.CreateFeed("feed12", "", "", "http://test.com/feed12.xml", &folder1.Id)
-	feed21 := db.CreateFeed("feed21", "", "", "http://test.com/feed21.xml", &folder2.Id)
-	feed01 := db.CreateFeed("feed01", "", "", "http://test.com/feed01.xml", nil)
+	feed11, _ := db.CreateFeed("feed11", "", "", "http://test.com/feed11.xml", "", &folder1.Id)
+	feed12, _ := db.CreateFeed("feed12", "", "", "http://test.com/feed12.xml", "", &folder1.Id)
+	feed21, _ := db.CreateFeed("feed21", "", "", "http://test.com/feed21.xml", "", &folder2.Id)
+	feed01, _ := db.CreateFeed("feed01", "", "", "http://test.com/feed01.xml", "", nil)
 
 	now := time.Now()
 	db.CreateItems([]Item{
@@ -77,12 +78,12 @@ func getItem(db *Storage, guid string) *Item {
 	err := db.db.QueryRow(`
 		select
 			i.id, i.guid, i.feed_id, i.title, i.link, i.content,
-			i.date, i.status, i.image, i.podcast_url
+			i.date, i.status, i.image
 		from items i
 		where i.guid = ?
 	`, guid).Scan(
 		&i.Id, &i.GUID, &i.FeedId, &i.Title, &i.Link, &i.Content,
-		&i.Date, &i.Status, &i.ImageURL, &i.AudioURL,
+		&i.Date, &i.Status, &i.ImageURL,
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -90,7 +91,10 @@ func getItem(db *Storage, guid string) *Item {
 	return i
 }
 
-func getItemGuids(items []Item) []string {
+func getItemGuids(items []Item, err error) []string {
+	if err != nil {
+		panic(err)
+	}
 	guids := make([]string, 0)
 	for _, item := range items {
 		guids = append(guids, item.GUID)
@@ -278,7 +282,7 @@ func TestDeleteOldItems(t *testing.T) {
 
 	now := time.Now().UTC()
 	db := testDB()
-	feed := db.CreateFeed("feed", "", "", "http://test.com/feed11.xml", nil)
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/feed11.xml", "", nil)
 
 	items := make([]Item, 0)
 	for i := 0; i < itemsKeepSize+extraItems; i++ {
@@ -319,7 +323,7 @@ func TestDeleteOldItems(t *testing.T) {
 	}
 
 	db.DeleteOldItems()
-	feedItems := db.ListItems(ItemFilter{FeedID: &feed.Id}, 1000, false, false)
+	feedItems, _ := db.ListItems(ItemFilter{FeedID: &feed.Id}, 1000, false, false)
 	if len(feedItems) != len(items)-3 {
 		t.Fatalf(
 			"invalid number of old items kept\nwant: %d\nhave: %d",
@@ -328,3 +332,119 @@ func TestDeleteOldItems(t *testing.T) {
 		)
 	}
 }
+
+func TestCreateItemsTruncatesContent(t *testing.T) {
+	defer func(size int) { MaxItemContentSize = size }(MaxItemContentSize)
+	MaxItemContentSize = 100
+
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/feed.xml", "", nil)
+	content := `<p>text</p><img src="data:image/png;base64,` + strings.Repeat("A", 200) + `">`
+	db.CreateItems([]Item{
+		{GUID: "small", FeedId: feed.Id, Content: "<p>text</p>"},
+		{GUID: "large", FeedId: feed.Id, Content: content},
+	})
+
+	small, _ := db.GetItem(getItem(db, "small").Id)
+	if small.Content != "<p>text</p>" || small.OriginalSize != nil {
+		t.Errorf("small content should be kept intact: %#v", small)
+	}
+	large, _ := db.GetItem(getItem(db, "large").Id)
+	if len(large.Content) > MaxItemContentSize {
+		t.Errorf("content not truncated: %d bytes", len(large.Content))
+	}
+	if large.Content != "<p>text</p>"+truncationMarker {
+		t.Errorf("unexpected truncated content: %q", large.Content)
+	}
+	if large.OriginalSize == nil || *large.OriginalSize != len(content) {
+		t.Errorf("original size not preserved: %v", large.OriginalSize)
+	}
+}
+
+func TestCreateItemsUpdated(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	published := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	updated := published.Add(24 * time.Hour)
+
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "one", Content: "old", Date: published},
+		{GUID: "2", FeedId: feed.Id, Title: "two", Content: "two", Date: published.Add(time.Hour)},
+	})
+	// same timestamp or none: ignored
+	db.CreateItems([]Item{{GUID: "2", FeedId: feed.Id, Title: "two", Content: "ignored", Date: published}})
+	db.CreateItems([]Item{{GUID: "1", FeedId: feed.Id, Title: "one", Content: "new", Date: published, DateUpdated: &updated}})
+	db.CreateItems([]Item{{GUID: "1", FeedId: feed.Id, Title: "one", Content: "ignored", Date: published, DateUpdated: &updated}})
+
+	if item := getItem(db, "1"); item.Content != "new" {
+		t.Fatalf("expected updated content, got %q", item.Content)
+	}
+	if item := getItem(db, "2"); item.Content != "two" {
+		t.Fatalf("expected content to be kept, got %q", item.Content)
+	}
+
+	filter := ItemFilter{FeedID: &feed.Id}
+	if have := getItemGuids(db.ListItems(filter, 10, true, false)); !reflect.DeepEqual(have, []string{"2", "1"}) {
+		t.Fatalf("invalid order by published date: %v", have)
+	}
+	filter.SortBy = SortUpdated
+	items, _ := db.ListItems(filter, 10, true, false)
+	if have := getItemGuids(items, nil); !reflect.DeepEqual(have, []string{"1", "2"}) {
+		t.Fatalf("invalid order by updated date: %v", have)
+	}
+	filter.After = &items[0].Id
+	if have := getItemGuids(db.ListItems(filter, 10, true, false)); !reflect.DeepEqual(have, []string{"2"}) {
+		t.Fatalf("invalid page after %d: %v", items[0].Id, have)
+	}
+}
+
+func TestListItemsSortArrived(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+
+	db.CreateItems([]Item{{GUID: "new", FeedId: feed.Id, Title: "new", Date: now}})
+	db.db.Exec(`update items set date_arrived = ?`, now.Add(-time.Hour).UTC())
+	// backfilled by the publisher after "new" was fetched
+	db.CreateItems([]Item{{GUID: "backfilled", FeedId: feed.Id, Title: "backfilled", Date: now.Add(-30 * 24 * time.Hour)}})
+
+	filter := ItemFilter{FeedID: &feed.Id, SortBy: SortArrived}
+	if have := getItemGuids(db.ListItems(filter, 10, true, false)); !reflect.DeepEqual(have, []string{"backfilled", "new"}) {
+		t.Fatalf("invalid order by arrival: %v", have)
+	}
+}
+
+func TestFeedStatsCounters(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	counts := func() map[int64][2]int64 {
+		result := make(map[int64][2]int64)
+		stats, _ := db.FeedStats()
+		for _, stat := range stats {
+			result[stat.FeedId] = [2]int64{stat.UnreadCount, stat.StarredCount}
+		}
+		return result
+	}
+	check := func(step string) {
+		t.Helper()
+		have := counts()
+		db.RebuildFeedCounts()
+		if want := counts(); !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: want %v, have %v", step, want, have)
+		}
+	}
+
+	check("setup")
+	if have := counts()[scope.feed11.Id]; have != [2]int64{1, 1} {
+		t.Errorf("want 1 unread and 1 starred, have %v", have)
+	}
+	db.UpdateItemStatus(getItem(db, "item111").Id, STARRED)
+	check("status update")
+	db.MarkItemsRead(MarkFilter{FolderID: &scope.folder1.Id})
+	check("mark read")
+	db.CreateItems([]Item{{GUID: "new", FeedId: scope.feed12.Id, Title: "new"}})
+	check("insert")
+	db.db.Exec(`delete from items where guid = ?`, "item121")
+	check("delete")
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItemLanguage(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	if err := db.SetFeedLanguage(feed.Id, "ar"); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := db.GetFeed(feed.Id); have.Language != "ar" {
+		t.Errorf("want the feed in ar, have %q", have.Language)
+	}
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "1", Date: time.Now()},
+		{GUID: "2", FeedId: feed.Id, Title: "2", Date: time.Now(), Language: "en-GB"},
+	})
+
+	if item, _ := db.GetItem(getItem(db, "1").Id); item.Language != "ar" {
+		t.Errorf("want the item in the feed's language, have %q", item.Language)
+	}
+	for language, want := range map[string]string{"ar": "1", "en": "2", "EN-gb": "2"} {
+		items, _ := db.ListItems(ItemFilter{Language: &language}, 10, true, false)
+		if len(items) != 1 || items[0].GUID != want {
+			t.Errorf("%s: want item %s, have %#v", language, want, items)
+		}
+	}
+	for _, language := range []string{"e", "en-US", "%"} {
+		if items, _ := db.ListItems(ItemFilter{Language: &language}, 10, true, false); len(items) != 0 {
+			t.Errorf("%s: want no items, have %#v", language, items)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/nkanaev/yarr/src/tracing"
+)
+
+// QueryStats is the number of queries run and the time spent running them.
+// Statements executed inside a transaction are accounted to its Begin call
+// and are not timed individually.
+type QueryStats struct {
+	Queries  int64         `json:"queries"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// QueryTracker accumulates the queries run within the contexts it's
+// attached to, see TrackQueries.
+type QueryTracker struct {
+	queries int64
+	nanos   int64
+}
+
+func (t *QueryTracker) add(d time.Duration) {
+	atomic.AddInt64(&t.queries, 1)
+	atomic.AddInt64(&t.nanos, int64(d))
+}
+
+func (t *QueryTracker) Stats() QueryStats {
+	return QueryStats{
+		Queries:  atomic.LoadInt64(&t.queries),
+		Duration: time.Duration(atomic.LoadInt64(&t.nanos)),
+	}
+}
+
+type trackerKey struct{}
+
+// TrackQueries returns a copy of ctx accounting the queries of the
+// storages running within it (see Storage.WithContext) to t, and only
+// those: the queries of other requests or of the worker aren't.
+func TrackQueries(ctx context.Context, t *QueryTracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// instrumentedDB records every query into the totals, and into the
+// tracker and the span of ctx. Queries run within ctx, see
+// Storage.WithContext.
+type instrumentedDB struct {
+	*sql.DB
+
+	total *QueryTracker
+	ctx   context.Context
+}
+
+func newInstrumentedDB(db *sql.DB) *instrumentedDB {
+	return &instrumentedDB{
+		DB:    db,
+		total: &QueryTracker{},
+		ctx:   context.Background(),
+	}
+}
+
+func (db *instrumentedDB) record(query string, start time.Time) {
+	d := time.Since(start)
+	db.total.add(d)
+	if t, ok := db.ctx.Value(trackerKey{}).(*QueryTracker); ok {
+		t.add(d)
+	}
+	if span := tracing.FromContext(db.ctx); span != nil {
+		span.AddChild("db.query", start, d, map[string]interface{}{
+			"db.system":    "sqlite",
+			"db.statement": query,
+		})
+	}
+}
+
+func (db *instrumentedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer db.record(query, time.Now())
+	return db.DB.QueryContext(db.ctx, query, args...)
+}
+
+func (db *instrumentedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer db.record(query, time.Now())
+	return db.DB.QueryRowContext(db.ctx, query, args...)
+}
+
+func (db *instrumentedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer db.record(query, time.Now())
+	return db.DB.ExecContext(db.ctx, query, args...)
+}
+
+// Begin starts a transaction, rolled back if ctx is done before it's committed.
+func (db *instrumentedDB) Begin() (*sql.Tx, error) {
+	defer db.record("begin", time.Now())
+	return db.DB.BeginTx(db.ctx, nil)
+}
+
+// QueryStats returns the totals since the storage was opened.
+func (s *Storage) QueryStats() QueryStats {
+	return s.db.total.Stats()
+}
@@ -2,8 +2,12 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"runtime"
+	"strings"
 	"time"
 )
 
@@ -17,41 +21,132 @@ var migrations = []func(*sql.Tx) error{
 	m07_add_feed_size,
 	m08_normalize_datetime,
 	m09_custom_order,
+	m10_item_original_size,
+	m11_feed_iframe_hosts,
+	m12_enclosure_downloads,
+	m13_playback,
+	m14_item_podcast,
+	m15_feed_refresh_options,
+	m16_folder_custom_order,
+	m17_feed_size_history,
+	m18_feed_user_modified,
+	m19_foreign_key_constraints,
+	m20_feed_accept_language,
+	m21_item_guid_strategy,
+	m22_item_updates,
+	m23_item_state_imports,
+	m24_item_snapshots,
+	m25_feed_bandwidth,
+	m26_icon_states,
+	m27_feed_suggestions,
+	m28_item_snooze,
+	m29_feed_delivery_times,
+	m30_folder_mute_schedule,
+	m31_notifications,
+	m32_search_authors,
+	m33_folder_parent,
+	m34_icon_source,
+	m35_feed_retention,
+	m36_item_tags,
+	m37_saved_searches,
+	m38_feed_error_history,
+	m39_feed_trials,
+	m40_feed_daily_limit,
+	m41_feed_counts,
+	m42_feed_blocked_hosts,
+	m43_feed_deleted_at,
+	m44_item_location,
+	m45_activitypub,
+	m46_feed_bridges,
+	m47_item_notes,
+	m48_item_dedup_hash,
+	m49_starred_archive,
+	m50_item_incidents,
+	m51_feed_credentials,
+	m52_feed_watches,
+	m53_backlog_snapshots,
+	m54_feed_rules,
+	m55_filters,
+	m56_feed_self_link,
+	m57_item_read_at,
+	m58_language,
+	m59_item_enclosures,
+	m60_search_fts5,
+	m61_item_audio_enclosure,
+	m62_item_notify_on_wake,
 }
 
 var maxVersion = int64(len(migrations))
 
+// ErrSchemaTooNew is returned when the database has been migrated by a
+// newer release of yarr: its schema is unknown to this one.
+var ErrSchemaTooNew = errors.New("the database was upgraded by a newer version of yarr")
+
+// MigrationError is returned when the database can't be brought to the
+// current version. It's left at the last version migrated successfully,
+// each migration being applied in a transaction of its own.
+type MigrationError struct {
+	Version int64
+	Name    string
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("failed to migrate the database to version %d (%s): %s", e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// migrationName returns the name of the function migrating to the version.
+func migrationName(v int64) string {
+	name := runtime.FuncForPC(reflect.ValueOf(migrations[v-1]).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// migrate brings the database to the latest version (kept in the user_version
+// pragma) by applying the missing migrations in order, and records them in
+// the schema_migrations table. The database is checked for corruption
+// before being migrated: a damaged one is better left untouched.
 func migrate(db *sql.DB) error {
 	var version int64
-	db.QueryRow("pragma user_version").Scan(&version)
+	if err := db.QueryRow("pragma user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read the database version: %w", err)
+	}
+	if version > maxVersion {
+		return fmt.Errorf("%w (version %d, this one knows up to %d)", ErrSchemaTooNew, version, maxVersion)
+	}
+	if err := initMigrationHistory(db, version); err != nil {
+		return err
+	}
 
 	if version >= maxVersion {
 		return nil
 	}
+	if version > 0 {
+		if err := quickCheck(db); err != nil {
+			return err
+		}
+	}
 
 	log.Printf("db version is %d. migrating to %d", version, maxVersion)
 
 	for v := version + 1; v <= maxVersion; v++ {
-		// Migrations altering schema using a sequence of steps due to SQLite limitations.
-		// Must come with `pragma foreign_key_check` at the end. See:
-		// "Making Other Kinds Of Table Schema Changes"
-		// https://www.sqlite.org/lang_altertable.html
-		trickyAlteration := (v == 3)
-
 		log.Printf("[migration:%d] starting", v)
 
-		if trickyAlteration {
+		if trickyAlteration(v) {
 			db.Exec("pragma foreign_keys=off;")
 		}
 
 		err := migrateVersion(v, db)
 
-		if trickyAlteration {
+		if trickyAlteration(v) {
 			db.Exec("pragma foreign_keys=on;")
 		}
 
 		if err != nil {
-			return err
+			return &MigrationError{Version: v, Name: migrationName(v), Err: err}
 		}
 
 		log.Printf("[migration:%d] done", v)
@@ -59,31 +154,106 @@ func migrate(db *sql.DB) error {
 	return nil
 }
 
+// trickyAlteration tells whether the migration alters the schema using a
+// sequence of steps due to SQLite limitations, with the foreign keys off.
+// Must come with `pragma foreign_key_check` at the end. See "Making Other
+// Kinds Of Table Schema Changes" https://www.sqlite.org/lang_altertable.html
+func trickyAlteration(v int64) bool {
+	return v == 3 || v == 19
+}
+
 func migrateVersion(v int64, db *sql.DB) error {
-	var err error
-	var tx *sql.Tx
-	migratefunc := migrations[v-1]
-	if tx, err = db.Begin(); err != nil {
-		log.Printf("[migration:%d] failed to start transaction", v)
-		return err
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start the transaction: %w", err)
 	}
+	migratefunc := migrations[v-1]
 	if err = migratefunc(tx); err != nil {
-		log.Printf("[migration:%d] failed to migrate", v)
 		tx.Rollback()
 		return err
 	}
+	_, err = tx.Exec(
+		`insert or replace into schema_migrations (version, name, applied_at) values (?, ?, ?)`,
+		v, migrationName(v), time.Now().UTC(),
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record the migration: %w", err)
+	}
 	if _, err = tx.Exec(fmt.Sprintf("pragma user_version = %d", v)); err != nil {
-		log.Printf("[migration:%d] failed to bump version", v)
 		tx.Rollback()
-		return err
+		return fmt.Errorf("failed to bump the version: %w", err)
 	}
 	if err = tx.Commit(); err != nil {
-		log.Printf("[migration:%d] failed to commit changes", v)
-		return err
+		return fmt.Errorf("failed to commit the changes: %w", err)
 	}
 	return nil
 }
 
+// initMigrationHistory sets up the schema_migrations table, filling in the
+// migrations applied before it was kept (without a date), and checks that
+// it agrees with the version of the database.
+func initMigrationHistory(db *sql.DB, version int64) error {
+	_, err := db.Exec(`
+		create table if not exists schema_migrations (
+		 version    integer primary key,
+		 name       text not null,
+		 applied_at datetime
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create the migration history: %w", err)
+	}
+	var recorded, latest int64
+	err = db.QueryRow(`select count(*), ifnull(max(version), 0) from schema_migrations`).Scan(&recorded, &latest)
+	if err != nil {
+		return fmt.Errorf("failed to read the migration history: %w", err)
+	}
+	if latest > version {
+		return fmt.Errorf("the migration history (up to version %d) is ahead of the database version %d", latest, version)
+	}
+	if recorded == version {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for v := int64(1); v <= version; v++ {
+		_, err := tx.Exec(`insert or ignore into schema_migrations (version, name) values (?, ?)`, v, migrationName(v))
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to fill in the migration history: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// quickCheck runs SQLite's quick check of the database file before it's
+// migrated. Unlike CheckIntegrity it leaves out the foreign keys (the
+// migrations repair them) and the slower checks of the indexes.
+func quickCheck(db *sql.DB) error {
+	rows, err := db.Query("pragma quick_check")
+	if err != nil {
+		return fmt.Errorf("failed to check the database: %w", err)
+	}
+	defer rows.Close()
+	problems := make([]string, 0)
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return fmt.Errorf("failed to check the database: %w", err)
+		}
+		if problem != "ok" && len(problems) < 5 {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the database is damaged, restore a backup before upgrading: %s", strings.Join(problems, "; "))
+	}
+	return rows.Err()
+}
+
 func m01_initial(tx *sql.Tx) error {
 	sql := `
 		create table if not exists folders (
@@ -303,3 +473,892 @@ func m09_custom_order(tx *sql.Tx) error {
 	_, err := tx.Exec(sql)
 	return err
 }
+
+func m10_item_original_size(tx *sql.Tx) error {
+	sql := `
+		alter table items add column original_size integer
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m11_feed_iframe_hosts(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column iframe_hosts text not null default ''
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m12_enclosure_downloads(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column download_enclosures boolean not null default false;
+
+		create table if not exists downloads (
+		 item_id        references items(id) on delete cascade unique,
+		 url            text not null,
+		 status         text not null,
+		 path           text not null default '',
+		 size           integer not null default 0,
+		 attempts       integer not null default 0,
+		 error          text not null default '',
+		 updated_at     datetime not null
+		);
+
+		create index if not exists idx_download_status on downloads(status);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m13_playback(tx *sql.Tx) error {
+	sql := `
+		create table if not exists playback (
+		 item_id        references items(id) on delete cascade unique,
+		 position       real not null default 0,
+		 duration       real not null default 0,
+		 completed      boolean not null default false,
+		 updated_at     datetime not null
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m14_item_podcast(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_podcast (
+		 item_id        references items(id) on delete cascade unique,
+		 chapters_url   text not null default '',
+		 chapters_type  text not null default '',
+		 chapters       text,
+		 transcripts    text,
+		 persons        text
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m15_feed_refresh_options(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column paused boolean not null default false;
+		alter table feeds add column refresh_interval integer not null default 0;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m16_folder_custom_order(tx *sql.Tx) error {
+	sql := `
+		alter table folders add column custom_order text not null default "xxxxxxxxx"
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m17_feed_size_history(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_size_history (
+		 feed_id        references feeds(id) on delete cascade,
+		 day            text not null,
+		 size           integer not null default 0,
+		 items          integer not null default 0,
+		 unique (feed_id, day)
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m18_feed_user_modified(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column title_modified boolean not null default false;
+		alter table feeds add column folder_modified boolean not null default false;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+// orphanRepairs remove rows whose parent no longer exists.
+// Children come before parents, so that a repair never creates new orphans.
+var orphanRepairs = []string{
+	`update feeds set folder_id = null where folder_id is not null and folder_id not in (select id from folders)`,
+	`delete from items where feed_id is null or feed_id not in (select id from feeds)`,
+	`delete from http_states where feed_id is null or feed_id not in (select id from feeds)`,
+	`delete from feed_errors where feed_id is null or feed_id not in (select id from feeds)`,
+	`delete from feed_sizes where feed_id is null or feed_id not in (select id from feeds)`,
+	`delete from feed_size_history where feed_id is null or feed_id not in (select id from feeds)`,
+	`delete from downloads where item_id is null or item_id not in (select id from items)`,
+	`delete from playback where item_id is null or item_id not in (select id from items)`,
+	`delete from item_podcast where item_id is null or item_id not in (select id from items)`,
+}
+
+func m19_foreign_key_constraints(tx *sql.Tx) error {
+	for _, query := range orphanRepairs {
+		result, err := tx.Exec(query)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			log.Printf("[migration:19] repaired %d orphaned rows: %s", n, query)
+		}
+	}
+
+	sql := `
+		-- 01. create constrained tables
+		create table new_items (
+		 id             integer primary key autoincrement,
+		 guid           string not null,
+		 feed_id        integer not null references feeds(id) on delete cascade,
+		 title          text,
+		 link           text,
+		 description    text,
+		 content        text,
+		 author         text,
+		 date           datetime,
+		 date_updated   datetime,
+		 date_arrived   datetime,
+		 status         integer,
+		 image          text,
+		 search_rowid   integer,
+		 podcast_url    text,
+		 original_size  integer
+		);
+		create table new_feed_errors (
+		 feed_id        integer not null references feeds(id) on delete cascade unique,
+		 error          string
+		);
+		create table new_feed_sizes (
+		 feed_id        integer not null references feeds(id) on delete cascade unique,
+		 size           integer not null default 0
+		);
+
+		-- 02. transfer data into new tables
+		insert into new_items
+			(id, guid, feed_id, title, link, description, content, author, date,
+			 date_updated, date_arrived, status, image, search_rowid, podcast_url, original_size)
+		select
+			id, guid, feed_id, title, link, description, content, author, date,
+			date_updated, date_arrived, status, image, search_rowid, podcast_url, original_size
+		from items;
+		insert into new_feed_errors (feed_id, error) select feed_id, error from feed_errors;
+		insert into new_feed_sizes (feed_id, size) select feed_id, size from feed_sizes;
+
+		-- 03. drop old tables
+		drop table items;
+		drop table feed_errors;
+		drop table feed_sizes;
+
+		-- 04. rename new tables
+		alter table new_items rename to items;
+		alter table new_feed_errors rename to feed_errors;
+		alter table new_feed_sizes rename to feed_sizes;
+
+		-- 05. reconstruct indexes & triggers
+		create index if not exists idx_item_feed_id on items(feed_id);
+		create index if not exists idx_item_status  on items(status);
+		create index if not exists idx_item_search_rowid on items(search_rowid);
+		create unique index if not exists idx_item_guid on items(feed_id, guid);
+		create trigger if not exists del_item_search after delete on items begin
+		  delete from search where rowid = old.search_rowid;
+		end;
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return err
+	}
+
+	// 06. check consistency
+	var violations int
+	if err := tx.QueryRow(`select count(*) from pragma_foreign_key_check`).Scan(&violations); err != nil {
+		return err
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d foreign key violations after migration", violations)
+	}
+	return nil
+}
+
+func m20_feed_accept_language(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column accept_language text not null default ''
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m21_item_guid_strategy(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column guid_strategy text not null default ''
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return err
+	}
+	// items stored without a guid get one from their link or content
+	return rekeyItems(tx, nil, GUIDAuto)
+}
+
+func m22_item_updates(tx *sql.Tx) error {
+	sql := `
+		-- atom entries with a link as id used to include the updated date
+		-- in their guid, making every edit a new item
+		update or ignore items
+		set guid = substr(guid, 1, instr(guid, '::') - 1)
+		where guid like 'http%::%';
+
+		-- edited items get re-indexed by CreateItems
+		create trigger if not exists upd_item_search after update of title, content on items begin
+		  delete from search where rowid = old.search_rowid;
+		  update items set search_rowid = null where id = new.id;
+		end;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m23_item_state_imports(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_state_imports (
+		 feed_link text not null,
+		 guid      text not null,
+		 status    integer not null,
+		 primary key (feed_link, guid)
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m24_item_snapshots(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_snapshots (
+		 item_id    references items(id) on delete cascade unique,
+		 content    text not null,
+		 size       integer not null,
+		 created_at datetime not null
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m25_feed_bandwidth(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_bandwidth (
+		 feed_id        references feeds(id) on delete cascade,
+		 day            text not null,
+		 bytes          integer not null default 0,
+		 requests       integer not null default 0,
+		 unique (feed_id, day)
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m26_icon_states(tx *sql.Tx) error {
+	sql := `
+		create table if not exists icon_states (
+		 feed_id        references feeds(id) on delete cascade unique,
+		 attempts       integer not null default 0,
+		 next_check     datetime not null,
+		 error          text not null default ''
+		);
+
+		-- feeds looked up by previous versions get rechecked in a month
+		insert into icon_states (feed_id, next_check)
+		select id, datetime('now', '+30 days') from feeds where icon is not null;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m27_feed_suggestions(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_suggestions (
+		 feed_id        references feeds(id) on delete cascade unique,
+		 kind           text not null,
+		 detail         text not null default '',
+		 target_feed_id references feeds(id) on delete cascade,
+		 replacement    text not null default '',
+		 created_at     datetime not null
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m28_item_snooze(tx *sql.Tx) error {
+	sql := `
+		alter table items add column snoozed_until datetime;
+		create index if not exists idx_item_snoozed_until on items(snoozed_until);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m29_feed_delivery_times(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column delivery_times text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m30_folder_mute_schedule(tx *sql.Tx) error {
+	sql := `
+		alter table folders add column mute_schedule text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m31_notifications(tx *sql.Tx) error {
+	sql := `
+		create table if not exists notifiers (
+		 id             integer primary key autoincrement,
+		 name           text not null unique,
+		 kind           text not null,
+		 config         text not null default '{}'
+		);
+
+		create table if not exists notification_routes (
+		 id             integer primary key autoincrement,
+		 notifier_id    references notifiers(id) on delete cascade not null,
+		 feed_id        references feeds(id) on delete cascade,
+		 folder_id      references folders(id) on delete cascade,
+		 match          text not null default '',
+		 batch_minutes  integer not null default 0,
+		 max_per_hour   integer not null default 0
+		);
+
+		create table if not exists notification_queue (
+		 route_id       references notification_routes(id) on delete cascade not null,
+		 item_id        references items(id) on delete cascade not null,
+		 created_at     datetime not null,
+		 unique(route_id, item_id)
+		);
+
+		create table if not exists notification_sends (
+		 route_id       references notification_routes(id) on delete cascade not null,
+		 sent_at        datetime not null
+		);
+		create index if not exists idx_notification_sends on notification_sends(route_id, sent_at);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m32_search_authors(tx *sql.Tx) error {
+	sql := `
+		-- the description column has been empty since m05
+		drop table if exists search;
+		create virtual table search using fts4(title, author, content);
+		-- re-indexed by SyncSearch on start
+		update items set search_rowid = null;
+
+		drop trigger if exists upd_item_search;
+		create trigger upd_item_search after update of title, author, content on items begin
+		  delete from search where rowid = old.search_rowid;
+		  update items set search_rowid = null where id = new.id;
+		end;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m33_folder_parent(tx *sql.Tx) error {
+	sql := `
+		alter table folders add column parent_id references folders(id) on delete set null;
+		create index if not exists idx_folder_parent_id on folders(parent_id);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m34_icon_source(tx *sql.Tx) error {
+	sql := `
+		alter table icon_states add column url text not null default '';
+		alter table icon_states add column etag text not null default '';
+		alter table icon_states add column last_modified text not null default '';
+		alter table icon_states add column changed_at datetime;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m35_feed_retention(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column retention text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m36_item_tags(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_tags (
+		 item_id    integer not null references items(id) on delete cascade,
+		 tag        text not null,
+		 primary key (item_id, tag)
+		);
+		create index if not exists idx_item_tags_tag on item_tags(tag);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m37_saved_searches(tx *sql.Tx) error {
+	sql := `
+		create table if not exists saved_searches (
+		 id         integer primary key autoincrement,
+		 title      text not null,
+		 query      text not null default '',
+		 search_in  text not null default '',
+		 feed_id    references feeds(id) on delete cascade,
+		 folder_id  references folders(id) on delete cascade,
+		 tag        text,
+		 status     integer
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m38_feed_error_history(tx *sql.Tx) error {
+	sql := `
+		alter table feed_errors add column since datetime;
+		create table if not exists feed_error_history (
+		 id         integer primary key autoincrement,
+		 feed_id    integer not null references feeds(id) on delete cascade,
+		 error      text not null,
+		 date       datetime not null
+		);
+		create index if not exists idx_feed_error_history_feed_id on feed_error_history(feed_id);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m39_feed_trials(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column trial_until datetime;
+		alter table feeds add column read_count integer not null default 0;
+		alter table feeds add column last_read datetime;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m40_feed_daily_limit(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column daily_limit integer not null default 0;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+// m41_feed_counts keeps the number of unread and starred items per feed
+// up to date on every change, instead of counting them on each FeedStats.
+func m41_feed_counts(tx *sql.Tx) error {
+	sql := fmt.Sprintf(`
+		create table if not exists feed_counts (
+		 feed_id    integer primary key references feeds(id) on delete cascade,
+		 unread     integer not null default 0,
+		 starred    integer not null default 0
+		);
+
+		insert into feed_counts (feed_id, unread, starred)
+		select
+			feed_id,
+			sum(case status when %[1]d then 1 else 0 end),
+			sum(case status when %[2]d then 1 else 0 end)
+		from items
+		group by feed_id;
+
+		create trigger if not exists ins_item_counts after insert on items begin
+			insert into feed_counts (feed_id, unread, starred)
+			values (new.feed_id, new.status = %[1]d, new.status = %[2]d)
+			on conflict (feed_id) do update set
+				unread = unread + excluded.unread,
+				starred = starred + excluded.starred;
+		end;
+
+		create trigger if not exists del_item_counts after delete on items begin
+			update feed_counts set
+				unread = unread - (old.status = %[1]d),
+				starred = starred - (old.status = %[2]d)
+			where feed_id = old.feed_id;
+		end;
+
+		create trigger if not exists upd_item_counts after update of status, feed_id on items
+		when old.status != new.status or old.feed_id != new.feed_id
+		begin
+			update feed_counts set
+				unread = unread - (old.status = %[1]d),
+				starred = starred - (old.status = %[2]d)
+			where feed_id = old.feed_id;
+			insert into feed_counts (feed_id, unread, starred)
+			values (new.feed_id, new.status = %[1]d, new.status = %[2]d)
+			on conflict (feed_id) do update set
+				unread = unread + excluded.unread,
+				starred = starred + excluded.starred;
+		end;
+	`, UNREAD, STARRED)
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m42_feed_blocked_hosts(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column blocked_hosts text not null default '';
+		alter table feeds add column allowed_hosts text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m43_feed_deleted_at(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column deleted_at datetime;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m44_item_location(tx *sql.Tx) error {
+	sql := `
+		alter table items add column latitude real;
+		alter table items add column longitude real;
+		create index if not exists idx_item_latitude on items(latitude) where latitude is not null;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m45_activitypub(tx *sql.Tx) error {
+	sql := `
+		create table if not exists activitypub_keys (
+		 id          integer primary key check (id = 1),
+		 private_key text not null
+		);
+
+		create table if not exists activitypub_follows (
+		 feed_id   integer primary key references feeds(id) on delete cascade,
+		 actor_id  text not null unique,
+		 inbox     text not null,
+		 follow_id text not null,
+		 accepted  boolean not null default false
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m46_feed_bridges(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_bridges (
+		 feed_id integer primary key references feeds(id) on delete cascade,
+		 bridge  text not null,
+		 context text not null default '',
+		 params  text not null default '{}'
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m47_item_notes(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_notes (
+		 item_id integer primary key references items(id) on delete cascade,
+		 note    text not null,
+		 updated datetime not null
+		);
+
+		-- the notes are searchable along with the items
+		drop table if exists search;
+		create virtual table search using fts4(title, author, content, note);
+		-- re-indexed by SyncSearch on start
+		update items set search_rowid = null;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m48_item_dedup_hash(tx *sql.Tx) error {
+	sql := `
+		alter table items add column dedup_hash text;
+		create index if not exists idx_item_dedup_hash on items(dedup_hash);
+	`
+	if _, err := tx.Exec(sql); err != nil {
+		return err
+	}
+	return backfillDedupHashes(tx)
+}
+
+func m49_starred_archive(tx *sql.Tx) error {
+	sql := `
+		create table if not exists archived_items (
+		 id           integer primary key autoincrement,
+		 item_id      integer unique,
+		 guid         text not null,
+		 feed_title   text not null,
+		 feed_link    text not null,
+		 site_link    text not null,
+		 folder_title text,
+		 title        text not null,
+		 link         text not null,
+		 author       text,
+		 date         datetime,
+		 content      text,
+		 image        text,
+		 podcast_url  text,
+		 starred_at   datetime not null
+		);
+
+		create index if not exists idx_archived_item_starred_at on archived_items(starred_at);
+
+		-- the starred items are copied along with their feeds' details,
+		-- the copies outlive the items (see ArchivedItem)
+		create trigger if not exists archive_starred_item after update of status on items
+		when new.status = 2 and old.status != 2 begin
+			insert or replace into archived_items (
+				item_id, guid, feed_title, feed_link, site_link, folder_title,
+				title, link, author, date, content, image, podcast_url, starred_at
+			)
+			select
+				i.id, i.guid, ifnull(f.title, ''), f.feed_link, ifnull(f.link, ''), fo.title,
+				ifnull(i.title, ''), ifnull(i.link, ''), i.author, i.date, i.content, i.image, i.podcast_url,
+				strftime('%Y-%m-%d %H:%M:%f', 'now')
+			from items i
+			join feeds f on f.id = i.feed_id
+			left join folders fo on fo.id = f.folder_id
+			where i.id = new.id;
+		end;
+
+		create trigger if not exists unarchive_unstarred_item after update of status on items
+		when old.status = 2 and new.status != 2 begin
+			delete from archived_items where item_id = new.id;
+		end;
+
+		create trigger if not exists update_archived_item after update of title, link, author, content on items
+		when new.status = 2 begin
+			update archived_items
+			set title = ifnull(new.title, ''), link = ifnull(new.link, ''), author = new.author, content = new.content
+			where item_id = new.id;
+		end;
+
+		create trigger if not exists detach_archived_item after delete on items
+		when old.status = 2 begin
+			update archived_items set item_id = null where item_id = old.id;
+		end;
+
+		-- the items starred so far
+		insert or ignore into archived_items (
+			item_id, guid, feed_title, feed_link, site_link, folder_title,
+			title, link, author, date, content, image, podcast_url, starred_at
+		)
+		select
+			i.id, i.guid, ifnull(f.title, ''), f.feed_link, ifnull(f.link, ''), fo.title,
+			ifnull(i.title, ''), ifnull(i.link, ''), i.author, i.date, i.content, i.image, i.podcast_url,
+			i.date_arrived
+		from items i
+		join feeds f on f.id = i.feed_id
+		left join folders fo on fo.id = f.folder_id
+		where i.status = 2;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m50_item_incidents(tx *sql.Tx) error {
+	sql := `
+		alter table items add column incident_status text;
+		alter table items add column incident_severity integer;
+		alter table notification_routes add column min_severity integer not null default 0;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m51_feed_credentials(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column auth_username text not null default '';
+		alter table feeds add column auth_password text not null default '';
+		alter table feeds add column auth_header text not null default '';
+		alter table feeds add column auth_header_value text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m52_feed_watches(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_watches (
+		 feed_id    integer primary key references feeds(id) on delete cascade,
+		 selector   text not null,
+		 attr       text not null default '',
+		 pattern    text not null default '',
+		 condition  text not null,
+		 threshold  real not null default 0,
+		 value      text,
+		 checked_at datetime
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m53_backlog_snapshots(tx *sql.Tx) error {
+	sql := `
+		-- the unread items per folder at the end of each day (0 for the
+		-- feeds without a folder), see RecordBacklog
+		create table if not exists backlog_snapshots (
+		 day       text not null,
+		 folder_id integer not null,
+		 unread    integer not null,
+		 primary key (day, folder_id)
+		);
+
+		alter table notification_routes add column backlog_threshold integer not null default 0;
+		alter table notification_routes add column backlog_triggered integer not null default 0;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m54_feed_rules(tx *sql.Tx) error {
+	sql := `
+		create table if not exists feed_rules (
+		 id          integer primary key autoincrement,
+		 feed_id     integer not null references feeds(id) on delete cascade,
+		 kind        text not null,
+		 selector    text not null default '',
+		 pattern     text not null default '',
+		 replacement text not null default '',
+		 position    integer not null default 0
+		);
+
+		create index if not exists idx_feed_rules_feed_id on feed_rules(feed_id);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m55_filters(tx *sql.Tx) error {
+	sql := `
+		create table if not exists filters (
+		 id      integer primary key autoincrement,
+		 feed_id integer references feeds(id) on delete cascade,
+		 field   text not null,
+		 pattern text not null,
+		 regex   boolean not null default false,
+		 action  text not null,
+		 tag     text not null default ''
+		);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m56_feed_self_link(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column self_link text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m57_item_read_at(tx *sql.Tx) error {
+	sql := `
+		alter table items add column read_at datetime;
+		create index if not exists idx_item_read_at on items(read_at);
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m58_language(tx *sql.Tx) error {
+	sql := `
+		alter table feeds add column language text not null default '';
+		alter table items add column language text not null default '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m59_item_enclosures(tx *sql.Tx) error {
+	sql := `
+		create table if not exists item_enclosures (
+		 item_id   integer not null references items(id) on delete cascade,
+		 position  integer not null,
+		 url       text not null,
+		 type      text not null default '',
+		 length    integer not null default 0,
+		 primary key (item_id, position)
+		);
+
+		-- the single enclosure kept so far, its type wasn't
+		insert into item_enclosures (item_id, position, url)
+		select id, 0, podcast_url from items where ifnull(podcast_url, '') != '';
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m60_search_fts5(tx *sql.Tx) error {
+	sql := `
+		drop table if exists search;
+		create virtual table search using fts5(title, author, content, note);
+		-- re-indexed by SyncSearch on start
+		update items set search_rowid = null;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m61_item_audio_enclosure(tx *sql.Tx) error {
+	sql := `
+		-- the urls m59 copied were audio files, the player's, the first
+		-- audio enclosure is the one played from now on
+		update item_enclosures set type = 'audio/*'
+		where type = '' and position = 0
+		  and url = (select podcast_url from items where items.id = item_enclosures.item_id);
+
+		-- podcast_url isn't kept up to date anymore
+		update items set podcast_url = null;
+
+		drop trigger if exists archive_starred_item;
+		create trigger archive_starred_item after update of status on items
+		when new.status = 2 and old.status != 2 begin
+			insert or replace into archived_items (
+				item_id, guid, feed_title, feed_link, site_link, folder_title,
+				title, link, author, date, content, image, podcast_url, starred_at
+			)
+			select
+				i.id, i.guid, ifnull(f.title, ''), f.feed_link, ifnull(f.link, ''), fo.title,
+				ifnull(i.title, ''), ifnull(i.link, ''), i.author, i.date, i.content, i.image,
+				(select e.url from item_enclosures e
+				 where e.item_id = i.id and e.type like 'audio/%'
+				 order by e.position limit 1),
+				strftime('%Y-%m-%d %H:%M:%f', 'now')
+			from items i
+			join feeds f on f.id = i.feed_id
+			left join folders fo on fo.id = f.folder_id
+			where i.id = new.id;
+		end;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
+
+func m62_item_notify_on_wake(tx *sql.Tx) error {
+	sql := `
+		-- the items held for a delivery window are queued for the
+		-- notifications once they're delivered, not as they arrive
+		alter table items add column notify_on_wake boolean not null default 0;
+	`
+	_, err := tx.Exec(sql)
+	return err
+}
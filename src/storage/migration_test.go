@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func openMemoryDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a single connection, each one has its own in-memory database
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func quietMigrate(db *sql.DB) error {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	return migrate(db)
+}
+
+func userVersion(t *testing.T, db *sql.DB) int64 {
+	var version int64
+	if err := db.QueryRow("pragma user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrate(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := quietMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if v := userVersion(t, db); v != maxVersion {
+		t.Fatalf("want version %d, have %d", maxVersion, v)
+	}
+	var count, dated int64
+	db.QueryRow(`select count(*), count(applied_at) from schema_migrations`).Scan(&count, &dated)
+	if count != maxVersion || dated != maxVersion {
+		t.Errorf("want %d migrations recorded, have %d (%d dated)", maxVersion, count, dated)
+	}
+	var name string
+	db.QueryRow(`select name from schema_migrations where version = 1`).Scan(&name)
+	if name != "m01_initial" {
+		t.Errorf("want the name of the first migration, have %q", name)
+	}
+
+	// the history is filled in for the databases migrated before it was kept
+	db.Exec(`drop table schema_migrations`)
+	if err := quietMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.QueryRow(`select count(*), count(applied_at) from schema_migrations`).Scan(&count, &dated)
+	if count != maxVersion || dated != 0 {
+		t.Errorf("want %d undated migrations, have %d (%d dated)", maxVersion, count, dated)
+	}
+
+	db.Exec(`insert into schema_migrations (version, name) values (?, 'future')`, maxVersion+1)
+	if err := quietMigrate(db); err == nil {
+		t.Error("want an error for a history ahead of the version")
+	}
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	db := openMemoryDB(t)
+	db.Exec("pragma user_version = 100000")
+	if err := quietMigrate(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("want ErrSchemaTooNew, have %v", err)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := openMemoryDB(t)
+	if err := quietMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	defer func(saved []func(*sql.Tx) error) {
+		migrations = saved
+		maxVersion = int64(len(migrations))
+	}(migrations)
+	migrations = append(migrations[:len(migrations):len(migrations)], func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`create table half_done (id integer)`); err != nil {
+			return err
+		}
+		return failure
+	})
+	maxVersion = int64(len(migrations))
+
+	err := quietMigrate(db)
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Version != maxVersion || !errors.Is(err, failure) {
+		t.Fatalf("want a MigrationError of version %d, have %v", maxVersion, err)
+	}
+	if v := userVersion(t, db); v != maxVersion-1 {
+		t.Errorf("want the database left at version %d, have %d", maxVersion-1, v)
+	}
+	var tables int
+	db.QueryRow(`select count(*) from sqlite_master where name = 'half_done'`).Scan(&tables)
+	if tables != 0 {
+		t.Error("want the failed migration rolled back")
+	}
+}
+
+func TestMigrateNullItemColumns(t *testing.T) {
+	db := openMemoryDB(t)
+	all := migrations
+	defer func() {
+		migrations = all
+		maxVersion = int64(len(migrations))
+	}()
+
+	// the items stored before the guid strategies, without a guid
+	migrations = all[:20]
+	maxVersion = int64(len(migrations))
+	if err := quietMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into feeds (title, feed_link) values ('feed', 'http://example.com/feed.xml')`); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`insert into items (guid, feed_id, title, link, content) values ('', 1, null, null, null)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations = all
+	maxVersion = int64(len(migrations))
+	if err := quietMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	var guid string
+	db.QueryRow(`select guid from items`).Scan(&guid)
+	if guid == "" {
+		t.Error("want a guid computed for the item")
+	}
+}
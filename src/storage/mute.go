@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MutePeriod is a weekly quiet period of a folder: the unread items of
+// its feeds aren't counted while it lasts, though they're still fetched.
+// It starts on each of Days (every day if empty) at From and ends at To,
+// on the following day if To isn't after From (so "00:00"-"00:00" lasts
+// the whole day). Times are local, formatted as "HH:MM".
+type MutePeriod struct {
+	Days []time.Weekday `json:"days,omitempty"`
+	From string         `json:"from"`
+	To   string         `json:"to"`
+}
+
+// MaxMutePeriods limits the number of quiet periods of a folder.
+const MaxMutePeriods = 20
+
+func ValidateMuteSchedule(schedule []MutePeriod) error {
+	if len(schedule) > MaxMutePeriods {
+		return &ValidationError{"mute_schedule", fmt.Sprintf("must have at most %d periods", MaxMutePeriods)}
+	}
+	for _, p := range schedule {
+		for _, day := range p.Days {
+			if day < time.Sunday || day > time.Saturday {
+				return &ValidationError{"mute_schedule", fmt.Sprintf("%d is not a weekday (0-6)", day)}
+			}
+		}
+		if ValidateDeliveryTimes([]string{p.From, p.To}) != nil {
+			return &ValidationError{"mute_schedule", "from/to must be HH:MM times"}
+		}
+	}
+	return nil
+}
+
+func (p MutePeriod) startsOn(day time.Weekday) bool {
+	if len(p.Days) == 0 {
+		return true
+	}
+	for _, d := range p.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the period lasts at the given (local) time.
+func (p MutePeriod) Covers(t time.Time) bool {
+	hm := t.Format(deliveryTimeLayout)
+	if p.startsOn(t.Weekday()) && hm >= p.From && (p.To <= p.From || hm < p.To) {
+		return true
+	}
+	// spilled over from the day before
+	return p.To <= p.From && p.startsOn(t.AddDate(0, 0, -1).Weekday()) && hm < p.To
+}
+
+func parseMuteSchedule(value string) ([]MutePeriod, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var schedule []MutePeriod
+	if err := json.Unmarshal([]byte(value), &schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// UpdateFolderMuteSchedule replaces the quiet periods of the folder,
+// an empty schedule unmutes it.
+func (s *Storage) UpdateFolderMuteSchedule(folderId int64, schedule []MutePeriod) error {
+	if err := ValidateMuteSchedule(schedule); err != nil {
+		return err
+	}
+	value := ""
+	if len(schedule) > 0 {
+		data, err := json.Marshal(schedule)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+	return s.execOne(`update folders set mute_schedule = ? where id = ?`, value, folderId)
+}
+
+// MutedFeeds returns the feeds in the folders (or their parent
+// folders) muted at the given time, in the user's time zone.
+func (s *Storage) MutedFeeds(now time.Time) (map[int64]bool, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return nil, err
+	}
+	now = now.In(loc)
+	result := make(map[int64]bool)
+	rows, err := s.db.Query(`
+		with recursive ` + folderAncestors + `
+		select f.id, d.mute_schedule
+		from feeds f
+		join folder_ancestors a on a.folder_id = f.folder_id
+		join folders d on d.id = a.ancestor_id
+		where d.mute_schedule != ''
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	schedules := make(map[string]bool)
+	for rows.Next() {
+		var feedId int64
+		var schedule string
+		if err := rows.Scan(&feedId, &schedule); err != nil {
+			return nil, wrapError(err)
+		}
+		muted, ok := schedules[schedule]
+		if !ok {
+			periods, err := parseMuteSchedule(schedule)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range periods {
+				if p.Covers(now) {
+					muted = true
+					break
+				}
+			}
+			schedules[schedule] = muted
+		}
+		if muted {
+			result[feedId] = true
+		}
+	}
+	return result, wrapError(rows.Err())
+}
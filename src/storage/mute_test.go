@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMutePeriodCovers(t *testing.T) {
+	weekend := MutePeriod{Days: []time.Weekday{time.Saturday, time.Sunday}, From: "00:00", To: "00:00"}
+	night := MutePeriod{From: "22:00", To: "07:00"}
+	friday := MutePeriod{Days: []time.Weekday{time.Friday}, From: "18:00", To: "06:00"}
+
+	// 2020-01-03 is a friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		period MutePeriod
+		t      time.Time
+		want   bool
+	}{
+		{weekend, at(3, 23, 59), false},
+		{weekend, at(4, 0, 0), true},
+		{weekend, at(5, 23, 59), true},
+		{weekend, at(6, 0, 0), false},
+		{night, at(3, 21, 59), false},
+		{night, at(3, 22, 0), true},
+		{night, at(4, 6, 59), true},
+		{night, at(4, 7, 0), false},
+		{friday, at(3, 18, 0), true},
+		{friday, at(4, 5, 0), true},
+		{friday, at(4, 18, 0), false},
+		{friday, at(3, 5, 0), false},
+	}
+	for _, c := range cases {
+		if have := c.period.Covers(c.t); have != c.want {
+			t.Errorf("%v at %s: want %v, have %v", c.period, c.t, c.want, have)
+		}
+	}
+}
+
+func TestFolderMuteSchedule(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	invalid := [][]MutePeriod{
+		{{From: "7:00", To: "08:00"}},
+		{{Days: []time.Weekday{7}, From: "07:00", To: "08:00"}},
+	}
+	for _, schedule := range invalid {
+		if err := db.UpdateFolderMuteSchedule(scope.folder1.Id, schedule); err == nil {
+			t.Errorf("%v: expected an error", schedule)
+		}
+	}
+
+	always := []MutePeriod{{From: "00:00", To: "00:00"}}
+	if err := db.UpdateFolderMuteSchedule(scope.folder1.Id, always); err != nil {
+		t.Fatal(err)
+	}
+	if folders, _ := db.ListFolders(); len(folders[0].MuteSchedule) != 1 {
+		t.Fatalf("schedule not saved: %#v", folders[0])
+	}
+	stats, _ := db.FeedStats()
+	for _, stat := range stats {
+		inFolder := stat.FeedId == scope.feed11.Id || stat.FeedId == scope.feed12.Id
+		if stat.Muted != inFolder {
+			t.Errorf("feed %d: want muted %v, have %v", stat.FeedId, inFolder, stat.Muted)
+		}
+	}
+
+	if err := db.UpdateFolderMuteSchedule(scope.folder1.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if muted, _ := db.MutedFeeds(time.Now()); len(muted) != 0 {
+		t.Fatal("folder still muted")
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxNoteLength is the number of characters a note of an item is limited to.
+const MaxNoteLength = 10000
+
+// SetItemNote attaches the private note to the item, replacing the previous
+// one; an empty note removes it. The note is searchable along with the
+// item's text (see SearchNote).
+func (s *Storage) SetItemNote(itemId int64, note string) error {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return &ValidationError{"note", fmt.Sprintf("must be at most %d characters", MaxNoteLength)}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var item Item
+	var searchRowId sql.NullInt64
+	err = tx.QueryRow(
+		`select id, title, ifnull(author, ''), content, search_rowid from items where id = ?`, itemId,
+	).Scan(&item.Id, &item.Title, &item.Author, &item.Content, &searchRowId)
+	if err != nil {
+		return wrapError(err)
+	}
+	if note == "" {
+		_, err = tx.Exec(`delete from item_notes where item_id = ?`, itemId)
+	} else {
+		_, err = tx.Exec(`
+			insert into item_notes (item_id, note, updated) values (?, ?, datetime())
+			on conflict (item_id) do update set note = excluded.note, updated = excluded.updated`,
+			itemId, note,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	// the note is indexed along with the rest of the item
+	if searchRowId.Valid {
+		if _, err = tx.Exec(`delete from search where rowid = ?`, searchRowId.Int64); err != nil {
+			return err
+		}
+	}
+	if err = indexItem(tx, item.Id, item.Title, item.Author, item.Content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ItemNotes returns the notes of the items which have any.
+func (s *Storage) ItemNotes(itemIds []int64) (map[int64]string, error) {
+	result := make(map[int64]string)
+	if len(itemIds) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(itemIds))
+	for i, id := range itemIds {
+		args[i] = id
+	}
+	rows, err := s.db.Query(`
+		select item_id, note from item_notes
+		where item_id in (`+placeholders(len(itemIds))+`)
+	`, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemId int64
+		var note string
+		if err := rows.Scan(&itemId, &note); err != nil {
+			return nil, wrapError(err)
+		}
+		result[itemId] = note
+	}
+	return result, wrapError(rows.Err())
+}
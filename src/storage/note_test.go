@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestItemNotes(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+	db.SyncSearch()
+
+	item111 := getItem(db, "item111")
+	if err := db.SetItemNote(item111.Id, "  referenced in my blog post "); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := db.GetItem(item111.Id); have.Note != "referenced in my blog post" {
+		t.Errorf("unexpected note: %q", have.Note)
+	}
+
+	// the note is searchable, along with the item's text
+	for _, field := range []string{"", SearchNote} {
+		search := "blog"
+		have := getItemGuids(db.ListItems(ItemFilter{Search: &search, SearchField: field}, 10, false, false))
+		if want := []string{"item111"}; !reflect.DeepEqual(have, want) {
+			t.Errorf("%q: want %v, have %v", field, want, have)
+		}
+	}
+	search := "title111"
+	have := getItemGuids(db.ListItems(ItemFilter{Search: &search}, 10, false, false))
+	if want := []string{"item111"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	if err := db.SetItemNote(item111.Id, ""); err != nil {
+		t.Fatal(err)
+	}
+	search = "blog"
+	if have, _ := db.ListItems(ItemFilter{Search: &search}, 10, false, false); len(have) != 0 {
+		t.Errorf("unexpected items: %v", getItemGuids(have, nil))
+	}
+	if have, _ := db.GetItem(item111.Id); have.Note != "" {
+		t.Errorf("unexpected note: %q", have.Note)
+	}
+
+	if err := db.SetItemNote(100500, "note"); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	var validationErr *ValidationError
+	if err := db.SetItemNote(item111.Id, strings.Repeat("x", MaxNoteLength+1)); !errors.As(err, &validationErr) {
+		t.Errorf("want a validation error, have %v", err)
+	}
+}
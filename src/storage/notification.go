@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notifier is a configured notification channel, the config
+// is specific to its kind (see the notify package).
+type Notifier struct {
+	Id     int64           `json:"id"`
+	Name   string          `json:"name"`
+	Kind   string          `json:"kind"`
+	Config json.RawMessage `json:"config"`
+}
+
+// NotificationRoute sends the new items matching it to a notifier.
+// An item matches if it belongs to FeedId and FolderId (any if nil),
+// its title contains Match (case-insensitive, any if empty) and, with
+// MinSeverity set, it's an incident of a status page at least that severe.
+//
+// The items are batched: a notification is sent once the oldest queued
+// item has waited BatchMinutes, and at most MaxPerHour notifications
+// (unlimited if 0) are sent per hour, the rest keep accumulating.
+type NotificationRoute struct {
+	Id           int64    `json:"id"`
+	NotifierId   int64    `json:"notifier_id"`
+	FeedId       *int64   `json:"feed_id"`
+	FolderId     *int64   `json:"folder_id"`
+	Match        string   `json:"match"`
+	BatchMinutes int      `json:"batch_minutes"`
+	MaxPerHour   int      `json:"max_per_hour"`
+	MinSeverity  Severity `json:"min_severity"`
+	// if set, the route doesn't notify about the items but about the
+	// backlog: once the unread items of its feed or folder reach the
+	// threshold, see PendingBacklogNotifications
+	BacklogThreshold int64 `json:"backlog_threshold"`
+}
+
+type NotificationItem struct {
+	ItemId    int64
+	Title     string
+	Link      string
+	FeedTitle string
+}
+
+// PendingNotification is a batch of queued items due to be sent.
+type PendingNotification struct {
+	Route    NotificationRoute
+	Notifier Notifier
+	Items    []NotificationItem
+}
+
+// MaxNotificationItems limits the items of a single notification,
+// the rest are sent with the next one.
+const MaxNotificationItems = 50
+
+// NotificationMaxAge is how long items wait in the queue at most,
+// e.g. while their notifier keeps failing.
+const NotificationMaxAge = 24 * time.Hour
+
+func (s *Storage) ListNotifiers() ([]Notifier, error) {
+	result := make([]Notifier, 0)
+	rows, err := s.db.Query(`select id, name, kind, config from notifiers order by name collate nocase`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n Notifier
+		var config string
+		if err := rows.Scan(&n.Id, &n.Name, &n.Kind, &config); err != nil {
+			return nil, err
+		}
+		n.Config = json.RawMessage(config)
+		result = append(result, n)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetNotifier(id int64) (*Notifier, error) {
+	var n Notifier
+	var config string
+	err := s.db.QueryRow(`select id, name, kind, config from notifiers where id = ?`, id).
+		Scan(&n.Id, &n.Name, &n.Kind, &config)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	n.Config = json.RawMessage(config)
+	return &n, nil
+}
+
+// CreateNotifier stores the notifier, its kind and config are
+// expected to be validated by the caller.
+func (s *Storage) CreateNotifier(name, kind string, config json.RawMessage) (*Notifier, error) {
+	if err := ValidateTitle("name", name); err != nil {
+		return nil, err
+	}
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	var id int64
+	err := s.db.QueryRow(`
+		insert into notifiers (name, kind, config) values (?, ?, ?)
+		returning id`,
+		name, kind, string(config),
+	).Scan(&id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &Notifier{Id: id, Name: name, Kind: kind, Config: config}, nil
+}
+
+func (s *Storage) DeleteNotifier(id int64) error {
+	return s.execOne(`delete from notifiers where id = ?`, id)
+}
+
+func validateNotificationRoute(route NotificationRoute) error {
+	if route.BatchMinutes < 0 || route.BatchMinutes > 7*24*60 {
+		return &ValidationError{"batch_minutes", "must be between 0 and 10080"}
+	}
+	if route.MaxPerHour < 0 {
+		return &ValidationError{"max_per_hour", "must not be negative"}
+	}
+	if _, ok := SeverityRepresentations[route.MinSeverity]; !ok {
+		return &ValidationError{"min_severity", "must be one of maintenance, minor, major and critical"}
+	}
+	if len(route.Match) > MaxTitleLength {
+		return &ValidationError{"match", fmt.Sprintf("must be at most %d characters", MaxTitleLength)}
+	}
+	if route.BacklogThreshold < 0 {
+		return &ValidationError{"backlog_threshold", "must not be negative"}
+	}
+	if route.BacklogThreshold > 0 && (route.Match != "" || route.MinSeverity != SeverityNone) {
+		return &ValidationError{"backlog_threshold", "doesn't go along with match and min_severity"}
+	}
+	return nil
+}
+
+func (s *Storage) ListNotificationRoutes() ([]NotificationRoute, error) {
+	result := make([]NotificationRoute, 0)
+	rows, err := s.db.Query(`
+		select id, notifier_id, feed_id, folder_id, match, batch_minutes, max_per_hour, min_severity, backlog_threshold
+		from notification_routes
+		order by id
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r NotificationRoute
+		err := rows.Scan(&r.Id, &r.NotifierId, &r.FeedId, &r.FolderId, &r.Match, &r.BatchMinutes, &r.MaxPerHour, &r.MinSeverity, &r.BacklogThreshold)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// CreateNotificationRoute stores the route, ErrConstraint is returned
+// if the notifier, feed or folder doesn't exist.
+func (s *Storage) CreateNotificationRoute(route NotificationRoute) (*NotificationRoute, error) {
+	if err := validateNotificationRoute(route); err != nil {
+		return nil, err
+	}
+	err := s.db.QueryRow(`
+		insert into notification_routes (notifier_id, feed_id, folder_id, match, batch_minutes, max_per_hour, min_severity, backlog_threshold)
+		values (?, ?, ?, ?, ?, ?, ?, ?)
+		returning id`,
+		route.NotifierId, route.FeedId, route.FolderId, route.Match, route.BatchMinutes, route.MaxPerHour, route.MinSeverity, route.BacklogThreshold,
+	).Scan(&route.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &route, nil
+}
+
+func (s *Storage) DeleteNotificationRoute(id int64) error {
+	return s.execOne(`delete from notification_routes where id = ?`, id)
+}
+
+// queueNotifications queues the new item for the routes it matches.
+func queueNotifications(tx *sql.Tx, itemId int64, now time.Time) error {
+	_, err := tx.Exec(`
+		insert or ignore into notification_queue (route_id, item_id, created_at)
+		select r.id, i.id, ?
+		from items i
+		join feeds f on f.id = i.feed_id
+		join notification_routes r
+		 on (r.feed_id is null or r.feed_id = f.id)
+		 and (r.folder_id is null or r.folder_id in (
+		   with recursive `+folderAncestors+`
+		   select ancestor_id from folder_ancestors where folder_id = f.folder_id
+		 ))
+		 and (r.match = '' or instr(lower(i.title), lower(r.match)) > 0)
+		 and (r.min_severity = 0 or ifnull(i.incident_severity, 0) >= r.min_severity)
+		where i.id = ? and r.backlog_threshold = 0
+	`, now, itemId)
+	return err
+}
+
+// PendingNotifications returns the batches due to be sent at the given
+// time. Snoozed items and items of muted folders wait in the queue
+// until they're due, the items held for scheduled delivery are queued
+// once they're delivered.
+func (s *Storage) PendingNotifications(now time.Time) ([]PendingNotification, error) {
+	muted, err := s.MutedFeeds(now)
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+
+	_, err = s.db.Exec(`delete from notification_queue where created_at < ?`, now.Add(-NotificationMaxAge))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	_, err = s.db.Exec(`delete from notification_sends where sent_at < ?`, now.Add(-time.Hour))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	rows, err := s.db.Query(`
+		select
+			r.id, r.notifier_id, r.feed_id, r.folder_id, r.match, r.batch_minutes, r.max_per_hour, r.min_severity,
+			n.name, n.kind, n.config
+		from notification_routes r
+		join notifiers n on n.id = r.notifier_id
+		where r.id in (
+			select route_id from notification_queue
+			group by route_id
+			having julianday(min(created_at)) <= julianday(?) - r.batch_minutes / 1440.0
+		)
+		and (r.max_per_hour = 0 or r.max_per_hour > (
+			select count(*) from notification_sends
+			where route_id = r.id and sent_at > ?
+		))
+		order by r.id
+	`, now, now.Add(-time.Hour))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	result := make([]PendingNotification, 0)
+	for rows.Next() {
+		var p PendingNotification
+		var config string
+		r := &p.Route
+		err := rows.Scan(
+			&r.Id, &r.NotifierId, &r.FeedId, &r.FolderId, &r.Match, &r.BatchMinutes, &r.MaxPerHour, &r.MinSeverity,
+			&p.Notifier.Name, &p.Notifier.Kind, &config,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Notifier.Id = r.NotifierId
+		p.Notifier.Config = json.RawMessage(config)
+		result = append(result, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, wrapError(err)
+	}
+	rows.Close()
+
+	due := result[:0]
+	for _, p := range result {
+		items, err := s.queuedNotificationItems(p.Route.Id, muted)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			p.Items = items
+			due = append(due, p)
+		}
+	}
+	return due, nil
+}
+
+func (s *Storage) queuedNotificationItems(routeId int64, muted map[int64]bool) ([]NotificationItem, error) {
+	rows, err := s.db.Query(`
+		select i.id, i.title, i.link, i.feed_id, f.title
+		from notification_queue q
+		join items i on i.id = q.item_id
+		join feeds f on f.id = i.feed_id
+		where q.route_id = ? and i.snoozed_until is null
+		order by i.date, i.id
+	`, routeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]NotificationItem, 0)
+	for rows.Next() {
+		var x NotificationItem
+		var feedId int64
+		if err := rows.Scan(&x.ItemId, &x.Title, &x.Link, &feedId, &x.FeedTitle); err != nil {
+			return nil, err
+		}
+		if muted[feedId] {
+			continue
+		}
+		items = append(items, x)
+		if len(items) == MaxNotificationItems {
+			break
+		}
+	}
+	return items, rows.Err()
+}
+
+// MarkNotificationSent removes the sent items from the queue of the
+// route and counts the notification against its rate limit.
+func (s *Storage) MarkNotificationSent(routeId int64, itemIds []int64, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if len(itemIds) > 0 {
+		args := []interface{}{routeId}
+		for _, id := range itemIds {
+			args = append(args, id)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(itemIds)), ",")
+		_, err = tx.Exec(`
+			delete from notification_queue
+			where route_id = ? and item_id in (`+placeholders+`)`,
+			args...,
+		)
+	}
+	if err == nil {
+		_, err = tx.Exec(`insert into notification_sends (route_id, sent_at) values (?, ?)`, routeId, now.UTC())
+	}
+	if err != nil {
+		tx.Rollback()
+		return wrapError(err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func pendingTitles(pending []PendingNotification, err error) map[int64][]string {
+	if err != nil {
+		panic(err)
+	}
+	result := make(map[int64][]string)
+	for _, p := range pending {
+		for _, item := range p.Items {
+			result[p.Route.Id] = append(result[p.Route.Id], item.Title)
+		}
+	}
+	return result
+}
+
+func TestNotificationRouting(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	notifier, err := db.CreateNotifier("hook", "webhook", []byte(`{"url":"http://example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateNotifier("hook", "webhook", nil); err == nil {
+		t.Fatal("expected an error for a duplicate name")
+	}
+	if _, err := db.CreateNotificationRoute(NotificationRoute{NotifierId: -1}); err == nil {
+		t.Fatal("expected an error for a missing notifier")
+	}
+	byFeed, _ := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, FeedId: &scope.feed11.Id})
+	byFolder, _ := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, FolderId: &scope.folder2.Id})
+	byMatch, _ := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, Match: "GO"})
+
+	// items existing before the routes aren't notified about
+	if pending, _ := db.PendingNotifications(time.Now()); len(pending) != 0 {
+		t.Fatalf("unexpected notifications: %v", pendingTitles(pending, nil))
+	}
+
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "new111", FeedId: scope.feed11.Id, Title: "new111", Date: now},
+		{GUID: "new121", FeedId: scope.feed12.Id, Title: "new121", Date: now},
+		{GUID: "new211", FeedId: scope.feed21.Id, Title: "Let's go", Date: now},
+		{GUID: "new011", FeedId: scope.feed01.Id, Title: "new011", Date: now},
+		// updated, not new
+		{GUID: "item112", FeedId: scope.feed11.Id, Title: "go", Date: now, DateUpdated: &now},
+	})
+
+	want := map[int64][]string{
+		byFeed.Id:   {"new111"},
+		byFolder.Id: {"Let's go"},
+		byMatch.Id:  {"Let's go"},
+	}
+	pending, _ := db.PendingNotifications(time.Now())
+	if have := pendingTitles(pending, nil); !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if pending[0].Notifier.Kind != "webhook" {
+		t.Fatalf("invalid notifier: %#v", pending[0].Notifier)
+	}
+
+	if err := db.MarkNotificationSent(byFeed.Id, []int64{pending[0].Items[0].ItemId}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, byFeed.Id)
+	if have := pendingTitles(db.PendingNotifications(time.Now())); !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	if err := db.DeleteNotifier(notifier.Id); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ := db.ListNotificationRoutes(); len(routes) != 0 {
+		t.Fatalf("routes not deleted with the notifier: %v", routes)
+	}
+}
+
+func TestNotificationBatching(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	notifier, _ := db.CreateNotifier("hook", "webhook", nil)
+	route, _ := db.CreateNotificationRoute(NotificationRoute{
+		NotifierId:   notifier.Id,
+		BatchMinutes: 10,
+		MaxPerHour:   1,
+	})
+	if _, err := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, BatchMinutes: -1}); err == nil {
+		t.Fatal("expected an error for negative batch_minutes")
+	}
+
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "new1", FeedId: scope.feed11.Id, Title: "new1", Date: now},
+		{GUID: "new2", FeedId: scope.feed12.Id, Title: "new2", Date: now},
+	})
+	if pending, _ := db.PendingNotifications(now); len(pending) != 0 {
+		t.Fatalf("notification sent before the batch period: %v", pendingTitles(pending, nil))
+	}
+
+	later := now.Add(11 * time.Minute)
+	pending, _ := db.PendingNotifications(later)
+	want := map[int64][]string{route.Id: {"new1", "new2"}}
+	if have := pendingTitles(pending, nil); !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	db.MarkNotificationSent(route.Id, []int64{pending[0].Items[0].ItemId}, later)
+
+	// rate limited
+	if pending, _ := db.PendingNotifications(later); len(pending) != 0 {
+		t.Fatalf("rate limit exceeded: %v", pendingTitles(pending, nil))
+	}
+	want = map[int64][]string{route.Id: {"new2"}}
+	if have := pendingTitles(db.PendingNotifications(later.Add(time.Hour))); !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	// snoozed items wait until woken
+	db.SnoozeItem(getItem(db, "new2").Id, time.Now().Add(time.Hour))
+	if pending, _ := db.PendingNotifications(later.Add(time.Hour)); len(pending) != 0 {
+		t.Fatalf("snoozed item notified about: %v", pendingTitles(pending, nil))
+	}
+}
+
+func TestNotificationRouteSeverity(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("status", "", "", "http://status.example.com/history.rss", "", nil)
+	notifier, _ := db.CreateNotifier("hook", "webhook", nil)
+	route, err := db.CreateNotificationRoute(NotificationRoute{NotifierId: notifier.Id, MinSeverity: SeverityMajor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "Degraded performance", Date: now, Incident: &Incident{Status: "investigating", Severity: SeverityMinor}},
+		{GUID: "2", FeedId: feed.Id, Title: "Major outage", Date: now, Incident: &Incident{Status: "identified", Severity: SeverityCritical}},
+		{GUID: "3", FeedId: feed.Id, Title: "Not an incident", Date: now},
+	})
+	want := map[int64][]string{route.Id: {"Major outage"}}
+	if have := pendingTitles(db.PendingNotifications(time.Now())); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	items, _ := db.ListItems(ItemFilter{}, 10, false, false)
+	if incident := items[1].Incident; incident == nil || *incident != (Incident{Status: "identified", Severity: SeverityCritical}) {
+		t.Errorf("unexpected incident: %#v", incident)
+	}
+	if items[2].Incident != nil {
+		t.Errorf("unexpected incident: %#v", items[2].Incident)
+	}
+	if routes, _ := db.ListNotificationRoutes(); routes[0].MinSeverity != SeverityMajor {
+		t.Errorf("unexpected routes: %#v", routes)
+	}
+}
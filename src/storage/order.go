@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// DefaultCustomOrder is assigned to feeds and folders without an explicit
+// order. Rebalanced keys are always generated below it, so that new
+// entries keep sorting after the ones ordered by the user.
+const DefaultCustomOrder = "xxxxxxxxx"
+
+// orderAlphabet is sorted in byte order, which is how sqlite compares text.
+const orderAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// maxOrderKeyLength is the key length past which the keys are renormalized.
+const maxOrderKeyLength = 16
+
+func orderDigit(c byte) int {
+	return strings.IndexByte(orderAlphabet, c)
+}
+
+// OrderKeyBetween returns a key sorting strictly between lo and hi.
+// An empty lo means no lower bound, an empty hi means no upper bound.
+// Returns an empty string if no such key exists (e.g. lo >= hi).
+func OrderKeyBetween(lo, hi string) string {
+	if hi != "" && lo >= hi {
+		return ""
+	}
+	base := len(orderAlphabet)
+	bounded := hi != ""
+	key := make([]byte, 0, len(lo)+1)
+	for i := 0; ; i++ {
+		l := 0
+		if i < len(lo) {
+			if l = orderDigit(lo[i]); l < 0 {
+				return ""
+			}
+		}
+		h := base
+		if bounded {
+			if i >= len(hi) {
+				// hi is a prefix of the key built so far
+				return ""
+			}
+			if h = orderDigit(hi[i]); h < 0 {
+				return ""
+			}
+		}
+		if h-l > 1 {
+			return string(append(key, orderAlphabet[(l+h)/2]))
+		}
+		key = append(key, orderAlphabet[l])
+		if h-l == 1 {
+			// the key is now below hi, whatever comes next
+			bounded = false
+		}
+	}
+}
+
+// OrderKeys returns n evenly spaced keys of equal length sorting
+// below DefaultCustomOrder, leaving room for insertions in between.
+func OrderKeys(n int) []string {
+	base := len(orderAlphabet)
+	limit := orderDigit(DefaultCustomOrder[0])
+
+	// smallest width with at least `base` free slots between neighbours
+	width, space := 1, limit
+	for space/(n+1) < base {
+		width++
+		space *= base
+	}
+	step := space / (n + 1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		v := step * (i + 1)
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = orderAlphabet[v%base]
+			v /= base
+		}
+		keys[i] = string(key)
+	}
+	return keys
+}
+
+// CustomOrderNeedsRebalance reports whether any feed or folder
+// has accumulated an overly long custom_order key.
+func (s *Storage) CustomOrderNeedsRebalance() (bool, error) {
+	var n int
+	err := s.db.QueryRow(`
+		select
+			(select count(*) from feeds where length(custom_order) > ?) +
+			(select count(*) from folders where length(custom_order) > ?)
+	`, maxOrderKeyLength, maxOrderKeyLength).Scan(&n)
+	if err != nil {
+		return false, wrapError(err)
+	}
+	return n > 0, nil
+}
+
+// RebalanceCustomOrder renormalizes the custom_order keys of feeds and
+// folders preserving their relative order (entries sharing a key keep
+// sharing one). Keys at or above DefaultCustomOrder are left untouched.
+func (s *Storage) RebalanceCustomOrder() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"feeds", "folders"} {
+		if err = rebalanceTable(tx, table); err != nil {
+			return wrapError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+func rebalanceTable(tx *sql.Tx, table string) error {
+	rows, err := tx.Query(`
+		select id, custom_order from `+table+`
+		where custom_order < ?
+		order by custom_order`,
+		DefaultCustomOrder,
+	)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0)
+	groups := make([]int, 0)
+	distinct := 0
+	prev := ""
+	for rows.Next() {
+		var id int64
+		var key string
+		if err = rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(ids) == 0 || key != prev {
+			distinct++
+		}
+		ids = append(ids, id)
+		groups = append(groups, distinct-1)
+		prev = key
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	keys := OrderKeys(distinct)
+	for i, id := range ids {
+		_, err = tx.Exec(`update `+table+` set custom_order = ? where id = ?`, keys[groups[i]], id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FeedOrder places a feed in ReorderFeeds.
+type FeedOrder struct {
+	FeedId   int64  `json:"feed_id"`
+	FolderId *int64 `json:"folder_id"`
+}
+
+// ReorderFeeds puts the feeds in the given order (e.g. the feeds of a folder
+// after one of them is dragged to another position), moving them to their
+// folder if it's changed. The feeds get fresh evenly spaced keys, the ones
+// not listed keep theirs. Nothing is changed if any of the feeds doesn't
+// exist (ErrNotFound) or any of the folders doesn't (ErrConstraint).
+func (s *Storage) ReorderFeeds(order []FeedOrder) error {
+	seen := make(map[int64]bool, len(order))
+	for _, o := range order {
+		if seen[o.FeedId] {
+			return &ValidationError{"feeds", "must list each feed once"}
+		}
+		seen[o.FeedId] = true
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	keys := OrderKeys(len(order))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for i, o := range order {
+		var result sql.Result
+		result, err = tx.Exec(`
+			update feeds
+			set custom_order = ?,
+			    folder_modified = folder_modified or folder_id is not ?,
+			    folder_id = ?
+			where id = ?`,
+			keys[i], o.FolderId, o.FolderId, o.FeedId,
+		)
+		if err == nil {
+			var n int64
+			if n, err = result.RowsAffected(); err == nil && n == 0 {
+				err = ErrNotFound
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return wrapError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ReorderFolders puts the folders in the given order (e.g. the subfolders
+// of a folder after one of them is dragged to another position) like
+// ReorderFeeds. Nothing is changed if any of the folders doesn't exist (ErrNotFound).
+func (s *Storage) ReorderFolders(folderIds []int64) error {
+	seen := make(map[int64]bool, len(folderIds))
+	for _, id := range folderIds {
+		if seen[id] {
+			return &ValidationError{"folder_ids", "must list each folder once"}
+		}
+		seen[id] = true
+	}
+	if len(folderIds) == 0 {
+		return nil
+	}
+	keys := OrderKeys(len(folderIds))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for i, id := range folderIds {
+		var result sql.Result
+		result, err = tx.Exec(`update folders set custom_order = ? where id = ?`, keys[i], id)
+		if err == nil {
+			var n int64
+			if n, err = result.RowsAffected(); err == nil && n == 0 {
+				err = ErrNotFound
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return wrapError(err)
+		}
+	}
+	return tx.Commit()
+}
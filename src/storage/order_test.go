@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestOrderKeyBetween(t *testing.T) {
+	cases := [][2]string{
+		{"", ""},
+		{"", "1"},
+		{"a", "b"},
+		{"a", "a1"},
+		{"az", "b"},
+		{"azz", "b"},
+		{"1", ""},
+		{"zzz", ""},
+		{"", DefaultCustomOrder},
+	}
+	for _, c := range cases {
+		key := OrderKeyBetween(c[0], c[1])
+		if key == "" || key <= c[0] || (c[1] != "" && key >= c[1]) {
+			t.Errorf("invalid key between %q and %q: %q", c[0], c[1], key)
+		}
+	}
+	if key := OrderKeyBetween("b", "a"); key != "" {
+		t.Errorf("expected no key for inverted bounds, got %q", key)
+	}
+}
+
+func TestOrderKeys(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 10, 100, 5000} {
+		keys := OrderKeys(n)
+		if len(keys) != n {
+			t.Fatalf("expected %d keys, got %d", n, len(keys))
+		}
+		if !sort.StringsAreSorted(keys) {
+			t.Fatalf("keys not sorted: %v", keys)
+		}
+		for i, key := range keys {
+			if key >= DefaultCustomOrder {
+				t.Fatalf("key %q not below the default", key)
+			}
+			if i > 0 && OrderKeyBetween(keys[i-1], key) == "" {
+				t.Fatalf("no room between %q and %q", keys[i-1], key)
+			}
+		}
+	}
+}
+
+func TestRebalanceCustomOrder(t *testing.T) {
+	db := testDB()
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "a", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "a0000000000000000001", nil)
+	feed3, _ := db.CreateFeed("feed3", "", "", "http://example.com/feed3.xml", "a0000000000000000001", nil)
+	feed4, _ := db.CreateFeed("feed4", "", "", "http://example.com/feed4.xml", "b", nil)
+	feed5, _ := db.CreateFeed("feed5", "", "", "http://example.com/feed5.xml", "", nil)
+
+	if needed, _ := db.CustomOrderNeedsRebalance(); !needed {
+		t.Fatal("expected rebalance to be needed")
+	}
+	if err := db.RebalanceCustomOrder(); err != nil {
+		t.Fatal(err)
+	}
+	if needed, _ := db.CustomOrderNeedsRebalance(); needed {
+		t.Fatal("expected no rebalance to be needed")
+	}
+
+	order := func(feed *Feed) string {
+		feed, _ = db.GetFeed(feed.Id)
+		return feed.CustomOrder
+	}
+	if !(order(feed1) < order(feed2) && order(feed2) == order(feed3) && order(feed3) < order(feed4)) {
+		t.Fatalf("order not preserved: %q %q %q %q", order(feed1), order(feed2), order(feed3), order(feed4))
+	}
+	if order(feed5) != DefaultCustomOrder {
+		t.Fatalf("default order changed: %q", order(feed5))
+	}
+}
+
+func TestReorderFeeds(t *testing.T) {
+	db := testDB()
+	folder, _ := db.CreateFolder("folder")
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", nil)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+	feed3, _ := db.CreateFeed("feed3", "", "", "http://example.com/feed3.xml", "", nil)
+
+	// the feeds outside of any folder
+	titles := func() []string {
+		feeds, _ := db.ListFeeds()
+		result := make([]string, 0)
+		for _, feed := range feeds {
+			if feed.FolderId == nil {
+				result = append(result, feed.Title)
+			}
+		}
+		return result
+	}
+
+	if err := db.ReorderFeeds([]FeedOrder{{FeedId: feed3.Id}, {FeedId: feed1.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	// the unlisted feeds go after the ordered ones
+	if have, want := titles(), []string{"feed3", "feed1", "feed2"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	if err := db.ReorderFeeds([]FeedOrder{{FeedId: feed2.Id, FolderId: &folder.Id}}); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(feed2.Id); feed.FolderId == nil || *feed.FolderId != folder.Id || !feed.FolderModified {
+		t.Fatalf("feed not moved: %#v", feed)
+	}
+
+	// nothing is changed if any of the feeds is missing
+	err := db.ReorderFeeds([]FeedOrder{{FeedId: feed1.Id}, {FeedId: feed3.Id}, {FeedId: 100500}})
+	if err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+	missing := int64(100500)
+	if err := db.ReorderFeeds([]FeedOrder{{FeedId: feed1.Id, FolderId: &missing}}); !errors.Is(err, ErrConstraint) {
+		t.Fatalf("want ErrConstraint, have %v", err)
+	}
+	var validationErr *ValidationError
+	if err := db.ReorderFeeds([]FeedOrder{{FeedId: feed1.Id}, {FeedId: feed1.Id}}); !errors.As(err, &validationErr) {
+		t.Fatalf("want a validation error, have %v", err)
+	}
+	if have, want := titles(), []string{"feed3", "feed1"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestReorderFolders(t *testing.T) {
+	db := testDB()
+	folder1, _ := db.CreateFolder("folder1")
+	folder2, _ := db.CreateFolder("folder2")
+	folder3, _ := db.CreateFolder("folder3")
+
+	titles := func() []string {
+		result := make([]string, 0)
+		folders, _ := db.ListFolders()
+		for _, folder := range folders {
+			result = append(result, folder.Title)
+		}
+		return result
+	}
+
+	if err := db.ReorderFolders([]int64{folder3.Id, folder1.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := titles(), []string{"folder3", "folder1", "folder2"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if err := db.ReorderFolders([]int64{folder1.Id, folder2.Id, 100500}); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+	var validationErr *ValidationError
+	if err := db.ReorderFolders([]int64{folder1.Id, folder1.Id}); !errors.As(err, &validationErr) {
+		t.Fatalf("want a validation error, have %v", err)
+	}
+	if have, want := titles(), []string{"folder3", "folder1", "folder2"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Playback is the listening/watching progress of an item's enclosure.
+type Playback struct {
+	Position  float64   `json:"position"`
+	Duration  float64   `json:"duration"`
+	Completed bool      `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// playbackScanner collects the (nullable) playback columns
+// of items joined with the playback table.
+type playbackScanner struct {
+	position  sql.NullFloat64
+	duration  sql.NullFloat64
+	completed sql.NullBool
+	updatedAt sql.NullTime
+}
+
+func (ps *playbackScanner) dest() []interface{} {
+	return []interface{}{&ps.position, &ps.duration, &ps.completed, &ps.updatedAt}
+}
+
+func (ps *playbackScanner) value() *Playback {
+	if !ps.position.Valid {
+		return nil
+	}
+	return &Playback{
+		Position:  ps.position.Float64,
+		Duration:  ps.duration.Float64,
+		Completed: ps.completed.Bool,
+		UpdatedAt: ps.updatedAt.Time,
+	}
+}
+
+const playbackCols = "p.position, p.duration, p.completed, p.updated_at"
+
+func (s *Storage) GetPlayback(itemId int64) (*Playback, error) {
+	var p Playback
+	err := s.db.QueryRow(`
+		select position, duration, completed, updated_at
+		from playback where item_id = ?
+	`, itemId).Scan(&p.Position, &p.Duration, &p.Completed, &p.UpdatedAt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &p, nil
+}
+
+// UpdatePlayback stores the playback state, unless a more recent one
+// (as reported by another device) is already stored. The time reported
+// is capped at the server's now: a device with its clock ahead would
+// otherwise keep the others' updates out until the clocks caught up.
+func (s *Storage) UpdatePlayback(itemId int64, playback Playback) error {
+	if now := time.Now(); playback.UpdatedAt.IsZero() || playback.UpdatedAt.After(now) {
+		playback.UpdatedAt = now
+	}
+	_, err := s.db.Exec(`
+		insert into playback (item_id, position, duration, completed, updated_at)
+		values (?, ?, ?, ?, ?)
+		on conflict (item_id) do update set
+			position = excluded.position,
+			duration = excluded.duration,
+			completed = excluded.completed,
+			updated_at = excluded.updated_at
+		where excluded.updated_at >= playback.updated_at`,
+		itemId, playback.Position, playback.Duration, playback.Completed, playback.UpdatedAt.UTC(),
+	)
+	return wrapError(err)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayback(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	item := getItem(db, "item111")
+
+	if _, err := db.GetPlayback(item.Id); err != ErrNotFound {
+		t.Fatalf("expected no playback state, got %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	db.UpdatePlayback(item.Id, Playback{Position: 42.5, Duration: 100, UpdatedAt: now})
+	// stale update from another device
+	db.UpdatePlayback(item.Id, Playback{Position: 10, Duration: 100, UpdatedAt: now.Add(-time.Minute)})
+
+	playback, _ := db.GetPlayback(item.Id)
+	if playback == nil || playback.Position != 42.5 || playback.Completed {
+		t.Fatalf("unexpected playback: %#v", playback)
+	}
+
+	db.UpdatePlayback(item.Id, Playback{Position: 100, Duration: 100, Completed: true, UpdatedAt: now.Add(time.Minute)})
+	if item, _ := db.GetItem(item.Id); item.Playback == nil || !item.Playback.Completed {
+		t.Fatalf("unexpected item playback: %#v", playback)
+	}
+
+	items, _ := db.ListItems(ItemFilter{FeedID: &scope.feed11.Id}, 10, false, false)
+	for _, x := range items {
+		if x.Id == item.Id && (x.Playback == nil || x.Playback.Position != 100) {
+			t.Fatalf("unexpected listed playback: %#v", x.Playback)
+		}
+		if x.Id != item.Id && x.Playback != nil {
+			t.Fatalf("unexpected listed playback: %#v", x.Playback)
+		}
+	}
+}
+
+func TestPlaybackFutureUpdate(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+	item := getItem(db, "item111")
+
+	// a device with its clock a day ahead
+	db.UpdatePlayback(item.Id, Playback{Position: 10, Duration: 100, UpdatedAt: time.Now().Add(24 * time.Hour)})
+	if playback, _ := db.GetPlayback(item.Id); playback == nil || playback.UpdatedAt.After(time.Now()) {
+		t.Fatalf("want the update time capped at now, have %#v", playback)
+	}
+
+	db.UpdatePlayback(item.Id, Playback{Position: 20, Duration: 100, UpdatedAt: time.Now()})
+	if playback, _ := db.GetPlayback(item.Id); playback == nil || playback.Position != 20 {
+		t.Fatalf("want the later update stored, have %#v", playback)
+	}
+}
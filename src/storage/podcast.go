@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// ItemPodcast holds the podcastindex.org namespace metadata of an item.
+type ItemPodcast struct {
+	ChaptersURL  string       `json:"chapters_url,omitempty"`
+	ChaptersType string       `json:"chapters_type,omitempty"`
+	Chapters     []Chapter    `json:"chapters,omitempty"`
+	Transcripts  []Transcript `json:"transcripts,omitempty"`
+	Persons      []Person     `json:"persons,omitempty"`
+}
+
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+	Title string  `json:"title"`
+	Image string  `json:"image,omitempty"`
+	URL   string  `json:"url,omitempty"`
+}
+
+type Transcript struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Language string `json:"language,omitempty"`
+	Rel      string `json:"rel,omitempty"`
+}
+
+type Person struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	Group string `json:"group"`
+	Image string `json:"image,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+func jsonOrNull(val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func createItemPodcast(tx *sql.Tx, item Item) error {
+	transcripts, err := jsonOrNull(item.Podcast.Transcripts)
+	if err != nil {
+		return err
+	}
+	persons, err := jsonOrNull(item.Podcast.Persons)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		insert into item_podcast (item_id, chapters_url, chapters_type, transcripts, persons)
+		select id, ?, ?, ?, ?
+		from items where feed_id = ? and guid = ?
+		on conflict (item_id) do nothing`,
+		item.Podcast.ChaptersURL, item.Podcast.ChaptersType,
+		transcripts, persons,
+		item.FeedId, item.GUID,
+	)
+	return err
+}
+
+func (s *Storage) GetItemPodcast(itemId int64) (*ItemPodcast, error) {
+	var podcast ItemPodcast
+	var chapters, transcripts, persons sql.NullString
+	err := s.db.QueryRow(`
+		select chapters_url, chapters_type, chapters, transcripts, persons
+		from item_podcast where item_id = ?
+	`, itemId).Scan(&podcast.ChaptersURL, &podcast.ChaptersType, &chapters, &transcripts, &persons)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	for _, field := range []struct {
+		src sql.NullString
+		dst interface{}
+	}{
+		{chapters, &podcast.Chapters},
+		{transcripts, &podcast.Transcripts},
+		{persons, &podcast.Persons},
+	} {
+		if !field.src.Valid {
+			continue
+		}
+		if err := json.Unmarshal([]byte(field.src.String), field.dst); err != nil {
+			return nil, err
+		}
+	}
+	return &podcast, nil
+}
+
+// SetItemChapters caches chapters fetched from the item's chapters url.
+func (s *Storage) SetItemChapters(itemId int64, chapters []Chapter) error {
+	if chapters == nil {
+		chapters = make([]Chapter, 0)
+	}
+	data, err := jsonOrNull(chapters)
+	if err != nil {
+		return err
+	}
+	return s.execOne(`update item_podcast set chapters = ? where item_id = ?`, data, itemId)
+}
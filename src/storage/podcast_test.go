@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItemPodcast(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("podcast", "", "", "http://example.com/feed.xml", "", nil)
+	podcast := &ItemPodcast{
+		ChaptersURL:  "http://example.com/chapters.json",
+		ChaptersType: "application/json+chapters",
+		Transcripts:  []Transcript{{URL: "http://example.com/t.srt", Type: "application/srt"}},
+		Persons:      []Person{{Name: "Jane", Role: "host", Group: "cast"}},
+	}
+	db.CreateItems([]Item{
+		{GUID: "ep1", FeedId: feed.Id, Title: "Episode 1", Podcast: podcast},
+		{GUID: "ep2", FeedId: feed.Id, Title: "Episode 2"},
+	})
+
+	item := getItem(db, "ep1")
+	have, _ := db.GetItemPodcast(item.Id)
+	if !reflect.DeepEqual(have, podcast) {
+		t.Fatalf("invalid podcast\nhave: %#v\nwant: %#v", have, podcast)
+	}
+	if _, err := db.GetItemPodcast(getItem(db, "ep2").Id); err != ErrNotFound {
+		t.Fatalf("expected no podcast metadata, got %v", err)
+	}
+
+	chapters := []Chapter{{Start: 0, Title: "Intro"}, {Start: 60, Title: "Main"}}
+	if err := db.SetItemChapters(item.Id, chapters); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := db.GetItemPodcast(item.Id); !reflect.DeepEqual(have.Chapters, chapters) {
+		t.Fatalf("invalid chapters: %#v", have.Chapters)
+	}
+}
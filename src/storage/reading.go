@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"time"
+)
+
+// ReadingDay is the number of items read on a day, see Item.ReadAt.
+type ReadingDay struct {
+	Day  string `json:"day"`
+	Read int64  `json:"read"`
+}
+
+// FeedReading is the number of items of a feed read over a period.
+type FeedReading struct {
+	FeedId int64 `json:"feed_id"`
+	Read   int64 `json:"read"`
+}
+
+// readingCond narrows the read items down to the folder (with its
+// subfolders, nil for all feeds) and to the days since the given one.
+func readingCond(folderId *int64, since time.Time) (string, []interface{}) {
+	y, m, d := since.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, since.Location()).UTC()
+	cond := "i.read_at >= strftime('%Y-%m-%d %H:%M:%f', ?)"
+	args := []interface{}{start}
+	if folderId != nil {
+		cond += ` and i.feed_id in (
+			with recursive ` + folderAncestors + `
+			select f.id from feeds f
+			join folder_ancestors a on a.folder_id = f.folder_id
+			where a.ancestor_id = ?
+		)`
+		args = append(args, *folderId)
+	}
+	return cond, args
+}
+
+// ReadingHistory returns the number of items read per day (in the
+// user's time zone, see Location) since the given one, the oldest first.
+// The days without any are left out.
+func (s *Storage) ReadingHistory(folderId *int64, since time.Time) ([]ReadingDay, error) {
+	result := make([]ReadingDay, 0)
+	loc, err := s.Location()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	cond, args := readingCond(folderId, since.In(loc))
+	// grouped here rather than in SQL, sqlite only knows of UTC and
+	// of the server's local time
+	rows, err := s.db.Query(`
+		select i.read_at
+		from items i
+		where `+cond+`
+		order by i.read_at`,
+		args...,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var readAt time.Time
+		if err := rows.Scan(&readAt); err != nil {
+			return nil, err
+		}
+		day := readAt.In(loc).Format("2006-01-02")
+		if n := len(result); n > 0 && result[n-1].Day == day {
+			result[n-1].Read++
+		} else {
+			result = append(result, ReadingDay{Day: day, Read: 1})
+		}
+	}
+	return result, wrapError(rows.Err())
+}
+
+// ReadingByFeed returns the number of items read per feed since
+// the given day, the most read feeds first.
+func (s *Storage) ReadingByFeed(folderId *int64, since time.Time) ([]FeedReading, error) {
+	result := make([]FeedReading, 0)
+	loc, err := s.Location()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	cond, args := readingCond(folderId, since.In(loc))
+	rows, err := s.db.Query(`
+		select i.feed_id, count(*) as n
+		from items i
+		where `+cond+`
+		group by i.feed_id
+		order by n desc, i.feed_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r FeedReading
+		if err := rows.Scan(&r.FeedId, &r.Read); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
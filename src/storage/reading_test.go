@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItemReadAt(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	readAt := func(guid string) *time.Time {
+		item, _ := db.GetItem(getItem(db, guid).Id)
+		return item.ReadAt
+	}
+
+	// the items in testItemsSetup are marked read behind the user's back
+	if at := readAt("item112"); at != nil {
+		t.Errorf("want no read_at, have %v", at)
+	}
+
+	item111 := getItem(db, "item111").Id
+	db.UpdateItemStatus(item111, READ)
+	first := readAt("item111")
+	if first == nil || time.Since(*first) > time.Minute {
+		t.Fatalf("want read_at set to now, have %v", first)
+	}
+	db.UpdateItemStatus(item111, STARRED)
+	if at := readAt("item111"); at == nil || !at.Equal(*first) {
+		t.Errorf("want read_at kept when starring, have %v", at)
+	}
+	db.UpdateItemStatus(item111, UNREAD)
+	if at := readAt("item111"); at != nil {
+		t.Errorf("want read_at cleared, have %v", at)
+	}
+
+	// snoozing isn't reading
+	item121 := getItem(db, "item121").Id
+	if err := db.SnoozeItem(item121, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if at := readAt("item121"); at != nil {
+		t.Errorf("want no read_at for a snoozed item, have %v", at)
+	}
+
+	db.MarkItemsRead(MarkFilter{FeedID: &scope.feed01.Id})
+	if readAt("item011") == nil || readAt("item012") != nil {
+		t.Errorf("want read_at set for the unread items only")
+	}
+
+	items, _ := db.ListItems(ItemFilter{SortBy: SortRead}, 10, true, false)
+	if len(items) != 1 || items[0].GUID != "item011" || items[0].ReadAt == nil {
+		t.Errorf("unexpected read items: %#v", items)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	history, _ := db.ReadingHistory(nil, time.Now().AddDate(0, 0, -6))
+	if len(history) != 1 || history[0].Day != today || history[0].Read != 1 {
+		t.Errorf("unexpected history: %#v", history)
+	}
+	if history, _ := db.ReadingHistory(&scope.folder1.Id, time.Now()); len(history) != 0 {
+		t.Errorf("want no history in the folder, have %#v", history)
+	}
+	byFeed, _ := db.ReadingByFeed(nil, time.Now())
+	if len(byFeed) != 1 || byFeed[0].FeedId != scope.feed01.Id || byFeed[0].Read != 1 {
+		t.Errorf("unexpected reading by feed: %#v", byFeed)
+	}
+}
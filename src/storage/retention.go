@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Retention is how long the read items of a feed are kept: those both
+// older than Days and beyond the newest Items are deleted by
+// DeleteOldItems, a zero field doesn't keep any (so {Days: 90} keeps
+// 90 days of read items, {Items: 500} the last 500 of them).
+// Starred, snoozed and tagged items are always kept.
+//
+// A feed without a retention follows the "retention" setting, and
+// without either the read items are subject to the built-in rules
+// of DeleteOldItems like the unread ones.
+type Retention struct {
+	Days  int `json:"days,omitempty"`
+	Items int `json:"items,omitempty"`
+}
+
+func (r Retention) IsZero() bool {
+	return r.Days == 0 && r.Items == 0
+}
+
+func ValidateRetention(field string, r Retention) error {
+	if r.Days < 0 || r.Items < 0 {
+		return &ValidationError{field, "days and items must not be negative"}
+	}
+	return nil
+}
+
+// parseRetention returns nil for an empty or zero retention.
+func parseRetention(value string) (*Retention, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var r Retention
+	if err := json.Unmarshal([]byte(value), &r); err != nil {
+		return nil, err
+	}
+	if r.IsZero() {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// UpdateFeedRetention sets the retention of the feed's read items,
+// nil (or a zero retention) makes it follow the "retention" setting.
+func (s *Storage) UpdateFeedRetention(feedId int64, r *Retention) error {
+	return s.UpdateFeed(feedId, FeedUpdate{SetRetention: true, Retention: r})
+}
+
+// retentionValue validates the feed's retention and encodes it
+// for the retention column, empty if it follows the setting.
+func retentionValue(r *Retention) (string, error) {
+	if r == nil || r.IsZero() {
+		return "", nil
+	}
+	if err := ValidateRetention("retention", *r); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// defaultRetention returns the "retention" setting, nil if not set.
+func (s *Storage) defaultRetention() (*Retention, error) {
+	val, err := s.GetSettingsValue("retention")
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return parseRetention(string(data))
+}
+
+// feedRetentions returns the retention of each feed which has one,
+// either its own or the default.
+func (s *Storage) feedRetentions() (map[int64]Retention, error) {
+	fallback, err := s.defaultRetention()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`select id, retention from feeds`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	result := make(map[int64]Retention)
+	for rows.Next() {
+		var feedId int64
+		var value string
+		if err := rows.Scan(&feedId, &value); err != nil {
+			return nil, wrapError(err)
+		}
+		r, err := parseRetention(value)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			result[feedId] = *r
+		} else if fallback != nil {
+			result[feedId] = *fallback
+		}
+	}
+	return result, wrapError(rows.Err())
+}
+
+// purgeReadItems deletes the read items of the feed
+// which aren't kept by the retention.
+func (s *Storage) purgeReadItems(feedId int64, r Retention, now time.Time) (int64, error) {
+	cutoff := now.UTC()
+	if r.Days > 0 {
+		cutoff = cutoff.AddDate(0, 0, -r.Days)
+	}
+	result, err := s.db.Exec(`
+		delete from items
+		where id in (
+			select i.id
+			from items i
+			where i.feed_id = ? and i.status = ?
+			order by date desc
+			limit -1 offset ?
+		) and date_arrived < ? and snoozed_until is null
+		and id not in (select item_id from item_tags)
+		and id not in (select item_id from item_notes)
+	`, feedId, READ, r.Items, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purging read items of feed %d: %w", feedId, err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	db := testDB()
+	now := time.Now().UTC()
+	createItems := func(feed *Feed, prefix string, n int, status ItemStatus, arrived time.Time) {
+		items := make([]Item, n)
+		for i := range items {
+			guid := prefix + strconv.Itoa(i)
+			items[i] = Item{GUID: guid, FeedId: feed.Id, Title: guid, Date: now.Add(time.Duration(i) * time.Minute)}
+		}
+		db.CreateItems(items)
+		db.db.Exec(`update items set status = ?, date_arrived = ? where guid like ?`, status, arrived, prefix+"%")
+	}
+	count := func(feed *Feed) int {
+		items, _ := db.ListItems(ItemFilter{FeedID: &feed.Id}, 1000, false, false)
+		return len(items)
+	}
+
+	// the last 2 read items
+	byCount, _ := db.CreateFeed("count", "", "", "http://test.com/count.xml", "", nil)
+	createItems(byCount, "count-read", 5, READ, now)
+	createItems(byCount, "count-starred", 1, STARRED, now)
+	createItems(byCount, "count-unread", 1, UNREAD, now)
+	if err := db.UpdateFeedRetention(byCount.Id, &Retention{Items: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// longer than the built-in rules
+	byAge, _ := db.CreateFeed("age", "", "", "http://test.com/age.xml", "", nil)
+	createItems(byAge, "age-read", itemsKeepSize+10, READ, now.AddDate(0, 0, -itemsKeepDays-10))
+	if err := db.UpdateFeedRetention(byAge.Id, &Retention{Days: 365}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the setting
+	byDefault, _ := db.CreateFeed("default", "", "", "http://test.com/default.xml", "", nil)
+	createItems(byDefault, "default-old", 3, READ, now.AddDate(0, 0, -20))
+	createItems(byDefault, "default-new", 1, READ, now)
+	createItems(byDefault, "default-unread", 1, UNREAD, now.AddDate(0, 0, -20))
+	if err := db.UpdateSettings(map[string]interface{}{"retention": map[string]interface{}{"days": 10}}); err != nil {
+		t.Fatal("setting not saved")
+	}
+
+	db.DeleteOldItems()
+	if have := count(byCount); have != 4 {
+		t.Errorf("want 2 read, the starred and the unread items kept, have %d", have)
+	}
+	if have := count(byAge); have != itemsKeepSize+10 {
+		t.Errorf("want the read items kept for a year, have %d", have)
+	}
+	if have := count(byDefault); have != 2 {
+		t.Errorf("want the recent read and the unread items kept, have %d", have)
+	}
+
+	if feed, _ := db.GetFeed(byCount.Id); feed.Retention == nil || *feed.Retention != (Retention{Items: 2}) {
+		t.Errorf("unexpected retention: %#v", feed.Retention)
+	}
+	if err := db.UpdateFeedRetention(byCount.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(byCount.Id); feed.Retention != nil {
+		t.Errorf("retention not cleared: %#v", feed.Retention)
+	}
+
+	var validationErr *ValidationError
+	if err := db.UpdateFeedRetention(byCount.Id, &Retention{Days: -1}); !errors.As(err, &validationErr) {
+		t.Errorf("want a validation error, have %v", err)
+	}
+	if db.UpdateSettings(map[string]interface{}{"retention": map[string]interface{}{"items": -1}}) == nil {
+		t.Error("invalid setting saved")
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/nkanaev/yarr/src/content/rewrite"
+)
+
+// FeedRule rewrites the contents of the items of a feed as they're
+// stored, see package rewrite. The rules of a feed are applied by
+// position, the extract ones first.
+type FeedRule struct {
+	Id          int64  `json:"id"`
+	FeedId      int64  `json:"feed_id"`
+	Kind        string `json:"kind"`
+	Selector    string `json:"selector,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Position    int64  `json:"position"`
+}
+
+// Rewrite returns the rule to apply.
+func (r FeedRule) Rewrite() rewrite.Rule {
+	return rewrite.Rule{
+		Kind:        rewrite.Kind(r.Kind),
+		Selector:    r.Selector,
+		Pattern:     r.Pattern,
+		Replacement: r.Replacement,
+	}
+}
+
+func validateFeedRule(r FeedRule) error {
+	if err := r.Rewrite().Validate(); err != nil {
+		return &ValidationError{"rule", err.Error()}
+	}
+	return nil
+}
+
+func (s *Storage) ListFeedRules(feedId int64) ([]FeedRule, error) {
+	result := make([]FeedRule, 0)
+	rows, err := s.db.Query(`
+		select id, feed_id, kind, selector, pattern, replacement, position
+		from feed_rules
+		where feed_id = ?
+		order by position, id`,
+		feedId,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r FeedRule
+		if err := rows.Scan(&r.Id, &r.FeedId, &r.Kind, &r.Selector, &r.Pattern, &r.Replacement, &r.Position); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// CreateFeedRule adds the rule after the other rules of the feed,
+// ErrConstraint is returned if the feed doesn't exist.
+func (s *Storage) CreateFeedRule(r FeedRule) (*FeedRule, error) {
+	if err := validateFeedRule(r); err != nil {
+		return nil, err
+	}
+	err := s.db.QueryRow(`
+		insert into feed_rules (feed_id, kind, selector, pattern, replacement, position)
+		values (?, ?, ?, ?, ?, (select ifnull(max(position) + 1, 0) from feed_rules where feed_id = ?))
+		returning id, position`,
+		r.FeedId, r.Kind, r.Selector, r.Pattern, r.Replacement, r.FeedId,
+	).Scan(&r.Id, &r.Position)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &r, nil
+}
+
+// UpdateFeedRule changes the rule (and its position), not its feed.
+func (s *Storage) UpdateFeedRule(r FeedRule) error {
+	if err := validateFeedRule(r); err != nil {
+		return err
+	}
+	return s.execOne(`
+		update feed_rules
+		set kind = ?, selector = ?, pattern = ?, replacement = ?, position = ?
+		where id = ?`,
+		r.Kind, r.Selector, r.Pattern, r.Replacement, r.Position, r.Id,
+	)
+}
+
+func (s *Storage) DeleteFeedRule(id int64) error {
+	return s.execOne(`delete from feed_rules where id = ?`, id)
+}
+
+// StoredItemUpdates returns when the stored items of the feed with the
+// given guids were last updated, the zero time if they never were.
+// The guids of the items not stored yet are left out.
+func (s *Storage) StoredItemUpdates(feedId int64, guids []string) (map[string]time.Time, error) {
+	result := make(map[string]time.Time, len(guids))
+	for start := 0; start < len(guids); start += 500 {
+		batch := guids[start:]
+		if len(batch) > 500 {
+			batch = batch[:500]
+		}
+		args := []interface{}{feedId}
+		for _, guid := range batch {
+			args = append(args, guid)
+		}
+		rows, err := s.db.Query(`
+			select guid, date_updated from items
+			where feed_id = ? and guid in (`+placeholders(len(batch))+`)`,
+			args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var guid string
+			var updated *time.Time
+			if err := rows.Scan(&guid, &updated); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[guid] = time.Time{}
+			if updated != nil {
+				result[guid] = *updated
+			}
+		}
+		rows.Close()
+	}
+	return result, nil
+}
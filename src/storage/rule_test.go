@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFeedRules(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+
+	strip, err := db.CreateFeedRule(FeedRule{FeedId: feed.Id, Kind: "strip", Selector: "div.ads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extract, err := db.CreateFeedRule(FeedRule{FeedId: feed.Id, Kind: "extract", Selector: "article"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strip.Position != 0 || extract.Position != 1 {
+		t.Errorf("want the positions 0 and 1, have %d and %d", strip.Position, extract.Position)
+	}
+
+	for _, r := range []FeedRule{
+		{FeedId: feed.Id, Kind: "scrape", Selector: "article"},
+		{FeedId: feed.Id, Kind: "strip"},
+		{FeedId: feed.Id, Kind: "strip", Selector: "div["},
+		{FeedId: feed.Id, Kind: "replace", Pattern: "(unclosed"},
+	} {
+		if _, err := db.CreateFeedRule(r); err == nil {
+			t.Errorf("%#v: want a validation error", r)
+		} else if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("%#v: want a validation error, have %v", r, err)
+		}
+	}
+	if _, err := db.CreateFeedRule(FeedRule{FeedId: 999, Kind: "strip", Selector: "div"}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("want ErrConstraint, have %v", err)
+	}
+
+	strip.Position = 2
+	strip.Selector = "div.ads, aside"
+	if err := db.UpdateFeedRule(*strip); err != nil {
+		t.Fatal(err)
+	}
+	rules, _ := db.ListFeedRules(feed.Id)
+	if len(rules) != 2 || rules[0].Id != extract.Id || rules[1].Selector != "div.ads, aside" {
+		t.Errorf("unexpected rules: %#v", rules)
+	}
+
+	if err := db.DeleteFeedRule(extract.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteFeedRule(extract.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	if _, err := db.DeleteFeed(feed.Id); err != nil {
+		t.Fatal(err)
+	}
+	if rules, _ := db.ListFeedRules(feed.Id); len(rules) != 0 {
+		t.Errorf("want the rules deleted with the feed, have %#v", rules)
+	}
+}
+
+func TestStoredItemUpdates(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	db.CreateItems([]Item{
+		{GUID: "item1", FeedId: feed.Id, Title: "item1", Date: time.Now()},
+		{GUID: "item2", FeedId: feed.Id, Title: "item2", Date: time.Now(), DateUpdated: &updated},
+	})
+
+	have, err := db.StoredItemUpdates(feed.Id, []string{"item1", "item2", "item3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 2 || !have["item1"].IsZero() || !have["item2"].Equal(updated) {
+		t.Errorf("unexpected updates: %#v", have)
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SavedSearch is a named item filter listed along with the feeds
+// (a "smart feed"): the items matching Query (see ItemFilter.Search),
+// limited to FeedId, FolderId, Tag and Status if set.
+type SavedSearch struct {
+	Id       int64       `json:"id"`
+	Title    string      `json:"title"`
+	Query    string      `json:"query"`
+	SearchIn string      `json:"search_in,omitempty"`
+	FeedId   *int64      `json:"feed_id"`
+	FolderId *int64      `json:"folder_id"`
+	Tag      *string     `json:"tag"`
+	Status   *ItemStatus `json:"status"`
+}
+
+type SavedSearchStat struct {
+	SearchId     int64 `json:"search_id"`
+	UnreadCount  int64 `json:"unread"`
+	StarredCount int64 `json:"starred"`
+}
+
+// Narrow adds the criteria of the saved search to the filter,
+// the search query is added to the filter's one.
+func (ss SavedSearch) Narrow(filter ItemFilter) ItemFilter {
+	if ss.Query != "" {
+		search := ss.Query
+		if filter.Search != nil && *filter.Search != "" {
+			search += " " + *filter.Search
+		}
+		filter.Search = &search
+	}
+	if ss.SearchIn != "" {
+		filter.SearchField = ss.SearchIn
+	}
+	if ss.FeedId != nil {
+		filter.FeedID = ss.FeedId
+	}
+	if ss.FolderId != nil {
+		filter.FolderID = ss.FolderId
+	}
+	if ss.Tag != nil {
+		filter.Tag = ss.Tag
+	}
+	if ss.Status != nil {
+		filter.Status = ss.Status
+	}
+	return filter
+}
+
+func validateSavedSearch(ss SavedSearch) error {
+	if err := ValidateTitle("title", ss.Title); err != nil {
+		return err
+	}
+	if len(ss.Query) > MaxTitleLength {
+		return &ValidationError{"query", fmt.Sprintf("must be at most %d characters", MaxTitleLength)}
+	}
+	switch ss.SearchIn {
+	case "", SearchTitle, SearchAuthor, SearchContent, SearchNote:
+	default:
+		return &ValidationError{"search_in", "must be title, author, content or note"}
+	}
+	return nil
+}
+
+const savedSearchCols = `id, title, query, search_in, feed_id, folder_id, tag, status`
+
+func (ss *SavedSearch) dest() []interface{} {
+	return []interface{}{&ss.Id, &ss.Title, &ss.Query, &ss.SearchIn, &ss.FeedId, &ss.FolderId, &ss.Tag, &ss.Status}
+}
+
+func (s *Storage) ListSavedSearches() ([]SavedSearch, error) {
+	result := make([]SavedSearch, 0)
+	rows, err := s.db.Query(`select ` + savedSearchCols + ` from saved_searches order by title collate nocase`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ss SavedSearch
+		if err := rows.Scan(ss.dest()...); err != nil {
+			return nil, err
+		}
+		result = append(result, ss)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetSavedSearch(id int64) (*SavedSearch, error) {
+	var ss SavedSearch
+	err := s.db.QueryRow(`select `+savedSearchCols+` from saved_searches where id = ?`, id).Scan(ss.dest()...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// CreateSavedSearch stores the search, ErrConstraint is returned
+// if the feed or folder doesn't exist.
+func (s *Storage) CreateSavedSearch(ss SavedSearch) (*SavedSearch, error) {
+	if err := validateSavedSearch(ss); err != nil {
+		return nil, err
+	}
+	err := s.db.QueryRow(`
+		insert into saved_searches (title, query, search_in, feed_id, folder_id, tag, status)
+		values (?, ?, ?, ?, ?, ?, ?)
+		returning id`,
+		ss.Title, ss.Query, ss.SearchIn, ss.FeedId, ss.FolderId, ss.Tag, ss.Status,
+	).Scan(&ss.Id)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &ss, nil
+}
+
+// UpdateSavedSearch replaces the title and the criteria of the search.
+func (s *Storage) UpdateSavedSearch(ss SavedSearch) error {
+	if err := validateSavedSearch(ss); err != nil {
+		return err
+	}
+	return s.execOne(`
+		update saved_searches
+		set title = ?, query = ?, search_in = ?, feed_id = ?, folder_id = ?, tag = ?, status = ?
+		where id = ?`,
+		ss.Title, ss.Query, ss.SearchIn, ss.FeedId, ss.FolderId, ss.Tag, ss.Status, ss.Id,
+	)
+}
+
+func (s *Storage) DeleteSavedSearch(id int64) error {
+	return s.execOne(`delete from saved_searches where id = ?`, id)
+}
+
+// SavedSearchStats returns the number of unread and starred items
+// matching each saved search.
+func (s *Storage) SavedSearchStats() ([]SavedSearchStat, error) {
+	searches, err := s.ListSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]SavedSearchStat, 0)
+	unread, starred := UNREAD, STARRED
+	for _, ss := range searches {
+		stat := SavedSearchStat{SearchId: ss.Id}
+		if ss.Status == nil || *ss.Status == UNREAD {
+			count, err := s.CountItems(ss.Narrow(ItemFilter{Status: &unread}))
+			if err != nil {
+				return nil, err
+			}
+			stat.UnreadCount = int64(count)
+		}
+		if ss.Status == nil || *ss.Status == STARRED {
+			count, err := s.CountItems(ss.Narrow(ItemFilter{Status: &starred}))
+			if err != nil {
+				return nil, err
+			}
+			stat.StarredCount = int64(count)
+		}
+		result = append(result, stat)
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSavedSearches(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	db.SyncSearch()
+
+	byTitle, err := db.CreateSavedSearch(SavedSearch{Title: "titles", Query: "title11", SearchIn: SearchTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unread := UNREAD
+	inFolder, err := db.CreateSavedSearch(SavedSearch{Title: "folder", FolderId: &scope.folder1.Id, Status: &unread})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have := getItemGuids(db.ListItems(byTitle.Narrow(ItemFilter{}), 10, false, false))
+	if want := []string{"item111", "item112", "item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	// narrowing the search further
+	search := "title113"
+	have = getItemGuids(db.ListItems(byTitle.Narrow(ItemFilter{Search: &search}), 10, false, false))
+	if want := []string{"item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	have = getItemGuids(db.ListItems(inFolder.Narrow(ItemFilter{}), 10, false, false))
+	if want := []string{"item111", "item121"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	wantStats := []SavedSearchStat{
+		{SearchId: inFolder.Id, UnreadCount: 2},
+		{SearchId: byTitle.Id, UnreadCount: 1, StarredCount: 1},
+	}
+	if haveStats, _ := db.SavedSearchStats(); !reflect.DeepEqual(haveStats, wantStats) {
+		t.Errorf("want %v, have %v", wantStats, haveStats)
+	}
+
+	byTitle.Title = "renamed"
+	if err := db.UpdateSavedSearch(*byTitle); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := db.GetSavedSearch(byTitle.Id); !reflect.DeepEqual(saved, byTitle) {
+		t.Errorf("want %#v, have %#v", byTitle, saved)
+	}
+
+	var validationErr *ValidationError
+	if _, err := db.CreateSavedSearch(SavedSearch{Title: " "}); !errors.As(err, &validationErr) {
+		t.Errorf("want a validation error, have %v", err)
+	}
+	missing := int64(100500)
+	if _, err := db.CreateSavedSearch(SavedSearch{Title: "missing", FeedId: &missing}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("want ErrConstraint, have %v", err)
+	}
+
+	// the searches go along with their folder
+	db.DeleteFolder(scope.folder1.Id)
+	if _, err := db.GetSavedSearch(inFolder.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	if err := db.DeleteSavedSearch(byTitle.Id); err != nil {
+		t.Fatal(err)
+	}
+	if searches, _ := db.ListSavedSearches(); len(searches) != 0 {
+		t.Errorf("unexpected searches: %#v", searches)
+	}
+}
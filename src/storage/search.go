@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+)
+
+// SearchMatch tells why an item matched a search query.
+type SearchMatch struct {
+	// html-escaped excerpt of the text with the matched terms
+	// wrapped in <mark> tags
+	Snippet string        `json:"snippet"`
+	Offsets []MatchOffset `json:"offsets"`
+	// relevance of the item, set by SearchItems
+	Rank float64 `json:"rank,omitempty"`
+}
+
+// MatchOffset locates a matched term in the indexed (plain) text
+// of the item's title, author or content.
+type MatchOffset struct {
+	Field  string `json:"field"`
+	Term   int    `json:"term"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+var searchColumns = []string{"title", "author", "content", "note"}
+
+// weights of the columns passed to bm25(), a match in
+// the title or the note counts as much as two in the content
+const searchWeights = "2.0, 1.0, 1.0, 2.0"
+
+// markers wrapping the matched terms in the output of snippet() and
+// highlight(), replaced with html tags once the rest has been escaped
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// matchColumns selects the snippet and the highlighted text of each
+// column of the matching row, see scanMatch.
+const matchColumns = `
+	snippet(search, -1, char(2), char(3), '…', 16),
+	highlight(search, 0, char(2), char(3)),
+	highlight(search, 1, char(2), char(3)),
+	highlight(search, 2, char(2), char(3)),
+	highlight(search, 3, char(2), char(3))`
+
+// Fields a search can be limited to, see ItemFilter.SearchField.
+const (
+	SearchTitle   = "title"
+	SearchAuthor  = "author"
+	SearchContent = "content"
+	SearchNote    = "note"
+)
+
+// searchQuery turns the user's input into a full-text query
+// matching words starting with each of the given ones,
+// in the given field or in any of them if it's empty.
+// The words are quoted for their punctuation not to be
+// taken for the query syntax.
+func searchQuery(search, field string) string {
+	prefix := ""
+	if field == SearchTitle || field == SearchAuthor || field == SearchContent || field == SearchNote {
+		prefix = field + ":"
+	}
+	words := strings.Fields(search)
+	terms := make([]string, len(words))
+	for idx, word := range words {
+		terms[idx] = prefix + `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchMatches returns the snippets and term offsets of the given
+// items for the search query, keyed by item id.
+func (s *Storage) SearchMatches(ids []int64, search, field string) (map[int64]*SearchMatch, error) {
+	result := make(map[int64]*SearchMatch)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	qmarks := make([]string, len(ids))
+	args := []interface{}{searchQuery(search, field)}
+	for i, id := range ids {
+		qmarks[i] = "?"
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		select i.id, %s
+		from search
+		join items i on i.search_rowid = search.rowid
+		where search match ? and i.id in (%s)
+	`, matchColumns, strings.Join(qmarks, ",")), args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	words := strings.Fields(search)
+	for rows.Next() {
+		var id int64
+		match, err := scanMatch(rows, words, &id)
+		if err != nil {
+			return nil, err
+		}
+		result[id] = match
+	}
+	return result, wrapError(rows.Err())
+}
+
+// scanMatch reads the columns selected by matchColumns, preceded by
+// the given destinations.
+func scanMatch(rows *sql.Rows, words []string, dest ...interface{}) (*SearchMatch, error) {
+	var snippet string
+	var highlights [4]sql.NullString
+	dest = append(dest, &snippet)
+	for i := range highlights {
+		dest = append(dest, &highlights[i])
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	snippet = html.EscapeString(snippet)
+	snippet = strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(snippet)
+	offsets := make([]MatchOffset, 0)
+	for i, text := range highlights {
+		offsets = append(offsets, parseHighlight(searchColumns[i], text.String, words)...)
+	}
+	return &SearchMatch{Snippet: snippet, Offsets: offsets}, nil
+}
+
+// parseHighlight locates the terms marked by highlight() in the text
+// of the field, the offsets are in bytes of the text without the marks.
+// The term is the index of the first query word the marked one starts with.
+func parseHighlight(field, text string, words []string) []MatchOffset {
+	result := make([]MatchOffset, 0)
+	offset := 0
+	for {
+		start := strings.Index(text, matchStart)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start:], matchEnd)
+		if end < 0 {
+			break
+		}
+		marked := text[start+len(matchStart) : start+end]
+		offset += start
+		term := 0
+		for i, word := range words {
+			if strings.HasPrefix(strings.ToLower(marked), strings.ToLower(word)) {
+				term = i
+				break
+			}
+		}
+		result = append(result, MatchOffset{
+			Field:  field,
+			Term:   term,
+			Offset: offset,
+			Length: len(marked),
+		})
+		offset += len(marked)
+		text = text[start+end+len(matchEnd):]
+	}
+	return result
+}
+
+// SearchItems returns the items matching filter.Search, the most
+// relevant first, along with their snippets (see SearchMatch).
+// The rest of the filter narrows the results down, except for the
+// cursors: the results are paged with offset instead.
+func (s *Storage) SearchItems(filter ItemFilter, limit, offset int) ([]Item, error) {
+	if filter.Search == nil {
+		return nil, &ValidationError{"search", "must not be empty"}
+	}
+	search, field := *filter.Search, filter.SearchField
+	filter.Search, filter.After, filter.SinceID, filter.MaxID = nil, nil, nil, nil
+	predicate, args := listQueryPredicate(filter, true)
+	if offset < 0 {
+		offset = 0
+	}
+
+	// bm25() is lower for the more relevant rows
+	args = append([]interface{}{searchQuery(search, field)}, args...)
+	rows, err := s.db.Query(fmt.Sprintf(`
+		select i.id, -bm25(search, %s), %s
+		from search
+		join items i on i.search_rowid = search.rowid
+		where search match ? and %s
+		order by bm25(search, %s), i.id desc
+		limit ? offset ?
+	`, searchWeights, matchColumns, predicate, searchWeights), append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(search)
+	ids := make([]int64, 0)
+	matches := make(map[int64]*SearchMatch)
+	for rows.Next() {
+		var id int64
+		var rank float64
+		match, err := scanMatch(rows, words, &id, &rank)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		match.Rank = rank
+		ids = append(ids, id)
+		matches[id] = match
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []Item{}, nil
+	}
+
+	items, err := s.ListItems(ItemFilter{IDs: &ids}, len(ids), true, false)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[int64]Item, len(ids))
+	for _, item := range items {
+		byId[item.Id] = item
+	}
+	result := make([]Item, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byId[id]
+		if !ok {
+			continue
+		}
+		item.Match = matches[id]
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// indexItem adds the item's text and note to the full-text index. Its
+// previous entry, if any, is removed by the upd_item_search trigger when the
+// title or content change, by del_item_search on delete, and by SetItemNote.
+func indexItem(db execer, id int64, title, author, content string) error {
+	res, err := db.Exec(
+		`insert into search (title, author, content, note)
+		values (?, ?, ?, (select note from item_notes where item_id = ?))`,
+		title, author, htmlutil.ExtractText(content), id,
+	)
+	if err != nil {
+		return err
+	}
+	rowId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`update items set search_rowid = ? where id = ?`, rowId, id)
+	return err
+}
+
+// RebuildSearch recreates the full-text index from scratch, e.g. after
+// it got corrupted, and returns the number of indexed items.
+func (s *Storage) RebuildSearch() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+		drop table if exists search;
+		create virtual table search using fts5(title, author, content, note);
+		update items set search_rowid = null;
+	`)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.SyncSearch()
+
+	var count int
+	err = s.db.QueryRow(`select count(*) from items where search_rowid is not null`).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSearchMatches(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.CreateItems([]Item{
+		{GUID: "1", FeedId: feed.Id, Title: "Weekly news", Content: "<p>Nothing about <b>cats</b> & dogs here</p>", Date: time.Now()},
+		{GUID: "2", FeedId: feed.Id, Title: "Other", Content: "<p>unrelated</p>", Date: time.Now()},
+	})
+	db.SyncSearch()
+
+	search := "cat"
+	items, _ := db.ListItems(ItemFilter{Search: &search}, 10, true, false)
+	if len(items) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(items))
+	}
+	matches, _ := db.SearchMatches([]int64{items[0].Id}, search, "")
+	match := matches[items[0].Id]
+	if match == nil {
+		t.Fatal("no match returned")
+	}
+	if match.Snippet != "Nothing about <mark>cats</mark> &amp; dogs here" {
+		t.Errorf("unexpected snippet: %q", match.Snippet)
+	}
+	want := []MatchOffset{{Field: "content", Term: 0, Offset: 14, Length: 4}}
+	if !reflect.DeepEqual(match.Offsets, want) {
+		t.Errorf("unexpected offsets: %#v", match.Offsets)
+	}
+}
+
+func TestSearchIndexMaintenance(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	published := time.Now().Add(-time.Hour)
+	updated := time.Now()
+	db.CreateItems([]Item{{GUID: "1", FeedId: feed.Id, Title: "first", Content: "apples", Date: published}})
+
+	count := func(search string) int {
+		items, _ := db.ListItems(ItemFilter{Search: &search}, 10, true, false)
+		return len(items)
+	}
+	if count("apples") != 1 {
+		t.Fatal("new item not indexed")
+	}
+
+	db.CreateItems([]Item{{GUID: "1", FeedId: feed.Id, Title: "first", Content: "oranges", Date: published, DateUpdated: &updated}})
+	if count("apples") != 0 || count("oranges") != 1 {
+		t.Fatal("edited item not re-indexed")
+	}
+
+	n, err := db.RebuildSearch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || count("oranges") != 1 {
+		t.Fatalf("index not rebuilt, %d items indexed", n)
+	}
+
+	db.DeleteFeed(feed.Id)
+	var rows int
+	db.db.QueryRow(`select count(*) from search`).Scan(&rows)
+	if rows != 0 {
+		t.Fatalf("expected index to be empty, got %d rows", rows)
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	db := testDB()
+	folder, _ := db.CreateFolder("archive")
+	feed1, _ := db.CreateFeed("feed1", "", "", "http://example.com/feed1.xml", "", &folder.Id)
+	feed2, _ := db.CreateFeed("feed2", "", "", "http://example.com/feed2.xml", "", nil)
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "title", FeedId: feed1.Id, Title: "golang release", Content: "notes", Date: now},
+		{GUID: "content", FeedId: feed1.Id, Title: "weekly", Content: "about golang", Date: now},
+		{GUID: "other", FeedId: feed2.Id, Title: "golang", Content: "", Date: now},
+	})
+	db.UpdateItemStatus(getItem(db, "content").Id, STARRED)
+
+	search := "golang"
+	starred := STARRED
+	cases := []struct {
+		filter ItemFilter
+		want   []string
+	}{
+		{ItemFilter{SearchField: SearchTitle}, []string{"other", "title"}},
+		{ItemFilter{SearchField: SearchContent}, []string{"content"}},
+		{ItemFilter{FolderID: &folder.Id}, []string{"content", "title"}},
+		{ItemFilter{Status: &starred}, []string{"content"}},
+	}
+	for i, tc := range cases {
+		tc.filter.Search = &search
+		have := getItemGuids(db.ListItems(tc.filter, 10, true, false))
+		sort.Strings(have)
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("case %d: expected %v, got %v", i, tc.want, have)
+		}
+	}
+}
+
+func TestSearchItems(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+	db.CreateItems([]Item{
+		{GUID: "content", FeedId: feed.Id, Title: "Weekly news", Content: "<p>a post about go, among many other things</p>", Date: now},
+		{GUID: "title", FeedId: feed.Id, Title: "Go generics", Content: "<p>a post about go</p>", Date: now.Add(-time.Hour)},
+		{GUID: "author", FeedId: feed.Id, Title: "Release notes", Author: "Gopher", Content: "<p>nothing</p>", Date: now},
+		{GUID: "other", FeedId: feed.Id, Title: "Other", Content: "<p>unrelated</p>", Date: now},
+	})
+
+	guids := func(items []Item) []string {
+		result := make([]string, len(items))
+		for i, item := range items {
+			result[i] = item.GUID
+		}
+		return result
+	}
+
+	search := "go"
+	items, err := db.SearchItems(ItemFilter{Search: &search}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// matches in the title weigh more
+	all := guids(items)
+	if len(all) != 3 || all[0] != "title" {
+		t.Fatalf("unexpected results: %v", all)
+	}
+	if items[0].Match == nil || items[0].Match.Rank <= items[1].Match.Rank || items[0].Match.Snippet == "" {
+		t.Fatalf("unexpected match: %#v", items[0].Match)
+	}
+	if item, _ := db.GetItem(getItem(db, "author").Id); item.Author != "Gopher" {
+		t.Fatal("author not stored")
+	}
+
+	items, _ = db.SearchItems(ItemFilter{Search: &search}, 1, 1)
+	if have, want := guids(items), all[1:2]; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	items, _ = db.SearchItems(ItemFilter{Search: &search, SearchField: SearchAuthor}, 10, 0)
+	if have, want := guids(items), []string{"author"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	db.UpdateItemStatus(getItem(db, "title").Id, READ)
+	unread := UNREAD
+	items, _ = db.SearchItems(ItemFilter{Search: &search, Status: &unread}, 10, 0)
+	if have, want := guids(items), all[1:]; !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"database/sql"
 	"encoding/json"
-	"log"
+	"fmt"
+	"strings"
 )
 
 func settingsDefaults() map[string]interface{} {
@@ -12,64 +14,193 @@ func settingsDefaults() map[string]interface{} {
 		"feed_list_width":   300,
 		"item_list_width":   300,
 		"sort_newest_first": true,
+		"sort_by":           "",
 		"theme_name":        "light",
 		"theme_font":        "",
 		"theme_size":        1,
 		"refresh_rate":      0,
+		"digest":            false,
+		"archive_starred":   false,
+		// hide the copies of the items found in several feeds, see ItemFilter.CollapseDuplicates
+		"collapse_duplicates": false,
+		"default_view":        map[string]interface{}{},
+		// see Retention
+		"retention": map[string]interface{}{},
+		// days the new subscriptions stay on trial, 0 to disable, see StartFeedTrial
+		"trial_days": 0,
+		// hosts whose images, embeds and links are stripped from the content, see BlockedHosts
+		"blocked_hosts": []interface{}{},
+		// RSS-Bridge instance the feeds of the sites without any are made by, see FeedBridge
+		"rss_bridge_url": "",
+		// host -> API token of the git hosting sites, see ForgeToken
+		"forge_tokens": map[string]interface{}{},
+		// where the subscriptions without a folder land, see DefaultFolderId
+		"default_folder": "",
+		// point the feeds to the url they say they're at, see SuggestMoved
+		"auto_update_feed_links": false,
+		// IANA time zone the days start in, the server's if empty, see Location
+		"timezone": "",
 	}
 }
 
-func (s *Storage) GetSettingsValue(key string) interface{} {
-	row := s.db.QueryRow(`select val from settings where key=?`, key)
-	if row == nil {
-		return settingsDefaults()[key]
+// ValidateDefaultView checks the view the UI opens with. It overrides
+// the last used settings of the same name, an empty view keeps them.
+func ValidateDefaultView(val interface{}) error {
+	view, ok := val.(map[string]interface{})
+	if !ok {
+		return &ValidationError{"default_view", "must be an object"}
 	}
+	for key, v := range view {
+		var valid bool
+		switch key {
+		case "feed":
+			_, valid = v.(string)
+		case "filter":
+			switch v {
+			case "", "unread", "starred":
+				valid = true
+			}
+		case "sort_by":
+			switch ItemSort(fmt.Sprint(v)) {
+			case SortPublished, SortUpdated, SortArrived, SortRead:
+				_, valid = v.(string)
+			}
+		case "sort_newest_first":
+			_, valid = v.(bool)
+		default:
+			return &ValidationError{"default_view", fmt.Sprintf("unknown key %q", key)}
+		}
+		if !valid {
+			return &ValidationError{"default_view", fmt.Sprintf("invalid value of %q", key)}
+		}
+	}
+	return nil
+}
+
+// GetSettingsValue returns the value of the setting, its default if it
+// hasn't been changed.
+func (s *Storage) GetSettingsValue(key string) (interface{}, error) {
 	var val []byte
-	row.Scan(&val)
+	err := s.db.QueryRow(`select val from settings where key=?`, key).Scan(&val)
+	if err == sql.ErrNoRows {
+		return settingsDefaults()[key], nil
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
 	if len(val) == 0 {
-		return nil
+		return nil, nil
 	}
 	var valDecoded interface{}
-	if err := json.Unmarshal([]byte(val), &valDecoded); err != nil {
-		log.Print(err)
-		return nil
+	if err := json.Unmarshal(val, &valDecoded); err != nil {
+		return nil, fmt.Errorf("invalid value of the %q setting: %w", key, err)
 	}
-	return valDecoded
+	return valDecoded, nil
 }
 
-func (s *Storage) GetSettingsValueInt64(key string) int64 {
-	val := s.GetSettingsValue(key)
-	if val != nil {
-		if fval, ok := val.(float64); ok {
-			return int64(fval)
-		}
+func (s *Storage) GetSettingsValueInt64(key string) (int64, error) {
+	val, err := s.GetSettingsValue(key)
+	if err != nil {
+		return 0, err
+	}
+	switch v := val.(type) {
+	case float64:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
 	}
-	return 0
+	return 0, nil
 }
 
-func (s *Storage) GetSettings() map[string]interface{} {
+func (s *Storage) GetSettings() (map[string]interface{}, error) {
 	result := settingsDefaults()
 	rows, err := s.db.Query(`select key, val from settings;`)
 	if err != nil {
-		log.Print(err)
-		return result
+		return nil, wrapError(err)
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var key string
 		var val []byte
 		var valDecoded interface{}
 
-		rows.Scan(&key, &val)
-		if err = json.Unmarshal([]byte(val), &valDecoded); err != nil {
-			log.Print(err)
-			continue
+		if err := rows.Scan(&key, &val); err != nil {
+			return nil, wrapError(err)
+		}
+		if err = json.Unmarshal(val, &valDecoded); err != nil {
+			return nil, fmt.Errorf("invalid value of the %q setting: %w", key, err)
 		}
 		result[key] = valDecoded
 	}
-	return result
+	return result, wrapError(rows.Err())
 }
 
-func (s *Storage) UpdateSettings(kv map[string]interface{}) bool {
+// UpdateSettings validates and stores the settings, the unknown ones
+// are ignored. Nothing is stored if any of them is invalid.
+func (s *Storage) UpdateSettings(kv map[string]interface{}) error {
+	if val, ok := kv["default_view"]; ok {
+		if err := ValidateDefaultView(val); err != nil {
+			return err
+		}
+	}
+	if val, ok := kv["retention"]; ok {
+		var retention Retention
+		data, _ := json.Marshal(val)
+		if err := json.Unmarshal(data, &retention); err != nil {
+			return &ValidationError{"retention", "must be an object with days and items"}
+		}
+		if err := ValidateRetention("retention", retention); err != nil {
+			return err
+		}
+	}
+	if val, ok := kv["blocked_hosts"]; ok {
+		var hosts []string
+		data, _ := json.Marshal(val)
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return &ValidationError{"blocked_hosts", "must be a list of host names"}
+		}
+		hosts = cleanHosts(hosts)
+		if err := ValidateHosts("blocked_hosts", hosts); err != nil {
+			return err
+		}
+		kv["blocked_hosts"] = hosts
+	}
+	if val, ok := kv["rss_bridge_url"]; ok {
+		link, isString := val.(string)
+		if !isString || (link != "" && ValidateFeedLink(link) != nil) {
+			return &ValidationError{"rss_bridge_url", "must be an absolute http(s) url"}
+		}
+	}
+	if val, ok := kv["default_folder"]; ok {
+		if err := ValidateDefaultFolder(val); err != nil {
+			return err
+		}
+	}
+	if val, ok := kv["timezone"]; ok {
+		if err := ValidateTimezone(val); err != nil {
+			return err
+		}
+	}
+	if val, ok := kv["forge_tokens"]; ok {
+		var tokens map[string]string
+		data, _ := json.Marshal(val)
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			return &ValidationError{"forge_tokens", "must be an object of host names and tokens"}
+		}
+		cleaned := make(map[string]string, len(tokens))
+		hosts := make([]string, 0, len(tokens))
+		for host, token := range tokens {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if token = strings.TrimSpace(token); token != "" {
+				cleaned[host] = token
+				hosts = append(hosts, host)
+			}
+		}
+		if err := ValidateHosts("forge_tokens", hosts); err != nil {
+			return err
+		}
+		kv["forge_tokens"] = cleaned
+	}
 	defaults := settingsDefaults()
 	for key, val := range kv {
 		if defaults[key] == nil {
@@ -77,8 +208,7 @@ func (s *Storage) UpdateSettings(kv map[string]interface{}) bool {
 		}
 		valEncoded, err := json.Marshal(val)
 		if err != nil {
-			log.Print(err)
-			return false
+			return &ValidationError{key, err.Error()}
 		}
 		_, err = s.db.Exec(`
 			insert into settings (key, val) values (?, ?)
@@ -86,9 +216,37 @@ func (s *Storage) UpdateSettings(kv map[string]interface{}) bool {
 			key, valEncoded, valEncoded,
 		)
 		if err != nil {
-			log.Print(err)
-			return false
+			return wrapError(err)
 		}
 	}
-	return true
+	return nil
+}
+
+// BlockedHosts returns the hosts whose content is stripped from all feeds
+// (along with their subdomains), unless a feed is exempt from it.
+func (s *Storage) BlockedHosts() ([]string, error) {
+	val, err := s.GetSettingsValue("blocked_hosts")
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	list, _ := val.([]interface{})
+	for _, host := range list {
+		if host, ok := host.(string); ok {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// ForgeToken returns the API token set up for the git hosting site
+// (e.g. "github.com"), an empty string if there's none.
+func (s *Storage) ForgeToken(host string) (string, error) {
+	val, err := s.GetSettingsValue("forge_tokens")
+	if err != nil {
+		return "", err
+	}
+	tokens, _ := val.(map[string]interface{})
+	token, _ := tokens[strings.ToLower(host)].(string)
+	return token, nil
 }
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultView(t *testing.T) {
+	db := testDB()
+	if have, _ := db.GetSettings(); !reflect.DeepEqual(have["default_view"], map[string]interface{}{}) {
+		t.Fatalf("expected empty default view, got %#v", have)
+	}
+
+	view := map[string]interface{}{
+		"feed":              "folder:1",
+		"filter":            "unread",
+		"sort_by":           "arrived",
+		"sort_newest_first": false,
+	}
+	if err := db.UpdateSettings(map[string]interface{}{"default_view": view}); err != nil {
+		t.Fatal("failed to save a valid view")
+	}
+	if have, _ := db.GetSettings(); !reflect.DeepEqual(have["default_view"], view) {
+		t.Fatalf("unexpected default view: %#v", have)
+	}
+
+	invalid := []interface{}{
+		"unread",
+		map[string]interface{}{"filter": "read"},
+		map[string]interface{}{"sort_by": "title"},
+		map[string]interface{}{"sort_newest_first": "yes"},
+		map[string]interface{}{"theme_name": "dark"},
+	}
+	for _, val := range invalid {
+		if db.UpdateSettings(map[string]interface{}{"default_view": val, "filter": "starred"}) == nil {
+			t.Errorf("expected %#v to be rejected", val)
+		}
+	}
+	if have, _ := db.GetSettings(); have["filter"] != "" {
+		t.Fatalf("rejected update must not change other settings, got %#v", have)
+	}
+}
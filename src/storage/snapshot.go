@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"time"
+)
+
+// ItemSnapshot is a local copy of the page an item links to,
+// kept around in case the original disappears.
+type ItemSnapshot struct {
+	ItemId    int64     `json:"item_id"`
+	Content   string    `json:"-"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Storage) SaveItemSnapshot(itemId int64, content string) error {
+	_, err := s.db.Exec(`
+		insert into item_snapshots (item_id, content, size, created_at)
+		values (?, ?, ?, ?)
+		on conflict (item_id) do update set
+		 content = excluded.content,
+		 size = excluded.size,
+		 created_at = excluded.created_at
+	`, itemId, content, len(content), time.Now().UTC())
+	return wrapError(err)
+}
+
+func (s *Storage) GetItemSnapshot(itemId int64) (*ItemSnapshot, error) {
+	var snapshot ItemSnapshot
+	err := s.db.QueryRow(`
+		select item_id, content, size, created_at
+		from item_snapshots where item_id = ?
+	`, itemId).Scan(&snapshot.ItemId, &snapshot.Content, &snapshot.Size, &snapshot.CreatedAt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &snapshot, nil
+}
+
+func (s *Storage) DeleteItemSnapshot(itemId int64) error {
+	return s.execOne(`delete from item_snapshots where item_id = ?`, itemId)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import "testing"
+
+func TestItemSnapshot(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/feed.xml", "", nil)
+	db.CreateItems([]Item{{GUID: "item1", FeedId: feed.Id}})
+	item := getItem(db, "item1")
+
+	if _, err := db.GetItemSnapshot(item.Id); err != ErrNotFound {
+		t.Fatalf("expected no snapshot, got %v", err)
+	}
+	if err := db.SaveItemSnapshot(item.Id, "<p>old</p>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveItemSnapshot(item.Id, "<p>new</p>"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := db.GetItemSnapshot(item.Id)
+	if snapshot == nil || snapshot.Content != "<p>new</p>" || snapshot.Size != 10 {
+		t.Fatalf("unexpected snapshot: %#v", snapshot)
+	}
+
+	if err := db.DeleteItemSnapshot(item.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteItemSnapshot(item.Id); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	db.SaveItemSnapshot(item.Id, "<p>kept</p>")
+	db.DeleteFeed(feed.Id)
+	if _, err := db.GetItemSnapshot(item.Id); err != ErrNotFound {
+		t.Fatalf("snapshot must be deleted along with the item, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SnoozeItem marks the item as read until the given time,
+// when WakeSnoozedItems makes it unread again.
+func (s *Storage) SnoozeItem(itemId int64, until time.Time) error {
+	if !until.After(time.Now()) {
+		return &ValidationError{"until", "must be in the future"}
+	}
+	var status ItemStatus
+	err := s.db.QueryRow(`select status from items where id = ?`, itemId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status == STARRED {
+		return &ValidationError{"status", "starred items can't be snoozed"}
+	}
+	return s.execOne(
+		`update items set status = ?, snoozed_until = ?, notify_on_wake = 0 where id = ?`,
+		READ, until.UTC(), itemId,
+	)
+}
+
+// UnsnoozeItem makes the snoozed item unread right away.
+func (s *Storage) UnsnoozeItem(itemId int64) error {
+	return s.execOne(
+		`update items set status = ?, snoozed_until = null, notify_on_wake = 0 where id = ? and snoozed_until is not null`,
+		UNREAD, itemId,
+	)
+}
+
+// WakeSnoozedItems makes the items snoozed until now unread again,
+// queueing the notifications of the items held for a delivery window.
+func (s *Storage) WakeSnoozedItems(now time.Time) (int64, error) {
+	now = now.UTC()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`select id from items where snoozed_until <= ? and notify_on_wake`, now)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, wrapError(err)
+	}
+	for _, id := range ids {
+		if err := queueNotifications(tx, id, now); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(
+		`update items set status = ?, snoozed_until = null, notify_on_wake = 0 where snoozed_until <= ?`,
+		UNREAD, now,
+	)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	woken, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return woken, tx.Commit()
+}
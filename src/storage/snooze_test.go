@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnoozeItem(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+
+	item := getItem(db, "item111")
+	if err := db.SnoozeItem(item.Id, time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("expected an error for a time in the past")
+	}
+	if err := db.SnoozeItem(getItem(db, "item113").Id, time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("expected an error for a starred item")
+	}
+	if err := db.SnoozeItem(-1, time.Now().Add(time.Hour)); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if err := db.SnoozeItem(item.Id, until); err != nil {
+		t.Fatal(err)
+	}
+	snoozed, _ := db.GetItem(item.Id)
+	if snoozed.Status != READ || snoozed.SnoozedUntil == nil {
+		t.Fatalf("item not snoozed: %#v", snoozed)
+	}
+
+	have := getItemGuids(db.ListItems(ItemFilter{Snoozed: true}, 10, false, false))
+	if want := []string{"item111"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("invalid snoozed items, want %v, have %v", want, have)
+	}
+
+	if n, _ := db.WakeSnoozedItems(time.Now()); n != 0 {
+		t.Fatalf("woke %d items too early", n)
+	}
+	if n, _ := db.WakeSnoozedItems(until.Add(time.Second)); n != 1 {
+		t.Fatalf("want 1 woken item, have %d", n)
+	}
+	woken, _ := db.GetItem(item.Id)
+	if woken.Status != UNREAD || woken.SnoozedUntil != nil {
+		t.Fatalf("item not woken: %#v", woken)
+	}
+}
+
+func TestUnsnoozeItem(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+
+	item := getItem(db, "item112")
+	if err := db.UnsnoozeItem(item.Id); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound for an item not snoozed, have %v", err)
+	}
+	db.SnoozeItem(item.Id, time.Now().Add(time.Hour))
+	if err := db.UnsnoozeItem(item.Id); err != nil {
+		t.Fatal(err)
+	}
+	if item, _ := db.GetItem(item.Id); item.Status != UNREAD {
+		t.Fatalf("want unread, have %v", item.Status)
+	}
+
+	// a manual status change cancels the snooze
+	db.SnoozeItem(item.Id, time.Now().Add(time.Hour))
+	db.UpdateItemStatus(item.Id, READ)
+	if item, _ := db.GetItem(item.Id); item.SnoozedUntil != nil {
+		t.Fatal("status change didn't cancel the snooze")
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// FeedItemStates lists the guids of a feed's read and starred items.
+// Unread is the default and isn't exported.
+type FeedItemStates struct {
+	Read    []string `json:"read,omitempty"`
+	Starred []string `json:"starred,omitempty"`
+}
+
+// ItemStates maps feed links to the states of their items. Links and
+// guids are used since ids differ between databases.
+type ItemStates map[string]*FeedItemStates
+
+// ExportItemStates returns the state of every read or starred item.
+func (s *Storage) ExportItemStates() (ItemStates, error) {
+	rows, err := s.db.Query(`
+		select f.feed_link, i.guid, i.status
+		from items i
+		join feeds f on f.id = i.feed_id
+		where i.status != ?
+		order by f.feed_link, i.id`,
+		UNREAD,
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	result := make(ItemStates)
+	for rows.Next() {
+		var link, guid string
+		var status ItemStatus
+		if err := rows.Scan(&link, &guid, &status); err != nil {
+			return nil, wrapError(err)
+		}
+		states := result[link]
+		if states == nil {
+			states = &FeedItemStates{}
+			result[link] = states
+		}
+		if status == STARRED {
+			states.Starred = append(states.Starred, guid)
+		} else {
+			states.Read = append(states.Read, guid)
+		}
+	}
+	return result, wrapError(rows.Err())
+}
+
+// ImportItemStates applies the states to the matching items. States of
+// items that haven't been fetched yet are kept and applied by
+// CreateItems once they are. Returns the number of states applied and
+// kept for later.
+func (s *Storage) ImportItemStates(states ItemStates) (applied, pending int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, wrapError(err)
+	}
+	defer tx.Rollback()
+
+	for link, feedStates := range states {
+		if feedStates == nil {
+			continue
+		}
+		for status, guids := range map[ItemStatus][]string{READ: feedStates.Read, STARRED: feedStates.Starred} {
+			for _, guid := range guids {
+				ok, err := applyItemState(tx, link, guid, status)
+				if err != nil {
+					return 0, 0, err
+				}
+				if ok {
+					applied++
+				} else {
+					pending++
+				}
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, wrapError(err)
+	}
+	return applied, pending, nil
+}
+
+func applyItemState(tx *sql.Tx, link, guid string, status ItemStatus) (bool, error) {
+	if status != READ && status != STARRED {
+		return false, &ValidationError{"status", fmt.Sprintf("unexpected status %d", status)}
+	}
+	res, err := tx.Exec(`
+		update items set status = ?
+		where guid = ? and feed_id in (select id from feeds where feed_link = ?)`,
+		status, guid, link,
+	)
+	if err != nil {
+		return false, wrapError(err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	_, err = tx.Exec(`
+		insert into item_state_imports (feed_link, guid, status) values (?, ?, ?)
+		on conflict (feed_link, guid) do update set status = excluded.status`,
+		link, guid, status,
+	)
+	return false, wrapError(err)
+}
+
+// applyImportedStates sets the imported state of newly created items.
+func applyImportedStates(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		update items set status = (
+			select s.status from item_state_imports s
+			join feeds f on f.feed_link = s.feed_link
+			where f.id = items.feed_id and s.guid = items.guid
+		)
+		where id in (
+			select i.id from items i
+			join feeds f on f.id = i.feed_id
+			join item_state_imports s on s.feed_link = f.feed_link and s.guid = i.guid
+		);
+		delete from item_state_imports
+		where exists (
+			select 1 from items i
+			join feeds f on f.id = i.feed_id
+			where f.feed_link = item_state_imports.feed_link and i.guid = item_state_imports.guid
+		);
+	`)
+	return err
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItemStatesRoundTrip(t *testing.T) {
+	src := testDB()
+	feed, _ := src.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	now := time.Now()
+	src.CreateItems([]Item{
+		{GUID: "unread", FeedId: feed.Id, Title: "unread", Date: now},
+		{GUID: "read", FeedId: feed.Id, Title: "read", Date: now},
+		{GUID: "starred", FeedId: feed.Id, Title: "starred", Date: now},
+	})
+	src.UpdateItemStatus(getItem(src, "read").Id, READ)
+	src.UpdateItemStatus(getItem(src, "starred").Id, STARRED)
+
+	states, err := src.ExportItemStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ItemStates{"http://example.com/feed.xml": {Read: []string{"read"}, Starred: []string{"starred"}}}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("unexpected export: %#v", states)
+	}
+
+	// the new instance has fetched only one of the items so far
+	dst := testDB()
+	feed, _ = dst.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	dst.CreateItems([]Item{{GUID: "read", FeedId: feed.Id, Title: "read", Date: now}})
+
+	applied, pending, err := dst.ImportItemStates(states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 1 || pending != 1 {
+		t.Fatalf("expected 1 applied and 1 pending, got %d and %d", applied, pending)
+	}
+	if getItem(dst, "read").Status != READ {
+		t.Error("state not applied to existing item")
+	}
+
+	dst.CreateItems([]Item{
+		{GUID: "unread", FeedId: feed.Id, Title: "unread", Date: now},
+		{GUID: "starred", FeedId: feed.Id, Title: "starred", Date: now},
+	})
+	if getItem(dst, "starred").Status != STARRED || getItem(dst, "unread").Status != UNREAD {
+		t.Error("imported state not applied to fetched items")
+	}
+	var left int
+	dst.db.QueryRow(`select count(*) from item_state_imports`).Scan(&left)
+	if left != 0 {
+		t.Errorf("expected applied states to be removed, %d left", left)
+	}
+}
@@ -1,25 +1,48 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
 )
 
 type Storage struct {
-	db *sql.DB
+	db *instrumentedDB
 }
 
 func New(path string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
+	if err := SQLiteTuning.Validate(); err != nil {
 		return nil, err
 	}
+	// enforce foreign keys on every connection
+	params := SQLiteTuning.params()
+	params.Set("_foreign_keys", "1")
+	// the parameters of the path come first and win
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&" + params.Encode()
+	} else {
+		dsn += "?" + params.Encode()
+	}
+	db := sql.OpenDB(SQLiteTuning.connector(dsn))
 
 	// TODO: https://foxcpp.dev/articles/the-right-way-to-use-go-sqlite3
 	db.SetMaxOpenConns(1)
 
-	if err = migrate(db); err != nil {
+	if err := migrate(db); err != nil {
 		return nil, err
 	}
-	return &Storage{db: db}, nil
+	return &Storage{db: newInstrumentedDB(db)}, nil
+}
+
+// WithContext returns a storage running its queries within ctx, so
+// that they're abandoned (and transactions rolled back) once ctx is
+// cancelled or its deadline passes, e.g. when the client of an HTTP
+// request goes away. The queries are accounted to the tracker of ctx
+// (see TrackQueries) and traced as children of its span. The storage
+// returned shares the connection and the totals of the queries with s.
+func (s *Storage) WithContext(ctx context.Context) *Storage {
+	db := *s.db
+	db.ctx = ctx
+	return &Storage{db: &db}
 }
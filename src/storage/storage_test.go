@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -24,3 +27,75 @@ func TestStorage(t *testing.T) {
 		t.Fatal("no db")
 	}
 }
+
+func TestForeignKeysEnforced(t *testing.T) {
+	db := testDB()
+	_, err := db.db.Exec(`insert into items (guid, feed_id, title) values ('guid', 100500, 'title')`)
+	if err == nil {
+		t.Fatal("expected foreign key violation")
+	}
+	report, err := db.CheckIntegrity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("unexpected integrity problems: %s", report)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	db := testDB()
+	tracker := &QueryTracker{}
+
+	ctx, cancel := context.WithCancel(TrackQueries(context.Background(), tracker))
+	scoped := db.WithContext(ctx)
+	if _, err := scoped.ListFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	// the queries of the other storages aren't accounted to the tracker
+	db.ListFeeds()
+	db.WithContext(context.Background()).ListFeeds()
+	if tracker.Stats().Queries != 1 {
+		t.Fatalf("want 1 query tracked, have %#v", tracker.Stats())
+	}
+	if total := db.QueryStats().Queries; total < 3 {
+		t.Fatalf("want all the queries in the totals, have %d", total)
+	}
+
+	cancel()
+	if _, err := scoped.ListFeeds(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, have %v", err)
+	}
+	if _, err := scoped.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, have %v", err)
+	}
+	if feeds, err := db.ListFeeds(); err != nil || len(feeds) != 0 {
+		t.Fatalf("unexpected feeds: %v, %v", feeds, err)
+	}
+}
+
+func TestTuning(t *testing.T) {
+	defer func(tuning Tuning) { SQLiteTuning = tuning }(SQLiteTuning)
+	SQLiteTuning = Tuning{JournalMode: "wal", Synchronous: "normal", BusyTimeout: 10000, CacheSize: 4096, MmapSize: 1 << 20}
+	db, err := New(filepath.Join(t.TempDir(), "storage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var journalMode string
+	var synchronous, busyTimeout, cacheSize, mmapSize int64
+	db.db.QueryRow(`pragma journal_mode`).Scan(&journalMode)
+	db.db.QueryRow(`pragma synchronous`).Scan(&synchronous)
+	db.db.QueryRow(`pragma busy_timeout`).Scan(&busyTimeout)
+	db.db.QueryRow(`pragma cache_size`).Scan(&cacheSize)
+	db.db.QueryRow(`pragma mmap_size`).Scan(&mmapSize)
+	if journalMode != "wal" || synchronous != 1 || busyTimeout != 10000 || cacheSize != -4096 || mmapSize != 1<<20 {
+		t.Errorf("unexpected pragmas: %s, %d, %d, %d, %d", journalMode, synchronous, busyTimeout, cacheSize, mmapSize)
+	}
+
+	for _, tuning := range []Tuning{{JournalMode: "fast"}, {Synchronous: "always"}, {BusyTimeout: -1}} {
+		SQLiteTuning = tuning
+		if _, err := New(":memory:"); err == nil {
+			t.Errorf("want %#v rejected", tuning)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"time"
+)
+
+// SuggestionKind is the problem found with a subscription.
+type SuggestionKind string
+
+const (
+	// the feed url can't be reached or is gone
+	SuggestDead SuggestionKind = "dead"
+	// the feed url redirects to another subscription
+	SuggestDuplicate SuggestionKind = "duplicate"
+	// the feed url points to a web page, Replacement is the feed
+	// it links to (if any)
+	SuggestNotAFeed SuggestionKind = "not_a_feed"
+	// the feed says it's at another url (see parser.Feed.FeedLink),
+	// Replacement is the new one
+	SuggestMoved SuggestionKind = "moved"
+)
+
+// FeedSuggestion is a proposed fix for a subscription, produced by
+// checking the feeds after an import, or by refreshing them.
+type FeedSuggestion struct {
+	FeedId       int64          `json:"feed_id"`
+	Kind         SuggestionKind `json:"kind"`
+	Detail       string         `json:"detail"`
+	TargetFeedId *int64         `json:"target_feed_id,omitempty"`
+	Replacement  string         `json:"replacement,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+func (s *Storage) SetFeedSuggestion(sg FeedSuggestion) error {
+	_, err := s.db.Exec(`
+		insert into feed_suggestions (feed_id, kind, detail, target_feed_id, replacement, created_at)
+		values (?, ?, ?, ?, ?, ?)
+		on conflict (feed_id) do update set
+		 kind = excluded.kind,
+		 detail = excluded.detail,
+		 target_feed_id = excluded.target_feed_id,
+		 replacement = excluded.replacement,
+		 created_at = excluded.created_at
+	`, sg.FeedId, sg.Kind, sg.Detail, sg.TargetFeedId, sg.Replacement, time.Now().UTC())
+	return wrapError(err)
+}
+
+func (s *Storage) ListFeedSuggestions() ([]FeedSuggestion, error) {
+	result := make([]FeedSuggestion, 0)
+	rows, err := s.db.Query(`
+		select feed_id, kind, detail, target_feed_id, replacement, created_at
+		from feed_suggestions
+		order by feed_id
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sg FeedSuggestion
+		err = rows.Scan(&sg.FeedId, &sg.Kind, &sg.Detail, &sg.TargetFeedId, &sg.Replacement, &sg.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, sg)
+	}
+	return result, wrapError(rows.Err())
+}
+
+func (s *Storage) GetFeedSuggestion(feedId int64) (*FeedSuggestion, error) {
+	var sg FeedSuggestion
+	err := s.db.QueryRow(`
+		select feed_id, kind, detail, target_feed_id, replacement, created_at
+		from feed_suggestions where feed_id = ?
+	`, feedId).Scan(&sg.FeedId, &sg.Kind, &sg.Detail, &sg.TargetFeedId, &sg.Replacement, &sg.CreatedAt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &sg, nil
+}
+
+// DeleteFeedSuggestion dismisses the suggestion.
+func (s *Storage) DeleteFeedSuggestion(feedId int64) error {
+	return s.execOne(`delete from feed_suggestions where feed_id = ?`, feedId)
+}
+
+// ApplyFeedSuggestion fixes the subscription as suggested: feeds with a
+// known replacement are pointed to it, all the others are unsubscribed.
+func (s *Storage) ApplyFeedSuggestion(feedId int64) error {
+	sg, err := s.GetFeedSuggestion(feedId)
+	if err != nil {
+		return err
+	}
+	if (sg.Kind == SuggestNotAFeed || sg.Kind == SuggestMoved) && sg.Replacement != "" {
+		if err := s.UpdateFeedLink(feedId, sg.Replacement); err != nil {
+			return err
+		}
+		return s.DeleteFeedSuggestion(feedId)
+	}
+	_, err = s.DeleteFeed(feedId)
+	return err
+}
+
+// SetFeedSelfLink remembers the url the feed says it's at, returning
+// whether it has changed since the last time. Each url is looked into
+// once: a dismissed suggestion isn't made again.
+func (s *Storage) SetFeedSelfLink(feedId int64, link string) (bool, error) {
+	res, err := s.db.Exec(`update feeds set self_link = ? where id = ? and self_link != ?`, link, feedId, link)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "testing"
+
+func TestFeedSuggestions(t *testing.T) {
+	db := testDB()
+	dead, _ := db.CreateFeed("dead", "", "", "http://test.com/dead.xml", "", nil)
+	page, _ := db.CreateFeed("page", "", "", "http://test.com/", "", nil)
+	target, _ := db.CreateFeed("target", "", "", "http://test.com/new.xml", "", nil)
+	dup, _ := db.CreateFeed("dup", "", "", "http://test.com/old.xml", "", nil)
+
+	db.SetFeedSuggestion(FeedSuggestion{FeedId: dead.Id, Kind: SuggestDead, Detail: "status code 404"})
+	db.SetFeedSuggestion(FeedSuggestion{FeedId: page.Id, Kind: SuggestNotAFeed, Replacement: "http://test.com/feed.xml"})
+	db.SetFeedSuggestion(FeedSuggestion{FeedId: dup.Id, Kind: SuggestDuplicate, TargetFeedId: &target.Id})
+
+	if have, _ := db.ListFeedSuggestions(); len(have) != 3 || have[0].Kind != SuggestDead {
+		t.Fatalf("unexpected suggestions: %#v", have)
+	}
+
+	if err := db.ApplyFeedSuggestion(page.Id); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(page.Id); feed == nil || feed.FeedLink != "http://test.com/feed.xml" {
+		t.Fatalf("feed link not replaced: %#v", feed)
+	}
+	if err := db.ApplyFeedSuggestion(dead.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetFeed(dead.Id); err != ErrNotFound {
+		t.Fatal("dead feed must be deleted")
+	}
+	if err := db.ApplyFeedSuggestion(dead.Id); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// removing the target makes the duplicate suggestion obsolete
+	db.DeleteFeed(target.Id)
+	if have, _ := db.ListFeedSuggestions(); len(have) != 0 {
+		t.Fatalf("expected no suggestions left, got %#v", have)
+	}
+}
+
+func TestFeedMoved(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://old.test.com/feed.xml", "", nil)
+
+	for i, want := range []bool{true, false} {
+		if changed, err := db.SetFeedSelfLink(feed.Id, "https://new.test.com/feed.xml"); err != nil || changed != want {
+			t.Fatalf("#%d: want %v, have %v (%v)", i, want, changed, err)
+		}
+	}
+	db.SetFeedSuggestion(FeedSuggestion{FeedId: feed.Id, Kind: SuggestMoved, Replacement: "https://new.test.com/feed.xml"})
+	if err := db.ApplyFeedSuggestion(feed.Id); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := db.GetFeed(feed.Id); feed == nil || feed.FeedLink != "https://new.test.com/feed.xml" {
+		t.Fatalf("feed link not replaced: %#v", feed)
+	}
+	if sg, _ := db.GetFeedSuggestion(feed.Id); sg != nil {
+		t.Errorf("want the suggestion gone, have %#v", sg)
+	}
+}
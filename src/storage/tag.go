@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxTagLength = 64
+
+// TagStat is the number of items with the tag, see ItemFilter.Tag.
+type TagStat struct {
+	Tag          string `json:"tag"`
+	TotalCount   int64  `json:"total"`
+	UnreadCount  int64  `json:"unread"`
+	StarredCount int64  `json:"starred"`
+}
+
+// normalizeTag trims and lowercases the tag, so that "Work" and "work " are the same.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" {
+		return "", &ValidationError{"tag", "must not be empty"}
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", &ValidationError{"tag", "too long"}
+	}
+	return tag, nil
+}
+
+func (s *Storage) AddItemTag(itemId int64, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	var id int64
+	err = s.db.QueryRow(`select id from items where id = ?`, itemId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`insert or ignore into item_tags (item_id, tag) values (?, ?)`, itemId, tag)
+	return wrapError(err)
+}
+
+func (s *Storage) RemoveItemTag(itemId int64, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	return s.execOne(`delete from item_tags where item_id = ? and tag = ?`, itemId, tag)
+}
+
+// ItemTags returns the tags of the items, sorted.
+func (s *Storage) ItemTags(itemIds []int64) (map[int64][]string, error) {
+	result := make(map[int64][]string)
+	if len(itemIds) == 0 {
+		return result, nil
+	}
+	qmarks := make([]string, len(itemIds))
+	args := make([]interface{}, len(itemIds))
+	for i, id := range itemIds {
+		qmarks[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(`
+		select item_id, tag from item_tags
+		where item_id in (`+strings.Join(qmarks, ",")+`)
+		order by tag
+	`, args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemId int64
+		var tag string
+		if err := rows.Scan(&itemId, &tag); err != nil {
+			return nil, wrapError(err)
+		}
+		result[itemId] = append(result[itemId], tag)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// ListTags returns all the tags in use, sorted.
+func (s *Storage) ListTags() ([]TagStat, error) {
+	rows, err := s.db.Query(`
+		select
+			t.tag,
+			count(*),
+			sum(case i.status when ? then 1 else 0 end),
+			sum(case i.status when ? then 1 else 0 end)
+		from item_tags t
+		join items i on i.id = t.item_id
+		join feeds f on f.id = i.feed_id
+		where f.deleted_at is null
+		group by t.tag
+		order by t.tag
+	`, UNREAD, STARRED)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	result := make([]TagStat, 0)
+	for rows.Next() {
+		var stat TagStat
+		if err := rows.Scan(&stat.Tag, &stat.TotalCount, &stat.UnreadCount, &stat.StarredCount); err != nil {
+			return nil, err
+		}
+		result = append(result, stat)
+	}
+	return result, wrapError(rows.Err())
+}
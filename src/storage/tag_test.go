@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestItemTags(t *testing.T) {
+	db := testDB()
+	testItemsSetup(db)
+
+	item111 := getItem(db, "item111")
+	item113 := getItem(db, "item113")
+	for _, tag := range []string{"work", " Work ", "to-read"} {
+		if err := db.AddItemTag(item111.Id, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AddItemTag(item113.Id, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	if have, _ := db.GetItem(item111.Id); !reflect.DeepEqual(have.Tags, []string{"to-read", "work"}) {
+		t.Errorf("want [to-read work], have %v", have.Tags)
+	}
+
+	tag := "WORK"
+	have := getItemGuids(db.ListItems(ItemFilter{Tag: &tag}, 10, false, false))
+	if want := []string{"item111", "item113"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	wantStats := []TagStat{
+		{Tag: "to-read", TotalCount: 1, UnreadCount: 1},
+		{Tag: "work", TotalCount: 2, UnreadCount: 1, StarredCount: 1},
+	}
+	if haveStats, _ := db.ListTags(); !reflect.DeepEqual(haveStats, wantStats) {
+		t.Errorf("want %v, have %v", wantStats, haveStats)
+	}
+
+	if err := db.RemoveItemTag(item111.Id, "to-read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RemoveItemTag(item111.Id, "to-read"); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	if err := db.AddItemTag(-1, "work"); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	var validationErr *ValidationError
+	if err := db.AddItemTag(item111.Id, "  "); !errors.As(err, &validationErr) {
+		t.Errorf("want a validation error, have %v", err)
+	}
+
+	// the tagged items are kept like the starred ones
+	feed, _ := db.CreateFeed("feed", "", "", "http://test.com/feed.xml", "", nil)
+	now := time.Now()
+	items := make([]Item, itemsKeepSize+2)
+	for i := range items {
+		guid := "old" + strconv.Itoa(i)
+		items[i] = Item{GUID: guid, FeedId: feed.Id, Title: guid, Date: now.Add(time.Duration(i) * time.Minute)}
+	}
+	db.CreateItems(items)
+	db.db.Exec(`update items set status = ?, date_arrived = ? where feed_id = ?`, READ, RetentionCutoff(now).AddDate(0, 0, -1), feed.Id)
+	oldest := getItem(db, "old0")
+	if err := db.AddItemTag(oldest.Id, "keep"); err != nil {
+		t.Fatal(err)
+	}
+	db.DeleteOldItems()
+	if _, err := db.GetItem(oldest.Id); err != nil {
+		t.Error("tagged item deleted")
+	}
+	if have, _ := db.ListItems(ItemFilter{FeedID: &feed.Id}, 100, false, false); len(have) != itemsKeepSize+1 {
+		t.Errorf("want %d items kept, have %d", itemsKeepSize+1, len(have))
+	}
+}
+
+func TestTagsOfTrashedFeeds(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+
+	db.AddItemTag(getItem(db, "item111").Id, "work")
+	db.AddItemTag(getItem(db, "item121").Id, "work")
+	db.AddItemTag(getItem(db, "item112").Id, "later")
+	if err := db.TrashFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TagStat{{Tag: "work", TotalCount: 1, UnreadCount: 1}}
+	if have, _ := db.ListTags(); !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	if err := db.RestoreFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := db.ListTags(); len(have) != 2 || have[1].TotalCount != 2 {
+		t.Errorf("want the tags back with the feed, have %v", have)
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "time"
+
+// ValidateTimezone checks the "timezone" setting: an IANA time zone
+// name (e.g. "Europe/Berlin"), empty for the server's local time.
+func ValidateTimezone(val interface{}) error {
+	name, ok := val.(string)
+	if !ok {
+		return &ValidationError{"timezone", "must be a time zone name"}
+	}
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return &ValidationError{"timezone", "unknown time zone " + name}
+	}
+	return nil
+}
+
+// Location returns the time zone of the user (the "timezone" setting),
+// the days of the digests, the daily limits, the delivery times, the
+// mute schedules and the metrics start at midnight of. The timestamps
+// themselves are stored in UTC.
+func (s *Storage) Location() (*time.Location, error) {
+	val, err := s.GetSettingsValue("timezone")
+	if err != nil {
+		return nil, err
+	}
+	name, _ := val.(string)
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
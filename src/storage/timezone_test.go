@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimezone(t *testing.T) {
+	db := testDB()
+	if loc, _ := db.Location(); loc != time.Local {
+		t.Errorf("want the server's time zone by default, have %s", loc)
+	}
+	for _, val := range []interface{}{"Mars/Olympus_Mons", 2} {
+		if db.UpdateSettings(map[string]interface{}{"timezone": val}) == nil {
+			t.Errorf("want %#v rejected", val)
+		}
+	}
+	if err := db.UpdateSettings(map[string]interface{}{"timezone": "Pacific/Kiritimati"}); err != nil {
+		t.Fatal("failed to set the time zone")
+	}
+	if loc, _ := db.Location(); loc.String() != "Pacific/Kiritimati" {
+		t.Errorf("want Pacific/Kiritimati, have %s", loc)
+	}
+
+	scope := testItemsSetup(db)
+
+	// 20:00 UTC is already the next day at UTC+14
+	at := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	if err := db.RecordBacklog(at); err != nil {
+		t.Fatal(err)
+	}
+	if backlog, _ := db.ListBacklog(nil, at); len(backlog) != 1 || backlog[0].Day != "2024-03-02" {
+		t.Errorf("want the backlog of 2024-03-02, have %v", backlog)
+	}
+
+	db.UpdateItemStatus(getItem(db, "item111").Id, READ)
+	db.UpdateItemStatus(getItem(db, "item121").Id, READ)
+	if _, err := db.db.Exec(`update items set read_at = ? where guid = 'item121'`, at); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := db.Location()
+	history, _ := db.ReadingHistory(nil, at)
+	wantHistory := []ReadingDay{{"2024-03-02", 1}, {time.Now().In(loc).Format("2006-01-02"), 1}}
+	if !reflect.DeepEqual(history, wantHistory) {
+		t.Errorf("want %v, have %v", wantHistory, history)
+	}
+	// since the start of today in the time zone
+	if history, _ := db.ReadingHistory(&scope.folder1.Id, time.Now()); len(history) != 1 {
+		t.Errorf("want today's item only, have %v", history)
+	}
+}
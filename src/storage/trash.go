@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"time"
+)
+
+// FeedRestorePeriod is how long the deleted feeds are kept along with
+// their items, see TrashFeed.
+const FeedRestorePeriod = 7 * 24 * time.Hour
+
+// DeletedFeed is a feed which can still be restored until PurgeAt.
+type DeletedFeed struct {
+	FeedId    int64     `json:"feed_id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// TrashFeed hides the feed with its items and stops refreshing it.
+// They are kept for FeedRestorePeriod, see RestoreFeed and PurgeDeletedFeeds.
+func (s *Storage) TrashFeed(feedId int64) error {
+	return s.execOne(
+		`update feeds set deleted_at = ? where id = ? and deleted_at is null`,
+		time.Now().UTC(), feedId,
+	)
+}
+
+// RestoreFeed undoes TrashFeed, ErrNotFound is returned
+// if the feed hasn't been deleted (or is gone for good).
+func (s *Storage) RestoreFeed(feedId int64) error {
+	return s.execOne(`update feeds set deleted_at = null where id = ? and deleted_at is not null`, feedId)
+}
+
+// ListDeletedFeeds returns the feeds which can be restored, the latest deleted first.
+func (s *Storage) ListDeletedFeeds() ([]DeletedFeed, error) {
+	result := make([]DeletedFeed, 0)
+	rows, err := s.db.Query(`
+		select id, title, deleted_at
+		from feeds
+		where deleted_at is not null
+		order by deleted_at desc, id desc
+	`)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var feed DeletedFeed
+		if err := rows.Scan(&feed.FeedId, &feed.Title, &feed.DeletedAt); err != nil {
+			return nil, err
+		}
+		feed.PurgeAt = feed.DeletedAt.Add(FeedRestorePeriod)
+		result = append(result, feed)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// PurgeDeletedFeeds removes for good the feeds deleted before the given time.
+// Returns the number of feeds removed.
+func (s *Storage) PurgeDeletedFeeds(before time.Time) (int, error) {
+	rows, err := s.db.Query(`select id from feeds where deleted_at < ?`, before.UTC())
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, wrapError(err)
+	}
+	rows.Close()
+
+	purged := 0
+	for _, id := range ids {
+		if _, err := s.DeleteFeed(id); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
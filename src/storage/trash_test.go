@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrashFeed(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	folder1Items := func() []string {
+		return getItemGuids(db.ListItems(ItemFilter{FolderID: &scope.folder1.Id}, 10, false, false))
+	}
+
+	before := folder1Items()
+	if err := db.TrashFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.TrashFeed(scope.feed11.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound for a deleted feed, have %v", err)
+	}
+	feeds, _ := db.ListFeeds()
+	if len(feeds) != 3 {
+		t.Errorf("the deleted feed must be left out: %#v", feeds)
+	}
+	if have, want := folder1Items(), []string{"item121", "item122"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	stats, _ := db.FeedStats()
+	for _, stat := range stats {
+		if stat.FeedId == scope.feed11.Id {
+			t.Errorf("unexpected stats of a deleted feed: %#v", stat)
+		}
+	}
+	deleted, _ := db.ListDeletedFeeds()
+	if len(deleted) != 1 || deleted[0].FeedId != scope.feed11.Id || !deleted[0].PurgeAt.Equal(deleted[0].DeletedAt.Add(FeedRestorePeriod)) {
+		t.Fatalf("unexpected deleted feeds: %#v", deleted)
+	}
+
+	if err := db.RestoreFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestoreFeed(scope.feed11.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound for a feed which isn't deleted, have %v", err)
+	}
+	if have := folder1Items(); !reflect.DeepEqual(have, before) {
+		t.Errorf("want %v, have %v", before, have)
+	}
+
+	// re-subscribing brings the feed back as well
+	db.TrashFeed(scope.feed12.Id)
+	db.CreateFeed("feed12", "", "", "http://test.com/feed12.xml", "", &scope.folder1.Id)
+	if feed, _ := db.GetFeed(scope.feed12.Id); feed.DeletedAt != nil {
+		t.Errorf("the feed must be restored: %#v", feed)
+	}
+
+	db.TrashFeed(scope.feed21.Id)
+	if n, _ := db.PurgeDeletedFeeds(time.Now().Add(-time.Hour)); n != 0 {
+		t.Errorf("purged %d feeds within the restore period", n)
+	}
+	if n, _ := db.PurgeDeletedFeeds(time.Now().Add(time.Hour)); n != 1 {
+		t.Errorf("want 1 feed purged, have %d", n)
+	}
+	if _, err := db.GetFeed(scope.feed21.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+	if deleted, _ := db.ListDeletedFeeds(); len(deleted) != 0 {
+		t.Errorf("unexpected deleted feeds: %#v", deleted)
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"time"
+)
+
+// TrialFolder is where the new subscriptions are put on trial.
+const TrialFolder = "Trial"
+
+// FeedTrial is a subscription whose trial is over, waiting for
+// the user to keep it or unsubscribe.
+type FeedTrial struct {
+	FeedId     int64      `json:"feed_id"`
+	Title      string     `json:"title"`
+	TrialUntil time.Time  `json:"trial_until"`
+	ReadCount  int64      `json:"read_count"`
+	LastRead   *time.Time `json:"last_read"`
+	// "keep" if any of the feed's items has been read, "unsubscribe" otherwise
+	Suggestion string `json:"suggestion"`
+}
+
+// recordFeedRead counts the items read one by one, as opposed to
+// marking them all read, which tells whether a feed is worth keeping.
+func (s *Storage) recordFeedRead(itemId int64) error {
+	_, err := s.db.Exec(`
+		update feeds set read_count = read_count + 1, last_read = ?
+		where id = (select feed_id from items where id = ?)`,
+		time.Now().UTC(), itemId,
+	)
+	return wrapError(err)
+}
+
+// StartFeedTrial moves the feed to the trial folder until the given time,
+// restarting its read activity.
+func (s *Storage) StartFeedTrial(feedId int64, until time.Time) error {
+	folder, err := s.CreateFolder(TrialFolder)
+	if err != nil {
+		return err
+	}
+	return s.execOne(`
+		update feeds
+		set folder_id = ?, trial_until = ?, read_count = 0, last_read = null
+		where id = ?`,
+		folder.Id, until.UTC(), feedId,
+	)
+}
+
+// ListFeedTrials returns the feeds whose trial has ended by the given time.
+func (s *Storage) ListFeedTrials(now time.Time) ([]FeedTrial, error) {
+	result := make([]FeedTrial, 0)
+	rows, err := s.db.Query(`
+		select id, title, trial_until, read_count, last_read
+		from feeds
+		where trial_until is not null and trial_until <= ? and deleted_at is null
+		order by trial_until`,
+		now.UTC(),
+	)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var trial FeedTrial
+		if err := rows.Scan(&trial.FeedId, &trial.Title, &trial.TrialUntil, &trial.ReadCount, &trial.LastRead); err != nil {
+			return nil, err
+		}
+		trial.Suggestion = "unsubscribe"
+		if trial.ReadCount > 0 {
+			trial.Suggestion = "keep"
+		}
+		result = append(result, trial)
+	}
+	return result, wrapError(rows.Err())
+}
+
+// KeepFeed ends the trial of the feed, moving it out of the trial
+// folder to the default one.
+func (s *Storage) KeepFeed(feedId int64) error {
+	folderId, err := s.DefaultFolderId()
+	if err != nil {
+		return err
+	}
+	return s.execOne(`
+		update feeds
+		set trial_until = null,
+		    folder_id = case when folder_id = (select id from folders where title = ?) then ? else folder_id end
+		where id = ? and trial_until is not null`,
+		TrialFolder, folderId, feedId,
+	)
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedTrials(t *testing.T) {
+	db := testDB()
+	scope := testItemsSetup(db)
+	now := time.Now()
+
+	if err := db.StartFeedTrial(scope.feed01.Id, now.AddDate(0, 0, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.StartFeedTrial(scope.feed11.Id, now.AddDate(0, 0, 7)); err != nil {
+		t.Fatal(err)
+	}
+	feed, _ := db.GetFeed(scope.feed01.Id)
+	if feed.TrialUntil == nil || feed.FolderId == nil {
+		t.Fatalf("feed not on trial: %#v", feed)
+	}
+	if trials, _ := db.ListFeedTrials(now); len(trials) != 0 {
+		t.Fatalf("unexpected trials: %#v", trials)
+	}
+
+	// reading one by one counts, marking everything read doesn't
+	db.UpdateItemStatus(getItem(db, "item111").Id, READ)
+	db.MarkItemsRead(MarkFilter{FeedID: &scope.feed01.Id})
+
+	trials, _ := db.ListFeedTrials(now.AddDate(0, 0, 8))
+	if len(trials) != 2 {
+		t.Fatalf("want 2 trials, have %#v", trials)
+	}
+	suggestions := map[int64]string{}
+	for _, trial := range trials {
+		suggestions[trial.FeedId] = trial.Suggestion
+	}
+	if suggestions[scope.feed11.Id] != "keep" || suggestions[scope.feed01.Id] != "unsubscribe" {
+		t.Errorf("invalid suggestions: %#v", trials)
+	}
+
+	if err := db.KeepFeed(scope.feed11.Id); err != nil {
+		t.Fatal(err)
+	}
+	feed, _ = db.GetFeed(scope.feed11.Id)
+	if feed.TrialUntil != nil || feed.FolderId != nil {
+		t.Errorf("feed still on trial: %#v", feed)
+	}
+	if err := db.KeepFeed(scope.feed11.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Tuning is applied to the database connection by New, see the SQLite
+// pragmas of the same names. The zero values keep SQLite's defaults.
+type Tuning struct {
+	// "wal" makes the writes cheaper and lets other processes
+	// (e.g. backups) read while yarr writes
+	JournalMode string
+	// "off", "normal", "full" or "extra"
+	Synchronous string
+	// how long a query waits for the database to be unlocked before
+	// failing with "database is locked", in milliseconds
+	BusyTimeout int
+	// the size of the page cache, in kibibytes
+	CacheSize int
+	// how much of the database file is memory-mapped, in bytes
+	MmapSize int64
+}
+
+// SQLiteTuning is the tuning the databases are opened with.
+var SQLiteTuning = Tuning{BusyTimeout: 5000}
+
+// Validate checks the tuning, returning a description of the first problem.
+func (t Tuning) Validate() error {
+	switch strings.ToLower(t.JournalMode) {
+	case "", "delete", "truncate", "persist", "memory", "wal", "off":
+	default:
+		return fmt.Errorf("unknown journal mode %q", t.JournalMode)
+	}
+	switch strings.ToLower(t.Synchronous) {
+	case "", "off", "normal", "full", "extra":
+	default:
+		return fmt.Errorf("unknown synchronous level %q", t.Synchronous)
+	}
+	if t.BusyTimeout < 0 || t.CacheSize < 0 || t.MmapSize < 0 {
+		return fmt.Errorf("the busy timeout, cache size and mmap size must not be negative")
+	}
+	return nil
+}
+
+// params returns the connection parameters of the go-sqlite3 driver
+// setting the tuning up, but for the mmap size which it has none for.
+func (t Tuning) params() url.Values {
+	params := url.Values{}
+	if t.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(t.JournalMode))
+	}
+	if t.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(t.Synchronous))
+	}
+	if t.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.Itoa(t.BusyTimeout))
+	}
+	if t.CacheSize > 0 {
+		// negative sizes are in kibibytes, positive ones in pages
+		params.Set("_cache_size", strconv.Itoa(-t.CacheSize))
+	}
+	return params
+}
+
+// connector opens the connections with the parameters of the dsn and,
+// since the pool may reopen them, sets up the mmap size on each one.
+func (t Tuning) connector(dsn string) driver.Connector {
+	return &connector{dsn: dsn, driver: &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if t.MmapSize == 0 {
+				return nil
+			}
+			_, err := conn.Exec(fmt.Sprintf("pragma mmap_size = %d", t.MmapSize), nil)
+			return err
+		},
+	}}
+}
+
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
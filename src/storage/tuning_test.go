@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestTuningMmapSizePerConnection(t *testing.T) {
+	tuning := Tuning{MmapSize: 1 << 20}
+	db := sql.OpenDB(tuning.connector(filepath.Join(t.TempDir(), "yarr.db")))
+	defer db.Close()
+	// every query gets a new connection
+	db.SetMaxIdleConns(0)
+
+	for i := 0; i < 2; i++ {
+		var size int64
+		if err := db.QueryRow(`pragma mmap_size`).Scan(&size); err != nil {
+			t.Fatal(err)
+		}
+		if size != tuning.MmapSize {
+			t.Fatalf("connection %d: want mmap size %d, have %d", i, tuning.MmapSize, size)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits applied to user-provided and fetched values before writing them.
+var (
+	MaxTitleLength = 1024
+	MaxURLLength   = 4096
+)
+
+// ValidationError is returned for input that cannot be stored as is.
+// It matches ErrConstraint.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrConstraint
+}
+
+// ValidateTitle checks a user-provided feed or folder title.
+func ValidateTitle(field, title string) error {
+	if strings.TrimSpace(title) == "" {
+		return &ValidationError{field, "must not be empty"}
+	}
+	if !utf8.ValidString(title) {
+		return &ValidationError{field, "must be valid UTF-8"}
+	}
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return &ValidationError{field, fmt.Sprintf("must be at most %d characters long", MaxTitleLength)}
+	}
+	return nil
+}
+
+// ValidateFeedLink checks that the link is an absolute http(s) url.
+func ValidateFeedLink(link string) error {
+	if strings.HasPrefix(link, digestScheme) {
+		return nil
+	}
+	if len(link) > MaxURLLength {
+		return &ValidationError{"feed_link", fmt.Sprintf("must be at most %d bytes long", MaxURLLength)}
+	}
+	if !utf8.ValidString(link) {
+		return &ValidationError{"feed_link", "must be valid UTF-8"}
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ValidationError{"feed_link", "must be an absolute http(s) url"}
+	}
+	return nil
+}
+
+// ValidateAcceptLanguage checks an Accept-Language header value,
+// e.g. `de-CH, de;q=0.9, en;q=0.5`. An empty value is valid.
+func ValidateAcceptLanguage(language string) error {
+	if len(language) > MaxTitleLength {
+		return &ValidationError{"accept_language", fmt.Sprintf("must be at most %d bytes long", MaxTitleLength)}
+	}
+	for _, r := range language {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-*,;=. ", r):
+		default:
+			return &ValidationError{"accept_language", fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return nil
+}
+
+// ValidateHosts checks a list of host names, e.g. `example.com`,
+// which also stand for their subdomains.
+func ValidateHosts(field string, hosts []string) error {
+	for _, host := range hosts {
+		if len(host) > 253 {
+			return &ValidationError{field, fmt.Sprintf("%q is too long", host)}
+		}
+		if strings.HasPrefix(host, ".") || strings.HasSuffix(host, ".") || strings.Contains(host, "..") {
+			return &ValidationError{field, fmt.Sprintf("%q is not a host name", host)}
+		}
+		for _, r := range host {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+				return &ValidationError{field, fmt.Sprintf("%q is not a host name", host)}
+			}
+		}
+	}
+	return nil
+}
+
+// cleanHosts lowercases the host names, dropping the empty ones and the repeats.
+func cleanHosts(hosts []string) []string {
+	result := make([]string, 0, len(hosts))
+	seen := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" && !seen[host] {
+			seen[host] = true
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// cleanText fixes up fetched text instead of rejecting it:
+// invalid UTF-8 sequences are dropped and the text is cut to max characters.
+func cleanText(text string, max int) string {
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "")
+	}
+	if max > 0 && len(text) > max && utf8.RuneCountInString(text) > max {
+		runes := []rune(text)
+		text = string(runes[:max])
+	}
+	return text
+}
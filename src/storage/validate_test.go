@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateFeedLink(t *testing.T) {
+	valid := []string{
+		"http://example.com/feed.xml",
+		"https://example.com",
+		DigestFeedLink(1),
+	}
+	for _, link := range valid {
+		if err := ValidateFeedLink(link); err != nil {
+			t.Errorf("expected %q to be valid: %s", link, err)
+		}
+	}
+	invalid := []string{
+		"",
+		"example.com/feed.xml",
+		"ftp://example.com/feed.xml",
+		"javascript:alert(1)",
+		"http://",
+		"http://example.com/" + strings.Repeat("a", MaxURLLength),
+		"http://example.com/\xff",
+	}
+	for _, link := range invalid {
+		var verr *ValidationError
+		if err := ValidateFeedLink(link); !errors.As(err, &verr) {
+			t.Errorf("expected %q to be invalid", link)
+		}
+	}
+}
+
+func TestValidateTitle(t *testing.T) {
+	if err := ValidateTitle("title", "news"); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"", "  ", "\xff\xfe", strings.Repeat("a", MaxTitleLength+1)} {
+		if ValidateTitle("title", title) == nil {
+			t.Errorf("expected %q to be invalid", title)
+		}
+	}
+}
+
+func TestInvalidWritesRejected(t *testing.T) {
+	db := testDB()
+	if _, err := db.CreateFeed("title", "", "", "not a url", "", nil); err == nil {
+		t.Fatal("created feed with invalid link")
+	}
+	feed, _ := db.CreateFeed("title\xff", "", "", "http://example.com/feed.xml", "", nil)
+	if feed == nil || feed.Title != "title" {
+		t.Fatalf("expected fetched title to be cleaned up: %#v", feed)
+	}
+	if db.RenameFeed(feed.Id, "") == nil || db.UpdateFeedLink(feed.Id, "file:///etc/passwd") == nil {
+		t.Fatal("accepted invalid update")
+	}
+	if _, err := db.CreateFolder(strings.Repeat("a", MaxTitleLength+1)); err == nil {
+		t.Fatal("created folder with invalid title")
+	}
+
+	db.CreateItems([]Item{{GUID: "item", FeedId: feed.Id, Title: "broken \xff title"}})
+	if item := getItem(db, "item"); item.Title != "broken  title" {
+		t.Fatalf("unexpected item title: %q", item.Title)
+	}
+}
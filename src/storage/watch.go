@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+)
+
+// FeedWatch is the rule of a feed watching a value on a web page (see
+// package watch), along with the value last seen there. The feed's link
+// is the page.
+type FeedWatch struct {
+	FeedId    int64   `json:"feed_id"`
+	Selector  string  `json:"selector"`
+	Attr      string  `json:"attr,omitempty"`
+	Pattern   string  `json:"pattern,omitempty"`
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+
+	// nil until the page is checked
+	Value     *string    `json:"value"`
+	CheckedAt *time.Time `json:"checked_at"`
+}
+
+// SaveFeedWatch records the rule of the watching feed, replacing the
+// previous one. The value last seen is kept.
+func (s *Storage) SaveFeedWatch(fw FeedWatch) error {
+	_, err := s.db.Exec(`
+		insert into feed_watches (feed_id, selector, attr, pattern, condition, threshold)
+		values (?, ?, ?, ?, ?, ?)
+		on conflict (feed_id) do update set
+			selector = excluded.selector,
+			attr = excluded.attr,
+			pattern = excluded.pattern,
+			condition = excluded.condition,
+			threshold = excluded.threshold`,
+		fw.FeedId, fw.Selector, fw.Attr, fw.Pattern, fw.Condition, fw.Threshold,
+	)
+	return wrapError(err)
+}
+
+// GetFeedWatch returns the rule of the feed, ErrNotFound if it doesn't watch a page.
+func (s *Storage) GetFeedWatch(feedId int64) (*FeedWatch, error) {
+	var fw FeedWatch
+	err := s.db.QueryRow(`
+		select feed_id, selector, attr, pattern, condition, threshold, value, checked_at
+		from feed_watches where feed_id = ?`,
+		feedId,
+	).Scan(&fw.FeedId, &fw.Selector, &fw.Attr, &fw.Pattern, &fw.Condition, &fw.Threshold, &fw.Value, &fw.CheckedAt)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &fw, nil
+}
+
+// SetFeedWatchValue records the value seen on the page of the feed.
+func (s *Storage) SetFeedWatchValue(feedId int64, value string, checkedAt time.Time) error {
+	return s.execOne(
+		`update feed_watches set value = ?, checked_at = ? where feed_id = ?`,
+		value, checkedAt.UTC(), feedId,
+	)
+}
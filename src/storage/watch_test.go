@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFeedWatch(t *testing.T) {
+	db := testDB()
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/product", "", nil)
+
+	if _, err := db.GetFeedWatch(feed.Id); err != ErrNotFound {
+		t.Fatalf("want ErrNotFound, have %v", err)
+	}
+	fw := FeedWatch{FeedId: feed.Id, Selector: ".price", Condition: "below", Threshold: 100}
+	if err := db.SaveFeedWatch(fw); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetFeedWatchValue(feed.Id, "$120", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// changing the rule keeps the value
+	fw.Condition = "changed"
+	if err := db.SaveFeedWatch(fw); err != nil {
+		t.Fatal(err)
+	}
+	have, err := db.GetFeedWatch(feed.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Condition != "changed" || have.Value == nil || *have.Value != "$120" || have.CheckedAt == nil {
+		t.Errorf("unexpected watch: %#v", have)
+	}
+
+	if _, err := db.DeleteFeed(feed.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetFeedWatch(feed.Id); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, have %v", err)
+	}
+}
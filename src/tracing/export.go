@@ -0,0 +1,194 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	exportInterval = 5 * time.Second
+	exportBatch    = 512
+	// spans are dropped past this, if the collector can't keep up
+	maxQueued = 8192
+)
+
+type exporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+	flush chan bool
+}
+
+// Setup enables tracing, exporting spans to the OTLP/HTTP collector at
+// endpoint (e.g. http://localhost:4318).
+func Setup(endpoint, service string) {
+	e := &exporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		flush:   make(chan bool, 1),
+	}
+	exp = e
+	go e.run()
+}
+
+func (e *exporter) add(span *Span) {
+	e.mu.Lock()
+	if len(e.spans) < maxQueued {
+		e.spans = append(e.spans, span)
+	}
+	full := len(e.spans) >= exportBatch
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flush <- true:
+		default:
+		}
+	}
+}
+
+func (e *exporter) run() {
+	ticker := time.NewTicker(exportInterval)
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		}
+		e.mu.Lock()
+		spans := e.spans
+		e.spans = nil
+		e.mu.Unlock()
+		for len(spans) > 0 {
+			n := len(spans)
+			if n > exportBatch {
+				n = exportBatch
+			}
+			if err := e.export(spans[:n]); err != nil {
+				log.Printf("Failed to export %d spans: %s", n, err)
+			}
+			spans = spans[n:]
+		}
+	}
+}
+
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+// The types below follow the JSON encoding of the OTLP protobuf messages:
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         SpanKind   `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+	Status       otlpStatus `json:"status"`
+}
+
+func attrValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	}
+	s := fmt.Sprint(value)
+	return otlpValue{StringValue: &s}
+}
+
+func attrList(attrs map[string]interface{}) []otlpAttr {
+	list := make([]otlpAttr, 0, len(attrs))
+	for key, value := range attrs {
+		list = append(list, otlpAttr{Key: key, Value: attrValue(value)})
+	}
+	return list
+}
+
+func (e *exporter) payload(spans []*Span) interface{} {
+	list := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		list[i] = otlpSpan{
+			TraceID:    fmt.Sprintf("%x", s.traceID),
+			SpanID:     fmt.Sprintf("%x", s.spanID),
+			Name:       s.name,
+			Kind:       s.kind,
+			Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+			End:        strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes: attrList(s.attrs),
+			Status:     otlpStatus{Code: 1},
+		}
+		if s.parentID != (SpanID{}) {
+			list[i].ParentSpanID = fmt.Sprintf("%x", s.parentID)
+		}
+		if s.err != nil {
+			list[i].Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": attrList(map[string]interface{}{"service.name": e.service}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "github.com/nkanaev/yarr"},
+						"spans": list,
+					},
+				},
+			},
+		},
+	}
+}
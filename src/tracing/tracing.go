@@ -0,0 +1,141 @@
+// Package tracing records spans and exports them to an OpenTelemetry
+// collector using OTLP over HTTP with JSON encoding.
+//
+// Tracing is disabled until Setup is called: spans are then nil and
+// all of their methods are no-ops.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type SpanKind int
+
+// Values as defined by OTLP.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+type Span struct {
+	name     string
+	kind     SpanKind
+	traceID  TraceID
+	spanID   SpanID
+	parentID SpanID
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	err      error
+}
+
+type spanKey struct{}
+
+var exp *exporter
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return exp != nil
+}
+
+// Start creates a span, child of the one carried by ctx (if any).
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if exp == nil {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now(), attrs: make(map[string]interface{})}
+	if parent := FromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartRemote is like Start, but continues the trace of an incoming
+// request carrying a W3C `traceparent` header.
+func StartRemote(r *http.Request, name string) (context.Context, *Span) {
+	ctx, span := Start(r.Context(), name, KindServer)
+	if span == nil {
+		return ctx, nil
+	}
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) == 4 && parts[0] == "00" {
+		traceID, err1 := hex.DecodeString(parts[1])
+		parentID, err2 := hex.DecodeString(parts[2])
+		if err1 == nil && err2 == nil && len(traceID) == 16 && len(parentID) == 8 {
+			copy(span.traceID[:], traceID)
+			copy(span.parentID[:], parentID)
+		}
+	}
+	return ctx, span
+}
+
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttr sets an attribute. Supported values are strings, bools,
+// integers and floats, anything else is formatted as a string.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err
+}
+
+// AddChild records an already finished child span, for operations
+// timed elsewhere (e.g. database queries).
+func (s *Span) AddChild(name string, start time.Time, d time.Duration, attrs map[string]interface{}) {
+	if s == nil || exp == nil {
+		return
+	}
+	child := &Span{
+		name:     name,
+		kind:     KindClient,
+		traceID:  s.traceID,
+		parentID: s.spanID,
+		start:    start,
+		end:      start.Add(d),
+		attrs:    attrs,
+	}
+	rand.Read(child.spanID[:])
+	exp.add(child)
+}
+
+func (s *Span) End() {
+	if s == nil || exp == nil {
+		return
+	}
+	s.end = time.Now()
+	exp.add(s)
+}
+
+// TraceParent returns the W3C `traceparent` header value for outgoing requests.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop", KindInternal)
+	if span != nil || FromContext(ctx) != nil {
+		t.Fatal("expected no span while disabled")
+	}
+	// must not panic
+	span.SetAttr("key", "value")
+	span.SetError(errors.New("error"))
+	span.End()
+}
+
+func TestExport(t *testing.T) {
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("invalid path: %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	Setup(server.URL, "test")
+	defer func() { exp = nil }()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx, root := StartRemote(req, "request")
+	_, child := Start(ctx, "child", KindInternal)
+	child.SetAttr("count", 3)
+	child.SetError(errors.New("failed"))
+	child.End()
+	root.AddChild("db.query", time.Now(), time.Millisecond, nil)
+	root.End()
+
+	exp.mu.Lock()
+	spans := exp.spans
+	exp.spans = nil
+	exp.mu.Unlock()
+	if err := exp.export(spans); err != nil {
+		t.Fatal(err)
+	}
+
+	have := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(have) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(have))
+	}
+	for _, span := range have {
+		if span.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("span %s not part of the remote trace: %s", span.Name, span.TraceID)
+		}
+	}
+	if have[0].Name != "child" || have[0].ParentSpanID != have[2].SpanID || have[0].Status.Code != 2 {
+		t.Errorf("invalid child span: %#v", have[0])
+	}
+	if have[2].ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("invalid root span parent: %#v", have[2])
+	}
+}
@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/nkanaev/yarr/src/client"
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"github.com/nkanaev/yarr/src/content/sanitizer"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// the page size of the web interface
+const pageSize = 20
+
+type storageBackend struct {
+	db *storage.Storage
+}
+
+// NewStorageBackend reads the items from the database directly,
+// e.g. on a machine without a running instance.
+func NewStorageBackend(db *storage.Storage) Backend {
+	return &storageBackend{db: db}
+}
+
+func (b *storageBackend) Feeds() ([]client.Feed, error) {
+	feeds, err := b.db.ListFeeds()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]client.Feed, len(feeds))
+	for i, f := range feeds {
+		result[i] = client.Feed{Id: f.Id, FolderId: f.FolderId, Title: f.Title, Link: f.Link, FeedLink: f.FeedLink}
+	}
+	return result, nil
+}
+
+func (b *storageBackend) Stats() ([]client.Stat, error) {
+	stats, err := b.db.FeedStats()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]client.Stat, len(stats))
+	for i, s := range stats {
+		result[i] = client.Stat{FeedId: s.FeedId, Unread: s.UnreadCount, Starred: s.StarredCount}
+	}
+	return result, nil
+}
+
+func (b *storageBackend) Items(q client.ItemQuery) ([]client.Item, bool, error) {
+	filter := storage.ItemFilter{FolderID: q.FolderId, FeedID: q.FeedId, After: q.After}
+	if q.Status != "" {
+		status, ok := storage.StatusValues[q.Status]
+		if !ok {
+			return nil, false, fmt.Errorf("invalid status %q", q.Status)
+		}
+		filter.Status = &status
+	}
+	if q.Search != "" {
+		filter.Search = &q.Search
+	}
+	items, err := b.db.ListItems(filter, pageSize+1, true, false)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+	}
+	result := make([]client.Item, len(items))
+	for i, item := range items {
+		result[i] = client.Item{
+			Id:     item.Id,
+			FeedId: item.FeedId,
+			Title:  item.Title,
+			Link:   item.Link,
+			Author: item.Author,
+			Date:   item.Date,
+			Status: storage.StatusRepresentations[item.Status],
+		}
+	}
+	return result, hasMore, nil
+}
+
+func (b *storageBackend) ItemText(id int64) (string, error) {
+	item, err := b.db.GetItem(id)
+	if err != nil {
+		return "", err
+	}
+	return htmlutil.PlainText(sanitizer.Sanitize(item.Link, item.Content)), nil
+}
+
+func (b *storageBackend) SetStatus(id int64, status string) error {
+	value, ok := storage.StatusValues[status]
+	if !ok {
+		return fmt.Errorf("invalid status %q", status)
+	}
+	return b.db.UpdateItemStatus(id, value)
+}
@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// stty changes or queries the settings of the terminal.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func size(tty *os.File) (width, height int) {
+	out, err := stty(tty, "size")
+	if err == nil {
+		fmt.Sscan(out, &height, &width)
+	}
+	return
+}
+
+var keys = map[string]string{
+	"\x03":    "ctrl-c",
+	"\x1b":    "esc",
+	"\r":      "enter",
+	"\n":      "enter",
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1b[C":  "right",
+	"\x1b[D":  "left",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1bOC":  "right",
+	"\x1bOD":  "left",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdown",
+}
+
+// parseKey names the special keys, the rest are returned as typed.
+func parseKey(input []byte) string {
+	if name, ok := keys[string(input)]; ok {
+		return name
+	}
+	return string(input)
+}
+
+// Run takes over the terminal until the user quits.
+func Run(backend Backend, tty *os.File, out io.Writer) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("the terminal interface isn't supported on windows")
+	}
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return fmt.Errorf("not a terminal: %s", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return err
+	}
+	defer stty(tty, saved)
+
+	// alternate screen, hidden cursor
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	defer io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+
+	app := New(backend)
+	app.Resize(size(tty))
+	app.Load()
+	buf := make([]byte, 32)
+	for {
+		app.Render(out)
+		n, err := tty.Read(buf)
+		if err != nil {
+			return err
+		}
+		app.Resize(size(tty))
+		if app.HandleKey(parseKey(buf[:n])) {
+			return nil
+		}
+	}
+}
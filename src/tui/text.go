@@ -0,0 +1,40 @@
+package tui
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// wrap breaks the text into lines of at most width runes
+// (longer words are cut), keeping the empty lines.
+func wrap(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	lines := make([]string, 0)
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,421 @@
+// Package tui is a keyboard-driven terminal client, reading either
+// from a running instance (see the client package) or from the
+// database directly.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nkanaev/yarr/src/client"
+)
+
+// Backend is where the items come from.
+type Backend interface {
+	Feeds() ([]client.Feed, error)
+	Stats() ([]client.Stat, error)
+	// Items returns a page of the matching items, newest first,
+	// and whether there are more.
+	Items(q client.ItemQuery) ([]client.Item, bool, error)
+	// ItemText returns the content of the item as plain text.
+	ItemText(id int64) (string, error)
+	SetStatus(id int64, status string) error
+}
+
+type view int
+
+const (
+	feedsView view = iota
+	itemsView
+	readerView
+)
+
+// the filters cycled through with "f", as in the web interface
+var filters = []string{client.Unread, "", client.Starred}
+
+type feedEntry struct {
+	// nil for all feeds
+	feed    *client.Feed
+	unread  int64
+	starred int64
+}
+
+func (e feedEntry) title() string {
+	if e.feed == nil {
+		return "All feeds"
+	}
+	return e.feed.Title
+}
+
+// App holds the state of the interface, it's driven by HandleKey
+// and drawn by Render.
+type App struct {
+	backend Backend
+
+	width  int
+	height int
+
+	view   view
+	filter int
+
+	feeds      []feedEntry
+	feedCursor int
+	feedTitles map[int64]string
+
+	items      []client.Item
+	hasMore    bool
+	itemCursor int
+
+	// the text of the open item and its lines wrapped to the width
+	text   string
+	lines  []string
+	scroll int
+
+	// the last error or notice, shown at the bottom
+	message string
+}
+
+func New(backend Backend) *App {
+	return &App{backend: backend, width: 80, height: 24}
+}
+
+func (a *App) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if width != a.width && a.text != "" {
+		a.lines = wrap(a.text, width)
+	}
+	a.width, a.height = width, height
+}
+
+// Load (re)loads the feeds and their counters.
+func (a *App) Load() {
+	feeds, err := a.backend.Feeds()
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	stats, err := a.backend.Stats()
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	counters := make(map[int64]client.Stat, len(stats))
+	all := feedEntry{}
+	for _, s := range stats {
+		counters[s.FeedId] = s
+		all.unread += s.Unread
+		all.starred += s.Starred
+	}
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return strings.ToLower(feeds[i].Title) < strings.ToLower(feeds[j].Title)
+	})
+	a.feeds = []feedEntry{all}
+	a.feedTitles = make(map[int64]string, len(feeds))
+	for i := range feeds {
+		feed := &feeds[i]
+		a.feedTitles[feed.Id] = feed.Title
+		entry := feedEntry{feed: feed, unread: counters[feed.Id].Unread, starred: counters[feed.Id].Starred}
+		// only the feeds with items in the current filter, like the web interface
+		if filters[a.filter] == client.Unread && entry.unread == 0 ||
+			filters[a.filter] == client.Starred && entry.starred == 0 {
+			continue
+		}
+		a.feeds = append(a.feeds, entry)
+	}
+	if a.feedCursor >= len(a.feeds) {
+		a.feedCursor = 0
+	}
+}
+
+func (a *App) loadItems(more bool) {
+	query := client.ItemQuery{Status: filters[a.filter]}
+	if feed := a.feeds[a.feedCursor].feed; feed != nil {
+		query.FeedId = &feed.Id
+	}
+	if more && len(a.items) > 0 {
+		query.After = &a.items[len(a.items)-1].Id
+	}
+	items, hasMore, err := a.backend.Items(query)
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	if more {
+		a.items = append(a.items, items...)
+	} else {
+		a.items, a.itemCursor = items, 0
+	}
+	a.hasMore = hasMore
+}
+
+func (a *App) openItem() {
+	item := &a.items[a.itemCursor]
+	text, err := a.backend.ItemText(item.Id)
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	a.text = item.Title + "\n"
+	if item.Author != "" {
+		a.text += item.Author + ", "
+	}
+	a.text += item.Date.Local().Format("2006-01-02 15:04") + "\n" + item.Link + "\n\n" + text
+	a.lines = wrap(a.text, a.width)
+	if a.view != readerView {
+		a.view, a.scroll = readerView, 0
+	}
+	if item.Status == client.Unread {
+		a.setStatus(item, client.Read)
+	}
+}
+
+func (a *App) setStatus(item *client.Item, status string) {
+	if err := a.backend.SetStatus(item.Id, status); err != nil {
+		a.message = err.Error()
+		return
+	}
+	item.Status = status
+}
+
+// pageSize is the number of list rows or text lines on the screen,
+// without the header and the footer.
+func (a *App) pageSize() int {
+	if a.height < 3 {
+		return 1
+	}
+	return a.height - 2
+}
+
+func move(cursor, delta, length int) int {
+	cursor += delta
+	if cursor >= length {
+		cursor = length - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	return cursor
+}
+
+// HandleKey applies the key (see parseKey), returning
+// whether the user wants to quit.
+func (a *App) HandleKey(key string) bool {
+	a.message = ""
+	if key == "ctrl-c" {
+		return true
+	}
+	switch a.view {
+	case feedsView:
+		switch key {
+		case "q", "esc":
+			return true
+		case "j", "down":
+			a.feedCursor = move(a.feedCursor, 1, len(a.feeds))
+		case "k", "up":
+			a.feedCursor = move(a.feedCursor, -1, len(a.feeds))
+		case "f":
+			a.filter = (a.filter + 1) % len(filters)
+			a.Load()
+		case "r":
+			a.Load()
+		case "l", "right", "enter":
+			if len(a.feeds) > 0 {
+				a.loadItems(false)
+				a.view = itemsView
+			}
+		}
+	case itemsView:
+		switch key {
+		case "q", "esc", "h", "left":
+			a.view = feedsView
+			a.Load()
+		case "j", "down":
+			a.itemCursor = move(a.itemCursor, 1, len(a.items))
+		case "k", "up":
+			a.itemCursor = move(a.itemCursor, -1, len(a.items))
+		case "pgdown", " ":
+			a.itemCursor = move(a.itemCursor, a.pageSize(), len(a.items))
+		case "pgup":
+			a.itemCursor = move(a.itemCursor, -a.pageSize(), len(a.items))
+		case "r":
+			a.loadItems(false)
+		case "s", "m":
+			if len(a.items) > 0 {
+				a.toggle(key)
+			}
+		case "l", "right", "enter":
+			if len(a.items) > 0 {
+				a.openItem()
+			}
+		}
+		if a.view == itemsView && a.hasMore && a.itemCursor >= len(a.items)-1 {
+			a.loadItems(true)
+		}
+	case readerView:
+		switch key {
+		case "q", "esc", "h", "left":
+			a.view = itemsView
+		case "j", "down":
+			a.scroll = move(a.scroll, 1, len(a.lines))
+		case "k", "up":
+			a.scroll = move(a.scroll, -1, len(a.lines))
+		case "pgdown", " ":
+			a.scroll = move(a.scroll, a.pageSize(), len(a.lines))
+		case "pgup":
+			a.scroll = move(a.scroll, -a.pageSize(), len(a.lines))
+		case "s", "m":
+			a.toggle(key)
+		case "n", "p":
+			delta := 1
+			if key == "p" {
+				delta = -1
+			}
+			if a.hasMore && a.itemCursor+delta >= len(a.items) {
+				a.loadItems(true)
+			}
+			if next := move(a.itemCursor, delta, len(a.items)); next != a.itemCursor {
+				a.itemCursor = next
+				a.scroll = 0
+				a.openItem()
+			}
+		}
+	}
+	return false
+}
+
+// toggle stars ("s") or marks read ("m") the selected item,
+// or reverts it to read or unread.
+func (a *App) toggle(key string) {
+	item := &a.items[a.itemCursor]
+	switch {
+	case key == "s" && item.Status == client.Starred:
+		a.setStatus(item, client.Read)
+	case key == "s":
+		a.setStatus(item, client.Starred)
+	case key == "m" && item.Status == client.Unread:
+		a.setStatus(item, client.Read)
+	case key == "m":
+		a.setStatus(item, client.Unread)
+	}
+}
+
+// truncate cuts the line to the width, counting runes
+// (wide characters take more space than assumed).
+func truncate(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	if width < 1 {
+		return ""
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func pad(line string, width int) string {
+	line = truncate(line, width)
+	if n := utf8.RuneCountInString(line); n < width {
+		line += strings.Repeat(" ", width-n)
+	}
+	return line
+}
+
+// window returns the range of rows to show so that the cursor is visible.
+func window(cursor, length, size int) (int, int) {
+	start := 0
+	if cursor >= size {
+		start = cursor - size + 1
+	}
+	end := start + size
+	if end > length {
+		end = length
+	}
+	return start, end
+}
+
+var itemMarks = map[string]string{client.Unread: "•", client.Read: " ", client.Starred: "★"}
+
+// Render draws the whole screen.
+func (a *App) Render(w io.Writer) {
+	filterName := map[string]string{client.Unread: "unread", "": "all", client.Starred: "starred"}[filters[a.filter]]
+	var header, help string
+	rows := make([]string, 0, a.pageSize())
+	selected := -1
+
+	switch a.view {
+	case feedsView:
+		header = "yarr · " + filterName
+		help = "j/k move · enter open · f filter · r reload · q quit"
+		start, end := window(a.feedCursor, len(a.feeds), a.pageSize())
+		for i := start; i < end; i++ {
+			entry := a.feeds[i]
+			count := entry.unread
+			if filters[a.filter] == client.Starred {
+				count = entry.starred
+			}
+			countText := ""
+			if count > 0 {
+				countText = fmt.Sprint(count)
+			}
+			title := truncate(entry.title(), a.width-len(countText)-3)
+			rows = append(rows, " "+pad(title, a.width-len(countText)-2)+countText+" ")
+		}
+		selected = a.feedCursor - start
+	case itemsView:
+		header = a.feeds[a.feedCursor].title() + " · " + filterName
+		help = "j/k move · enter read · s star · m read/unread · r reload · q back"
+		start, end := window(a.itemCursor, len(a.items), a.pageSize())
+		for i := start; i < end; i++ {
+			item := a.items[i]
+			date := item.Date.Local().Format("Jan 02")
+			source := ""
+			if a.feeds[a.feedCursor].feed == nil {
+				source = a.feedTitles[item.FeedId] + ": "
+			}
+			rows = append(rows, pad(itemMarks[item.Status]+" "+date+"  "+source+item.Title, a.width))
+		}
+		if len(a.items) == 0 {
+			rows = append(rows, " no items")
+		}
+		selected = a.itemCursor - start
+	case readerView:
+		item := a.items[a.itemCursor]
+		header = a.feedTitles[item.FeedId]
+		if item.Status == client.Starred {
+			header = "★ " + header
+		}
+		help = "j/k scroll · space page · n/p next/prev · s star · m read/unread · q back"
+		end := a.scroll + a.pageSize()
+		if end > len(a.lines) {
+			end = len(a.lines)
+		}
+		for _, line := range a.lines[a.scroll:end] {
+			rows = append(rows, pad(line, a.width))
+		}
+	}
+
+	if a.message != "" {
+		help = a.message
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	b.WriteString("\x1b[1m" + pad(header, a.width) + "\x1b[0m")
+	for i := 0; i < a.pageSize(); i++ {
+		b.WriteString("\r\n")
+		if i >= len(rows) {
+			continue
+		}
+		if i == selected {
+			b.WriteString("\x1b[7m" + rows[i] + "\x1b[0m")
+		} else {
+			b.WriteString(rows[i])
+		}
+	}
+	b.WriteString("\r\n\x1b[2m" + truncate(help, a.width) + "\x1b[0m")
+	io.WriteString(w, b.String())
+}
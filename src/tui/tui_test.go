@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nkanaev/yarr/src/client"
+)
+
+type fakeBackend struct {
+	items []client.Item
+}
+
+func (b *fakeBackend) Feeds() ([]client.Feed, error) {
+	return []client.Feed{{Id: 2, Title: "b feed"}, {Id: 1, Title: "A feed"}}, nil
+}
+
+func (b *fakeBackend) Stats() ([]client.Stat, error) {
+	stats := map[int64]*client.Stat{1: {FeedId: 1}, 2: {FeedId: 2}}
+	for _, item := range b.items {
+		switch item.Status {
+		case client.Unread:
+			stats[item.FeedId].Unread++
+		case client.Starred:
+			stats[item.FeedId].Starred++
+		}
+	}
+	return []client.Stat{*stats[1], *stats[2]}, nil
+}
+
+func (b *fakeBackend) Items(q client.ItemQuery) ([]client.Item, bool, error) {
+	result := make([]client.Item, 0)
+	for _, item := range b.items {
+		if (q.FeedId == nil || *q.FeedId == item.FeedId) && (q.Status == "" || q.Status == item.Status) {
+			result = append(result, item)
+		}
+	}
+	return result, false, nil
+}
+
+func (b *fakeBackend) ItemText(id int64) (string, error) {
+	return fmt.Sprintf("content of item (http://example.com/%d)", id), nil
+}
+
+func (b *fakeBackend) SetStatus(id int64, status string) error {
+	for i := range b.items {
+		if b.items[i].Id == id {
+			b.items[i].Status = status
+		}
+	}
+	return nil
+}
+
+func TestApp(t *testing.T) {
+	backend := &fakeBackend{items: []client.Item{
+		{Id: 1, FeedId: 1, Title: "first", Status: client.Unread, Date: time.Now()},
+		{Id: 2, FeedId: 1, Title: "second", Status: client.Unread, Date: time.Now()},
+		{Id: 3, FeedId: 2, Title: "third", Status: client.Read, Date: time.Now()},
+	}}
+	app := New(backend)
+	app.Load()
+
+	screen := func() string {
+		var b strings.Builder
+		app.Render(&b)
+		return b.String()
+	}
+	// the feeds without unread items are hidden
+	if s := screen(); !strings.Contains(s, "All feeds") || !strings.Contains(s, "A feed") || strings.Contains(s, "b feed") {
+		t.Fatalf("unexpected feed list: %q", s)
+	}
+
+	for _, key := range []string{"j", "enter", "j", "enter"} {
+		app.HandleKey(key)
+	}
+	if s := screen(); !strings.Contains(s, "content of item (http://example.com/2)") {
+		t.Fatalf("item not shown: %q", s)
+	}
+	if backend.items[1].Status != client.Read {
+		t.Fatal("opened item not marked read")
+	}
+
+	app.HandleKey("s")
+	if backend.items[1].Status != client.Starred {
+		t.Fatal("item not starred")
+	}
+	app.HandleKey("p")
+	app.HandleKey("m")
+	if backend.items[0].Status != client.Unread {
+		t.Fatal("item not marked unread")
+	}
+
+	app.HandleKey("q")
+	app.HandleKey("q")
+	app.HandleKey("f")
+	if s := screen(); !strings.Contains(s, "b feed") {
+		t.Fatalf("all feeds not listed: %q", s)
+	}
+	if !app.HandleKey("q") {
+		t.Fatal("expected to quit")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	have := wrap("a bb ccc dddddddd\n\ne", 5)
+	want := []string{"a bb", "ccc", "ddddd", "ddd", "", "e"}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,194 @@
+// Package watch makes feeds out of a value on a web page (a price, a stock
+// level, a version number): the page is fetched on schedule and an item
+// is made when the value changes, or when it crosses a threshold.
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// Condition tells when a change of the value makes an item.
+type Condition string
+
+const (
+	// any change of the value
+	Changed Condition = "changed"
+	// the value (a number) goes above the threshold, or below it
+	Above Condition = "above"
+	Below Condition = "below"
+)
+
+// Rule tells where the value is on the page and when to report it.
+type Rule struct {
+	// the CSS selector of the element holding the value, see htmlutil.Selector
+	Selector string `json:"selector"`
+	// the attribute holding the value (e.g. "content" of a meta tag),
+	// the element's text if empty
+	Attr string `json:"attr,omitempty"`
+	// a regular expression narrowing the value down to its first
+	// group (or to the whole match if it has none), optional
+	Pattern   string    `json:"pattern,omitempty"`
+	Condition Condition `json:"condition"`
+	Threshold float64   `json:"threshold,omitempty"`
+}
+
+// ErrNoValue is returned when the value can't be found on the page.
+var ErrNoValue = errors.New("the value isn't on the page")
+
+// Validate checks the rule, returning a description of the first problem.
+func (r Rule) Validate() error {
+	if strings.TrimSpace(r.Selector) == "" {
+		return errors.New("the selector is required")
+	}
+	if _, err := htmlutil.ParseSelector(r.Selector); err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return err
+	}
+	switch r.Condition {
+	case Changed, Above, Below:
+	default:
+		return fmt.Errorf("unknown condition %q", r.Condition)
+	}
+	return nil
+}
+
+// Extract finds the value on the page, in the charset (utf-8 if empty).
+// The value's whitespace is collapsed.
+func (r Rule) Extract(page io.Reader, cs string) (string, error) {
+	sel, err := htmlutil.ParseSelector(r.Selector)
+	if err != nil {
+		return "", err
+	}
+	if cs != "" {
+		if decoded, err := charset.NewReaderLabel(cs, page); err == nil {
+			page = decoded
+		}
+	}
+	doc, err := html.Parse(page)
+	if err != nil {
+		return "", err
+	}
+	node := htmlutil.First(doc, sel.Match)
+	if node == nil {
+		return "", ErrNoValue
+	}
+	var value string
+	if r.Attr != "" {
+		value = htmlutil.Attr(node, strings.ToLower(r.Attr))
+	} else {
+		var buf bytes.Buffer
+		text(node, &buf)
+		value = buf.String()
+	}
+	value = strings.Join(strings.Fields(value), " ")
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return "", err
+		}
+		m := re.FindStringSubmatch(value)
+		if m == nil {
+			return "", ErrNoValue
+		}
+		value = m[0]
+		if len(m) > 1 {
+			value = m[1]
+		}
+		value = strings.TrimSpace(value)
+	}
+	if value == "" {
+		return "", ErrNoValue
+	}
+	return value, nil
+}
+
+func text(n *html.Node, buf *bytes.Buffer) {
+	switch {
+	case n.Type == html.TextNode:
+		buf.WriteString(n.Data)
+		return
+	case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text(c, buf)
+	}
+}
+
+// Triggered tells whether the value, following the previous one
+// (nil if there's none), makes an item. The first value is only
+// reported if it's already past the threshold.
+func (r Rule) Triggered(previous *string, value string) (bool, error) {
+	if r.Condition == Changed {
+		return previous != nil && *previous != value, nil
+	}
+	n, err := ParseNumber(value)
+	if err != nil {
+		return false, err
+	}
+	if !r.past(n) {
+		return false, nil
+	}
+	if previous != nil {
+		if p, err := ParseNumber(*previous); err == nil && r.past(p) {
+			// still past the threshold
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r Rule) past(n float64) bool {
+	if r.Condition == Above {
+		return n > r.Threshold
+	}
+	return n < r.Threshold
+}
+
+var numberRe = regexp.MustCompile(`-?[0-9][0-9.,' \x{a0}\x{202f}]*`)
+
+var groupSeparators = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "")
+
+// ParseNumber reads the first number of the text, with either decimal
+// separator: "$1,299.99", "1.299,99 €", "12,5" and "1 299" are all read.
+// A single comma followed by three digits is taken for a thousands
+// separator.
+func ParseNumber(s string) (float64, error) {
+	m := numberRe.FindString(s)
+	m = groupSeparators.Replace(strings.TrimRight(m, ".,' \u00a0\u202f"))
+	if m == "" {
+		return 0, fmt.Errorf("no number in %q", s)
+	}
+	dot, comma := strings.LastIndex(m, "."), strings.LastIndex(m, ",")
+	switch {
+	case dot >= 0 && comma > dot:
+		m = strings.Replace(strings.ReplaceAll(m, ".", ""), ",", ".", 1)
+	case dot >= 0 && comma >= 0:
+		m = strings.ReplaceAll(m, ",", "")
+	case comma >= 0:
+		if strings.Count(m, ",") == 1 && len(m)-comma-1 != 3 {
+			m = strings.Replace(m, ",", ".", 1)
+		} else {
+			m = strings.ReplaceAll(m, ",", "")
+		}
+	case strings.Count(m, ".") > 1:
+		m = strings.ReplaceAll(m, ".", "")
+	}
+	n, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, fmt.Errorf("no number in %q", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,108 @@
+package watch
+
+import (
+	"strings"
+	"testing"
+)
+
+const page = `<!DOCTYPE html>
+<html><head>
+	<meta itemprop="price" content="1299.00">
+	<script>var price = "0.00"</script>
+</head><body>
+	<div id="main" class="product card">
+		<h1>Widget</h1>
+		<p class="price"><span class="currency">$</span>1,299.<small>99</small></p>
+		<ul class="stock"><li><span>In stock: 12</span></li></ul>
+	</div>
+	<p class="price">$5</p>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Selector: "p.price"}, "$1,299.99"},
+		{Rule{Selector: "#main > .price"}, "$1,299.99"},
+		{Rule{Selector: "body > .price"}, "$5"},
+		{Rule{Selector: "div.card.product h1"}, "Widget"},
+		{Rule{Selector: "meta[itemprop=price]", Attr: "content"}, "1299.00"},
+		{Rule{Selector: `meta[itemprop="price"]`, Attr: "Content"}, "1299.00"},
+		{Rule{Selector: ".stock span", Pattern: `stock: (\d+)`}, "12"},
+		{Rule{Selector: ".stock", Pattern: `\d+`}, "12"},
+		{Rule{Selector: "h2, ul > li"}, "In stock: 12"},
+	}
+	for _, c := range cases {
+		have, err := c.rule.Extract(strings.NewReader(page), "")
+		if err != nil {
+			t.Errorf("%q: %s", c.rule.Selector, err)
+		} else if have != c.want {
+			t.Errorf("%q: want %q, have %q", c.rule.Selector, c.want, have)
+		}
+	}
+
+	for _, rule := range []Rule{
+		{Selector: "table"},
+		{Selector: "#main > span"},
+		{Selector: "h1", Pattern: `\d`},
+		{Selector: "h1", Attr: "title"},
+	} {
+		if _, err := rule.Extract(strings.NewReader(page), ""); err != ErrNoValue {
+			t.Errorf("%q: want ErrNoValue, have %v", rule.Selector, err)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	cases := map[string]float64{
+		"$1,299.99":     1299.99,
+		"1.299,99 €":    1299.99,
+		"12,5":          12.5,
+		"1,299":         1299,
+		"1 299 Kč":      1299,
+		"1 299,00 zł":   1299,
+		"CHF 1'299.50":  1299.5,
+		"-3.5%":         -3.5,
+		"In stock: 12.": 12,
+	}
+	for s, want := range cases {
+		have, err := ParseNumber(s)
+		if err != nil || have != want {
+			t.Errorf("%q: want %v, have %v (%v)", s, want, have, err)
+		}
+	}
+	if _, err := ParseNumber("sold out"); err == nil {
+		t.Error("want an error")
+	}
+}
+
+func TestTriggered(t *testing.T) {
+	str := func(s string) *string { return &s }
+	cases := []struct {
+		rule     Rule
+		previous *string
+		value    string
+		want     bool
+	}{
+		{Rule{Condition: Changed}, nil, "a", false},
+		{Rule{Condition: Changed}, str("a"), "a", false},
+		{Rule{Condition: Changed}, str("a"), "b", true},
+		{Rule{Condition: Below, Threshold: 100}, nil, "$99", true},
+		{Rule{Condition: Below, Threshold: 100}, nil, "$100", false},
+		{Rule{Condition: Below, Threshold: 100}, str("$120"), "$99", true},
+		{Rule{Condition: Below, Threshold: 100}, str("$98"), "$99", false},
+		{Rule{Condition: Below, Threshold: 100}, str("sold out"), "$99", true},
+		{Rule{Condition: Above, Threshold: 10}, str("9"), "11", true},
+		{Rule{Condition: Above, Threshold: 10}, str("11"), "9", false},
+	}
+	for _, c := range cases {
+		have, err := c.rule.Triggered(c.previous, c.value)
+		if err != nil || have != c.want {
+			t.Errorf("%s %v, %v -> %q: want %v, have %v (%v)", c.rule.Condition, c.rule.Threshold, c.previous, c.value, c.want, have, err)
+		}
+	}
+	if _, err := (Rule{Condition: Above}).Triggered(nil, "sold out"); err == nil {
+		t.Error("want an error for a value without a number")
+	}
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nkanaev/yarr/src/notify"
+)
+
+// StartBacklog snapshots the unread items hourly (the last snapshot of
+// a day is the one kept, see storage.RecordBacklog) and sends the
+// notifications of the routes whose backlog threshold is reached.
+func (w *Worker) StartBacklog() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for {
+			if err := w.db.RecordBacklog(time.Now()); err != nil {
+				log.Print(err)
+			}
+			w.SendBacklogNotifications()
+			<-ticker.C
+		}
+	}()
+}
+
+// SendBacklogNotifications notifies about the backlogs past their threshold.
+func (w *Worker) SendBacklogNotifications() {
+	pending, err := w.db.PendingBacklogNotifications()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, b := range pending {
+		scope := b.Scope
+		if scope == "" {
+			scope = "All feeds"
+		}
+		item := notify.Item{
+			Title:     fmt.Sprintf("%d unread items (threshold: %d)", b.Unread, b.Route.BacklogThreshold),
+			FeedTitle: scope,
+		}
+		if err := SendNotification(b.Notifier, []notify.Item{item}); err != nil {
+			log.Printf("notifier %q: %s", b.Notifier.Name, err)
+			continue
+		}
+		if err := w.db.MarkBacklogNotified(b.Route.Id); err != nil {
+			log.Print(err)
+		}
+	}
+}
@@ -3,23 +3,52 @@ package worker
 import (
 	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type Client struct {
 	httpClient *http.Client
 	userAgent  string
+
+	mu    sync.Mutex
+	stats map[string]*ProtocolStats
+}
+
+// ProtocolStats counts the responses received over a protocol version
+// ("HTTP/1.1", "HTTP/2.0") and the time spent waiting for their headers.
+type ProtocolStats struct {
+	Requests int64         `json:"requests"`
+	Duration time.Duration `json:"duration_ns"`
 }
 
 func (c *Client) get(url string) (*http.Response, error) {
-	return c.getConditional(url, "", "")
+	return c.getConditional(url, "", "", "", nil)
+}
+
+func (c *Client) getConditional(url, lastModified, etag, acceptLanguage string, header http.Header) (*http.Response, error) {
+	return c.do("GET", url, lastModified, etag, acceptLanguage, header)
+}
+
+// getAPI requests a JSON API with the given headers (e.g. a token).
+func (c *Client) getAPI(url, lastModified, etag string, header http.Header) (*http.Response, error) {
+	return c.do("GET", url, lastModified, etag, "", header)
+}
+
+// headConditional asks for the headers only, which is enough to tell
+// whether the document has changed since the validators were issued.
+func (c *Client) headConditional(url, lastModified, etag, acceptLanguage string, header http.Header) (*http.Response, error) {
+	return c.do("HEAD", url, lastModified, etag, acceptLanguage, header)
 }
 
-func (c *Client) getConditional(url, lastModified, etag string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) do(method, url, lastModified, etag, acceptLanguage string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
 	req.Header.Set("User-Agent", c.userAgent)
 	if lastModified != "" {
 		req.Header.Set("If-Modified-Since", lastModified)
@@ -27,18 +56,54 @@ func (c *Client) getConditional(url, lastModified, etag string) (*http.Response,
 	if etag != "" {
 		req.Header.Set("If-None-Match", etag)
 	}
-	return c.httpClient.Do(req)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	start := time.Now()
+	res, err := c.httpClient.Do(req)
+	if err == nil {
+		c.record(res.Proto, time.Since(start))
+	}
+	return res, err
+}
+
+func (c *Client) record(proto string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats[proto]
+	if stats == nil {
+		stats = &ProtocolStats{}
+		c.stats[proto] = stats
+	}
+	stats.Requests++
+	stats.Duration += d
+}
+
+// FetchStats returns the fetcher's response counts per protocol version.
+func FetchStats() map[string]ProtocolStats {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	result := make(map[string]ProtocolStats, len(client.stats))
+	for proto, stats := range client.stats {
+		result[proto] = *stats
+	}
+	return result
 }
 
 var client *Client
 
 func init() {
+	// Connections are kept alive so that feeds served from the same
+	// host (typically a CDN) share one HTTP/2 connection. HTTP/2 has
+	// to be requested explicitly since the dialer is customized.
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
 			Timeout: 10 * time.Second,
 		}).DialContext,
-		DisableKeepAlives:   true,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
 		TLSHandshakeTimeout: time.Second * 10,
 	}
 	httpClient := &http.Client{
@@ -48,5 +113,6 @@ func init() {
 	client = &Client{
 		httpClient: httpClient,
 		userAgent:  "Yarr/1.0",
+		stats:      make(map[string]*ProtocolStats),
 	}
 }
@@ -5,11 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime"
 	"net/http"
-	"net/url"
 	"strings"
+	"time"
 
 	"github.com/nkanaev/yarr/src/content/scraper"
 	"github.com/nkanaev/yarr/src/parser"
@@ -30,6 +29,10 @@ type DiscoverResult struct {
 
 func DiscoverFeed(candidateUrl string) (*DiscoverResult, error) {
 	result := &DiscoverResult{}
+	// calendar subscription links, served over https nowadays
+	if strings.HasPrefix(strings.ToLower(candidateUrl), "webcal://") {
+		candidateUrl = "https://" + candidateUrl[len("webcal://"):]
+	}
 	// Query URL
 	res, err := client.get(candidateUrl)
 	if err != nil {
@@ -81,126 +84,120 @@ func DiscoverFeed(candidateUrl string) (*DiscoverResult, error) {
 	return result, nil
 }
 
-var emptyIcon = make([]byte, 0)
-var imageTypes = map[string]bool{
-	"image/x-icon": true,
-	"image/png":    true,
-	"image/jpeg":   true,
-	"image/gif":    true,
-}
-
-func findFavicon(siteUrl, feedUrl string) (*[]byte, error) {
-	urls := make([]string, 0)
-
-	favicon := func(link string) string {
-		u, err := url.Parse(link)
-		if err != nil {
-			return ""
-		}
-		return fmt.Sprintf("%s://%s/favicon.ico", u.Scheme, u.Host)
-	}
-
-	if siteUrl != "" {
-		if res, err := client.get(siteUrl); err == nil {
-			defer res.Body.Close()
-			if body, err := ioutil.ReadAll(res.Body); err == nil {
-				urls = append(urls, scraper.FindIcons(string(body), siteUrl)...)
-				if c := favicon(siteUrl); c != "" {
-					urls = append(urls, c)
-				}
-			}
-		}
-	}
-
-	if c := favicon(feedUrl); c != "" {
-		urls = append(urls, c)
-	}
-
-	for _, u := range urls {
-		res, err := client.get(u)
-		if err != nil {
-			continue
-		}
-		defer res.Body.Close()
-		if res.StatusCode != 200 {
-			continue
-		}
-
-		content, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			continue
-		}
-
-		ctype := http.DetectContentType(content)
-		if imageTypes[ctype] {
-			return &content, nil
-		}
-	}
-	return &emptyIcon, nil
-}
-
 func ConvertItems(items []parser.Item, feed storage.Feed) []storage.Item {
 	result := make([]storage.Item, len(items))
 	for i, item := range items {
 		item := item
-		var audioURL *string = nil
-		if item.AudioURL != "" {
-			audioURL = &item.AudioURL
-		}
 		var imageURL *string = nil
 		if item.ImageURL != "" {
 			imageURL = &item.ImageURL
 		}
+		var dateUpdated *time.Time = nil
+		if !item.Updated.IsZero() {
+			dateUpdated = &item.Updated
+		}
 		result[i] = storage.Item{
-			GUID:     item.GUID,
+			GUID:     storage.ItemGUID(feed.GUIDStrategy, item.GUID, item.URL, item.Title, item.Content),
 			FeedId:   feed.Id,
 			Title:    item.Title,
 			Link:     item.URL,
+			Author:   item.Author,
+			Language: item.Language,
 			Content:  item.Content,
 			Date:     item.Date,
 			Status:   storage.UNREAD,
 			ImageURL: imageURL,
-			AudioURL: audioURL,
+			Podcast:  convertPodcast(item.Podcast),
+
+			DateUpdated: dateUpdated,
+		}
+		for _, e := range item.Enclosures {
+			result[i].Enclosures = append(result[i].Enclosures, storage.Enclosure{
+				URL:    e.URL,
+				Type:   e.Type,
+				Length: e.Length,
+			})
+		}
+		if item.Location != nil {
+			result[i].Latitude = &item.Location.Latitude
+			result[i].Longitude = &item.Location.Longitude
+		}
+		if item.Incident != nil {
+			result[i].Incident = &storage.Incident{
+				Status:   item.Incident.Status,
+				Severity: storage.SeverityValues[item.Incident.Severity],
+			}
 		}
 	}
 	return result
 }
 
-func listItems(f storage.Feed, db *storage.Storage) ([]storage.Item, error) {
-	lmod := ""
-	etag := ""
-	if state := db.GetHTTPState(f.Id); state != nil {
-		lmod = state.LastModified
-		etag = state.Etag
+func convertPodcast(podcast *parser.Podcast) *storage.ItemPodcast {
+	if podcast == nil {
+		return nil
+	}
+	result := &storage.ItemPodcast{
+		ChaptersURL:  podcast.ChaptersURL,
+		ChaptersType: podcast.ChaptersType,
+	}
+	for _, t := range podcast.Transcripts {
+		result.Transcripts = append(result.Transcripts, storage.Transcript(t))
 	}
+	for _, p := range podcast.Persons {
+		result.Persons = append(result.Persons, storage.Person(p))
+	}
+	return result
+}
 
-	res, err := client.getConditional(f.FeedLink, lmod, etag)
+// GetChapters fetches and parses the podcast chapters file.
+func GetChapters(url string) ([]storage.Chapter, error) {
+	res, err := client.get(url)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
-
-	switch {
-	case res.StatusCode < 200 || res.StatusCode > 399:
-		if res.StatusCode == 404 {
-			return nil, fmt.Errorf("feed not found")
-		}
+	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("status code %d", res.StatusCode)
-	case res.StatusCode == http.StatusNotModified:
-		return nil, nil
 	}
-
-	feed, err := parser.ParseAndFix(res.Body, f.FeedLink, getCharset(res))
+	chapters, err := parser.ParseChapters(res.Body)
 	if err != nil {
 		return nil, err
 	}
+	result := make([]storage.Chapter, len(chapters))
+	for i, c := range chapters {
+		result[i] = storage.Chapter(c)
+	}
+	return result, nil
+}
+
+// DormantFeedAfter is how long a feed has to go without new items
+// before it's probed with HEAD requests instead of being fetched.
+var DormantFeedAfter = time.Hour * 24 * 90
 
-	lmod = res.Header.Get("Last-Modified")
-	etag = res.Header.Get("Etag")
-	if lmod != "" || etag != "" {
-		db.SetHTTPState(f.Id, lmod, etag)
+// probeUnchanged issues a conditional HEAD request and reports whether
+// the feed is known not to have changed. Any doubt (no validators,
+// HEAD not supported, validators differ) is resolved by a full fetch.
+func probeUnchanged(f storage.Feed, state *storage.HTTPState, header http.Header) bool {
+	if state == nil || (state.LastModified == "" && state.Etag == "") {
+		return false
+	}
+	res, err := client.headConditional(f.FeedLink, state.LastModified, state.Etag, f.AcceptLanguage, header)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return true
+	case http.StatusOK:
+		etag := res.Header.Get("Etag")
+		lmod := res.Header.Get("Last-Modified")
+		if etag == "" && lmod == "" {
+			return false
+		}
+		return etag == state.Etag && lmod == state.LastModified
 	}
-	return ConvertItems(feed.Items, f), nil
+	return false
 }
 
 func getCharset(res *http.Response) string {
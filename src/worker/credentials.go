@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"net/http"
+
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// feedHeader returns the headers authenticating the requests of the feed,
+// nil if it has no credentials (see storage.FeedCredentials).
+func feedHeader(db *storage.Storage, f storage.Feed) (http.Header, error) {
+	if !f.HasCredentials {
+		return nil, nil
+	}
+	creds, err := db.GetFeedCredentials(f.Id)
+	if err != nil || creds == nil {
+		return nil, err
+	}
+	return credentialsHeader(*creds), nil
+}
+
+func credentialsHeader(creds storage.FeedCredentials) http.Header {
+	header := make(http.Header)
+	if creds.HeaderName != "" {
+		header.Set(creds.HeaderName, creds.HeaderValue)
+	} else {
+		// for the encoding of the Authorization header
+		req := http.Request{Header: header}
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	return header
+}
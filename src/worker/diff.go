@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// Why a remote entry is (not) stored locally, see DiffFeed.
+const (
+	DiffStored = "stored"
+	// another entry of the document has the same guid
+	DiffDuplicate = "duplicate"
+	// older than the retention period, may have been cleaned up
+	DiffRetention = "retention"
+	// not stored yet, the feed hasn't been refreshed since it appeared
+	DiffNotFetched = "not_fetched"
+)
+
+type FeedDiffEntry struct {
+	GUID   string    `json:"guid"`
+	Title  string    `json:"title"`
+	Link   string    `json:"link"`
+	Date   time.Time `json:"date"`
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail,omitempty"`
+	// the stored item, if any
+	Item *storage.Item `json:"item,omitempty"`
+}
+
+type FeedDiff struct {
+	FeedId    int64           `json:"feed_id"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Entries   []FeedDiffEntry `json:"entries"`
+	// number of entries not stored locally
+	Missing int `json:"missing"`
+}
+
+// DiffFeed fetches the feed and reports which of its entries are
+// stored locally, and why the others aren't, to debug missed items.
+func DiffFeed(db *storage.Storage, feed storage.Feed) (*FeedDiff, error) {
+	header, err := feedHeader(db, feed)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.getConditional(feed.FeedLink, "", "", feed.AcceptLanguage, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, parser.MaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parser.ParseAndFix(bytes.NewReader(body), feed.FeedLink, getCharset(res))
+	if err != nil {
+		return nil, err
+	}
+
+	items := ConvertItems(parsed.Items, feed)
+	stored, err := db.LookupItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notFetched := "the feed hasn't been refreshed yet"
+	state, err := db.GetHTTPState(feed.Id)
+	if err == nil && !state.LastRefreshed.IsZero() {
+		notFetched = "last refreshed at " + state.LastRefreshed.Format(time.RFC3339)
+	} else if err != nil && err != storage.ErrNotFound {
+		return nil, err
+	}
+	feedErrors, err := db.GetFeedErrors()
+	if err != nil {
+		return nil, err
+	}
+	if lastErr, ok := feedErrors[feed.Id]; ok {
+		notFetched = "last refresh failed: " + lastErr
+	}
+
+	diff := &FeedDiff{FeedId: feed.Id, FetchedAt: now, Entries: make([]FeedDiffEntry, len(items))}
+	seen := make(map[string]int)
+	for i, item := range items {
+		entry := FeedDiffEntry{GUID: item.GUID, Title: item.Title, Link: item.Link, Date: item.Date}
+		first, duplicate := seen[item.GUID]
+		switch {
+		case duplicate:
+			entry.Reason = DiffDuplicate
+			entry.Detail = fmt.Sprintf("same guid as entry %d (%q)", first+1, items[first].Title)
+		case stored[i] != nil:
+			entry.Reason = DiffStored
+			entry.Item = stored[i]
+			if stored[i].GUID != item.GUID {
+				entry.Detail = "guid reused by another article, stored as " + stored[i].GUID
+			}
+		case !item.Date.IsZero() && item.Date.Before(storage.RetentionCutoff(now)):
+			entry.Reason = DiffRetention
+			entry.Detail = "published before " + storage.RetentionCutoff(now).Format("2006-01-02")
+		default:
+			entry.Reason = DiffNotFetched
+			entry.Detail = notFetched
+		}
+		if !duplicate {
+			seen[item.GUID] = i
+		}
+		if entry.Reason != DiffStored {
+			diff.Missing++
+		}
+		diff.Entries[i] = entry
+	}
+	return diff, nil
+}
@@ -0,0 +1,115 @@
+package worker
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// StartDigest periodically generates yesterday's digest for each
+// folder if the "digest" setting is enabled, the day starting at midnight
+// in the user's time zone. Generation is idempotent, so checking hourly
+// is enough to catch the day change.
+func (w *Worker) StartDigest() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		for {
+			if err := w.generateYesterdaysDigests(); err != nil {
+				log.Print(err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (w *Worker) generateYesterdaysDigests() error {
+	val, err := w.db.GetSettingsValue("digest")
+	if err != nil {
+		return err
+	}
+	if enabled, _ := val.(bool); !enabled {
+		return nil
+	}
+	loc, err := w.db.Location()
+	if err != nil {
+		return err
+	}
+	w.GenerateDigests(time.Now().In(loc).AddDate(0, 0, -1))
+	return nil
+}
+
+// GenerateDigests creates the digest item of the given day for every folder.
+func (w *Worker) GenerateDigests(day time.Time) {
+	since := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	until := since.AddDate(0, 0, 1)
+
+	list, err := w.db.ListFeeds()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	feeds := make(map[int64]storage.Feed)
+	for _, feed := range list {
+		feeds[feed.Id] = feed
+	}
+
+	folders, err := w.db.ListFolders()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, folder := range folders {
+		headlines, err := w.db.ListHeadlines(folder.Id, since, until)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if len(headlines) == 0 {
+			continue
+		}
+		feed, err := w.db.GetDigestFeed(folder)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		_, err = w.db.CreateItems([]storage.Item{{
+			GUID:    "digest:" + since.Format("2006-01-02"),
+			FeedId:  feed.Id,
+			Title:   fmt.Sprintf("%s: %s", folder.Title, since.Format("Monday, January 2, 2006")),
+			Content: digestContent(headlines, feeds),
+			Date:    until,
+			Status:  storage.UNREAD,
+		}})
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func digestContent(headlines []storage.Item, feeds map[int64]storage.Feed) string {
+	var b strings.Builder
+	lastFeedId := int64(-1)
+	for _, item := range headlines {
+		if item.FeedId != lastFeedId {
+			if lastFeedId != -1 {
+				b.WriteString("</ul>")
+			}
+			b.WriteString("<h3>" + html.EscapeString(feeds[item.FeedId].Title) + "</h3><ul>")
+			lastFeedId = item.FeedId
+		}
+		title := item.Title
+		if title == "" {
+			title = item.Link
+		}
+		b.WriteString(fmt.Sprintf(
+			`<li><a href="%s">%s</a></li>`,
+			html.EscapeString(item.Link), html.EscapeString(title),
+		))
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
@@ -0,0 +1,243 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+const (
+	downloadMaxAttempts = 3
+	downloadRetryAfter  = time.Minute * 30
+	downloadBatchSize   = 10
+)
+
+var errQuotaExceeded = errors.New("download quota exceeded")
+
+// downloadName matches the names of the files written by the downloader:
+// the item id, the extension, and the suffix of the partial downloads.
+var downloadName = regexp.MustCompile(`^\d+(\.[A-Za-z0-9]+)?(\.\d+\.part)?$`)
+
+// Downloader fetches enclosures of feeds with downloads enabled
+// into a local directory, keeping the total size within the quota.
+type Downloader struct {
+	db    *storage.Storage
+	dir   string
+	quota int64
+
+	wakeup chan bool
+	mutex  sync.Mutex
+}
+
+func NewDownloader(db *storage.Storage, dir string, quota int64) *Downloader {
+	return &Downloader{
+		db:     db,
+		dir:    dir,
+		quota:  quota,
+		wakeup: make(chan bool, 1),
+	}
+}
+
+func (d *Downloader) Start() {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		log.Printf("Failed to create downloads dir: %s", err)
+		return
+	}
+	ticker := time.NewTicker(downloadRetryAfter)
+	go func() {
+		for {
+			d.process()
+			select {
+			case <-d.wakeup:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Notify schedules a pass over the download queue.
+func (d *Downloader) Notify() {
+	select {
+	case d.wakeup <- true:
+	default:
+	}
+}
+
+// Path returns the location of the downloaded file.
+func (d *Downloader) Path(download storage.Download) string {
+	return filepath.Join(d.dir, download.Path)
+}
+
+func (d *Downloader) process() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.removeOrphans()
+	if err := d.db.QueueEnclosureDownloads(); err != nil {
+		log.Printf("Failed to queue downloads: %s", err)
+		return
+	}
+	// the usage drops as the items (and their downloads) go away
+	used, err := d.db.DownloadsSize()
+	if err != nil {
+		log.Printf("Failed to get downloads size: %s", err)
+		return
+	}
+	if d.quota <= 0 || used < d.quota {
+		if err := d.db.RequeueQuotaExceededDownloads(); err != nil {
+			log.Printf("Failed to requeue downloads: %s", err)
+			return
+		}
+	}
+	for {
+		downloads, err := d.db.ListPendingDownloads(downloadMaxAttempts, downloadRetryAfter, downloadBatchSize)
+		if err != nil {
+			log.Printf("Failed to list downloads: %s", err)
+			return
+		}
+		if len(downloads) == 0 {
+			return
+		}
+		for _, download := range downloads {
+			// a download left pending would be picked up again right away
+			if err := d.db.SetDownloadStarted(download.ItemId); err != nil {
+				log.Printf("Failed to start download %s: %s", download.URL, err)
+				return
+			}
+			filename, size, err := d.download(download)
+			switch {
+			case err == errQuotaExceeded:
+				err = d.db.SetDownloadError(download.ItemId, storage.DownloadQuotaExceed, err)
+			case err != nil:
+				log.Printf("Failed to download %s: %s", download.URL, err)
+				err = d.db.SetDownloadError(download.ItemId, storage.DownloadFailed, err)
+			default:
+				err = d.db.SetDownloadDone(download.ItemId, filename, size)
+			}
+			if err != nil {
+				log.Printf("Failed to update download %s: %s", download.URL, err)
+				return
+			}
+		}
+	}
+}
+
+// removeOrphans deletes the files left without a row in the downloads
+// table, which the quota doesn't account for: the rows go away with their
+// items (deleted feeds, the retention policy), and the partial downloads
+// of an interrupted pass are never finished.
+func (d *Downloader) removeOrphans() {
+	known, err := d.db.DownloadedFiles()
+	if err != nil {
+		log.Printf("Failed to list downloads: %s", err)
+		return
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("Failed to list downloads dir: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || known[name] || !downloadName.MatchString(name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.dir, name)); err != nil {
+			log.Printf("Failed to remove %s: %s", name, err)
+		}
+	}
+}
+
+func (d *Downloader) download(download storage.Download) (string, int64, error) {
+	used, err := d.db.DownloadsSize()
+	if err != nil {
+		return "", 0, err
+	}
+	available := d.quota - used
+	if d.quota > 0 && available <= 0 {
+		return "", 0, errQuotaExceeded
+	}
+
+	res, err := client.get(download.URL)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("status code %d", res.StatusCode)
+	}
+	if d.quota > 0 && res.ContentLength > available {
+		return "", 0, errQuotaExceeded
+	}
+
+	filename := strconv.FormatInt(download.ItemId, 10) + enclosureExt(download.URL, res.Header.Get("Content-Type"))
+	tmp, err := os.CreateTemp(d.dir, filename+".*.part")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	var body io.Reader = res.Body
+	if d.quota > 0 {
+		body = io.LimitReader(res.Body, available+1)
+	}
+	size, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if d.quota > 0 && size > available {
+		return "", 0, errQuotaExceeded
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d.dir, filename)); err != nil {
+		return "", 0, err
+	}
+	return filename, size, nil
+}
+
+func enclosureExt(url, contentType string) string {
+	if ext := path.Ext(path.Base(stripQuery(url))); isSafeExt(ext) {
+		return ext
+	}
+	if mediatype, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, _ := mime.ExtensionsByType(mediatype); len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
+
+func isSafeExt(ext string) bool {
+	if len(ext) < 2 || len(ext) > 6 {
+		return false
+	}
+	for _, c := range ext[1:] {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func stripQuery(url string) string {
+	for i, c := range url {
+		if c == '?' || c == '#' {
+			return url[:i]
+		}
+	}
+	return url
+}
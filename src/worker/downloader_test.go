@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+func TestDownloaderRemovesOrphans(t *testing.T) {
+	db, err := storage.New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, _ := db.CreateFeed("feed", "", "", "http://example.com/feed.xml", "", nil)
+	db.UpdateFeedDownloadEnclosures(feed.Id, true)
+	audio := []storage.Enclosure{{URL: "http://example.com/episode.mp3", Type: "audio/mpeg"}}
+	db.CreateItems([]storage.Item{{GUID: "1", FeedId: feed.Id, Title: "1", Date: time.Now(), Enclosures: audio}})
+	db.QueueEnclosureDownloads()
+	downloads, _ := db.ListDownloads()
+	if len(downloads) != 1 {
+		t.Fatalf("want 1 download, have %v", downloads)
+	}
+
+	dir := t.TempDir()
+	kept := downloads[0]
+	kept.Path = "1.mp3"
+	db.SetDownloadDone(kept.ItemId, kept.Path, 3)
+	for _, name := range []string{"1.mp3", "2.mp3", "3", "4.ogg.123456.part", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("abc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	d := NewDownloader(db, dir, 0)
+	d.removeOrphans()
+	for name, want := range map[string]bool{"1.mp3": true, "2.mp3": false, "3": false, "4.ogg.123456.part": false, "notes.txt": true} {
+		if exists(name) != want {
+			t.Errorf("%s: want exists=%v", name, want)
+		}
+	}
+
+	// the row goes away with the feed, the file with the next pass
+	if _, err := db.DeleteFeed(feed.Id); err != nil {
+		t.Fatal(err)
+	}
+	d.removeOrphans()
+	if exists("1.mp3") {
+		t.Error("want the file of the deleted feed removed")
+	}
+	if !exists("notes.txt") {
+		t.Error("want the files not written by the downloader kept")
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nkanaev/yarr/src/forge"
+)
+
+// forgeLimits holds the APIs of the git hosting sites which are out of
+// requests, by host, until when. Their sources aren't requested until
+// then, which would only make the wait longer.
+var forgeLimits = struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+func forgeLimited(host string, now time.Time) (time.Time, bool) {
+	forgeLimits.mu.Lock()
+	defer forgeLimits.mu.Unlock()
+	until, ok := forgeLimits.until[host]
+	if ok && !now.Before(until) {
+		delete(forgeLimits.until, host)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+func setForgeLimit(host string, until time.Time) {
+	forgeLimits.mu.Lock()
+	defer forgeLimits.mu.Unlock()
+	forgeLimits.until[host] = until
+}
+
+// getForge requests the API link of a source of releases (or tags).
+// Conditional requests are cheap there: GitHub doesn't count the ones
+// answered with 304 against the rate limit.
+func getForge(source forge.Source, link, lastModified, etag, token string) (*http.Response, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if until, limited := forgeLimited(u.Host, time.Now()); limited {
+		return nil, &forge.RateLimitError{Host: u.Host, Until: until}
+	}
+	res, err := client.getAPI(link, lastModified, etag, source.Header(token))
+	if err != nil {
+		return nil, err
+	}
+	if until, limited := forge.RateLimit(res, time.Now()); limited {
+		setForgeLimit(u.Host, until)
+		if res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests {
+			res.Body.Close()
+			return nil, &forge.RateLimitError{Host: u.Host, Until: until}
+		}
+	}
+	return res, nil
+}
+
+// DiscoverForgeFeed makes the feed of the releases (or tags)
+// of a repository, see forge.FromURL.
+func DiscoverForgeFeed(source forge.Source, token string) (*DiscoverResult, error) {
+	link := source.APIURL()
+	res, err := getForge(source, link, "", "", token)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound && token == "":
+		return nil, fmt.Errorf("repository not found (a private one needs an API token of %s)", source.Host())
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("status code %d", res.StatusCode)
+	}
+	feed, err := source.Parse(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	feed.NormalizeDates(time.Now())
+	return &DiscoverResult{Feed: feed, FeedLink: link}, nil
+}
@@ -0,0 +1,244 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nkanaev/yarr/src/content/scraper"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+const NUM_ICON_WORKERS = 4
+
+var (
+	// How often icons are looked up again, whether found or not.
+	IconRecheckAfter = time.Hour * 24 * 30
+
+	// Failed lookups are retried with exponential backoff starting at
+	// IconRetryAfter, and given up (until the next recheck) after
+	// IconMaxAttempts.
+	IconRetryAfter  = time.Hour
+	IconMaxAttempts = 5
+)
+
+const iconMaxRetryAfter = time.Hour * 24 * 7
+
+var errNoIcon = errors.New("no icon found")
+
+var emptyIcon = make([]byte, 0)
+var imageTypes = map[string]bool{
+	"image/x-icon": true,
+	"image/png":    true,
+	"image/jpeg":   true,
+	"image/gif":    true,
+}
+
+// StartIconChecker looks up the icons which are due right away,
+// and then every hour.
+func (w *Worker) StartIconChecker() {
+	w.FindFavicons()
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for {
+			<-ticker.C
+			w.FindFavicons()
+		}
+	}()
+}
+
+// FindFavicons looks up the icons of the feeds due for a check
+// in the background, unless it's already in progress.
+func (w *Worker) FindFavicons() {
+	if !atomic.CompareAndSwapInt32(&w.iconsRunning, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&w.iconsRunning, 0)
+
+		feeds := make(chan storage.Feed)
+		var wg sync.WaitGroup
+		for i := 0; i < NUM_ICON_WORKERS; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for feed := range feeds {
+					w.FindFeedFavicon(feed)
+				}
+			}()
+		}
+		due, err := w.db.ListFeedsDueIconCheck(time.Now())
+		if err != nil {
+			log.Print(err)
+		}
+		for _, feed := range due {
+			if storage.IsSystemFeed(feed) {
+				continue
+			}
+			feeds <- feed
+		}
+		close(feeds)
+		wg.Wait()
+	}()
+}
+
+func (w *Worker) FindFeedFavicon(feed storage.Feed) {
+	state, err := w.db.GetIconState(feed.Id)
+	if err != nil && err != storage.ErrNotFound {
+		log.Print(err)
+		return
+	}
+	var prev *storage.IconSource
+	if feed.HasIcon && state != nil && state.Source.URL != "" {
+		prev = &state.Source
+	}
+	icon, err := findFavicon(feed.Link, feed.FeedLink, prev)
+	now := time.Now()
+	attempts, nextCheck := 0, now.Add(IconRecheckAfter)
+	switch err {
+	case nil:
+		if icon.content != nil {
+			changed, err := w.db.ReplaceFeedIcon(feed.Id, icon.content, icon.source)
+			if err != nil {
+				log.Print(err)
+			} else if changed && w.onIconChanged != nil {
+				w.onIconChanged(feed.Id)
+			}
+		}
+	case errNoIcon:
+		// a permanent failure, keep the icon found previously (if any)
+		if !feed.HasIcon {
+			if err := w.db.UpdateFeedIcon(feed.Id, &emptyIcon); err != nil {
+				log.Print(err)
+			}
+		}
+	default:
+		log.Printf("Failed to find favicon for %s (%s): %s", feed.FeedLink, feed.Link, err)
+		attempts = 1
+		if state != nil {
+			attempts = state.Attempts + 1
+		}
+		if attempts >= IconMaxAttempts {
+			if !feed.HasIcon {
+				if err := w.db.UpdateFeedIcon(feed.Id, &emptyIcon); err != nil {
+					log.Print(err)
+				}
+			}
+		} else {
+			nextCheck = now.Add(iconRetryAfter(attempts))
+		}
+	}
+	if err := w.db.SetIconState(feed.Id, attempts, nextCheck, err); err != nil {
+		log.Print(err)
+	}
+}
+
+func iconRetryAfter(attempts int) time.Duration {
+	d := IconRetryAfter
+	for i := 1; i < attempts && d < iconMaxRetryAfter; i++ {
+		d *= 2
+	}
+	if d > iconMaxRetryAfter {
+		d = iconMaxRetryAfter
+	}
+	return d
+}
+
+// iconResult is the icon found by findFavicon,
+// content is nil if it hasn't changed since the previous lookup.
+type iconResult struct {
+	content []byte
+	source  storage.IconSource
+}
+
+// findFavicon returns errNoIcon if the sites responded, but none of the
+// candidates is an image. Any other error means they couldn't be reached.
+//
+// The candidates are looked up again every time, so that a site
+// switching to another icon is noticed, but the one the icon has been
+// fetched from previously (prev, if any) is requested conditionally.
+func findFavicon(siteUrl, feedUrl string, prev *storage.IconSource) (*iconResult, error) {
+	urls := make([]string, 0)
+
+	favicon := func(link string) string {
+		u, err := url.Parse(link)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%s://%s/favicon.ico", u.Scheme, u.Host)
+	}
+
+	var lastErr error
+	reached := false
+
+	if siteUrl != "" {
+		if res, err := client.get(siteUrl); err == nil {
+			reached = reached || res.StatusCode < 500
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err == nil {
+				urls = append(urls, scraper.FindIcons(string(body), siteUrl)...)
+				if c := favicon(siteUrl); c != "" {
+					urls = append(urls, c)
+				}
+			}
+		} else {
+			lastErr = err
+		}
+	}
+
+	if c := favicon(feedUrl); c != "" {
+		urls = append(urls, c)
+	}
+
+	for _, u := range urls {
+		var validators storage.IconSource
+		if prev != nil && prev.URL == u {
+			validators = *prev
+		}
+		content, status, source, err := fetchIcon(u, validators)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		reached = reached || status < 500
+		if status == http.StatusNotModified && validators.URL != "" {
+			return &iconResult{source: validators}, nil
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		ctype := http.DetectContentType(content)
+		if imageTypes[ctype] {
+			return &iconResult{content: content, source: source}, nil
+		}
+	}
+	if reached || lastErr == nil {
+		return nil, errNoIcon
+	}
+	return nil, lastErr
+}
+
+// fetchIcon requests the icon, conditionally if validators are given,
+// returning the validators of the response along with the content.
+func fetchIcon(link string, validators storage.IconSource) ([]byte, int, storage.IconSource, error) {
+	source := storage.IconSource{URL: link}
+	res, err := client.getConditional(link, validators.LastModified, validators.Etag, "", nil)
+	if err != nil {
+		return nil, 0, source, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, res.StatusCode, source, nil
+	}
+	source.Etag = res.Header.Get("Etag")
+	source.LastModified = res.Header.Get("Last-Modified")
+	content, err := io.ReadAll(res.Body)
+	return content, res.StatusCode, source, err
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/nkanaev/yarr/src/content/scraper"
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+	"golang.org/x/net/html/charset"
+)
+
+// CheckImportedFeeds validates the feeds in the background (typically
+// right after an OPML import) and records suggestions for the dead,
+// duplicate and misdirected ones, see storage.FeedSuggestion.
+func (w *Worker) CheckImportedFeeds(feeds []storage.Feed) {
+	go func() {
+		list, err := w.db.ListFeeds()
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		subscribed := make(map[string]int64)
+		for _, feed := range list {
+			subscribed[feed.FeedLink] = feed.Id
+		}
+
+		queue := make(chan storage.Feed)
+		var wg sync.WaitGroup
+		for i := 0; i < NUM_WORKERS; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for feed := range queue {
+					sg := checkFeed(feed, subscribed)
+					if sg == nil {
+						continue
+					}
+					if err := w.db.SetFeedSuggestion(*sg); err != nil {
+						log.Print(err)
+					}
+				}
+			}()
+		}
+		for _, feed := range feeds {
+			queue <- feed
+		}
+		close(queue)
+		wg.Wait()
+		log.Printf("Finished checking %d imported feeds", len(feeds))
+	}()
+}
+
+// checkFeed returns nil if the feed looks fine, or its state can't be
+// determined (e.g. the server is temporarily failing).
+func checkFeed(feed storage.Feed, subscribed map[string]int64) *storage.FeedSuggestion {
+	suggest := func(kind storage.SuggestionKind, detail string) *storage.FeedSuggestion {
+		return &storage.FeedSuggestion{FeedId: feed.Id, Kind: kind, Detail: detail}
+	}
+
+	res, err := client.get(feed.FeedLink)
+	if err != nil {
+		return suggest(storage.SuggestDead, err.Error())
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return suggest(storage.SuggestDead, fmt.Sprintf("status code %d", res.StatusCode))
+	default:
+		return nil
+	}
+
+	finalLink := res.Request.URL.String()
+	if id, ok := subscribed[finalLink]; ok && finalLink != feed.FeedLink && id != feed.Id {
+		sg := suggest(storage.SuggestDuplicate, "redirects to "+finalLink)
+		sg.TargetFeedId = &id
+		return sg
+	}
+
+	cs := getCharset(res)
+	body, err := io.ReadAll(io.LimitReader(res.Body, parser.MaxBodySize))
+	if err != nil {
+		return nil
+	}
+	if _, err := parser.ParseAndFix(bytes.NewReader(body), finalLink, cs); err == nil {
+		return nil
+	}
+
+	content := string(body)
+	if cs != "" {
+		if r, err := charset.NewReaderLabel(cs, bytes.NewReader(body)); err == nil {
+			if body, err := io.ReadAll(r); err == nil {
+				content = string(body)
+			}
+		}
+	}
+	links := make([]string, 0)
+	for link := range scraper.FindFeeds(content, finalLink) {
+		links = append(links, link)
+	}
+	if len(links) == 0 {
+		return suggest(storage.SuggestNotAFeed, "no feed found at the url")
+	}
+	sort.Strings(links)
+	for _, link := range links {
+		if id, ok := subscribed[link]; ok && id != feed.Id {
+			sg := suggest(storage.SuggestDuplicate, "web page of "+link)
+			sg.TargetFeedId = &id
+			return sg
+		}
+	}
+	sg := suggest(storage.SuggestNotAFeed, "web page linking to "+links[0])
+	sg.Replacement = links[0]
+	return sg
+}
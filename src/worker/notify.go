@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"log"
+	"time"
+
+	"github.com/nkanaev/yarr/src/notify"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// StartNotifier sends the queued notifications every minute.
+// Failed ones are retried until the items expire from the queue.
+func (w *Worker) StartNotifier() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		for {
+			w.SendNotifications(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// SendNotifications sends the batches due at the given time.
+func (w *Worker) SendNotifications(now time.Time) {
+	pending, err := w.db.PendingNotifications(now)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, p := range pending {
+		if err := SendNotification(p.Notifier, NotificationItems(p.Items)); err != nil {
+			log.Printf("notifier %q: %s", p.Notifier.Name, err)
+			continue
+		}
+		ids := make([]int64, len(p.Items))
+		for i, item := range p.Items {
+			ids[i] = item.ItemId
+		}
+		if err := w.db.MarkNotificationSent(p.Route.Id, ids, now); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+func NotificationItems(items []storage.NotificationItem) []notify.Item {
+	result := make([]notify.Item, len(items))
+	for i, item := range items {
+		result[i] = notify.Item{Title: item.Title, Link: item.Link, FeedTitle: item.FeedTitle}
+	}
+	return result
+}
+
+// SendNotification sends the items through the stored notifier.
+func SendNotification(n storage.Notifier, items []notify.Item) error {
+	notifier, err := notify.NewNotifier(n.Kind, n.Config)
+	if err != nil {
+		return err
+	}
+	return notifier.Send(notify.New(items))
+}
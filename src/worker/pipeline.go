@@ -0,0 +1,432 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nkanaev/yarr/src/forge"
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/tracing"
+)
+
+// A refresh runs as a pipeline: NUM_WORKERS fetchers download feed
+// documents into memory, NUM_PARSERS parsers turn them into items and
+// a single storer writes the results. The queues between the stages
+// are bounded, so a slow stage holds the previous ones back instead of
+// piling up documents, and a fetcher never waits for the database.
+const NUM_PARSERS = 2
+
+// Documents larger than LargeFeedSize are spooled to a temporary file
+// instead of being kept in memory, and parsed as a stream, handing the
+// items over to the store stage in batches of streamBatchSize.
+var LargeFeedSize int64 = 4 << 20
+
+const streamBatchSize = 500
+
+// StoreTimeout limits the time spent storing a batch of items, so that
+// a stuck write doesn't hold back the rest of the refresh.
+var StoreTimeout = 5 * time.Minute
+
+// fetchedFeed is the outcome of the fetch stage.
+type fetchedFeed struct {
+	feed storage.Feed
+
+	// whether a document was downloaded, as opposed to
+	// the server (or a probe) reporting it unchanged
+	modified bool
+	body     []byte
+	spool    *os.File
+	charset  string
+
+	lastModified string
+	etag         string
+
+	// the rule of the feeds watching a page, see package watch
+	watch *storage.FeedWatch
+	// the rules rewriting the contents of the items, see applyFeedRules
+	rules []storage.FeedRule
+
+	// whether a request was made and how many bytes it downloaded.
+	// The bytes are counted after the transport has undone any gzip
+	// compression, which makes the count an upper bound of the traffic.
+	requested bool
+	size      int64
+
+	err error
+}
+
+// parsedFeed is the outcome of the parse stage. Large feeds
+// produce several of them, the last one has done set.
+type parsedFeed struct {
+	fetchedFeed
+	items []storage.Item
+
+	done bool
+	// number of items in the whole document
+	count int
+	// the value found on the watched page
+	watchValue string
+	// the url the feed says it's at, see checkFeedMoved
+	selfLink string
+	// the language the feed says it's in
+	language string
+}
+
+// StageStats counts the feeds passed through a pipeline stage
+// and the time spent on them.
+type StageStats struct {
+	Feeds    int64         `json:"feeds"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+var stageStats = struct {
+	mu     sync.Mutex
+	stages map[string]*StageStats
+}{stages: make(map[string]*StageStats)}
+
+func recordStage(stage string, start time.Time, feeds int64) {
+	stageStats.mu.Lock()
+	defer stageStats.mu.Unlock()
+	stats := stageStats.stages[stage]
+	if stats == nil {
+		stats = &StageStats{}
+		stageStats.stages[stage] = stats
+	}
+	stats.Feeds += feeds
+	stats.Duration += time.Since(start)
+}
+
+// PipelineStats returns the totals of each refresh pipeline stage.
+func PipelineStats() map[string]StageStats {
+	stageStats.mu.Lock()
+	defer stageStats.mu.Unlock()
+	result := make(map[string]StageStats, len(stageStats.stages))
+	for stage, stats := range stageStats.stages {
+		result[stage] = *stats
+	}
+	return result
+}
+
+func (w *Worker) pipeline(ctx context.Context, feeds []storage.Feed, dormant map[int64]bool) {
+	srcqueue := make(chan storage.Feed, len(feeds))
+	for _, feed := range feeds {
+		srcqueue <- feed
+	}
+	close(srcqueue)
+
+	fetched := make(chan fetchedFeed, NUM_WORKERS)
+	parsed := make(chan parsedFeed, NUM_PARSERS)
+
+	var fetchers, parsers sync.WaitGroup
+	for i := 0; i < NUM_WORKERS; i++ {
+		fetchers.Add(1)
+		go func() {
+			defer fetchers.Done()
+			for feed := range srcqueue {
+				fetched <- fetchFeed(ctx, feed, w.db.WithContext(ctx), dormant[feed.Id])
+			}
+		}()
+	}
+	for i := 0; i < NUM_PARSERS; i++ {
+		parsers.Add(1)
+		go func() {
+			defer parsers.Done()
+			for result := range fetched {
+				parseFeed(ctx, result, parsed, w.db.WithContext(ctx))
+			}
+		}()
+	}
+	go func() {
+		fetchers.Wait()
+		close(fetched)
+	}()
+	go func() {
+		parsers.Wait()
+		close(parsed)
+	}()
+
+	for result := range parsed {
+		w.storeFeed(ctx, result)
+	}
+}
+
+func fetchFeed(ctx context.Context, f storage.Feed, db *storage.Storage, dormant bool) (result fetchedFeed) {
+	defer recordStage("fetch", time.Now(), 1)
+	_, span := tracing.Start(ctx, "fetch", tracing.KindClient)
+	defer func() {
+		span.SetError(result.err)
+		span.End()
+	}()
+	span.SetAttr("feed.id", f.Id)
+	span.SetAttr("http.url", f.FeedLink)
+	result.feed = f
+
+	lmod := ""
+	etag := ""
+	state, err := db.GetHTTPState(f.Id)
+	if err == nil {
+		lmod = state.LastModified
+		etag = state.Etag
+	} else if err != storage.ErrNotFound {
+		result.err = err
+		return
+	}
+
+	// the releases of git hosting sites are requested from their APIs
+	source := forge.FromAPIURL(f.FeedLink)
+
+	header, err := feedHeader(db, f)
+	if err != nil {
+		result.err = err
+		return
+	}
+
+	if fw, err := db.GetFeedWatch(f.Id); err == nil {
+		result.watch = fw
+	} else if err != storage.ErrNotFound {
+		result.err = err
+		return
+	}
+	if result.rules, err = db.ListFeedRules(f.Id); err != nil {
+		result.err = err
+		return
+	}
+
+	if dormant && source == nil {
+		span.SetAttr("probe", true)
+		if probeUnchanged(f, state, header) {
+			result.requested = true
+			return
+		}
+	}
+
+	var res *http.Response
+	if source != nil {
+		var token string
+		if token, err = db.ForgeToken(source.Host()); err != nil {
+			result.err = err
+			return
+		}
+		res, err = getForge(*source, f.FeedLink, lmod, etag, token)
+	} else {
+		res, err = client.getConditional(f.FeedLink, lmod, etag, f.AcceptLanguage, header)
+	}
+	if err != nil {
+		result.err = err
+		return
+	}
+	defer res.Body.Close()
+	result.requested = true
+	span.SetAttr("http.status_code", res.StatusCode)
+
+	switch {
+	case res.StatusCode < 200 || res.StatusCode > 399:
+		if res.StatusCode == 404 {
+			result.err = fmt.Errorf("feed not found")
+		} else {
+			result.err = fmt.Errorf("status code %d", res.StatusCode)
+		}
+		return
+	case res.StatusCode == http.StatusNotModified:
+		return
+	}
+
+	// reading the whole document here frees the connection
+	// regardless of how long the parsing takes
+	body, err := io.ReadAll(io.LimitReader(res.Body, LargeFeedSize+1))
+	result.size = int64(len(body))
+	if err != nil {
+		result.err = err
+		return
+	}
+	if result.size > LargeFeedSize {
+		span.SetAttr("spooled", true)
+		var n int64
+		result.spool, n, err = spool(body, res.Body)
+		result.size += n
+		if err != nil {
+			result.err = err
+			return
+		}
+	} else {
+		result.body = body
+	}
+	result.modified = true
+	result.charset = getCharset(res)
+	result.lastModified = res.Header.Get("Last-Modified")
+	result.etag = res.Header.Get("Etag")
+	return
+}
+
+// spool writes the document to a temporary file,
+// which is removed by the parse stage.
+func spool(head []byte, rest io.Reader) (*os.File, int64, error) {
+	file, err := os.CreateTemp("", "yarr-feed-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err = file.Write(head); err == nil {
+		limit := parser.MaxStreamBodySize - int64(len(head))
+		var n int64
+		n, err = io.Copy(file, io.LimitReader(rest, limit+1))
+		if err == nil && n > limit {
+			err = parser.ErrBodyTooLarge
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err == nil {
+			return file, n, nil
+		}
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil, 0, err
+}
+
+func parseFeed(ctx context.Context, fetched fetchedFeed, out chan<- parsedFeed, db *storage.Storage) {
+	result := parsedFeed{fetchedFeed: fetched, done: true}
+	if !fetched.modified || fetched.err != nil {
+		out <- result
+		return
+	}
+	start := time.Now()
+	_, span := tracing.Start(ctx, "parse", tracing.KindInternal)
+	span.SetAttr("feed.id", fetched.feed.Id)
+
+	f := fetched.feed
+	if fetched.watch != nil {
+		var page io.Reader = bytes.NewReader(fetched.body)
+		if fetched.spool != nil {
+			page = fetched.spool
+		}
+		result.items, result.watchValue, result.err = watchItems(*fetched.watch, f, page, fetched.charset, time.Now())
+		if fetched.spool != nil {
+			fetched.spool.Close()
+			os.Remove(fetched.spool.Name())
+		}
+	} else if source := forge.FromAPIURL(f.FeedLink); source != nil && fetched.spool == nil {
+		feed, err := source.Parse(bytes.NewReader(fetched.body))
+		if err == nil {
+			feed.NormalizeDates(time.Now())
+			result.items = ConvertItems(feed.Items, f)
+			result.count = len(result.items)
+		}
+		result.err = err
+	} else if fetched.spool != nil {
+		result.err = parser.ParseStream(fetched.spool, f.FeedLink, fetched.charset, streamBatchSize, func(feed *parser.Feed) error {
+			result.selfLink, result.language = feed.FeedLink, feed.Language
+			items := ConvertItems(feed.Items, f)
+			if len(fetched.rules) > 0 {
+				items = applyFeedRules(db, f, fetched.rules, items)
+			}
+			result.count += len(items)
+			out <- parsedFeed{fetchedFeed: fetchedFeed{feed: f}, items: items}
+			return nil
+		})
+		fetched.spool.Close()
+		os.Remove(fetched.spool.Name())
+	} else {
+		feed, err := parser.ParseAndFix(bytes.NewReader(fetched.body), f.FeedLink, fetched.charset)
+		if err == nil {
+			result.items = ConvertItems(feed.Items, f)
+			result.count = len(result.items)
+			result.selfLink, result.language = feed.FeedLink, feed.Language
+		}
+		result.err = err
+	}
+	if len(fetched.rules) > 0 && len(result.items) > 0 && fetched.watch == nil {
+		result.items = applyFeedRules(db, f, fetched.rules, result.items)
+	}
+	result.body = nil
+	result.spool = nil
+
+	span.SetAttr("items", result.count)
+	span.SetError(result.err)
+	span.End()
+	recordStage("parse", start, 1)
+	out <- result
+}
+
+func (w *Worker) storeFeed(ctx context.Context, result parsedFeed) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "store", tracing.KindInternal)
+	defer span.End()
+	span.SetAttr("feed.id", result.feed.Id)
+	span.SetAttr("items", len(result.items))
+
+	ctx, cancel := context.WithTimeout(ctx, StoreTimeout)
+	defer cancel()
+	db := w.db.WithContext(ctx)
+
+	feedId := result.feed.Id
+	if len(result.items) > 0 {
+		created, err := db.CreateItems(result.items)
+		if err != nil {
+			log.Print(err)
+		} else if created > 0 && w.onNewItems != nil {
+			w.onNewItems(feedId, created)
+		}
+	}
+	if !result.done {
+		recordStage("store", start, 0)
+		return
+	}
+	defer recordStage("store", start, 1)
+
+	if result.requested {
+		if err := db.RecordFeedBandwidth(feedId, result.size); err != nil {
+			log.Print(err)
+		}
+	}
+	if result.err != nil {
+		if err := db.SetFeedError(feedId, result.err); err != nil {
+			log.Print(err)
+		}
+	} else {
+		if err := db.ClearFeedError(feedId); err != nil {
+			log.Print(err)
+		}
+		if result.modified {
+			if err := db.SetHTTPState(feedId, result.lastModified, result.etag); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+	if result.watchValue != "" {
+		if err := db.SetFeedWatchValue(feedId, result.watchValue, time.Now()); err != nil {
+			log.Print(err)
+		}
+	}
+	if result.selfLink != "" && !sameFeedLink(result.feed.FeedLink, result.selfLink) {
+		if changed, err := db.SetFeedSelfLink(feedId, result.selfLink); err != nil {
+			log.Print(err)
+		} else if changed {
+			go w.checkFeedMoved(result.feed, result.selfLink)
+		}
+	}
+	if result.language != "" && result.language != result.feed.Language {
+		if err := db.SetFeedLanguage(feedId, result.language); err != nil {
+			log.Print(err)
+		}
+	}
+	if err := db.SetHTTPStateRefreshed(feedId); err != nil {
+		log.Print(err)
+	}
+	if result.count > 0 {
+		if err := db.SetFeedSize(feedId, result.count); err != nil {
+			log.Print(err)
+		}
+	}
+	w.running.release(feedId)
+	atomic.AddInt32(w.pending, -1)
+}
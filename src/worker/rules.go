@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/nkanaev/yarr/src/content/rewrite"
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// ExtractLimit is the most pages fetched by the extract rules of a feed
+// per refresh. The items beyond it keep the content of the feed.
+var ExtractLimit = 20
+
+// applyFeedRules rewrites the contents of the items with the rules of
+// the feed. The extract rules only fetch the pages of the new (or
+// updated) items: the stored ones keep their contents.
+func applyFeedRules(db *storage.Storage, f storage.Feed, rules []storage.FeedRule, items []storage.Item) []storage.Item {
+	var extract, edits []rewrite.Rule
+	for _, r := range rules {
+		if rule := r.Rewrite(); rule.Kind == rewrite.Extract {
+			extract = append(extract, rule)
+		} else {
+			edits = append(edits, rule)
+		}
+	}
+	var stored map[string]time.Time
+	if len(extract) > 0 {
+		guids := make([]string, len(items))
+		for i, item := range items {
+			guids[i] = item.GUID
+		}
+		var err error
+		if stored, err = db.StoredItemUpdates(f.Id, guids); err != nil {
+			log.Print(err)
+			extract = nil
+		}
+	}
+	fetched := 0
+	for i := range items {
+		item := &items[i]
+		updated, isStored := stored[item.GUID]
+		fresh := !isStored || (item.DateUpdated != nil && item.DateUpdated.After(updated))
+		if len(extract) > 0 && fresh && item.Link != "" && fetched < ExtractLimit {
+			fetched++
+			if content, err := extractPage(item.Link, extract); err == nil {
+				item.Content = content
+			} else {
+				log.Printf("Failed to extract the content of %s: %s", item.Link, err)
+			}
+		}
+		item.Content = rewrite.Apply(item.Content, edits)
+	}
+	return items
+}
+
+// extractPage fetches the page and returns the content of the first
+// extract rule matching it.
+func extractPage(link string, rules []rewrite.Rule) (string, error) {
+	res, err := client.get(link)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, parser.MaxBodySize))
+	if err != nil {
+		return "", err
+	}
+	cs := getCharset(res)
+	for _, rule := range rules {
+		content, err := rule.Extract(bytes.NewReader(body), cs)
+		if err != rewrite.ErrNoMatch {
+			return content, err
+		}
+	}
+	return "", rewrite.ErrNoMatch
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+)
+
+// sameFeedLink tells whether the links point to the same feed, ignoring
+// the case of the host, default ports and a trailing slash.
+func sameFeedLink(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	normalize := func(u *url.URL) string {
+		host := strings.ToLower(u.Hostname())
+		if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443") {
+			host += ":" + port
+		}
+		return strings.ToLower(u.Scheme) + "://" + host + strings.TrimSuffix(u.EscapedPath(), "/") + "?" + u.RawQuery
+	}
+	return normalize(ua) == normalize(ub)
+}
+
+// checkFeedMoved looks into the url the feed says it's at (see
+// parser.Feed.FeedLink) when it isn't the one subscribed to: CDN and
+// domain migrations often leave the old url serving the feed, or a stale
+// copy of it, without ever redirecting. If the new url serves a feed
+// too, the subscription is pointed to it (with the "auto_update_feed_links"
+// setting) or a suggestion is made, see storage.SuggestMoved.
+func (w *Worker) checkFeedMoved(f storage.Feed, link string) {
+	if err := storage.ValidateFeedLink(link); err != nil {
+		return
+	}
+	if err := checkFeedLink(w.db, f, link); err != nil {
+		log.Printf("Ignoring the self link %s of %s: %s", link, f.FeedLink, err)
+		return
+	}
+
+	feeds, err := w.db.ListFeeds()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, other := range feeds {
+		if other.Id != f.Id && sameFeedLink(other.FeedLink, link) {
+			err := w.db.SetFeedSuggestion(storage.FeedSuggestion{
+				FeedId:       f.Id,
+				Kind:         storage.SuggestDuplicate,
+				Detail:       "says it's at " + link,
+				TargetFeedId: &other.Id,
+			})
+			if err != nil {
+				log.Print(err)
+			}
+			return
+		}
+	}
+
+	val, err := w.db.GetSettingsValue("auto_update_feed_links")
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if auto, _ := val.(bool); auto {
+		if err := w.db.UpdateFeedLink(f.Id, link); err != nil {
+			log.Print(err)
+		} else {
+			log.Printf("Moved the feed %s to %s", f.FeedLink, link)
+		}
+		return
+	}
+	err = w.db.SetFeedSuggestion(storage.FeedSuggestion{
+		FeedId:      f.Id,
+		Kind:        storage.SuggestMoved,
+		Detail:      "says it's at " + link,
+		Replacement: link,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// checkFeedLink fetches the link and checks that it serves a feed. The
+// credentials of the feed are only sent along to the same host.
+func checkFeedLink(db *storage.Storage, f storage.Feed, link string) error {
+	var header http.Header
+	if from, to := hostname(f.FeedLink), hostname(link); from != "" && from == to {
+		var err error
+		if header, err = feedHeader(db, f); err != nil {
+			return err
+		}
+	}
+	res, err := client.getConditional(link, "", "", "", header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return fmt.Errorf("status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, parser.MaxBodySize))
+	if err != nil {
+		return err
+	}
+	_, err = parser.ParseWithEncoding(bytes.NewReader(body), getCharset(res))
+	return err
+}
+
+func hostname(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
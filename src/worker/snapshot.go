@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"github.com/nkanaev/yarr/src/content/readability"
+	"github.com/nkanaev/yarr/src/content/sanitizer"
+	"golang.org/x/net/html"
+)
+
+// Limits applied to images inlined into page snapshots.
+// Images exceeding them are left pointing to the original location.
+var (
+	SnapshotMaxImageSize int64 = 2 << 20
+	SnapshotMaxImages          = 50
+)
+
+var errImageTooLarge = errors.New("image is too large")
+
+// Snapshot fetches the page at url and returns its readable part,
+// sanitized and with images embedded as data URIs, so that it can
+// be viewed without access to the original site.
+func Snapshot(url string) (string, error) {
+	body, err := GetBody(url)
+	if err != nil {
+		return "", err
+	}
+	content, err := readability.ExtractContent(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	// sanitizer resolves relative image urls, hence inlining afterwards
+	return inlineImages(sanitizer.Sanitize(url, content))
+}
+
+func inlineImages(content string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	body := doc
+	if nodes := htmlutil.Query(doc, "body"); len(nodes) > 0 {
+		body = nodes[0]
+	}
+
+	images := htmlutil.Query(body, "img")
+	if len(images) > SnapshotMaxImages {
+		images = images[:SnapshotMaxImages]
+	}
+	for _, img := range images {
+		src := htmlutil.Attr(img, "src")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			continue
+		}
+		data, err := fetchImage(src)
+		if err != nil {
+			continue
+		}
+		attrs := img.Attr[:0]
+		for _, attr := range img.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "srcset", "sizes":
+				// would take precedence over the inlined src
+				continue
+			case "src":
+				attr.Val = data
+			}
+			attrs = append(attrs, attr)
+		}
+		img.Attr = attrs
+	}
+	return htmlutil.InnerHTML(body), nil
+}
+
+func fetchImage(url string) (string, error) {
+	res, err := client.get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code %d", res.StatusCode)
+	}
+	ctype, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if !strings.HasPrefix(ctype, "image/") || ctype == "image/svg+xml" {
+		return "", fmt.Errorf("unsupported content type %q", ctype)
+	}
+	if res.ContentLength > SnapshotMaxImageSize {
+		return "", errImageTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, SnapshotMaxImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > SnapshotMaxImageSize {
+		return "", errImageTooLarge
+	}
+	return "data:" + ctype + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
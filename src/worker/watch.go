@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"time"
+
+	"github.com/nkanaev/yarr/src/content/htmlutil"
+	"github.com/nkanaev/yarr/src/parser"
+	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/watch"
+	xhtml "golang.org/x/net/html"
+)
+
+// WatchRule returns the rule of the watching feed.
+func WatchRule(fw storage.FeedWatch) watch.Rule {
+	return watch.Rule{
+		Selector:  fw.Selector,
+		Attr:      fw.Attr,
+		Pattern:   fw.Pattern,
+		Condition: watch.Condition(fw.Condition),
+		Threshold: fw.Threshold,
+	}
+}
+
+// watchItems finds the value on the page of the watching feed, and makes
+// an item of it if the rule is triggered. The value is returned whenever
+// it's found, to be recorded for the next check.
+func watchItems(fw storage.FeedWatch, f storage.Feed, page io.Reader, cs string, now time.Time) ([]storage.Item, string, error) {
+	rule := WatchRule(fw)
+	value, err := rule.Extract(page, cs)
+	if err != nil {
+		return nil, "", err
+	}
+	triggered, err := rule.Triggered(fw.Value, value)
+	if err != nil || !triggered {
+		return nil, value, err
+	}
+	var content string
+	switch rule.Condition {
+	case watch.Above:
+		content = fmt.Sprintf("Above %g", rule.Threshold)
+	case watch.Below:
+		content = fmt.Sprintf("Below %g", rule.Threshold)
+	default:
+		content = "Changed"
+	}
+	if fw.Value != nil {
+		content += ", previously " + *fw.Value
+	}
+	item := storage.Item{
+		GUID:    fmt.Sprintf("watch:%d:%d", f.Id, now.UnixNano()),
+		FeedId:  f.Id,
+		Title:   f.Title + ": " + value,
+		Link:    f.FeedLink,
+		Content: "<p>" + html.EscapeString(content) + ".</p>",
+		Date:    now,
+		Status:  storage.UNREAD,
+	}
+	return []storage.Item{item}, value, nil
+}
+
+// PreviewWatch fetches the page and finds the value of the rule on it,
+// along with the page's title, to check the rule before watching the page.
+func PreviewWatch(link string, rule watch.Rule) (value, title string, err error) {
+	res, err := client.get(link)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", "", fmt.Errorf("status code %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, parser.MaxBodySize))
+	if err != nil {
+		return "", "", err
+	}
+	cs := getCharset(res)
+	if value, err = rule.Extract(bytes.NewReader(body), cs); err != nil {
+		return "", "", err
+	}
+	if doc, err := xhtml.Parse(bytes.NewReader(body)); err == nil {
+		if nodes := htmlutil.Query(doc, "title"); len(nodes) > 0 {
+			title = htmlutil.Text(nodes[0])
+		}
+	}
+	return value, title, nil
+}
@@ -1,27 +1,80 @@
 package worker
 
 import (
+	"context"
 	"log"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/nkanaev/yarr/src/storage"
+	"github.com/nkanaev/yarr/src/tracing"
 )
 
 const NUM_WORKERS = 4
 
 type Worker struct {
-	db      *storage.Storage
-	pending *int32
-	refresh *time.Ticker
-	reflock sync.Mutex
-	stopper chan bool
+	db            *storage.Storage
+	pending       *int32
+	reflock       sync.Mutex
+	running       feedLocks
+	refreshRate   int64
+	schedulerOnce sync.Once
+	downloader    *Downloader
+	onNewItems    func(feedId int64, count int)
+	onIconChanged func(feedId int64)
+
+	iconsRunning int32
 }
 
 func NewWorker(db *storage.Storage) *Worker {
 	pending := int32(0)
-	return &Worker{db: db, pending: &pending}
+	return &Worker{db: db, pending: &pending, running: feedLocks{feeds: make(map[int64]bool)}}
+}
+
+// feedLocks keeps a feed from being fetched by overlapping refreshes
+// (e.g. a manual one started during a scheduled one): a refresh skips
+// the feeds still being refreshed by another.
+type feedLocks struct {
+	mu    sync.Mutex
+	feeds map[int64]bool
+}
+
+// acquire locks the feeds which aren't being refreshed and returns them.
+func (l *feedLocks) acquire(feeds []storage.Feed) []storage.Feed {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]storage.Feed, 0, len(feeds))
+	for _, feed := range feeds {
+		if !l.feeds[feed.Id] {
+			l.feeds[feed.Id] = true
+			result = append(result, feed)
+		}
+	}
+	return result
+}
+
+func (l *feedLocks) release(feedId int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.feeds, feedId)
+}
+
+// SetDownloader enables enclosure downloads after each refresh.
+func (w *Worker) SetDownloader(d *Downloader) {
+	w.downloader = d
+}
+
+// SetNewItemsHandler sets the function called after a refresh
+// has stored new items of a feed.
+func (w *Worker) SetNewItemsHandler(f func(feedId int64, count int)) {
+	w.onNewItems = f
+}
+
+// SetIconChangedHandler sets the function called after a different
+// icon has been found for a feed.
+func (w *Worker) SetIconChangedHandler(f func(feedId int64)) {
+	w.onIconChanged = f
 }
 
 func (w *Worker) FeedsPending() int32 {
@@ -29,118 +82,166 @@ func (w *Worker) FeedsPending() int32 {
 }
 
 func (w *Worker) StartFeedCleaner() {
-	go w.db.DeleteOldItems()
+	go w.cleanup()
 	ticker := time.NewTicker(time.Hour * 24)
 	go func() {
 		for {
 			<-ticker.C
-			w.db.DeleteOldItems()
+			w.cleanup()
 		}
 	}()
 }
 
-func (w *Worker) FindFavicons() {
+// StartSnoozer checks every minute for the snoozed items which are due.
+func (w *Worker) StartSnoozer() {
+	go w.wakeSnoozed()
+	ticker := time.NewTicker(time.Minute)
 	go func() {
-		for _, feed := range w.db.ListFeedsMissingIcons() {
-			w.FindFeedFavicon(feed)
+		for {
+			<-ticker.C
+			w.wakeSnoozed()
 		}
 	}()
 }
 
-func (w *Worker) FindFeedFavicon(feed storage.Feed) {
-	icon, err := findFavicon(feed.Link, feed.FeedLink)
-	if err != nil {
-		log.Printf("Failed to find favicon for %s (%s): %s", feed.FeedLink, feed.Link, err)
-	}
-	if icon != nil {
-		w.db.UpdateFeedIcon(feed.Id, icon)
+func (w *Worker) wakeSnoozed() {
+	if _, err := w.db.WakeSnoozedItems(time.Now()); err != nil {
+		log.Print(err)
 	}
 }
 
-func (w *Worker) SetRefreshRate(minute int64) {
-	if w.stopper != nil {
-		w.refresh.Stop()
-		w.refresh = nil
-		w.stopper <- true
-		w.stopper = nil
+func (w *Worker) cleanup() {
+	if err := w.db.DeleteOldItems(); err != nil {
+		log.Print(err)
 	}
-
-	if minute == 0 {
-		return
+	n, err := w.db.PurgeDeletedFeeds(time.Now().Add(-storage.FeedRestorePeriod))
+	if err != nil {
+		log.Printf("failed to purge deleted feeds: %s", err)
 	}
+	if n > 0 {
+		log.Printf("purged %d deleted feeds", n)
+	}
+	rebalance, err := w.db.CustomOrderNeedsRebalance()
+	if err == nil && rebalance {
+		err = w.db.RebalanceCustomOrder()
+	}
+	if err != nil {
+		log.Print(err)
+	}
+}
 
-	w.stopper = make(chan bool)
-	w.refresh = time.NewTicker(time.Minute * time.Duration(minute))
-
-	go func(fire <-chan time.Time, stop <-chan bool, m int64) {
-		log.Printf("auto-refresh %dm: starting", m)
-		for {
-			select {
-			case <-fire:
-				log.Printf("auto-refresh %dm: firing", m)
-				w.RefreshFeeds()
-			case <-stop:
-				log.Printf("auto-refresh %dm: stopping", m)
-				return
+// SetRefreshRate sets the interval (in minutes) at which the feeds
+// are refreshed, unless they have their own storage.Feed.RefreshInterval.
+// Zero disables the auto-refresh of the feeds without one.
+func (w *Worker) SetRefreshRate(minute int64) {
+	atomic.StoreInt64(&w.refreshRate, minute)
+	w.schedulerOnce.Do(func() {
+		ticker := time.NewTicker(time.Minute)
+		go func() {
+			for range ticker.C {
+				w.refreshFeeds(true)
 			}
-		}
-	}(w.refresh.C, w.stopper, minute)
+		}()
+	})
+	log.Printf("auto-refresh: %dm", minute)
 }
 
+// RefreshFeeds refreshes all the feeds, except for the ones
+// refreshed more recently than their own interval.
 func (w *Worker) RefreshFeeds() {
+	w.refreshFeeds(false)
+}
+
+// refreshFeeds refreshes the feeds which are due. Scheduled refreshes
+// fall back to the global refresh rate for the feeds without an interval
+// and stay quiet when there's nothing to do, since they run every minute.
+// The feeds still being refreshed by a previous refresh are skipped.
+func (w *Worker) refreshFeeds(scheduled bool) {
 	w.reflock.Lock()
 	defer w.reflock.Unlock()
 
-	if *w.pending > 0 {
-		log.Print("Refreshing already in progress")
+	list, err := w.db.ListFeeds()
+	if err != nil {
+		log.Print(err)
 		return
 	}
-
-	feeds := w.db.ListFeeds()
+	rate := atomic.LoadInt64(&w.refreshRate)
+	states, err := w.db.ListHTTPStates()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	now := time.Now()
+	feeds := make([]storage.Feed, 0)
+	// their posts are delivered to the server instead, see activitypub
+	following, err := w.db.ActivityPubFeeds()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, feed := range list {
+		if storage.IsSystemFeed(feed) || feed.Paused || following[feed.Id] {
+			continue
+		}
+		interval := feed.RefreshInterval
+		if interval == 0 && scheduled {
+			if rate == 0 {
+				continue
+			}
+			interval = rate
+		}
+		if state, ok := states[feed.Id]; ok && !isDue(state.LastRefreshed, interval, now) {
+			continue
+		}
+		feeds = append(feeds, feed)
+	}
+	dormant, err := w.db.ListDormantFeeds(now.Add(-DormantFeedAfter))
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	due := len(feeds)
+	feeds = w.running.acquire(feeds)
 	if len(feeds) == 0 {
-		log.Print("Nothing to refresh")
+		if !scheduled {
+			if due > 0 {
+				log.Print("Refreshing already in progress")
+			} else {
+				log.Print("Nothing to refresh")
+			}
+		}
 		return
 	}
 
-	log.Print("Refreshing feeds")
-	atomic.StoreInt32(w.pending, int32(len(feeds)))
-	go w.refresher(feeds)
+	if skipped := due - len(feeds); skipped > 0 {
+		log.Printf("Refreshing feeds, skipping %d already in progress", skipped)
+	} else {
+		log.Print("Refreshing feeds")
+	}
+	atomic.AddInt32(w.pending, int32(len(feeds)))
+	go w.refresher(feeds, dormant)
 }
 
-func (w *Worker) refresher(feeds []storage.Feed) {
-	w.db.ResetFeedErrors()
+// isDue reports whether a feed refreshed at the given time should be
+// refreshed again. The scheduler ticks every minute and the refresh itself
+// takes a while, hence the slack: otherwise a 10m interval would become 11m.
+func isDue(lastRefreshed time.Time, interval int64, now time.Time) bool {
+	if interval <= 0 {
+		return true
+	}
+	return now.Sub(lastRefreshed) >= time.Minute*time.Duration(interval)-30*time.Second
+}
 
-	srcqueue := make(chan storage.Feed, len(feeds))
-	dstqueue := make(chan []storage.Item)
+func (w *Worker) refresher(feeds []storage.Feed, dormant map[int64]bool) {
+	ctx, span := tracing.Start(context.Background(), "refresh", tracing.KindInternal)
+	defer span.End()
+	span.SetAttr("feeds", len(feeds))
 
-	for i := 0; i < NUM_WORKERS; i++ {
-		go w.worker(srcqueue, dstqueue)
-	}
+	w.pipeline(ctx, feeds, dormant)
 
-	for _, feed := range feeds {
-		srcqueue <- feed
-	}
-	for i := 0; i < len(feeds); i++ {
-		items := <-dstqueue
-		if len(items) > 0 {
-			w.db.CreateItems(items)
-			w.db.SetFeedSize(items[0].FeedId, len(items))
-		}
-		atomic.AddInt32(w.pending, -1)
-		w.db.SyncSearch()
+	if w.downloader != nil {
+		w.downloader.Notify()
 	}
-	close(srcqueue)
-	close(dstqueue)
 
 	log.Printf("Finished refreshing %d feeds", len(feeds))
 }
-
-func (w *Worker) worker(srcqueue <-chan storage.Feed, dstqueue chan<- []storage.Item) {
-	for feed := range srcqueue {
-		items, err := listItems(feed, w.db)
-		if err != nil {
-			w.db.SetFeedError(feed.Id, err)
-		}
-		dstqueue <- items
-	}
-}